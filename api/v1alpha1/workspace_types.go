@@ -2,6 +2,7 @@
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -10,9 +11,13 @@ type WorkspaceSpec struct {
 	// User identifies the workspace owner (from OIDC).
 	User UserInfo `json:"user"`
 	// Resources defines CPU, memory, and storage for the workspace pod.
-	Resources ResourceRequirements `json:"resources"`
+	// Fields left empty are taken from the template named by TemplateRef.
+	// +optional
+	Resources ResourceRequirements `json:"resources,omitempty"`
 	// AIConfig configures the AI coding assistant (OpenAI-compatible LLM endpoint).
-	AIConfig AIConfiguration `json:"aiConfig"`
+	// Fields left empty are taken from the template named by TemplateRef.
+	// +optional
+	AIConfig AIConfiguration `json:"aiConfig,omitempty"`
 	// Persistence configures storage class for the workspace PVC.
 	Persistence PersistenceConfig `json:"persistence"`
 	// TLS configures custom TLS certificate trust for the workspace.
@@ -21,6 +26,240 @@ type WorkspaceSpec struct {
 	// Lifecycle configures optional runtime behavior such as idle shutdown.
 	// +optional
 	Lifecycle WorkspaceLifecycleSpec `json:"lifecycle,omitempty"`
+	// Suspended stops the workspace until it is set back to false: the operator
+	// deletes the pod (or scales the Deployment to zero) and sets phase Stopped.
+	// The PVC is kept. Unlike an idle stop, the gateway does not restart a
+	// suspended workspace on the next connection.
+	// +optional
+	Suspended bool `json:"suspended,omitempty"`
+	// Readiness configures how the workspace container reports readiness.
+	// +optional
+	Readiness ReadinessSpec `json:"readiness,omitempty"`
+	// StartupProbe tunes how long the workspace container may take to start
+	// ttyd before the kubelet restarts it.
+	// +optional
+	StartupProbe StartupProbeSpec `json:"startupProbe,omitempty"`
+	// Scratch mounts an emptyDir for large throwaway files such as build
+	// output, kept off the workspace PVC and the small /tmp.
+	// +optional
+	Scratch ScratchSpec `json:"scratch,omitempty"`
+	// AutoUpdate controls whether the operator recreates the workspace pod when
+	// the operator's workspace image changes. When false the running pod is kept
+	// and status.updateAvailable is set instead; delete the pod to pick up the update.
+	// +kubebuilder:default=true
+	// +optional
+	AutoUpdate *bool `json:"autoUpdate,omitempty"`
+	// Replicas above 1 run the workspace as a Deployment with that many pods
+	// behind the Service, for read-only preview workspaces. This requires
+	// spec.persistence.ephemeral or accessMode ReadWriteMany, since a
+	// ReadWriteOnce volume cannot be shared. Idle shutdown does not apply.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=10
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+	// Port is the TCP port ttyd listens on in the workspace image. It is used
+	// for the container port, probes, Service and ingress NetworkPolicy, and
+	// published in status.port for the gateway. Empty defaults to 7681.
+	// The container receives it as TTYD_PORT. Changing it recreates the
+	// workspace pod.
+	// +kubebuilder:validation:Minimum=1024
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+	// AdditionalNetworks lists Multus NetworkAttachmentDefinitions ("name" or
+	// "namespace/name") to attach as secondary interfaces. They are set on the
+	// pod's k8s.v1.cni.cncf.io/networks annotation and require Multus in the cluster.
+	// +optional
+	AdditionalNetworks []string `json:"additionalNetworks,omitempty"`
+	// DependsOn lists Workspaces in the same namespace that must be Running
+	// before this workspace's pod is created (e.g. a database workspace for an
+	// app workspace). Cycles are reported as Failed.
+	// +optional
+	DependsOn []string `json:"dependsOn,omitempty"`
+	// DataEgress opens egress to shared data services (e.g. Postgres, Redis) in
+	// other namespaces, restricted to the listed TCP ports.
+	// +optional
+	DataEgress []DataEgressRule `json:"dataEgress,omitempty"`
+	// RuntimeClassName runs the workspace pod under a RuntimeClass (e.g. "gvisor"
+	// or "kata") for sandboxed execution. Empty inherits the operator default
+	// (WORKSPACE_RUNTIME_CLASS); the RuntimeClass must exist in the cluster.
+	// +optional
+	RuntimeClassName string `json:"runtimeClassName,omitempty"`
+	// SchedulerName dispatches the workspace pod to a custom scheduler
+	// (e.g. "volcano" or "yunikorn"). Empty uses the cluster's default scheduler.
+	// +optional
+	SchedulerName string `json:"schedulerName,omitempty"`
+	// Scheduling places the workspace pod on particular nodes, e.g. a GPU or
+	// spot node pool.
+	// +optional
+	Scheduling SchedulingSpec `json:"scheduling,omitempty"`
+	// Sharing grants other users a read-only view of the workspace terminal
+	// through the gateway's /view/<user>/ link, for pairing.
+	// +optional
+	Sharing SharingSpec `json:"sharing,omitempty"`
+	// APIServerEgress allows egress to the Kubernetes API server so in-cluster
+	// tools (kubectl, k9s) can use the workspace ServiceAccount. The operator
+	// can also enable this for every workspace (API_SERVER_EGRESS).
+	// +optional
+	APIServerEgress bool `json:"apiServerEgress,omitempty"`
+	// ClusterInspection enables kubectl/k9s from inside the workspace: it opens
+	// API server egress and keeps the read-only Role bound to the workspace
+	// ServiceAccount. When false, API server egress is closed unless
+	// apiServerEgress or the operator default enables it.
+	// +optional
+	ClusterInspection bool `json:"clusterInspection,omitempty"`
+	// Repo is a git repository cloned into the workspace on first start.
+	// +optional
+	Repo RepoSpec `json:"repo,omitempty"`
+	// FeatureFlags are per-workspace feature toggles passed to the pod as JSON
+	// in FEATURE_FLAGS_JSON. Keys are alphanumeric with '.', '_' or '-'.
+	// +optional
+	FeatureFlags map[string]string `json:"featureFlags,omitempty"`
+	// Env lists extra environment variables for the workspace container, such
+	// as API keys or git settings. Secret and ConfigMap references are
+	// resolved by the kubelet when the pod starts; the operator never reads
+	// them. Names the operator sets itself are rejected.
+	// +optional
+	Env []EnvSource `json:"env,omitempty"`
+	// PodAnnotations are added to the workspace pod, replacing operator
+	// defaults (WORKSPACE_POD_ANNOTATIONS) with the same key, e.g.
+	// sidecar.istio.io/inject: "true" to opt one workspace into the mesh.
+	// +optional
+	PodAnnotations map[string]string `json:"podAnnotations,omitempty"`
+	// ServiceAnnotations are added to the workspace Service. external-dns keys
+	// (external-dns.alpha.kubernetes.io/*) are rejected: the hostname comes from
+	// the operator (WORKSPACE_EXTERNAL_DNS_HOSTNAME). Keys removed from the spec
+	// are removed from the Service.
+	// +optional
+	ServiceAnnotations map[string]string `json:"serviceAnnotations,omitempty"`
+	// TemplateRef names a WorkspaceTemplate in the same namespace. Its values
+	// fill in resources, aiConfig, runtimeClassName and schedulerName fields
+	// this spec leaves empty; fields set here always win.
+	// +optional
+	TemplateRef string `json:"templateRef,omitempty"`
+}
+
+// SchedulingSpec holds the node placement fields copied onto the workspace pod.
+type SchedulingSpec struct {
+	// NodeSelector restricts the pod to nodes carrying all of these labels.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Tolerations let the pod schedule onto nodes with matching taints.
+	// +optional
+	Tolerations []corev1.Toleration `json:"tolerations,omitempty"`
+	// NodeAffinity is a node selector term the pod's node must match, set as
+	// requiredDuringSchedulingIgnoredDuringExecution.
+	// +optional
+	NodeAffinity *corev1.NodeSelectorTerm `json:"nodeAffinity,omitempty"`
+}
+
+// SharingSpec lists who may watch the workspace terminal. Viewers see the
+// owner's tmux session but their keystrokes and resizes are dropped by the
+// gateway.
+type SharingSpec struct {
+	// Users are the user IDs (Kubernetes-safe names derived from the OIDC
+	// subject, as in spec.user.id) allowed to view.
+	// +optional
+	Users []string `json:"users,omitempty"`
+	// Groups are identity provider groups whose members may view. Groups
+	// from a gateway issuer with a userIDPrefix are written "<prefix>:<group>".
+	// +optional
+	Groups []string `json:"groups,omitempty"`
+}
+
+// RepoSpec describes a git repository to pre-seed the workspace with. The
+// entrypoint clones it once; an existing checkout is never touched again.
+type RepoSpec struct {
+	// URL is the clone URL: https://, ssh://, git:// or scp-style
+	// (git@github.com:org/repo.git). SSH URLs need a key in ~/.ssh.
+	// +optional
+	URL string `json:"url,omitempty"`
+	// Ref is the branch or tag to check out. Empty uses the remote default branch.
+	// +optional
+	Ref string `json:"ref,omitempty"`
+	// SubPath is the directory under /workspace to clone into. Empty uses the
+	// repository name.
+	// +optional
+	SubPath string `json:"subPath,omitempty"`
+}
+
+// DataEgressRule allows workspace egress to every pod in Namespace on Ports.
+type DataEgressRule struct {
+	// Namespace is the name of the namespace running the data services (e.g. "data").
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace"`
+	// Ports lists the TCP ports allowed (e.g. [5432] for Postgres, [6379] for Redis).
+	// +kubebuilder:validation:MinItems=1
+	Ports []int32 `json:"ports"`
+}
+
+// ReadinessProbeType selects the readiness check for the workspace container.
+// +kubebuilder:validation:Enum=TCP;Exec
+type ReadinessProbeType string
+
+const (
+	// ReadinessProbeTCP checks that ttyd accepts TCP connections (default).
+	ReadinessProbeTCP ReadinessProbeType = "TCP"
+	// ReadinessProbeExec runs Command inside the container; exit code 0 means ready.
+	ReadinessProbeExec ReadinessProbeType = "Exec"
+)
+
+// ReadinessSpec configures the workspace container readiness probe.
+type ReadinessSpec struct {
+	// Type is the probe mechanism. Empty defaults to TCP on the ttyd port.
+	// +optional
+	Type ReadinessProbeType `json:"type,omitempty"`
+	// Command is executed in the workspace container when Type is Exec
+	// (e.g. ["sh", "-c", "curl -fs http://localhost:8000/health"]).
+	// Required when Type is Exec; ignored otherwise.
+	// +optional
+	Command []string `json:"command,omitempty"`
+}
+
+// EnvSource is one extra environment variable for the workspace container.
+// Exactly one of Value, SecretKeyRef or ConfigMapKeyRef must be set.
+type EnvSource struct {
+	// Name of the environment variable.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Value is a literal value.
+	// +optional
+	Value string `json:"value,omitempty"`
+	// SecretKeyRef selects a key of a Secret in the workspace namespace. The
+	// Secret name must start with "<spec.user.id>-".
+	// +optional
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
+	// ConfigMapKeyRef selects a key of a ConfigMap in the workspace namespace.
+	// The ConfigMap name must start with "<spec.user.id>-".
+	// +optional
+	ConfigMapKeyRef *corev1.ConfigMapKeySelector `json:"configMapKeyRef,omitempty"`
+}
+
+// StartupProbeSpec configures the workspace container startup probe, a TCP
+// check on the ttyd port. Readiness and liveness checks wait until it passes,
+// so slow-booting images get FailureThreshold * PeriodSeconds to start.
+type StartupProbeSpec struct {
+	// FailureThreshold is how many failed checks are tolerated before the
+	// container is restarted. Zero defaults to 60.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+	// PeriodSeconds is the interval between checks. Zero defaults to 5.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=60
+	// +optional
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+}
+
+// ScratchSpec configures the optional scratch emptyDir volume. Its contents
+// are lost whenever the pod is recreated.
+type ScratchSpec struct {
+	// SizeLimit caps the volume (e.g. "50Gi"). Empty disables the volume.
+	// +optional
+	SizeLimit string `json:"sizeLimit,omitempty"`
+	// MountPath is where the volume is mounted. Empty defaults to /scratch.
+	// +optional
+	MountPath string `json:"mountPath,omitempty"`
 }
 
 // WorkspaceLifecycleSpec holds optional per-workspace runtime tuning.
@@ -31,9 +270,30 @@ type WorkspaceLifecycleSpec struct {
 	// Empty inherits the operator IDLE_TIMEOUT default (Helm values.workspace.idleTimeout).
 	// Set to "0" to disable idle shutdown for this workspace even when the operator default is non-zero.
 	// +optional
+	// +kubebuilder:validation:XValidation:rule="self == '0' || duration(self) >= duration('0s')",message="must be \"0\" or a non-negative Go duration"
 	IdleTimeout string `json:"idleTimeout,omitempty"`
+	// PreStopExec is a command run in the workspace container before it is
+	// stopped (e.g. to save tmux sessions or flush editor state). It runs within
+	// the pod's termination grace period.
+	// +optional
+	PreStopExec []string `json:"preStopExec,omitempty"`
+	// StopMode selects how the workspace is stopped. Delete (the default) runs a
+	// bare Pod and deletes it on stop. ScaleToZero runs the workspace as a
+	// Deployment and stops it by scaling to zero replicas; starting scales it
+	// back up. The PVC is kept in both modes.
+	// +optional
+	StopMode StopMode `json:"stopMode,omitempty"`
 }
 
+// StopMode is how a workspace is stopped when it goes idle.
+// +kubebuilder:validation:Enum=Delete;ScaleToZero
+type StopMode string
+
+const (
+	StopModeDelete      StopMode = "Delete"
+	StopModeScaleToZero StopMode = "ScaleToZero"
+)
+
 // UserInfo holds the sanitized user identity from OIDC.
 type UserInfo struct {
 	// ID is the sanitized username (e.g., "john").
@@ -44,14 +304,72 @@ type UserInfo struct {
 
 // ResourceRequirements defines CPU, memory, and storage requests/limits.
 type ResourceRequirements struct {
-	// CPU limit (e.g., "2").
-	CPU string `json:"cpu"`
-	// Memory limit (e.g., "4Gi").
-	Memory string `json:"memory"`
-	// Storage size for the workspace PVC (e.g., "20Gi").
-	Storage string `json:"storage"`
+	// CPU request (e.g., "2"), also used as the limit unless CPULimit or
+	// CPUBurst is set. Required unless set by the workspace template.
+	// +optional
+	// +kubebuilder:validation:XValidation:rule="isQuantity(self) && quantity(self).isGreaterThan(quantity('0'))",message="must be a quantity greater than zero"
+	CPU string `json:"cpu,omitempty"`
+	// Memory request (e.g., "4Gi"), also used as the limit unless MemoryLimit
+	// is set. Required unless set by the workspace template.
+	// +optional
+	// +kubebuilder:validation:XValidation:rule="isQuantity(self) && quantity(self).isGreaterThan(quantity('0'))",message="must be a quantity greater than zero"
+	Memory string `json:"memory,omitempty"`
+	// Storage size for the workspace PVC (e.g., "20Gi"). Required unless set by
+	// the workspace template.
+	// +optional
+	// +kubebuilder:validation:XValidation:rule="isQuantity(self) && quantity(self).isGreaterThan(quantity('0'))",message="must be a quantity greater than zero"
+	Storage string `json:"storage,omitempty"`
+	// CPUBurst is an optional factor applied to CPU to derive the container's
+	// CPU limit (e.g., "2" lets a 1-CPU workspace burst to 2 CPUs during builds).
+	// When empty or "1" the limit equals the request (Guaranteed QoS).
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	CPUBurst string `json:"cpuBurst,omitempty"`
+	// CPULimit is an explicit CPU limit (e.g., "4") at or above CPU, letting
+	// interactive work burst without throttling. Mutually exclusive with CPUBurst.
+	// +optional
+	// +kubebuilder:validation:XValidation:rule="isQuantity(self) && quantity(self).isGreaterThan(quantity('0'))",message="must be a quantity greater than zero"
+	CPULimit string `json:"cpuLimit,omitempty"`
+	// MemoryLimit is an explicit memory limit (e.g., "8Gi") at or above Memory.
+	// Empty keeps the limit equal to the request.
+	// +optional
+	// +kubebuilder:validation:XValidation:rule="isQuantity(self) && quantity(self).isGreaterThan(quantity('0'))",message="must be a quantity greater than zero"
+	MemoryLimit string `json:"memoryLimit,omitempty"`
+	// GPU requests accelerators through a device plugin's extended resource.
+	// Nil requests none.
+	// +optional
+	GPU *GPUResource `json:"gpu,omitempty"`
+	// QoSClass declares the intended Kubernetes QoS class for the workspace pod.
+	// Guaranteed sets limits equal to requests; Burstable sets requests with a
+	// memory limit and a CPU limit only when CPUBurst is set; BestEffort sets
+	// neither requests nor limits. Empty keeps the default: Guaranteed, or
+	// Burstable when CPUBurst is above 1. Burstable with CPUBurst "1" is rejected
+	// unless CPULimit or MemoryLimit is above the request.
+	// +optional
+	QoSClass QoSClass `json:"qosClass,omitempty"`
 }
 
+// GPUResource requests a number of devices of one extended resource.
+type GPUResource struct {
+	// Vendor is the extended resource name advertised by the device plugin
+	// (e.g., "nvidia.com/gpu" or "amd.com/gpu"). Empty means "nvidia.com/gpu".
+	// +optional
+	Vendor string `json:"vendor,omitempty"`
+	// Count is the number of devices, set as both request and limit.
+	// +kubebuilder:validation:Minimum=1
+	Count int32 `json:"count"`
+}
+
+// QoSClass is the declared Kubernetes QoS class for a workspace pod.
+// +kubebuilder:validation:Enum=Guaranteed;Burstable;BestEffort
+type QoSClass string
+
+const (
+	QoSClassGuaranteed QoSClass = "Guaranteed"
+	QoSClassBurstable  QoSClass = "Burstable"
+	QoSClassBestEffort QoSClass = "BestEffort"
+)
+
 // AIProvider configures a single AI provider backend.
 // The endpoint must be OpenAI API-compatible (vLLM, Ollama, OpenWebUI, etc.).
 type AIProvider struct {
@@ -71,9 +389,10 @@ type AIProvider struct {
 // AIConfiguration configures the AI assistant backend.
 type AIConfiguration struct {
 	// Providers is the list of AI provider backends available to this workspace.
-	// At least one provider must be specified.
+	// At least one provider must be specified here or in the workspace template.
+	// +optional
 	// +kubebuilder:validation:MinItems=1
-	Providers []AIProvider `json:"providers"`
+	Providers []AIProvider `json:"providers,omitempty"`
 	// EgressNamespaces lists Kubernetes namespaces where LLM services run.
 	// NetworkPolicy egress rules allow traffic to all pods in these namespaces.
 	// +optional
@@ -84,6 +403,23 @@ type AIConfiguration struct {
 	// If empty, the operator default or built-in default list is used.
 	// +optional
 	EgressPorts []int32 `json:"egressPorts,omitempty"`
+	// Settings holds assistant tuning rendered by the operator into a ConfigMap
+	// mounted in the workspace pod (see AI_SETTINGS_FILE).
+	// +optional
+	Settings AISettings `json:"settings,omitempty"`
+}
+
+// AISettings configures assistant behaviour beyond provider selection.
+type AISettings struct {
+	// Temperature is the sampling temperature between 0 and 2 (e.g., "0.2").
+	// Empty uses the assistant default.
+	// +optional
+	// +kubebuilder:validation:Pattern=`^[0-9]+(\.[0-9]+)?$`
+	Temperature string `json:"temperature,omitempty"`
+	// SystemPrompt is prepended to every conversation.
+	// +optional
+	// +kubebuilder:validation:MaxLength=16384
+	SystemPrompt string `json:"systemPrompt,omitempty"`
 }
 
 // TLSConfig configures custom TLS certificate trust for the workspace.
@@ -104,8 +440,30 @@ type CABundleRef struct {
 type PersistenceConfig struct {
 	// StorageClass is the name of the StorageClass for the workspace PVC.
 	StorageClass string `json:"storageClass,omitempty"`
+	// AccessMode is the access mode of the workspace PVC. Empty defaults to
+	// ReadWriteOnce. ReadWriteMany lets several replicas share the volume.
+	// +optional
+	AccessMode PersistenceAccessMode `json:"accessMode,omitempty"`
+	// Ephemeral replaces the workspace PVC with an emptyDir sized to
+	// spec.resources.storage. Data is lost whenever the pod is recreated.
+	// +optional
+	Ephemeral bool `json:"ephemeral,omitempty"`
+	// RestoreFrom names a VolumeSnapshot in the workspace namespace that the
+	// workspace PVC is populated from whenever it is created. Set by the
+	// gateway's checkpoint restore endpoint.
+	// +optional
+	RestoreFrom string `json:"restoreFrom,omitempty"`
 }
 
+// PersistenceAccessMode is the access mode requested for the workspace PVC.
+// +kubebuilder:validation:Enum=ReadWriteOnce;ReadWriteMany
+type PersistenceAccessMode string
+
+const (
+	PersistenceAccessModeReadWriteOnce PersistenceAccessMode = "ReadWriteOnce"
+	PersistenceAccessModeReadWriteMany PersistenceAccessMode = "ReadWriteMany"
+)
+
 // WorkspacePhase is the lifecycle phase of a Workspace.
 type WorkspacePhase string
 
@@ -125,13 +483,19 @@ type WorkspaceStatus struct {
 	PodName string `json:"podName,omitempty"`
 	// ServiceEndpoint is the internal service DNS name for the workspace.
 	ServiceEndpoint string `json:"serviceEndpoint,omitempty"`
+	// Port is the ttyd port behind ServiceEndpoint. Zero (from operators that
+	// predate the field) means 7681.
+	// +optional
+	Port int32 `json:"port,omitempty"`
 	// Message is a human-readable error or info (e.g. validation failure, PVC not bound).
 	Message string `json:"message,omitempty"`
 	// RemediationHint is a short, non-secret operator hint when phase is Failed or
 	// the workspace is not Ready (e.g. verify RBAC, image pull, or storage class).
 	// +optional
 	RemediationHint string `json:"remediationHint,omitempty"`
-	// Conditions represent the current state of the workspace (for example Ready).
+	// Conditions represent the current state of the workspace: Ready,
+	// PodScheduled (mirrored from the workspace pod), NetworkPoliciesReady and,
+	// when CA bundle validation is enabled, CABundleValid.
 	// +optional
 	// +patchMergeKey=type
 	// +patchStrategy=merge
@@ -140,11 +504,56 @@ type WorkspaceStatus struct {
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
 	// LastAccessed is when the workspace was last accessed by the user.
 	LastAccessed metav1.Time `json:"lastAccessed,omitempty"`
+	// IdleStopAt is when the operator will stop this idle workspace unless
+	// lastAccessed advances first. Set only while an idle grace period is
+	// running; cleared on activity or once the workspace stops.
+	// +optional
+	IdleStopAt metav1.Time `json:"idleStopAt,omitempty"`
+	// StorageCapacity is the provisioned capacity of the bound workspace PVC
+	// (status.capacity.storage, e.g. "20Gi"). It may exceed spec.resources.storage
+	// when the storage class rounds up or the volume was expanded.
+	// +optional
+	StorageCapacity string `json:"storageCapacity,omitempty"`
+	// UpdateAvailable is true when spec.autoUpdate is false and the running pod
+	// uses an older image than the operator's current workspace image.
+	// +optional
+	UpdateAvailable bool `json:"updateAvailable,omitempty"`
+	// ImageRolloutStartedAt is when the operator deleted the pod to move it to a
+	// new workspace image. It holds one of MAX_CONCURRENT_IMAGE_ROLLOUTS until
+	// the recreated pod is Ready, or for at most ten minutes.
+	// +optional
+	ImageRolloutStartedAt metav1.Time `json:"imageRolloutStartedAt,omitempty"`
+	// LastReconcileTime is when the operator last finished reconciling this
+	// workspace. It is refreshed at most once a minute while the outcome is unchanged.
+	// +optional
+	LastReconcileTime metav1.Time `json:"lastReconcileTime,omitempty"`
+	// LastReconcileResult is the outcome of the last reconcile: "Success", or
+	// "Error: " followed by the error message.
+	// +optional
+	LastReconcileResult string `json:"lastReconcileResult,omitempty"`
+	// ManagedResources lists the owned resources the operator ensured during
+	// the last reconcile, in creation order. Resources not reached because the
+	// reconcile stopped early (e.g. the PVC is still binding) are omitted.
+	// +optional
+	ManagedResources []ManagedResource `json:"managedResources,omitempty"`
+}
+
+// ManagedResource identifies one resource owned by a Workspace.
+type ManagedResource struct {
+	// Kind is the resource kind, e.g. Pod or NetworkPolicy.
+	Kind string `json:"kind"`
+	// Name is the resource name in the workspace namespace.
+	Name string `json:"name"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
 //+kubebuilder:resource:path=workspaces,scope=Namespaced,shortName=ws
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Pod",type=string,JSONPath=`.status.podName`
+//+kubebuilder:printcolumn:name="Endpoint",type=string,JSONPath=`.status.serviceEndpoint`,priority=1
+//+kubebuilder:printcolumn:name="Last Accessed",type=date,JSONPath=`.status.lastAccessed`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Workspace is the Schema for the workspaces API.
 type Workspace struct {
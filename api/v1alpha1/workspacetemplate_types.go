@@ -0,0 +1,46 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WorkspaceTemplateSpec holds defaults shared by the Workspaces that reference
+// the template through spec.templateRef.
+type WorkspaceTemplateSpec struct {
+	// Resources supplies CPU, memory, and storage values a workspace leaves empty.
+	// +optional
+	Resources ResourceRequirements `json:"resources,omitempty"`
+	// AIConfig supplies AI providers and settings a workspace leaves empty.
+	// +optional
+	AIConfig AIConfiguration `json:"aiConfig,omitempty"`
+	// RuntimeClassName is used when a workspace sets no spec.runtimeClassName.
+	// +optional
+	RuntimeClassName string `json:"runtimeClassName,omitempty"`
+	// SchedulerName is used when a workspace sets no spec.schedulerName.
+	// +optional
+	SchedulerName string `json:"schedulerName,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:path=workspacetemplates,scope=Namespaced,shortName=wst
+
+// WorkspaceTemplate is the Schema for the workspacetemplates API.
+type WorkspaceTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec WorkspaceTemplateSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// WorkspaceTemplateList contains a list of WorkspaceTemplate.
+type WorkspaceTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WorkspaceTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&WorkspaceTemplate{}, &WorkspaceTemplateList{})
+}
@@ -4,24 +4,35 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
+	"math/rand/v2"
+	"net"
 	"net/http"
 	"net/http/httputil"
+	"net/http/pprof"
 	"net/url"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	gooidc "github.com/coreos/go-oidc/v3/oidc"
 	"github.com/go-logr/logr"
 	"github.com/go-logr/zapr"
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 	"golang.org/x/oauth2"
@@ -51,15 +62,54 @@ type workspaceLifecycle interface {
 	TouchLastAccessed(ctx context.Context, ws *workspacev1alpha1.Workspace)
 }
 
+// tokenCacheInvalidator evicts cached token validations so the next request
+// for an affected token is re-verified against the IdP.
+type tokenCacheInvalidator interface {
+	EvictUser(user string) int
+	EvictTokenHash(tokenHash string) int
+}
+
+// kubeconfigIssuer mints a kubeconfig for the user's workspace ServiceAccount.
+type kubeconfigIssuer interface {
+	Issue(ctx context.Context, namespace, userID string) ([]byte, time.Time, error)
+}
+
+// workspaceHealthChecker reports the health of a user's workspace for monitors.
+type workspaceHealthChecker interface {
+	Check(ctx context.Context, namespace, userID string) (gw.WorkspaceHealth, error)
+}
+
+// checkpointManager snapshots the user's workspace volume and restores from it.
+type checkpointManager interface {
+	Create(ctx context.Context, namespace, userID, name string) (*gw.Checkpoint, error)
+	Restore(ctx context.Context, namespace, userID, name string) (*gw.Checkpoint, error)
+}
+
+// sharedWorkspaces resolves workspaces other users have shared read-only.
+type sharedWorkspaces interface {
+	Viewable(ctx context.Context, namespace, ownerID string, viewer *gw.Claims) (*workspacev1alpha1.Workspace, error)
+}
+
 // wsProxy proxies a WebSocket connection to a backend URL.
 type wsProxy interface {
-	ServeWS(w http.ResponseWriter, r *http.Request, backendURL string, onActivity func(), onFrame gw.FrameObserver) error
+	ServeWS(w http.ResponseWriter, r *http.Request, userID, backendURL string, onActivity func(), onFrame gw.FrameObserver) error
+	// ServeWSReadOnly proxies a viewer's connection, dropping their input.
+	ServeWSReadOnly(w http.ResponseWriter, r *http.Request, userID, backendURL string, onFrame gw.FrameObserver) error
+	// BackendURL returns the ws:// or wss:// URL of a workspace's ttyd service.
+	BackendURL(serviceEndpoint string, port int32) string
+}
+
+// httpBackend builds the http:// or https:// URL of a workspace's ttyd
+// service, matching the scheme the WebSocket tunnel dials.
+type httpBackend interface {
+	BackendHTTPURL(serviceEndpoint string, port int32) string
 }
 
 // oauthConfig abstracts *oauth2.Config for testability.
 type oauthConfig interface {
 	AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string
 	Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error)
+	TokenSource(ctx context.Context, t *oauth2.Token) oauth2.TokenSource
 }
 
 func init() {
@@ -89,10 +139,20 @@ func main() {
 		os.Exit(1)
 	}
 
-	cookieSecure := strings.HasPrefix(redirectURL, "https://")
+	cookieSecure, err := parseCookieSecure(redirectURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid COOKIE_SECURE: %v\n", err)
+		os.Exit(1)
+	}
 
 	ctx := ctrl.SetupSignalHandler()
 
+	discoveryRetry, err := parseOIDCDiscoveryRetry()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid OIDC discovery retry settings: %v\n", err)
+		os.Exit(1)
+	}
+
 	var validator tokenValidator
 	if os.Getenv("GATEWAY_DEV_INSECURE_FIXED_IDENTITY") == "1" {
 		devSub := envOr("GATEWAY_DEV_USER_SUB", "dev-user")
@@ -101,31 +161,84 @@ func main() {
 		log.Info("DEV ONLY: fixed-identity auth enabled; OIDC JWT verification is disabled",
 			"devUserSub", devSub)
 	} else {
-		audienceOverride := strings.TrimSpace(os.Getenv("OIDC_AUDIENCE"))
+		// OIDC_AUDIENCE optionally lists the accepted "aud" values, comma
+		// separated, when the API audience differs from the OAuth client ID.
+		audiences := parseCommaList(os.Getenv("OIDC_AUDIENCE"))
 		clockSkew, err := parseOIDCClockSkew()
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "invalid OIDC_CLOCK_SKEW: %v\n", err)
 			os.Exit(1)
 		}
-		v, err := gw.NewValidatorWithOIDC(ctx, gw.OIDCConfig{
-			IssuerURL: issuerURL,
-			ClientID:  clientID,
-			Audience:  audienceOverride,
-			ClockSkew: clockSkew,
-		})
+		// OIDC_GROUPS_CLAIM names the claim carrying group or role names
+		// (default "groups"). ALLOWED_GROUPS is an optional comma-separated
+		// allowlist; valid tokens in none of the groups get 403.
+		groupsClaim := envOr("OIDC_GROUPS_CLAIM", gw.DefaultGroupsClaim)
+		allowedGroups := parseCommaList(os.Getenv("ALLOWED_GROUPS"))
+		tokenCache, err := parseTokenCacheConfig()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid token cache settings: %v\n", err)
+			os.Exit(1)
+		}
+		// Served on /metrics alongside the other gateway metrics.
+		tokenCache.Registerer = prometheus.DefaultRegisterer
+		oidcCfg := gw.OIDCConfig{
+			IssuerURL:       issuerURL,
+			ClientID:        clientID,
+			Audiences:       audiences,
+			ClockSkew:       clockSkew,
+			Discovery:       discoveryRetry,
+			GroupsClaim:     groupsClaim,
+			AllowedGroups:   allowedGroups,
+			ValidatorConfig: tokenCache,
+		}
+		v, err := gw.NewValidatorWithOIDC(ctx, oidcCfg)
 		if err != nil {
 			log.Error(err, "Failed to initialize OIDC validator")
 			os.Exit(1)
 		}
 		validator = v
-		effectiveAud := audienceOverride
-		if effectiveAud == "" {
-			effectiveAud = clientID
+		// OIDC_ISSUERS_JSON optionally lists further issuers whose tokens are
+		// accepted alongside OIDC_ISSUER_URL, e.g.
+		// [{"issuer":"https://contractors.example.auth0.com/","clientID":"devplane","userIDPrefix":"ctr"}].
+		// Their user IDs become "<userIDPrefix>--<sub>" and their groups
+		// "<userIDPrefix>:<group>" so neither collides with OIDC_ISSUER_URL's;
+		// ALLOWED_GROUPS applies to all issuers. Browser login still goes to OIDC_ISSUER_URL.
+		extraIssuers, err := parseOIDCIssuers(os.Getenv("OIDC_ISSUERS_JSON"))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid OIDC_ISSUERS_JSON: %v\n", err)
+			os.Exit(1)
+		}
+		if len(extraIssuers) > 0 {
+			validators := []*gw.Validator{v}
+			for _, iss := range extraIssuers {
+				cfg := oidcCfg
+				cfg.IssuerURL, cfg.ClientID, cfg.Audiences = iss.Issuer, iss.ClientID, iss.Audiences
+				cfg.UserIDPrefix = iss.UserIDPrefix
+				extra, err := gw.NewValidatorWithOIDC(ctx, cfg)
+				if err != nil {
+					log.Error(err, "Failed to initialize OIDC validator", "issuer", iss.Issuer)
+					os.Exit(1)
+				}
+				validators = append(validators, extra)
+				log.Info("Additional OIDC issuer ready", "issuer", iss.Issuer, "clientID", iss.ClientID)
+			}
+			multi, err := gw.NewMultiValidator(validators...)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "invalid OIDC_ISSUERS_JSON: %v\n", err)
+				os.Exit(1)
+			}
+			validator = multi
+		}
+		effectiveAud := audiences
+		if len(effectiveAud) == 0 {
+			effectiveAud = []string{clientID}
 		}
-		log.Info("OIDC validator ready", "issuer", issuerURL, "audience", effectiveAud, "clockSkew", clockSkew.String())
+		log.Info("OIDC validator ready", "issuer", issuerURL, "audiences", effectiveAud, "clockSkew", clockSkew.String(),
+			"groupsClaim", groupsClaim, "allowedGroups", allowedGroups,
+			"tokenCacheTTL", tokenCache.CacheTTL.String(), "tokenCacheMax", tokenCache.CacheMax)
 	}
 
-	oidcProvider, err := gooidc.NewProvider(ctx, issuerURL)
+	oidcProvider, err := gw.DiscoverProvider(ctx, issuerURL, discoveryRetry)
 	if err != nil {
 		log.Error(err, "Failed to initialize OIDC provider for OAuth2 flow")
 		os.Exit(1)
@@ -149,40 +262,224 @@ func main() {
 		os.Exit(1)
 	}
 
+	// GATEWAY_TRUSTED_PROXIES is an optional comma-separated list of CIDRs whose
+	// X-Forwarded-For header is trusted when logging the client address.
+	trustedProxies, err := gw.ParseTrustedProxies(os.Getenv("GATEWAY_TRUSTED_PROXIES"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid GATEWAY_TRUSTED_PROXIES: %v\n", err)
+		os.Exit(1)
+	}
+
+	maxProvisioningWaits, err := parseMaxProvisioningWaits()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid GATEWAY_MAX_PROVISIONING_WAITS: %v\n", err)
+		os.Exit(1)
+	}
+	maxWorkspacesPerUser, err := parseMaxWorkspacesPerUser()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid GATEWAY_MAX_WORKSPACES_PER_USER: %v\n", err)
+		os.Exit(1)
+	}
+	readyTimeout, err := parseWorkspaceReadyTimeout()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid WORKSPACE_READY_TIMEOUT: %v\n", err)
+		os.Exit(1)
+	}
+	minCreateInterval, err := parseMinCreateInterval()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid GATEWAY_MIN_CREATE_INTERVAL: %v\n", err)
+		os.Exit(1)
+	}
 	lifecycle := gw.NewLifecycleManager(k8sClient, log, gw.LifecycleConfig{
-		Providers:      aiProviders,
-		DefaultCPU:     envOr("DEFAULT_CPU", "2"),
-		DefaultMemory:  envOr("DEFAULT_MEMORY", "4Gi"),
-		DefaultStorage: envOr("DEFAULT_STORAGE", "20Gi"),
-		StorageClass:   os.Getenv("DEFAULT_STORAGE_CLASS"),
+		Providers:            aiProviders,
+		DefaultCPU:           envOr("DEFAULT_CPU", "2"),
+		DefaultMemory:        envOr("DEFAULT_MEMORY", "4Gi"),
+		DefaultStorage:       envOr("DEFAULT_STORAGE", "20Gi"),
+		StorageClass:         os.Getenv("DEFAULT_STORAGE_CLASS"),
+		MaxConcurrentWaits:   maxProvisioningWaits,
+		MaxWorkspacesPerUser: maxWorkspacesPerUser,
+		ReadyTimeout:         readyTimeout,
+		MinCreateInterval:    minCreateInterval,
+	})
+	wsKeepalive, err := parseWSKeepaliveInterval()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid GATEWAY_WS_KEEPALIVE_INTERVAL: %v\n", err)
+		os.Exit(1)
+	}
+	backendTLS, err := parseBackendTLS()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid GATEWAY_BACKEND_TLS: %v\n", err)
+		os.Exit(1)
+	}
+	maxWSConnsPerUser, err := parseMaxWSConnectionsPerUser()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid GATEWAY_MAX_WS_CONNECTIONS_PER_USER: %v\n", err)
+		os.Exit(1)
+	}
+	backendTransportCfg, err := parseBackendTransportConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid backend transport settings: %v\n", err)
+		os.Exit(1)
+	}
+	// Shared by every HTTP reverse proxy to workspace pods so keep-alive
+	// connections are pooled and expire instead of outliving a recreated pod.
+	backendTransportCfg.TLS = backendTLS
+	backendTransport := gw.NewBackendTransport(backendTransportCfg)
+	proxy := gw.NewProxy(log, gw.ProxyConfig{
+		KeepaliveInterval:     wsKeepalive,
+		BackendTLS:            backendTLS,
+		BackendPath:           os.Getenv("GATEWAY_BACKEND_PATH"),
+		MaxConnectionsPerUser: maxWSConnsPerUser,
 	})
-	proxy := gw.NewProxy(log)
 
 	lifecycleRL := gw.LoadEndpointLimiterFromEnv("GATEWAY_RL_LIFECYCLE_")
 	wsRL := gw.LoadEndpointLimiterFromEnv("GATEWAY_RL_WS_")
 
+	handlerTimeout, err := parseHandlerTimeout()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid GATEWAY_HANDLER_TIMEOUT: %v\n", err)
+		os.Exit(1)
+	}
+
+	maxSessionAge, err := parseMaxSessionAge()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid MAX_SESSION_AGE: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Browser sessions whose ID token expired are renewed with the refresh
+	// token stored at login, when the IdP issued one.
+	refresher := &sessionRefresher{cfg: oauth2Cfg, validator: validator, secure: cookieSecure, maxSessionAge: maxSessionAge, proxies: trustedProxies, log: log}
+
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
 	mux.HandleFunc("/health", handleHealth)
-	mux.HandleFunc("/api/workspace", func(w http.ResponseWriter, r *http.Request) {
-		handleWorkspaceAPI(w, r, validator, lifecycle, namespace, cookieSecure, log, lifecycleRL)
-	})
+	mux.Handle("/api/workspace", withTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleWorkspaceAPI(w, r, validator, lifecycle, namespace, cookieSecure, trustedProxies, log, lifecycleRL)
+	}), handlerTimeout))
+	// No handler timeout: WebSocket sessions are long-lived.
 	mux.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
-		handleWS(w, r, validator, lifecycle, proxy, namespace, log, wsRL)
-	})
-	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
-		handleLogin(w, r, oauth2Cfg, cookieSecure, log)
+		handleWS(w, r, validator, refresher, lifecycle, proxy, namespace, trustedProxies, log, wsRL)
 	})
-	mux.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
-		handleCallback(w, r, oauth2Cfg, validator, cookieSecure, log)
+	mux.Handle("/login", withTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleLogin(w, r, oauth2Cfg, cookieSecure, trustedProxies, log)
+	}), handlerTimeout))
+	mux.Handle("/callback", withTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleCallback(w, r, oauth2Cfg, validator, cookieSecure, maxSessionAge, log)
+	}), handlerTimeout))
+	// GATEWAY_LOGOUT_REDIRECT_HOSTS is an optional comma-separated allow-list
+	// of hosts /logout may send the browser to via post_logout_redirect_uri.
+	logoutRedirectHosts := parseCommaList(os.Getenv("GATEWAY_LOGOUT_REDIRECT_HOSTS"))
+	mux.Handle("/logout", withTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleLogout(w, r, logoutRedirectHosts, cookieSecure, trustedProxies, log)
+	}), handlerTimeout))
+	// GATEWAY_ADMIN_TOKEN is an optional shared secret that enables the admin
+	// cache-invalidation endpoints. When unset the endpoints are not registered.
+	if adminToken := os.Getenv("GATEWAY_ADMIN_TOKEN"); adminToken != "" {
+		if inv, ok := validator.(tokenCacheInvalidator); ok {
+			adminHandler := withTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				handleAdminInvalidate(w, r, inv, adminToken, trustedProxies, log)
+			}), handlerTimeout)
+			mux.Handle("POST /api/admin/invalidate/{user}", adminHandler)
+			mux.Handle("POST /api/admin/invalidate/token/{hash}", adminHandler)
+			log.Info("Admin token cache invalidation endpoints enabled")
+		}
+	}
+	// GET /api/workspaces/{user}/healthz lets external uptime monitors check a
+	// user's workspace. It is enabled by GATEWAY_MONITOR_TOKEN and/or
+	// GATEWAY_ADMIN_TOKEN, either of which is accepted as the bearer token.
+	if tokens := nonEmpty(os.Getenv("GATEWAY_MONITOR_TOKEN"), os.Getenv("GATEWAY_ADMIN_TOKEN")); len(tokens) > 0 {
+		checker := gw.NewHealthChecker(k8sClient, proxy, backendTransport)
+		mux.Handle("GET /api/workspaces/{user}/healthz", withTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handleWorkspaceHealth(w, r, checker, tokens, namespace, log)
+		}), handlerTimeout))
+		log.Info("Workspace health endpoint enabled")
+	}
+	// GATEWAY_KUBECONFIG_SERVER is the externally reachable API server URL. When
+	// set, GET /api/me/kubeconfig hands out kubeconfigs for the caller's
+	// workspace ServiceAccount, verified with GATEWAY_KUBECONFIG_CA_FILE and
+	// valid for GATEWAY_KUBECONFIG_TOKEN_TTL.
+	if server := os.Getenv("GATEWAY_KUBECONFIG_SERVER"); server != "" {
+		kcCfg, err := parseKubeconfigConfig(server)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid kubeconfig download settings: %v\n", err)
+			os.Exit(1)
+		}
+		issuer := gw.NewKubeconfigIssuer(k8sClient, kcCfg)
+		mux.Handle("GET /api/me/kubeconfig", withTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handleKubeconfig(w, r, validator, issuer, namespace, trustedProxies, log, lifecycleRL)
+		}), handlerTimeout))
+		log.Info("Kubeconfig download endpoint enabled", "server", server, "tokenTTL", kcCfg.TokenTTL.String())
+	}
+	// GATEWAY_SNAPSHOTS=1 enables on-demand checkpoints: VolumeSnapshots of the
+	// caller's workspace PVC (class GATEWAY_VOLUME_SNAPSHOT_CLASS, or the
+	// cluster default) and restoring the workspace from one of them.
+	if os.Getenv("GATEWAY_SNAPSHOTS") == "1" {
+		checkpoints := gw.NewCheckpointManager(k8sClient, gw.CheckpointConfig{
+			VolumeSnapshotClassName: os.Getenv("GATEWAY_VOLUME_SNAPSHOT_CLASS"),
+		})
+		mux.Handle("POST /api/workspaces/me/checkpoints", withTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handleCheckpoint(w, r, validator, checkpoints, namespace, false, trustedProxies, log, lifecycleRL)
+		}), handlerTimeout))
+		mux.Handle("POST /api/workspaces/me/restore", withTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handleCheckpoint(w, r, validator, checkpoints, namespace, true, trustedProxies, log, lifecycleRL)
+		}), handlerTimeout))
+		log.Info("Workspace checkpoint endpoints enabled")
+	}
+	// GATEWAY_LANDING_PAGE=1 serves a static sign-in page to unauthenticated
+	// browsers instead of redirecting them straight to the identity provider.
+	landingPage := os.Getenv("GATEWAY_LANDING_PAGE") == "1"
+
+	// Read-only views of workspaces shared through spec.sharing. The page under
+	// /view/<owner>/ is the owner's ttyd frontend, whose WebSocket URL resolves
+	// to /view/<owner>/ws.
+	sharing := gw.NewSharingManager(k8sClient)
+	mux.HandleFunc("/view/{user}/ws", func(w http.ResponseWriter, r *http.Request) {
+		handleViewWS(w, r, validator, refresher, sharing, proxy, namespace, trustedProxies, log, wsRL)
 	})
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		handleProxy(w, r, validator, lifecycle, namespace, cookieSecure, log)
+	// ttyd started with a base path (GATEWAY_BACKEND_PATH) opens its socket
+	// deeper under /view/<owner>/; every upgrade goes through the read-only
+	// tunnel, never the page proxy.
+	viewPage := withTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleViewPage(w, r, validator, refresher, sharing, proxy, backendTransport, namespace, landingPage, log)
+	}), handlerTimeout)
+	mux.HandleFunc("/view/{user}/", func(w http.ResponseWriter, r *http.Request) {
+		if isWebSocketUpgrade(r) {
+			handleViewWS(w, r, validator, refresher, sharing, proxy, namespace, trustedProxies, log, wsRL)
+			return
+		}
+		viewPage.ServeHTTP(w, r)
 	})
+	mux.Handle("/", withTimeout(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleProxy(w, r, validator, refresher, lifecycle, proxy, backendTransport, namespace, cookieSecure, landingPage, log)
+	}), handlerTimeout))
+
+	// GATEWAY_PPROF=1 exposes net/http/pprof on the separate listener named by
+	// GATEWAY_PPROF_ADDR (e.g. "127.0.0.1:6060"). The profiles carry no auth,
+	// so they are never mounted on the public mux.
+	pprofEnabled := os.Getenv("GATEWAY_PPROF") == "1"
+	pprofAddr := os.Getenv("GATEWAY_PPROF_ADDR")
+	if pprofEnabled && pprofAddr == "" {
+		fmt.Fprintf(os.Stderr, "GATEWAY_PPROF=1 requires GATEWAY_PPROF_ADDR\n")
+		os.Exit(1)
+	}
+	var pprofSrv *http.Server
+	if pprofEnabled {
+		pprofMux := http.NewServeMux()
+		registerPprof(pprofMux, true)
+		pprofSrv = &http.Server{Addr: pprofAddr, Handler: pprofMux, ReadTimeout: 30 * time.Second}
+		go func() {
+			log.Info("pprof listening", "addr", pprofAddr)
+			if err := pprofSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Error(err, "pprof server failed")
+			}
+		}()
+	}
+	registerPprof(mux, false)
 
 	srv := &http.Server{
 		Addr:        ":" + port,
-		Handler:     mux,
+		Handler:     withAccessLog(mux, trustedProxies, log),
 		ReadTimeout: 30 * time.Second,
 		// No write timeout: WebSocket connections are long-lived.
 	}
@@ -201,9 +498,21 @@ func main() {
 		log.Info("Shutting down gateway server")
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
+		// srv.Shutdown does not track hijacked WebSocket connections; drain the
+		// tunnels alongside it so clients get a close frame instead of a reset.
+		var drain sync.WaitGroup
+		drain.Go(func() {
+			if err := proxy.Shutdown(shutdownCtx); err != nil {
+				log.Error(err, "WebSocket tunnels not drained before shutdown deadline")
+			}
+		})
 		if err := srv.Shutdown(shutdownCtx); err != nil {
 			log.Error(err, "Server shutdown error")
 		}
+		drain.Wait()
+		if pprofSrv != nil {
+			_ = pprofSrv.Shutdown(shutdownCtx)
+		}
 	case err := <-srvErr:
 		if err != nil {
 			log.Error(err, "Server failed")
@@ -247,6 +556,26 @@ const loadingPageTmpl = `<!DOCTYPE html>
 
 var loadingTmpl = template.Must(template.New("loading").Parse(loadingPageTmpl))
 
+// registerPprof mounts the net/http/pprof handlers on mux. When disabled the
+// /debug/pprof/ tree answers 404 instead of falling through to the workspace proxy.
+func registerPprof(mux *http.ServeMux, enabled bool) {
+	if !enabled {
+		mux.Handle("/debug/pprof/", http.NotFoundHandler())
+		return
+	}
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
+
+// landingPageHTML is the static sign-in page served to unauthenticated browsers
+// when GATEWAY_LANDING_PAGE is enabled.
+//
+//go:embed landing.html
+var landingPageHTML []byte
+
 // fullWidthTerminalCSS is injected into proxied ttyd HTML so the terminal
 // container fills the browser viewport (fixes narrow layout).
 const fullWidthTerminalCSS = `<style>html,body{width:100%;height:100%;margin:0;padding:0;overflow:hidden;box-sizing:border-box}body>div,#terminal{width:100%!important;height:100%!important;margin:0!important;padding:0!important;box-sizing:border-box}</style>`
@@ -310,19 +639,19 @@ type workspaceAPIResponse struct {
 // raw terminal WebSocket.
 func handleWorkspaceAPI(w http.ResponseWriter, r *http.Request,
 	validator tokenValidator, lifecycle workspaceLifecycle,
-	namespace string, secure bool, log logr.Logger,
+	namespace string, secure bool, proxies gw.TrustedProxies, log logr.Logger,
 	lifecycleRL *gw.EndpointLimiter,
 ) {
 	if r.Method != http.MethodGet && r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		gw.WriteAPIError(w, http.StatusMethodNotAllowed, gw.MethodNotAllowedErrorCode)
 		return
 	}
 	reqID := gw.RequestID(w, r)
 	log = log.WithValues(gw.LogKeyRequestID, reqID)
 	rawToken, err := extractToken(r)
 	if err != nil {
-		gw.LogAuthTokenRejected(log, reqID, r.RemoteAddr, "missing_token", http.StatusUnauthorized, gw.AuthErrorCodeUnauthorized)
-		gw.WriteJSONAuthError(w, http.StatusUnauthorized, gw.AuthErrorCodeUnauthorized)
+		gw.LogAuthTokenRejected(log, reqID, proxies.ClientIP(r), "missing_token", http.StatusUnauthorized, gw.AuthErrorCodeUnauthorized)
+		gw.WriteAPIError(w, http.StatusUnauthorized, gw.AuthErrorCodeUnauthorized)
 		return
 	}
 	claims, err := validator.Validate(r.Context(), rawToken)
@@ -336,28 +665,48 @@ func handleWorkspaceAPI(w http.ResponseWriter, r *http.Request,
 			Secure:   secure,
 		})
 		st, code := gw.AuthErrorResponse(err)
-		gw.LogAuthTokenRejected(log, reqID, r.RemoteAddr, "invalid_token", st, code)
-		gw.WriteJSONAuthError(w, st, code)
+		gw.LogAuthTokenRejected(log, reqID, proxies.ClientIP(r), "invalid_token", st, code)
+		gw.WriteAPIError(w, st, code)
 		return
 	}
+	setAccessLogUser(r.Context(), claims.UserID)
 	if ok, scope := lifecycleRL.Allow(claims.Sub); !ok {
 		gw.RecordRateLimitHit("lifecycle", scope)
 		log.Info("Rate limit exceeded", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventRateLimited,
 			"scope", scope, "user", claims.UserID)
 		gw.LogRateLimitAudit(log, reqID, "lifecycle", scope, claims.UserID)
-		gw.WriteJSONError(w, http.StatusTooManyRequests, gw.RateLimitErrorCode)
+		gw.WriteAPIError(w, http.StatusTooManyRequests, gw.RateLimitErrorCode)
 		return
 	}
 	ws, details, err := lifecycle.EnsureExists(r.Context(), namespace, claims)
+	if errors.Is(err, gw.ErrQuotaExceeded) {
+		log.Info("Workspace quota exceeded", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventWorkspaceError, "user", claims.UserID)
+		gw.WriteAPIError(w, http.StatusTooManyRequests, gw.WorkspaceErrorCodeQuotaExceeded)
+		return
+	}
+	var throttled *gw.CreateThrottledError
+	if errors.As(err, &throttled) {
+		log.Info("Workspace creation throttled", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventRateLimited, "user", claims.UserID)
+		gw.SetRetryAfter(w, throttled.RetryAfter)
+		gw.WriteAPIError(w, http.StatusTooManyRequests, gw.RateLimitErrorCode)
+		return
+	}
+	var elsewhere *gw.WorkspaceElsewhereError
+	if errors.As(err, &elsewhere) {
+		log.Info("Workspace exists in another namespace", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventWorkspaceError,
+			"user", claims.UserID, "workspaceNamespace", elsewhere.Namespace)
+		gw.WriteAPIWorkspaceElsewhere(w, elsewhere)
+		return
+	}
 	if err != nil {
 		log.Error(err, "EnsureExists failed (API)", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventWorkspaceError, "user", claims.UserID)
-		gw.WriteJSONError(w, http.StatusInternalServerError, gw.WorkspaceErrorCodeUnavailable)
+		gw.WriteAPIError(w, http.StatusInternalServerError, gw.WorkspaceErrorCodeUnavailable)
 		return
 	}
 	gw.LogWorkspaceLifecycleAudit(log, "audit: workspace ensure (API)", reqID, gw.EventAuditWorkspaceEnsureExists, namespace, claims, ws, details)
 	ready := ws.Status.Phase == workspacev1alpha1.WorkspacePhaseRunning &&
 		ws.Status.ServiceEndpoint != "" &&
-		gw.BackendReady(ws.Status.ServiceEndpoint)
+		gw.BackendReady(ws.Status.ServiceEndpoint, gw.BackendPort(ws))
 	resp := workspaceAPIResponse{
 		Name:            ws.Name,
 		Namespace:       ws.Namespace,
@@ -376,6 +725,264 @@ func handleWorkspaceAPI(w http.ResponseWriter, r *http.Request,
 	_ = enc.Encode(resp)
 }
 
+// adminInvalidateResponse is the JSON body for POST /api/admin/invalidate/*.
+type adminInvalidateResponse struct {
+	Evicted int `json:"evicted"`
+}
+
+// handleAdminInvalidate evicts cached token validations for a user
+// (/api/admin/invalidate/{user}, matched against UserID or OIDC sub) or for a
+// single token (/api/admin/invalidate/token/{hash}, hex SHA-256 of the raw
+// token). Callers authenticate with "Authorization: Bearer <GATEWAY_ADMIN_TOKEN>".
+func handleAdminInvalidate(w http.ResponseWriter, r *http.Request,
+	inv tokenCacheInvalidator, adminToken string, proxies gw.TrustedProxies, log logr.Logger,
+) {
+	reqID := gw.RequestID(w, r)
+	log = log.WithValues(gw.LogKeyRequestID, reqID)
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") ||
+		subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(adminToken)) != 1 {
+		gw.LogAudit(log, "audit: admin cache invalidation denied", reqID, gw.EventAuditAdminCacheInvalidate,
+			gw.LogKeyAuditOutcome, gw.OutcomeDenied,
+			"remote", proxies.ClientIP(r),
+		)
+		gw.WriteAPIError(w, http.StatusUnauthorized, gw.AuthErrorCodeUnauthorized)
+		return
+	}
+
+	var evicted int
+	target := "user"
+	if hash := r.PathValue("hash"); hash != "" {
+		target = "tokenHash"
+		evicted = inv.EvictTokenHash(hash)
+	} else {
+		evicted = inv.EvictUser(r.PathValue("user"))
+	}
+	gw.LogAudit(log, "audit: admin cache invalidation", reqID, gw.EventAuditAdminCacheInvalidate,
+		gw.LogKeyAuditOutcome, gw.OutcomeSuccess,
+		"target", target,
+		gw.LogKeyUserID, r.PathValue("user"),
+		"evicted", evicted,
+		"remote", proxies.ClientIP(r),
+	)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(adminInvalidateResponse{Evicted: evicted})
+}
+
+// handleWorkspaceHealth serves GET /api/workspaces/{user}/healthz for external
+// monitors: 200 when the workspace is Running and its ttyd backend answers,
+// 503 with the phase and message otherwise. Callers authenticate with
+// "Authorization: Bearer <token>" for one of tokens.
+func handleWorkspaceHealth(w http.ResponseWriter, r *http.Request,
+	checker workspaceHealthChecker, tokens []string, namespace string, log logr.Logger,
+) {
+	reqID := gw.RequestID(w, r)
+	log = log.WithValues(gw.LogKeyRequestID, reqID)
+	if !bearerMatches(r, tokens) {
+		gw.WriteAPIError(w, http.StatusUnauthorized, gw.AuthErrorCodeUnauthorized)
+		return
+	}
+	user := r.PathValue("user")
+	health, err := checker.Check(r.Context(), namespace, user)
+	if err != nil {
+		log.Error(err, "Workspace health check failed", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventWorkspaceError, "user", user)
+		gw.WriteAPIError(w, http.StatusServiceUnavailable, gw.WorkspaceErrorCodeUnavailable)
+		return
+	}
+	status := http.StatusOK
+	if !health.Healthy {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(health)
+}
+
+// bearerMatches reports whether r carries "Authorization: Bearer <t>" for one
+// of tokens, compared in constant time.
+func bearerMatches(r *http.Request, tokens []string) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	got := []byte(strings.TrimPrefix(auth, "Bearer "))
+	matched := false
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare(got, []byte(t)) == 1 {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// nonEmpty returns the non-empty values among vals.
+func nonEmpty(vals ...string) []string {
+	var out []string
+	for _, v := range vals {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// handleKubeconfig serves GET /api/me/kubeconfig: a kubeconfig for the caller's
+// workspace ServiceAccount, scoped to the workspace namespace, with a
+// short-lived token. Users without a workspace are refused with 403.
+func handleKubeconfig(w http.ResponseWriter, r *http.Request,
+	validator tokenValidator, issuer kubeconfigIssuer,
+	namespace string, proxies gw.TrustedProxies, log logr.Logger, rl *gw.EndpointLimiter,
+) {
+	reqID := gw.RequestID(w, r)
+	log = log.WithValues(gw.LogKeyRequestID, reqID)
+	rawToken, err := extractToken(r)
+	if err != nil {
+		gw.LogAuthTokenRejected(log, reqID, proxies.ClientIP(r), "missing_token", http.StatusUnauthorized, gw.AuthErrorCodeUnauthorized)
+		gw.WriteAPIError(w, http.StatusUnauthorized, gw.AuthErrorCodeUnauthorized)
+		return
+	}
+	claims, err := validator.Validate(r.Context(), rawToken)
+	if err != nil {
+		st, code := gw.AuthErrorResponse(err)
+		gw.LogAuthTokenRejected(log, reqID, proxies.ClientIP(r), "invalid_token", st, code)
+		gw.WriteAPIError(w, st, code)
+		return
+	}
+	setAccessLogUser(r.Context(), claims.UserID)
+	if ok, scope := rl.Allow(claims.Sub); !ok {
+		gw.RecordRateLimitHit("lifecycle", scope)
+		gw.LogRateLimitAudit(log, reqID, "lifecycle", scope, claims.UserID)
+		gw.WriteAPIError(w, http.StatusTooManyRequests, gw.RateLimitErrorCode)
+		return
+	}
+	kubeconfig, expiry, err := issuer.Issue(r.Context(), namespace, claims.UserID)
+	if errors.Is(err, gw.ErrNoWorkspace) {
+		gw.LogAudit(log, "audit: kubeconfig download denied", reqID, gw.EventAuditKubeconfigIssued,
+			gw.LogKeyAuditOutcome, gw.OutcomeDenied,
+			gw.LogKeyActorSubject, claims.Sub,
+			gw.LogKeyUserID, claims.UserID,
+			"reason", "no_workspace",
+		)
+		gw.WriteAPIError(w, http.StatusForbidden, gw.AuthErrorCodeForbidden)
+		return
+	}
+	if err != nil {
+		log.Error(err, "Kubeconfig issue failed", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventWorkspaceError, "user", claims.UserID)
+		gw.WriteAPIError(w, http.StatusInternalServerError, gw.WorkspaceErrorCodeUnavailable)
+		return
+	}
+	gw.LogAudit(log, "audit: kubeconfig issued", reqID, gw.EventAuditKubeconfigIssued,
+		gw.LogKeyAuditOutcome, gw.OutcomeSuccess,
+		gw.LogKeyActorSubject, claims.Sub,
+		gw.LogKeyUserID, claims.UserID,
+		"namespace", namespace,
+		"expires", expiry.UTC().Format(time.RFC3339),
+	)
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="devplane-%s.kubeconfig"`, claims.UserID))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(kubeconfig)
+}
+
+// checkpointRequest is the JSON body of the checkpoint endpoints. Name is
+// optional when creating a checkpoint and required when restoring.
+type checkpointRequest struct {
+	Name string `json:"name"`
+}
+
+// maxCheckpointRequestBytes bounds the checkpoint request body.
+const maxCheckpointRequestBytes = 4 << 10
+
+// handleCheckpoint serves POST /api/workspaces/me/checkpoints (restore false),
+// which snapshots the caller's workspace PVC, and POST
+// /api/workspaces/me/restore (restore true), which recreates the PVC and pod
+// from a named checkpoint. Both respond with the checkpoint as JSON.
+func handleCheckpoint(w http.ResponseWriter, r *http.Request,
+	validator tokenValidator, checkpoints checkpointManager,
+	namespace string, restore bool, proxies gw.TrustedProxies, log logr.Logger, rl *gw.EndpointLimiter,
+) {
+	reqID := gw.RequestID(w, r)
+	log = log.WithValues(gw.LogKeyRequestID, reqID)
+	rawToken, err := extractToken(r)
+	if err != nil {
+		gw.LogAuthTokenRejected(log, reqID, proxies.ClientIP(r), "missing_token", http.StatusUnauthorized, gw.AuthErrorCodeUnauthorized)
+		gw.WriteAPIError(w, http.StatusUnauthorized, gw.AuthErrorCodeUnauthorized)
+		return
+	}
+	claims, err := validator.Validate(r.Context(), rawToken)
+	if err != nil {
+		st, code := gw.AuthErrorResponse(err)
+		gw.LogAuthTokenRejected(log, reqID, proxies.ClientIP(r), "invalid_token", st, code)
+		gw.WriteAPIError(w, st, code)
+		return
+	}
+	setAccessLogUser(r.Context(), claims.UserID)
+	if ok, scope := rl.Allow(claims.Sub); !ok {
+		gw.RecordRateLimitHit("lifecycle", scope)
+		gw.LogRateLimitAudit(log, reqID, "lifecycle", scope, claims.UserID)
+		gw.WriteAPIError(w, http.StatusTooManyRequests, gw.RateLimitErrorCode)
+		return
+	}
+	var req checkpointRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxCheckpointRequestBytes)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		gw.WriteAPIError(w, http.StatusBadRequest, gw.InvalidRequestErrorCode)
+		return
+	}
+
+	event, action, status := gw.EventAuditCheckpointCreate, "create", http.StatusCreated
+	var cp *gw.Checkpoint
+	if restore {
+		event, action, status = gw.EventAuditCheckpointRestore, "restore", http.StatusAccepted
+		cp, err = checkpoints.Restore(r.Context(), namespace, claims.UserID, req.Name)
+	} else {
+		cp, err = checkpoints.Create(r.Context(), namespace, claims.UserID, req.Name)
+	}
+	if err != nil {
+		var st int
+		var code string
+		switch {
+		case errors.Is(err, gw.ErrNoWorkspace):
+			st, code = http.StatusForbidden, gw.AuthErrorCodeForbidden
+		case errors.Is(err, gw.ErrCheckpointInvalidName):
+			st, code = http.StatusBadRequest, gw.InvalidRequestErrorCode
+		case errors.Is(err, gw.ErrCheckpointNotFound):
+			st, code = http.StatusNotFound, gw.CheckpointErrorCodeNotFound
+		case errors.Is(err, gw.ErrCheckpointExists):
+			st, code = http.StatusConflict, gw.CheckpointErrorCodeExists
+		case errors.Is(err, gw.ErrCheckpointNotReady):
+			st, code = http.StatusConflict, gw.CheckpointErrorCodeNotReady
+		case errors.Is(err, gw.ErrCheckpointUnsupported):
+			st, code = http.StatusConflict, gw.CheckpointErrorCodeUnsupported
+		default:
+			log.Error(err, "Checkpoint "+action+" failed", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventWorkspaceError, "user", claims.UserID)
+			gw.WriteAPIError(w, http.StatusInternalServerError, gw.WorkspaceErrorCodeUnavailable)
+			return
+		}
+		gw.LogAudit(log, "audit: checkpoint "+action+" denied", reqID, event,
+			gw.LogKeyAuditOutcome, gw.OutcomeDenied,
+			gw.LogKeyActorSubject, claims.Sub,
+			gw.LogKeyUserID, claims.UserID,
+			"checkpoint", req.Name,
+			"reason", code,
+		)
+		gw.WriteAPIError(w, st, code)
+		return
+	}
+	gw.LogAudit(log, "audit: checkpoint "+action, reqID, event,
+		gw.LogKeyAuditOutcome, gw.OutcomeSuccess,
+		gw.LogKeyActorSubject, claims.Sub,
+		gw.LogKeyUserID, claims.UserID,
+		"namespace", namespace,
+		"checkpoint", cp.Name,
+	)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(cp)
+}
+
 // handleHealth responds to liveness and readiness probes.
 func handleHealth(w http.ResponseWriter, _ *http.Request) {
 	w.WriteHeader(http.StatusOK)
@@ -383,11 +990,13 @@ func handleHealth(w http.ResponseWriter, _ *http.Request) {
 }
 
 // handleLogin initiates the OIDC authorization code flow by setting a CSRF
-// state cookie and redirecting the browser to the identity provider.
-func handleLogin(w http.ResponseWriter, r *http.Request, cfg oauthConfig, secure bool, log logr.Logger) {
+// state cookie and a PKCE code verifier cookie, then redirecting the browser
+// to the identity provider with the matching S256 code challenge.
+func handleLogin(w http.ResponseWriter, r *http.Request, cfg oauthConfig, secure bool, proxies gw.TrustedProxies, log logr.Logger) {
 	reqID := gw.RequestID(w, r)
 	log = log.WithValues(gw.LogKeyRequestID, reqID)
 	state := uuid.NewString()
+	verifier := oauth2.GenerateVerifier()
 	http.SetCookie(w, &http.Cookie{
 		Name:     "devplane_state",
 		Value:    state,
@@ -397,19 +1006,28 @@ func handleLogin(w http.ResponseWriter, r *http.Request, cfg oauthConfig, secure
 		Secure:   secure,
 		SameSite: http.SameSiteLaxMode,
 	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     "devplane_pkce",
+		Value:    verifier,
+		Path:     "/",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
 	gw.LogAudit(log, "audit: OIDC login redirect", reqID, gw.EventAuditOIDCLoginRedirect,
 		gw.LogKeyAuditOutcome, gw.OutcomeSuccess,
-		"remote", r.RemoteAddr,
+		"remote", proxies.ClientIP(r),
 	)
-	log.Info("Redirecting to IdP", "remote", r.RemoteAddr)
-	http.Redirect(w, r, cfg.AuthCodeURL(state), http.StatusFound)
+	log.Info("Redirecting to IdP", "remote", proxies.ClientIP(r))
+	http.Redirect(w, r, cfg.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)), http.StatusFound)
 }
 
 // handleCallback completes the OIDC authorization code flow: exchanges the
-// code for tokens, validates the ID token, sets a session cookie, and
-// redirects the browser to the root path.
+// code and PKCE verifier for tokens, validates the ID token, sets a session
+// cookie, and redirects the browser to the root path.
 func handleCallback(w http.ResponseWriter, r *http.Request,
-	cfg oauthConfig, validator tokenValidator, secure bool, log logr.Logger,
+	cfg oauthConfig, validator tokenValidator, secure bool, maxSessionAge time.Duration, log logr.Logger,
 ) {
 	reqID := gw.RequestID(w, r)
 	log = log.WithValues(gw.LogKeyRequestID, reqID)
@@ -424,18 +1042,26 @@ func handleCallback(w http.ResponseWriter, r *http.Request,
 		http.Error(w, "State mismatch", http.StatusBadRequest)
 		return
 	}
+	verifierCookie, err := r.Cookie("devplane_pkce")
+	if err != nil || verifierCookie.Value == "" {
+		gw.LogOIDCCallbackFailure(log, reqID, "missing_pkce_verifier")
+		http.Error(w, "Missing PKCE verifier cookie", http.StatusBadRequest)
+		return
+	}
 
-	// Clear the state cookie immediately after validation.
-	http.SetCookie(w, &http.Cookie{
-		Name:     "devplane_state",
-		Value:    "",
-		Path:     "/",
-		MaxAge:   -1,
-		HttpOnly: true,
-		Secure:   secure,
-	})
+	// Clear the state and verifier cookies immediately after validation.
+	for _, name := range []string{"devplane_state", "devplane_pkce"} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Value:    "",
+			Path:     "/",
+			MaxAge:   -1,
+			HttpOnly: true,
+			Secure:   secure,
+		})
+	}
 
-	token, err := cfg.Exchange(r.Context(), r.URL.Query().Get("code"))
+	token, err := cfg.Exchange(r.Context(), r.URL.Query().Get("code"), oauth2.VerifierOption(verifierCookie.Value))
 	if err != nil {
 		log.Error(err, "Token exchange failed", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventOIDCTokenExchange)
 		gw.LogOIDCCallbackFailure(log, reqID, "token_exchange_failed")
@@ -451,62 +1077,290 @@ func handleCallback(w http.ResponseWriter, r *http.Request,
 	}
 
 	claims, err := validator.Validate(r.Context(), rawIDToken)
+	if errors.Is(err, gw.ErrGroupNotAllowed) {
+		log.Info("User not in an allowed group", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventOIDCInvalidIDToken, "error", err.Error())
+		gw.LogOIDCCallbackFailure(log, reqID, "group_not_allowed")
+		http.Error(w, groupNotAllowedMessage, http.StatusForbidden)
+		return
+	}
 	if err != nil {
 		log.Info("Invalid ID token after OAuth exchange", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventOIDCInvalidIDToken, "error", err.Error())
 		gw.LogOIDCCallbackFailure(log, reqID, "invalid_id_token")
 		http.Error(w, "Invalid ID token", http.StatusUnauthorized)
 		return
 	}
+	setAccessLogUser(r.Context(), claims.UserID)
 
-	expiry := token.Expiry
-	if expiry.IsZero() {
-		expiry = time.Now().Add(time.Hour)
-	}
-	http.SetCookie(w, &http.Cookie{
-		Name:     "devplane_token",
-		Value:    rawIDToken,
-		Path:     "/",
-		Expires:  expiry,
-		HttpOnly: true,
-		Secure:   secure,
-		SameSite: http.SameSiteLaxMode,
-	})
+	setSessionCookies(w, token, rawIDToken, secure, maxSessionAge, time.Now())
 
 	gw.LogOIDCCallbackSuccess(log, reqID, claims)
 
 	http.Redirect(w, r, "/", http.StatusFound)
 }
 
-// handleProxy is the catch-all handler that proxies authenticated HTTP
-// requests (e.g. the ttyd web UI) to the user's workspace pod.
-// Unauthenticated requests are redirected to /login. While the workspace is
-// provisioning, a friendly loading page is served that auto-refreshes every 3 s.
-func handleProxy(w http.ResponseWriter, r *http.Request,
-	validator tokenValidator, lifecycle workspaceLifecycle,
-	namespace string, secure bool, log logr.Logger,
-) {
-	rawToken, err := extractToken(r)
-	if err != nil {
-		http.Redirect(w, r, "/login", http.StatusFound)
-		return
-	}
+// handleLogout clears the gateway session cookies and redirects the browser to
+// post_logout_redirect_uri when its host is in allowedHosts, or to /login
+// otherwise, so the parameter cannot be used as an open redirect. It does not
+// end the session at the identity provider.
+func handleLogout(w http.ResponseWriter, r *http.Request, allowedHosts []string, secure bool, proxies gw.TrustedProxies, log logr.Logger) {
+	reqID := gw.RequestID(w, r)
+	clearSessionCookies(w, secure)
+	target := "/login"
+	if raw := r.URL.Query().Get("post_logout_redirect_uri"); raw != "" {
+		if logoutRedirectAllowed(raw, allowedHosts) {
+			target = raw
+		} else {
+			log.Info("Ignoring post-logout redirect to a host not in GATEWAY_LOGOUT_REDIRECT_HOSTS",
+				gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyRequestID, reqID,
+				"remote", proxies.ClientIP(r), "target", raw)
+		}
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
 
-	claims, err := validator.Validate(r.Context(), rawToken)
-	if err != nil {
-		// Clear stale cookie then redirect to login.
+// clearSessionCookies expires devplane_token, devplane_refresh and
+// devplane_session_start.
+func clearSessionCookies(w http.ResponseWriter, secure bool) {
+	for _, name := range []string{"devplane_token", "devplane_refresh", sessionStartCookie} {
 		http.SetCookie(w, &http.Cookie{
-			Name:     "devplane_token",
+			Name:     name,
 			Value:    "",
 			Path:     "/",
 			MaxAge:   -1,
 			HttpOnly: true,
 			Secure:   secure,
 		})
-		http.Redirect(w, r, "/login", http.StatusFound)
+	}
+}
+
+// logoutRedirectAllowed reports whether raw is an absolute http(s) URL without
+// user info whose host is one of allowedHosts (case-insensitive, port ignored).
+func logoutRedirectAllowed(raw string, allowedHosts []string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.User != nil || u.Hostname() == "" {
+		return false
+	}
+	return slices.ContainsFunc(allowedHosts, func(h string) bool {
+		return strings.EqualFold(h, u.Hostname())
+	})
+}
+
+// groupNotAllowedMessage is the browser-facing body for ErrGroupNotAllowed.
+const groupNotAllowedMessage = "Your account is not a member of a group allowed to use this DevPlane installation."
+
+// sessionStartCookie holds the Unix time of the login that started the
+// session, so refreshed cookies keep the MAX_SESSION_AGE deadline of the
+// original login.
+const sessionStartCookie = "devplane_session_start"
+
+// setSessionCookies stores the ID token in devplane_token, expiring with the
+// OAuth token, and the refresh token (when the IdP returned one) in
+// devplane_refresh for sessionRefresher, next to the session's loginTime in
+// devplane_session_start. A positive maxAge caps all cookies at
+// loginTime+maxAge.
+func setSessionCookies(w http.ResponseWriter, token *oauth2.Token, rawIDToken string, secure bool, maxAge time.Duration, loginTime time.Time) {
+	expiry := token.Expiry
+	if expiry.IsZero() {
+		expiry = time.Now().Add(time.Hour)
+	}
+	var deadline time.Time // zero leaves devplane_refresh a browser-session cookie
+	if maxAge > 0 {
+		deadline = loginTime.Add(maxAge)
+		if deadline.Before(expiry) {
+			expiry = deadline
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     "devplane_token",
+		Value:    rawIDToken,
+		Path:     "/",
+		Expires:  expiry,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	if token.RefreshToken != "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:     "devplane_refresh",
+			Value:    token.RefreshToken,
+			Path:     "/",
+			Expires:  deadline,
+			HttpOnly: true,
+			Secure:   secure,
+			SameSite: http.SameSiteLaxMode,
+		})
+		http.SetCookie(w, &http.Cookie{
+			Name:     sessionStartCookie,
+			Value:    strconv.FormatInt(loginTime.Unix(), 10),
+			Path:     "/",
+			Expires:  deadline,
+			HttpOnly: true,
+			Secure:   secure,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+}
+
+// sessionStart returns the login time recorded in devplane_session_start. It
+// reports false when the cookie is missing, malformed or in the future.
+func sessionStart(r *http.Request) (time.Time, bool) {
+	c, err := r.Cookie(sessionStartCookie)
+	if err != nil {
+		return time.Time{}, false
+	}
+	sec, err := strconv.ParseInt(c.Value, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	start := time.Unix(sec, 0)
+	if start.After(time.Now()) {
+		return time.Time{}, false
+	}
+	return start, true
+}
+
+// sessionRefresher renews an expired devplane_token using the refresh token
+// in the devplane_refresh cookie. A nil *sessionRefresher never refreshes.
+type sessionRefresher struct {
+	cfg           oauthConfig
+	validator     tokenValidator
+	secure        bool
+	maxSessionAge time.Duration // caps the session at its login time plus this; 0 disables
+	proxies       gw.TrustedProxies
+	log           logr.Logger
+}
+
+// refresh obtains and validates a new ID token for the caller and re-sets the
+// session cookies on w. It reports false when the request carries no refresh
+// token, the session has outlived maxSessionAge, or the IdP rejects the
+// token; the refresh token is then cleared so the browser falls back to a
+// normal login. Refreshed cookies keep the original login's deadline.
+func (s *sessionRefresher) refresh(w http.ResponseWriter, r *http.Request) (*gw.Claims, bool) {
+	if s == nil {
+		return nil, false
+	}
+	c, err := r.Cookie("devplane_refresh")
+	if err != nil || c.Value == "" {
+		return nil, false
+	}
+	clearRefresh := func() {
+		for _, name := range []string{"devplane_refresh", sessionStartCookie} {
+			http.SetCookie(w, &http.Cookie{
+				Name:     name,
+				Value:    "",
+				Path:     "/",
+				MaxAge:   -1,
+				HttpOnly: true,
+				Secure:   s.secure,
+			})
+		}
+	}
+	loginTime, ok := sessionStart(r)
+	if s.maxSessionAge > 0 && (!ok || time.Since(loginTime) >= s.maxSessionAge) {
+		s.log.Info("Session reached MAX_SESSION_AGE; not refreshing", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventOIDCTokenExchange,
+			"remote", s.proxies.ClientIP(r))
+		clearRefresh()
+		return nil, false
+	}
+	if !ok {
+		loginTime = time.Now()
+	}
+	token, err := s.cfg.TokenSource(r.Context(), &oauth2.Token{RefreshToken: c.Value}).Token()
+	if err != nil {
+		s.log.Info("ID token refresh failed", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventOIDCTokenExchange,
+			"remote", s.proxies.ClientIP(r), "error", err.Error())
+		clearRefresh()
+		return nil, false
+	}
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		s.log.Info("Refresh response carried no id_token", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventOIDCTokenExchange,
+			"remote", s.proxies.ClientIP(r))
+		clearRefresh()
+		return nil, false
+	}
+	claims, err := s.validator.Validate(r.Context(), rawIDToken)
+	if err != nil {
+		s.log.Info("Invalid ID token after refresh", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventOIDCInvalidIDToken,
+			"remote", s.proxies.ClientIP(r), "error", err.Error())
+		clearRefresh()
+		return nil, false
+	}
+	setSessionCookies(w, token, rawIDToken, s.secure, s.maxSessionAge, loginTime)
+	return claims, true
+}
+
+// validateOrRefresh validates rawToken and, when it failed only because it
+// expired, retries with a refreshed ID token. An empty rawToken (the browser
+// dropped the expired devplane_token cookie) goes straight to the refresh.
+// Signature, audience and other failures are returned as-is.
+func validateOrRefresh(w http.ResponseWriter, r *http.Request, validator tokenValidator, refresher *sessionRefresher, rawToken string) (*gw.Claims, error) {
+	if rawToken == "" {
+		claims, ok := refresher.refresh(w, r)
+		if !ok {
+			return nil, fmt.Errorf("%w: no session token", gw.ErrUnauthorized)
+		}
+		setAccessLogUser(r.Context(), claims.UserID)
+		return claims, nil
+	}
+	claims, err := validator.Validate(r.Context(), rawToken)
+	if err != nil && errors.Is(err, gw.ErrTokenExpired) {
+		if refreshed, ok := refresher.refresh(w, r); ok {
+			claims, err = refreshed, nil
+		}
+	}
+	if err == nil {
+		setAccessLogUser(r.Context(), claims.UserID)
+	}
+	return claims, err
+}
+
+// handleProxy is the catch-all handler that proxies authenticated HTTP
+// requests (e.g. the ttyd web UI) to the user's workspace pod.
+// Unauthenticated requests are redirected to /login, or shown the static
+// landing page when landingPage is set. While the workspace is provisioning,
+// a friendly loading page is served that auto-refreshes every 3 s. Backend
+// requests go through transport; nil uses http.DefaultTransport.
+func handleProxy(w http.ResponseWriter, r *http.Request,
+	validator tokenValidator, refresher *sessionRefresher, lifecycle workspaceLifecycle,
+	backend httpBackend, transport http.RoundTripper, namespace string, secure, landingPage bool, log logr.Logger,
+) {
+	// devplane_token expires with the ID token, so a missing token may still
+	// be renewed from devplane_refresh.
+	rawToken, _ := extractToken(r)
+	claims, err := validateOrRefresh(w, r, validator, refresher, rawToken)
+	if errors.Is(err, gw.ErrGroupNotAllowed) {
+		// The session is valid; logging in again would not change the outcome.
+		http.Error(w, groupNotAllowedMessage, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		// Clear the stale session then redirect to login.
+		clearSessionCookies(w, secure)
+		sendToLogin(w, r, landingPage)
 		return
 	}
 
 	ws, _, err := lifecycle.EnsureExists(r.Context(), namespace, claims)
+	if errors.Is(err, gw.ErrQuotaExceeded) {
+		log.Info("Workspace quota exceeded", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventWorkspaceError, "user", claims.UserID)
+		http.Error(w, "You have reached the maximum number of workspaces.", http.StatusTooManyRequests)
+		return
+	}
+	var throttled *gw.CreateThrottledError
+	if errors.As(err, &throttled) {
+		log.Info("Workspace creation throttled", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventRateLimited, "user", claims.UserID)
+		gw.SetRetryAfter(w, throttled.RetryAfter)
+		http.Error(w, "Your workspace was created moments ago; retry shortly.", http.StatusTooManyRequests)
+		return
+	}
+	var elsewhere *gw.WorkspaceElsewhereError
+	if errors.As(err, &elsewhere) {
+		log.Info("Workspace exists in another namespace", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventWorkspaceError,
+			"user", claims.UserID, "workspaceNamespace", elsewhere.Namespace)
+		http.Error(w, fmt.Sprintf("Your workspace is served from namespace %q; use the DevPlane gateway for that namespace.", elsewhere.Namespace), http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to provision workspace", http.StatusInternalServerError)
 		log.Error(err, "EnsureExists failed", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventWorkspaceError, "user", claims.UserID)
@@ -523,8 +1377,9 @@ func handleProxy(w http.ResponseWriter, r *http.Request,
 		return
 	}
 
-	target, _ := url.Parse(gw.BackendHTTPURL(ws.Status.ServiceEndpoint))
+	target, _ := url.Parse(backend.BackendHTTPURL(ws.Status.ServiceEndpoint, gw.BackendPort(ws)))
 	rp := httputil.NewSingleHostReverseProxy(target)
+	rp.Transport = transport
 	rp.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
 		log.Info("Backend not reachable, serving loading page",
 			gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventHTTPBackendUnreachable,
@@ -535,14 +1390,29 @@ func handleProxy(w http.ResponseWriter, r *http.Request,
 	rp.ServeHTTP(w, r)
 }
 
+// sendToLogin directs an unauthenticated browser to sign in: either the static
+// landing page (GET/HEAD only, when enabled) or a redirect to /login.
+func sendToLogin(w http.ResponseWriter, r *http.Request, landingPage bool) {
+	if landingPage && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(landingPageHTML)
+		return
+	}
+	http.Redirect(w, r, "/login", http.StatusFound)
+}
+
 // handleWS is the main WebSocket endpoint. It validates the caller's OIDC token,
 // provisions or retrieves their Workspace CR, then proxies the connection to the
 // workspace pod's ttyd server.
 func handleWS(w http.ResponseWriter, r *http.Request,
 	validator tokenValidator,
+	refresher *sessionRefresher,
 	lifecycle workspaceLifecycle,
 	proxy wsProxy,
 	namespace string,
+	proxies gw.TrustedProxies,
 	log logr.Logger,
 	wsRL *gw.EndpointLimiter,
 ) {
@@ -550,18 +1420,18 @@ func handleWS(w http.ResponseWriter, r *http.Request,
 	log = log.WithValues(gw.LogKeyRequestID, reqID)
 	rawToken, err := extractToken(r)
 	if err != nil {
-		gw.LogAuthTokenRejected(log, reqID, r.RemoteAddr, "missing_token", http.StatusUnauthorized, gw.AuthErrorCodeUnauthorized)
+		gw.LogAuthTokenRejected(log, reqID, proxies.ClientIP(r), "missing_token", http.StatusUnauthorized, gw.AuthErrorCodeUnauthorized)
 		gw.WriteJSONAuthError(w, http.StatusUnauthorized, gw.AuthErrorCodeUnauthorized)
-		log.Info("Missing token", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventAuthFailure, "remote", r.RemoteAddr)
+		log.Info("Missing token", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventAuthFailure, "remote", proxies.ClientIP(r))
 		return
 	}
 
-	claims, err := validator.Validate(r.Context(), rawToken)
+	claims, err := validateOrRefresh(w, r, validator, refresher, rawToken)
 	if err != nil {
 		st, code := gw.AuthErrorResponse(err)
-		gw.LogAuthTokenRejected(log, reqID, r.RemoteAddr, "invalid_token", st, code)
+		gw.LogAuthTokenRejected(log, reqID, proxies.ClientIP(r), "invalid_token", st, code)
 		gw.WriteJSONAuthError(w, st, code)
-		log.Info("Token validation failed", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventAuthFailure, "remote", r.RemoteAddr, "status", st, "code", code)
+		log.Info("Token validation failed", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventAuthFailure, "remote", proxies.ClientIP(r), "status", st, "code", code)
 		return
 	}
 	if ok, scope := wsRL.Allow(claims.Sub); !ok {
@@ -574,6 +1444,45 @@ func handleWS(w http.ResponseWriter, r *http.Request,
 	}
 
 	ws, details, err := lifecycle.EnsureWorkspace(r.Context(), namespace, claims)
+	if errors.Is(err, gw.ErrProvisioningBusy) {
+		log.Info("Provisioning wait limit reached, returning 503",
+			gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventWorkspaceError, "user", claims.UserID)
+		gw.SetRetryAfter(w, provisioningBusyRetryAfter())
+		gw.WriteJSONError(w, http.StatusServiceUnavailable, gw.WorkspaceErrorCodeProvisioningBusy)
+		return
+	}
+	if errors.Is(err, gw.ErrWorkspaceNotReady) {
+		log.Info("Workspace not ready before timeout, returning 504",
+			gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventWorkspaceError, "user", claims.UserID)
+		gw.SetRetryAfter(w, retryAfterReadyTimeout)
+		gw.WriteJSONError(w, http.StatusGatewayTimeout, gw.WorkspaceErrorCodeReadyTimeout)
+		return
+	}
+	if errors.Is(err, gw.ErrWorkspaceSuspended) {
+		log.Info("Workspace is suspended, returning 409",
+			gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventWorkspaceError, "user", claims.UserID)
+		gw.WriteJSONError(w, http.StatusConflict, gw.WorkspaceErrorCodeSuspended)
+		return
+	}
+	if errors.Is(err, gw.ErrQuotaExceeded) {
+		log.Info("Workspace quota exceeded", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventWorkspaceError, "user", claims.UserID)
+		gw.WriteJSONError(w, http.StatusTooManyRequests, gw.WorkspaceErrorCodeQuotaExceeded)
+		return
+	}
+	var throttled *gw.CreateThrottledError
+	if errors.As(err, &throttled) {
+		log.Info("Workspace creation throttled", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventRateLimited, "user", claims.UserID)
+		gw.SetRetryAfter(w, throttled.RetryAfter)
+		gw.WriteJSONError(w, http.StatusTooManyRequests, gw.RateLimitErrorCode)
+		return
+	}
+	var elsewhere *gw.WorkspaceElsewhereError
+	if errors.As(err, &elsewhere) {
+		log.Info("Workspace exists in another namespace", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventWorkspaceError,
+			"user", claims.UserID, "workspaceNamespace", elsewhere.Namespace)
+		gw.WriteJSONWorkspaceElsewhere(w, elsewhere)
+		return
+	}
 	if err != nil {
 		log.Error(err, "EnsureWorkspace failed", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventWorkspaceError, "user", claims.UserID)
 		gw.WriteJSONError(w, http.StatusInternalServerError, gw.WorkspaceErrorCodeUnavailable)
@@ -585,15 +1494,16 @@ func handleWS(w http.ResponseWriter, r *http.Request,
 	// before proxying.  If the pod is Running but ttyd hasn't started yet,
 	// return 503 with a machine-readable code so clients can retry (same
 	// identity path as above — upgrade never happened).
-	if !gw.BackendReady(ws.Status.ServiceEndpoint) {
+	if !gw.BackendReady(ws.Status.ServiceEndpoint, gw.BackendPort(ws)) {
 		log.Info("Backend not ready yet, returning 503",
 			gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventWSProxyBackendNotReady,
 			"user", claims.UserID, "endpoint", ws.Status.ServiceEndpoint)
+		gw.SetRetryAfter(w, retryAfterBackendNotReady)
 		gw.WriteJSONError(w, http.StatusServiceUnavailable, gw.WorkspaceErrorCodeNotReady)
 		return
 	}
 
-	backendURL := gw.BackendURL(ws.Status.ServiceEndpoint)
+	backendURL := proxy.BackendURL(ws.Status.ServiceEndpoint, gw.BackendPort(ws))
 	recorder := gw.NewSessionRecorder(log, gw.SessionRecordingConfigFromEnv(), gw.SessionMeta{
 		RequestID: reqID,
 		Subject:   claims.Sub,
@@ -601,7 +1511,7 @@ func handleWS(w http.ResponseWriter, r *http.Request,
 		Namespace: namespace,
 		Workspace: ws.Name,
 		Backend:   ws.Status.ServiceEndpoint,
-		Remote:    r.RemoteAddr,
+		Remote:    proxies.ClientIP(r),
 	})
 	onFrame := gw.FrameObserver(func(direction string, msgType int, payload []byte) {
 		if recorder != nil {
@@ -629,10 +1539,21 @@ func handleWS(w http.ResponseWriter, r *http.Request,
 		lifecycle.TouchLastAccessed(r.Context(), ws)
 	}
 
-	if err := proxy.ServeWS(w, r, backendURL, onActivity, onFrame); err != nil {
+	if err := proxy.ServeWS(w, r, claims.UserID, backendURL, onActivity, onFrame); err != nil {
 		if recorder != nil {
 			recorder.Close(err)
 		}
+		if errors.Is(err, gw.ErrTooManyConnections) {
+			gw.LogAudit(log, "audit: WebSocket session denied", reqID, gw.EventAuditWSSessionEnd,
+				gw.LogKeyActorSubject, claims.Sub,
+				gw.LogKeyUserID, claims.UserID,
+				gw.LogKeyNamespace, namespace,
+				gw.LogKeyWorkspace, ws.Name,
+				gw.LogKeyAuditOutcome, gw.OutcomeDenied,
+				gw.LogKeyAuditReason, err.Error(),
+			)
+			return
+		}
 		gw.LogAudit(log, "audit: WebSocket session end", reqID, gw.EventAuditWSSessionEnd,
 			gw.LogKeyActorSubject, claims.Sub,
 			gw.LogKeyUserID, claims.UserID,
@@ -649,6 +1570,141 @@ func handleWS(w http.ResponseWriter, r *http.Request,
 	}
 }
 
+// handleViewWS proxies a read-only WebSocket to the terminal of the workspace
+// owned by the {user} path value, for callers allowed by its spec.sharing.
+// The viewer's keystrokes and resizes are dropped and the workspace is never
+// created or started on their behalf.
+func handleViewWS(w http.ResponseWriter, r *http.Request,
+	validator tokenValidator,
+	refresher *sessionRefresher,
+	sharing sharedWorkspaces,
+	proxy wsProxy,
+	namespace string,
+	proxies gw.TrustedProxies,
+	log logr.Logger,
+	wsRL *gw.EndpointLimiter,
+) {
+	reqID := gw.RequestID(w, r)
+	log = log.WithValues(gw.LogKeyRequestID, reqID)
+	owner := r.PathValue("user")
+	rawToken, err := extractToken(r)
+	if err != nil {
+		gw.LogAuthTokenRejected(log, reqID, proxies.ClientIP(r), "missing_token", http.StatusUnauthorized, gw.AuthErrorCodeUnauthorized)
+		gw.WriteJSONAuthError(w, http.StatusUnauthorized, gw.AuthErrorCodeUnauthorized)
+		return
+	}
+	claims, err := validateOrRefresh(w, r, validator, refresher, rawToken)
+	if err != nil {
+		st, code := gw.AuthErrorResponse(err)
+		gw.LogAuthTokenRejected(log, reqID, proxies.ClientIP(r), "invalid_token", st, code)
+		gw.WriteJSONAuthError(w, st, code)
+		return
+	}
+	if ok, scope := wsRL.Allow(claims.Sub); !ok {
+		gw.RecordRateLimitHit("websocket", scope)
+		gw.LogRateLimitAudit(log, reqID, "websocket", scope, claims.UserID)
+		gw.WriteJSONError(w, http.StatusTooManyRequests, gw.RateLimitErrorCode)
+		return
+	}
+
+	ws, err := sharing.Viewable(r.Context(), namespace, owner, claims)
+	if errors.Is(err, gw.ErrViewForbidden) {
+		gw.LogAudit(log, "audit: read-only view denied", reqID, gw.EventAuditWSViewEnd,
+			gw.LogKeyActorSubject, claims.Sub,
+			gw.LogKeyUserID, claims.UserID,
+			gw.LogKeyNamespace, namespace,
+			"owner", owner,
+			gw.LogKeyAuditOutcome, gw.OutcomeDenied,
+			gw.LogKeyAuditReason, err.Error(),
+		)
+		gw.WriteJSONError(w, http.StatusForbidden, gw.AuthErrorCodeForbidden)
+		return
+	}
+	if err != nil {
+		log.Error(err, "Shared workspace lookup failed", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventWorkspaceError,
+			"user", claims.UserID, "owner", owner)
+		gw.WriteJSONError(w, http.StatusInternalServerError, gw.WorkspaceErrorCodeUnavailable)
+		return
+	}
+	if ws.Status.Phase != workspacev1alpha1.WorkspacePhaseRunning || !gw.BackendReady(ws.Status.ServiceEndpoint, gw.BackendPort(ws)) {
+		gw.SetRetryAfter(w, retryAfterBackendNotReady)
+		gw.WriteJSONError(w, http.StatusServiceUnavailable, gw.WorkspaceErrorCodeNotReady)
+		return
+	}
+
+	backendURL := proxy.BackendURL(ws.Status.ServiceEndpoint, gw.BackendPort(ws))
+	gw.LogAudit(log, "audit: read-only view start", reqID, gw.EventAuditWSViewStart,
+		gw.LogKeyActorSubject, claims.Sub,
+		gw.LogKeyUserID, claims.UserID,
+		gw.LogKeyNamespace, namespace,
+		gw.LogKeyWorkspace, ws.Name,
+		"owner", owner,
+		gw.LogKeyAuditOutcome, gw.OutcomeSuccess,
+	)
+	err = proxy.ServeWSReadOnly(w, r, claims.UserID, backendURL, nil)
+	outcome := gw.OutcomeSuccess
+	if errors.Is(err, gw.ErrTooManyConnections) {
+		outcome = gw.OutcomeDenied
+	}
+	reason := ""
+	if err != nil {
+		reason = err.Error()
+	}
+	gw.LogAudit(log, "audit: read-only view end", reqID, gw.EventAuditWSViewEnd,
+		gw.LogKeyActorSubject, claims.Sub,
+		gw.LogKeyUserID, claims.UserID,
+		gw.LogKeyNamespace, namespace,
+		gw.LogKeyWorkspace, ws.Name,
+		"owner", owner,
+		gw.LogKeyAuditOutcome, outcome,
+		gw.LogKeyAuditReason, reason,
+	)
+}
+
+// handleViewPage serves the ttyd frontend of a shared workspace under
+// /view/{user}/, so the link can be handed to a collaborator. Its WebSocket
+// connects to handleViewWS.
+func handleViewPage(w http.ResponseWriter, r *http.Request,
+	validator tokenValidator, refresher *sessionRefresher, sharing sharedWorkspaces,
+	backend httpBackend, transport http.RoundTripper, namespace string, landingPage bool, log logr.Logger,
+) {
+	// httputil.ReverseProxy forwards upgrades, which would hand the viewer a
+	// writable ttyd socket that bypasses ServeWSReadOnly.
+	if isWebSocketUpgrade(r) {
+		http.Error(w, "Shared terminals are only streamed read-only via /view/{user}/ws.", http.StatusForbidden)
+		return
+	}
+	owner := r.PathValue("user")
+	rawToken, _ := extractToken(r) // empty tries devplane_refresh
+	claims, err := validateOrRefresh(w, r, validator, refresher, rawToken)
+	if err != nil {
+		sendToLogin(w, r, landingPage)
+		return
+	}
+	ws, err := sharing.Viewable(r.Context(), namespace, owner, claims)
+	if errors.Is(err, gw.ErrViewForbidden) {
+		http.Error(w, "This workspace is not shared with you.", http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		log.Error(err, "Shared workspace lookup failed", gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventWorkspaceError,
+			"user", claims.UserID, "owner", owner)
+		http.Error(w, "Failed to look up the shared workspace", http.StatusInternalServerError)
+		return
+	}
+	if ws.Status.Phase != workspacev1alpha1.WorkspacePhaseRunning || ws.Status.ServiceEndpoint == "" {
+		gw.SetRetryAfter(w, retryAfterBackendNotReady)
+		http.Error(w, "The shared workspace is not running.", http.StatusServiceUnavailable)
+		return
+	}
+
+	target, _ := url.Parse(backend.BackendHTTPURL(ws.Status.ServiceEndpoint, gw.BackendPort(ws)))
+	rp := httputil.NewSingleHostReverseProxy(target)
+	rp.Transport = transport
+	rp.ModifyResponse = injectFullWidthTerminalCSS
+	http.StripPrefix("/view/"+owner, rp).ServeHTTP(w, r)
+}
+
 // extractToken returns the bearer token from the Authorization header, the
 // devplane_token cookie, or the ?token query parameter (in that priority order).
 // The cookie is used by the browser login flow; the query parameter is needed
@@ -698,3 +1754,491 @@ func parseOIDCClockSkew() (time.Duration, error) {
 	}
 	return d, nil
 }
+
+// parseTokenCacheConfig reads TOKEN_CACHE_TTL (Go duration) and TOKEN_CACHE_MAX
+// (entry count). Unset values are left zero so the validator defaults apply.
+func parseTokenCacheConfig() (gw.ValidatorConfig, error) {
+	var cfg gw.ValidatorConfig
+	if s := strings.TrimSpace(os.Getenv("TOKEN_CACHE_TTL")); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return cfg, fmt.Errorf("TOKEN_CACHE_TTL: %w", err)
+		}
+		if d <= 0 {
+			return cfg, fmt.Errorf("TOKEN_CACHE_TTL must be > 0")
+		}
+		cfg.CacheTTL = d
+	}
+	if s := strings.TrimSpace(os.Getenv("TOKEN_CACHE_MAX")); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return cfg, fmt.Errorf("TOKEN_CACHE_MAX: %w", err)
+		}
+		if n < 1 {
+			return cfg, fmt.Errorf("TOKEN_CACHE_MAX must be >= 1")
+		}
+		cfg.CacheMax = n
+	}
+	return cfg, nil
+}
+
+// oidcIssuer is one entry of OIDC_ISSUERS_JSON. Audiences defaults to ClientID.
+type oidcIssuer struct {
+	Issuer    string   `json:"issuer"`
+	ClientID  string   `json:"clientID"`
+	Audiences []string `json:"audiences,omitempty"`
+	// UserIDPrefix qualifies this issuer's user IDs; see gw.OIDCConfig.
+	UserIDPrefix string `json:"userIDPrefix"`
+}
+
+// parseOIDCIssuers decodes OIDC_ISSUERS_JSON. Empty yields no extra issuers.
+func parseOIDCIssuers(raw string) ([]oidcIssuer, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var issuers []oidcIssuer
+	if err := json.Unmarshal([]byte(raw), &issuers); err != nil {
+		return nil, err
+	}
+	for i, iss := range issuers {
+		if iss.Issuer == "" || iss.ClientID == "" || iss.UserIDPrefix == "" {
+			return nil, fmt.Errorf("entry %d: issuer, clientID and userIDPrefix are required", i)
+		}
+	}
+	return issuers, nil
+}
+
+// parseCommaList splits a comma-separated env value (ALLOWED_GROUPS,
+// OIDC_AUDIENCE), dropping blank entries.
+func parseCommaList(raw string) []string {
+	var groups []string
+	for g := range strings.SplitSeq(raw, ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// parseCookieSecure returns whether gateway cookies carry the Secure
+// attribute. COOKIE_SECURE "true" or "false" forces it, for TLS-terminating
+// proxies whose internal redirect URL is http; unset or "auto" derives it from
+// whether redirectURL is https.
+func parseCookieSecure(redirectURL string) (bool, error) {
+	switch s := strings.ToLower(strings.TrimSpace(os.Getenv("COOKIE_SECURE"))); s {
+	case "", "auto":
+		return strings.HasPrefix(redirectURL, "https://"), nil
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	default:
+		return false, fmt.Errorf("%q must be true, false or auto", s)
+	}
+}
+
+// Retry-After hints for retryable 503 and 504 responses.
+const (
+	// retryAfterBackendNotReady is short: ttyd normally starts within seconds of the pod.
+	retryAfterBackendNotReady = 2 * time.Second
+	// retryAfterProvisioningBusy is the base delay when the provisioning wait
+	// limit is hit; provisioningBusyRetryAfter adds jitter on top.
+	retryAfterProvisioningBusy = 5 * time.Second
+	// retryAfterTimeout is sent when a handler exceeds GATEWAY_HANDLER_TIMEOUT.
+	retryAfterTimeout = 5 * time.Second
+	// retryAfterReadyTimeout is sent when a workspace misses
+	// WORKSPACE_READY_TIMEOUT; it is usually still scheduling.
+	retryAfterReadyTimeout = 10 * time.Second
+)
+
+// provisioningBusyRetryAfter spreads retries over [base, 2*base) so clients
+// turned away during a login storm do not all come back at once.
+func provisioningBusyRetryAfter() time.Duration {
+	return retryAfterProvisioningBusy + rand.N(retryAfterProvisioningBusy)
+}
+
+// handlerTimeoutBody is written with HTTP 503 when a non-WebSocket handler
+// exceeds GATEWAY_HANDLER_TIMEOUT.
+const handlerTimeoutBody = `{"error":"` + gw.TimeoutErrorCode + `"}`
+
+// withTimeout bounds h with http.TimeoutHandler so a hung IdP or API server
+// cannot pile up goroutines. WebSocket upgrade requests bypass the timeout
+// because TimeoutHandler's ResponseWriter cannot be hijacked. d <= 0 disables.
+func withTimeout(h http.Handler, d time.Duration) http.Handler {
+	if d <= 0 {
+		return h
+	}
+	bounded := http.TimeoutHandler(h, d, handlerTimeoutBody)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isWebSocketUpgrade(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		bounded.ServeHTTP(&retryAfterWriter{ResponseWriter: w, retryAfter: retryAfterTimeout}, r)
+	})
+}
+
+// accessLogEntryKey is the context key for the request's *accessLogEntry.
+type accessLogEntryKey struct{}
+
+// accessLogEntry collects request details that are only known inside the
+// handlers. It is guarded by a mutex because http.TimeoutHandler runs the
+// handler on its own goroutine.
+type accessLogEntry struct {
+	mu     sync.Mutex
+	userID string
+}
+
+// setAccessLogUser records the authenticated user for the access log line of
+// the request carrying ctx. It is a no-op outside withAccessLog.
+func setAccessLogUser(ctx context.Context, userID string) {
+	if e, ok := ctx.Value(accessLogEntryKey{}).(*accessLogEntry); ok {
+		e.mu.Lock()
+		e.userID = userID
+		e.mu.Unlock()
+	}
+}
+
+// withAccessLog logs one line per request with method, path, status,
+// duration, client address and, once authenticated, the user ID. The line is
+// written when h returns, so WebSocket sessions are logged with status 101
+// and their full duration after the tunnel closes. Health and metrics
+// scrapes are logged at V(1) to keep probes out of the default output.
+func withAccessLog(h http.Handler, proxies gw.TrustedProxies, log logr.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		entry := &accessLogEntry{}
+		sw := &statusWriter{ResponseWriter: w}
+		h.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), accessLogEntryKey{}, entry)))
+
+		status := sw.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		kv := []any{gw.LogKeyComponent, gw.ComponentGateway, gw.LogKeyEvent, gw.EventHTTPAccess,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", status,
+			"durationMs", time.Since(start).Milliseconds(),
+			"remote", proxies.ClientIP(r),
+		}
+		entry.mu.Lock()
+		if entry.userID != "" {
+			kv = append(kv, gw.LogKeyUserID, entry.userID)
+		}
+		entry.mu.Unlock()
+		l := log
+		if r.URL.Path == "/health" || r.URL.Path == "/metrics" {
+			l = log.V(1)
+		}
+		l.Info("HTTP request", kv...)
+	})
+}
+
+// statusWriter records the status code written through it. A hijacked
+// connection (WebSocket upgrade) is recorded as 101 Switching Protocols.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// retryAfterWriter adds a Retry-After header to any 503 written without one,
+// covering the timeout response that http.TimeoutHandler writes itself.
+type retryAfterWriter struct {
+	http.ResponseWriter
+	retryAfter time.Duration
+}
+
+func (w *retryAfterWriter) WriteHeader(status int) {
+	if status == http.StatusServiceUnavailable && w.Header().Get("Retry-After") == "" {
+		gw.SetRetryAfter(w.ResponseWriter, w.retryAfter)
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// isWebSocketUpgrade reports whether r asks to upgrade to the WebSocket
+// protocol: a Connection header listing the "upgrade" token and an Upgrade
+// header listing "websocket", each matched per comma-separated token.
+func isWebSocketUpgrade(r *http.Request) bool {
+	return headerHasToken(r.Header, "Connection", "upgrade") &&
+		headerHasToken(r.Header, "Upgrade", "websocket")
+}
+
+// headerHasToken reports whether any value of header key holds token in its
+// comma-separated list, compared case-insensitively.
+func headerHasToken(h http.Header, key, token string) bool {
+	for _, v := range h.Values(key) {
+		for tok := range strings.SplitSeq(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(tok), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseMaxSessionAge returns the MAX_SESSION_AGE cap on session cookie
+// lifetime. Unset or "0" leaves cookies expiring with the ID token.
+func parseMaxSessionAge() (time.Duration, error) {
+	s := strings.TrimSpace(os.Getenv("MAX_SESSION_AGE"))
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("duration must be >= 0")
+	}
+	return d, nil
+}
+
+// parseWorkspaceReadyTimeout returns how long WebSocket connects wait for the
+// workspace to reach Running, from WORKSPACE_READY_TIMEOUT. Unset leaves the
+// lifecycle manager's 60s default.
+func parseWorkspaceReadyTimeout() (time.Duration, error) {
+	s := strings.TrimSpace(os.Getenv("WORKSPACE_READY_TIMEOUT"))
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("duration must be > 0")
+	}
+	return d, nil
+}
+
+// parseMinCreateInterval returns the minimum time between Workspace creations
+// for one user from GATEWAY_MIN_CREATE_INTERVAL. Unset or "0" disables it.
+func parseMinCreateInterval() (time.Duration, error) {
+	s := strings.TrimSpace(os.Getenv("GATEWAY_MIN_CREATE_INTERVAL"))
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("duration must be >= 0")
+	}
+	return d, nil
+}
+
+// parseBackendTLS returns the TLS configuration for wss:// workspace backends
+// when GATEWAY_BACKEND_TLS=true, trusting GATEWAY_BACKEND_CA_FILE if set.
+// It returns nil (plain ws://) otherwise.
+func parseBackendTLS() (*tls.Config, error) {
+	if !strings.EqualFold(strings.TrimSpace(os.Getenv("GATEWAY_BACKEND_TLS")), "true") {
+		return nil, nil
+	}
+	return gw.BackendTLSConfig(strings.TrimSpace(os.Getenv("GATEWAY_BACKEND_CA_FILE")))
+}
+
+// parseWSKeepaliveInterval returns the WebSocket ping interval from
+// GATEWAY_WS_KEEPALIVE_INTERVAL for gw.ProxyConfig. Unset keeps the 30s
+// default (0); "0" disables keepalive (returned as -1).
+func parseWSKeepaliveInterval() (time.Duration, error) {
+	s := strings.TrimSpace(os.Getenv("GATEWAY_WS_KEEPALIVE_INTERVAL"))
+	if s == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("duration must be >= 0")
+	}
+	if d == 0 {
+		return -1, nil
+	}
+	return d, nil
+}
+
+// parseHandlerTimeout returns the per-request timeout for non-WebSocket
+// handlers. Default 30s when GATEWAY_HANDLER_TIMEOUT is unset; "0" disables.
+func parseHandlerTimeout() (time.Duration, error) {
+	s := strings.TrimSpace(os.Getenv("GATEWAY_HANDLER_TIMEOUT"))
+	if s == "" {
+		return 30 * time.Second, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("duration must be >= 0")
+	}
+	return d, nil
+}
+
+// parseBackendTransportConfig reads the reverse proxy transport settings for
+// workspace backends: GATEWAY_BACKEND_IDLE_CONN_TIMEOUT,
+// GATEWAY_BACKEND_MAX_IDLE_CONNS_PER_HOST and GATEWAY_BACKEND_DIAL_TIMEOUT.
+// Unset values are left zero so the gw.NewBackendTransport defaults apply.
+func parseBackendTransportConfig() (gw.BackendTransportConfig, error) {
+	var cfg gw.BackendTransportConfig
+	for _, d := range []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"GATEWAY_BACKEND_IDLE_CONN_TIMEOUT", &cfg.IdleConnTimeout},
+		{"GATEWAY_BACKEND_DIAL_TIMEOUT", &cfg.DialTimeout},
+	} {
+		s := strings.TrimSpace(os.Getenv(d.name))
+		if s == "" {
+			continue
+		}
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return cfg, fmt.Errorf("%s: %w", d.name, err)
+		}
+		if v <= 0 {
+			return cfg, fmt.Errorf("%s must be > 0", d.name)
+		}
+		*d.dst = v
+	}
+	n, err := parseNonNegativeInt("GATEWAY_BACKEND_MAX_IDLE_CONNS_PER_HOST")
+	if err != nil {
+		return cfg, fmt.Errorf("GATEWAY_BACKEND_MAX_IDLE_CONNS_PER_HOST: %w", err)
+	}
+	cfg.MaxIdleConnsPerHost = n
+	return cfg, nil
+}
+
+// inClusterCAFile is the CA bundle mounted into every pod for the in-cluster
+// API server address.
+const inClusterCAFile = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+
+// parseKubeconfigConfig builds the kubeconfig download settings for server.
+// GATEWAY_KUBECONFIG_CA_FILE defaults to the in-cluster CA, which also covers
+// external endpoints served with the cluster's own certificate; "none" leaves
+// verification to the client's system roots (e.g. a publicly trusted load
+// balancer). GATEWAY_KUBECONFIG_TOKEN_TTL defaults to gw.DefaultKubeconfigTokenTTL.
+func parseKubeconfigConfig(server string) (gw.KubeconfigConfig, error) {
+	cfg := gw.KubeconfigConfig{Server: server, TokenTTL: gw.DefaultKubeconfigTokenTTL}
+	if u, err := url.Parse(server); err != nil || u.Scheme != "https" || u.Host == "" {
+		return cfg, fmt.Errorf("GATEWAY_KUBECONFIG_SERVER %q must be an https URL", server)
+	}
+	if s := strings.TrimSpace(os.Getenv("GATEWAY_KUBECONFIG_TOKEN_TTL")); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return cfg, fmt.Errorf("GATEWAY_KUBECONFIG_TOKEN_TTL: %w", err)
+		}
+		// TokenRequest rejects lifetimes under ten minutes.
+		if d < 10*time.Minute {
+			return cfg, fmt.Errorf("GATEWAY_KUBECONFIG_TOKEN_TTL must be at least 10m")
+		}
+		cfg.TokenTTL = d
+	}
+	caFile := envOr("GATEWAY_KUBECONFIG_CA_FILE", inClusterCAFile)
+	if caFile != "none" {
+		ca, err := os.ReadFile(caFile)
+		if err != nil {
+			return cfg, fmt.Errorf("GATEWAY_KUBECONFIG_CA_FILE: %w", err)
+		}
+		cfg.CAData = ca
+	}
+	return cfg, nil
+}
+
+// parseMaxProvisioningWaits returns the cap on concurrent WebSocket provisioning
+// waits from GATEWAY_MAX_PROVISIONING_WAITS. Unset or "0" means unlimited.
+func parseMaxProvisioningWaits() (int, error) {
+	return parseNonNegativeInt("GATEWAY_MAX_PROVISIONING_WAITS")
+}
+
+// parseMaxWorkspacesPerUser returns the per-user workspace cap from
+// GATEWAY_MAX_WORKSPACES_PER_USER. Unset or "0" means unlimited.
+func parseMaxWorkspacesPerUser() (int, error) {
+	return parseNonNegativeInt("GATEWAY_MAX_WORKSPACES_PER_USER")
+}
+
+// parseMaxWSConnectionsPerUser returns the per-user cap on concurrent /ws
+// tunnels from GATEWAY_MAX_WS_CONNECTIONS_PER_USER. Unset or "0" means unlimited.
+func parseMaxWSConnectionsPerUser() (int, error) {
+	return parseNonNegativeInt("GATEWAY_MAX_WS_CONNECTIONS_PER_USER")
+}
+
+// parseNonNegativeInt reads an integer >= 0 from the named environment
+// variable, returning 0 when it is unset.
+func parseNonNegativeInt(name string) (int, error) {
+	s := strings.TrimSpace(os.Getenv(name))
+	if s == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("must be >= 0")
+	}
+	return n, nil
+}
+
+// parseOIDCDiscoveryRetry returns the startup retry policy for OIDC provider
+// discovery. OIDC_DISCOVERY_ATTEMPTS defaults to 5 (1 disables retries);
+// OIDC_DISCOVERY_BACKOFF is the initial wait between attempts (default 2s,
+// doubling up to 30s).
+func parseOIDCDiscoveryRetry() (gw.DiscoveryRetry, error) {
+	retry := gw.DiscoveryRetry{Attempts: 5, Backoff: 2 * time.Second}
+	if s := strings.TrimSpace(os.Getenv("OIDC_DISCOVERY_ATTEMPTS")); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return retry, fmt.Errorf("OIDC_DISCOVERY_ATTEMPTS: %w", err)
+		}
+		if n < 1 {
+			return retry, fmt.Errorf("OIDC_DISCOVERY_ATTEMPTS must be >= 1")
+		}
+		retry.Attempts = n
+	}
+	if s := strings.TrimSpace(os.Getenv("OIDC_DISCOVERY_BACKOFF")); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return retry, fmt.Errorf("OIDC_DISCOVERY_BACKOFF: %w", err)
+		}
+		if d < 0 {
+			return retry, fmt.Errorf("OIDC_DISCOVERY_BACKOFF must be >= 0")
+		}
+		retry.Backoff = d
+	}
+	return retry, nil
+}
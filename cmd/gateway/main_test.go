@@ -1,18 +1,26 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
 	"golang.org/x/oauth2"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	workspacev1alpha1 "workspace-operator/api/v1alpha1"
@@ -47,33 +55,153 @@ func (l *stubLifecycle) EnsureWorkspace(_ context.Context, _ string, _ *gw.Claim
 
 func (l *stubLifecycle) TouchLastAccessed(_ context.Context, _ *workspacev1alpha1.Workspace) {}
 
+type stubKubeconfigIssuer struct {
+	kubeconfig []byte
+	err        error
+	gotUser    string
+}
+
+func (k *stubKubeconfigIssuer) Issue(_ context.Context, _ string, userID string) ([]byte, time.Time, error) {
+	k.gotUser = userID
+	return k.kubeconfig, time.Now().Add(time.Hour), k.err
+}
+
+type stubCheckpoints struct {
+	err      error
+	gotUser  string
+	gotName  string
+	restored bool
+}
+
+func (c *stubCheckpoints) Create(_ context.Context, _ string, userID, name string) (*gw.Checkpoint, error) {
+	c.gotUser, c.gotName = userID, name
+	if c.err != nil {
+		return nil, c.err
+	}
+	if name == "" {
+		name = userID + "-generated"
+	}
+	return &gw.Checkpoint{Name: name, CreatedAt: time.Now()}, nil
+}
+
+func (c *stubCheckpoints) Restore(_ context.Context, _ string, userID, name string) (*gw.Checkpoint, error) {
+	c.gotUser, c.gotName, c.restored = userID, name, true
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &gw.Checkpoint{Name: name, ReadyToUse: true}, nil
+}
+
 type stubProxy struct {
 	err error
+	// readOnlyUser records the viewer passed to ServeWSReadOnly.
+	readOnlyUser string
 }
 
-func (p *stubProxy) ServeWS(w http.ResponseWriter, _ *http.Request, _ string, _ func(), _ gw.FrameObserver) error {
+func (p *stubProxy) ServeWS(w http.ResponseWriter, _ *http.Request, _, _ string, _ func(), _ gw.FrameObserver) error {
 	// Simulate a successful upgrade by writing 101; real upgrades are tested in proxy_test.go.
 	w.WriteHeader(http.StatusSwitchingProtocols)
 	return p.err
 }
 
-type stubOAuthConfig struct {
-	authURL     string
-	token       *oauth2.Token
-	exchangeErr error
+func (p *stubProxy) ServeWSReadOnly(w http.ResponseWriter, _ *http.Request, userID, _ string, _ gw.FrameObserver) error {
+	p.readOnlyUser = userID
+	w.WriteHeader(http.StatusSwitchingProtocols)
+	return p.err
 }
 
-func (s *stubOAuthConfig) AuthCodeURL(state string, _ ...oauth2.AuthCodeOption) string {
+type stubSharing struct {
+	ws  *workspacev1alpha1.Workspace
+	err error
+	// gotOwner records the owner path value passed to Viewable.
+	gotOwner string
+}
+
+func (s *stubSharing) Viewable(_ context.Context, _, ownerID string, _ *gw.Claims) (*workspacev1alpha1.Workspace, error) {
+	s.gotOwner = ownerID
+	return s.ws, s.err
+}
+
+type stubHealth struct {
+	health gw.WorkspaceHealth
+	err    error
+	// gotUser records the user path value passed to Check.
+	gotUser string
+}
+
+func (s *stubHealth) Check(_ context.Context, _, userID string) (gw.WorkspaceHealth, error) {
+	s.gotUser = userID
+	return s.health, s.err
+}
+
+func (p *stubProxy) BackendURL(serviceEndpoint string, port int32) string {
+	return gw.BackendURL(serviceEndpoint, port)
+}
+
+func (p *stubProxy) BackendHTTPURL(serviceEndpoint string, port int32) string {
+	return gw.BackendHTTPURL(serviceEndpoint, port)
+}
+
+type stubOAuthConfig struct {
+	authURL      string
+	token        *oauth2.Token
+	exchangeErr  error
+	authOpts     []oauth2.AuthCodeOption
+	exchangeOpts []oauth2.AuthCodeOption
+	// refreshed and refreshErr are returned for refresh-token grants;
+	// refreshedWith records the refresh tokens presented.
+	refreshed     *oauth2.Token
+	refreshErr    error
+	refreshedWith []string
+}
+
+func (s *stubOAuthConfig) AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string {
+	s.authOpts = opts
 	if s.authURL != "" {
 		return s.authURL + "?state=" + state
 	}
 	return "https://idp.example.com/auth?state=" + state
 }
 
-func (s *stubOAuthConfig) Exchange(_ context.Context, _ string, _ ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+func (s *stubOAuthConfig) Exchange(_ context.Context, _ string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	s.exchangeOpts = opts
 	return s.token, s.exchangeErr
 }
 
+// authCodeParams renders opts as the query parameters they add to a request.
+func authCodeParams(opts []oauth2.AuthCodeOption) url.Values {
+	cfg := &oauth2.Config{Endpoint: oauth2.Endpoint{AuthURL: "https://idp.example.com/auth"}}
+	u, _ := url.Parse(cfg.AuthCodeURL("", opts...))
+	return u.Query()
+}
+
+func (s *stubOAuthConfig) TokenSource(_ context.Context, t *oauth2.Token) oauth2.TokenSource {
+	s.refreshedWith = append(s.refreshedWith, t.RefreshToken)
+	return stubTokenSource{token: s.refreshed, err: s.refreshErr}
+}
+
+type stubTokenSource struct {
+	token *oauth2.Token
+	err   error
+}
+
+func (s stubTokenSource) Token() (*oauth2.Token, error) { return s.token, s.err }
+
+type stubInvalidator struct {
+	users  []string
+	hashes []string
+}
+
+func (s *stubInvalidator) EvictUser(user string) int {
+	s.users = append(s.users, user)
+	return 2
+}
+
+func (s *stubInvalidator) EvictTokenHash(tokenHash string) int {
+	s.hashes = append(s.hashes, tokenHash)
+	return 1
+}
+
 // discardLog returns a no-op logger suitable for tests.
 func discardLog() logr.Logger { return logr.Discard() }
 
@@ -106,6 +234,30 @@ func TestEnvOr_Missing(t *testing.T) {
 	}
 }
 
+func TestParseOIDCDiscoveryRetry_Default(t *testing.T) {
+	t.Setenv("OIDC_DISCOVERY_ATTEMPTS", "")
+	t.Setenv("OIDC_DISCOVERY_BACKOFF", "")
+	r, err := parseOIDCDiscoveryRetry()
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if r.Attempts != 5 || r.Backoff != 2*time.Second {
+		t.Fatalf("default = %+v, want 5 attempts / 2s", r)
+	}
+}
+
+func TestParseOIDCDiscoveryRetry_Invalid(t *testing.T) {
+	t.Setenv("OIDC_DISCOVERY_ATTEMPTS", "0")
+	if _, err := parseOIDCDiscoveryRetry(); err == nil {
+		t.Error("expected error for OIDC_DISCOVERY_ATTEMPTS=0")
+	}
+	t.Setenv("OIDC_DISCOVERY_ATTEMPTS", "3")
+	t.Setenv("OIDC_DISCOVERY_BACKOFF", "soon")
+	if _, err := parseOIDCDiscoveryRetry(); err == nil {
+		t.Error("expected error for invalid OIDC_DISCOVERY_BACKOFF")
+	}
+}
+
 func TestParseOIDCClockSkew_Default(t *testing.T) {
 	t.Setenv("OIDC_CLOCK_SKEW", "")
 	d, err := parseOIDCClockSkew()
@@ -213,7 +365,7 @@ func TestHandleLogin_SetsCookieAndRedirects(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/login", nil)
 
-	handleLogin(w, r, cfg, false, discardLog())
+	handleLogin(w, r, cfg, false, nil, discardLog())
 
 	resp := w.Result()
 	if resp.StatusCode != http.StatusFound {
@@ -251,12 +403,30 @@ func TestHandleLogin_SetsCookieAndRedirects(t *testing.T) {
 	}
 }
 
+func TestHandleLogin_SendsPKCEChallenge(t *testing.T) {
+	cfg := &stubOAuthConfig{}
+	w := httptest.NewRecorder()
+	handleLogin(w, httptest.NewRequest(http.MethodGet, "/login", nil), cfg, false, nil, discardLog())
+
+	verifier := responseCookie(w.Result(), "devplane_pkce")
+	if verifier == nil || verifier.Value == "" || !verifier.HttpOnly || verifier.MaxAge != 600 {
+		t.Fatalf("devplane_pkce cookie = %+v, want an HttpOnly verifier living 600s", verifier)
+	}
+	params := authCodeParams(cfg.authOpts)
+	if got := params.Get("code_challenge_method"); got != "S256" {
+		t.Errorf("code_challenge_method = %q, want S256", got)
+	}
+	if got, want := params.Get("code_challenge"), oauth2.S256ChallengeFromVerifier(verifier.Value); got != want {
+		t.Errorf("code_challenge = %q, want %q derived from the cookie verifier", got, want)
+	}
+}
+
 func TestHandleLogin_SecureCookie(t *testing.T) {
 	cfg := &stubOAuthConfig{}
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/login", nil)
 
-	handleLogin(w, r, cfg, true, discardLog())
+	handleLogin(w, r, cfg, true, nil, discardLog())
 
 	resp := w.Result()
 	for _, c := range resp.Cookies() {
@@ -266,6 +436,45 @@ func TestHandleLogin_SecureCookie(t *testing.T) {
 	}
 }
 
+func TestParseCookieSecure(t *testing.T) {
+	tests := []struct {
+		env, redirectURL string
+		want             bool
+	}{
+		{"", "https://devplane.example.com/callback", true},
+		{"", "http://localhost:8080/callback", false},
+		{"auto", "http://devplane.internal/callback", false},
+		{"true", "http://devplane.internal/callback", true},
+		{"TRUE", "http://devplane.internal/callback", true},
+		{"false", "https://devplane.example.com/callback", false},
+	}
+	for _, tt := range tests {
+		t.Setenv("COOKIE_SECURE", tt.env)
+		secure, err := parseCookieSecure(tt.redirectURL)
+		if err != nil {
+			t.Fatalf("COOKIE_SECURE=%q: unexpected err: %v", tt.env, err)
+		}
+		if secure != tt.want {
+			t.Errorf("COOKIE_SECURE=%q redirect %q: secure = %v, want %v", tt.env, tt.redirectURL, secure, tt.want)
+		}
+
+		w := httptest.NewRecorder()
+		handleLogin(w, httptest.NewRequest(http.MethodGet, "/login", nil), &stubOAuthConfig{}, secure, nil, discardLog())
+		for _, c := range w.Result().Cookies() {
+			if c.Name == "devplane_state" && c.Secure != tt.want {
+				t.Errorf("COOKIE_SECURE=%q redirect %q: state cookie Secure = %v, want %v", tt.env, tt.redirectURL, c.Secure, tt.want)
+			}
+		}
+	}
+}
+
+func TestParseCookieSecure_Invalid(t *testing.T) {
+	t.Setenv("COOKIE_SECURE", "yes")
+	if _, err := parseCookieSecure("https://devplane.example.com/callback"); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
 // --- handleCallback tests ---
 
 func TestHandleCallback_MissingStateCookie(t *testing.T) {
@@ -273,7 +482,7 @@ func TestHandleCallback_MissingStateCookie(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/callback?state=abc&code=xyz", nil)
 
-	handleCallback(w, r, cfg, &stubValidator{}, false, discardLog())
+	handleCallback(w, r, cfg, &stubValidator{}, false, 0, discardLog())
 
 	if w.Code != http.StatusBadRequest {
 		t.Errorf("status = %d, want 400", w.Code)
@@ -286,7 +495,7 @@ func TestHandleCallback_StateMismatch(t *testing.T) {
 	r := httptest.NewRequest(http.MethodGet, "/callback?state=wrong&code=xyz", nil)
 	r.AddCookie(&http.Cookie{Name: "devplane_state", Value: "correct"})
 
-	handleCallback(w, r, cfg, &stubValidator{}, false, discardLog())
+	handleCallback(w, r, cfg, &stubValidator{}, false, 0, discardLog())
 
 	if w.Code != http.StatusBadRequest {
 		t.Errorf("status = %d, want 400", w.Code)
@@ -298,8 +507,9 @@ func TestHandleCallback_ExchangeError(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/callback?state=mystate&code=xyz", nil)
 	r.AddCookie(&http.Cookie{Name: "devplane_state", Value: "mystate"})
+	r.AddCookie(&http.Cookie{Name: "devplane_pkce", Value: "verifier"})
 
-	handleCallback(w, r, cfg, &stubValidator{}, false, discardLog())
+	handleCallback(w, r, cfg, &stubValidator{}, false, 0, discardLog())
 
 	if w.Code != http.StatusBadGateway {
 		t.Errorf("status = %d, want 502", w.Code)
@@ -312,8 +522,9 @@ func TestHandleCallback_MissingIDToken(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/callback?state=mystate&code=xyz", nil)
 	r.AddCookie(&http.Cookie{Name: "devplane_state", Value: "mystate"})
+	r.AddCookie(&http.Cookie{Name: "devplane_pkce", Value: "verifier"})
 
-	handleCallback(w, r, cfg, &stubValidator{}, false, discardLog())
+	handleCallback(w, r, cfg, &stubValidator{}, false, 0, discardLog())
 
 	if w.Code != http.StatusBadGateway {
 		t.Errorf("status = %d, want 502", w.Code)
@@ -327,14 +538,49 @@ func TestHandleCallback_InvalidToken(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/callback?state=mystate&code=xyz", nil)
 	r.AddCookie(&http.Cookie{Name: "devplane_state", Value: "mystate"})
+	r.AddCookie(&http.Cookie{Name: "devplane_pkce", Value: "verifier"})
 
-	handleCallback(w, r, cfg, v, false, discardLog())
+	handleCallback(w, r, cfg, v, false, 0, discardLog())
 
 	if w.Code != http.StatusUnauthorized {
 		t.Errorf("status = %d, want 401", w.Code)
 	}
 }
 
+func TestHandleCallback_SendsPKCEVerifier(t *testing.T) {
+	tok := (&oauth2.Token{}).WithExtra(map[string]interface{}{"id_token": "validtoken"})
+	cfg := &stubOAuthConfig{token: tok}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/callback?state=mystate&code=xyz", nil)
+	r.AddCookie(&http.Cookie{Name: "devplane_state", Value: "mystate"})
+	r.AddCookie(&http.Cookie{Name: "devplane_pkce", Value: "the-verifier"})
+
+	handleCallback(w, r, cfg, &stubValidator{claims: validClaims()}, false, 0, discardLog())
+
+	if got := authCodeParams(cfg.exchangeOpts).Get("code_verifier"); got != "the-verifier" {
+		t.Errorf("code_verifier on exchange = %q, want the-verifier", got)
+	}
+	if c := responseCookie(w.Result(), "devplane_pkce"); c == nil || c.MaxAge != -1 {
+		t.Errorf("devplane_pkce cookie = %+v, want cleared after use", c)
+	}
+}
+
+func TestHandleCallback_MissingPKCEVerifier(t *testing.T) {
+	cfg := &stubOAuthConfig{token: (&oauth2.Token{}).WithExtra(map[string]interface{}{"id_token": "validtoken"})}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/callback?state=mystate&code=xyz", nil)
+	r.AddCookie(&http.Cookie{Name: "devplane_state", Value: "mystate"})
+
+	handleCallback(w, r, cfg, &stubValidator{claims: validClaims()}, false, 0, discardLog())
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", w.Code)
+	}
+	if cfg.exchangeOpts != nil {
+		t.Error("code must not be exchanged without a PKCE verifier")
+	}
+}
+
 func TestHandleCallback_HappyPath(t *testing.T) {
 	tok := (&oauth2.Token{}).WithExtra(map[string]interface{}{"id_token": "validtoken"})
 	cfg := &stubOAuthConfig{token: tok}
@@ -342,8 +588,9 @@ func TestHandleCallback_HappyPath(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/callback?state=mystate&code=xyz", nil)
 	r.AddCookie(&http.Cookie{Name: "devplane_state", Value: "mystate"})
+	r.AddCookie(&http.Cookie{Name: "devplane_pkce", Value: "verifier"})
 
-	handleCallback(w, r, cfg, v, false, discardLog())
+	handleCallback(w, r, cfg, v, false, 0, discardLog())
 
 	resp := w.Result()
 	if resp.StatusCode != http.StatusFound {
@@ -371,12 +618,90 @@ func TestHandleCallback_HappyPath(t *testing.T) {
 	}
 }
 
+func TestHandleCallback_MaxSessionAgeClampsCookieExpiry(t *testing.T) {
+	tok := (&oauth2.Token{Expiry: time.Now().Add(8 * time.Hour), RefreshToken: "refresh"}).
+		WithExtra(map[string]interface{}{"id_token": "validtoken"})
+	cfg := &stubOAuthConfig{token: tok}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/callback?state=mystate&code=xyz", nil)
+	r.AddCookie(&http.Cookie{Name: "devplane_state", Value: "mystate"})
+	r.AddCookie(&http.Cookie{Name: "devplane_pkce", Value: "verifier"})
+
+	before := time.Now()
+	handleCallback(w, r, cfg, &stubValidator{claims: validClaims()}, false, time.Hour, discardLog())
+
+	resp := w.Result()
+	for _, name := range []string{"devplane_token", "devplane_refresh"} {
+		c := responseCookie(resp, name)
+		if c == nil {
+			t.Fatalf("%s cookie not set", name)
+		}
+		// Cookie Expires has second precision.
+		if c.Expires.Before(before.Add(time.Hour).Add(-time.Second)) || c.Expires.After(time.Now().Add(time.Hour)) {
+			t.Errorf("%s Expires = %v, want about now+1h (token lives 8h)", name, c.Expires)
+		}
+	}
+	c := responseCookie(resp, sessionStartCookie)
+	if c == nil {
+		t.Fatalf("%s cookie not set", sessionStartCookie)
+	}
+	r.AddCookie(c)
+	if start, ok := sessionStart(r); !ok || start.Before(before.Truncate(time.Second)) {
+		t.Errorf("%s = %v (ok %v), want the login time %v", sessionStartCookie, start, ok, before)
+	}
+}
+
 // --- handleWS tests ---
 
 func wsRequest(token string) *http.Request {
 	return httptest.NewRequest(http.MethodGet, "/ws?token="+token, nil)
 }
 
+func TestHandleLogout(t *testing.T) {
+	allowed := []string{"docs.example.com", "Portal.Example.com"}
+	tests := []struct {
+		name     string
+		redirect string
+		want     string
+	}{
+		{name: "no redirect", want: "/login"},
+		{name: "allowed host", redirect: "https://docs.example.com/signed-out", want: "https://docs.example.com/signed-out"},
+		{name: "allowed host with port and other case", redirect: "https://portal.example.com:8443/bye", want: "https://portal.example.com:8443/bye"},
+		{name: "disallowed host", redirect: "https://evil.example.net/phish", want: "/login"},
+		{name: "allowed host as subdomain suffix", redirect: "https://docs.example.com.evil.net/", want: "/login"},
+		{name: "protocol-relative", redirect: "//docs.example.com/", want: "/login"},
+		{name: "javascript scheme", redirect: "javascript:alert(1)", want: "/login"},
+		{name: "user info", redirect: "https://docs.example.com@evil.example.net/", want: "/login"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/logout"
+			if tt.redirect != "" {
+				target += "?post_logout_redirect_uri=" + url.QueryEscape(tt.redirect)
+			}
+			w := httptest.NewRecorder()
+			handleLogout(w, httptest.NewRequest(http.MethodGet, target, nil), allowed, true, nil, discardLog())
+
+			resp := w.Result()
+			if resp.StatusCode != http.StatusFound {
+				t.Fatalf("status = %d, want 302", resp.StatusCode)
+			}
+			if loc := resp.Header.Get("Location"); loc != tt.want {
+				t.Errorf("Location = %q, want %q", loc, tt.want)
+			}
+			cleared := map[string]bool{}
+			for _, c := range resp.Cookies() {
+				if c.MaxAge < 0 && c.Secure {
+					cleared[c.Name] = true
+				}
+			}
+			if !cleared["devplane_token"] || !cleared["devplane_refresh"] || !cleared[sessionStartCookie] {
+				t.Errorf("cleared cookies = %v, want devplane_token, devplane_refresh and %s", cleared, sessionStartCookie)
+			}
+		})
+	}
+}
+
 func TestMustEnv_Present(t *testing.T) {
 	t.Setenv("TEST_MUSTENV_GATEWAY_KEY", "myvalue")
 	got := mustEnv("TEST_MUSTENV_GATEWAY_KEY")
@@ -389,7 +714,7 @@ func TestHandleWS_MissingToken(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/ws", nil) // no token
 
-	handleWS(w, r, &stubValidator{}, &stubLifecycle{}, &stubProxy{}, "default", discardLog(), nil)
+	handleWS(w, r, &stubValidator{}, nil, &stubLifecycle{}, &stubProxy{}, "default", nil, discardLog(), nil)
 
 	if w.Code != http.StatusUnauthorized {
 		t.Errorf("status = %d, want 401", w.Code)
@@ -407,7 +732,7 @@ func TestHandleWS_InvalidToken(t *testing.T) {
 	w := httptest.NewRecorder()
 
 	v := &stubValidator{err: fmt.Errorf("%w: invalid", gw.ErrUnauthorized)}
-	handleWS(w, wsRequest("badtoken"), v, &stubLifecycle{}, &stubProxy{}, "default", discardLog(), nil)
+	handleWS(w, wsRequest("badtoken"), v, nil, &stubLifecycle{}, &stubProxy{}, "default", nil, discardLog(), nil)
 
 	if w.Code != http.StatusUnauthorized {
 		t.Errorf("status = %d, want 401", w.Code)
@@ -424,7 +749,7 @@ func TestHandleWS_InvalidToken(t *testing.T) {
 func TestHandleWS_ForbiddenAudience(t *testing.T) {
 	w := httptest.NewRecorder()
 	v := &stubValidator{err: fmt.Errorf("%w: aud", gw.ErrForbidden)}
-	handleWS(w, wsRequest("tok"), v, &stubLifecycle{}, &stubProxy{}, "default", discardLog(), nil)
+	handleWS(w, wsRequest("tok"), v, nil, &stubLifecycle{}, &stubProxy{}, "default", nil, discardLog(), nil)
 	if w.Code != http.StatusForbidden {
 		t.Errorf("status = %d, want 403", w.Code)
 	}
@@ -440,7 +765,7 @@ func TestHandleWS_ForbiddenAudience(t *testing.T) {
 func TestHandleWS_TokenExpired(t *testing.T) {
 	w := httptest.NewRecorder()
 	v := &stubValidator{err: fmt.Errorf("%w: expired", gw.ErrTokenExpired)}
-	handleWS(w, wsRequest("tok"), v, &stubLifecycle{}, &stubProxy{}, "default", discardLog(), nil)
+	handleWS(w, wsRequest("tok"), v, nil, &stubLifecycle{}, &stubProxy{}, "default", nil, discardLog(), nil)
 	if w.Code != http.StatusUnauthorized {
 		t.Errorf("status = %d, want 401", w.Code)
 	}
@@ -458,7 +783,7 @@ func TestHandleWS_WorkspaceProvisionFails(t *testing.T) {
 
 	v := &stubValidator{claims: &gw.Claims{Sub: "u1", Email: "u1@test.com", UserID: "u1"}}
 	lc := &stubLifecycle{err: errors.New("workspace failed")}
-	handleWS(w, wsRequest("validtoken"), v, lc, &stubProxy{}, "default", discardLog(), nil)
+	handleWS(w, wsRequest("validtoken"), v, nil, lc, &stubProxy{}, "default", nil, discardLog(), nil)
 
 	if w.Code != http.StatusInternalServerError {
 		t.Errorf("status = %d, want 500", w.Code)
@@ -472,6 +797,46 @@ func TestHandleWS_WorkspaceProvisionFails(t *testing.T) {
 	}
 }
 
+func TestHandleWS_ProvisioningBusy_Returns503(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	v := &stubValidator{claims: &gw.Claims{Sub: "u1", Email: "u1@test.com", UserID: "u1"}}
+	lc := &stubLifecycle{err: gw.ErrProvisioningBusy}
+	handleWS(w, wsRequest("validtoken"), v, nil, lc, &stubProxy{}, "default", nil, discardLog(), nil)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if body["error"] != gw.WorkspaceErrorCodeProvisioningBusy {
+		t.Errorf("error = %q, want %q", body["error"], gw.WorkspaceErrorCodeProvisioningBusy)
+	}
+	assertRetryAfter(t, w, 5, 10)
+}
+
+func TestHandleWS_ReadyTimeout_Returns504(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	v := &stubValidator{claims: &gw.Claims{Sub: "u1", Email: "u1@test.com", UserID: "u1"}}
+	lc := &stubLifecycle{err: fmt.Errorf("%w: %q did not reach Running within 1m0s", gw.ErrWorkspaceNotReady, "u1")}
+	handleWS(w, wsRequest("validtoken"), v, nil, lc, &stubProxy{}, "default", nil, discardLog(), nil)
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want 504", w.Code)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if body["error"] != gw.WorkspaceErrorCodeReadyTimeout {
+		t.Errorf("error = %q, want %q", body["error"], gw.WorkspaceErrorCodeReadyTimeout)
+	}
+	assertRetryAfter(t, w, 10, 10)
+}
+
 // TestHandleWS_StoppedWorkspaceRecovery verifies that when EnsureWorkspace
 // succeeds (stopped workspace was cleared and re-provisioned by the lifecycle
 // manager), the gateway proceeds to proxy rather than returning 500.
@@ -499,7 +864,7 @@ func TestHandleWS_StoppedWorkspaceRecovery(t *testing.T) {
 	ws.Status.ServiceEndpoint = "127.0.0.1"
 	// EnsureWorkspace succeeds (lifecycle manager internally restarted the stopped workspace).
 	lc := &stubLifecycle{ws: ws}
-	handleWS(w, wsRequest("validtoken"), v, lc, &stubProxy{}, "default", discardLog(), nil)
+	handleWS(w, wsRequest("validtoken"), v, nil, lc, &stubProxy{}, "default", nil, discardLog(), nil)
 
 	// Expect the proxy to have been called (stub writes 101).
 	if w.Code == http.StatusInternalServerError {
@@ -530,7 +895,7 @@ func TestHandleWS_HappyPath(t *testing.T) {
 	ws.Status.Phase = workspacev1alpha1.WorkspacePhaseRunning
 	ws.Status.ServiceEndpoint = "127.0.0.1"
 	lc := &stubLifecycle{ws: ws}
-	handleWS(w, wsRequest("validtoken"), v, lc, &stubProxy{}, "default", discardLog(), nil)
+	handleWS(w, wsRequest("validtoken"), v, nil, lc, &stubProxy{}, "default", nil, discardLog(), nil)
 
 	// stubProxy writes 101; no 4xx or 5xx from handleWS itself.
 	if w.Code >= 400 {
@@ -547,7 +912,7 @@ func TestHandleWS_BackendNotReady_Returns503(t *testing.T) {
 	// 127.0.0.1:7681 not listening → BackendReady returns false immediately.
 	ws.Status.ServiceEndpoint = "127.0.0.1"
 	lc := &stubLifecycle{ws: ws}
-	handleWS(w, wsRequest("validtoken"), v, lc, &stubProxy{}, "default", discardLog(), nil)
+	handleWS(w, wsRequest("validtoken"), v, nil, lc, &stubProxy{}, "default", nil, discardLog(), nil)
 
 	if w.Code != http.StatusServiceUnavailable {
 		t.Errorf("status = %d, want 503", w.Code)
@@ -559,6 +924,120 @@ func TestHandleWS_BackendNotReady_Returns503(t *testing.T) {
 	if body["error"] != gw.WorkspaceErrorCodeNotReady {
 		t.Errorf("error = %q, want %q", body["error"], gw.WorkspaceErrorCodeNotReady)
 	}
+	assertRetryAfter(t, w, 1, 5)
+}
+
+func viewRequest(path, owner string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, path+"?token=validtoken", nil)
+	r.SetPathValue("user", owner)
+	return r
+}
+
+func TestHandleViewWS_NotShared_Returns403(t *testing.T) {
+	w := httptest.NewRecorder()
+	v := &stubValidator{claims: &gw.Claims{Sub: "mallory", UserID: "mallory"}}
+	sh := &stubSharing{err: gw.ErrViewForbidden}
+	p := &stubProxy{}
+	handleViewWS(w, viewRequest("/view/alice/ws", "alice"), v, nil, sh, p, "default", nil, discardLog(), nil)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", w.Code)
+	}
+	if sh.gotOwner != "alice" {
+		t.Errorf("owner = %q, want alice from the path", sh.gotOwner)
+	}
+	if p.readOnlyUser != "" {
+		t.Error("proxy must not be called for a viewer without access")
+	}
+}
+
+func TestHandleViewWS_NotRunning_Returns503(t *testing.T) {
+	w := httptest.NewRecorder()
+	v := &stubValidator{claims: &gw.Claims{Sub: "bob", UserID: "bob"}}
+	ws := &workspacev1alpha1.Workspace{}
+	ws.Status.Phase = workspacev1alpha1.WorkspacePhaseStopped
+	handleViewWS(w, viewRequest("/view/alice/ws", "alice"), v, nil, &stubSharing{ws: ws}, &stubProxy{}, "default", nil, discardLog(), nil)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", w.Code)
+	}
+}
+
+func TestHandleViewWS_ProxiesReadOnly(t *testing.T) {
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", 7681))
+	if err != nil {
+		t.Skipf("cannot bind to port 7681 (likely in use): %v", err)
+	}
+	defer func() { _ = ln.Close() }()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = c.Close()
+		}
+	}()
+
+	w := httptest.NewRecorder()
+	v := &stubValidator{claims: &gw.Claims{Sub: "bob", UserID: "bob"}}
+	ws := &workspacev1alpha1.Workspace{}
+	ws.Name = "alice"
+	ws.Status.Phase = workspacev1alpha1.WorkspacePhaseRunning
+	ws.Status.ServiceEndpoint = "127.0.0.1"
+	p := &stubProxy{}
+	handleViewWS(w, viewRequest("/view/alice/ws", "alice"), v, nil, &stubSharing{ws: ws}, p, "default", nil, discardLog(), nil)
+
+	if w.Code >= 400 {
+		t.Errorf("status = %d, expected successful proxy", w.Code)
+	}
+	if p.readOnlyUser != "bob" {
+		t.Errorf("read-only proxy user = %q, want the viewer bob", p.readOnlyUser)
+	}
+}
+
+func TestHandleViewPage_NotShared_Returns403(t *testing.T) {
+	w := httptest.NewRecorder()
+	v := &stubValidator{claims: &gw.Claims{Sub: "mallory", UserID: "mallory"}}
+	handleViewPage(w, viewRequest("/view/alice/", "alice"), v, nil, &stubSharing{err: gw.ErrViewForbidden}, &stubProxy{}, nil, "default", false, discardLog())
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", w.Code)
+	}
+}
+
+func TestHandleViewPage_RefusesUpgrade(t *testing.T) {
+	for _, path := range []string{"/view/alice/ws", "/view/alice/terminal/ws"} {
+		w := httptest.NewRecorder()
+		r := viewRequest(path, "alice")
+		r.Header.Set("Connection", "keep-alive, Upgrade")
+		r.Header.Set("Upgrade", "websocket")
+		sh := &stubSharing{ws: &workspacev1alpha1.Workspace{Status: workspacev1alpha1.WorkspaceStatus{
+			Phase: workspacev1alpha1.WorkspacePhaseRunning, ServiceEndpoint: "alice-workspace-svc",
+		}}}
+		v := &stubValidator{claims: &gw.Claims{Sub: "bob", UserID: "bob"}}
+		handleViewPage(w, r, v, nil, sh, &stubProxy{}, nil, "default", false, discardLog())
+
+		if w.Code != http.StatusForbidden {
+			t.Errorf("%s: status = %d, want 403 so the page proxy never tunnels a writable socket", path, w.Code)
+		}
+		if sh.gotOwner != "" {
+			t.Errorf("%s: sharing consulted for an upgrade on the page route", path)
+		}
+	}
+}
+
+// assertRetryAfter fails unless w carries a Retry-After of lo..hi seconds.
+func assertRetryAfter(t *testing.T, w *httptest.ResponseRecorder, lo, hi int) {
+	t.Helper()
+	raw := w.Header().Get("Retry-After")
+	secs, err := strconv.Atoi(raw)
+	if err != nil {
+		t.Fatalf("Retry-After = %q, want an integer number of seconds", raw)
+	}
+	if secs < lo || secs > hi {
+		t.Errorf("Retry-After = %d, want %d..%d", secs, lo, hi)
+	}
 }
 
 // --- handleProxy tests ---
@@ -567,7 +1046,7 @@ func TestHandleProxy_NoToken_RedirectsToLogin(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/", nil)
 
-	handleProxy(w, r, &stubValidator{}, &stubLifecycle{}, "default", false, discardLog())
+	handleProxy(w, r, &stubValidator{}, nil, &stubLifecycle{}, &stubProxy{}, nil, "default", false, false, discardLog())
 
 	resp := w.Result()
 	if resp.StatusCode != http.StatusFound {
@@ -578,13 +1057,45 @@ func TestHandleProxy_NoToken_RedirectsToLogin(t *testing.T) {
 	}
 }
 
+func TestHandleProxy_NoToken_LandingPageEnabled(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	handleProxy(w, r, &stubValidator{}, nil, &stubLifecycle{}, &stubProxy{}, nil, "default", false, true, discardLog())
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if loc := resp.Header.Get("Location"); loc != "" {
+		t.Errorf("unexpected redirect to %q", loc)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Content-Type = %q, want text/html", ct)
+	}
+	if body := w.Body.String(); !strings.Contains(body, `href="/login"`) {
+		t.Errorf("landing page missing sign-in link to /login: %s", body)
+	}
+}
+
+func TestHandleProxy_NoToken_LandingPagePostStillRedirects(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+
+	handleProxy(w, r, &stubValidator{}, nil, &stubLifecycle{}, &stubProxy{}, nil, "default", false, true, discardLog())
+
+	if w.Code != http.StatusFound {
+		t.Errorf("status = %d, want 302", w.Code)
+	}
+}
+
 func TestHandleProxy_InvalidToken_RedirectsToLogin(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/", nil)
 	r.AddCookie(&http.Cookie{Name: "devplane_token", Value: "staletoken"})
 
 	v := &stubValidator{err: errors.New("expired")}
-	handleProxy(w, r, v, &stubLifecycle{}, "default", false, discardLog())
+	handleProxy(w, r, v, nil, &stubLifecycle{}, &stubProxy{}, nil, "default", false, false, discardLog())
 
 	resp := w.Result()
 	if resp.StatusCode != http.StatusFound {
@@ -602,38 +1113,299 @@ func TestHandleProxy_InvalidToken_RedirectsToLogin(t *testing.T) {
 	}
 }
 
-func proxyRequest(token string) *http.Request {
-	r := httptest.NewRequest(http.MethodGet, "/", nil)
-	r.AddCookie(&http.Cookie{Name: "devplane_token", Value: token})
-	return r
+// validatorFunc adapts a function to tokenValidator for tests that need
+// per-token results.
+type validatorFunc func(rawToken string) (*gw.Claims, error)
+
+func (f validatorFunc) Validate(_ context.Context, rawToken string) (*gw.Claims, error) {
+	return f(rawToken)
 }
 
-func validClaims() *gw.Claims {
-	return &gw.Claims{Sub: "alice", Email: "alice@example.com", UserID: "alice"}
+// expiringValidator accepts only "fresh" and reports "stale" as expired.
+var expiringValidator = validatorFunc(func(rawToken string) (*gw.Claims, error) {
+	switch rawToken {
+	case "fresh":
+		return validClaims(), nil
+	case "stale":
+		return nil, fmt.Errorf("%w: token is expired", gw.ErrTokenExpired)
+	}
+	return nil, errors.New("oidc: id token signed by unknown key")
+})
+
+func responseCookie(resp *http.Response, name string) *http.Cookie {
+	for _, c := range resp.Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
 }
 
-func TestHandleProxy_EnsureExistsFails(t *testing.T) {
+func TestHandleCallback_StoresRefreshToken(t *testing.T) {
+	tok := (&oauth2.Token{RefreshToken: "rt"}).WithExtra(map[string]interface{}{"id_token": "validtoken"})
+	cfg := &stubOAuthConfig{token: tok}
 	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/callback?state=mystate&code=xyz", nil)
+	r.AddCookie(&http.Cookie{Name: "devplane_state", Value: "mystate"})
+	r.AddCookie(&http.Cookie{Name: "devplane_pkce", Value: "verifier"})
 
-	v := &stubValidator{claims: validClaims()}
-	lc := &stubLifecycle{existsErr: errors.New("k8s unavailable")}
-	handleProxy(w, proxyRequest("tok"), v, lc, "default", false, discardLog())
+	handleCallback(w, r, cfg, &stubValidator{claims: validClaims()}, true, 0, discardLog())
 
-	if w.Code != http.StatusInternalServerError {
-		t.Errorf("status = %d, want 500", w.Code)
+	c := responseCookie(w.Result(), "devplane_refresh")
+	if c == nil || c.Value != "rt" {
+		t.Fatalf("devplane_refresh cookie = %+v, want value rt", c)
+	}
+	if !c.HttpOnly || !c.Secure {
+		t.Errorf("devplane_refresh cookie HttpOnly=%v Secure=%v, want both true", c.HttpOnly, c.Secure)
 	}
 }
 
-func TestHandleProxy_PendingPhase_ServesLoadingPage(t *testing.T) {
+func TestHandleProxy_ExpiredToken_Refreshes(t *testing.T) {
+	expiry := time.Now().Add(15 * time.Minute).Truncate(time.Second)
+	cfg := &stubOAuthConfig{
+		refreshed: (&oauth2.Token{RefreshToken: "rt2", Expiry: expiry}).WithExtra(map[string]interface{}{"id_token": "fresh"}),
+	}
+	refresher := &sessionRefresher{cfg: cfg, validator: expiringValidator, log: discardLog()}
+	lc := &stubLifecycle{existsWs: &workspacev1alpha1.Workspace{
+		Status: workspacev1alpha1.WorkspaceStatus{Phase: workspacev1alpha1.WorkspacePhaseCreating},
+	}}
+	r := proxyRequest("stale")
+	r.AddCookie(&http.Cookie{Name: "devplane_refresh", Value: "rt1"})
 	w := httptest.NewRecorder()
 
-	v := &stubValidator{claims: validClaims()}
-	ws := &workspacev1alpha1.Workspace{}
-	ws.Status.Phase = workspacev1alpha1.WorkspacePhasePending
-	lc := &stubLifecycle{existsWs: ws}
-	handleProxy(w, proxyRequest("tok"), v, lc, "default", false, discardLog())
+	handleProxy(w, r, expiringValidator, refresher, lc, &stubProxy{}, nil, "default", false, false, discardLog())
 
-	if w.Code != http.StatusOK {
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (loading page) after refresh", resp.StatusCode)
+	}
+	if len(cfg.refreshedWith) != 1 || cfg.refreshedWith[0] != "rt1" {
+		t.Errorf("refreshed with %v, want [rt1]", cfg.refreshedWith)
+	}
+	if c := responseCookie(resp, "devplane_token"); c == nil || c.Value != "fresh" || !c.Expires.Equal(expiry) {
+		t.Errorf("devplane_token cookie = %+v, want fresh expiring %v", c, expiry)
+	}
+	if c := responseCookie(resp, "devplane_refresh"); c == nil || c.Value != "rt2" {
+		t.Errorf("devplane_refresh cookie = %+v, want rotated rt2", c)
+	}
+}
+
+func TestHandleProxy_OnlyRefreshCookie_Refreshes(t *testing.T) {
+	cfg := &stubOAuthConfig{
+		refreshed: (&oauth2.Token{RefreshToken: "rt2", Expiry: time.Now().Add(15 * time.Minute)}).WithExtra(map[string]interface{}{"id_token": "fresh"}),
+	}
+	refresher := &sessionRefresher{cfg: cfg, validator: expiringValidator, log: discardLog()}
+	lc := &stubLifecycle{existsWs: &workspacev1alpha1.Workspace{
+		Status: workspacev1alpha1.WorkspaceStatus{Phase: workspacev1alpha1.WorkspacePhaseCreating},
+	}}
+	// The browser dropped devplane_token when the ID token expired.
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "devplane_refresh", Value: "rt1"})
+	w := httptest.NewRecorder()
+
+	handleProxy(w, r, expiringValidator, refresher, lc, &stubProxy{}, nil, "default", false, false, discardLog())
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (loading page) after refresh", resp.StatusCode)
+	}
+	if len(cfg.refreshedWith) != 1 || cfg.refreshedWith[0] != "rt1" {
+		t.Errorf("refreshed with %v, want [rt1]", cfg.refreshedWith)
+	}
+	if c := responseCookie(resp, "devplane_token"); c == nil || c.Value != "fresh" {
+		t.Errorf("devplane_token cookie = %+v, want fresh", c)
+	}
+}
+
+func TestSessionRefresher_MaxSessionAgeFromLogin(t *testing.T) {
+	start := time.Now().Add(-30 * time.Minute).Truncate(time.Second)
+	tests := []struct {
+		name        string
+		start       string // devplane_session_start; "" omits the cookie
+		wantRefresh bool
+	}{
+		{name: "within cap", start: strconv.FormatInt(start.Unix(), 10), wantRefresh: true},
+		{name: "past cap", start: strconv.FormatInt(time.Now().Add(-2*time.Hour).Unix(), 10)},
+		{name: "missing start", start: ""},
+		{name: "malformed start", start: "soon"},
+		{name: "start in the future", start: strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &stubOAuthConfig{
+				refreshed: (&oauth2.Token{RefreshToken: "rt2", Expiry: time.Now().Add(8 * time.Hour)}).WithExtra(map[string]interface{}{"id_token": "fresh"}),
+			}
+			s := &sessionRefresher{cfg: cfg, validator: expiringValidator, maxSessionAge: time.Hour, log: discardLog()}
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.AddCookie(&http.Cookie{Name: "devplane_refresh", Value: "rt1"})
+			if tt.start != "" {
+				r.AddCookie(&http.Cookie{Name: sessionStartCookie, Value: tt.start})
+			}
+			w := httptest.NewRecorder()
+
+			_, ok := s.refresh(w, r)
+
+			resp := w.Result()
+			if !tt.wantRefresh {
+				if ok || len(cfg.refreshedWith) != 0 {
+					t.Fatalf("refresh = %v with %v, want no refresh past MAX_SESSION_AGE", ok, cfg.refreshedWith)
+				}
+				if c := responseCookie(resp, "devplane_refresh"); c == nil || c.MaxAge != -1 {
+					t.Errorf("devplane_refresh cookie = %+v, want cleared", c)
+				}
+				return
+			}
+			if !ok {
+				t.Fatal("refresh failed within MAX_SESSION_AGE")
+			}
+			// Every refreshed cookie keeps the login's deadline, not now+1h.
+			deadline := start.Add(time.Hour)
+			for _, name := range []string{"devplane_token", "devplane_refresh", sessionStartCookie} {
+				if c := responseCookie(resp, name); c == nil || !c.Expires.Equal(deadline) {
+					t.Errorf("%s cookie = %+v, want Expires %v", name, c, deadline)
+				}
+			}
+			if c := responseCookie(resp, sessionStartCookie); c == nil || c.Value != tt.start {
+				t.Errorf("%s cookie = %+v, want the original login time %s", sessionStartCookie, c, tt.start)
+			}
+		})
+	}
+}
+
+func TestHandleProxy_GroupNotAllowed_Forbidden(t *testing.T) {
+	v := &stubValidator{err: fmt.Errorf("%w: user \"alice\"", gw.ErrGroupNotAllowed)}
+	w := httptest.NewRecorder()
+
+	handleProxy(w, proxyRequest("tok"), v, nil, &stubLifecycle{}, &stubProxy{}, nil, "default", false, false, discardLog())
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status = %d, want 403 rather than a login redirect", resp.StatusCode)
+	}
+	if c := responseCookie(resp, "devplane_token"); c != nil {
+		t.Errorf("session cookie must be kept for a valid token, got %+v", c)
+	}
+}
+
+func TestHandleCallback_GroupNotAllowed_Forbidden(t *testing.T) {
+	tok := (&oauth2.Token{}).WithExtra(map[string]interface{}{"id_token": "validtoken"})
+	cfg := &stubOAuthConfig{token: tok}
+	v := &stubValidator{err: gw.ErrGroupNotAllowed}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/callback?state=mystate&code=xyz", nil)
+	r.AddCookie(&http.Cookie{Name: "devplane_state", Value: "mystate"})
+	r.AddCookie(&http.Cookie{Name: "devplane_pkce", Value: "verifier"})
+
+	handleCallback(w, r, cfg, v, false, 0, discardLog())
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("status = %d, want 403", w.Code)
+	}
+	if c := responseCookie(w.Result(), "devplane_token"); c != nil {
+		t.Errorf("no session cookie expected, got %+v", c)
+	}
+}
+
+func TestParseOIDCIssuers(t *testing.T) {
+	if got, err := parseOIDCIssuers(""); err != nil || got != nil {
+		t.Fatalf("empty: got %v, err %v", got, err)
+	}
+	got, err := parseOIDCIssuers(`[{"issuer":"https://a.example.com","clientID":"devplane","audiences":["api"],"userIDPrefix":"a"}]`)
+	if err != nil {
+		t.Fatalf("parseOIDCIssuers: %v", err)
+	}
+	if len(got) != 1 || got[0].Issuer != "https://a.example.com" || got[0].ClientID != "devplane" || !slices.Equal(got[0].Audiences, []string{"api"}) || got[0].UserIDPrefix != "a" {
+		t.Errorf("parseOIDCIssuers = %+v", got)
+	}
+	for _, raw := range []string{`{}`, `[{"issuer":"https://a.example.com"}]`, `[{"clientID":"x"}]`,
+		`[{"issuer":"https://a.example.com","clientID":"devplane"}]`} {
+		if _, err := parseOIDCIssuers(raw); err == nil {
+			t.Errorf("parseOIDCIssuers(%s): expected error", raw)
+		}
+	}
+}
+
+func TestParseCommaList(t *testing.T) {
+	if got := parseCommaList(""); got != nil {
+		t.Errorf("empty = %v, want nil", got)
+	}
+	got := parseCommaList(" devs, ,admins ,")
+	if len(got) != 2 || got[0] != "devs" || got[1] != "admins" {
+		t.Errorf("parseCommaList = %q, want [devs admins]", got)
+	}
+}
+
+func TestHandleProxy_InvalidSignature_DoesNotRefresh(t *testing.T) {
+	cfg := &stubOAuthConfig{}
+	refresher := &sessionRefresher{cfg: cfg, validator: expiringValidator, log: discardLog()}
+	r := proxyRequest("forged")
+	r.AddCookie(&http.Cookie{Name: "devplane_refresh", Value: "rt1"})
+	w := httptest.NewRecorder()
+
+	handleProxy(w, r, expiringValidator, refresher, &stubLifecycle{}, &stubProxy{}, nil, "default", false, false, discardLog())
+
+	if len(cfg.refreshedWith) != 0 {
+		t.Errorf("refresh attempted for a non-expiry failure: %v", cfg.refreshedWith)
+	}
+	resp := w.Result()
+	if loc := resp.Header.Get("Location"); loc != "/login" {
+		t.Errorf("redirect location = %q, want /login", loc)
+	}
+	if c := responseCookie(resp, "devplane_refresh"); c == nil || c.MaxAge != -1 {
+		t.Errorf("devplane_refresh cookie = %+v, want cleared with the rejected session", c)
+	}
+}
+
+func TestHandleProxy_RefreshRejected_ClearsRefreshCookie(t *testing.T) {
+	cfg := &stubOAuthConfig{refreshErr: errors.New("invalid_grant")}
+	refresher := &sessionRefresher{cfg: cfg, validator: expiringValidator, log: discardLog()}
+	r := proxyRequest("stale")
+	r.AddCookie(&http.Cookie{Name: "devplane_refresh", Value: "revoked"})
+	w := httptest.NewRecorder()
+
+	handleProxy(w, r, expiringValidator, refresher, &stubLifecycle{}, &stubProxy{}, nil, "default", false, false, discardLog())
+
+	resp := w.Result()
+	if loc := resp.Header.Get("Location"); loc != "/login" {
+		t.Errorf("redirect location = %q, want /login", loc)
+	}
+	if c := responseCookie(resp, "devplane_refresh"); c == nil || c.MaxAge != -1 {
+		t.Errorf("devplane_refresh cookie = %+v, want cleared", c)
+	}
+}
+
+func proxyRequest(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(&http.Cookie{Name: "devplane_token", Value: token})
+	return r
+}
+
+func validClaims() *gw.Claims {
+	return &gw.Claims{Sub: "alice", Email: "alice@example.com", UserID: "alice"}
+}
+
+func TestHandleProxy_EnsureExistsFails(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	v := &stubValidator{claims: validClaims()}
+	lc := &stubLifecycle{existsErr: errors.New("k8s unavailable")}
+	handleProxy(w, proxyRequest("tok"), v, nil, lc, &stubProxy{}, nil, "default", false, false, discardLog())
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", w.Code)
+	}
+}
+
+func TestHandleProxy_PendingPhase_ServesLoadingPage(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	v := &stubValidator{claims: validClaims()}
+	ws := &workspacev1alpha1.Workspace{}
+	ws.Status.Phase = workspacev1alpha1.WorkspacePhasePending
+	lc := &stubLifecycle{existsWs: ws}
+	handleProxy(w, proxyRequest("tok"), v, nil, lc, &stubProxy{}, nil, "default", false, false, discardLog())
+
+	if w.Code != http.StatusOK {
 		t.Errorf("status = %d, want 200", w.Code)
 	}
 	body := w.Body.String()
@@ -655,7 +1427,7 @@ func TestHandleProxy_CreatingPhase_ServesLoadingPage(t *testing.T) {
 	ws := &workspacev1alpha1.Workspace{}
 	ws.Status.Phase = workspacev1alpha1.WorkspacePhaseCreating
 	lc := &stubLifecycle{existsWs: ws}
-	handleProxy(w, proxyRequest("tok"), v, lc, "default", false, discardLog())
+	handleProxy(w, proxyRequest("tok"), v, nil, lc, &stubProxy{}, nil, "default", false, false, discardLog())
 
 	if w.Code != http.StatusOK {
 		t.Errorf("status = %d, want 200", w.Code)
@@ -673,7 +1445,7 @@ func TestHandleProxy_RunningNoEndpoint_ServesLoadingPage(t *testing.T) {
 	ws.Status.Phase = workspacev1alpha1.WorkspacePhaseRunning
 	ws.Status.ServiceEndpoint = "" // endpoint not yet set
 	lc := &stubLifecycle{existsWs: ws}
-	handleProxy(w, proxyRequest("tok"), v, lc, "default", false, discardLog())
+	handleProxy(w, proxyRequest("tok"), v, nil, lc, &stubProxy{}, nil, "default", false, false, discardLog())
 
 	if w.Code != http.StatusOK {
 		t.Errorf("status = %d, want 200", w.Code)
@@ -689,7 +1461,7 @@ func TestHandleProxy_FreshWorkspace_ServesLoadingPage(t *testing.T) {
 	v := &stubValidator{claims: validClaims()}
 	ws := &workspacev1alpha1.Workspace{} // phase == "" (brand new CR)
 	lc := &stubLifecycle{existsWs: ws}
-	handleProxy(w, proxyRequest("tok"), v, lc, "default", false, discardLog())
+	handleProxy(w, proxyRequest("tok"), v, nil, lc, &stubProxy{}, nil, "default", false, false, discardLog())
 
 	if w.Code != http.StatusOK {
 		t.Errorf("status = %d, want 200", w.Code)
@@ -709,7 +1481,7 @@ func TestHandleProxy_RunningWithEndpoint_ErrorHandlerServesLoadingPage(t *testin
 	// 127.0.0.1 → http://127.0.0.1:7681 — connection refused immediately (no ttyd in tests).
 	ws.Status.ServiceEndpoint = "127.0.0.1"
 	lc := &stubLifecycle{existsWs: ws}
-	handleProxy(w, proxyRequest("tok"), v, lc, "default", false, discardLog())
+	handleProxy(w, proxyRequest("tok"), v, nil, lc, &stubProxy{}, nil, "default", false, false, discardLog())
 
 	if w.Code != http.StatusOK {
 		t.Errorf("status = %d, want 200 (ErrorHandler should serve loading page)", w.Code)
@@ -719,6 +1491,88 @@ func TestHandleProxy_RunningWithEndpoint_ErrorHandlerServesLoadingPage(t *testin
 	}
 }
 
+// roundTripFunc adapts a function to http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestHandleProxy_UsesBackendTransport(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	v := &stubValidator{claims: validClaims()}
+	ws := &workspacev1alpha1.Workspace{}
+	ws.Status.Phase = workspacev1alpha1.WorkspacePhaseRunning
+	ws.Status.ServiceEndpoint = "alice-workspace.default.svc"
+	lc := &stubLifecycle{existsWs: ws}
+	var gotHost string
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		gotHost = r.URL.Host
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"text/plain"}},
+			Body:       io.NopCloser(strings.NewReader("ttyd")),
+			Request:    r,
+		}, nil
+	})
+	handleProxy(w, proxyRequest("tok"), v, nil, lc, &stubProxy{}, transport, "default", false, false, discardLog())
+
+	if gotHost != "alice-workspace.default.svc:7681" {
+		t.Errorf("backend host = %q, want the request to go through the configured transport", gotHost)
+	}
+	if w.Code != http.StatusOK || w.Body.String() != "ttyd" {
+		t.Errorf("response = %d %q, want 200 ttyd", w.Code, w.Body.String())
+	}
+}
+
+func TestHandleProxy_BackendTLSUsesHTTPS(t *testing.T) {
+	w := httptest.NewRecorder()
+
+	ws := &workspacev1alpha1.Workspace{}
+	ws.Status.Phase = workspacev1alpha1.WorkspacePhaseRunning
+	ws.Status.ServiceEndpoint = "alice-workspace.default.svc"
+	var gotScheme string
+	transport := roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		gotScheme = r.URL.Scheme
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ttyd")), Request: r}, nil
+	})
+	backend := gw.NewProxy(discardLog(), gw.ProxyConfig{BackendTLS: &tls.Config{}})
+	handleProxy(w, proxyRequest("tok"), &stubValidator{claims: validClaims()}, nil, &stubLifecycle{existsWs: ws}, backend, transport, "default", false, false, discardLog())
+
+	if gotScheme != "https" {
+		t.Errorf("backend scheme = %q, want https when GATEWAY_BACKEND_TLS is on", gotScheme)
+	}
+}
+
+func TestParseBackendTransportConfig(t *testing.T) {
+	t.Setenv("GATEWAY_BACKEND_IDLE_CONN_TIMEOUT", "")
+	t.Setenv("GATEWAY_BACKEND_MAX_IDLE_CONNS_PER_HOST", "")
+	t.Setenv("GATEWAY_BACKEND_DIAL_TIMEOUT", "")
+	if cfg, err := parseBackendTransportConfig(); err != nil || cfg != (gw.BackendTransportConfig{}) {
+		t.Errorf("default = %+v, %v; want zero (transport defaults)", cfg, err)
+	}
+
+	t.Setenv("GATEWAY_BACKEND_IDLE_CONN_TIMEOUT", "15s")
+	t.Setenv("GATEWAY_BACKEND_MAX_IDLE_CONNS_PER_HOST", "8")
+	t.Setenv("GATEWAY_BACKEND_DIAL_TIMEOUT", "2s")
+	cfg, err := parseBackendTransportConfig()
+	if err != nil {
+		t.Fatalf("parseBackendTransportConfig: %v", err)
+	}
+	want := gw.BackendTransportConfig{IdleConnTimeout: 15 * time.Second, MaxIdleConnsPerHost: 8, DialTimeout: 2 * time.Second}
+	if cfg != want {
+		t.Errorf("cfg = %+v, want %+v", cfg, want)
+	}
+	tr := gw.NewBackendTransport(cfg)
+	if tr.IdleConnTimeout != 15*time.Second || tr.MaxIdleConnsPerHost != 8 {
+		t.Errorf("transport idle = %s, max idle per host = %d; want 15s, 8", tr.IdleConnTimeout, tr.MaxIdleConnsPerHost)
+	}
+
+	t.Setenv("GATEWAY_BACKEND_DIAL_TIMEOUT", "0")
+	if _, err := parseBackendTransportConfig(); err == nil {
+		t.Error("expected error for zero dial timeout")
+	}
+}
+
 func TestHandleProxy_EmailFallsBackToUserID(t *testing.T) {
 	w := httptest.NewRecorder()
 
@@ -727,7 +1581,7 @@ func TestHandleProxy_EmailFallsBackToUserID(t *testing.T) {
 	ws := &workspacev1alpha1.Workspace{}
 	ws.Status.Phase = workspacev1alpha1.WorkspacePhasePending
 	lc := &stubLifecycle{existsWs: ws}
-	handleProxy(w, proxyRequest("tok"), v, lc, "default", false, discardLog())
+	handleProxy(w, proxyRequest("tok"), v, nil, lc, &stubProxy{}, nil, "default", false, false, discardLog())
 
 	if w.Code != http.StatusOK {
 		t.Errorf("status = %d, want 200", w.Code)
@@ -767,13 +1621,67 @@ func TestServeLoadingPage_Status200(t *testing.T) {
 
 // --- handleWorkspaceAPI tests ---
 
+func TestHandleKubeconfig_OK(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/me/kubeconfig", nil)
+	r.Header.Set("Authorization", "Bearer tok")
+	issuer := &stubKubeconfigIssuer{kubeconfig: []byte("apiVersion: v1\nkind: Config\n")}
+	handleKubeconfig(w, r, &stubValidator{claims: validClaims()}, issuer, "default", nil, discardLog(), nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if issuer.gotUser != "alice" {
+		t.Errorf("issued for %q, want alice", issuer.gotUser)
+	}
+	if got := w.Header().Get("Content-Disposition"); !strings.Contains(got, "devplane-alice.kubeconfig") {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", got)
+	}
+	if w.Body.String() != "apiVersion: v1\nkind: Config\n" {
+		t.Errorf("body = %q", w.Body.String())
+	}
+}
+
+func TestHandleKubeconfig_NoWorkspaceForbidden(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/me/kubeconfig", nil)
+	r.Header.Set("Authorization", "Bearer tok")
+	handleKubeconfig(w, r, &stubValidator{claims: validClaims()}, &stubKubeconfigIssuer{err: gw.ErrNoWorkspace}, "default", nil, discardLog(), nil)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want 403", w.Code)
+	}
+	var body gw.APIError
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if body.Error != gw.AuthErrorCodeForbidden {
+		t.Errorf("error = %q, want %s", body.Error, gw.AuthErrorCodeForbidden)
+	}
+}
+
+func TestHandleKubeconfig_Unauthorized(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/me/kubeconfig", nil)
+	issuer := &stubKubeconfigIssuer{}
+	handleKubeconfig(w, r, &stubValidator{}, issuer, "default", nil, discardLog(), nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("status = %d, want 401", w.Code)
+	}
+	if issuer.gotUser != "" {
+		t.Error("issuer must not be called without a token")
+	}
+}
+
 func TestHandleWorkspaceAPI_MethodNotAllowed(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodPut, "/api/workspace", nil)
-	handleWorkspaceAPI(w, r, &stubValidator{}, &stubLifecycle{}, "default", false, discardLog(), nil)
+	handleWorkspaceAPI(w, r, &stubValidator{}, &stubLifecycle{}, "default", false, nil, discardLog(), nil)
 	if w.Code != http.StatusMethodNotAllowed {
 		t.Errorf("status = %d, want 405", w.Code)
 	}
+	assertAPIError(t, w, http.StatusMethodNotAllowed, gw.MethodNotAllowedErrorCode)
 }
 
 func TestHandleWorkspaceAPI_POST_OK(t *testing.T) {
@@ -786,7 +1694,7 @@ func TestHandleWorkspaceAPI_POST_OK(t *testing.T) {
 	}
 	ws.Status.Phase = workspacev1alpha1.WorkspacePhasePending
 	lc := &stubLifecycle{existsWs: ws}
-	handleWorkspaceAPI(w, r, v, lc, "default", false, discardLog(), nil)
+	handleWorkspaceAPI(w, r, v, lc, "default", false, nil, discardLog(), nil)
 	if w.Code != http.StatusOK {
 		t.Fatalf("status = %d, want 200", w.Code)
 	}
@@ -802,16 +1710,16 @@ func TestHandleWorkspaceAPI_POST_OK(t *testing.T) {
 func TestHandleWorkspaceAPI_UnauthorizedNoToken(t *testing.T) {
 	w := httptest.NewRecorder()
 	r := httptest.NewRequest(http.MethodGet, "/api/workspace", nil)
-	handleWorkspaceAPI(w, r, &stubValidator{}, &stubLifecycle{}, "default", false, discardLog(), nil)
+	handleWorkspaceAPI(w, r, &stubValidator{}, &stubLifecycle{}, "default", false, nil, discardLog(), nil)
 	if w.Code != http.StatusUnauthorized {
 		t.Errorf("status = %d, want 401", w.Code)
 	}
-	var body map[string]string
+	var body gw.APIError
 	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
 		t.Fatalf("invalid JSON: %v", err)
 	}
-	if body["error"] != "unauthorized" {
-		t.Errorf("error = %q, want unauthorized", body["error"])
+	if body.Error != "unauthorized" {
+		t.Errorf("error = %q, want unauthorized", body.Error)
 	}
 }
 
@@ -820,7 +1728,7 @@ func TestHandleWorkspaceAPI_InvalidTokenJSON(t *testing.T) {
 	r := httptest.NewRequest(http.MethodGet, "/api/workspace", nil)
 	r.Header.Set("Authorization", "Bearer bad")
 	v := &stubValidator{err: fmt.Errorf("%w: invalid", gw.ErrUnauthorized)}
-	handleWorkspaceAPI(w, r, v, &stubLifecycle{}, "default", false, discardLog(), nil)
+	handleWorkspaceAPI(w, r, v, &stubLifecycle{}, "default", false, nil, discardLog(), nil)
 	if w.Code != http.StatusUnauthorized {
 		t.Errorf("status = %d, want 401", w.Code)
 	}
@@ -831,16 +1739,16 @@ func TestHandleWorkspaceAPI_ForbiddenJSON(t *testing.T) {
 	r := httptest.NewRequest(http.MethodGet, "/api/workspace", nil)
 	r.Header.Set("Authorization", "Bearer tok")
 	v := &stubValidator{err: fmt.Errorf("%w: aud mismatch", gw.ErrForbidden)}
-	handleWorkspaceAPI(w, r, v, &stubLifecycle{}, "default", false, discardLog(), nil)
+	handleWorkspaceAPI(w, r, v, &stubLifecycle{}, "default", false, nil, discardLog(), nil)
 	if w.Code != http.StatusForbidden {
 		t.Errorf("status = %d, want 403", w.Code)
 	}
-	var body map[string]string
+	var body gw.APIError
 	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
 		t.Fatalf("invalid JSON: %v", err)
 	}
-	if body["error"] != gw.AuthErrorCodeForbidden {
-		t.Errorf("error = %q, want forbidden", body["error"])
+	if body.Error != gw.AuthErrorCodeForbidden {
+		t.Errorf("error = %q, want forbidden", body.Error)
 	}
 }
 
@@ -850,10 +1758,11 @@ func TestHandleWorkspaceAPI_EnsureExistsFails(t *testing.T) {
 	r.Header.Set("Authorization", "Bearer tok")
 	v := &stubValidator{claims: validClaims()}
 	lc := &stubLifecycle{existsErr: errors.New("k8s down")}
-	handleWorkspaceAPI(w, r, v, lc, "default", false, discardLog(), nil)
+	handleWorkspaceAPI(w, r, v, lc, "default", false, nil, discardLog(), nil)
 	if w.Code != http.StatusInternalServerError {
 		t.Errorf("status = %d, want 500", w.Code)
 	}
+	assertAPIError(t, w, http.StatusInternalServerError, gw.WorkspaceErrorCodeUnavailable)
 }
 
 func TestHandleWorkspaceAPI_OK(t *testing.T) {
@@ -867,7 +1776,7 @@ func TestHandleWorkspaceAPI_OK(t *testing.T) {
 	ws.Status.Phase = workspacev1alpha1.WorkspacePhasePending
 	ws.Status.Message = "waiting"
 	lc := &stubLifecycle{existsWs: ws}
-	handleWorkspaceAPI(w, r, v, lc, "default", false, discardLog(), nil)
+	handleWorkspaceAPI(w, r, v, lc, "default", false, nil, discardLog(), nil)
 	if w.Code != http.StatusOK {
 		t.Fatalf("status = %d, want 200", w.Code)
 	}
@@ -915,7 +1824,7 @@ func TestHandleWorkspaceAPI_TTYDReadyTrue(t *testing.T) {
 	ws.Status.Phase = workspacev1alpha1.WorkspacePhaseRunning
 	ws.Status.ServiceEndpoint = "127.0.0.1"
 	lc := &stubLifecycle{existsWs: ws}
-	handleWorkspaceAPI(w, r, v, lc, "default", false, discardLog(), nil)
+	handleWorkspaceAPI(w, r, v, lc, "default", false, nil, discardLog(), nil)
 	if w.Code != http.StatusOK {
 		t.Fatalf("status = %d, want 200", w.Code)
 	}
@@ -932,6 +1841,61 @@ func TestHandleWorkspaceAPI_TTYDReadyTrue(t *testing.T) {
 // {"error":"rate_limited"} once the configured (non-nil) limiter rejects a request after OIDC
 // validation. Uses a global token bucket (1 RPS, burst 1): first request passes the limiter
 // then fails downstream; second hits the limiter first.
+func TestHandleWorkspaceAPI_WorkspaceInOtherNamespace(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/workspace", nil)
+	r.Header.Set("Authorization", "Bearer tok")
+	lc := &stubLifecycle{existsErr: &gw.WorkspaceElsewhereError{Namespace: "team-a", Name: "alice"}}
+	handleWorkspaceAPI(w, r, &stubValidator{claims: validClaims()}, lc, "team-b", false, nil, discardLog(), nil)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409", w.Code)
+	}
+	var body gw.APIError
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if body.Error != gw.WorkspaceErrorCodeElsewhere || body.Namespace != "team-a" {
+		t.Errorf("body = %v, want error %s and namespace team-a", body, gw.WorkspaceErrorCodeElsewhere)
+	}
+}
+
+func TestHandleWorkspaceAPI_QuotaExceeded(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/workspace", nil)
+	r.Header.Set("Authorization", "Bearer tok")
+	lc := &stubLifecycle{existsErr: fmt.Errorf("%w: limit 1", gw.ErrQuotaExceeded)}
+	handleWorkspaceAPI(w, r, &stubValidator{claims: validClaims()}, lc, "default", false, nil, discardLog(), nil)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+	var body gw.APIError
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if body.Error != gw.WorkspaceErrorCodeQuotaExceeded {
+		t.Errorf("error = %q, want %s", body.Error, gw.WorkspaceErrorCodeQuotaExceeded)
+	}
+}
+
+func TestHandleWorkspaceAPI_CreateThrottled(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/workspace", nil)
+	r.Header.Set("Authorization", "Bearer tok")
+	lc := &stubLifecycle{existsErr: &gw.CreateThrottledError{UserID: "alice", RetryAfter: 42 * time.Second}}
+	handleWorkspaceAPI(w, r, &stubValidator{claims: validClaims()}, lc, "default", false, nil, discardLog(), nil)
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429", w.Code)
+	}
+	var body gw.APIError
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if body.Error != gw.RateLimitErrorCode {
+		t.Errorf("error = %q, want %s", body.Error, gw.RateLimitErrorCode)
+	}
+	assertRetryAfter(t, w, 42, 42)
+}
+
 func TestHandleWorkspaceAPI_RateLimited(t *testing.T) {
 	rl := gw.NewEndpointLimiter(1, 1, 0, 0)
 	v := &stubValidator{claims: validClaims()}
@@ -940,7 +1904,7 @@ func TestHandleWorkspaceAPI_RateLimited(t *testing.T) {
 	r1 := httptest.NewRequest(http.MethodGet, "/api/workspace", nil)
 	r1.Header.Set("Authorization", "Bearer tok")
 	w1 := httptest.NewRecorder()
-	handleWorkspaceAPI(w1, r1, v, lc, "default", false, discardLog(), rl)
+	handleWorkspaceAPI(w1, r1, v, lc, "default", false, nil, discardLog(), rl)
 	if w1.Code != http.StatusInternalServerError {
 		t.Fatalf("first request status = %d, want 500 (past rate limit, downstream error)", w1.Code)
 	}
@@ -948,16 +1912,16 @@ func TestHandleWorkspaceAPI_RateLimited(t *testing.T) {
 	r2 := httptest.NewRequest(http.MethodGet, "/api/workspace", nil)
 	r2.Header.Set("Authorization", "Bearer tok")
 	w2 := httptest.NewRecorder()
-	handleWorkspaceAPI(w2, r2, v, lc, "default", false, discardLog(), rl)
+	handleWorkspaceAPI(w2, r2, v, lc, "default", false, nil, discardLog(), rl)
 	if w2.Code != http.StatusTooManyRequests {
 		t.Fatalf("second request status = %d, want 429", w2.Code)
 	}
-	var body map[string]string
+	var body gw.APIError
 	if err := json.Unmarshal(w2.Body.Bytes(), &body); err != nil {
 		t.Fatalf("invalid JSON: %v", err)
 	}
-	if body["error"] != gw.RateLimitErrorCode {
-		t.Errorf("error = %q, want %q", body["error"], gw.RateLimitErrorCode)
+	if body.Error != gw.RateLimitErrorCode {
+		t.Errorf("error = %q, want %q", body.Error, gw.RateLimitErrorCode)
 	}
 	if w2.Header().Get("X-Request-ID") == "" {
 		t.Error("expected X-Request-ID header on rate-limited response")
@@ -977,7 +1941,7 @@ func TestHandleWorkspaceAPI_RateLimitedPerUser(t *testing.T) {
 		w := httptest.NewRecorder()
 		r := httptest.NewRequest(http.MethodGet, "/api/workspace", nil)
 		r.Header.Set("Authorization", "Bearer tok")
-		handleWorkspaceAPI(w, r, v, lc, "default", false, discardLog(), rl)
+		handleWorkspaceAPI(w, r, v, lc, "default", false, nil, discardLog(), rl)
 		if w.Code != http.StatusInternalServerError {
 			t.Fatalf("request %d status = %d, want 500 (past rate limit, downstream error)", i+1, w.Code)
 		}
@@ -986,16 +1950,16 @@ func TestHandleWorkspaceAPI_RateLimitedPerUser(t *testing.T) {
 	w4 := httptest.NewRecorder()
 	r4 := httptest.NewRequest(http.MethodGet, "/api/workspace", nil)
 	r4.Header.Set("Authorization", "Bearer tok")
-	handleWorkspaceAPI(w4, r4, v, lc, "default", false, discardLog(), rl)
+	handleWorkspaceAPI(w4, r4, v, lc, "default", false, nil, discardLog(), rl)
 	if w4.Code != http.StatusTooManyRequests {
 		t.Fatalf("fourth request status = %d, want 429", w4.Code)
 	}
-	var body map[string]string
+	var body gw.APIError
 	if err := json.Unmarshal(w4.Body.Bytes(), &body); err != nil {
 		t.Fatalf("invalid JSON: %v", err)
 	}
-	if body["error"] != gw.RateLimitErrorCode {
-		t.Errorf("error = %q, want %q", body["error"], gw.RateLimitErrorCode)
+	if body.Error != gw.RateLimitErrorCode {
+		t.Errorf("error = %q, want %q", body.Error, gw.RateLimitErrorCode)
 	}
 	if gw.RateLimitHitsTotal("lifecycle", "user") != before+1 {
 		t.Errorf("expected exactly one new lifecycle/user rate-limit hit")
@@ -1010,13 +1974,13 @@ func TestHandleWS_RateLimited(t *testing.T) {
 	lc := &stubLifecycle{err: errors.New("downstream")}
 
 	w1 := httptest.NewRecorder()
-	handleWS(w1, wsRequest("a"), v, lc, &stubProxy{}, "default", discardLog(), rl)
+	handleWS(w1, wsRequest("a"), v, nil, lc, &stubProxy{}, "default", nil, discardLog(), rl)
 	if w1.Code != http.StatusInternalServerError {
 		t.Fatalf("first request status = %d, want 500", w1.Code)
 	}
 
 	w2 := httptest.NewRecorder()
-	handleWS(w2, wsRequest("a"), v, lc, &stubProxy{}, "default", discardLog(), rl)
+	handleWS(w2, wsRequest("a"), v, nil, lc, &stubProxy{}, "default", nil, discardLog(), rl)
 	if w2.Code != http.StatusTooManyRequests {
 		t.Fatalf("second request status = %d, want 429", w2.Code)
 	}
@@ -1042,14 +2006,14 @@ func TestHandleWS_RateLimitedPerUser(t *testing.T) {
 	before := gw.RateLimitHitsTotal("websocket", "user")
 	for i := 0; i < 3; i++ {
 		w := httptest.NewRecorder()
-		handleWS(w, wsRequest("a"), v, lc, &stubProxy{}, "default", discardLog(), rl)
+		handleWS(w, wsRequest("a"), v, nil, lc, &stubProxy{}, "default", nil, discardLog(), rl)
 		if w.Code != http.StatusInternalServerError {
 			t.Fatalf("request %d status = %d, want 500", i+1, w.Code)
 		}
 	}
 
 	w4 := httptest.NewRecorder()
-	handleWS(w4, wsRequest("a"), v, lc, &stubProxy{}, "default", discardLog(), rl)
+	handleWS(w4, wsRequest("a"), v, nil, lc, &stubProxy{}, "default", nil, discardLog(), rl)
 	if w4.Code != http.StatusTooManyRequests {
 		t.Fatalf("fourth request status = %d, want 429", w4.Code)
 	}
@@ -1064,3 +2028,636 @@ func TestHandleWS_RateLimitedPerUser(t *testing.T) {
 		t.Errorf("expected exactly one new websocket/user rate-limit hit")
 	}
 }
+
+// --- handleAdminInvalidate tests ---
+
+func TestHandleCheckpoint_Create(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/workspaces/me/checkpoints", strings.NewReader(`{"name":"before-upgrade"}`))
+	r.Header.Set("Authorization", "Bearer tok")
+	cps := &stubCheckpoints{}
+	handleCheckpoint(w, r, &stubValidator{claims: validClaims()}, cps, "default", false, nil, discardLog(), nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", w.Code)
+	}
+	if cps.gotUser != "alice" || cps.gotName != "before-upgrade" || cps.restored {
+		t.Errorf("Create called with user %q name %q (restored %v), want alice/before-upgrade", cps.gotUser, cps.gotName, cps.restored)
+	}
+	var got gw.Checkpoint
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode JSON: %v", err)
+	}
+	if got.Name != "before-upgrade" {
+		t.Errorf("name = %q, want before-upgrade", got.Name)
+	}
+}
+
+func TestHandleCheckpoint_CreateWithoutBody(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/workspaces/me/checkpoints", nil)
+	r.Header.Set("Authorization", "Bearer tok")
+	cps := &stubCheckpoints{}
+	handleCheckpoint(w, r, &stubValidator{claims: validClaims()}, cps, "default", false, nil, discardLog(), nil)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201", w.Code)
+	}
+	if cps.gotName != "" {
+		t.Errorf("name = %q, want empty so the manager generates one", cps.gotName)
+	}
+}
+
+func TestHandleCheckpoint_Restore(t *testing.T) {
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/workspaces/me/restore", strings.NewReader(`{"name":"before-upgrade"}`))
+	r.Header.Set("Authorization", "Bearer tok")
+	cps := &stubCheckpoints{}
+	handleCheckpoint(w, r, &stubValidator{claims: validClaims()}, cps, "default", true, nil, discardLog(), nil)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202", w.Code)
+	}
+	if !cps.restored || cps.gotName != "before-upgrade" {
+		t.Errorf("Restore called = %v with name %q, want before-upgrade", cps.restored, cps.gotName)
+	}
+}
+
+func TestHandleCheckpoint_Errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		restore bool
+		err     error
+		status  int
+		code    string
+	}{
+		{name: "malformed body", body: `{`, status: http.StatusBadRequest, code: gw.InvalidRequestErrorCode},
+		{name: "no workspace", err: gw.ErrNoWorkspace, status: http.StatusForbidden, code: gw.AuthErrorCodeForbidden},
+		{name: "exists", body: `{"name":"cp"}`, err: gw.ErrCheckpointExists, status: http.StatusConflict, code: gw.CheckpointErrorCodeExists},
+		{name: "restore not found", body: `{"name":"cp"}`, restore: true, err: gw.ErrCheckpointNotFound, status: http.StatusNotFound, code: gw.CheckpointErrorCodeNotFound},
+		{name: "restore not ready", body: `{"name":"cp"}`, restore: true, err: gw.ErrCheckpointNotReady, status: http.StatusConflict, code: gw.CheckpointErrorCodeNotReady},
+		{name: "downstream", err: errors.New("k8s down"), status: http.StatusInternalServerError, code: gw.WorkspaceErrorCodeUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/api/workspaces/me/checkpoints", strings.NewReader(tt.body))
+			r.Header.Set("Authorization", "Bearer tok")
+			handleCheckpoint(w, r, &stubValidator{claims: validClaims()}, &stubCheckpoints{err: tt.err}, "default", tt.restore, nil, discardLog(), nil)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			assertAPIError(t, w, tt.status, tt.code)
+		})
+	}
+}
+
+// assertAPIError checks that w holds the JSON error body of the /api/*
+// endpoints: {"error": code, "message": ..., "code": status}.
+func assertAPIError(t *testing.T, w *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if body["error"] != code {
+		t.Errorf("error = %v, want %q", body["error"], code)
+	}
+	if msg, _ := body["message"].(string); msg == "" {
+		t.Errorf("message = %v, want a non-empty string", body["message"])
+	}
+	if body["code"] != float64(status) {
+		t.Errorf("code = %v, want %d", body["code"], status)
+	}
+}
+
+func TestHandleWorkspaceAPI_JSONErrorShape(t *testing.T) {
+	tests := []struct {
+		name      string
+		token     string
+		validator *stubValidator
+		lifecycle *stubLifecycle
+		status    int
+		code      string
+	}{
+		{
+			name:      "missing token",
+			validator: &stubValidator{},
+			lifecycle: &stubLifecycle{},
+			status:    http.StatusUnauthorized,
+			code:      gw.AuthErrorCodeUnauthorized,
+		},
+		{
+			name:      "expired token",
+			token:     "old",
+			validator: &stubValidator{err: fmt.Errorf("%w: exp", gw.ErrTokenExpired)},
+			lifecycle: &stubLifecycle{},
+			status:    http.StatusUnauthorized,
+			code:      gw.AuthErrorCodeTokenExpired,
+		},
+		{
+			name:      "quota exceeded",
+			token:     "tok",
+			validator: &stubValidator{claims: validClaims()},
+			lifecycle: &stubLifecycle{existsErr: gw.ErrQuotaExceeded},
+			status:    http.StatusTooManyRequests,
+			code:      gw.WorkspaceErrorCodeQuotaExceeded,
+		},
+		{
+			name:      "workspace elsewhere",
+			token:     "tok",
+			validator: &stubValidator{claims: validClaims()},
+			lifecycle: &stubLifecycle{existsErr: &gw.WorkspaceElsewhereError{Namespace: "team-a", Name: "alice"}},
+			status:    http.StatusConflict,
+			code:      gw.WorkspaceErrorCodeElsewhere,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, "/api/workspace", nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			handleWorkspaceAPI(w, r, tt.validator, tt.lifecycle, "default", false, nil, discardLog(), nil)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d", w.Code, tt.status)
+			}
+			assertAPIError(t, w, tt.status, tt.code)
+		})
+	}
+}
+
+func TestHandleAdminInvalidate_WrongToken(t *testing.T) {
+	inv := &stubInvalidator{}
+	r := httptest.NewRequest(http.MethodPost, "/api/admin/invalidate/alice", nil)
+	r.SetPathValue("user", "alice")
+	r.Header.Set("Authorization", "Bearer nope")
+	w := httptest.NewRecorder()
+	handleAdminInvalidate(w, r, inv, "s3cret", nil, discardLog())
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", w.Code)
+	}
+	assertAPIError(t, w, http.StatusUnauthorized, gw.AuthErrorCodeUnauthorized)
+	if len(inv.users) != 0 {
+		t.Errorf("EvictUser should not be called on auth failure, got %v", inv.users)
+	}
+}
+
+func TestHandleAdminInvalidate_User(t *testing.T) {
+	inv := &stubInvalidator{}
+	r := httptest.NewRequest(http.MethodPost, "/api/admin/invalidate/alice", nil)
+	r.SetPathValue("user", "alice")
+	r.Header.Set("Authorization", "Bearer s3cret")
+	w := httptest.NewRecorder()
+	handleAdminInvalidate(w, r, inv, "s3cret", nil, discardLog())
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if len(inv.users) != 1 || inv.users[0] != "alice" {
+		t.Errorf("EvictUser calls = %v, want [alice]", inv.users)
+	}
+	var body adminInvalidateResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if body.Evicted != 2 {
+		t.Errorf("evicted = %d, want 2", body.Evicted)
+	}
+}
+
+func TestHandleAdminInvalidate_TokenHash(t *testing.T) {
+	inv := &stubInvalidator{}
+	r := httptest.NewRequest(http.MethodPost, "/api/admin/invalidate/token/abc123", nil)
+	r.SetPathValue("hash", "abc123")
+	r.Header.Set("Authorization", "Bearer s3cret")
+	w := httptest.NewRecorder()
+	handleAdminInvalidate(w, r, inv, "s3cret", nil, discardLog())
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if len(inv.hashes) != 1 || inv.hashes[0] != "abc123" || len(inv.users) != 0 {
+		t.Errorf("hashes = %v users = %v, want hash eviction only", inv.hashes, inv.users)
+	}
+}
+
+func healthRequest(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/workspaces/alice/healthz", nil)
+	r.SetPathValue("user", "alice")
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func TestHandleWorkspaceHealth(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		checker    *stubHealth
+		wantStatus int
+		wantPhase  string
+	}{
+		{
+			name:       "running and reachable",
+			token:      "monitor",
+			checker:    &stubHealth{health: gw.WorkspaceHealth{User: "alice", Healthy: true, Phase: "Running"}},
+			wantStatus: http.StatusOK,
+			wantPhase:  "Running",
+		},
+		{
+			name:       "running but unreachable",
+			token:      "admin",
+			checker:    &stubHealth{health: gw.WorkspaceHealth{User: "alice", Phase: "Running", Message: "Workspace backend is unreachable: connection refused"}},
+			wantStatus: http.StatusServiceUnavailable,
+			wantPhase:  "Running",
+		},
+		{
+			name:       "not running",
+			token:      "monitor",
+			checker:    &stubHealth{health: gw.WorkspaceHealth{User: "alice", Phase: "Stopped", Message: "Workspace stopped due to inactivity"}},
+			wantStatus: http.StatusServiceUnavailable,
+			wantPhase:  "Stopped",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			handleWorkspaceHealth(w, healthRequest(tt.token), tt.checker, []string{"monitor", "admin"}, "default", discardLog())
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.checker.gotUser != "alice" {
+				t.Errorf("checked user = %q, want alice", tt.checker.gotUser)
+			}
+			var body gw.WorkspaceHealth
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if body.Phase != tt.wantPhase || body.Message != tt.checker.health.Message {
+				t.Errorf("body = %+v, want phase %q and the checker's message", body, tt.wantPhase)
+			}
+		})
+	}
+}
+
+func TestHandleWorkspaceHealth_RequiresToken(t *testing.T) {
+	for _, token := range []string{"", "wrong"} {
+		checker := &stubHealth{health: gw.WorkspaceHealth{Healthy: true}}
+		w := httptest.NewRecorder()
+		handleWorkspaceHealth(w, healthRequest(token), checker, []string{"monitor"}, "default", discardLog())
+		assertAPIError(t, w, http.StatusUnauthorized, gw.AuthErrorCodeUnauthorized)
+		if checker.gotUser != "" {
+			t.Errorf("token %q: Check must not run without a valid token", token)
+		}
+	}
+}
+
+func TestHandleWorkspaceHealth_CheckError(t *testing.T) {
+	w := httptest.NewRecorder()
+	checker := &stubHealth{err: errors.New("k8s unavailable")}
+	handleWorkspaceHealth(w, healthRequest("monitor"), checker, []string{"monitor"}, "default", discardLog())
+	assertAPIError(t, w, http.StatusServiceUnavailable, gw.WorkspaceErrorCodeUnavailable)
+}
+
+// --- withTimeout tests ---
+
+func TestWithTimeout_SlowHandlerReturns503(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/workspace", nil)
+	start := time.Now()
+	withTimeout(slow, 20*time.Millisecond).ServeHTTP(w, r)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("handler not cut off at timeout (took %v)", elapsed)
+	}
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), gw.TimeoutErrorCode) {
+		t.Errorf("body = %q, want %q", w.Body.String(), gw.TimeoutErrorCode)
+	}
+	assertRetryAfter(t, w, 1, 10)
+}
+
+func TestWithTimeout_FastHandlerHasNoRetryAfter(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	w := httptest.NewRecorder()
+	withTimeout(ok, time.Second).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/workspace", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "" {
+		t.Errorf("Retry-After = %q on a successful response, want none", got)
+	}
+}
+
+func TestWithTimeout_WebSocketUpgradeBypassesTimeout(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		time.Sleep(40 * time.Millisecond)
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	r.Header.Set("Connection", "Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	withTimeout(h, 10*time.Millisecond).ServeHTTP(w, r)
+	if w.Code != http.StatusSwitchingProtocols {
+		t.Errorf("status = %d, want 101 (no timeout for upgrades)", w.Code)
+	}
+}
+
+func TestIsWebSocketUpgrade(t *testing.T) {
+	tests := []struct {
+		name       string
+		connection []string
+		upgrade    string
+		want       bool
+	}{
+		{"plain", []string{"Upgrade"}, "websocket", true},
+		{"token list", []string{"keep-alive, Upgrade"}, "WebSocket", true},
+		{"repeated header", []string{"keep-alive", "upgrade"}, "websocket", true},
+		{"no connection token", []string{"keep-alive"}, "websocket", false},
+		{"token substring", []string{"x-upgrade-hint"}, "websocket", false},
+		{"other protocol", []string{"Upgrade"}, "h2c", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+			for _, v := range tt.connection {
+				r.Header.Add("Connection", v)
+			}
+			r.Header.Set("Upgrade", tt.upgrade)
+			if got := isWebSocketUpgrade(r); got != tt.want {
+				t.Errorf("isWebSocketUpgrade = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// captureLog returns a logger that appends each JSON log line to the
+// returned function's result.
+func captureLog() (logr.Logger, func() []map[string]any) {
+	var mu sync.Mutex
+	var lines []map[string]any
+	log := funcr.NewJSON(func(obj string) {
+		var m map[string]any
+		if err := json.Unmarshal([]byte(obj), &m); err == nil {
+			mu.Lock()
+			lines = append(lines, m)
+			mu.Unlock()
+		}
+	}, funcr.Options{})
+	return log, func() []map[string]any {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(lines)
+	}
+}
+
+func TestWithAccessLog_LogsRequestFields(t *testing.T) {
+	log, lines := captureLog()
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setAccessLogUser(r.Context(), "alice")
+		w.WriteHeader(http.StatusTeapot)
+	})
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/api/workspace?x=1", nil)
+	r.RemoteAddr = "192.0.2.10:5555"
+	withAccessLog(withTimeout(h, time.Second), nil, log).ServeHTTP(w, r)
+
+	got := lines()
+	if len(got) != 1 {
+		t.Fatalf("log lines = %v, want 1", got)
+	}
+	line := got[0]
+	want := map[string]any{
+		"msg":           "HTTP request",
+		gw.LogKeyEvent:  gw.EventHTTPAccess,
+		"method":        http.MethodPost,
+		"path":          "/api/workspace",
+		"status":        float64(http.StatusTeapot),
+		"remote":        "192.0.2.10",
+		gw.LogKeyUserID: "alice",
+	}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf("%s = %v, want %v", k, line[k], v)
+		}
+	}
+	if _, ok := line["durationMs"].(float64); !ok {
+		t.Errorf("durationMs = %v, want a number", line["durationMs"])
+	}
+}
+
+func TestWithAccessLog_RemoteUsesTrustedProxies(t *testing.T) {
+	proxies, err := gw.ParseTrustedProxies("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies: %v", err)
+	}
+	log, lines := captureLog()
+	h := http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})
+	r := httptest.NewRequest(http.MethodGet, "/api/workspace", nil)
+	r.RemoteAddr = "10.1.2.3:5555"
+	r.Header.Set("X-Forwarded-For", "198.51.100.7")
+	withAccessLog(h, proxies, log).ServeHTTP(httptest.NewRecorder(), r)
+	if got := lines(); len(got) != 1 || got[0]["remote"] != "198.51.100.7" {
+		t.Fatalf("log lines = %v, want one with remote 198.51.100.7", got)
+	}
+}
+
+func TestWithAccessLog_OmitsUserWhenUnauthenticated(t *testing.T) {
+	log, lines := captureLog()
+	h := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		gw.WriteJSONAuthError(w, http.StatusUnauthorized, gw.AuthErrorCodeUnauthorized)
+	})
+	withAccessLog(h, nil, log).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/workspace", nil))
+	got := lines()
+	if len(got) != 1 || got[0]["status"] != float64(http.StatusUnauthorized) {
+		t.Fatalf("log lines = %v, want one with status 401", got)
+	}
+	if _, ok := got[0][gw.LogKeyUserID]; ok {
+		t.Errorf("unauthenticated request logged user %v", got[0][gw.LogKeyUserID])
+	}
+}
+
+// TestWithAccessLog_WebSocketLoggedWhenTunnelCloses hijacks the connection
+// like a WebSocket upgrade and checks the access log line is only written
+// after the tunnel closes, with status 101.
+func TestWithAccessLog_WebSocketLoggedWhenTunnelCloses(t *testing.T) {
+	log, lines := captureLog()
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		setAccessLogUser(r.Context(), "bob")
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Errorf("Hijack: %v", err)
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\n")
+		_ = rw.Flush()
+		// Hold the tunnel open until the client hangs up.
+		_, _ = rw.ReadByte()
+	})
+	srv := httptest.NewServer(withAccessLog(h, nil, log))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	_, _ = fmt.Fprint(conn, "GET /ws HTTP/1.1\r\nHost: gw\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+	status, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || !strings.Contains(status, "101") {
+		t.Fatalf("status line = %q, %v; want 101", status, err)
+	}
+	if got := lines(); len(got) != 0 {
+		t.Fatalf("logged while tunnel open: %v", got)
+	}
+	_ = conn.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(lines()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no access log line after tunnel closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	line := lines()[0]
+	if line["status"] != float64(http.StatusSwitchingProtocols) || line[gw.LogKeyUserID] != "bob" {
+		t.Errorf("log line = %v, want status 101 for user bob", line)
+	}
+}
+
+func TestParseHandlerTimeout(t *testing.T) {
+	t.Setenv("GATEWAY_HANDLER_TIMEOUT", "")
+	if d, err := parseHandlerTimeout(); err != nil || d != 30*time.Second {
+		t.Errorf("default = %v, %v; want 30s", d, err)
+	}
+	t.Setenv("GATEWAY_HANDLER_TIMEOUT", "-1s")
+	if _, err := parseHandlerTimeout(); err == nil {
+		t.Error("expected error for negative timeout")
+	}
+}
+
+func TestParseMaxProvisioningWaits(t *testing.T) {
+	t.Setenv("GATEWAY_MAX_PROVISIONING_WAITS", "")
+	if n, err := parseMaxProvisioningWaits(); err != nil || n != 0 {
+		t.Errorf("default = %d, %v; want 0 (unlimited)", n, err)
+	}
+	t.Setenv("GATEWAY_MAX_PROVISIONING_WAITS", "50")
+	if n, err := parseMaxProvisioningWaits(); err != nil || n != 50 {
+		t.Errorf("got %d, %v; want 50", n, err)
+	}
+	t.Setenv("GATEWAY_MAX_PROVISIONING_WAITS", "-1")
+	if _, err := parseMaxProvisioningWaits(); err == nil {
+		t.Error("expected error for negative limit")
+	}
+}
+
+func TestParseMinCreateInterval(t *testing.T) {
+	t.Setenv("GATEWAY_MIN_CREATE_INTERVAL", "")
+	if d, err := parseMinCreateInterval(); err != nil || d != 0 {
+		t.Errorf("default = %s, %v; want 0 (disabled)", d, err)
+	}
+	t.Setenv("GATEWAY_MIN_CREATE_INTERVAL", "1m")
+	if d, err := parseMinCreateInterval(); err != nil || d != time.Minute {
+		t.Errorf("got %s, %v; want 1m", d, err)
+	}
+	t.Setenv("GATEWAY_MIN_CREATE_INTERVAL", "-1s")
+	if _, err := parseMinCreateInterval(); err == nil {
+		t.Error("expected error for negative interval")
+	}
+}
+
+func TestParseWSKeepaliveInterval(t *testing.T) {
+	t.Setenv("GATEWAY_WS_KEEPALIVE_INTERVAL", "")
+	if d, err := parseWSKeepaliveInterval(); err != nil || d != 0 {
+		t.Errorf("default = %s, %v; want 0 (proxy default)", d, err)
+	}
+	t.Setenv("GATEWAY_WS_KEEPALIVE_INTERVAL", "20s")
+	if d, err := parseWSKeepaliveInterval(); err != nil || d != 20*time.Second {
+		t.Errorf("got %s, %v; want 20s", d, err)
+	}
+	t.Setenv("GATEWAY_WS_KEEPALIVE_INTERVAL", "0")
+	if d, err := parseWSKeepaliveInterval(); err != nil || d >= 0 {
+		t.Errorf("\"0\" = %s, %v; want negative (disabled)", d, err)
+	}
+}
+
+func TestParseWorkspaceReadyTimeout(t *testing.T) {
+	t.Setenv("WORKSPACE_READY_TIMEOUT", "")
+	if d, err := parseWorkspaceReadyTimeout(); err != nil || d != 0 {
+		t.Errorf("default = %s, %v; want 0 (lifecycle default)", d, err)
+	}
+	t.Setenv("WORKSPACE_READY_TIMEOUT", "3m")
+	if d, err := parseWorkspaceReadyTimeout(); err != nil || d != 3*time.Minute {
+		t.Errorf("got %s, %v; want 3m", d, err)
+	}
+	t.Setenv("WORKSPACE_READY_TIMEOUT", "0")
+	if _, err := parseWorkspaceReadyTimeout(); err == nil {
+		t.Error("expected error for zero timeout")
+	}
+}
+
+func TestParseMaxWorkspacesPerUser(t *testing.T) {
+	t.Setenv("GATEWAY_MAX_WORKSPACES_PER_USER", "")
+	if n, err := parseMaxWorkspacesPerUser(); err != nil || n != 0 {
+		t.Errorf("default = %d, %v; want 0 (unlimited)", n, err)
+	}
+	t.Setenv("GATEWAY_MAX_WORKSPACES_PER_USER", "3")
+	if n, err := parseMaxWorkspacesPerUser(); err != nil || n != 3 {
+		t.Errorf("got %d, %v; want 3", n, err)
+	}
+	t.Setenv("GATEWAY_MAX_WORKSPACES_PER_USER", "many")
+	if _, err := parseMaxWorkspacesPerUser(); err == nil {
+		t.Error("expected error for non-integer limit")
+	}
+}
+
+func TestParseMaxWSConnectionsPerUser(t *testing.T) {
+	t.Setenv("GATEWAY_MAX_WS_CONNECTIONS_PER_USER", "")
+	if n, err := parseMaxWSConnectionsPerUser(); err != nil || n != 0 {
+		t.Errorf("default = %d, %v; want 0 (unlimited)", n, err)
+	}
+	t.Setenv("GATEWAY_MAX_WS_CONNECTIONS_PER_USER", "5")
+	if n, err := parseMaxWSConnectionsPerUser(); err != nil || n != 5 {
+		t.Errorf("got %d, %v; want 5", n, err)
+	}
+	t.Setenv("GATEWAY_MAX_WS_CONNECTIONS_PER_USER", "-1")
+	if _, err := parseMaxWSConnectionsPerUser(); err == nil {
+		t.Error("expected error for negative limit")
+	}
+}
+
+func TestRegisterPprof(t *testing.T) {
+	for _, tc := range []struct {
+		enabled bool
+		want    int
+	}{
+		{enabled: true, want: http.StatusOK},
+		{enabled: false, want: http.StatusNotFound},
+	} {
+		mux := http.NewServeMux()
+		// Catch-all like the workspace proxy route, so disabled pprof must not fall through.
+		mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		})
+		registerPprof(mux, tc.enabled)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+		if w.Code != tc.want {
+			t.Errorf("enabled=%v: GET /debug/pprof/ status = %d, want %d", tc.enabled, w.Code, tc.want)
+		}
+	}
+}
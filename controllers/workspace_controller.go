@@ -4,20 +4,32 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
+	"golang.org/x/time/rate"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	workspacev1alpha1 "workspace-operator/api/v1alpha1"
 	"workspace-operator/pkg/observability"
@@ -25,6 +37,58 @@ import (
 	"workspace-operator/pkg/workspace"
 )
 
+// FreezeConfigMapKey is the key in the freeze ConfigMap that, when "true",
+// pauses reconciliation of every Workspace.
+const FreezeConfigMapKey = "frozen"
+
+// freezeRequeueInterval is how often a frozen Reconcile re-checks the freeze.
+const freezeRequeueInterval = 30 * time.Second
+
+// DefaultStuckTerminatingTimeout is how long a workspace pod may stay
+// Terminating before the operator force-deletes it, when
+// STUCK_TERMINATING_TIMEOUT is unset.
+const DefaultStuckTerminatingTimeout = 10 * time.Minute
+
+// dependencyRequeueInterval is how often a workspace waiting on spec.dependsOn
+// re-checks its dependencies.
+const dependencyRequeueInterval = 10 * time.Second
+
+// pvcPendingGrace is how long a PVC of an Immediate-binding storage class may
+// stay Pending before the workspace reports it and waits instead of creating the pod.
+const pvcPendingGrace = 2 * time.Minute
+
+// pvcPendingRequeueInterval is how often a workspace blocked on a Pending PVC re-checks it.
+const pvcPendingRequeueInterval = 15 * time.Second
+
+// DefaultMaxConcurrentImageRollouts is how many workspace pods may be
+// recreated for an image change at once when MAX_CONCURRENT_IMAGE_ROLLOUTS is unset.
+const DefaultMaxConcurrentImageRollouts = 10
+
+// imageRolloutRequeueInterval is how often a workspace waiting for an image
+// rollout slot retries.
+const imageRolloutRequeueInterval = 15 * time.Second
+
+// imageRolloutSlotTimeout releases a rollout slot whose recreated pod never
+// became Ready, so a broken image cannot stall the rollout forever.
+const imageRolloutSlotTimeout = 10 * time.Minute
+
+// DefaultIdleStopsPerSecond is the sustained idle-stop rate when
+// IDLE_STOPS_PER_SECOND is unset.
+const DefaultIdleStopsPerSecond = 5.0
+
+// idleStopRequeueInterval is how soon a workspace held back by the idle stop
+// rate limit retries.
+const idleStopRequeueInterval = 5 * time.Second
+
+// statusUpdateAttempts bounds how many times updateStatus refetches and retries
+// a status patch that fails with a conflict.
+const statusUpdateAttempts = 3
+
+// reconcileRecordInterval bounds how often status.lastReconcileTime is refreshed
+// while the result is unchanged, so the status write does not itself trigger an
+// endless stream of reconciles.
+const reconcileRecordInterval = time.Minute
+
 // workspaceFinalizer is registered on every Workspace CR so that the operator
 // can perform cleanup before the object is removed from the API server.
 const workspaceFinalizer = "workspace.devplane.io/finalizer"
@@ -45,6 +109,48 @@ type WorkspaceReconciler struct {
 	// becomes Stopped. Per-workspace override: spec.lifecycle.idleTimeout. Zero
 	// disables the idle check when no per-workspace value is set.
 	IdleTimeout time.Duration
+	// NeverAccessedIdleTimeout is a shorter idle timeout for workspaces nobody
+	// has accessed since creation (status.lastAccessed unset or not after the
+	// creation timestamp), so unused workspaces are reaped sooner. It only
+	// shortens an enabled idle timeout. Zero applies the idle timeout to all.
+	NeverAccessedIdleTimeout time.Duration
+	// UseDeployment runs every workspace as a Deployment (one replica unless
+	// spec.replicas says otherwise) instead of a bare Pod, so a pod lost to
+	// eviction or a node failure is replaced by the ReplicaSet controller. Idle
+	// shutdown then scales the Deployment to zero. Image rollouts still honour
+	// spec.autoUpdate and MaxConcurrentImageRollouts, but the other Pod-only
+	// features (stuck-Terminating cleanup, crash and OOM diagnostics, the
+	// PodScheduled condition, GPU capacity warnings) do not apply in this mode.
+	// main enables it with WORKSPACE_USE_DEPLOYMENT=true.
+	UseDeployment bool
+	// IdleGracePeriod delays the idle stop: once the idle timeout is reached the
+	// workspace stays Running with a "stopping soon" message and
+	// status.idleStopAt set, and the pod is deleted only if lastAccessed has not
+	// advanced by then. Zero stops as soon as the idle timeout is reached.
+	IdleGracePeriod time.Duration
+	// MinStorage is the smallest spec.resources.storage the operator accepts.
+	// Workspaces requesting less are marked Failed with a validation message.
+	// Zero disables the floor.
+	MinStorage resource.Quantity
+	// MaxProvidersJSONBytes caps the serialized size of spec.aiConfig.providers
+	// (injected as AI_PROVIDERS_JSON). Larger specs are marked Failed instead of
+	// producing a pod that cannot start. Zero disables the limit.
+	MaxProvidersJSONBytes int
+	// MaxEgressPorts caps how many egress ports a spec may list across
+	// spec.aiConfig.egressPorts and spec.dataEgress. Larger specs are marked
+	// Failed. Zero disables the limit.
+	MaxEgressPorts int
+	// StuckTerminatingTimeout is how long a workspace pod may stay Terminating
+	// (e.g. on a lost node) before it is force-deleted with a zero grace period so
+	// it can be recreated. Zero disables the repair.
+	StuckTerminatingTimeout time.Duration
+	// ResourceQuota enables an owned ResourceQuota in the workspace namespace
+	// sized from the workspace's requests, limits and storage. Only useful when
+	// each workspace has a namespace of its own.
+	ResourceQuota bool
+	// ResourceQuotaHeadroomPercent is added on top of the workspace's own
+	// resources when sizing the ResourceQuota.
+	ResourceQuotaHeadroomPercent int
 	// GatewayNamespace is the namespace where gateway pods run (e.g.
 	// "workspace-operator-system").  It is used to add a cross-namespace
 	// NamespaceSelector to the ingress-gateway NetworkPolicy so that the
@@ -58,21 +164,116 @@ type WorkspaceReconciler struct {
 	PipIndexURL     string
 	PipTrustedHost  string
 	NpmRegistry     string
+	// APIServerEgress opens egress to the Kubernetes API server for every
+	// workspace; spec.apiServerEgress enables it per workspace.
+	APIServerEgress bool
+	// APIServerCIDRs are the API server endpoint IPs/CIDRs for that rule. When
+	// empty they are resolved from the default/kubernetes EndpointSlices.
+	APIServerCIDRs []string
+	// APIReader reads the kubernetes EndpointSlices uncached, so the operator
+	// does not cache every EndpointSlice in the cluster. Falls back to Client.
+	APIReader client.Reader
+	// RuntimeClassName is the default RuntimeClass for workspace pods whose
+	// spec.runtimeClassName is empty (e.g. "gvisor"). Empty uses the cluster default.
+	RuntimeClassName string
+	// DownwardAPIPath, when set, mounts the pod's name, namespace and user
+	// label as files at this path in the workspace container.
+	DownwardAPIPath string
+	// DefaultPodAnnotations are set on every workspace pod, e.g.
+	// sidecar.istio.io/inject: "false"; spec.podAnnotations overrides them.
+	DefaultPodAnnotations map[string]string
+	// SharedServiceAccount runs every workspace pod in a namespace as one
+	// shared ServiceAccount (workspace.SharedServiceAccountName) with one
+	// Role and RoleBinding, instead of one set per user. This trades per-user
+	// in-cluster identity for far fewer RBAC objects in dense namespaces.
+	SharedServiceAccount bool
+	// Frozen pauses reconciliation of all workspaces for the operator's lifetime
+	// (RECONCILE_FREEZE). Existing resources are left untouched.
+	Frozen bool
+	// FreezeConfigMap optionally names a ConfigMap whose FreezeConfigMapKey
+	// toggles the same freeze at runtime, without restarting the operator.
+	// A missing ConfigMap means not frozen.
+	FreezeConfigMap types.NamespacedName
+	// ValidateCABundle checks that every key of the workspace's CA bundle
+	// ConfigMap parses as PEM certificates and reports bad keys in the
+	// CABundleValid condition and a Warning event. The pod is still started.
+	ValidateCABundle bool
 	// Recorder emits Kubernetes API events for operator-visible failures (optional).
 	Recorder events.EventRecorder
+	// MaxConcurrentImageRollouts caps how many workspace pods are recreated for
+	// an image change at the same time, across all namespaces. A slot is held
+	// from the pod deletion until the replacement is Ready. Zero disables the cap.
+	MaxConcurrentImageRollouts int
+	// Ingress, when Ingress.BaseDomain is set, gives every workspace an owned
+	// Ingress at <user>.<BaseDomain> routed to its Service, for URL-based
+	// access without the gateway proxy.
+	Ingress workspace.IngressOpts
+	// IngressControllerNamespace is the namespace of the ingress controller
+	// pods. When set together with Ingress, the ingress-gateway NetworkPolicy
+	// also admits ttyd traffic from that namespace.
+	IngressControllerNamespace string
+	// ExternalDNSHostname is a host template such as "{user}.ws.example.com"
+	// expanded into the external-dns hostname annotation on every workspace
+	// Service. {namespace} is also replaced. Empty sets no annotation.
+	ExternalDNSHostname string
+	// ResourceLabels are label templates added to every object the operator
+	// creates for a workspace (e.g. {"cost-center": "{label:team}"}), rendered
+	// by workspace.ResourceLabels. Core labels are never overridden.
+	ResourceLabels map[string]string
+
+	// IdleStopsPerSecond rate-limits idle stops across all workspaces, so a
+	// mass idle timeout does not burst the API server. The burst is the rate
+	// rounded down, at least 1. Zero disables the rate limit.
+	IdleStopsPerSecond float64
+
+	idleStopOnce    sync.Once
+	idleStopLimiter *rate.Limiter
 }
 
 //+kubebuilder:rbac:groups=workspace.devplane.io,resources=workspaces,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=workspace.devplane.io,resources=workspaces/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=workspace.devplane.io,resources=workspaces/finalizers,verbs=update
+//+kubebuilder:rbac:groups=workspace.devplane.io,resources=workspacetemplates,verbs=get;list;watch
 //+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 //+kubebuilder:rbac:groups=events.k8s.io,resources=events,verbs=create;patch;update
-//+kubebuilder:rbac:groups=core,resources=pods;persistentvolumeclaims;services;serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=pods;persistentvolumeclaims;services;serviceaccounts;configmaps;resourcequotas,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=pods/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings;roles,verbs=get;list;watch;create;update;patch;delete
-//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies;ingresses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=storage.k8s.io,resources=storageclasses,verbs=get;list;watch
+//+kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list
+//+kubebuilder:rbac:groups=core,resources=nodes,verbs=get;list
 
 // Reconcile moves the current state of the cluster closer to the desired state.
-func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, err error) {
+	log := log.FromContext(ctx)
+	start := time.Now()
+	defer func() {
+		observability.WorkspaceReconcileDuration.Observe(time.Since(start).Seconds())
+		if err != nil {
+			observability.WorkspaceReconcileErrors.WithLabelValues(observability.ReconcileErrorType(err)).Inc()
+		}
+	}()
+
+	// A global freeze leaves every workspace exactly as it is (including
+	// deletion handling) until it is lifted.
+	frozen, err := r.isFrozen(ctx)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("check reconcile freeze: %w", err)
+	}
+	if frozen {
+		log.V(1).Info("Reconciliation frozen; skipping", "workspace", req.NamespacedName)
+		return ctrl.Result{RequeueAfter: freezeRequeueInterval}, nil
+	}
+
+	result, err := r.reconcile(ctx, req)
+	r.recordReconcile(ctx, req.NamespacedName, err)
+	return result, err
+}
+
+// reconcile performs a single reconciliation pass for an unfrozen Workspace.
+func (r *WorkspaceReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 
 	var ws workspacev1alpha1.Workspace
@@ -86,7 +287,36 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return r.reconcileDelete(ctx, &ws)
 	}
 
-	if err := workspace.ValidateSpec(&ws); err != nil {
+	// Merge the referenced WorkspaceTemplate under the explicit spec. The merge
+	// is in memory only and is redone on every reconcile, so template edits
+	// reach workspaces without rewriting them.
+	var err error
+	if name := ws.Spec.TemplateRef; name != "" {
+		var tmpl workspacev1alpha1.WorkspaceTemplate
+		switch getErr := r.Get(ctx, types.NamespacedName{Name: name, Namespace: ws.Namespace}, &tmpl); {
+		case errors.IsNotFound(getErr):
+			err = fmt.Errorf("spec.templateRef: WorkspaceTemplate %q not found", name)
+		case getErr != nil:
+			return ctrl.Result{}, fmt.Errorf("get WorkspaceTemplate %q: %w", name, getErr)
+		default:
+			if err = workspace.ValidateTemplate(&tmpl); err == nil {
+				workspace.ApplyTemplate(&ws, &tmpl.Spec)
+			}
+		}
+	}
+	if err == nil {
+		err = workspace.ValidateSpec(&ws)
+	}
+	if err == nil {
+		err = workspace.ValidateMinStorage(&ws, r.MinStorage)
+	}
+	if err == nil {
+		err = workspace.ValidateProvidersSize(&ws, r.MaxProvidersJSONBytes)
+	}
+	if err == nil {
+		err = workspace.ValidateEgressPorts(&ws, r.MaxEgressPorts)
+	}
+	if err != nil {
 		log.Error(err, "Invalid Workspace spec")
 		if updateErr := r.updateStatus(ctx, &ws, workspace.StatusSummary{
 			Phase:           workspacev1alpha1.WorkspacePhaseFailed,
@@ -100,14 +330,27 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	}
 
 	// Ensure the finalizer is registered so we can handle deletion gracefully.
+	// Patch rather than Update so template values merged above are not persisted.
 	if !controllerutil.ContainsFinalizer(&ws, workspaceFinalizer) {
+		patch := client.MergeFrom(ws.DeepCopy())
 		controllerutil.AddFinalizer(&ws, workspaceFinalizer)
-		if err := r.Update(ctx, &ws); err != nil {
+		if err := r.Patch(ctx, &ws, patch); err != nil {
 			return ctrl.Result{}, fmt.Errorf("add finalizer: %w", err)
 		}
 		return ctrl.Result{Requeue: true}, nil
 	}
 
+	// spec.suspended is a manual stop. It is checked before the idle timeout so
+	// the two never race, and only clearing it brings the workspace back.
+	if ws.Spec.Suspended {
+		return r.reconcileSuspended(ctx, &ws)
+	}
+	if ws.Status.Phase == workspacev1alpha1.WorkspacePhaseStopped && stoppedBySuspend(&ws) {
+		if err := r.resumeSuspended(ctx, &ws); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	// Handle stopped workspaces — do not reconcile further.
 	if ws.Status.Phase == workspacev1alpha1.WorkspacePhaseStopped {
 		return ctrl.Result{}, nil
@@ -119,6 +362,11 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	svcName := workspace.ServiceName(userID)
 	nn := req.NamespacedName
 
+	// Collect the owned resources ensured below and publish them in
+	// status.managedResources however this pass ends.
+	var managed managedResources
+	defer func() { r.recordManagedResources(ctx, &ws, managed) }()
+
 	// Ensure RBAC resources (ServiceAccount, Role, RoleBinding).
 	if err := r.ensureRBAC(ctx, &ws); err != nil {
 		log.Error(err, "Failed to ensure RBAC resources")
@@ -135,9 +383,13 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		}
 		return ctrl.Result{}, err
 	}
+	saName := r.serviceAccountName(userID)
+	managed.add("ServiceAccount", saName)
+	managed.add("Role", saName)
+	managed.add("RoleBinding", saName)
 
 	// Ensure NetworkPolicies (deny-all, egress, ingress-from-gateway).
-	if err := r.ensureNetworkPolicies(ctx, &ws); err != nil {
+	if err := r.ensureNetworkPolicies(ctx, &ws, &managed); err != nil {
 		log.Error(err, "Failed to ensure NetworkPolicies")
 		hint, rr := workspace.ErrorDetailsForNetworkPolicy(err)
 		if updateErr := r.updateStatus(ctx, &ws, workspace.StatusSummary{
@@ -150,64 +402,108 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		}); updateErr != nil {
 			return ctrl.Result{}, fmt.Errorf("ensure NetworkPolicies: %w (status patch: %v)", err, updateErr)
 		}
+		if condErr := r.setStatusCondition(ctx, &ws, workspace.NetworkPoliciesCondition(err)); condErr != nil {
+			log.Error(condErr, "Failed to record NetworkPoliciesReady condition")
+		}
+		return ctrl.Result{}, err
+	}
+	if err := r.setStatusCondition(ctx, &ws, workspace.NetworkPoliciesCondition(nil)); err != nil {
 		return ctrl.Result{}, err
 	}
 
 	// Ensure PVC — only create; Kubernetes does not support shrinking PVC storage.
+	// Ephemeral workspaces keep their data in an emptyDir and have no PVC.
 	var pvc corev1.PersistentVolumeClaim
-	if err := r.Get(ctx, client.ObjectKey{Namespace: nn.Namespace, Name: pvcName}, &pvc); err != nil {
-		if !errors.IsNotFound(err) {
-			log.Error(err, "Failed to get PVC")
-			hint, rr := workspace.ErrorDetailsForPVCGet(err)
+	if !ws.Spec.Persistence.Ephemeral {
+		if err := r.Get(ctx, client.ObjectKey{Namespace: nn.Namespace, Name: pvcName}, &pvc); err != nil {
+			if !errors.IsNotFound(err) {
+				log.Error(err, "Failed to get PVC")
+				hint, rr := workspace.ErrorDetailsForPVCGet(err)
+				if updateErr := r.updateStatus(ctx, &ws, workspace.StatusSummary{
+					Phase:           workspacev1alpha1.WorkspacePhaseCreating,
+					PodName:         ws.Status.PodName,
+					ServiceEndpoint: ws.Status.ServiceEndpoint,
+					Message:         fmt.Sprintf("Failed to read PersistentVolumeClaim: %v", err),
+					RemediationHint: hint,
+					ReadyReason:     rr,
+				}); updateErr != nil {
+					return ctrl.Result{}, fmt.Errorf("get PVC: %w (status patch: %v)", err, updateErr)
+				}
+				return ctrl.Result{}, err
+			}
+			pvcObj, buildErr := workspace.BuildPVC(&ws, r.Scheme)
+			if buildErr != nil {
+				log.Error(buildErr, "Failed to build PVC")
+				if updateErr := r.updateStatus(ctx, &ws, workspace.StatusSummary{
+					Phase:           workspacev1alpha1.WorkspacePhaseFailed,
+					MessageOverride: buildErr.Error(),
+					RemediationHint: workspace.RemediationValidation,
+					ReadyReason:     workspace.ReasonValidationFailed,
+				}); updateErr != nil {
+					return ctrl.Result{}, updateErr
+				}
+				return ctrl.Result{}, nil
+			}
+			pvcObj.Labels = r.resourceLabels(&ws, pvcObj.Labels)
+			if err := r.Create(ctx, pvcObj); err != nil {
+				log.Error(err, "Failed to create PVC")
+				hint, rr := workspace.ErrorDetailsForPVCCreate(err)
+				if updateErr := r.updateStatus(ctx, &ws, workspace.StatusSummary{
+					Phase:           workspacev1alpha1.WorkspacePhaseFailed,
+					MessageOverride: err.Error(),
+					RemediationHint: hint,
+					ReadyReason:     rr,
+				}); updateErr != nil {
+					return ctrl.Result{}, updateErr
+				}
+				return ctrl.Result{}, nil
+			}
+			managed.add("PersistentVolumeClaim", pvcName)
 			if updateErr := r.updateStatus(ctx, &ws, workspace.StatusSummary{
 				Phase:           workspacev1alpha1.WorkspacePhaseCreating,
 				PodName:         ws.Status.PodName,
 				ServiceEndpoint: ws.Status.ServiceEndpoint,
-				Message:         fmt.Sprintf("Failed to read PersistentVolumeClaim: %v", err),
-				RemediationHint: hint,
-				ReadyReason:     rr,
+				Message:         "PersistentVolumeClaim created; waiting for volume to bind",
+				ReadyReason:     workspace.ReasonProgressing,
 			}); updateErr != nil {
-				return ctrl.Result{}, fmt.Errorf("get PVC: %w (status patch: %v)", err, updateErr)
+				return ctrl.Result{}, updateErr
 			}
-			return ctrl.Result{}, err
+			log.Info("Created PVC", "pvc", pvcName)
+			if r.Recorder != nil {
+				r.Recorder.Eventf(&ws, pvcObj, corev1.EventTypeNormal, workspace.ReasonPVCCreated, "CreatePVC", "Created PersistentVolumeClaim %s", pvcName)
+			}
+			return ctrl.Result{RequeueAfter: 2 * time.Second}, nil
 		}
-		pvcObj, buildErr := workspace.BuildPVC(&ws, r.Scheme)
-		if buildErr != nil {
-			log.Error(buildErr, "Failed to build PVC")
+		managed.add("PersistentVolumeClaim", pvcName)
+		// A checkpoint restore deletes the PVC so it can be recreated from
+		// spec.persistence.restoreFrom; wait until the old volume is released
+		// instead of starting a pod against a terminating claim.
+		if !pvc.DeletionTimestamp.IsZero() {
 			if updateErr := r.updateStatus(ctx, &ws, workspace.StatusSummary{
-				Phase:           workspacev1alpha1.WorkspacePhaseFailed,
-				MessageOverride: buildErr.Error(),
-				RemediationHint: workspace.RemediationValidation,
-				ReadyReason:     workspace.ReasonValidationFailed,
+				Phase:       workspacev1alpha1.WorkspacePhaseCreating,
+				Message:     "PersistentVolumeClaim is being replaced; waiting for the old volume to be released",
+				ReadyReason: workspace.ReasonProgressing,
 			}); updateErr != nil {
 				return ctrl.Result{}, updateErr
 			}
-			return ctrl.Result{}, nil
+			return ctrl.Result{RequeueAfter: 2 * time.Second}, nil
 		}
-		if err := r.Create(ctx, pvcObj); err != nil {
-			log.Error(err, "Failed to create PVC")
-			hint, rr := workspace.ErrorDetailsForPVCCreate(err)
+		// A checkpoint restore only patches the Workspace; replace a PVC that
+		// was not built for the requested snapshot, stopping its pods first.
+		if workspace.RestorePending(&ws, &pvc) {
+			log.Info("Replacing PVC to restore from checkpoint", "pvc", pvcName, "checkpoint", ws.Spec.Persistence.RestoreFrom)
+			if err := r.replaceVolumeForRestore(ctx, &ws, &pvc); err != nil {
+				return ctrl.Result{}, err
+			}
 			if updateErr := r.updateStatus(ctx, &ws, workspace.StatusSummary{
-				Phase:           workspacev1alpha1.WorkspacePhaseFailed,
-				MessageOverride: err.Error(),
-				RemediationHint: hint,
-				ReadyReason:     rr,
+				Phase:       workspacev1alpha1.WorkspacePhaseCreating,
+				Message:     fmt.Sprintf("Restoring from checkpoint %s", ws.Spec.Persistence.RestoreFrom),
+				ReadyReason: workspace.ReasonProgressing,
 			}); updateErr != nil {
 				return ctrl.Result{}, updateErr
 			}
-			return ctrl.Result{}, nil
-		}
-		if updateErr := r.updateStatus(ctx, &ws, workspace.StatusSummary{
-			Phase:           workspacev1alpha1.WorkspacePhaseCreating,
-			PodName:         ws.Status.PodName,
-			ServiceEndpoint: ws.Status.ServiceEndpoint,
-			Message:         "PersistentVolumeClaim created; waiting for volume to bind",
-			ReadyReason:     workspace.ReasonProgressing,
-		}); updateErr != nil {
-			return ctrl.Result{}, updateErr
+			return ctrl.Result{RequeueAfter: 2 * time.Second}, nil
 		}
-		log.Info("Created PVC", "pvc", pvcName)
-		return ctrl.Result{RequeueAfter: 2 * time.Second}, nil
 	}
 
 	// Only block on a permanently lost PVC — a Pending PVC with WaitForFirstConsumer
@@ -226,11 +522,119 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, nil
 	}
 
-	// Ensure Pod — create if missing, delete and requeue if image changed.
+	// An Immediate-binding class should bind without a consumer, so a PVC that
+	// stays Pending past the grace window points at a stuck provisioner. Report
+	// it and keep polling instead of creating a pod that cannot schedule.
+	if !ws.Spec.Persistence.Ephemeral && pvc.Status.Phase == corev1.ClaimPending &&
+		time.Since(pvc.CreationTimestamp.Time) > pvcPendingGrace && r.immediateBinding(ctx, &pvc) {
+		if updateErr := r.updateStatus(ctx, &ws, workspace.StatusSummary{
+			Phase:           workspacev1alpha1.WorkspacePhaseCreating,
+			PodName:         ws.Status.PodName,
+			ServiceEndpoint: ws.Status.ServiceEndpoint,
+			MessageOverride: fmt.Sprintf("PersistentVolumeClaim %s still Pending after %s; storage class %q (Immediate binding) has not provisioned a volume", pvc.Name, time.Since(pvc.CreationTimestamp.Time).Round(time.Second), *pvc.Spec.StorageClassName),
+			RemediationHint: workspace.RemediationPVCPending,
+			ReadyReason:     workspace.ReasonPVCPending,
+		}); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{RequeueAfter: pvcPendingRequeueInterval}, nil
+	}
+
+	// Record the bound PVC's provisioned capacity so dashboards can warn before
+	// the volume fills up. Owns(PVC) re-triggers reconcile after a volume expansion.
+	if capacity := workspace.PVCCapacity(&pvc); capacity != "" && capacity != ws.Status.StorageCapacity {
+		base := ws.DeepCopy()
+		ws.Status.StorageCapacity = capacity
+		if err := r.patchStatus(ctx, &ws, client.MergeFrom(base)); err != nil {
+			return ctrl.Result{}, fmt.Errorf("record PVC capacity: %w", err)
+		}
+	}
+
+	// Ensure the AI settings ConfigMap before the pod so its volume mount resolves.
+	// Updates propagate to running pods through the kubelet's ConfigMap sync.
+	if err := r.ensureAISettingsConfigMap(ctx, &ws); err != nil {
+		log.Error(err, "Failed to ensure AI settings ConfigMap")
+		if updateErr := r.updateStatus(ctx, &ws, workspace.StatusSummary{
+			Phase:           workspacev1alpha1.WorkspacePhaseCreating,
+			PodName:         ws.Status.PodName,
+			ServiceEndpoint: ws.Status.ServiceEndpoint,
+			Message:         fmt.Sprintf("AI settings ConfigMap reconcile failed: %v", err),
+			ReadyReason:     workspace.ReasonProgressing,
+		}); updateErr != nil {
+			return ctrl.Result{}, fmt.Errorf("ensure AI settings ConfigMap: %w (status patch: %v)", err, updateErr)
+		}
+		return ctrl.Result{}, err
+	}
+	managed.add("ConfigMap", workspace.AISettingsConfigMapName(userID))
+
+	if r.ResourceQuota {
+		if err := r.ensureResourceQuota(ctx, &ws); err != nil {
+			log.Error(err, "Failed to ensure ResourceQuota")
+			if updateErr := r.updateStatus(ctx, &ws, workspace.StatusSummary{
+				Phase:           workspacev1alpha1.WorkspacePhaseCreating,
+				PodName:         ws.Status.PodName,
+				ServiceEndpoint: ws.Status.ServiceEndpoint,
+				Message:         fmt.Sprintf("ResourceQuota reconcile failed: %v", err),
+				ReadyReason:     workspace.ReasonProgressing,
+			}); updateErr != nil {
+				return ctrl.Result{}, fmt.Errorf("ensure ResourceQuota: %w (status patch: %v)", err, updateErr)
+			}
+			return ctrl.Result{}, err
+		}
+		managed.add("ResourceQuota", workspace.ResourceQuotaName(userID))
+	}
+
+	if r.Ingress.BaseDomain != "" {
+		if err := r.ensureIngress(ctx, &ws); err != nil {
+			log.Error(err, "Failed to ensure Ingress")
+			if updateErr := r.updateStatus(ctx, &ws, workspace.StatusSummary{
+				Phase:           workspacev1alpha1.WorkspacePhaseCreating,
+				PodName:         ws.Status.PodName,
+				ServiceEndpoint: ws.Status.ServiceEndpoint,
+				Message:         fmt.Sprintf("Ingress reconcile failed: %v", err),
+				ReadyReason:     workspace.ReasonProgressing,
+			}); updateErr != nil {
+				return ctrl.Result{}, fmt.Errorf("ensure Ingress: %w (status patch: %v)", err, updateErr)
+			}
+			return ctrl.Result{}, err
+		}
+		managed.add("Ingress", workspace.IngressName(userID))
+	} else if err := r.deleteIngress(ctx, &ws); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	image := r.WorkspaceImage
 	if image == "" {
 		image = "workspace:latest"
 	}
+	caHash, err := r.caBundleHash(ctx, &ws)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if r.ValidateCABundle {
+		if err := r.syncCABundleCondition(ctx, &ws); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Multi-replica preview workspaces, ScaleToZero workspaces and, with
+	// UseDeployment, all workspaces run as a Deployment instead of a single Pod.
+	if r.UseDeployment || workspace.UsesDeployment(&ws) {
+		return r.reconcileDeployment(ctx, &ws, pvcName, image, caHash, &managed)
+	}
+
+	// Remove the Deployment left behind when a workspace scales back to one replica.
+	var staleDeploy appsv1.Deployment
+	if err := r.Get(ctx, client.ObjectKey{Namespace: nn.Namespace, Name: workspace.DeploymentName(userID)}, &staleDeploy); err == nil {
+		log.Info("Deleting Deployment after scale-down to a single Pod", "deployment", staleDeploy.Name)
+		if err := r.Delete(ctx, &staleDeploy); err != nil && !errors.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("delete stale deployment: %w", err)
+		}
+	} else if !errors.IsNotFound(err) {
+		return ctrl.Result{}, fmt.Errorf("get Deployment: %w", err)
+	}
+
+	// Ensure Pod — create if missing, delete and requeue if image changed.
 
 	var pod corev1.Pod
 	if err := r.Get(ctx, client.ObjectKey{Namespace: nn.Namespace, Name: podName}, &pod); err != nil {
@@ -249,11 +653,19 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			}
 			return ctrl.Result{}, err
 		}
+		if result, blocked, err := r.waitForDependencies(ctx, &ws); blocked || err != nil {
+			return result, err
+		}
 		podObj, buildErr := workspace.BuildPod(&ws, pvcName, image, r.Scheme, workspace.BuildOpts{
-			DefaultCABundle: r.DefaultCABundle,
-			PipIndexURL:     r.PipIndexURL,
-			PipTrustedHost:  r.PipTrustedHost,
-			NpmRegistry:     r.NpmRegistry,
+			DefaultCABundle:       r.DefaultCABundle,
+			PipIndexURL:           r.PipIndexURL,
+			PipTrustedHost:        r.PipTrustedHost,
+			NpmRegistry:           r.NpmRegistry,
+			RuntimeClassName:      r.RuntimeClassName,
+			CABundleHash:          caHash,
+			DownwardAPIPath:       r.DownwardAPIPath,
+			ServiceAccountName:    r.serviceAccountName(ws.Spec.User.ID),
+			DefaultPodAnnotations: r.DefaultPodAnnotations,
 		})
 		if buildErr != nil {
 			log.Error(buildErr, "Failed to build Pod")
@@ -267,6 +679,14 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			}
 			return ctrl.Result{}, nil
 		}
+		// A pod requesting an extended resource (e.g. nvidia.com/gpu) that no
+		// node offers yet would sit Pending with only a scheduler event to
+		// explain it. The pod is still created: cluster-autoscaler only scales
+		// a GPU node group up from zero for a Pending pod that needs it.
+		if msg := r.unschedulableExtendedResources(ctx, podObj); msg != "" && r.Recorder != nil {
+			r.Recorder.Eventf(&ws, nil, corev1.EventTypeWarning, workspace.ReasonInsufficientResources, "CreatePod", "%s. %s", msg, workspace.RemediationInsufficientResources)
+		}
+		podObj.Labels = r.resourceLabels(&ws, podObj.Labels)
 		if err := r.Create(ctx, podObj); err != nil {
 			log.Error(err, "Failed to create Pod")
 			hint, rr := workspace.ErrorDetailsForPodCreate(err)
@@ -280,16 +700,93 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			}
 			return ctrl.Result{}, nil
 		}
+		managed.add("Pod", podName)
 		log.Info("Created Pod", "pod", podName)
+		if r.Recorder != nil {
+			r.Recorder.Eventf(&ws, podObj, corev1.EventTypeNormal, workspace.ReasonPodCreated, "CreatePod", "Created Pod %s", podName)
+		}
+		return ctrl.Result{RequeueAfter: 2 * time.Second}, nil
+	}
+	managed.add("Pod", podName)
+	if err := r.setStatusCondition(ctx, &ws, workspace.PodScheduledCondition(&pod)); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// A pod stuck Terminating (lost node, hung volume detach) blocks recreation.
+	// Force-delete it once it has been terminating longer than the threshold.
+	if !pod.DeletionTimestamp.IsZero() && r.StuckTerminatingTimeout > 0 &&
+		time.Since(pod.DeletionTimestamp.Time) > r.StuckTerminatingTimeout {
+		log.Info("Force-deleting pod stuck Terminating",
+			"pod", podName,
+			"terminatingSince", pod.DeletionTimestamp.Time,
+			"threshold", r.StuckTerminatingTimeout)
+		if err := r.Delete(ctx, &pod, client.GracePeriodSeconds(0)); err != nil && !errors.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("force-delete stuck pod: %w", err)
+		}
+		return ctrl.Result{RequeueAfter: 2 * time.Second}, nil
+	}
+
+	// The trust store is built when the container starts, so a changed CA bundle
+	// needs a fresh pod. Pods created before the hash annotation existed are left alone.
+	if current := pod.Annotations[workspace.CABundleHashAnnotation]; caHash != "" && current != "" &&
+		current != caHash && pod.DeletionTimestamp.IsZero() {
+		log.Info("CA bundle changed, deleting pod for recreation",
+			"pod", podName,
+			"current", current,
+			"desired", caHash)
+		if err := r.Delete(ctx, &pod); err != nil && !errors.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("delete pod with outdated CA bundle: %w", err)
+		}
+		return ctrl.Result{RequeueAfter: 2 * time.Second}, nil
+	}
+
+	// The ttyd port is baked into the container port and probes, so a changed
+	// spec.port needs a fresh pod to match the Service and NetworkPolicy.
+	if current, desired := workspace.PodTTYDPort(&pod), workspace.TTYDPort(&ws); current != 0 &&
+		current != desired && pod.DeletionTimestamp.IsZero() {
+		log.Info("ttyd port changed, deleting pod for recreation",
+			"pod", podName,
+			"current", current,
+			"desired", desired)
+		if err := r.Delete(ctx, &pod); err != nil && !errors.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("delete pod with outdated ttyd port: %w", err)
+		}
 		return ctrl.Result{RequeueAfter: 2 * time.Second}, nil
 	}
 
 	// If the pod's container image no longer matches the desired image, delete the
 	// pod so the next reconcile recreates it.  Only act when the pod is not already
-	// being deleted and has at least one container spec.
-	if len(pod.Spec.Containers) > 0 &&
-		pod.Spec.Containers[0].Image != image &&
-		pod.DeletionTimestamp.IsZero() {
+	// being deleted and has at least one container spec. With spec.autoUpdate=false
+	// the pod is kept and status.updateAvailable records the pending update.
+	imageOutdated := len(pod.Spec.Containers) > 0 && pod.Spec.Containers[0].Image != image
+	autoUpdate := ws.Spec.AutoUpdate == nil || *ws.Spec.AutoUpdate
+	if updateAvailable := imageOutdated && !autoUpdate; updateAvailable != ws.Status.UpdateAvailable {
+		base := ws.DeepCopy()
+		ws.Status.UpdateAvailable = updateAvailable
+		if err := r.patchStatus(ctx, &ws, client.MergeFrom(base)); err != nil {
+			return ctrl.Result{}, fmt.Errorf("record update available: %w", err)
+		}
+	}
+	if !imageOutdated && isPodReady(&pod) && !ws.Status.ImageRolloutStartedAt.IsZero() {
+		if err := r.setImageRolloutStartedAt(ctx, &ws, metav1.Time{}); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if imageOutdated && autoUpdate && pod.DeletionTimestamp.IsZero() {
+		ok, err := r.imageRolloutAllowed(ctx, &ws)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !ok {
+			log.V(1).Info("Image rollout limit reached; deferring pod recreation",
+				"pod", podName, "limit", r.MaxConcurrentImageRollouts)
+			return ctrl.Result{RequeueAfter: imageRolloutRequeueInterval}, nil
+		}
+		if ws.Status.ImageRolloutStartedAt.IsZero() {
+			if err := r.setImageRolloutStartedAt(ctx, &ws, metav1.Now()); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
 		log.Info("Pod image changed, deleting for recreation",
 			"pod", podName,
 			"current", pod.Spec.Containers[0].Image,
@@ -300,20 +797,13 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{RequeueAfter: 2 * time.Second}, nil
 	}
 
-	// Ensure headless Service via CreateOrUpdate so label/port changes are applied.
-	svcLabels := workspace.Labels(userID)
-	svc := &corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{Name: svcName, Namespace: nn.Namespace},
+	// The NetworkPolicies were ensured earlier in this pass; open the readiness gate.
+	if err := r.markNetworkPolicyReady(ctx, &pod); err != nil {
+		return ctrl.Result{}, err
 	}
-	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, svc, func() error {
-		svc.Labels = svcLabels
-		svc.Spec.ClusterIP = corev1.ClusterIPNone
-		svc.Spec.Selector = svcLabels
-		svc.Spec.Ports = []corev1.ServicePort{
-			{Name: "ttyd", Port: 7681, Protocol: corev1.ProtocolTCP},
-		}
-		return controllerutil.SetControllerReference(&ws, svc, r.Scheme)
-	}); err != nil {
+
+	// Ensure headless Service via CreateOrUpdate so label/port changes are applied.
+	if err := r.ensureService(ctx, &ws); err != nil {
 		log.Error(err, "Failed to ensure Service")
 		hint, rr := workspace.ErrorDetailsForService(err)
 		if updateErr := r.updateStatus(ctx, &ws, workspace.StatusSummary{
@@ -326,36 +816,31 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		}
 		return ctrl.Result{}, nil
 	}
+	managed.add("Service", svcName)
 
 	serviceEndpoint := fmt.Sprintf("%s.%s.svc.cluster.local", svcName, nn.Namespace)
 
-	idle := effectiveIdleTimeout(&ws, r.IdleTimeout)
+	idle := r.idleTimeoutFor(&ws)
 
 	// Idle-timeout check: stop the workspace if it has been idle longer than the effective timeout.
 	if pod.Status.Phase == corev1.PodRunning && isPodReady(&pod) && idle > 0 {
-		// Gateway normally stamps lastAccessed; seed when missing so CRs created without
-		// the gateway still participate in idle shutdown.
-		if ws.Status.LastAccessed.IsZero() {
-			base := ws.DeepCopy()
-			ws.Status.LastAccessed = metav1.Now()
-			if err := r.Status().Patch(ctx, &ws, client.MergeFrom(base)); err != nil {
-				return ctrl.Result{}, fmt.Errorf("seed lastAccessed: %w", err)
-			}
+		stop, result, err := r.idleStopDue(ctx, &ws, idle, podName, serviceEndpoint)
+		if err != nil || !result.IsZero() {
+			return result, err
 		}
-		if time.Since(ws.Status.LastAccessed.Time) > idle {
+		if stop {
+			if !r.idleStopAllowed() {
+				log.V(1).Info("Idle stop rate limit reached; deferring stop",
+					"workspace", ws.Name, "perSecond", r.IdleStopsPerSecond)
+				return ctrl.Result{RequeueAfter: idleStopRequeueInterval}, nil
+			}
 			log.Info("Workspace idle timeout reached, stopping pod",
 				"workspace", ws.Name, "idleTimeout", idle)
 			if err := r.Delete(ctx, &pod); err != nil && !errors.IsNotFound(err) {
 				return ctrl.Result{}, fmt.Errorf("delete idle pod: %w", err)
 			}
-			if updateErr := r.updateStatus(ctx, &ws, workspace.StatusSummary{
-				Phase:       workspacev1alpha1.WorkspacePhaseStopped,
-				Message:     "Workspace stopped due to inactivity",
-				ReadyReason: workspace.ReasonStopped,
-			}); updateErr != nil {
-				return ctrl.Result{}, updateErr
-			}
-			return ctrl.Result{}, nil
+			managed.remove("Pod", podName)
+			return ctrl.Result{}, r.markIdleStopped(ctx, &ws)
 		}
 	}
 
@@ -365,7 +850,7 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			Phase:           workspacev1alpha1.WorkspacePhaseRunning,
 			PodName:         podName,
 			ServiceEndpoint: serviceEndpoint,
-			ReadyReason:     workspace.ReasonRunning,
+			ReadyReason:     workspace.ReasonPodRunning,
 		}); updateErr != nil {
 			return ctrl.Result{}, updateErr
 		}
@@ -376,6 +861,21 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, nil
 	}
 
+	// A container killed for exceeding its memory limit either fails the pod or
+	// restarts into CrashLoopBackOff; both get memory-specific guidance.
+	if msg := workspace.OOMKilledMessage(&pod); msg != "" && (pod.Status.Phase == corev1.PodFailed || isCrashLooping(&pod)) {
+		if updateErr := r.updateStatus(ctx, &ws, workspace.StatusSummary{
+			Phase:           workspacev1alpha1.WorkspacePhaseFailed,
+			PodName:         podName,
+			MessageOverride: msg,
+			RemediationHint: workspace.RemediationOOMKilled,
+			ReadyReason:     workspace.ReasonOOMKilled,
+		}); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, nil
+	}
+
 	// Check for pod failure conditions.
 	if pod.Status.Phase == corev1.PodFailed {
 		msg := fmt.Sprintf("Pod failed: %s", pod.Status.Reason)
@@ -429,6 +929,25 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		}
 	}
 
+	// An unscheduled pod waiting for an extended resource no node offers says
+	// so in status. It stays Creating: cluster-autoscaler may still add a node,
+	// and scheduling the pod triggers a reconcile, so a slow requeue suffices.
+	if pod.Spec.NodeName == "" && pod.DeletionTimestamp.IsZero() {
+		if msg := r.unschedulableExtendedResources(ctx, &pod); msg != "" {
+			if updateErr := r.updateStatus(ctx, &ws, workspace.StatusSummary{
+				Phase:           workspacev1alpha1.WorkspacePhaseCreating,
+				PodName:         podName,
+				ServiceEndpoint: serviceEndpoint,
+				MessageOverride: msg,
+				RemediationHint: workspace.RemediationInsufficientResources,
+				ReadyReason:     workspace.ReasonInsufficientResources,
+			}); updateErr != nil {
+				return ctrl.Result{}, updateErr
+			}
+			return ctrl.Result{RequeueAfter: 30 * time.Second}, nil
+		}
+	}
+
 	// Pod exists but not running/ready — still creating.
 	msg := "Pod starting"
 	if pod.Status.Phase != "" {
@@ -446,41 +965,761 @@ func (r *WorkspaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 }
 
-// reconcileDelete removes the finalizer so that Kubernetes garbage collection
-// can cascade-delete all owned resources (Pod, PVC, Service, RBAC, NetworkPolicies).
-func (r *WorkspaceReconciler) reconcileDelete(ctx context.Context, ws *workspacev1alpha1.Workspace) (ctrl.Result, error) {
-	log := log.FromContext(ctx)
-	log.Info("Handling workspace deletion", "workspace", ws.Name)
-	controllerutil.RemoveFinalizer(ws, workspaceFinalizer)
-	if err := r.Update(ctx, ws); err != nil {
-		return ctrl.Result{}, fmt.Errorf("remove finalizer: %w", err)
+// isCrashLooping reports whether any container of pod is waiting in CrashLoopBackOff.
+func isCrashLooping(pod *corev1.Pod) bool {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.State.Waiting != nil && cs.State.Waiting.Reason == "CrashLoopBackOff" {
+			return true
+		}
 	}
-	return ctrl.Result{}, nil
+	return false
 }
 
-// ensureRBAC creates or updates the per-user ServiceAccount, Role, and RoleBinding.
-func (r *WorkspaceReconciler) ensureRBAC(ctx context.Context, ws *workspacev1alpha1.Workspace) error {
+// idleStopDue seeds status.lastAccessed and applies the idle grace period for
+// a running workspace. It reports stop when the workspace should be stopped
+// now; a non-zero result means a stop is pending and the caller should return it.
+func (r *WorkspaceReconciler) idleStopDue(ctx context.Context, ws *workspacev1alpha1.Workspace, idle time.Duration, podName, serviceEndpoint string) (bool, ctrl.Result, error) {
 	log := log.FromContext(ctx)
-	userID := ws.Spec.User.ID
-	saName := workspace.ServiceAccountName(userID)
-
-	rbacLabels := map[string]string{
-		"app":        "workspace",
-		"user":       userID,
-		"managed-by": "devplane",
+	// Gateway normally stamps lastAccessed; seed when missing so CRs created without
+	// the gateway still participate in idle shutdown. With a never-accessed tier the
+	// seed is the creation time, which keeps the workspace counted as never accessed.
+	if ws.Status.LastAccessed.IsZero() {
+		base := ws.DeepCopy()
+		ws.Status.LastAccessed = metav1.Now()
+		if r.NeverAccessedIdleTimeout > 0 {
+			ws.Status.LastAccessed = ws.CreationTimestamp
+		}
+		if err := r.patchStatus(ctx, ws, client.MergeFrom(base)); err != nil {
+			return false, ctrl.Result{}, fmt.Errorf("seed lastAccessed: %w", err)
+		}
+	}
+	// Activity during the grace period cancels the pending stop.
+	if !ws.Status.IdleStopAt.IsZero() && time.Since(ws.Status.LastAccessed.Time) <= idle {
+		if err := r.setIdleStopAt(ctx, ws, metav1.Time{}); err != nil {
+			return false, ctrl.Result{}, err
+		}
+	}
+	if time.Since(ws.Status.LastAccessed.Time) <= idle {
+		return false, ctrl.Result{}, nil
+	}
+	if r.IdleGracePeriod > 0 {
+		if ws.Status.IdleStopAt.IsZero() {
+			stopAt := metav1.NewTime(time.Now().Add(r.IdleGracePeriod).Truncate(time.Second))
+			log.Info("Workspace idle timeout reached, stopping after grace period",
+				"workspace", ws.Name, "idleTimeout", idle, "stopAt", stopAt.Time)
+			if err := r.setIdleStopAt(ctx, ws, stopAt); err != nil {
+				return false, ctrl.Result{}, err
+			}
+		}
+		if remaining := time.Until(ws.Status.IdleStopAt.Time); remaining > 0 {
+			if updateErr := r.updateStatus(ctx, ws, workspace.StatusSummary{
+				Phase:           workspacev1alpha1.WorkspacePhaseRunning,
+				PodName:         podName,
+				ServiceEndpoint: serviceEndpoint,
+				Message: fmt.Sprintf("Workspace idle; stopping at %s unless there is activity",
+					ws.Status.IdleStopAt.UTC().Format(time.RFC3339)),
+				ReadyReason: workspace.ReasonIdleStopPending,
+			}); updateErr != nil {
+				return false, ctrl.Result{}, updateErr
+			}
+			return false, ctrl.Result{RequeueAfter: remaining}, nil
+		}
 	}
+	return true, ctrl.Result{}, nil
+}
 
-	// ServiceAccount
-	sa := &corev1.ServiceAccount{
-		ObjectMeta: metav1.ObjectMeta{Name: saName, Namespace: ws.Namespace},
+// markIdleStopped records an idle stop: it counts it, clears any pending
+// status.idleStopAt and sets phase Stopped.
+func (r *WorkspaceReconciler) markIdleStopped(ctx context.Context, ws *workspacev1alpha1.Workspace) error {
+	observability.WorkspaceIdleStops.WithLabelValues(ws.Namespace).Inc()
+	if r.Recorder != nil {
+		r.Recorder.Eventf(ws, nil, corev1.EventTypeNormal, workspace.ReasonIdleStopped, "IdleStop", "Workspace stopped after %s without activity", r.idleTimeoutFor(ws))
 	}
-	if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, sa, func() error {
-		sa.Labels = rbacLabels
-		return controllerutil.SetControllerReference(ws, sa, r.Scheme)
-	}); err != nil {
-		return fmt.Errorf("ensure ServiceAccount: %w", err)
-	} else if result != controllerutil.OperationResultNone {
-		log.Info("ServiceAccount reconciled", "name", saName, "result", result)
+	if !ws.Status.IdleStopAt.IsZero() {
+		if err := r.setIdleStopAt(ctx, ws, metav1.Time{}); err != nil {
+			return err
+		}
+	}
+	return r.updateStatus(ctx, ws, workspace.StatusSummary{
+		Phase:       workspacev1alpha1.WorkspacePhaseStopped,
+		Message:     "Workspace stopped due to inactivity",
+		ReadyReason: workspace.ReasonStopped,
+	})
+}
+
+// reconcileSuspended stops a workspace with spec.suspended set: it deletes the
+// single-mode pod, scales a Deployment to zero and records phase Stopped.
+func (r *WorkspaceReconciler) reconcileSuspended(ctx context.Context, ws *workspacev1alpha1.Workspace) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	userID := ws.Spec.User.ID
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: workspace.PodName(userID), Namespace: ws.Namespace}}
+	if err := r.Delete(ctx, pod); err != nil && !errors.IsNotFound(err) {
+		return ctrl.Result{}, fmt.Errorf("delete suspended pod: %w", err)
+	}
+	var deploy appsv1.Deployment
+	switch err := r.Get(ctx, client.ObjectKey{Namespace: ws.Namespace, Name: workspace.DeploymentName(userID)}, &deploy); {
+	case errors.IsNotFound(err):
+	case err != nil:
+		return ctrl.Result{}, fmt.Errorf("get suspended Deployment: %w", err)
+	case deploy.Spec.Replicas == nil || *deploy.Spec.Replicas != 0:
+		patch := client.MergeFrom(deploy.DeepCopy())
+		zero := int32(0)
+		deploy.Spec.Replicas = &zero
+		if err := r.Patch(ctx, &deploy, patch); err != nil {
+			return ctrl.Result{}, fmt.Errorf("scale suspended Deployment to zero: %w", err)
+		}
+	}
+	if !ws.Status.IdleStopAt.IsZero() {
+		if err := r.setIdleStopAt(ctx, ws, metav1.Time{}); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	if ws.Status.Phase == workspacev1alpha1.WorkspacePhaseStopped && stoppedBySuspend(ws) {
+		return ctrl.Result{}, nil
+	}
+	log.Info("Workspace suspended", "workspace", ws.Name)
+	return ctrl.Result{}, r.updateStatus(ctx, ws, workspace.StatusSummary{
+		Phase:       workspacev1alpha1.WorkspacePhaseStopped,
+		Message:     "Suspended by user",
+		ReadyReason: workspace.ReasonSuspended,
+	})
+}
+
+// resumeSuspended moves a workspace whose spec.suspended was cleared back to
+// Pending. status.lastAccessed is reset so the idle timeout counts from the
+// resume rather than stopping the workspace again as soon as it is Running.
+func (r *WorkspaceReconciler) resumeSuspended(ctx context.Context, ws *workspacev1alpha1.Workspace) error {
+	base := ws.DeepCopy()
+	ws.Status.LastAccessed = metav1.Now()
+	if err := r.patchStatus(ctx, ws, client.MergeFrom(base)); err != nil {
+		return fmt.Errorf("patch lastAccessed on resume: %w", err)
+	}
+	log.FromContext(ctx).Info("Resuming suspended workspace", "workspace", ws.Name)
+	return r.updateStatus(ctx, ws, workspace.StatusSummary{
+		Phase:       workspacev1alpha1.WorkspacePhasePending,
+		Message:     "Resuming after suspension",
+		ReadyReason: workspace.ReasonProgressing,
+	})
+}
+
+// stoppedBySuspend reports whether the Ready condition records a stop caused
+// by spec.suspended rather than by the idle timeout.
+func stoppedBySuspend(ws *workspacev1alpha1.Workspace) bool {
+	c := meta.FindStatusCondition(ws.Status.Conditions, workspace.ConditionTypeReady)
+	return c != nil && c.Reason == workspace.ReasonSuspended
+}
+
+// reconcileDeployment drives a Deployment-backed workspace: it removes any
+// single-mode Pod left from before the switch, keeps the Deployment and Service
+// in sync with the spec, and reports Running once at least one replica is ready.
+// Replacing the bare Pod and rolling out a new image both restart the
+// workspace, so both take a MaxConcurrentImageRollouts slot; with
+// spec.autoUpdate=false the Deployment keeps its current image and
+// status.updateAvailable is set instead.
+// Idle shutdown applies only with spec.lifecycle.stopMode ScaleToZero or
+// UseDeployment, where it scales the Deployment to zero; reconciling after a
+// restart scales it back up.
+func (r *WorkspaceReconciler) reconcileDeployment(ctx context.Context, ws *workspacev1alpha1.Workspace, pvcName, image, caHash string, managed *managedResources) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	userID := ws.Spec.User.ID
+
+	var pod corev1.Pod
+	if err := r.Get(ctx, client.ObjectKey{Namespace: ws.Namespace, Name: workspace.PodName(userID)}, &pod); err == nil {
+		if pod.DeletionTimestamp.IsZero() {
+			ok, err := r.imageRolloutAllowed(ctx, ws)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if !ok {
+				log.V(1).Info("Image rollout limit reached; deferring switch to Deployment",
+					"pod", pod.Name, "limit", r.MaxConcurrentImageRollouts)
+				return ctrl.Result{RequeueAfter: imageRolloutRequeueInterval}, nil
+			}
+			if ws.Status.ImageRolloutStartedAt.IsZero() {
+				if err := r.setImageRolloutStartedAt(ctx, ws, metav1.Now()); err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+			log.Info("Deleting single-replica Pod in favor of Deployment", "pod", pod.Name)
+			if err := r.Delete(ctx, &pod); err != nil && !errors.IsNotFound(err) {
+				return ctrl.Result{}, fmt.Errorf("delete single-replica pod: %w", err)
+			}
+		}
+	} else if !errors.IsNotFound(err) {
+		return ctrl.Result{}, fmt.Errorf("get Pod: %w", err)
+	}
+
+	desired, err := workspace.BuildDeployment(ws, pvcName, image, r.Scheme, workspace.BuildOpts{
+		DefaultCABundle:       r.DefaultCABundle,
+		PipIndexURL:           r.PipIndexURL,
+		PipTrustedHost:        r.PipTrustedHost,
+		NpmRegistry:           r.NpmRegistry,
+		RuntimeClassName:      r.RuntimeClassName,
+		CABundleHash:          caHash,
+		DownwardAPIPath:       r.DownwardAPIPath,
+		ServiceAccountName:    r.serviceAccountName(ws.Spec.User.ID),
+		DefaultPodAnnotations: r.DefaultPodAnnotations,
+	})
+	if err != nil {
+		log.Error(err, "Failed to build Deployment")
+		if updateErr := r.updateStatus(ctx, ws, workspace.StatusSummary{
+			Phase:           workspacev1alpha1.WorkspacePhaseFailed,
+			MessageOverride: err.Error(),
+			RemediationHint: workspace.RemediationValidation,
+			ReadyReason:     workspace.ReasonValidationFailed,
+		}); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, nil
+	}
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: desired.Name, Namespace: ws.Namespace},
+	}
+	autoUpdate := ws.Spec.AutoUpdate == nil || *ws.Spec.AutoUpdate
+	updateAvailable, rolloutDeferred := false, false
+	if err := r.Get(ctx, client.ObjectKeyFromObject(deploy), deploy); errors.IsNotFound(err) {
+		if result, blocked, err := r.waitForDependencies(ctx, ws); blocked || err != nil {
+			return result, err
+		}
+	} else if err != nil {
+		return ctrl.Result{}, fmt.Errorf("get Deployment: %w", err)
+	} else if current := templateImage(&deploy.Spec.Template); current != "" && current != image {
+		// Like the bare Pod path: hold the running image while autoUpdate is
+		// off or the rollout cap is reached.
+		updateAvailable = !autoUpdate
+		if autoUpdate {
+			ok, err := r.imageRolloutAllowed(ctx, ws)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if ok && ws.Status.ImageRolloutStartedAt.IsZero() {
+				if err := r.setImageRolloutStartedAt(ctx, ws, metav1.Now()); err != nil {
+					return ctrl.Result{}, err
+				}
+			}
+			rolloutDeferred = !ok
+			if rolloutDeferred {
+				log.V(1).Info("Image rollout limit reached; deferring Deployment image update",
+					"deployment", deploy.Name, "limit", r.MaxConcurrentImageRollouts)
+			}
+		}
+		if !autoUpdate || rolloutDeferred {
+			desired.Spec.Template.Spec.Containers[0].Image = current
+		}
+	}
+	if updateAvailable != ws.Status.UpdateAvailable {
+		base := ws.DeepCopy()
+		ws.Status.UpdateAvailable = updateAvailable
+		if err := r.patchStatus(ctx, ws, client.MergeFrom(base)); err != nil {
+			return ctrl.Result{}, fmt.Errorf("record update available: %w", err)
+		}
+	}
+	if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, deploy, func() error {
+		deploy.Labels = r.resourceLabels(ws, desired.Labels)
+		deploy.Spec.Replicas = desired.Spec.Replicas
+		// The selector is immutable; only set it on create.
+		if deploy.Spec.Selector == nil {
+			deploy.Spec.Selector = desired.Spec.Selector
+		}
+		deploy.Spec.Strategy = desired.Spec.Strategy
+		deploy.Spec.Template = desired.Spec.Template
+		deploy.Spec.Template.Labels = r.resourceLabels(ws, desired.Spec.Template.Labels)
+		return controllerutil.SetControllerReference(ws, deploy, r.Scheme)
+	}); err != nil {
+		log.Error(err, "Failed to ensure Deployment")
+		if updateErr := r.updateStatus(ctx, ws, workspace.StatusSummary{
+			Phase:           workspacev1alpha1.WorkspacePhaseCreating,
+			ServiceEndpoint: ws.Status.ServiceEndpoint,
+			Message:         fmt.Sprintf("Deployment reconcile failed: %v", err),
+			ReadyReason:     workspace.ReasonProgressing,
+		}); updateErr != nil {
+			return ctrl.Result{}, fmt.Errorf("ensure Deployment: %w (status patch: %v)", err, updateErr)
+		}
+		return ctrl.Result{}, err
+	}
+	managed.add("Deployment", deploy.Name)
+
+	if err := r.ensureService(ctx, ws); err != nil {
+		log.Error(err, "Failed to ensure Service")
+		hint, rr := workspace.ErrorDetailsForService(err)
+		if updateErr := r.updateStatus(ctx, ws, workspace.StatusSummary{
+			Phase:           workspacev1alpha1.WorkspacePhaseFailed,
+			MessageOverride: err.Error(),
+			RemediationHint: hint,
+			ReadyReason:     rr,
+		}); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, nil
+	}
+	managed.add("Service", workspace.ServiceName(userID))
+	serviceEndpoint := fmt.Sprintf("%s.%s.svc.cluster.local", workspace.ServiceName(userID), ws.Namespace)
+
+	if err := r.markDeploymentPodsNetworkPolicyReady(ctx, ws); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	want := *desired.Spec.Replicas
+	if !ws.Status.ImageRolloutStartedAt.IsZero() && !rolloutDeferred && deploymentRolledOut(deploy, want) {
+		if err := r.setImageRolloutStartedAt(ctx, ws, metav1.Time{}); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	idle := r.idleTimeoutFor(ws)
+	idleScalesToZero := idle > 0 && (r.UseDeployment || ws.Spec.Lifecycle.StopMode == workspacev1alpha1.StopModeScaleToZero)
+	podName, err := r.deploymentPodName(ctx, ws)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if deploy.Status.ReadyReplicas > 0 && idleScalesToZero {
+		stop, result, err := r.idleStopDue(ctx, ws, idle, podName, serviceEndpoint)
+		if err != nil || !result.IsZero() {
+			return result, err
+		}
+		if stop {
+			if !r.idleStopAllowed() {
+				log.V(1).Info("Idle stop rate limit reached; deferring stop",
+					"workspace", ws.Name, "perSecond", r.IdleStopsPerSecond)
+				return ctrl.Result{RequeueAfter: idleStopRequeueInterval}, nil
+			}
+			log.Info("Workspace idle timeout reached, scaling Deployment to zero",
+				"workspace", ws.Name, "idleTimeout", idle)
+			patch := client.MergeFrom(deploy.DeepCopy())
+			zero := int32(0)
+			deploy.Spec.Replicas = &zero
+			if err := r.Patch(ctx, deploy, patch); err != nil {
+				return ctrl.Result{}, fmt.Errorf("scale idle Deployment to zero: %w", err)
+			}
+			return ctrl.Result{}, r.markIdleStopped(ctx, ws)
+		}
+	}
+	if deploy.Status.ReadyReplicas > 0 {
+		if updateErr := r.updateStatus(ctx, ws, workspace.StatusSummary{
+			Phase:           workspacev1alpha1.WorkspacePhaseRunning,
+			PodName:         podName,
+			ServiceEndpoint: serviceEndpoint,
+			Message:         fmt.Sprintf("%d/%d replicas ready", deploy.Status.ReadyReplicas, want),
+			ReadyReason:     workspace.ReasonPodRunning,
+		}); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		// Requeue periodically so the idle-timeout check and a deferred image
+		// rollout are retried even without events.
+		var requeue time.Duration
+		if idleScalesToZero {
+			requeue = idle / 4
+		}
+		if rolloutDeferred && (requeue == 0 || requeue > imageRolloutRequeueInterval) {
+			requeue = imageRolloutRequeueInterval
+		}
+		return ctrl.Result{RequeueAfter: requeue}, nil
+	}
+	if updateErr := r.updateStatus(ctx, ws, workspace.StatusSummary{
+		Phase:           workspacev1alpha1.WorkspacePhaseCreating,
+		PodName:         podName,
+		ServiceEndpoint: serviceEndpoint,
+		Message:         fmt.Sprintf("0/%d replicas ready", want),
+		ReadyReason:     workspace.ReasonProgressing,
+	}); updateErr != nil {
+		return ctrl.Result{}, updateErr
+	}
+	return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+}
+
+// templateImage returns the workspace container image of a pod template, or
+// "" when it has no containers.
+func templateImage(t *corev1.PodTemplateSpec) string {
+	if len(t.Spec.Containers) == 0 {
+		return ""
+	}
+	return t.Spec.Containers[0].Image
+}
+
+// deploymentRolledOut reports whether every wanted replica of deploy runs its
+// current template and is ready.
+func deploymentRolledOut(deploy *appsv1.Deployment, want int32) bool {
+	return deploy.Status.ObservedGeneration >= deploy.Generation &&
+		deploy.Status.UpdatedReplicas >= want && deploy.Status.ReadyReplicas >= want
+}
+
+// deploymentPodName returns the name of a pod run by the workspace's
+// Deployment for status.podName, preferring a ready one. It returns "" when
+// no pod exists yet.
+func (r *WorkspaceReconciler) deploymentPodName(ctx context.Context, ws *workspacev1alpha1.Workspace) (string, error) {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(ws.Namespace),
+		client.MatchingLabels(workspace.SelectorLabels(ws.Spec.User.ID))); err != nil {
+		return "", fmt.Errorf("list Deployment pods: %w", err)
+	}
+	slices.SortFunc(pods.Items, func(a, b corev1.Pod) int { return strings.Compare(a.Name, b.Name) })
+	name := ""
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		if isPodReady(pod) {
+			return pod.Name, nil
+		}
+		if name == "" {
+			name = pod.Name
+		}
+	}
+	return name, nil
+}
+
+// markNetworkPolicyReady sets the workspace.NetworkPolicyReadyCondition
+// readiness gate on pod to True. Callers must have ensured the workspace's
+// NetworkPolicies first: they carry no status, so a successful create or
+// update by the API server is what the gate records. Pods that do not declare
+// the gate, or already have it set, are left alone.
+func (r *WorkspaceReconciler) markNetworkPolicyReady(ctx context.Context, pod *corev1.Pod) error {
+	if !pod.DeletionTimestamp.IsZero() || !slices.ContainsFunc(pod.Spec.ReadinessGates, func(g corev1.PodReadinessGate) bool {
+		return g.ConditionType == workspace.NetworkPolicyReadyCondition
+	}) {
+		return nil
+	}
+	i := slices.IndexFunc(pod.Status.Conditions, func(c corev1.PodCondition) bool {
+		return c.Type == workspace.NetworkPolicyReadyCondition
+	})
+	if i >= 0 && pod.Status.Conditions[i].Status == corev1.ConditionTrue {
+		return nil
+	}
+	base := pod.DeepCopy()
+	cond := corev1.PodCondition{
+		Type:               workspace.NetworkPolicyReadyCondition,
+		Status:             corev1.ConditionTrue,
+		LastTransitionTime: metav1.Now(),
+		Reason:             "NetworkPoliciesApplied",
+		Message:            "deny-all, egress and ingress-gateway NetworkPolicies are in place",
+	}
+	if i >= 0 {
+		pod.Status.Conditions[i] = cond
+	} else {
+		pod.Status.Conditions = append(pod.Status.Conditions, cond)
+	}
+	if err := r.Status().Patch(ctx, pod, client.MergeFrom(base)); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("set NetworkPolicy readiness gate on pod %s: %w", pod.Name, err)
+	}
+	return nil
+}
+
+// markDeploymentPodsNetworkPolicyReady opens the NetworkPolicy readiness gate
+// on every pod run by the workspace's Deployment.
+func (r *WorkspaceReconciler) markDeploymentPodsNetworkPolicyReady(ctx context.Context, ws *workspacev1alpha1.Workspace) error {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(ws.Namespace),
+		client.MatchingLabels(workspace.SelectorLabels(ws.Spec.User.ID))); err != nil {
+		return fmt.Errorf("list Deployment pods: %w", err)
+	}
+	for i := range pods.Items {
+		if err := r.markNetworkPolicyReady(ctx, &pods.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureService creates or updates the headless Service selecting the
+// workspace pod(s) on the ttyd port.
+func (r *WorkspaceReconciler) ensureService(ctx context.Context, ws *workspacev1alpha1.Workspace) error {
+	svcLabels := r.resourceLabels(ws, workspace.Labels(ws.Spec.User.ID))
+	annotations := workspace.BuildServiceAnnotations(ws, r.ExternalDNSHostname)
+	svc := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: workspace.ServiceName(ws.Spec.User.ID), Namespace: ws.Namespace},
+	}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, svc, func() error {
+		svc.Labels = svcLabels
+		// Only merging would keep an annotation (e.g. a DNS name) alive after
+		// it left the spec; drop the operator's stale keys, keep foreign ones.
+		svc.Annotations = workspace.ApplyManagedAnnotations(svc.Annotations, annotations)
+		svc.Spec.ClusterIP = corev1.ClusterIPNone
+		svc.Spec.Selector = workspace.SelectorLabels(ws.Spec.User.ID)
+		svc.Spec.Ports = []corev1.ServicePort{
+			{Name: "ttyd", Port: workspace.TTYDPort(ws), Protocol: corev1.ProtocolTCP},
+		}
+		return controllerutil.SetControllerReference(ws, svc, r.Scheme)
+	})
+	return err
+}
+
+// isFrozen reports whether reconciliation is globally paused, either by the
+// static Frozen flag or by the freeze ConfigMap.
+func (r *WorkspaceReconciler) isFrozen(ctx context.Context) (bool, error) {
+	if r.Frozen {
+		return true, nil
+	}
+	if r.FreezeConfigMap.Name == "" {
+		return false, nil
+	}
+	var cm corev1.ConfigMap
+	if err := r.Get(ctx, r.FreezeConfigMap, &cm); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return strings.EqualFold(strings.TrimSpace(cm.Data[FreezeConfigMapKey]), "true"), nil
+}
+
+// recordReconcile stores the outcome of a reconcile in status.lastReconcileTime
+// and status.lastReconcileResult. The time is refreshed when the result changes
+// or reconcileRecordInterval has elapsed. Workspaces that are gone or being
+// deleted are left alone; failures are
+// logged rather than returned so they do not mask the reconcile result.
+func (r *WorkspaceReconciler) recordReconcile(ctx context.Context, nn types.NamespacedName, reconcileErr error) {
+	log := log.FromContext(ctx)
+	var ws workspacev1alpha1.Workspace
+	if err := r.Get(ctx, nn, &ws); err != nil {
+		if !errors.IsNotFound(err) {
+			log.Error(err, "Failed to fetch Workspace to record reconcile result")
+		}
+		return
+	}
+	if !ws.DeletionTimestamp.IsZero() {
+		return
+	}
+	result := workspace.ReconcileResult(reconcileErr)
+	now := time.Now()
+	if ws.Status.LastReconcileResult == result && now.Sub(ws.Status.LastReconcileTime.Time) < reconcileRecordInterval {
+		return
+	}
+	base := ws.DeepCopy()
+	ws.Status.LastReconcileTime = metav1.NewTime(now)
+	ws.Status.LastReconcileResult = result
+	if err := r.Status().Patch(ctx, &ws, client.MergeFrom(base)); err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "Failed to record reconcile result")
+	}
+}
+
+// reconcileDelete removes the finalizer so that Kubernetes garbage collection
+// can cascade-delete all owned resources (Pod, PVC, Service, RBAC, NetworkPolicies).
+func (r *WorkspaceReconciler) reconcileDelete(ctx context.Context, ws *workspacev1alpha1.Workspace) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.Info("Handling workspace deletion", "workspace", ws.Name)
+	controllerutil.RemoveFinalizer(ws, workspaceFinalizer)
+	if err := r.Update(ctx, ws); err != nil {
+		return ctrl.Result{}, fmt.Errorf("remove finalizer: %w", err)
+	}
+	return ctrl.Result{}, nil
+}
+
+// imageRolloutAllowed reports whether ws may recreate its pod for an image
+// change now. Rollouts in flight are read from status.imageRolloutStartedAt on
+// the Workspaces, so the cap survives operator restarts; entries older than
+// imageRolloutSlotTimeout no longer count. A workspace already rolling keeps
+// its slot.
+func (r *WorkspaceReconciler) imageRolloutAllowed(ctx context.Context, ws *workspacev1alpha1.Workspace) (bool, error) {
+	if r.MaxConcurrentImageRollouts <= 0 || rolloutInFlight(ws) {
+		return true, nil
+	}
+	var list workspacev1alpha1.WorkspaceList
+	if err := r.List(ctx, &list); err != nil {
+		return false, fmt.Errorf("list workspaces for image rollout limit: %w", err)
+	}
+	inFlight := 0
+	for i := range list.Items {
+		if rolloutInFlight(&list.Items[i]) {
+			inFlight++
+		}
+	}
+	return inFlight < r.MaxConcurrentImageRollouts, nil
+}
+
+// rolloutInFlight reports whether ws holds an image rollout slot.
+func rolloutInFlight(ws *workspacev1alpha1.Workspace) bool {
+	started := ws.Status.ImageRolloutStartedAt
+	return !started.IsZero() && ws.DeletionTimestamp.IsZero() && time.Since(started.Time) <= imageRolloutSlotTimeout
+}
+
+// setImageRolloutStartedAt patches status.imageRolloutStartedAt; a zero value clears it.
+func (r *WorkspaceReconciler) setImageRolloutStartedAt(ctx context.Context, ws *workspacev1alpha1.Workspace, at metav1.Time) error {
+	base := ws.DeepCopy()
+	ws.Status.ImageRolloutStartedAt = at
+	if err := r.patchStatus(ctx, ws, client.MergeFrom(base)); err != nil {
+		return fmt.Errorf("patch imageRolloutStartedAt: %w", err)
+	}
+	return nil
+}
+
+// idleStopAllowed reports whether an idle workspace may be stopped now under
+// IdleStopsPerSecond. The limiter only paces API calls; whether a workspace is
+// due to stop is always derived from its status.
+func (r *WorkspaceReconciler) idleStopAllowed() bool {
+	if r.IdleStopsPerSecond <= 0 {
+		return true
+	}
+	r.idleStopOnce.Do(func() {
+		r.idleStopLimiter = rate.NewLimiter(rate.Limit(r.IdleStopsPerSecond), max(1, int(r.IdleStopsPerSecond)))
+	})
+	return r.idleStopLimiter.Allow()
+}
+
+// replaceVolumeForRestore deletes the workspace pod, Deployment and PVC so the
+// next reconciles recreate the PVC from spec.persistence.restoreFrom. The PVC
+// stays Terminating until its pods are gone, which the caller waits out.
+func (r *WorkspaceReconciler) replaceVolumeForRestore(ctx context.Context, ws *workspacev1alpha1.Workspace, pvc *corev1.PersistentVolumeClaim) error {
+	userID := ws.Spec.User.ID
+	deploy := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: workspace.DeploymentName(userID), Namespace: ws.Namespace}}
+	if err := r.Delete(ctx, deploy, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("delete deployment for restore: %w", err)
+	}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: workspace.PodName(userID), Namespace: ws.Namespace}}
+	if err := r.Delete(ctx, pod); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("delete pod for restore: %w", err)
+	}
+	if err := r.Delete(ctx, pvc); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("delete pvc for restore: %w", err)
+	}
+	return nil
+}
+
+// ensureAISettingsConfigMap creates or updates the ConfigMap rendered from
+// spec.aiConfig.settings so it tracks spec changes.
+func (r *WorkspaceReconciler) ensureAISettingsConfigMap(ctx context.Context, ws *workspacev1alpha1.Workspace) error {
+	desired, err := workspace.BuildAISettingsConfigMap(ws, r.Scheme)
+	if err != nil {
+		return fmt.Errorf("build AI settings ConfigMap: %w", err)
+	}
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: desired.Name, Namespace: ws.Namespace},
+	}
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, cm, func() error {
+		cm.Labels = r.resourceLabels(ws, desired.Labels)
+		cm.Data = desired.Data
+		return controllerutil.SetControllerReference(ws, cm, r.Scheme)
+	})
+	if err != nil {
+		return fmt.Errorf("ensure AI settings ConfigMap: %w", err)
+	}
+	if result != controllerutil.OperationResultNone {
+		log.FromContext(ctx).Info("AI settings ConfigMap reconciled", "configMap", cm.Name, "result", result)
+	}
+	return nil
+}
+
+// ensureResourceQuota creates or updates the per-workspace ResourceQuota so its
+// limits track spec.resources.
+func (r *WorkspaceReconciler) ensureResourceQuota(ctx context.Context, ws *workspacev1alpha1.Workspace) error {
+	desired, err := workspace.BuildResourceQuota(ws, r.Scheme, r.ResourceQuotaHeadroomPercent)
+	if err != nil {
+		return fmt.Errorf("build ResourceQuota: %w", err)
+	}
+	quota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: desired.Name, Namespace: ws.Namespace},
+	}
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, quota, func() error {
+		quota.Labels = r.resourceLabels(ws, desired.Labels)
+		quota.Spec.Hard = desired.Spec.Hard
+		return controllerutil.SetControllerReference(ws, quota, r.Scheme)
+	})
+	if err != nil {
+		return fmt.Errorf("ensure ResourceQuota: %w", err)
+	}
+	if result != controllerutil.OperationResultNone {
+		log.FromContext(ctx).Info("ResourceQuota reconciled", "resourceQuota", quota.Name, "result", result)
+	}
+	return nil
+}
+
+// ensureIngress creates or updates the per-workspace Ingress.
+func (r *WorkspaceReconciler) ensureIngress(ctx context.Context, ws *workspacev1alpha1.Workspace) error {
+	desired, err := workspace.BuildIngress(ws, r.Scheme, r.Ingress)
+	if err != nil {
+		return fmt.Errorf("build Ingress: %w", err)
+	}
+	ing := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: desired.Name, Namespace: ws.Namespace},
+	}
+	result, err := controllerutil.CreateOrUpdate(ctx, r.Client, ing, func() error {
+		ing.Labels = r.resourceLabels(ws, desired.Labels)
+		if ing.Annotations == nil {
+			ing.Annotations = map[string]string{}
+		}
+		delete(ing.Annotations, workspace.IngressAuthSigninAnnotation)
+		maps.Copy(ing.Annotations, desired.Annotations)
+		ing.Spec = desired.Spec
+		return controllerutil.SetControllerReference(ws, ing, r.Scheme)
+	})
+	if err != nil {
+		return fmt.Errorf("ensure Ingress: %w", err)
+	}
+	if result != controllerutil.OperationResultNone {
+		log.FromContext(ctx).Info("Ingress reconciled", "ingress", ing.Name, "result", result)
+	}
+	return nil
+}
+
+// deleteIngress removes the workspace's owned Ingress once the operator no
+// longer publishes one, so turning the option off stops exposing ttyd.
+func (r *WorkspaceReconciler) deleteIngress(ctx context.Context, ws *workspacev1alpha1.Workspace) error {
+	var ing networkingv1.Ingress
+	key := client.ObjectKey{Namespace: ws.Namespace, Name: workspace.IngressName(ws.Spec.User.ID)}
+	if err := r.Get(ctx, key, &ing); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("get Ingress: %w", err)
+	}
+	if !metav1.IsControlledBy(&ing, ws) {
+		return nil
+	}
+	log.FromContext(ctx).Info("Deleting Ingress after workspace Ingresses were disabled", "ingress", ing.Name)
+	if err := r.Delete(ctx, &ing); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("delete Ingress: %w", err)
+	}
+	return nil
+}
+
+// serviceAccountName is the ServiceAccount (and Role/RoleBinding) name used
+// for userID's workspace pods.
+func (r *WorkspaceReconciler) serviceAccountName(userID string) string {
+	if r.SharedServiceAccount {
+		return workspace.SharedServiceAccountName
+	}
+	return workspace.ServiceAccountName(userID)
+}
+
+// ensureRBAC creates or updates the per-user ServiceAccount, Role, and RoleBinding,
+// or with SharedServiceAccount the namespace-wide ones.
+func (r *WorkspaceReconciler) ensureRBAC(ctx context.Context, ws *workspacev1alpha1.Workspace) error {
+	log := log.FromContext(ctx)
+	userID := ws.Spec.User.ID
+	saName := r.serviceAccountName(userID)
+
+	rbacLabels := map[string]string{
+		"app":        "workspace",
+		"user":       userID,
+		"managed-by": "devplane",
+	}
+	setOwner := func(obj client.Object) error {
+		return controllerutil.SetControllerReference(ws, obj, r.Scheme)
+	}
+	if r.SharedServiceAccount {
+		// Shared objects belong to no single user. Every Workspace using them
+		// adds a plain owner reference, so they are garbage-collected only
+		// once the last of those Workspaces is deleted.
+		delete(rbacLabels, "user")
+		setOwner = func(obj client.Object) error {
+			return controllerutil.SetOwnerReference(ws, obj, r.Scheme)
+		}
+	} else {
+		// Shared objects skip ResourceLabels: per-workspace values would flap
+		// between the Workspaces using them.
+		rbacLabels = r.resourceLabels(ws, rbacLabels)
+	}
+
+	// ServiceAccount
+	sa := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{Name: saName, Namespace: ws.Namespace},
+	}
+	if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, sa, func() error {
+		sa.Labels = rbacLabels
+		return setOwner(sa)
+	}); err != nil {
+		return fmt.Errorf("ensure ServiceAccount: %w", err)
+	} else if result != controllerutil.OperationResultNone {
+		log.Info("ServiceAccount reconciled", "name", saName, "result", result)
 	}
 
 	// Role — delegate desired rules to security.BuildRole for a single source of truth.
@@ -494,7 +1733,7 @@ func (r *WorkspaceReconciler) ensureRBAC(ctx context.Context, ws *workspacev1alp
 	if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, role, func() error {
 		role.Labels = rbacLabels
 		role.Rules = desiredRole.Rules
-		return controllerutil.SetControllerReference(ws, role, r.Scheme)
+		return setOwner(role)
 	}); err != nil {
 		return fmt.Errorf("ensure Role: %w", err)
 	} else if result != controllerutil.OperationResultNone {
@@ -517,7 +1756,7 @@ func (r *WorkspaceReconciler) ensureRBAC(ctx context.Context, ws *workspacev1alp
 			Kind:     "Role",
 			Name:     saName,
 		}
-		return controllerutil.SetControllerReference(ws, rb, r.Scheme)
+		return setOwner(rb)
 	}); err != nil {
 		return fmt.Errorf("ensure RoleBinding: %w", err)
 	} else if result != controllerutil.OperationResultNone {
@@ -529,7 +1768,7 @@ func (r *WorkspaceReconciler) ensureRBAC(ctx context.Context, ws *workspacev1alp
 
 // ensureNetworkPolicies creates or updates the three NetworkPolicies for a workspace:
 // deny-all, egress (dynamic, reacts to spec changes), and ingress-from-gateway.
-func (r *WorkspaceReconciler) ensureNetworkPolicies(ctx context.Context, ws *workspacev1alpha1.Workspace) error {
+func (r *WorkspaceReconciler) ensureNetworkPolicies(ctx context.Context, ws *workspacev1alpha1.Workspace, managed *managedResources) error {
 	log := log.FromContext(ctx)
 
 	// Deny-all (static spec — deny all ingress and egress by default).
@@ -541,7 +1780,7 @@ func (r *WorkspaceReconciler) ensureNetworkPolicies(ctx context.Context, ws *wor
 		ObjectMeta: metav1.ObjectMeta{Name: denyAll.Name, Namespace: ws.Namespace},
 	}
 	if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, npDenyAll, func() error {
-		npDenyAll.Labels = denyAll.Labels
+		npDenyAll.Labels = r.resourceLabels(ws, denyAll.Labels)
 		npDenyAll.Spec = denyAll.Spec
 		return controllerutil.SetControllerReference(ws, npDenyAll, r.Scheme)
 	}); err != nil {
@@ -549,6 +1788,7 @@ func (r *WorkspaceReconciler) ensureNetworkPolicies(ctx context.Context, ws *wor
 	} else if result != controllerutil.OperationResultNone {
 		log.Info("deny-all NetworkPolicy reconciled", "result", result)
 	}
+	managed.add("NetworkPolicy", npDenyAll.Name)
 
 	// Egress (dynamic — reacts to changes in llmNamespaces/egressPorts).
 	llmNamespaces := security.ResolveLLMEgressNamespaces(ws.Spec.AIConfig.EgressNamespaces, r.LLMNamespaces)
@@ -558,11 +1798,22 @@ func (r *WorkspaceReconciler) ensureNetworkPolicies(ctx context.Context, ws *wor
 	if err != nil {
 		return fmt.Errorf("build egress NetworkPolicy: %w", err)
 	}
+	if r.apiServerEgressEnabled(ws) {
+		endpoints, ports, err := r.apiServerEndpoints(ctx)
+		if err != nil {
+			return fmt.Errorf("resolve API server endpoints: %w", err)
+		}
+		if rule := security.BuildAPIServerEgressRule(endpoints, ports); rule != nil {
+			desiredEgress.Spec.Egress = append(desiredEgress.Spec.Egress, *rule)
+		} else {
+			log.Info("No API server endpoints resolved; API server egress not opened")
+		}
+	}
 	npEgress := &networkingv1.NetworkPolicy{
 		ObjectMeta: metav1.ObjectMeta{Name: desiredEgress.Name, Namespace: ws.Namespace},
 	}
 	if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, npEgress, func() error {
-		npEgress.Labels = desiredEgress.Labels
+		npEgress.Labels = r.resourceLabels(ws, desiredEgress.Labels)
 		npEgress.Spec = desiredEgress.Spec
 		return controllerutil.SetControllerReference(ws, npEgress, r.Scheme)
 	}); err != nil {
@@ -570,17 +1821,21 @@ func (r *WorkspaceReconciler) ensureNetworkPolicies(ctx context.Context, ws *wor
 	} else if result != controllerutil.OperationResultNone {
 		log.Info("egress NetworkPolicy reconciled", "result", result)
 	}
+	managed.add("NetworkPolicy", npEgress.Name)
 
 	// Ingress-from-gateway (static spec — allow ttyd traffic from gateway pods).
 	ingressGw, err := security.BuildIngressFromGatewayNetworkPolicy(ws, r.GatewayNamespace, r.Scheme)
 	if err != nil {
 		return fmt.Errorf("build ingress-gateway NetworkPolicy: %w", err)
 	}
+	if r.Ingress.BaseDomain != "" && r.IngressControllerNamespace != "" {
+		security.AllowIngressFromNamespace(ingressGw, r.IngressControllerNamespace)
+	}
 	npIngressGw := &networkingv1.NetworkPolicy{
 		ObjectMeta: metav1.ObjectMeta{Name: ingressGw.Name, Namespace: ws.Namespace},
 	}
 	if result, err := controllerutil.CreateOrUpdate(ctx, r.Client, npIngressGw, func() error {
-		npIngressGw.Labels = ingressGw.Labels
+		npIngressGw.Labels = r.resourceLabels(ws, ingressGw.Labels)
 		npIngressGw.Spec = ingressGw.Spec
 		return controllerutil.SetControllerReference(ws, npIngressGw, r.Scheme)
 	}); err != nil {
@@ -588,20 +1843,37 @@ func (r *WorkspaceReconciler) ensureNetworkPolicies(ctx context.Context, ws *wor
 	} else if result != controllerutil.OperationResultNone {
 		log.Info("ingress-gateway NetworkPolicy reconciled", "result", result)
 	}
+	managed.add("NetworkPolicy", npIngressGw.Name)
 
 	return nil
 }
 
 // updateStatus sets the Workspace status fields and patches via the status
 // subresource.  Patch is used instead of Update to avoid clobbering fields
-// owned by other controllers (e.g. the gateway writes LastAccessed).
+// owned by other controllers (e.g. the gateway writes LastAccessed). The patch
+// carries the resourceVersion, so a status computed from a stale object fails
+// with a conflict and is re-applied to a fresh copy.
 func (r *WorkspaceReconciler) updateStatus(ctx context.Context, ws *workspacev1alpha1.Workspace, sum workspace.StatusSummary) error {
-	base := ws.DeepCopy()
-	oldPhase := base.Status.Phase
-	workspace.ApplyStatusSummary(ws, sum)
-	if err := r.Status().Patch(ctx, ws, client.MergeFrom(base)); err != nil {
-		observability.WorkspaceStatusPatchFailures.Inc()
-		return err
+	oldPhase := ws.Status.Phase
+	for attempt := 1; ; attempt++ {
+		base := ws.DeepCopy()
+		workspace.ApplyStatusSummary(ws, sum)
+		err := r.patchStatus(ctx, ws, client.MergeFromWithOptions(base, client.MergeFromWithOptimisticLock{}))
+		if err == nil {
+			break
+		}
+		if !errors.IsConflict(err) || attempt >= statusUpdateAttempts {
+			observability.WorkspaceStatusPatchFailures.Inc()
+			return err
+		}
+		// The object changed under us: refetch and re-apply the computed summary
+		// rather than failing the whole reconcile.
+		var fresh workspacev1alpha1.Workspace
+		if getErr := r.Get(ctx, client.ObjectKeyFromObject(ws), &fresh); getErr != nil {
+			observability.WorkspaceStatusPatchFailures.Inc()
+			return getErr
+		}
+		ws.ObjectMeta, ws.Status = fresh.ObjectMeta, fresh.Status
 	}
 	if oldPhase != sum.Phase {
 		observability.LogWorkspacePhaseTransition(log.FromContext(ctx), ws, oldPhase, sum.Phase, sum.PodName, sum.ServiceEndpoint, ws.Status.Message)
@@ -620,6 +1892,369 @@ func (r *WorkspaceReconciler) updateStatus(ctx context.Context, ws *workspacev1a
 	return nil
 }
 
+// patchStatus patches ws's status subresource. The response would overwrite
+// ws.Spec with the stored spec, dropping WorkspaceTemplate values merged in
+// memory for this pass, so the spec is kept as it was.
+func (r *WorkspaceReconciler) patchStatus(ctx context.Context, ws *workspacev1alpha1.Workspace, patch client.Patch) error {
+	spec := ws.Spec.DeepCopy()
+	err := r.Status().Patch(ctx, ws, patch)
+	ws.Spec = *spec
+	return err
+}
+
+// resourceLabels returns base plus the operator's ResourceLabels rendered for ws.
+func (r *WorkspaceReconciler) resourceLabels(ws *workspacev1alpha1.Workspace, base map[string]string) map[string]string {
+	return workspace.ResourceLabels(base, ws, r.ResourceLabels)
+}
+
+// setStatusCondition records cond on the workspace status at the current
+// generation, patching only when the condition actually changed.
+func (r *WorkspaceReconciler) setStatusCondition(ctx context.Context, ws *workspacev1alpha1.Workspace, cond metav1.Condition) error {
+	base := ws.DeepCopy()
+	cond.ObservedGeneration = ws.Generation
+	if !meta.SetStatusCondition(&ws.Status.Conditions, cond) {
+		return nil
+	}
+	if err := r.patchStatus(ctx, ws, client.MergeFrom(base)); err != nil {
+		return fmt.Errorf("record %s condition: %w", cond.Type, err)
+	}
+	return nil
+}
+
+// setIdleStopAt patches status.idleStopAt; a zero value clears it.
+func (r *WorkspaceReconciler) setIdleStopAt(ctx context.Context, ws *workspacev1alpha1.Workspace, at metav1.Time) error {
+	base := ws.DeepCopy()
+	ws.Status.IdleStopAt = at
+	if err := r.patchStatus(ctx, ws, client.MergeFrom(base)); err != nil {
+		return fmt.Errorf("patch idleStopAt: %w", err)
+	}
+	return nil
+}
+
+// managedResources collects the owned resources ensured during one reconcile.
+type managedResources []workspacev1alpha1.ManagedResource
+
+func (m *managedResources) add(kind, name string) {
+	*m = append(*m, workspacev1alpha1.ManagedResource{Kind: kind, Name: name})
+}
+
+func (m *managedResources) remove(kind, name string) {
+	*m = slices.DeleteFunc(*m, func(res workspacev1alpha1.ManagedResource) bool {
+		return res.Kind == kind && res.Name == name
+	})
+}
+
+// recordManagedResources patches status.managedResources when managed differs
+// from the recorded list. Failures are logged; the next reconcile retries.
+func (r *WorkspaceReconciler) recordManagedResources(ctx context.Context, ws *workspacev1alpha1.Workspace, managed managedResources) {
+	if slices.Equal(ws.Status.ManagedResources, managed) {
+		return
+	}
+	base := ws.DeepCopy()
+	ws.Status.ManagedResources = managed
+	if err := r.patchStatus(ctx, ws, client.MergeFrom(base)); err != nil && !errors.IsNotFound(err) {
+		log.FromContext(ctx).Error(err, "Failed to record managed resources")
+	}
+}
+
+// immediateBinding reports whether pvc's storage class binds volumes
+// immediately. Unknown classes (unset, missing, unreadable) report false so
+// reconcile falls through to pod creation as before.
+func (r *WorkspaceReconciler) immediateBinding(ctx context.Context, pvc *corev1.PersistentVolumeClaim) bool {
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		return false
+	}
+	var sc storagev1.StorageClass
+	if err := r.Get(ctx, client.ObjectKey{Name: *pvc.Spec.StorageClassName}, &sc); err != nil {
+		return false
+	}
+	// VolumeBindingMode defaults to Immediate when unset.
+	return sc.VolumeBindingMode == nil || *sc.VolumeBindingMode == storagev1.VolumeBindingImmediate
+}
+
+// unschedulableExtendedResources returns a message naming the extended
+// resources pod requests that no schedulable node has enough of, or "" when
+// every request fits some node. The check is best-effort: it ignores taints,
+// selectors and current usage, and reports nothing when nodes cannot be listed.
+func (r *WorkspaceReconciler) unschedulableExtendedResources(ctx context.Context, pod *corev1.Pod) string {
+	requests := workspace.ExtendedResourceRequests(pod)
+	if len(requests) == 0 {
+		return ""
+	}
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	var nodes corev1.NodeList
+	if err := reader.List(ctx, &nodes); err != nil {
+		log.FromContext(ctx).V(1).Info("Skipping node capacity check", "error", err.Error())
+		return ""
+	}
+	var missing []string
+	for _, name := range slices.Sorted(maps.Keys(requests)) {
+		want := requests[name]
+		fits := false
+		for i := range nodes.Items {
+			node := &nodes.Items[i]
+			if node.Spec.Unschedulable {
+				continue
+			}
+			if have, ok := node.Status.Allocatable[name]; ok && have.Cmp(want) >= 0 {
+				fits = true
+				break
+			}
+		}
+		if !fits {
+			missing = append(missing, fmt.Sprintf("%s: %s", name, want.String()))
+		}
+	}
+	if len(missing) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("No schedulable node can provide %s; the workspace pod stays Pending until a node offering it joins the cluster", strings.Join(missing, ", "))
+}
+
+// apiServerEgressEnabled reports whether the workspace may reach the API
+// server: through spec.clusterInspection, spec.apiServerEgress, or the
+// operator-wide APIServerEgress.
+func (r *WorkspaceReconciler) apiServerEgressEnabled(ws *workspacev1alpha1.Workspace) bool {
+	return ws.Spec.ClusterInspection || ws.Spec.APIServerEgress || r.APIServerEgress
+}
+
+// apiServerEndpoints returns the addresses and ports to open for API server
+// egress: APIServerCIDRs when configured, else the endpoints of the
+// default/kubernetes Service. The Service port 443 is always included.
+func (r *WorkspaceReconciler) apiServerEndpoints(ctx context.Context) ([]string, []int32, error) {
+	if len(r.APIServerCIDRs) > 0 {
+		return r.APIServerCIDRs, security.DefaultAPIServerPorts, nil
+	}
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+	var epSlices discoveryv1.EndpointSliceList
+	if err := reader.List(ctx, &epSlices, client.InNamespace(metav1.NamespaceDefault),
+		client.MatchingLabels{discoveryv1.LabelServiceName: "kubernetes"}); err != nil {
+		return nil, nil, err
+	}
+	var addrs []string
+	ports := []int32{443}
+	for _, s := range epSlices.Items {
+		for _, ep := range s.Endpoints {
+			addrs = append(addrs, ep.Addresses...)
+		}
+		for _, p := range s.Ports {
+			if p.Port != nil {
+				ports = append(ports, *p.Port)
+			}
+		}
+	}
+	return addrs, ports, nil
+}
+
+// caBundleHash returns the ConfigMapDataHash of the workspace's CA bundle
+// ConfigMap, or "" when none is configured or it does not exist yet.
+func (r *WorkspaceReconciler) caBundleHash(ctx context.Context, ws *workspacev1alpha1.Workspace) (string, error) {
+	name := workspace.CABundleConfigMapName(ws, r.DefaultCABundle)
+	if name == "" {
+		return "", nil
+	}
+	var cm corev1.ConfigMap
+	if err := r.Get(ctx, client.ObjectKey{Namespace: ws.Namespace, Name: name}, &cm); err != nil {
+		if errors.IsNotFound(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("get CA bundle ConfigMap: %w", err)
+	}
+	return workspace.ConfigMapDataHash(&cm), nil
+}
+
+// syncCABundleCondition records in the CABundleValid condition whether the
+// workspace's CA bundle ConfigMap holds only parseable PEM certificates, and
+// emits a Warning event naming the bad keys when it turns invalid. The
+// condition is removed when no bundle is referenced or the ConfigMap is missing.
+func (r *WorkspaceReconciler) syncCABundleCondition(ctx context.Context, ws *workspacev1alpha1.Workspace) error {
+	base := ws.DeepCopy()
+	var changed bool
+	name := workspace.CABundleConfigMapName(ws, r.DefaultCABundle)
+	var cm corev1.ConfigMap
+	var err error
+	if name != "" {
+		err = r.Get(ctx, client.ObjectKey{Namespace: ws.Namespace, Name: name}, &cm)
+	}
+	switch {
+	case name == "" || errors.IsNotFound(err):
+		changed = meta.RemoveStatusCondition(&ws.Status.Conditions, workspace.ConditionTypeCABundleValid)
+	case err != nil:
+		return fmt.Errorf("get CA bundle ConfigMap: %w", err)
+	default:
+		cond := metav1.Condition{
+			Type:               workspace.ConditionTypeCABundleValid,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: ws.Generation,
+			Reason:             workspace.ReasonCABundleValid,
+			Message:            fmt.Sprintf("CA bundle ConfigMap %q contains valid PEM certificates.", name),
+		}
+		if bad := workspace.InvalidCABundleKeys(&cm); len(bad) > 0 {
+			cond.Status = metav1.ConditionFalse
+			cond.Reason = workspace.ReasonCABundleInvalid
+			cond.Message = fmt.Sprintf("Warning: CA bundle ConfigMap %q has keys that are not valid PEM certificates: %s", name, strings.Join(bad, ", "))
+			prev := meta.FindStatusCondition(ws.Status.Conditions, workspace.ConditionTypeCABundleValid)
+			if r.Recorder != nil && (prev == nil || prev.Message != cond.Message) {
+				r.Recorder.Eventf(ws, nil, corev1.EventTypeWarning, workspace.ReasonCABundleInvalid, "ValidateCABundle", "%s", cond.Message)
+			}
+		}
+		changed = meta.SetStatusCondition(&ws.Status.Conditions, cond)
+	}
+	if !changed {
+		return nil
+	}
+	if err := r.patchStatus(ctx, ws, client.MergeFrom(base)); err != nil {
+		return fmt.Errorf("record CA bundle condition: %w", err)
+	}
+	return nil
+}
+
+// workspacesForCABundle maps a ConfigMap event to the workspaces in its
+// namespace that mount it as their CA bundle.
+func (r *WorkspaceReconciler) workspacesForCABundle(ctx context.Context, obj client.Object) []reconcile.Request {
+	var list workspacev1alpha1.WorkspaceList
+	if err := r.List(ctx, &list, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list workspaces for CA bundle change", "configMap", obj.GetName())
+		return nil
+	}
+	var reqs []reconcile.Request
+	for i := range list.Items {
+		if workspace.CABundleConfigMapName(&list.Items[i], r.DefaultCABundle) == obj.GetName() {
+			reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+		}
+	}
+	return reqs
+}
+
+// workspacesForTemplate maps a WorkspaceTemplate event to the workspaces in
+// its namespace that reference it.
+func (r *WorkspaceReconciler) workspacesForTemplate(ctx context.Context, obj client.Object) []reconcile.Request {
+	var list workspacev1alpha1.WorkspaceList
+	if err := r.List(ctx, &list, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list workspaces for template change", "workspaceTemplate", obj.GetName())
+		return nil
+	}
+	var reqs []reconcile.Request
+	for i := range list.Items {
+		if list.Items[i].Spec.TemplateRef == obj.GetName() {
+			reqs = append(reqs, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+		}
+	}
+	return reqs
+}
+
+// waitForDependencies holds back pod creation until every workspace in
+// spec.dependsOn is Running. When blocked it records why in status and returns
+// blocked=true with the result the caller should return.
+func (r *WorkspaceReconciler) waitForDependencies(ctx context.Context, ws *workspacev1alpha1.Workspace) (ctrl.Result, bool, error) {
+	if len(ws.Spec.DependsOn) == 0 {
+		return ctrl.Result{}, false, nil
+	}
+	cycle, err := r.findDependencyCycle(ctx, ws)
+	if err != nil {
+		return ctrl.Result{}, true, fmt.Errorf("check dependencies: %w", err)
+	}
+	if cycle != nil {
+		if err := r.updateStatus(ctx, ws, workspace.StatusSummary{
+			Phase:           workspacev1alpha1.WorkspacePhaseFailed,
+			MessageOverride: "Dependency cycle: " + strings.Join(cycle, " -> "),
+			RemediationHint: workspace.RemediationDependency,
+			ReadyReason:     workspace.ReasonDependencyCycle,
+		}); err != nil {
+			return ctrl.Result{}, true, err
+		}
+		// Editing another workspace can break the cycle, so keep checking.
+		return ctrl.Result{RequeueAfter: dependencyRequeueInterval}, true, nil
+	}
+	for _, name := range ws.Spec.DependsOn {
+		var dep workspacev1alpha1.Workspace
+		msg := ""
+		if err := r.Get(ctx, client.ObjectKey{Namespace: ws.Namespace, Name: name}, &dep); err != nil {
+			if !errors.IsNotFound(err) {
+				return ctrl.Result{}, true, fmt.Errorf("get dependency %q: %w", name, err)
+			}
+			msg = fmt.Sprintf("Waiting for dependency %q: workspace not found", name)
+		} else if dep.Status.Phase != workspacev1alpha1.WorkspacePhaseRunning {
+			phase := dep.Status.Phase
+			if phase == "" {
+				phase = workspacev1alpha1.WorkspacePhasePending
+			}
+			msg = fmt.Sprintf("Waiting for dependency %q to be Running (phase %s)", name, phase)
+		}
+		if msg == "" {
+			continue
+		}
+		if err := r.updateStatus(ctx, ws, workspace.StatusSummary{
+			Phase:           workspacev1alpha1.WorkspacePhasePending,
+			ServiceEndpoint: ws.Status.ServiceEndpoint,
+			MessageOverride: msg,
+			ReadyReason:     workspace.ReasonWaitingForDependency,
+		}); err != nil {
+			return ctrl.Result{}, true, err
+		}
+		return ctrl.Result{RequeueAfter: dependencyRequeueInterval}, true, nil
+	}
+	return ctrl.Result{}, false, nil
+}
+
+// findDependencyCycle walks spec.dependsOn depth-first from ws and returns the
+// first cycle found as a path of workspace names, or nil. Missing dependencies
+// end their branch; they are reported separately as not yet Running.
+func (r *WorkspaceReconciler) findDependencyCycle(ctx context.Context, ws *workspacev1alpha1.Workspace) ([]string, error) {
+	onPath := map[string]bool{}
+	done := map[string]bool{}
+	var visit func(name string, deps, path []string) ([]string, error)
+	visit = func(name string, deps, path []string) ([]string, error) {
+		path = append(path, name)
+		onPath[name] = true
+		for _, d := range deps {
+			if onPath[d] {
+				return append(path, d), nil
+			}
+			if done[d] {
+				continue
+			}
+			var dep workspacev1alpha1.Workspace
+			if err := r.Get(ctx, client.ObjectKey{Namespace: ws.Namespace, Name: d}, &dep); err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return nil, err
+			}
+			if cycle, err := visit(d, dep.Spec.DependsOn, path); cycle != nil || err != nil {
+				return cycle, err
+			}
+		}
+		onPath[name] = false
+		done[name] = true
+		return nil, nil
+	}
+	return visit(ws.Name, ws.Spec.DependsOn, nil)
+}
+
+// idleTimeoutFor returns the idle shutdown window for this workspace: the
+// effective idle timeout, shortened to NeverAccessedIdleTimeout while nobody
+// has accessed the workspace since it was created.
+func (r *WorkspaceReconciler) idleTimeoutFor(ws *workspacev1alpha1.Workspace) time.Duration {
+	idle := effectiveIdleTimeout(ws, r.IdleTimeout)
+	if idle > 0 && r.NeverAccessedIdleTimeout > 0 && neverAccessed(ws) {
+		return min(idle, r.NeverAccessedIdleTimeout)
+	}
+	return idle
+}
+
+// neverAccessed reports whether status.lastAccessed is unset or not after the
+// workspace's creation, i.e. only seeded by the operator.
+func neverAccessed(ws *workspacev1alpha1.Workspace) bool {
+	return ws.Status.LastAccessed.IsZero() || !ws.Status.LastAccessed.After(ws.CreationTimestamp.Time)
+}
+
 // effectiveIdleTimeout returns the idle shutdown window for this workspace.
 // spec.lifecycle.idleTimeout empty inherits the operator default; "0" disables.
 func effectiveIdleTimeout(ws *workspacev1alpha1.Workspace, operatorDefault time.Duration) time.Duration {
@@ -652,14 +2287,24 @@ func isPodReady(pod *corev1.Pod) bool {
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *WorkspaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorder("workspace-controller")
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&workspacev1alpha1.Workspace{}).
 		Owns(&corev1.Pod{}).
+		Owns(&appsv1.Deployment{}).
 		Owns(&corev1.PersistentVolumeClaim{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.ResourceQuota{}).
 		Owns(&corev1.Service{}).
 		Owns(&corev1.ServiceAccount{}).
 		Owns(&rbacv1.Role{}).
 		Owns(&rbacv1.RoleBinding{}).
 		Owns(&networkingv1.NetworkPolicy{}).
+		Owns(&networkingv1.Ingress{}).
+		// CA bundle ConfigMaps are referenced, not owned; changes recreate the pods using them.
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.workspacesForCABundle)).
+		Watches(&workspacev1alpha1.WorkspaceTemplate{}, handler.EnqueueRequestsFromMapFunc(r.workspacesForTemplate)).
 		Complete(r)
 }
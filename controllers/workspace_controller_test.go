@@ -2,27 +2,49 @@ package controllers
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"maps"
+	"math/big"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/events"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	workspacev1alpha1 "workspace-operator/api/v1alpha1"
+	"workspace-operator/pkg/observability"
 	"workspace-operator/pkg/workspace"
 )
 
@@ -387,6 +409,58 @@ func TestSetupWithManager_Integration(t *testing.T) {
 	}
 }
 
+func TestWorkspaceCRD_PrinterColumns_Integration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	env := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "config", "crd", "bases")},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := env.Start()
+	if err != nil {
+		t.Fatalf("Failed to start envtest: %v", err)
+	}
+	defer func() {
+		if err := env.Stop(); err != nil {
+			t.Errorf("Failed to stop envtest: %v", err)
+		}
+	}()
+
+	k8sClient, err := client.New(cfg, client.Options{Scheme: testScheme})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	// Read the CRD as served by the API server, unstructured so the test needs
+	// no apiextensions types.
+	crd := &unstructured.Unstructured{}
+	crd.SetGroupVersionKind(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"})
+	if err := k8sClient.Get(context.Background(), client.ObjectKey{Name: "workspaces.workspace.devplane.io"}, crd); err != nil {
+		t.Fatalf("Get CRD: %v", err)
+	}
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	if len(versions) == 0 {
+		t.Fatal("CRD has no versions")
+	}
+	cols, _, _ := unstructured.NestedSlice(versions[0].(map[string]any), "additionalPrinterColumns")
+	got := map[string]string{}
+	for _, c := range cols {
+		col := c.(map[string]any)
+		got[col["name"].(string)] = col["jsonPath"].(string)
+	}
+	want := map[string]string{
+		"Phase":         ".status.phase",
+		"Pod":           ".status.podName",
+		"Endpoint":      ".status.serviceEndpoint",
+		"Last Accessed": ".status.lastAccessed",
+		"Age":           ".metadata.creationTimestamp",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("printer columns = %v, want %v", got, want)
+	}
+}
+
 // ── Fake-client unit tests (no envtest / etcd required) ──────────────────────
 //
 // These tests cover controller branches that the envtest integration tests do
@@ -432,99 +506,1734 @@ func newFakeReconciler(t *testing.T, objs ...client.Object) (*WorkspaceReconcile
 	}, fc
 }
 
-// reconcileNN is a convenience that issues one Reconcile call for the named object.
-func reconcileNN(t *testing.T, r *WorkspaceReconciler, nn types.NamespacedName) {
-	t.Helper()
-	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: nn}); err != nil {
-		t.Fatalf("Reconcile: %v", err)
+// reconcileNN is a convenience that issues one Reconcile call for the named object.
+func reconcileNN(t *testing.T, r *WorkspaceReconciler, nn types.NamespacedName) {
+	t.Helper()
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: nn}); err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+}
+
+// getWS fetches the workspace or fatals the test.
+func getWS(t *testing.T, fc client.Client, nn types.NamespacedName) workspacev1alpha1.Workspace {
+	t.Helper()
+	var ws workspacev1alpha1.Workspace
+	if err := fc.Get(context.Background(), nn, &ws); err != nil {
+		t.Fatalf("Get Workspace: %v", err)
+	}
+	return ws
+}
+
+func TestReconcile_StorageBelowFloor_SetsFailedStatus(t *testing.T) {
+	ws := wsWithFinalizer("tiny-pvc-ws", "tina")
+	ws.Spec.Resources.Storage = "1Mi"
+	r, fc := newFakeReconciler(t, ws)
+	r.MinStorage = resource.MustParse("1Gi")
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	stored := getWS(t, fc, nn)
+	if stored.Status.Phase != workspacev1alpha1.WorkspacePhaseFailed {
+		t.Errorf("status.phase = %q, want Failed", stored.Status.Phase)
+	}
+	if !strings.Contains(stored.Status.Message, "minimum") {
+		t.Errorf("status.message = %q, want storage floor message", stored.Status.Message)
+	}
+	var pvcList corev1.PersistentVolumeClaimList
+	if err := fc.List(context.Background(), &pvcList, client.InNamespace("default")); err != nil {
+		t.Fatal(err)
+	}
+	if len(pvcList.Items) != 0 {
+		t.Errorf("expected no PVC for below-floor workspace, got %d", len(pvcList.Items))
+	}
+}
+
+func TestReconcile_ProvidersTooLarge_SetsFailedStatus(t *testing.T) {
+	ws := wsWithFinalizer("big-providers-ws", "uma")
+	r, fc := newFakeReconciler(t, ws)
+	r.MaxProvidersJSONBytes = 32
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	stored := getWS(t, fc, nn)
+	if stored.Status.Phase != workspacev1alpha1.WorkspacePhaseFailed {
+		t.Errorf("status.phase = %q, want Failed", stored.Status.Phase)
+	}
+	if !strings.Contains(stored.Status.Message, "operator limit") {
+		t.Errorf("status.message = %q, want provider size message", stored.Status.Message)
+	}
+}
+
+func TestReconcile_StorageAtFloor_CreatesPVC(t *testing.T) {
+	ws := wsWithFinalizer("floor-pvc-ws", "tom")
+	r, fc := newFakeReconciler(t, ws)
+	r.MinStorage = resource.MustParse(ws.Spec.Resources.Storage)
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	var pvc corev1.PersistentVolumeClaim
+	if err := fc.Get(context.Background(), types.NamespacedName{Name: "tom-workspace-pvc", Namespace: "default"}, &pvc); err != nil {
+		t.Fatalf("expected PVC for at-floor workspace: %v", err)
+	}
+}
+
+func TestReconcile_FreezeConfigMap_PausesAndResumes(t *testing.T) {
+	ws := wsWithFinalizer("frozen-ws", "ivan")
+	freeze := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "devplane-freeze", Namespace: "operator-system"},
+		Data:       map[string]string{FreezeConfigMapKey: "true"},
+	}
+	r, fc := newFakeReconciler(t, ws, freeze)
+	r.FreezeConfigMap = types.NamespacedName{Name: freeze.Name, Namespace: freeze.Namespace}
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	res, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: nn})
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if res.RequeueAfter <= 0 {
+		t.Errorf("frozen Reconcile should requeue later, got %+v", res)
+	}
+	pvcKey := types.NamespacedName{Name: "ivan-workspace-pvc", Namespace: "default"}
+	var pvc corev1.PersistentVolumeClaim
+	if err := fc.Get(context.Background(), pvcKey, &pvc); err == nil {
+		t.Error("PVC must not be created while frozen")
+	}
+	var sa corev1.ServiceAccount
+	if err := fc.Get(context.Background(), types.NamespacedName{Name: "ivan-workspace", Namespace: "default"}, &sa); err == nil {
+		t.Error("ServiceAccount must not be created while frozen")
+	}
+	if stored := getWS(t, fc, nn); stored.Status.Phase != "" {
+		t.Errorf("status.phase = %q, want unchanged (empty) while frozen", stored.Status.Phase)
+	}
+
+	freeze.Data[FreezeConfigMapKey] = "false"
+	if err := fc.Update(context.Background(), freeze); err != nil {
+		t.Fatalf("Update freeze ConfigMap: %v", err)
+	}
+	reconcileNN(t, r, nn)
+	if err := fc.Get(context.Background(), pvcKey, &pvc); err != nil {
+		t.Errorf("PVC should be created after unfreezing: %v", err)
+	}
+}
+
+func TestReconcile_FrozenFlag_SkipsDeletion(t *testing.T) {
+	ctx := context.Background()
+	ws := wsWithFinalizer("frozen-delete-ws", "judy")
+	r, fc := newFakeReconciler(t, ws)
+	r.Frozen = true
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	stored := getWS(t, fc, nn)
+	if err := fc.Delete(ctx, &stored); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	reconcileNN(t, r, nn)
+
+	stored = getWS(t, fc, nn)
+	if len(stored.Finalizers) != 1 {
+		t.Errorf("finalizers = %v, want finalizer kept while frozen", stored.Finalizers)
+	}
+}
+
+func TestReconcile_StoppedPhase(t *testing.T) {
+	ws := wsWithFinalizer("stopped-ws", "alice")
+	ws.Status.Phase = workspacev1alpha1.WorkspacePhaseStopped
+	r, fc := newFakeReconciler(t, ws)
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	// No PVC should have been created (reconcile returns early for Stopped).
+	var pvcList corev1.PersistentVolumeClaimList
+	if err := fc.List(context.Background(), &pvcList, client.InNamespace("default")); err != nil {
+		t.Fatal(err)
+	}
+	if len(pvcList.Items) != 0 {
+		t.Errorf("expected no PVCs for stopped workspace, got %d", len(pvcList.Items))
+	}
+}
+
+func TestReconcile_RecordsLastReconcile(t *testing.T) {
+	ws := wsWithFinalizer("record-ws", "rita")
+	ws.Status.Phase = workspacev1alpha1.WorkspacePhaseStopped
+	before := metav1.NewTime(time.Now().Add(-2 * time.Hour).Truncate(time.Second))
+	ws.Status.LastReconcileTime = before
+	ws.Status.LastReconcileResult = "Error: stale"
+	r, fc := newFakeReconciler(t, ws)
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	stored := getWS(t, fc, nn)
+	if !stored.Status.LastReconcileTime.After(before.Time) {
+		t.Errorf("status.lastReconcileTime = %v, want after %v", stored.Status.LastReconcileTime, before)
+	}
+	if stored.Status.LastReconcileResult != workspace.ReconcileResultSuccess {
+		t.Errorf("status.lastReconcileResult = %q, want %q", stored.Status.LastReconcileResult, workspace.ReconcileResultSuccess)
+	}
+}
+
+func TestReconcile_Delete(t *testing.T) {
+	ctx := context.Background()
+	ws := wsWithFinalizer("del-ws", "bob")
+	r, fc := newFakeReconciler(t, ws)
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+
+	// Delete workspace — fake client sets DeletionTimestamp because finalizers are present.
+	var stored workspacev1alpha1.Workspace
+	if err := fc.Get(ctx, nn, &stored); err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if err := fc.Delete(ctx, &stored); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	// Reconcile should call reconcileDelete, removing the finalizer.
+	reconcileNN(t, r, nn)
+
+	// After removing the last finalizer the fake client deletes the object.
+	var ws2 workspacev1alpha1.Workspace
+	if err := fc.Get(ctx, nn, &ws2); err == nil {
+		// If found (unlikely), at least the finalizer must be gone.
+		if len(ws2.Finalizers) != 0 {
+			t.Errorf("expected no finalizers after delete, got %v", ws2.Finalizers)
+		}
+	}
+}
+
+func TestReconcile_StuckTerminatingPod_ForceDeleted(t *testing.T) {
+	ws := wsWithFinalizer("stuck-ws", "sam")
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "sam-workspace-pvc", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	terminatingSince := metav1.NewTime(time.Now().Add(-time.Hour))
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "sam-workspace-pod",
+			Namespace:         "default",
+			DeletionTimestamp: &terminatingSince,
+			// The fake client only accepts a terminating object that has finalizers.
+			Finalizers: []string{"example.com/hold"},
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "workspace", Image: "workspace:test"}},
+		},
+	}
+
+	var forceDeletes int
+	fc := fake.NewClientBuilder().
+		WithScheme(testScheme).
+		WithStatusSubresource(&workspacev1alpha1.Workspace{}).
+		WithObjects(ws, pvc, pod).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+				delOpts := &client.DeleteOptions{}
+				delOpts.ApplyOptions(opts)
+				if _, ok := obj.(*corev1.Pod); ok && delOpts.GracePeriodSeconds != nil && *delOpts.GracePeriodSeconds == 0 {
+					forceDeletes++
+				}
+				return c.Delete(ctx, obj, opts...)
+			},
+		}).
+		Build()
+	r := &WorkspaceReconciler{
+		Client:                  fc,
+		Scheme:                  testScheme,
+		WorkspaceImage:          "workspace:test",
+		StuckTerminatingTimeout: 2 * time.Hour,
+	}
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+	if forceDeletes != 0 {
+		t.Fatalf("force deletes = %d before threshold, want 0", forceDeletes)
+	}
+
+	r.StuckTerminatingTimeout = 10 * time.Minute
+	reconcileNN(t, r, nn)
+	if forceDeletes != 1 {
+		t.Errorf("force deletes = %d after threshold, want 1", forceDeletes)
+	}
+}
+
+func TestUpdateStatus_RetriesOnConflict(t *testing.T) {
+	ws := wsWithFinalizer("conflict-ws", "quinn")
+	var patches int
+	fc := fake.NewClientBuilder().
+		WithScheme(testScheme).
+		WithStatusSubresource(&workspacev1alpha1.Workspace{}).
+		WithObjects(ws).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourcePatch: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+				patches++
+				return c.SubResource(subResourceName).Patch(ctx, obj, patch, opts...)
+			},
+		}).
+		Build()
+	r := &WorkspaceReconciler{Client: fc, Scheme: testScheme, WorkspaceImage: "workspace:test"}
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	var current workspacev1alpha1.Workspace
+	if err := fc.Get(context.Background(), nn, &current); err != nil {
+		t.Fatal(err)
+	}
+	// Someone else (e.g. the gateway) writes the status after our read, so the
+	// first patch is stale.
+	lastAccessed := metav1.NewTime(time.Now().Truncate(time.Second))
+	other := current.DeepCopy()
+	other.Status.LastAccessed = lastAccessed
+	if err := fc.Status().Update(context.Background(), other); err != nil {
+		t.Fatal(err)
+	}
+	err := r.updateStatus(context.Background(), &current, workspace.StatusSummary{
+		Phase:   workspacev1alpha1.WorkspacePhaseRunning,
+		Message: "ready",
+	})
+	if err != nil {
+		t.Fatalf("updateStatus: %v", err)
+	}
+	if patches != 2 {
+		t.Errorf("status patches = %d, want 2", patches)
+	}
+	got := getWS(t, fc, nn)
+	if got.Status.Phase != workspacev1alpha1.WorkspacePhaseRunning {
+		t.Errorf("phase = %q, want Running", got.Status.Phase)
+	}
+	if !got.Status.LastAccessed.Equal(&lastAccessed) {
+		t.Errorf("lastAccessed = %v, want the concurrent write %v kept", got.Status.LastAccessed, lastAccessed)
+	}
+}
+
+func TestReconcile_PVCPendingImmediateBinding(t *testing.T) {
+	ctx := context.Background()
+	className := "fast"
+	immediate := storagev1.VolumeBindingImmediate
+	sc := &storagev1.StorageClass{
+		ObjectMeta:        metav1.ObjectMeta{Name: className},
+		Provisioner:       "example.com/csi",
+		VolumeBindingMode: &immediate,
+	}
+	ws := wsWithFinalizer("pending-pvc-ws", "morgan")
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "morgan-workspace-pvc",
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-5 * time.Minute)),
+		},
+		Spec:   corev1.PersistentVolumeClaimSpec{StorageClassName: &className},
+		Status: corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending},
+	}
+	r, fc := newFakeReconciler(t, sc, ws, pvc)
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: nn})
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if res.RequeueAfter != pvcPendingRequeueInterval {
+		t.Errorf("RequeueAfter = %v, want %v", res.RequeueAfter, pvcPendingRequeueInterval)
+	}
+	var pod corev1.Pod
+	if err := fc.Get(ctx, types.NamespacedName{Name: "morgan-workspace-pod", Namespace: "default"}, &pod); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected no pod while Immediate PVC is Pending, got err=%v", err)
+	}
+	stored := getWS(t, fc, nn)
+	if stored.Status.RemediationHint != workspace.RemediationPVCPending {
+		t.Errorf("remediationHint = %q, want PVC pending hint", stored.Status.RemediationHint)
+	}
+	if !strings.Contains(stored.Status.Message, "still Pending") {
+		t.Errorf("message = %q, want it to report the Pending PVC", stored.Status.Message)
+	}
+
+	// WaitForFirstConsumer binds only once the pod is scheduled, so the pod is created.
+	var storedSC storagev1.StorageClass
+	if err := fc.Get(ctx, types.NamespacedName{Name: className}, &storedSC); err != nil {
+		t.Fatalf("Get StorageClass: %v", err)
+	}
+	waitForConsumer := storagev1.VolumeBindingWaitForFirstConsumer
+	storedSC.VolumeBindingMode = &waitForConsumer
+	if err := fc.Update(ctx, &storedSC); err != nil {
+		t.Fatalf("Update StorageClass: %v", err)
+	}
+	reconcileNN(t, r, nn)
+	if err := fc.Get(ctx, types.NamespacedName{Name: "morgan-workspace-pod", Namespace: "default"}, &pod); err != nil {
+		t.Fatalf("expected pod for WaitForFirstConsumer class: %v", err)
+	}
+}
+
+// TestUnschedulableExtendedResources lists fake nodes without a schedulable GPU
+// and expects a warning naming the missing resource, then clears once a node
+// with enough GPUs exists.
+func TestUnschedulableExtendedResources(t *testing.T) {
+	ctx := context.Background()
+	gpu := corev1.ResourceName("nvidia.com/gpu")
+	cpuNode := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "cpu-only"},
+		Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+			corev1.ResourceCPU: resource.MustParse("8"),
+		}},
+	}
+	cordoned := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "cordoned-gpu"},
+		Spec:       corev1.NodeSpec{Unschedulable: true},
+		Status:     corev1.NodeStatus{Allocatable: corev1.ResourceList{gpu: resource.MustParse("4")}},
+	}
+	pod := &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+		Name: "workspace",
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+			Limits:   corev1.ResourceList{gpu: resource.MustParse("1")},
+		},
+	}}}}
+
+	r, fc := newFakeReconciler(t, cpuNode, cordoned)
+	msg := r.unschedulableExtendedResources(ctx, pod)
+	if !strings.Contains(msg, "nvidia.com/gpu: 1") {
+		t.Errorf("message = %q, want it to name nvidia.com/gpu: 1", msg)
+	}
+	if got := r.unschedulableExtendedResources(ctx, &corev1.Pod{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "workspace"}}}}); got != "" {
+		t.Errorf("pod without extended resources: message = %q, want empty", got)
+	}
+
+	gpuNode := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "gpu"},
+		Status:     corev1.NodeStatus{Allocatable: corev1.ResourceList{gpu: resource.MustParse("1")}},
+	}
+	if err := fc.Create(ctx, gpuNode); err != nil {
+		t.Fatalf("create node: %v", err)
+	}
+	if got := r.unschedulableExtendedResources(ctx, pod); got != "" {
+		t.Errorf("with a GPU node: message = %q, want empty", got)
+	}
+}
+
+// TestReconcile_UnschedulableGPUStillCreatesPod checks that a GPU request no
+// node can satisfy only raises a Warning event: cluster-autoscaler needs the
+// Pending pod to scale a GPU node group up from zero.
+func TestReconcile_UnschedulableGPUStillCreatesPod(t *testing.T) {
+	ws := wsWithFinalizer("gpu-ws", "gina")
+	ws.Spec.Resources.GPU = &workspacev1alpha1.GPUResource{Count: 1}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "gina-workspace-pvc", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	cpuNode := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "cpu-only"}}
+	r, fc := newFakeReconciler(t, ws, pvc, cpuNode)
+	recorder := events.NewFakeRecorder(10)
+	r.Recorder = recorder
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	var pod corev1.Pod
+	if err := fc.Get(context.Background(), types.NamespacedName{Name: "gina-workspace-pod", Namespace: "default"}, &pod); err != nil {
+		t.Fatalf("Get Pod: %v", err)
+	}
+	select {
+	case e := <-recorder.Events:
+		if !strings.HasPrefix(e, corev1.EventTypeWarning+" "+workspace.ReasonInsufficientResources) || !strings.Contains(e, "nvidia.com/gpu") {
+			t.Errorf("event = %q, want Warning InsufficientResources naming nvidia.com/gpu", e)
+		}
+	default:
+		t.Error("expected a Warning event")
+	}
+}
+
+func TestReconcile_UnschedulableGPUReportedInStatus(t *testing.T) {
+	ws := wsWithFinalizer("gpu-ws", "gina")
+	ws.Spec.Resources.GPU = &workspacev1alpha1.GPUResource{Count: 1}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "gina-workspace-pvc", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	cpuNode := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "cpu-only"}}
+	// No Recorder: the status must carry the warning on its own.
+	r, fc := newFakeReconciler(t, ws, pvc, cpuNode)
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+	var pod corev1.Pod
+	podKey := types.NamespacedName{Name: "gina-workspace-pod", Namespace: "default"}
+	if err := fc.Get(context.Background(), podKey, &pod); err != nil {
+		t.Fatalf("Get Pod: %v", err)
+	}
+	pod.Status.Phase = corev1.PodPending
+	if err := fc.Status().Update(context.Background(), &pod); err != nil {
+		t.Fatalf("update pod status: %v", err)
+	}
+	reconcileNN(t, r, nn)
+
+	got := getWS(t, fc, nn)
+	if got.Status.Phase != workspacev1alpha1.WorkspacePhaseCreating {
+		t.Errorf("phase = %q, want Creating", got.Status.Phase)
+	}
+	if !strings.Contains(got.Status.Message, "nvidia.com/gpu") {
+		t.Errorf("message = %q, want it to name nvidia.com/gpu", got.Status.Message)
+	}
+	cond := meta.FindStatusCondition(got.Status.Conditions, workspace.ConditionTypeReady)
+	if cond == nil || cond.Reason != workspace.ReasonInsufficientResources {
+		t.Errorf("Ready condition = %+v, want reason %s", cond, workspace.ReasonInsufficientResources)
+	}
+
+	// Once the pod is scheduled the warning clears.
+	if err := fc.Get(context.Background(), podKey, &pod); err != nil {
+		t.Fatalf("Get Pod: %v", err)
+	}
+	pod.Spec.NodeName = "gpu-node"
+	if err := fc.Update(context.Background(), &pod); err != nil {
+		t.Fatalf("update pod: %v", err)
+	}
+	reconcileNN(t, r, nn)
+	got = getWS(t, fc, nn)
+	if strings.Contains(got.Status.Message, "nvidia.com/gpu") {
+		t.Errorf("message = %q, want the warning cleared once the pod is scheduled", got.Status.Message)
+	}
+}
+
+func TestReconcile_CABundleChangeRecreatesPod(t *testing.T) {
+	ctx := context.Background()
+	ws := wsWithFinalizer("ca-ws", "casey")
+	ws.Spec.TLS.CustomCABundle = &workspacev1alpha1.CABundleRef{Name: "corp-ca"}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "casey-workspace-pvc", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	ca := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "corp-ca", Namespace: "default"},
+		Data:       map[string]string{"ca.crt": "-----BEGIN CERTIFICATE-----\nold\n"},
+	}
+	r, fc := newFakeReconciler(t, ws, pvc, ca)
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	podKey := types.NamespacedName{Name: "casey-workspace-pod", Namespace: "default"}
+	reconcileNN(t, r, nn)
+	var pod corev1.Pod
+	if err := fc.Get(ctx, podKey, &pod); err != nil {
+		t.Fatalf("Get Pod: %v", err)
+	}
+	oldHash := pod.Annotations[workspace.CABundleHashAnnotation]
+	if oldHash == "" {
+		t.Fatal("expected CA bundle hash annotation on the pod")
+	}
+
+	// An unchanged bundle keeps the pod.
+	reconcileNN(t, r, nn)
+	if err := fc.Get(ctx, podKey, &pod); err != nil {
+		t.Fatalf("pod should survive a reconcile with an unchanged CA bundle: %v", err)
+	}
+
+	var storedCA corev1.ConfigMap
+	if err := fc.Get(ctx, types.NamespacedName{Name: "corp-ca", Namespace: "default"}, &storedCA); err != nil {
+		t.Fatalf("Get ConfigMap: %v", err)
+	}
+	storedCA.Data["ca.crt"] = "-----BEGIN CERTIFICATE-----\nnew\n"
+	if err := fc.Update(ctx, &storedCA); err != nil {
+		t.Fatalf("Update ConfigMap: %v", err)
+	}
+
+	reconcileNN(t, r, nn)
+	if err := fc.Get(ctx, podKey, &pod); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected pod to be deleted after CA change, got err=%v", err)
+	}
+	reconcileNN(t, r, nn)
+	if err := fc.Get(ctx, podKey, &pod); err != nil {
+		t.Fatalf("expected pod to be recreated: %v", err)
+	}
+	if got := pod.Annotations[workspace.CABundleHashAnnotation]; got == "" || got == oldHash {
+		t.Errorf("recreated pod CA hash = %q, want a new hash (old %q)", got, oldHash)
+	}
+}
+
+// selfSignedPEM returns a throwaway self-signed CA certificate in PEM form.
+func selfSignedPEM(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestReconcile_CABundleValidation(t *testing.T) {
+	ws := wsWithFinalizer("ca-check-ws", "quinn")
+	ws.Spec.TLS.CustomCABundle = &workspacev1alpha1.CABundleRef{Name: "corp-ca"}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "quinn-workspace-pvc", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	ca := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "corp-ca", Namespace: "default"},
+		Data: map[string]string{
+			"good.crt": selfSignedPEM(t),
+			"bad.crt":  "not a certificate",
+		},
+	}
+	r, fc := newFakeReconciler(t, ws, pvc, ca)
+	r.ValidateCABundle = true
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	cond := meta.FindStatusCondition(getWS(t, fc, nn).Status.Conditions, workspace.ConditionTypeCABundleValid)
+	if cond == nil {
+		t.Fatal("expected CABundleValid condition")
+	}
+	if cond.Status != metav1.ConditionFalse || cond.Reason != workspace.ReasonCABundleInvalid {
+		t.Errorf("condition = %s/%s, want False/%s", cond.Status, cond.Reason, workspace.ReasonCABundleInvalid)
+	}
+	if !strings.Contains(cond.Message, "bad.crt") || strings.Contains(cond.Message, "good.crt") {
+		t.Errorf("condition message = %q, want only bad.crt named", cond.Message)
+	}
+
+	// Fixing the bad key flips the condition back to True.
+	var storedCA corev1.ConfigMap
+	if err := fc.Get(context.Background(), types.NamespacedName{Name: "corp-ca", Namespace: "default"}, &storedCA); err != nil {
+		t.Fatalf("Get ConfigMap: %v", err)
+	}
+	storedCA.Data["bad.crt"] = selfSignedPEM(t)
+	if err := fc.Update(context.Background(), &storedCA); err != nil {
+		t.Fatalf("Update ConfigMap: %v", err)
+	}
+	reconcileNN(t, r, nn)
+	cond = meta.FindStatusCondition(getWS(t, fc, nn).Status.Conditions, workspace.ConditionTypeCABundleValid)
+	if cond == nil || cond.Status != metav1.ConditionTrue {
+		t.Errorf("condition = %+v, want True after fixing the bundle", cond)
+	}
+}
+
+func TestReconcile_APIServerEgress(t *testing.T) {
+	ctx := context.Background()
+	apiPort := int32(6443)
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kubernetes",
+			Namespace: "default",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "kubernetes"},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints:   []discoveryv1.Endpoint{{Addresses: []string{"172.18.0.2"}}},
+		Ports:       []discoveryv1.EndpointPort{{Port: &apiPort}},
+	}
+	ws := wsWithFinalizer("api-egress-ws", "alex")
+	r, fc := newFakeReconciler(t, ws, slice)
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	egressKey := types.NamespacedName{Name: "alex-workspace-egress", Namespace: "default"}
+
+	apiRule := func() *networkingv1.NetworkPolicyEgressRule {
+		t.Helper()
+		var np networkingv1.NetworkPolicy
+		if err := fc.Get(ctx, egressKey, &np); err != nil {
+			t.Fatalf("Get egress NetworkPolicy: %v", err)
+		}
+		for i, rule := range np.Spec.Egress {
+			if len(rule.To) > 0 && rule.To[0].IPBlock != nil && rule.To[0].IPBlock.CIDR == "172.18.0.2/32" {
+				return &np.Spec.Egress[i]
+			}
+		}
+		return nil
+	}
+
+	reconcileNN(t, r, nn)
+	if apiRule() != nil {
+		t.Fatal("API server egress rule must be absent by default")
+	}
+
+	r.APIServerEgress = true
+	reconcileNN(t, r, nn)
+	rule := apiRule()
+	if rule == nil {
+		t.Fatal("expected API server egress rule when enabled")
+	}
+	var ports []int32
+	for _, p := range rule.Ports {
+		ports = append(ports, p.Port.IntVal)
+	}
+	if len(ports) != 2 || ports[0] != 443 || ports[1] != 6443 {
+		t.Errorf("API server rule ports = %v, want [443 6443]", ports)
+	}
+}
+
+// TestReconcile_ClusterInspection checks that spec.clusterInspection opens API
+// server egress together with the read-only Role and RoleBinding, and that
+// turning it off closes the egress again.
+func TestReconcile_ClusterInspection(t *testing.T) {
+	ctx := context.Background()
+	ws := wsWithFinalizer("inspect-ws", "ines")
+	ws.Spec.ClusterInspection = true
+	r, fc := newFakeReconciler(t, ws)
+	r.APIServerCIDRs = []string{"10.96.0.1/32"}
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	saName := workspace.ServiceAccountName("ines")
+
+	hasAPIRule := func() bool {
+		t.Helper()
+		var np networkingv1.NetworkPolicy
+		if err := fc.Get(ctx, types.NamespacedName{Name: "ines-workspace-egress", Namespace: "default"}, &np); err != nil {
+			t.Fatalf("Get egress NetworkPolicy: %v", err)
+		}
+		for _, rule := range np.Spec.Egress {
+			if len(rule.To) > 0 && rule.To[0].IPBlock != nil && rule.To[0].IPBlock.CIDR == "10.96.0.1/32" {
+				return true
+			}
+		}
+		return false
+	}
+
+	reconcileNN(t, r, nn)
+	if !hasAPIRule() {
+		t.Error("expected API server egress rule with clusterInspection enabled")
+	}
+	var role rbacv1.Role
+	if err := fc.Get(ctx, types.NamespacedName{Name: saName, Namespace: "default"}, &role); err != nil {
+		t.Fatalf("Get Role: %v", err)
+	}
+	if len(role.Rules) == 0 {
+		t.Error("read-only Role has no rules")
+	}
+	var rb rbacv1.RoleBinding
+	if err := fc.Get(ctx, types.NamespacedName{Name: saName, Namespace: "default"}, &rb); err != nil {
+		t.Fatalf("Get RoleBinding: %v", err)
+	}
+	if rb.RoleRef.Name != role.Name {
+		t.Errorf("RoleBinding references %q, want %q", rb.RoleRef.Name, role.Name)
+	}
+
+	cur := getWS(t, fc, nn)
+	cur.Spec.ClusterInspection = false
+	if err := fc.Update(ctx, &cur); err != nil {
+		t.Fatalf("Update Workspace: %v", err)
+	}
+	reconcileNN(t, r, nn)
+	if hasAPIRule() {
+		t.Error("API server egress rule must be removed when clusterInspection is disabled")
+	}
+}
+
+func TestReconcile_PVCLost(t *testing.T) {
+	ws := wsWithFinalizer("pvc-lost-ws", "charlie")
+
+	// Pre-create a PVC with Lost status (not a status subresource → status stored directly).
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "charlie-workspace-pvc",
+			Namespace: "default",
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Phase: corev1.ClaimLost,
+		},
+	}
+	r, fc := newFakeReconciler(t, ws, pvc)
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	stored := getWS(t, fc, nn)
+	if stored.Status.Phase != workspacev1alpha1.WorkspacePhaseFailed {
+		t.Errorf("status.phase = %q, want Failed", stored.Status.Phase)
+	}
+	if stored.Status.RemediationHint == "" {
+		t.Error("expected non-empty remediationHint for ClaimLost PVC")
+	}
+	cond := meta.FindStatusCondition(stored.Status.Conditions, workspace.ConditionTypeReady)
+	if cond == nil || cond.Reason != workspace.ReasonPVCLost {
+		t.Fatalf("Ready condition: %#v", cond)
+	}
+}
+
+func TestReconcile_BoundPVC_RecordsStorageCapacity(t *testing.T) {
+	ws := wsWithFinalizer("capacity-ws", "carol")
+
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "carol-workspace-pvc", Namespace: "default"},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Phase: corev1.ClaimBound,
+			Capacity: corev1.ResourceList{
+				corev1.ResourceStorage: resource.MustParse("25Gi"),
+			},
+		},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "carol-workspace-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "workspace", Image: "workspace:test"}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodPending},
+	}
+	r, fc := newFakeReconciler(t, ws, pvc, pod)
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	stored := getWS(t, fc, nn)
+	if stored.Status.StorageCapacity != "25Gi" {
+		t.Errorf("status.storageCapacity = %q, want 25Gi", stored.Status.StorageCapacity)
+	}
+	if stored.Status.Phase != workspacev1alpha1.WorkspacePhaseCreating {
+		t.Errorf("status.phase = %q, want Creating", stored.Status.Phase)
+	}
+}
+
+func TestReconcile_AISettingsConfigMap_CreatedAndUpdated(t *testing.T) {
+	ws := wsWithFinalizer("ai-settings-ws", "oscar")
+	ws.Spec.AIConfig.Settings = workspacev1alpha1.AISettings{Temperature: "0.2", SystemPrompt: "Be terse."}
+
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "oscar-workspace-pvc", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	r, fc := newFakeReconciler(t, ws, pvc)
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	cmKey := types.NamespacedName{Name: workspace.AISettingsConfigMapName("oscar"), Namespace: "default"}
+	var cm corev1.ConfigMap
+	if err := fc.Get(context.Background(), cmKey, &cm); err != nil {
+		t.Fatalf("Get AI settings ConfigMap: %v", err)
+	}
+	if got := cm.Data[workspace.AISettingsKey]; got != `{"temperature":0.2,"systemPrompt":"Be terse."}` {
+		t.Errorf("ConfigMap data = %s", got)
+	}
+	if len(cm.OwnerReferences) != 1 || cm.OwnerReferences[0].Kind != "Workspace" {
+		t.Errorf("expected Workspace owner reference, got %v", cm.OwnerReferences)
+	}
+
+	stored := getWS(t, fc, nn)
+	stored.Spec.AIConfig.Settings.Temperature = "0.7"
+	if err := fc.Update(context.Background(), &stored); err != nil {
+		t.Fatalf("Update Workspace: %v", err)
+	}
+	reconcileNN(t, r, nn)
+
+	if err := fc.Get(context.Background(), cmKey, &cm); err != nil {
+		t.Fatalf("Get AI settings ConfigMap: %v", err)
+	}
+	if got := cm.Data[workspace.AISettingsKey]; got != `{"temperature":0.7,"systemPrompt":"Be terse."}` {
+		t.Errorf("ConfigMap data after update = %s", got)
+	}
+}
+
+func TestReconcile_ResourceQuota_DerivedFromSpec(t *testing.T) {
+	ws := wsWithFinalizer("quota-ws", "quinn")
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "quinn-workspace-pvc", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	r, fc := newFakeReconciler(t, ws, pvc)
+	r.ResourceQuota = true
+	r.ResourceQuotaHeadroomPercent = 25
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	var quota corev1.ResourceQuota
+	quotaKey := types.NamespacedName{Name: workspace.ResourceQuotaName("quinn"), Namespace: "default"}
+	if err := fc.Get(context.Background(), quotaKey, &quota); err != nil {
+		t.Fatalf("Get ResourceQuota: %v", err)
+	}
+	// Spec is 100m CPU / 128Mi memory / 1Gi storage; 25% headroom on each.
+	want := map[corev1.ResourceName]string{
+		corev1.ResourceRequestsCPU:     "125m",
+		corev1.ResourceLimitsCPU:       "125m",
+		corev1.ResourceRequestsMemory:  "160Mi",
+		corev1.ResourceLimitsMemory:    "160Mi",
+		corev1.ResourceRequestsStorage: "1280Mi",
+	}
+	for name, w := range want {
+		got, ok := quota.Spec.Hard[name]
+		if !ok {
+			t.Errorf("quota missing %s", name)
+			continue
+		}
+		if got.Cmp(resource.MustParse(w)) != 0 {
+			t.Errorf("quota %s = %s, want %s", name, got.String(), w)
+		}
+	}
+	if len(quota.OwnerReferences) != 1 || quota.OwnerReferences[0].Kind != "Workspace" {
+		t.Errorf("expected Workspace owner reference, got %v", quota.OwnerReferences)
+	}
+}
+
+func TestReconcile_ResourceQuota_DisabledByDefault(t *testing.T) {
+	ws := wsWithFinalizer("no-quota-ws", "nora")
+	r, fc := newFakeReconciler(t, ws)
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	var quotas corev1.ResourceQuotaList
+	if err := fc.List(context.Background(), &quotas, client.InNamespace("default")); err != nil {
+		t.Fatal(err)
+	}
+	if len(quotas.Items) != 0 {
+		t.Errorf("expected no ResourceQuota when disabled, got %d", len(quotas.Items))
+	}
+}
+
+func TestReconcile_Ingress(t *testing.T) {
+	ws := wsWithFinalizer("ingress-ws", "ingrid")
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "ingrid-workspace-pvc", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	r, fc := newFakeReconciler(t, ws, pvc)
+	r.Ingress = workspace.IngressOpts{
+		BaseDomain:    "workspaces.example.com",
+		ClassName:     "nginx",
+		ClusterIssuer: "letsencrypt",
+		AuthURL:       "https://auth.example.com/oauth2/auth",
+		AuthSignin:    "https://auth.example.com/oauth2/start",
+	}
+	r.IngressControllerNamespace = "ingress-nginx"
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	var ing networkingv1.Ingress
+	ingKey := types.NamespacedName{Name: workspace.IngressName("ingrid"), Namespace: "default"}
+	if err := fc.Get(context.Background(), ingKey, &ing); err != nil {
+		t.Fatalf("Get Ingress: %v", err)
+	}
+	if len(ing.Spec.Rules) != 1 || ing.Spec.Rules[0].Host != "ingrid.workspaces.example.com" {
+		t.Fatalf("rules = %+v, want host ingrid.workspaces.example.com", ing.Spec.Rules)
+	}
+	backend := ing.Spec.Rules[0].HTTP.Paths[0].Backend.Service
+	if backend == nil || backend.Name != workspace.ServiceName("ingrid") || backend.Port.Name != "ttyd" {
+		t.Errorf("backend = %+v, want %s port ttyd", backend, workspace.ServiceName("ingrid"))
+	}
+	if ing.Spec.IngressClassName == nil || *ing.Spec.IngressClassName != "nginx" {
+		t.Errorf("ingressClassName = %v, want nginx", ing.Spec.IngressClassName)
+	}
+	if got := ing.Annotations["cert-manager.io/cluster-issuer"]; got != "letsencrypt" {
+		t.Errorf("cluster-issuer annotation = %q, want letsencrypt", got)
+	}
+	if got := ing.Annotations[workspace.IngressAuthURLAnnotation]; got != "https://auth.example.com/oauth2/auth" {
+		t.Errorf("auth-url annotation = %q, want the configured auth URL", got)
+	}
+	if got := ing.Annotations[workspace.IngressAuthSigninAnnotation]; got != "https://auth.example.com/oauth2/start" {
+		t.Errorf("auth-signin annotation = %q, want the configured signin URL", got)
+	}
+	if len(ing.Spec.TLS) != 1 || ing.Spec.TLS[0].SecretName != workspace.IngressTLSSecretName("ingrid") {
+		t.Errorf("tls = %+v", ing.Spec.TLS)
+	}
+	if len(ing.OwnerReferences) != 1 || ing.OwnerReferences[0].Kind != "Workspace" {
+		t.Errorf("expected Workspace owner reference, got %v", ing.OwnerReferences)
+	}
+
+	var np networkingv1.NetworkPolicy
+	if err := fc.Get(context.Background(), types.NamespacedName{Name: "ingrid-workspace-ingress-gateway", Namespace: "default"}, &np); err != nil {
+		t.Fatalf("Get ingress-gateway NetworkPolicy: %v", err)
+	}
+	if from := np.Spec.Ingress[0].From; len(from) != 2 || from[1].NamespaceSelector.MatchLabels["kubernetes.io/metadata.name"] != "ingress-nginx" {
+		t.Errorf("ingress-gateway peers = %+v, want the ingress controller namespace added", from)
+	}
+}
+
+func TestReconcile_Ingress_DisabledByDefault(t *testing.T) {
+	ws := wsWithFinalizer("no-ingress-ws", "nina")
+	r, fc := newFakeReconciler(t, ws)
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	var ingresses networkingv1.IngressList
+	if err := fc.List(context.Background(), &ingresses, client.InNamespace("default")); err != nil {
+		t.Fatal(err)
+	}
+	if len(ingresses.Items) != 0 {
+		t.Errorf("expected no Ingress when disabled, got %d", len(ingresses.Items))
+	}
+}
+
+func TestReconcile_Ingress_WithoutAuthURLFails(t *testing.T) {
+	ws := wsWithFinalizer("open-ingress-ws", "olga")
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "olga-workspace-pvc", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	r, fc := newFakeReconciler(t, ws, pvc)
+	r.Ingress = workspace.IngressOpts{BaseDomain: "workspaces.example.com"}
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: nn}); err == nil {
+		t.Fatal("expected an error building an Ingress without an auth URL")
+	}
+
+	var ingresses networkingv1.IngressList
+	if err := fc.List(context.Background(), &ingresses, client.InNamespace("default")); err != nil {
+		t.Fatal(err)
+	}
+	if len(ingresses.Items) != 0 {
+		t.Errorf("expected no unauthenticated Ingress, got %d", len(ingresses.Items))
+	}
+}
+
+func TestReconcile_Ingress_DeletedWhenDisabled(t *testing.T) {
+	ws := wsWithFinalizer("ingress-off-ws", "ivan")
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "ivan-workspace-pvc", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	r, fc := newFakeReconciler(t, ws, pvc)
+	r.Ingress = workspace.IngressOpts{
+		BaseDomain: "workspaces.example.com",
+		AuthURL:    "https://auth.example.com/oauth2/auth",
+	}
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+	ingKey := types.NamespacedName{Name: workspace.IngressName("ivan"), Namespace: "default"}
+	if err := fc.Get(context.Background(), ingKey, &networkingv1.Ingress{}); err != nil {
+		t.Fatalf("Get Ingress: %v", err)
+	}
+
+	r.Ingress = workspace.IngressOpts{}
+	reconcileNN(t, r, nn)
+	if err := fc.Get(context.Background(), ingKey, &networkingv1.Ingress{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Get Ingress after disabling: err = %v, want NotFound", err)
+	}
+}
+
+func TestReconcile_ExternalDNSHostnameAnnotation(t *testing.T) {
+	ws, pvc, pod := idleRunningObjects("dns-ws", "dana")
+	r, fc := newFakeReconciler(t, ws, pvc, pod)
+	r.ExternalDNSHostname = "{user}.ws.example.com"
+
+	reconcileNN(t, r, types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace})
+
+	var svc corev1.Service
+	svcKey := types.NamespacedName{Name: workspace.ServiceName("dana"), Namespace: "default"}
+	if err := fc.Get(context.Background(), svcKey, &svc); err != nil {
+		t.Fatalf("Get Service: %v", err)
+	}
+	if got := svc.Annotations[workspace.ExternalDNSHostnameAnnotation]; got != "dana.ws.example.com" {
+		t.Errorf("external-dns hostname = %q, want dana.ws.example.com", got)
+	}
+}
+
+func TestReconcile_ServiceAnnotationRemovedFromSpec(t *testing.T) {
+	ctx := context.Background()
+	ws, pvc, pod := idleRunningObjects("svc-ann-ws", "sven")
+	ws.Spec.ServiceAnnotations = map[string]string{"team": "infra"}
+	r, fc := newFakeReconciler(t, ws, pvc, pod)
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	svcKey := types.NamespacedName{Name: workspace.ServiceName("sven"), Namespace: "default"}
+	var svc corev1.Service
+	if err := fc.Get(ctx, svcKey, &svc); err != nil {
+		t.Fatalf("Get Service: %v", err)
+	}
+	if svc.Annotations["team"] != "infra" {
+		t.Fatalf("annotations = %v, want team=infra", svc.Annotations)
+	}
+	svc.Annotations["example.com/foreign"] = "kept"
+	if err := fc.Update(ctx, &svc); err != nil {
+		t.Fatalf("Update Service: %v", err)
+	}
+
+	stored := getWS(t, fc, nn)
+	stored.Spec.ServiceAnnotations = nil
+	if err := fc.Update(ctx, &stored); err != nil {
+		t.Fatalf("Update Workspace: %v", err)
+	}
+	reconcileNN(t, r, nn)
+
+	if err := fc.Get(ctx, svcKey, &svc); err != nil {
+		t.Fatalf("Get Service: %v", err)
+	}
+	if _, ok := svc.Annotations["team"]; ok {
+		t.Errorf("annotations = %v, want team removed with the spec entry", svc.Annotations)
+	}
+	if svc.Annotations["example.com/foreign"] != "kept" {
+		t.Errorf("annotations = %v, want the annotation set by someone else kept", svc.Annotations)
+	}
+}
+
+// TestReconcile_RecreatesDeletedRBACWhileRunning deletes each RBAC object of a
+// Running workspace and checks the next reconcile recreates it: ensureRBAC
+// runs before the Running pod path returns.
+func TestReconcile_RecreatesDeletedRBACWhileRunning(t *testing.T) {
+	tests := []struct {
+		name string
+		obj  client.Object
+	}{
+		{name: "ServiceAccount", obj: &corev1.ServiceAccount{}},
+		{name: "Role", obj: &rbacv1.Role{}},
+		{name: "RoleBinding", obj: &rbacv1.RoleBinding{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			ws, pvc, pod := idleRunningObjects("rbac-ws", "rita")
+			r, fc := newFakeReconciler(t, ws, pvc, pod)
+			nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+			reconcileNN(t, r, nn)
+			if stored := getWS(t, fc, nn); stored.Status.Phase != workspacev1alpha1.WorkspacePhaseRunning {
+				t.Fatalf("status.phase = %q, want Running before the deletion", stored.Status.Phase)
+			}
+
+			key := types.NamespacedName{Name: workspace.ServiceAccountName("rita"), Namespace: "default"}
+			if err := fc.Get(ctx, key, tt.obj); err != nil {
+				t.Fatalf("Get %s: %v", tt.name, err)
+			}
+			if err := fc.Delete(ctx, tt.obj); err != nil {
+				t.Fatalf("Delete %s: %v", tt.name, err)
+			}
+
+			reconcileNN(t, r, nn)
+			if err := fc.Get(ctx, key, tt.obj); err != nil {
+				t.Fatalf("%s not recreated while Running: %v", tt.name, err)
+			}
+			if refs := tt.obj.GetOwnerReferences(); len(refs) != 1 || refs[0].Kind != "Workspace" {
+				t.Errorf("%s owner references = %v, want the Workspace", tt.name, refs)
+			}
+			if stored := getWS(t, fc, nn); stored.Status.Phase != workspacev1alpha1.WorkspacePhaseRunning {
+				t.Errorf("status.phase = %q, want Running after the RBAC repair", stored.Status.Phase)
+			}
+		})
+	}
+}
+
+func TestReconcile_ManagedResources(t *testing.T) {
+	ctx := context.Background()
+	ws := wsWithFinalizer("managed-ws", "mira")
+	r, fc := newFakeReconciler(t, ws)
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+
+	// First pass creates the PVC and waits for it to bind.
+	reconcileNN(t, r, nn)
+	var pvc corev1.PersistentVolumeClaim
+	if err := fc.Get(ctx, types.NamespacedName{Name: "mira-workspace-pvc", Namespace: "default"}, &pvc); err != nil {
+		t.Fatalf("Get PVC: %v", err)
+	}
+	if got := getWS(t, fc, nn).Status.ManagedResources; slices.ContainsFunc(got, func(m workspacev1alpha1.ManagedResource) bool {
+		return m.Kind == "Pod"
+	}) {
+		t.Errorf("managedResources = %v, pod must not be listed before it exists", got)
+	}
+
+	pvc.Status.Phase = corev1.ClaimBound
+	if err := fc.Update(ctx, &pvc); err != nil {
+		t.Fatalf("bind PVC: %v", err)
+	}
+	reconcileNN(t, r, nn) // creates the pod
+	reconcileNN(t, r, nn) // ensures the service
+
+	got := getWS(t, fc, nn).Status.ManagedResources
+	for _, want := range []workspacev1alpha1.ManagedResource{
+		{Kind: "ServiceAccount", Name: "mira-workspace"},
+		{Kind: "Role", Name: "mira-workspace"},
+		{Kind: "RoleBinding", Name: "mira-workspace"},
+		{Kind: "NetworkPolicy", Name: "mira-workspace-deny-all"},
+		{Kind: "NetworkPolicy", Name: "mira-workspace-egress"},
+		{Kind: "NetworkPolicy", Name: "mira-workspace-ingress-gateway"},
+		{Kind: "PersistentVolumeClaim", Name: "mira-workspace-pvc"},
+		{Kind: "Pod", Name: "mira-workspace-pod"},
+		{Kind: "Service", Name: "mira-workspace-svc"},
+	} {
+		if n := countManaged(got, want); n != 1 {
+			t.Errorf("%s %q listed %d times in managedResources %v, want once", want.Kind, want.Name, n, got)
+		}
+	}
+}
+
+func TestReconcile_ResourceLabels(t *testing.T) {
+	ctx := context.Background()
+	ws := wsWithFinalizer("cost-ws", "carl")
+	ws.Labels = map[string]string{"team": "platform"}
+	r, fc := newFakeReconciler(t, ws)
+	r.ResourceLabels = map[string]string{"cost-center": "cc-{label:team}"}
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+
+	reconcileNN(t, r, nn) // creates RBAC, NetworkPolicies and the PVC
+	var pvc corev1.PersistentVolumeClaim
+	if err := fc.Get(ctx, types.NamespacedName{Name: "carl-workspace-pvc", Namespace: "default"}, &pvc); err != nil {
+		t.Fatalf("Get PVC: %v", err)
+	}
+	pvc.Status.Phase = corev1.ClaimBound
+	if err := fc.Update(ctx, &pvc); err != nil {
+		t.Fatalf("bind PVC: %v", err)
+	}
+	reconcileNN(t, r, nn) // creates the pod
+	reconcileNN(t, r, nn) // ensures the service
+
+	for _, obj := range []client.Object{
+		&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "carl-workspace-pod"}},
+		&corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "carl-workspace-pvc"}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "carl-workspace-svc"}},
+		&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "carl-workspace"}},
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "carl-workspace"}},
+		&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "carl-workspace"}},
+		&networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "carl-workspace-deny-all"}},
+		&networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "carl-workspace-egress"}},
+		&networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: "carl-workspace-ingress-gateway"}},
+	} {
+		name := fmt.Sprintf("%T/%s", obj, obj.GetName())
+		t.Run(name, func(t *testing.T) {
+			if err := fc.Get(ctx, types.NamespacedName{Name: obj.GetName(), Namespace: "default"}, obj); err != nil {
+				t.Fatalf("Get %s: %v", name, err)
+			}
+			if got := obj.GetLabels()["cost-center"]; got != "cc-platform" {
+				t.Errorf("cost-center label = %q, want cc-platform (labels %v)", got, obj.GetLabels())
+			}
+			if obj.GetLabels()["app"] != "workspace" {
+				t.Errorf("core app label lost: %v", obj.GetLabels())
+			}
+		})
+	}
+}
+
+// TestReconcile_ServiceSelectorUsesCoreLabels checks that a Service whose
+// selector picked up extra labels is reset to the core app/user labels, so it
+// keeps selecting the pod whatever else is set on it.
+func TestReconcile_ServiceSelectorUsesCoreLabels(t *testing.T) {
+	ctx := context.Background()
+	ws, pvc, pod := idleRunningObjects("selector-ws", "selma")
+	stale := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: workspace.ServiceName("selma"), Namespace: "default"},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: corev1.ClusterIPNone,
+			Selector:  map[string]string{"app": "workspace", "user": "selma", "team": "platform"},
+		},
+	}
+	r, fc := newFakeReconciler(t, ws, pvc, pod, stale)
+	reconcileNN(t, r, types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace})
+
+	var svc corev1.Service
+	if err := fc.Get(ctx, client.ObjectKeyFromObject(stale), &svc); err != nil {
+		t.Fatalf("Get Service: %v", err)
+	}
+	if !maps.Equal(svc.Spec.Selector, workspace.SelectorLabels("selma")) {
+		t.Errorf("Selector = %v, want %v", svc.Spec.Selector, workspace.SelectorLabels("selma"))
+	}
+}
+
+func TestReconcile_SharedServiceAccount(t *testing.T) {
+	ctx := context.Background()
+	wsA := wsWithFinalizer("shared-a", "sana")
+	wsB := wsWithFinalizer("shared-b", "sven")
+	r, fc := newFakeReconciler(t, wsA, wsB)
+	r.SharedServiceAccount = true
+
+	for _, ws := range []*workspacev1alpha1.Workspace{wsA, wsB} {
+		reconcileNN(t, r, types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace})
+	}
+
+	var sas corev1.ServiceAccountList
+	if err := fc.List(ctx, &sas, client.InNamespace("default")); err != nil {
+		t.Fatalf("List ServiceAccounts: %v", err)
+	}
+	if len(sas.Items) != 1 || sas.Items[0].Name != workspace.SharedServiceAccountName {
+		t.Fatalf("ServiceAccounts = %v, want only %q", sas.Items, workspace.SharedServiceAccountName)
+	}
+	sa := sas.Items[0]
+	if len(sa.OwnerReferences) != 2 {
+		t.Errorf("owner references = %+v, want one per workspace", sa.OwnerReferences)
+	}
+	for _, ref := range sa.OwnerReferences {
+		if ref.Controller != nil && *ref.Controller {
+			t.Errorf("owner reference %q is a controller reference; shared SA must not belong to one workspace", ref.Name)
+		}
+	}
+	if _, ok := sa.Labels["user"]; ok {
+		t.Errorf("shared SA labels = %v, want no user label", sa.Labels)
+	}
+
+	var roles rbacv1.RoleList
+	if err := fc.List(ctx, &roles, client.InNamespace("default")); err != nil {
+		t.Fatalf("List Roles: %v", err)
+	}
+	var bindings rbacv1.RoleBindingList
+	if err := fc.List(ctx, &bindings, client.InNamespace("default")); err != nil {
+		t.Fatalf("List RoleBindings: %v", err)
+	}
+	if len(roles.Items) != 1 || len(bindings.Items) != 1 {
+		t.Errorf("roles = %d, roleBindings = %d, want one shared each", len(roles.Items), len(bindings.Items))
+	}
+}
+
+func countManaged(list []workspacev1alpha1.ManagedResource, want workspacev1alpha1.ManagedResource) int {
+	n := 0
+	for _, m := range list {
+		if m == want {
+			n++
+		}
+	}
+	return n
+}
+
+func TestReconcile_WorkspaceTemplate(t *testing.T) {
+	ctx := context.Background()
+	tmpl := &workspacev1alpha1.WorkspaceTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "standard", Namespace: "default"},
+		Spec: workspacev1alpha1.WorkspaceTemplateSpec{
+			Resources: workspacev1alpha1.ResourceRequirements{CPU: "500m", Memory: "1Gi", Storage: "5Gi"},
+			AIConfig: workspacev1alpha1.AIConfiguration{
+				Providers: []workspacev1alpha1.AIProvider{
+					{Name: "shared", Endpoint: "http://vllm.ai-system:8000", Models: []string{"coder"}},
+				},
+			},
+			SchedulerName: "volcano",
+		},
+	}
+	ws := wsWithFinalizer("tmpl-ws", "tara")
+	ws.Spec.TemplateRef = "standard"
+	// Only memory is set explicitly; it must win over the template.
+	ws.Spec.Resources = workspacev1alpha1.ResourceRequirements{Memory: "2Gi"}
+	ws.Spec.AIConfig = workspacev1alpha1.AIConfiguration{}
+	r, fc := newFakeReconciler(t, tmpl, ws)
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	var pvc corev1.PersistentVolumeClaim
+	pvcKey := types.NamespacedName{Name: "tara-workspace-pvc", Namespace: "default"}
+	if err := fc.Get(ctx, pvcKey, &pvc); err != nil {
+		t.Fatalf("Get PVC: %v", err)
+	}
+	if got := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; got.Cmp(resource.MustParse("5Gi")) != 0 {
+		t.Errorf("PVC storage = %s, want template 5Gi", got.String())
+	}
+
+	pvc.Status.Phase = corev1.ClaimBound
+	if err := fc.Update(ctx, &pvc); err != nil {
+		t.Fatalf("bind PVC: %v", err)
+	}
+	reconcileNN(t, r, nn)
+
+	var pod corev1.Pod
+	if err := fc.Get(ctx, types.NamespacedName{Name: "tara-workspace-pod", Namespace: "default"}, &pod); err != nil {
+		t.Fatalf("Get Pod: %v", err)
+	}
+	limits := pod.Spec.Containers[0].Resources.Limits
+	if got := limits[corev1.ResourceCPU]; got.Cmp(resource.MustParse("500m")) != 0 {
+		t.Errorf("cpu limit = %s, want template 500m", got.String())
+	}
+	if got := limits[corev1.ResourceMemory]; got.Cmp(resource.MustParse("2Gi")) != 0 {
+		t.Errorf("memory limit = %s, want explicit 2Gi", got.String())
+	}
+	if pod.Spec.SchedulerName != "volcano" {
+		t.Errorf("schedulerName = %q, want template volcano", pod.Spec.SchedulerName)
+	}
+
+	// The merge is in memory only; the stored spec keeps just its own fields.
+	stored := getWS(t, fc, nn)
+	if stored.Spec.Resources.CPU != "" || len(stored.Spec.AIConfig.Providers) != 0 {
+		t.Errorf("stored spec was rewritten with template values: %+v", stored.Spec)
+	}
+	if stored.Status.Phase == workspacev1alpha1.WorkspacePhaseFailed {
+		t.Errorf("status.phase = Failed: %s", stored.Status.Message)
+	}
+}
+
+func TestReconcile_WorkspaceTemplate_Missing(t *testing.T) {
+	ws := wsWithFinalizer("tmpl-missing-ws", "theo")
+	ws.Spec.TemplateRef = "does-not-exist"
+	r, fc := newFakeReconciler(t, ws)
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	stored := getWS(t, fc, nn)
+	if stored.Status.Phase != workspacev1alpha1.WorkspacePhaseFailed {
+		t.Errorf("status.phase = %q, want Failed", stored.Status.Phase)
+	}
+	if !strings.Contains(stored.Status.Message, "does-not-exist") {
+		t.Errorf("status.message = %q, want the missing template named", stored.Status.Message)
+	}
+}
+
+func TestReconcile_MultiReplicaEphemeral_CreatesDeployment(t *testing.T) {
+	ctx := context.Background()
+	ws := wsWithFinalizer("preview-ws", "pia")
+	replicas := int32(2)
+	ws.Spec.Replicas = &replicas
+	ws.Spec.Persistence.Ephemeral = true
+	r, fc := newFakeReconciler(t, ws)
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	var deploy appsv1.Deployment
+	if err := fc.Get(ctx, types.NamespacedName{Name: workspace.DeploymentName("pia"), Namespace: "default"}, &deploy); err != nil {
+		t.Fatalf("Get Deployment: %v", err)
+	}
+	if deploy.Spec.Replicas == nil || *deploy.Spec.Replicas != 2 {
+		t.Errorf("replicas = %v, want 2", deploy.Spec.Replicas)
+	}
+	var pvcList corev1.PersistentVolumeClaimList
+	if err := fc.List(ctx, &pvcList, client.InNamespace("default")); err != nil {
+		t.Fatal(err)
+	}
+	if len(pvcList.Items) != 0 {
+		t.Errorf("expected no PVC for ephemeral workspace, got %d", len(pvcList.Items))
+	}
+	var svc corev1.Service
+	if err := fc.Get(ctx, types.NamespacedName{Name: "pia-workspace-svc", Namespace: "default"}, &svc); err != nil {
+		t.Fatalf("Get Service: %v", err)
+	}
+	if stored := getWS(t, fc, nn); stored.Status.Phase != workspacev1alpha1.WorkspacePhaseCreating {
+		t.Errorf("status.phase = %q, want Creating until a replica is ready", stored.Status.Phase)
+	}
+}
+
+// scaleToZeroObjects returns a ScaleToZero workspace with a bound PVC and a
+// Deployment scaled to the given replica count.
+func scaleToZeroObjects(name, user string, replicas int32) (*workspacev1alpha1.Workspace, *corev1.PersistentVolumeClaim, *appsv1.Deployment) {
+	ws := wsWithFinalizer(name, user)
+	ws.Spec.Lifecycle.StopMode = workspacev1alpha1.StopModeScaleToZero
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: user + "-workspace-pvc", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: workspace.DeploymentName(user), Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: workspace.Labels(user)},
+		},
+		Status: appsv1.DeploymentStatus{Replicas: replicas, ReadyReplicas: replicas},
 	}
+	return ws, pvc, deploy
 }
 
-// getWS fetches the workspace or fatals the test.
-func getWS(t *testing.T, fc client.Client, nn types.NamespacedName) workspacev1alpha1.Workspace {
-	t.Helper()
-	var ws workspacev1alpha1.Workspace
-	if err := fc.Get(context.Background(), nn, &ws); err != nil {
-		t.Fatalf("Get Workspace: %v", err)
+func TestReconcile_ScaleToZero_IdleStopScalesDeploymentToZero(t *testing.T) {
+	ctx := context.Background()
+	ws, pvc, deploy := scaleToZeroObjects("stz-ws", "sam", 1)
+	ws.Status.LastAccessed = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	r, fc := newFakeReconciler(t, ws, pvc, deploy)
+	r.IdleTimeout = time.Hour
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	var got appsv1.Deployment
+	if err := fc.Get(ctx, client.ObjectKeyFromObject(deploy), &got); err != nil {
+		t.Fatalf("Get Deployment: %v", err)
+	}
+	if got.Spec.Replicas == nil || *got.Spec.Replicas != 0 {
+		t.Errorf("replicas = %v, want 0 after idle stop", got.Spec.Replicas)
+	}
+	if err := fc.Get(ctx, client.ObjectKeyFromObject(pvc), &corev1.PersistentVolumeClaim{}); err != nil {
+		t.Errorf("expected PVC to be kept: %v", err)
+	}
+	if stored := getWS(t, fc, nn); stored.Status.Phase != workspacev1alpha1.WorkspacePhaseStopped {
+		t.Errorf("status.phase = %q, want Stopped", stored.Status.Phase)
+	}
+
+	// A stopped workspace is not scaled back up until it is restarted.
+	reconcileNN(t, r, nn)
+	if err := fc.Get(ctx, client.ObjectKeyFromObject(deploy), &got); err != nil {
+		t.Fatalf("Get Deployment: %v", err)
+	}
+	if *got.Spec.Replicas != 0 {
+		t.Errorf("replicas = %d after reconciling a Stopped workspace, want 0", *got.Spec.Replicas)
 	}
-	return ws
 }
 
-func TestReconcile_StoppedPhase(t *testing.T) {
-	ws := wsWithFinalizer("stopped-ws", "alice")
-	ws.Status.Phase = workspacev1alpha1.WorkspacePhaseStopped
-	r, fc := newFakeReconciler(t, ws)
+func TestReconcile_ScaleToZero_StartScalesDeploymentToOne(t *testing.T) {
+	ctx := context.Background()
+	// The gateway restarts a Stopped workspace by clearing its phase.
+	ws, pvc, deploy := scaleToZeroObjects("stz-start-ws", "sue", 0)
+	r, fc := newFakeReconciler(t, ws, pvc, deploy)
 
 	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
 	reconcileNN(t, r, nn)
 
-	// No PVC should have been created (reconcile returns early for Stopped).
-	var pvcList corev1.PersistentVolumeClaimList
-	if err := fc.List(context.Background(), &pvcList, client.InNamespace("default")); err != nil {
-		t.Fatal(err)
+	var got appsv1.Deployment
+	if err := fc.Get(ctx, client.ObjectKeyFromObject(deploy), &got); err != nil {
+		t.Fatalf("Get Deployment: %v", err)
 	}
-	if len(pvcList.Items) != 0 {
-		t.Errorf("expected no PVCs for stopped workspace, got %d", len(pvcList.Items))
+	if got.Spec.Replicas == nil || *got.Spec.Replicas != 1 {
+		t.Errorf("replicas = %v, want 1 after start", got.Spec.Replicas)
+	}
+	var pod corev1.Pod
+	if err := fc.Get(ctx, types.NamespacedName{Name: "sue-workspace-pod", Namespace: "default"}, &pod); err == nil {
+		t.Error("expected no bare Pod in ScaleToZero mode")
+	}
+	if stored := getWS(t, fc, nn); stored.Status.Phase != workspacev1alpha1.WorkspacePhaseCreating {
+		t.Errorf("status.phase = %q, want Creating until the replica is ready", stored.Status.Phase)
 	}
 }
 
-func TestReconcile_Delete(t *testing.T) {
+func TestReconcile_Suspend_DeletesPodAndResumes(t *testing.T) {
 	ctx := context.Background()
-	ws := wsWithFinalizer("del-ws", "bob")
-	r, fc := newFakeReconciler(t, ws)
+	ws, pvc, pod := idleRunningObjects("suspend-ws", "sid")
+	ws.Spec.Suspended = true
+	r, fc := newFakeReconciler(t, ws, pvc, pod)
+	r.IdleTimeout = time.Hour
 
 	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
 
-	// Delete workspace — fake client sets DeletionTimestamp because finalizers are present.
-	var stored workspacev1alpha1.Workspace
-	if err := fc.Get(ctx, nn, &stored); err != nil {
-		t.Fatalf("Get: %v", err)
+	if err := fc.Get(ctx, client.ObjectKeyFromObject(pod), &corev1.Pod{}); !apierrors.IsNotFound(err) {
+		t.Errorf("pod get err = %v, want NotFound while suspended", err)
 	}
-	if err := fc.Delete(ctx, &stored); err != nil {
-		t.Fatalf("Delete: %v", err)
+	if err := fc.Get(ctx, client.ObjectKeyFromObject(pvc), &corev1.PersistentVolumeClaim{}); err != nil {
+		t.Errorf("expected PVC to be kept: %v", err)
+	}
+	stored := getWS(t, fc, nn)
+	if stored.Status.Phase != workspacev1alpha1.WorkspacePhaseStopped || stored.Status.Message != "Suspended by user" {
+		t.Errorf("status = %q %q, want Stopped \"Suspended by user\"", stored.Status.Phase, stored.Status.Message)
+	}
+	if !stoppedBySuspend(&stored) {
+		t.Error("expected Ready condition reason Suspended")
 	}
 
-	// Reconcile should call reconcileDelete, removing the finalizer.
+	// Clearing spec.suspended recreates the pod and restarts the idle clock,
+	// even though lastAccessed was older than the idle timeout.
+	stored.Spec.Suspended = false
+	if err := fc.Update(ctx, &stored); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
 	reconcileNN(t, r, nn)
 
-	// After removing the last finalizer the fake client deletes the object.
-	var ws2 workspacev1alpha1.Workspace
-	if err := fc.Get(ctx, nn, &ws2); err == nil {
-		// If found (unlikely), at least the finalizer must be gone.
-		if len(ws2.Finalizers) != 0 {
-			t.Errorf("expected no finalizers after delete, got %v", ws2.Finalizers)
-		}
+	if err := fc.Get(ctx, client.ObjectKeyFromObject(pod), &corev1.Pod{}); err != nil {
+		t.Errorf("expected pod to be recreated after resume: %v", err)
+	}
+	resumed := getWS(t, fc, nn)
+	if resumed.Status.Phase == workspacev1alpha1.WorkspacePhaseStopped {
+		t.Error("status.phase = Stopped, want the workspace to resume")
+	}
+	if time.Since(resumed.Status.LastAccessed.Time) > time.Minute {
+		t.Errorf("lastAccessed = %v, want it reset on resume", resumed.Status.LastAccessed)
 	}
 }
 
-func TestReconcile_PVCLost(t *testing.T) {
-	ws := wsWithFinalizer("pvc-lost-ws", "charlie")
+func TestReconcile_Suspend_IdleStoppedWorkspaceStaysStopped(t *testing.T) {
+	// An idle stop is not a suspension: with spec.suspended false the
+	// workspace still waits for the gateway to restart it.
+	ws, pvc, _ := idleRunningObjects("idle-not-suspended-ws", "ines")
+	r, fc := newFakeReconciler(t, ws, pvc)
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	stored := getWS(t, fc, nn)
+	if err := r.updateStatus(context.Background(), &stored, workspace.StatusSummary{
+		Phase:       workspacev1alpha1.WorkspacePhaseStopped,
+		Message:     "Workspace stopped due to inactivity",
+		ReadyReason: workspace.ReasonStopped,
+	}); err != nil {
+		t.Fatalf("updateStatus: %v", err)
+	}
 
-	// Pre-create a PVC with Lost status (not a status subresource → status stored directly).
+	reconcileNN(t, r, nn)
+
+	if err := fc.Get(context.Background(), types.NamespacedName{Name: "ines-workspace-pod", Namespace: "default"}, &corev1.Pod{}); err == nil {
+		t.Error("expected no pod for an idle-stopped workspace")
+	}
+	if got := getWS(t, fc, nn).Status.Phase; got != workspacev1alpha1.WorkspacePhaseStopped {
+		t.Errorf("status.phase = %q, want Stopped", got)
+	}
+}
+
+func TestReconcile_Suspend_ScalesDeploymentToZeroAndBack(t *testing.T) {
+	ctx := context.Background()
+	ws, pvc, deploy := scaleToZeroObjects("suspend-deploy-ws", "sol", 1)
+	ws.Spec.Suspended = true
+	r, fc := newFakeReconciler(t, ws, pvc, deploy)
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	var got appsv1.Deployment
+	if err := fc.Get(ctx, client.ObjectKeyFromObject(deploy), &got); err != nil {
+		t.Fatalf("Get Deployment: %v", err)
+	}
+	if got.Spec.Replicas == nil || *got.Spec.Replicas != 0 {
+		t.Errorf("replicas = %v, want 0 while suspended", got.Spec.Replicas)
+	}
+	stored := getWS(t, fc, nn)
+	if stored.Status.Phase != workspacev1alpha1.WorkspacePhaseStopped {
+		t.Errorf("status.phase = %q, want Stopped", stored.Status.Phase)
+	}
+
+	stored.Spec.Suspended = false
+	if err := fc.Update(ctx, &stored); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	reconcileNN(t, r, nn)
+
+	if err := fc.Get(ctx, client.ObjectKeyFromObject(deploy), &got); err != nil {
+		t.Fatalf("Get Deployment: %v", err)
+	}
+	if got.Spec.Replicas == nil || *got.Spec.Replicas != 1 {
+		t.Errorf("replicas = %v, want 1 after resume", got.Spec.Replicas)
+	}
+}
+
+func TestReconcile_UseDeployment_SingleReplica(t *testing.T) {
+	ctx := context.Background()
+	ws := wsWithFinalizer("deploy-mode-ws", "dora")
 	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "dora-workspace-pvc", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	r, fc := newFakeReconciler(t, ws, pvc)
+	r.UseDeployment = true
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	var deploy appsv1.Deployment
+	if err := fc.Get(ctx, types.NamespacedName{Name: workspace.DeploymentName("dora"), Namespace: "default"}, &deploy); err != nil {
+		t.Fatalf("Get Deployment: %v", err)
+	}
+	if deploy.Spec.Replicas == nil || *deploy.Spec.Replicas != 1 {
+		t.Errorf("replicas = %v, want 1", deploy.Spec.Replicas)
+	}
+	if err := fc.Get(ctx, types.NamespacedName{Name: workspace.PodName("dora"), Namespace: "default"}, &corev1.Pod{}); err == nil {
+		t.Error("expected no bare Pod with UseDeployment")
+	}
+}
+
+func TestReconcile_UseDeployment_ReportsPodName(t *testing.T) {
+	ws, pvc, deploy := scaleToZeroObjects("deploy-pod-ws", "dirk", 1)
+	ws.Spec.Lifecycle.StopMode = ""
+	replicaPod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      "charlie-workspace-pvc",
+			Name:      workspace.DeploymentName("dirk") + "-7c9f8-abcde",
 			Namespace: "default",
+			Labels:    workspace.Labels("dirk"),
 		},
-		Status: corev1.PersistentVolumeClaimStatus{
-			Phase: corev1.ClaimLost,
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "workspace", Image: "workspace:test"}}},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
 		},
 	}
-	r, fc := newFakeReconciler(t, ws, pvc)
+	r, fc := newFakeReconciler(t, ws, pvc, deploy, replicaPod)
+	r.UseDeployment = true
 
 	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
 	reconcileNN(t, r, nn)
 
 	stored := getWS(t, fc, nn)
-	if stored.Status.Phase != workspacev1alpha1.WorkspacePhaseFailed {
-		t.Errorf("status.phase = %q, want Failed", stored.Status.Phase)
+	if stored.Status.Phase != workspacev1alpha1.WorkspacePhaseRunning {
+		t.Errorf("status.phase = %q, want Running", stored.Status.Phase)
 	}
-	if stored.Status.RemediationHint == "" {
-		t.Error("expected non-empty remediationHint for ClaimLost PVC")
+	if stored.Status.PodName != replicaPod.Name {
+		t.Errorf("status.podName = %q, want %q", stored.Status.PodName, replicaPod.Name)
 	}
-	cond := meta.FindStatusCondition(stored.Status.Conditions, workspace.ConditionTypeReady)
-	if cond == nil || cond.Reason != workspace.ReasonPVCLost {
-		t.Fatalf("Ready condition: %#v", cond)
+}
+
+func TestReconcile_UseDeployment_PodSwitchHonoursRolloutLimit(t *testing.T) {
+	ctx := context.Background()
+	users := []string{"fay", "gus", "hal"}
+	var objs []client.Object
+	for _, u := range users {
+		ws, pvc, pod := idleRunningObjects(u+"-ws", u)
+		objs = append(objs, ws, pvc, pod)
+	}
+	r, fc := newFakeReconciler(t, objs...)
+	r.UseDeployment = true
+	r.MaxConcurrentImageRollouts = 2
+
+	for _, u := range users {
+		reconcileNN(t, r, types.NamespacedName{Name: u + "-ws", Namespace: "default"})
+	}
+	barePods := 0
+	for _, u := range users {
+		if err := fc.Get(ctx, types.NamespacedName{Name: workspace.PodName(u), Namespace: "default"}, &corev1.Pod{}); err == nil {
+			barePods++
+		}
+	}
+	if barePods != 1 {
+		t.Errorf("bare Pods left after switching to Deployments = %d, want 1 held back by the rollout limit", barePods)
+	}
+}
+
+func TestReconcile_UseDeployment_AutoUpdateDisabledKeepsImage(t *testing.T) {
+	ctx := context.Background()
+	ws, pvc, deploy := scaleToZeroObjects("deploy-pinned-ws", "pia", 1)
+	ws.Spec.Lifecycle.StopMode = ""
+	autoUpdate := false
+	ws.Spec.AutoUpdate = &autoUpdate
+	deploy.Spec.Template.Spec.Containers = []corev1.Container{{Name: "workspace", Image: "workspace:old"}}
+	r, fc := newFakeReconciler(t, ws, pvc, deploy)
+	r.UseDeployment = true
+	r.WorkspaceImage = "workspace:new"
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	var got appsv1.Deployment
+	if err := fc.Get(ctx, client.ObjectKeyFromObject(deploy), &got); err != nil {
+		t.Fatalf("Get Deployment: %v", err)
+	}
+	if img := got.Spec.Template.Spec.Containers[0].Image; img != "workspace:old" {
+		t.Errorf("template image = %q, want workspace:old while autoUpdate is false", img)
+	}
+	if !getWS(t, fc, nn).Status.UpdateAvailable {
+		t.Error("expected status.updateAvailable to be set")
+	}
+
+	// Turning autoUpdate back on rolls the new image and clears the flag.
+	stored := getWS(t, fc, nn)
+	stored.Spec.AutoUpdate = nil
+	if err := fc.Update(ctx, &stored); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	reconcileNN(t, r, nn)
+	if err := fc.Get(ctx, client.ObjectKeyFromObject(deploy), &got); err != nil {
+		t.Fatalf("Get Deployment: %v", err)
+	}
+	if img := got.Spec.Template.Spec.Containers[0].Image; img != "workspace:new" {
+		t.Errorf("template image = %q, want workspace:new with autoUpdate", img)
+	}
+	if getWS(t, fc, nn).Status.UpdateAvailable {
+		t.Error("expected status.updateAvailable to be cleared")
+	}
+}
+
+func TestReconcile_UseDeployment_ImageRolloutLimit(t *testing.T) {
+	ctx := context.Background()
+	busy := wsWithFinalizer("busy-ws", "bea")
+	busy.Status.ImageRolloutStartedAt = metav1.Now()
+	ws, pvc, deploy := scaleToZeroObjects("deploy-capped-ws", "cy", 1)
+	ws.Spec.Lifecycle.StopMode = ""
+	deploy.Spec.Template.Spec.Containers = []corev1.Container{{Name: "workspace", Image: "workspace:old"}}
+	r, fc := newFakeReconciler(t, busy, ws, pvc, deploy)
+	r.UseDeployment = true
+	r.WorkspaceImage = "workspace:new"
+	r.MaxConcurrentImageRollouts = 1
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: nn})
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+
+	var got appsv1.Deployment
+	if err := fc.Get(ctx, client.ObjectKeyFromObject(deploy), &got); err != nil {
+		t.Fatalf("Get Deployment: %v", err)
+	}
+	if img := got.Spec.Template.Spec.Containers[0].Image; img != "workspace:old" {
+		t.Errorf("template image = %q, want workspace:old while the rollout limit is reached", img)
+	}
+	if res.RequeueAfter == 0 {
+		t.Error("expected a requeue to retry the deferred rollout")
+	}
+	if stored := getWS(t, fc, nn); stored.Status.UpdateAvailable || !stored.Status.ImageRolloutStartedAt.IsZero() {
+		t.Errorf("status = updateAvailable %v, imageRolloutStartedAt %v; want neither set while deferred",
+			stored.Status.UpdateAvailable, stored.Status.ImageRolloutStartedAt)
 	}
 }
 
@@ -540,9 +2249,94 @@ func TestReconcile_PodFailed(t *testing.T) {
 		Spec: corev1.PodSpec{
 			Containers: []corev1.Container{{Name: "workspace", Image: "workspace:test"}},
 		},
-		Status: corev1.PodStatus{Phase: corev1.PodFailed, Reason: "OOMKilled"},
+		Status: corev1.PodStatus{Phase: corev1.PodFailed, Reason: "Error"},
+	}
+	r, fc := newFakeReconciler(t, ws, pvc, pod)
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	stored := getWS(t, fc, nn)
+	if stored.Status.Phase != workspacev1alpha1.WorkspacePhaseFailed {
+		t.Errorf("status.phase = %q, want Failed", stored.Status.Phase)
+	}
+	ready := meta.FindStatusCondition(stored.Status.Conditions, workspace.ConditionTypeReady)
+	if ready == nil || ready.Status != metav1.ConditionFalse || ready.Reason != workspace.ReasonPodFailed {
+		t.Errorf("Ready condition = %+v, want False/%s", ready, workspace.ReasonPodFailed)
+	}
+}
+
+func TestReconcile_StatusConditions(t *testing.T) {
+	ws, pvc, pod := idleRunningObjects("conditions-ws", "carmen")
+	ws.Generation = 3
+	pod.Spec.NodeName = "node-a"
+	pod.Status.Conditions = append(pod.Status.Conditions, corev1.PodCondition{Type: corev1.PodScheduled, Status: corev1.ConditionTrue})
+	r, fc := newFakeReconciler(t, ws, pvc, pod)
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	stored := getWS(t, fc, nn)
+	if stored.Status.Phase != workspacev1alpha1.WorkspacePhaseRunning {
+		t.Fatalf("status.phase = %q, want Running", stored.Status.Phase)
+	}
+	tests := []struct {
+		condType string
+		reason   string
+	}{
+		{condType: workspace.ConditionTypeReady, reason: workspace.ReasonPodRunning},
+		{condType: workspace.ConditionTypePodScheduled, reason: workspace.ReasonScheduled},
+		{condType: workspace.ConditionTypeNetworkPoliciesReady, reason: workspace.ReasonNetPolApplied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condType, func(t *testing.T) {
+			cond := meta.FindStatusCondition(stored.Status.Conditions, tt.condType)
+			if cond == nil {
+				t.Fatalf("%s condition missing; conditions = %+v", tt.condType, stored.Status.Conditions)
+			}
+			if cond.Status != metav1.ConditionTrue || cond.Reason != tt.reason {
+				t.Errorf("%s = %s/%s, want True/%s", tt.condType, cond.Status, cond.Reason, tt.reason)
+			}
+			if cond.ObservedGeneration != 3 {
+				t.Errorf("%s observedGeneration = %d, want 3", tt.condType, cond.ObservedGeneration)
+			}
+		})
+	}
+}
+
+func TestReconcile_OOMKilled(t *testing.T) {
+	ws := wsWithFinalizer("oom-ws", "olga")
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "olga-workspace-pvc", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "olga-workspace-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "workspace",
+				Image: "workspace:test",
+				Resources: corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name: "workspace",
+				State: corev1.ContainerState{
+					Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"},
+				},
+				LastTerminationState: corev1.ContainerState{
+					Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137},
+				},
+			}},
+		},
 	}
 	r, fc := newFakeReconciler(t, ws, pvc, pod)
+	recorder := events.NewFakeRecorder(10)
+	r.Recorder = recorder
 
 	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
 	reconcileNN(t, r, nn)
@@ -551,6 +2345,66 @@ func TestReconcile_PodFailed(t *testing.T) {
 	if stored.Status.Phase != workspacev1alpha1.WorkspacePhaseFailed {
 		t.Errorf("status.phase = %q, want Failed", stored.Status.Phase)
 	}
+	if !strings.Contains(stored.Status.Message, "OOMKilled") || !strings.Contains(stored.Status.Message, "128Mi") ||
+		!strings.Contains(stored.Status.Message, "spec.resources.memory") {
+		t.Errorf("status.message = %q, want OOMKilled guidance naming the 128Mi limit and spec.resources.memory", stored.Status.Message)
+	}
+	if stored.Status.RemediationHint != workspace.RemediationOOMKilled {
+		t.Errorf("remediationHint = %q", stored.Status.RemediationHint)
+	}
+	select {
+	case e := <-recorder.Events:
+		if !strings.HasPrefix(e, corev1.EventTypeWarning+" "+workspace.ReasonOOMKilled) {
+			t.Errorf("event = %q, want Warning OOMKilled", e)
+		}
+	default:
+		t.Error("expected a Warning event")
+	}
+}
+
+func TestReconcile_Events(t *testing.T) {
+	boundPVC := func(user string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: user + "-workspace-pvc", Namespace: "default"},
+			Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+		}
+	}
+	invalid := wsWithFinalizer("events-invalid-ws", "erin")
+	invalid.Spec.Resources.CPU = ""
+	failedWS, failedPVC, failedPod := idleRunningObjects("events-failed-ws", "emil")
+	failedPod.Status = corev1.PodStatus{Phase: corev1.PodFailed, Reason: "Evicted"}
+	idleWS, idlePVC, idlePod := idleRunningObjects("events-idle-ws", "ezra")
+
+	tests := []struct {
+		name        string
+		objs        []client.Object
+		idleTimeout time.Duration
+		want        string
+	}{
+		{name: "validation failure", objs: []client.Object{invalid}, want: corev1.EventTypeWarning + " " + workspace.ReasonValidationFailed},
+		{name: "pod failed", objs: []client.Object{failedWS, failedPVC, failedPod}, want: corev1.EventTypeWarning + " " + workspace.ReasonPodFailed},
+		{name: "PVC created", objs: []client.Object{wsWithFinalizer("events-pvc-ws", "ella")}, want: corev1.EventTypeNormal + " " + workspace.ReasonPVCCreated},
+		{name: "pod created", objs: []client.Object{wsWithFinalizer("events-pod-ws", "eli"), boundPVC("eli")}, want: corev1.EventTypeNormal + " " + workspace.ReasonPodCreated},
+		{name: "idle stop", objs: []client.Object{idleWS, idlePVC, idlePod}, idleTimeout: time.Hour, want: corev1.EventTypeNormal + " " + workspace.ReasonIdleStopped},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newFakeReconciler(t, tt.objs...)
+			r.IdleTimeout = tt.idleTimeout
+			recorder := events.NewFakeRecorder(10)
+			r.Recorder = recorder
+
+			reconcileNN(t, r, client.ObjectKeyFromObject(tt.objs[0]))
+
+			var got []string
+			for len(recorder.Events) > 0 {
+				got = append(got, <-recorder.Events)
+			}
+			if !slices.ContainsFunc(got, func(e string) bool { return strings.HasPrefix(e, tt.want+" ") }) {
+				t.Errorf("events = %q, want one starting with %q", got, tt.want)
+			}
+		})
+	}
 }
 
 func TestReconcile_PodUnknown(t *testing.T) {
@@ -690,9 +2544,51 @@ func TestReconcile_PodCreatingPhase(t *testing.T) {
 	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
 	reconcileNN(t, r, nn)
 
-	stored := getWS(t, fc, nn)
-	if stored.Status.Phase != workspacev1alpha1.WorkspacePhaseCreating {
-		t.Errorf("status.phase = %q, want Creating", stored.Status.Phase)
+	stored := getWS(t, fc, nn)
+	if stored.Status.Phase != workspacev1alpha1.WorkspacePhaseCreating {
+		t.Errorf("status.phase = %q, want Creating", stored.Status.Phase)
+	}
+}
+
+func TestReconcile_SetsNetworkPolicyReadinessGate(t *testing.T) {
+	ctx := context.Background()
+	ws := wsWithFinalizer("np-gate-ws", "nadia")
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "nadia-workspace-pvc", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "nadia-workspace-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers:     []corev1.Container{{Name: "workspace", Image: "workspace:test"}},
+			ReadinessGates: []corev1.PodReadinessGate{{ConditionType: workspace.NetworkPolicyReadyCondition}},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	}
+	fc := fake.NewClientBuilder().
+		WithScheme(testScheme).
+		WithStatusSubresource(&workspacev1alpha1.Workspace{}, &corev1.Pod{}).
+		WithObjects(ws, pvc, pod).
+		Build()
+	r := &WorkspaceReconciler{Client: fc, Scheme: testScheme, WorkspaceImage: "workspace:test"}
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	for _, suffix := range []string{"deny-all", "egress", "ingress-gateway"} {
+		if err := fc.Get(ctx, types.NamespacedName{Name: "nadia-workspace-" + suffix, Namespace: "default"}, &networkingv1.NetworkPolicy{}); err != nil {
+			t.Fatalf("Get %s NetworkPolicy: %v", suffix, err)
+		}
+	}
+	var stored corev1.Pod
+	if err := fc.Get(ctx, client.ObjectKeyFromObject(pod), &stored); err != nil {
+		t.Fatalf("Get Pod: %v", err)
+	}
+	i := slices.IndexFunc(stored.Status.Conditions, func(c corev1.PodCondition) bool {
+		return c.Type == workspace.NetworkPolicyReadyCondition
+	})
+	if i < 0 || stored.Status.Conditions[i].Status != corev1.ConditionTrue {
+		t.Errorf("pod conditions = %+v, want %s=True", stored.Status.Conditions, workspace.NetworkPolicyReadyCondition)
 	}
 }
 
@@ -758,6 +2654,47 @@ func TestReconcile_IdleTimeout_PerWorkspaceDisabled(t *testing.T) {
 	}
 }
 
+func TestReconcile_IdleTimeout_PerWorkspaceOverride(t *testing.T) {
+	tests := []struct {
+		name        string
+		idleTimeout string
+		lastAccess  time.Duration
+		wantStopped bool
+	}{
+		// Shorter than the operator default: stops although the default would not.
+		{name: "shorter", idleTimeout: "30m", lastAccess: 45 * time.Minute, wantStopped: true},
+		// Longer than the operator default: keeps running although the default would stop it.
+		{name: "longer", idleTimeout: "24h", lastAccess: 2 * time.Hour, wantStopped: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws, pvc, pod := idleRunningObjects("idle-override-ws", "iris")
+			ws.Spec.Lifecycle.IdleTimeout = tt.idleTimeout
+			ws.Status.LastAccessed = metav1.NewTime(time.Now().Add(-tt.lastAccess))
+			r, fc := newFakeReconciler(t, ws, pvc, pod)
+			r.IdleTimeout = time.Hour
+
+			nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+			reconcileNN(t, r, nn)
+
+			err := fc.Get(context.Background(), types.NamespacedName{Name: pod.Name, Namespace: "default"}, &corev1.Pod{})
+			if tt.wantStopped && err == nil {
+				t.Error("expected pod to be deleted by the per-workspace idle timeout")
+			}
+			if !tt.wantStopped && err != nil {
+				t.Errorf("expected pod to remain: %v", err)
+			}
+			wantPhase := workspacev1alpha1.WorkspacePhaseRunning
+			if tt.wantStopped {
+				wantPhase = workspacev1alpha1.WorkspacePhaseStopped
+			}
+			if got := getWS(t, fc, nn).Status.Phase; got != wantPhase {
+				t.Errorf("status.phase = %q, want %q", got, wantPhase)
+			}
+		})
+	}
+}
+
 func TestReconcile_IdleTimeout_SeedsLastAccessed(t *testing.T) {
 	ws := wsWithFinalizer("idle-seed-ws", "igor")
 	// LastAccessed unset — operator should stamp it while running so idle logic can run later.
@@ -818,9 +2755,13 @@ func TestReconcile_IdleTimeout(t *testing.T) {
 	r, fc := newFakeReconciler(t, ws, pvc, pod)
 	// IdleTimeout of 1 hour → workspace that was last accessed 2 hours ago is idle.
 	r.IdleTimeout = time.Hour
+	idleStops := testutil.ToFloat64(observability.WorkspaceIdleStops.WithLabelValues("default"))
 
 	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
 	reconcileNN(t, r, nn)
+	if got := testutil.ToFloat64(observability.WorkspaceIdleStops.WithLabelValues("default")); got != idleStops+1 {
+		t.Errorf("idle stops = %v, want %v", got, idleStops+1)
+	}
 
 	// Pod should be deleted.
 	var p corev1.Pod
@@ -834,6 +2775,231 @@ func TestReconcile_IdleTimeout(t *testing.T) {
 	}
 }
 
+// reconcileDurationCount returns the number of observations recorded by
+// observability.WorkspaceReconcileDuration.
+func reconcileDurationCount(t *testing.T) uint64 {
+	t.Helper()
+	families, err := crmetrics.Registry.Gather()
+	if err != nil {
+		t.Fatalf("gather metrics: %v", err)
+	}
+	for _, mf := range families {
+		if mf.GetName() == "devplane_workspace_reconcile_duration_seconds" {
+			return mf.GetMetric()[0].GetHistogram().GetSampleCount()
+		}
+	}
+	t.Fatal("devplane_workspace_reconcile_duration_seconds not registered")
+	return 0
+}
+
+func TestReconcile_Metrics(t *testing.T) {
+	ws := wsWithFinalizer("metrics-ws", "mia")
+	r, _ := newFakeReconciler(t, ws)
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	before := reconcileDurationCount(t)
+
+	reconcileNN(t, r, nn)
+	if got := reconcileDurationCount(t); got <= before {
+		t.Errorf("reconcile duration observations = %d, want more than %d", got, before)
+	}
+}
+
+// idleRunningObjects returns a workspace last accessed two hours ago with a
+// bound PVC and a ready pod, for the idle grace-period tests.
+func idleRunningObjects(name, user string) (*workspacev1alpha1.Workspace, *corev1.PersistentVolumeClaim, *corev1.Pod) {
+	ws := wsWithFinalizer(name, user)
+	ws.Status.LastAccessed = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: user + "-workspace-pvc", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: user + "-workspace-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "workspace", Image: "workspace:test"}},
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+			},
+		},
+	}
+	return ws, pvc, pod
+}
+
+func TestReconcile_NeverAccessedIdleTimeout_StopsUnusedWorkspaceSooner(t *testing.T) {
+	ws, pvc, pod := idleRunningObjects("unused-ws", "uma")
+	ws.CreationTimestamp = metav1.NewTime(time.Now().Add(-45 * time.Minute))
+	ws.Status.LastAccessed = metav1.Time{}
+	r, fc := newFakeReconciler(t, ws, pvc, pod)
+	r.IdleTimeout = 2 * time.Hour
+	r.NeverAccessedIdleTimeout = 30 * time.Minute
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+	if stored := getWS(t, fc, nn); stored.Status.Phase != workspacev1alpha1.WorkspacePhaseStopped {
+		t.Errorf("status.phase = %q, want Stopped after 45m unused with a 30m never-accessed timeout", stored.Status.Phase)
+	}
+	var p corev1.Pod
+	if err := fc.Get(context.Background(), client.ObjectKeyFromObject(pod), &p); err == nil {
+		t.Error("expected pod of the never-accessed workspace to be deleted")
+	}
+}
+
+func TestReconcile_NeverAccessedIdleTimeout_ActiveWorkspaceUsesIdleTimeout(t *testing.T) {
+	ctx := context.Background()
+	ws, pvc, pod := idleRunningObjects("used-ws", "ugo")
+	ws.CreationTimestamp = metav1.NewTime(time.Now().Add(-4 * time.Hour))
+	ws.Status.LastAccessed = metav1.NewTime(time.Now().Add(-45 * time.Minute))
+	r, fc := newFakeReconciler(t, ws, pvc, pod)
+	r.IdleTimeout = 2 * time.Hour
+	r.NeverAccessedIdleTimeout = 30 * time.Minute
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+	if stored := getWS(t, fc, nn); stored.Status.Phase != workspacev1alpha1.WorkspacePhaseRunning {
+		t.Fatalf("status.phase = %q, want Running: 45m idle is under the 2h timeout for a used workspace", stored.Status.Phase)
+	}
+
+	cur := getWS(t, fc, nn)
+	base := cur.DeepCopy()
+	cur.Status.LastAccessed = metav1.NewTime(time.Now().Add(-150 * time.Minute))
+	if err := fc.Status().Patch(ctx, &cur, client.MergeFrom(base)); err != nil {
+		t.Fatalf("patch lastAccessed: %v", err)
+	}
+	reconcileNN(t, r, nn)
+	if stored := getWS(t, fc, nn); stored.Status.Phase != workspacev1alpha1.WorkspacePhaseStopped {
+		t.Errorf("status.phase = %q, want Stopped after 150m idle", stored.Status.Phase)
+	}
+}
+
+func TestReconcile_IdleGracePeriod_WarnThenStop(t *testing.T) {
+	ctx := context.Background()
+	ws, pvc, pod := idleRunningObjects("idle-grace-ws", "iris")
+	r, fc := newFakeReconciler(t, ws, pvc, pod)
+	r.IdleTimeout = time.Hour
+	r.IdleGracePeriod = 10 * time.Minute
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: nn})
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if res.RequeueAfter <= 0 || res.RequeueAfter > 10*time.Minute {
+		t.Errorf("RequeueAfter = %v, want within the grace period", res.RequeueAfter)
+	}
+	podKey := types.NamespacedName{Name: "iris-workspace-pod", Namespace: "default"}
+	var p corev1.Pod
+	if err := fc.Get(ctx, podKey, &p); err != nil {
+		t.Fatalf("expected pod to remain during the grace period: %v", err)
+	}
+	stored := getWS(t, fc, nn)
+	if stored.Status.Phase != workspacev1alpha1.WorkspacePhaseRunning {
+		t.Errorf("status.phase = %q, want Running", stored.Status.Phase)
+	}
+	if stored.Status.IdleStopAt.IsZero() {
+		t.Fatal("expected status.idleStopAt to be set")
+	}
+	if !strings.Contains(stored.Status.Message, "stopping at") {
+		t.Errorf("status.message = %q, want a stopping-soon notice", stored.Status.Message)
+	}
+	if c := meta.FindStatusCondition(stored.Status.Conditions, workspace.ConditionTypeReady); c == nil || c.Reason != workspace.ReasonIdleStopPending {
+		t.Errorf("Ready condition = %+v, want reason %s", c, workspace.ReasonIdleStopPending)
+	}
+
+	// Grace period elapses with no activity.
+	stored.Status.IdleStopAt = metav1.NewTime(time.Now().Add(-time.Second))
+	if err := fc.Status().Update(ctx, &stored); err != nil {
+		t.Fatalf("backdate idleStopAt: %v", err)
+	}
+	reconcileNN(t, r, nn)
+
+	if err := fc.Get(ctx, podKey, &p); err == nil {
+		t.Error("expected pod to be deleted after the grace period")
+	}
+	stored = getWS(t, fc, nn)
+	if stored.Status.Phase != workspacev1alpha1.WorkspacePhaseStopped {
+		t.Errorf("status.phase = %q, want Stopped", stored.Status.Phase)
+	}
+	if !stored.Status.IdleStopAt.IsZero() {
+		t.Errorf("status.idleStopAt = %v, want cleared after stop", stored.Status.IdleStopAt)
+	}
+}
+
+func TestReconcile_IdleGracePeriod_ActivityCancelsStop(t *testing.T) {
+	ctx := context.Background()
+	ws, pvc, pod := idleRunningObjects("idle-reprieve-ws", "ines")
+	r, fc := newFakeReconciler(t, ws, pvc, pod)
+	r.IdleTimeout = time.Hour
+	r.IdleGracePeriod = 10 * time.Minute
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+	stored := getWS(t, fc, nn)
+	if stored.Status.IdleStopAt.IsZero() {
+		t.Fatal("expected status.idleStopAt to be set")
+	}
+
+	// The user comes back: the gateway stamps lastAccessed.
+	stored.Status.LastAccessed = metav1.Now()
+	if err := fc.Status().Update(ctx, &stored); err != nil {
+		t.Fatalf("update lastAccessed: %v", err)
+	}
+	reconcileNN(t, r, nn)
+
+	var p corev1.Pod
+	if err := fc.Get(ctx, types.NamespacedName{Name: "ines-workspace-pod", Namespace: "default"}, &p); err != nil {
+		t.Fatalf("expected pod to remain after activity: %v", err)
+	}
+	stored = getWS(t, fc, nn)
+	if !stored.Status.IdleStopAt.IsZero() {
+		t.Errorf("status.idleStopAt = %v, want cleared after activity", stored.Status.IdleStopAt)
+	}
+	if stored.Status.Phase != workspacev1alpha1.WorkspacePhaseRunning || stored.Status.Message != "" {
+		t.Errorf("status = %q/%q, want Running with no message", stored.Status.Phase, stored.Status.Message)
+	}
+}
+
+// TestReconcile_IdleStop_RateLimited reconciles three idle workspaces under a
+// limit with a burst of one: the first is stopped, the others stay Running and
+// requeue instead of deleting their pods in the same burst.
+func TestReconcile_IdleStop_RateLimited(t *testing.T) {
+	ctx := context.Background()
+	var objs []client.Object
+	var nns []types.NamespacedName
+	for i := range 3 {
+		ws, pvc, pod := idleRunningObjects(fmt.Sprintf("burst-ws-%d", i), fmt.Sprintf("burst%d", i))
+		objs = append(objs, ws, pvc, pod)
+		nns = append(nns, types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace})
+	}
+	r, fc := newFakeReconciler(t, objs...)
+	r.IdleTimeout = time.Hour
+	// One token, refilled every ~17 minutes: nothing refills during the test.
+	r.IdleStopsPerSecond = 0.001
+
+	for i, nn := range nns {
+		res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: nn})
+		if err != nil {
+			t.Fatalf("Reconcile %s: %v", nn.Name, err)
+		}
+		podKey := types.NamespacedName{Name: fmt.Sprintf("burst%d-workspace-pod", i), Namespace: "default"}
+		podErr := fc.Get(ctx, podKey, &corev1.Pod{})
+		if i == 0 {
+			if !apierrors.IsNotFound(podErr) {
+				t.Errorf("%s: Get pod err = %v, want the first idle pod deleted", nn.Name, podErr)
+			}
+			continue
+		}
+		if podErr != nil {
+			t.Errorf("%s: Get pod err = %v, want the pod kept while rate limited", nn.Name, podErr)
+		}
+		if res.RequeueAfter != idleStopRequeueInterval {
+			t.Errorf("%s: RequeueAfter = %s, want %s", nn.Name, res.RequeueAfter, idleStopRequeueInterval)
+		}
+	}
+}
+
 func TestReconcile_PodImageChanged(t *testing.T) {
 	ctx := context.Background()
 	ws := wsWithFinalizer("imgchange-ws", "judy")
@@ -863,6 +3029,263 @@ func TestReconcile_PodImageChanged(t *testing.T) {
 	}
 }
 
+func TestReconcile_RestoreReplacesPVC(t *testing.T) {
+	ctx := context.Background()
+	ws := wsWithFinalizer("restore-ws", "lena")
+	ws.Spec.Persistence.RestoreFrom = "before-upgrade"
+	ws.Annotations = map[string]string{workspace.RestoreRequestAnnotation: "t1"}
+
+	// The PVC predates the restore request, so it has no dataSource.
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "lena-workspace-pvc", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "lena-workspace-pod", Namespace: "default"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "workspace", Image: "workspace:test"}}},
+	}
+	r, fc := newFakeReconciler(t, ws, pvc, pod)
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	if err := fc.Get(ctx, client.ObjectKeyFromObject(pod), &corev1.Pod{}); !apierrors.IsNotFound(err) {
+		t.Errorf("pod get err = %v, want NotFound before the volume is replaced", err)
+	}
+	if err := fc.Get(ctx, client.ObjectKeyFromObject(pvc), &corev1.PersistentVolumeClaim{}); !apierrors.IsNotFound(err) {
+		t.Fatalf("PVC get err = %v, want NotFound so it is recreated from the snapshot", err)
+	}
+
+	// The next reconcile recreates the PVC from the checkpoint.
+	reconcileNN(t, r, nn)
+	var got corev1.PersistentVolumeClaim
+	if err := fc.Get(ctx, client.ObjectKeyFromObject(pvc), &got); err != nil {
+		t.Fatalf("get recreated PVC: %v", err)
+	}
+	if ds := got.Spec.DataSource; ds == nil || ds.Name != "before-upgrade" {
+		t.Errorf("dataSource = %+v, want before-upgrade", ds)
+	}
+	if workspace.RestorePending(ws, &got) {
+		t.Error("recreated PVC must satisfy the restore request")
+	}
+}
+
+func TestReconcile_PodPortChanged(t *testing.T) {
+	ctx := context.Background()
+	ws := wsWithFinalizer("portchange-ws", "kim")
+	ws.Spec.Port = 8080
+
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "kim-workspace-pvc", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	// Pod was created before spec.port changed and still serves on 7681.
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "kim-workspace-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:  "workspace",
+				Image: "workspace:test",
+				Ports: []corev1.ContainerPort{{Name: "ttyd", ContainerPort: 7681}},
+			}},
+		},
+	}
+	r, fc := newFakeReconciler(t, ws, pvc, pod)
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	var p corev1.Pod
+	if err := fc.Get(ctx, types.NamespacedName{Name: "kim-workspace-pod", Namespace: "default"}, &p); err == nil {
+		t.Error("expected pod to be deleted after spec.port change")
+	}
+}
+
+func TestReconcile_PodImageChanged_RolloutLimit(t *testing.T) {
+	ctx := context.Background()
+	users := []string{"amy", "ben", "cal", "dan", "eve"}
+	var objs []client.Object
+	for _, u := range users {
+		objs = append(objs,
+			wsWithFinalizer(u+"-ws", u),
+			&corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{Name: u + "-workspace-pvc", Namespace: "default"},
+				Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+			},
+			&corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: u + "-workspace-pod", Namespace: "default"},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "workspace", Image: "workspace:old"}},
+				},
+			},
+		)
+	}
+	r, fc := newFakeReconciler(t, objs...)
+	r.WorkspaceImage = "workspace:new"
+	r.MaxConcurrentImageRollouts = 2
+
+	remaining := func() int {
+		n := 0
+		for _, u := range users {
+			var p corev1.Pod
+			if err := fc.Get(ctx, types.NamespacedName{Name: u + "-workspace-pod", Namespace: "default"}, &p); err == nil && p.Spec.Containers[0].Image == "workspace:old" {
+				n++
+			}
+		}
+		return n
+	}
+
+	for _, u := range users {
+		reconcileNN(t, r, types.NamespacedName{Name: u + "-ws", Namespace: "default"})
+	}
+	if got := remaining(); got != len(users)-2 {
+		t.Fatalf("outdated pods after first batch = %d, want %d", got, len(users)-2)
+	}
+
+	// Slots stay held until the recreated pods are Ready, so a second pass
+	// recreates nothing more.
+	for _, u := range users {
+		reconcileNN(t, r, types.NamespacedName{Name: u + "-ws", Namespace: "default"})
+	}
+	if got := remaining(); got != len(users)-2 {
+		t.Errorf("outdated pods after second batch = %d, want %d", got, len(users)-2)
+	}
+
+	// The slots are recorded on the Workspaces, not in the reconciler.
+	for _, u := range users[:2] {
+		if ws := getWS(t, fc, types.NamespacedName{Name: u + "-ws", Namespace: "default"}); ws.Status.ImageRolloutStartedAt.IsZero() {
+			t.Errorf("%s: status.imageRolloutStartedAt not set", u)
+		}
+	}
+
+	// Once the recreated pods are Ready their slots free up for the next batch.
+	for _, u := range users[:2] {
+		var p corev1.Pod
+		if err := fc.Get(ctx, types.NamespacedName{Name: u + "-workspace-pod", Namespace: "default"}, &p); err != nil {
+			t.Fatalf("get recreated pod for %s: %v", u, err)
+		}
+		p.Status.Phase = corev1.PodRunning
+		p.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		if err := fc.Status().Update(ctx, &p); err != nil {
+			t.Fatalf("mark pod ready for %s: %v", u, err)
+		}
+	}
+	for _, u := range users {
+		reconcileNN(t, r, types.NamespacedName{Name: u + "-ws", Namespace: "default"})
+	}
+	if got := remaining(); got != len(users)-4 {
+		t.Errorf("outdated pods after third batch = %d, want %d", got, len(users)-4)
+	}
+}
+
+func TestReconcile_PodImageChanged_AutoUpdateDisabled(t *testing.T) {
+	ctx := context.Background()
+	ws := wsWithFinalizer("pinned-ws", "pat")
+	autoUpdate := false
+	ws.Spec.AutoUpdate = &autoUpdate
+
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "pat-workspace-pvc", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pat-workspace-pod", Namespace: "default"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "workspace", Image: "workspace:old"}},
+		},
+	}
+	r, fc := newFakeReconciler(t, ws, pvc, pod)
+	r.WorkspaceImage = "workspace:new"
+
+	nn := types.NamespacedName{Name: ws.Name, Namespace: ws.Namespace}
+	reconcileNN(t, r, nn)
+
+	var p corev1.Pod
+	if err := fc.Get(ctx, types.NamespacedName{Name: "pat-workspace-pod", Namespace: "default"}, &p); err != nil {
+		t.Fatalf("expected pod to be kept when autoUpdate is false: %v", err)
+	}
+	if p.Spec.Containers[0].Image != "workspace:old" {
+		t.Errorf("pod image = %q, want workspace:old", p.Spec.Containers[0].Image)
+	}
+	if stored := getWS(t, fc, nn); !stored.Status.UpdateAvailable {
+		t.Error("expected status.updateAvailable to be set")
+	}
+
+	// Once the pod runs the desired image the flag is cleared.
+	p.Spec.Containers[0].Image = "workspace:new"
+	if err := fc.Update(ctx, &p); err != nil {
+		t.Fatalf("Update Pod: %v", err)
+	}
+	reconcileNN(t, r, nn)
+	if stored := getWS(t, fc, nn); stored.Status.UpdateAvailable {
+		t.Error("expected status.updateAvailable to be cleared")
+	}
+}
+
+func TestReconcile_DependsOn_WaitsForRunningDependency(t *testing.T) {
+	ctx := context.Background()
+	db := wsWithFinalizer("db-ws", "dbuser")
+	app := wsWithFinalizer("app-ws", "appuser")
+	app.Spec.DependsOn = []string{"db-ws"}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "appuser-workspace-pvc", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	r, fc := newFakeReconciler(t, db, app, pvc)
+
+	nn := types.NamespacedName{Name: app.Name, Namespace: app.Namespace}
+	res, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: nn})
+	if err != nil {
+		t.Fatalf("Reconcile: %v", err)
+	}
+	if res.RequeueAfter != dependencyRequeueInterval {
+		t.Errorf("RequeueAfter = %v, want %v", res.RequeueAfter, dependencyRequeueInterval)
+	}
+	var pod corev1.Pod
+	podKey := types.NamespacedName{Name: "appuser-workspace-pod", Namespace: "default"}
+	if err := fc.Get(ctx, podKey, &pod); !apierrors.IsNotFound(err) {
+		t.Fatalf("expected no pod while dependency is not Running, got err=%v", err)
+	}
+	stored := getWS(t, fc, nn)
+	if stored.Status.Phase != workspacev1alpha1.WorkspacePhasePending {
+		t.Errorf("phase = %q, want Pending", stored.Status.Phase)
+	}
+	if !strings.Contains(stored.Status.Message, `"db-ws"`) {
+		t.Errorf("message = %q, want it to name the dependency", stored.Status.Message)
+	}
+
+	dbStored := getWS(t, fc, types.NamespacedName{Name: db.Name, Namespace: db.Namespace})
+	dbStored.Status.Phase = workspacev1alpha1.WorkspacePhaseRunning
+	if err := fc.Status().Update(ctx, &dbStored); err != nil {
+		t.Fatalf("update dependency status: %v", err)
+	}
+	reconcileNN(t, r, nn)
+	if err := fc.Get(ctx, podKey, &pod); err != nil {
+		t.Fatalf("expected pod once dependency is Running: %v", err)
+	}
+}
+
+func TestReconcile_DependsOn_Cycle(t *testing.T) {
+	a := wsWithFinalizer("a-ws", "auser")
+	a.Spec.DependsOn = []string{"b-ws"}
+	b := wsWithFinalizer("b-ws", "buser")
+	b.Spec.DependsOn = []string{"a-ws"}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "auser-workspace-pvc", Namespace: "default"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimBound},
+	}
+	r, fc := newFakeReconciler(t, a, b, pvc)
+
+	nn := types.NamespacedName{Name: a.Name, Namespace: a.Namespace}
+	reconcileNN(t, r, nn)
+	stored := getWS(t, fc, nn)
+	if stored.Status.Phase != workspacev1alpha1.WorkspacePhaseFailed {
+		t.Errorf("phase = %q, want Failed", stored.Status.Phase)
+	}
+	if want := "a-ws -> b-ws -> a-ws"; !strings.Contains(stored.Status.Message, want) {
+		t.Errorf("message = %q, want it to contain %q", stored.Status.Message, want)
+	}
+}
+
 func TestReconcile_DefaultWorkspaceImage(t *testing.T) {
 	ws := wsWithFinalizer("default-img-ws", "kim")
 	r, _ := newFakeReconciler(t, ws)
@@ -56,6 +56,18 @@ for p in providers:
         'options': {'baseURL': p['endpoint'] + '/v1', 'apiKey': 'no-key-required'},
         'models': {m: {'name': m} for m in p['models']}
     }
+# Operator-rendered spec.aiConfig.settings (ConfigMap mounted read-only).
+settings_file = os.environ.get('AI_SETTINGS_FILE', '')
+if settings_file and os.path.isfile(settings_file):
+    with open(settings_file) as f:
+        settings = json.load(f)
+    agent = {}
+    if 'temperature' in settings:
+        agent['temperature'] = settings['temperature']
+    if settings.get('systemPrompt'):
+        agent['prompt'] = settings['systemPrompt']
+    if agent:
+        cfg['agent'] = {'build': agent}
 print(json.dumps(cfg, indent=2))
 " > "${HOME}/.config/opencode/opencode.json"
 
@@ -67,6 +79,23 @@ if [ -n "${USER_ID:-}" ]; then
   git config --global user.name "${USER_ID}"
 fi
 
+# ── Repository pre-seed (first start only; never touches an existing checkout) ─
+if [ -n "${GIT_REPO_URL:-}" ]; then
+  repo_dir="${GIT_REPO_SUBPATH:-$(basename "${GIT_REPO_URL%/}" .git)}"
+  repo_dir="${HOME}/${repo_dir}"
+  if [ ! -e "${repo_dir}" ]; then
+    clone_args=()
+    if [ -n "${GIT_REPO_REF:-}" ]; then
+      clone_args+=(--branch "${GIT_REPO_REF}")
+    fi
+    mkdir -p "$(dirname "${repo_dir}")"
+    if ! git clone "${clone_args[@]}" -- "${GIT_REPO_URL}" "${repo_dir}"; then
+      echo "WARNING: cloning ${GIT_REPO_URL} failed; continuing without it" >&2
+      rm -rf "${repo_dir}"
+    fi
+  fi
+fi
+
 # ── zsh config (bootstrapped once; user can edit afterwards) ─────────────────
 if [ ! -f "${HOME}/.zshrc" ]; then
   cat > "${HOME}/.zshrc" <<'ZSHRC'
@@ -240,8 +269,9 @@ fi
 
 # ── ttyd ──────────────────────────────────────────────────────────────────────
 # exec replaces this process; ttyd serves the tmux attach command over
-# WebSocket on port 7681.  --writable allows keyboard input from the browser.
+# WebSocket on $TTYD_PORT (spec.port, default 7681).  --writable allows
+# keyboard input from the browser.
 # -t fontFamily: use Unicode-capable monospace fonts so OpenCode logo renders.
-exec ttyd --port 7681 --writable \
+exec ttyd --port "${TTYD_PORT:-7681}" --writable \
   -t 'fontFamily=JetBrains Mono, Fira Code, Cascadia Code, Monaco, Menlo, Consolas, monospace' \
   tmux attach-session -t workspace
@@ -2,22 +2,30 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 	"time"
 
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	workspacev1alpha1 "workspace-operator/api/v1alpha1"
 	"workspace-operator/controllers"
+	"workspace-operator/pkg/observability"
+	"workspace-operator/pkg/workspace"
 )
 
 var (
@@ -94,6 +102,17 @@ func main() {
 		}
 	}
 
+	// API_SERVER_EGRESS=true opens egress to the Kubernetes API server for every
+	// workspace. API_SERVER_CIDRS optionally lists the API server endpoint IPs or
+	// CIDRs; when unset they are read from the default/kubernetes EndpointSlices.
+	apiServerEgress := strings.EqualFold(strings.TrimSpace(os.Getenv("API_SERVER_EGRESS")), "true")
+	var apiServerCIDRs []string
+	for _, c := range strings.Split(os.Getenv("API_SERVER_CIDRS"), ",") {
+		if c = strings.TrimSpace(c); c != "" {
+			apiServerCIDRs = append(apiServerCIDRs, c)
+		}
+	}
+
 	// IDLE_TIMEOUT is an optional Go duration string (e.g. "24h", "8h30m") that
 	// controls how long a Running workspace may be idle before its pod is stopped.
 	// Zero or unset disables idle shutdown.
@@ -108,6 +127,161 @@ func main() {
 		}
 	}
 
+	// NEVER_ACCESSED_IDLE_TIMEOUT is an optional, shorter Go duration applied
+	// instead of the idle timeout to workspaces nobody has accessed since
+	// creation. Zero or unset uses the idle timeout for every workspace.
+	var neverAccessedIdleTimeout time.Duration
+	if raw := os.Getenv("NEVER_ACCESSED_IDLE_TIMEOUT"); raw != "" {
+		d, parseErr := time.ParseDuration(raw)
+		if parseErr != nil || d < 0 {
+			setupLog.Info("Ignoring invalid NEVER_ACCESSED_IDLE_TIMEOUT", "value", raw, "error", parseErr)
+		} else {
+			neverAccessedIdleTimeout = d
+			setupLog.Info("Never-accessed idle timeout configured", "neverAccessedIdleTimeout", neverAccessedIdleTimeout)
+		}
+	}
+
+	// IDLE_GRACE_PERIOD is an optional Go duration the operator waits after the
+	// idle timeout is reached before stopping the pod, so a returning user can
+	// keep the workspace alive. Zero or unset stops immediately.
+	var idleGracePeriod time.Duration
+	if raw := os.Getenv("IDLE_GRACE_PERIOD"); raw != "" {
+		d, parseErr := time.ParseDuration(raw)
+		if parseErr != nil || d < 0 {
+			setupLog.Info("Ignoring invalid IDLE_GRACE_PERIOD", "value", raw, "error", parseErr)
+		} else {
+			idleGracePeriod = d
+			setupLog.Info("Idle grace period configured", "idleGracePeriod", idleGracePeriod)
+		}
+	}
+
+	// STUCK_TERMINATING_TIMEOUT is an optional Go duration after which a workspace
+	// pod stuck Terminating is force-deleted. Defaults to
+	// controllers.DefaultStuckTerminatingTimeout; "0" disables the repair.
+	stuckTerminatingTimeout := controllers.DefaultStuckTerminatingTimeout
+	if raw := os.Getenv("STUCK_TERMINATING_TIMEOUT"); raw != "" {
+		d, parseErr := time.ParseDuration(raw)
+		if parseErr != nil || d < 0 {
+			setupLog.Info("Ignoring invalid STUCK_TERMINATING_TIMEOUT", "value", raw, "error", parseErr)
+		} else {
+			stuckTerminatingTimeout = d
+		}
+	}
+
+	// MIN_STORAGE is an optional resource quantity (e.g. "1Gi", "5Gi") below which
+	// spec.resources.storage is rejected. Defaults to workspace.DefaultMinStorage;
+	// "0" disables the floor.
+	minStorage := resource.MustParse(workspace.DefaultMinStorage)
+	if raw := os.Getenv("MIN_STORAGE"); raw != "" {
+		q, parseErr := resource.ParseQuantity(raw)
+		if parseErr != nil {
+			setupLog.Info("Ignoring invalid MIN_STORAGE", "value", raw, "error", parseErr)
+		} else {
+			minStorage = q
+		}
+	}
+
+	// MAX_PROVIDERS_JSON_BYTES is an optional limit on the serialized size of
+	// spec.aiConfig.providers. Defaults to workspace.DefaultMaxProvidersJSONBytes;
+	// "0" disables the limit.
+	maxProvidersJSONBytes := workspace.DefaultMaxProvidersJSONBytes
+	if raw := os.Getenv("MAX_PROVIDERS_JSON_BYTES"); raw != "" {
+		n, parseErr := strconv.Atoi(raw)
+		if parseErr != nil || n < 0 {
+			setupLog.Info("Ignoring invalid MAX_PROVIDERS_JSON_BYTES", "value", raw, "error", parseErr)
+		} else {
+			maxProvidersJSONBytes = n
+		}
+	}
+
+	// MAX_EGRESS_PORTS is an optional limit on the number of egress ports a
+	// workspace spec may list. Defaults to workspace.DefaultMaxEgressPorts;
+	// "0" disables the limit.
+	maxEgressPorts := workspace.DefaultMaxEgressPorts
+	if raw := os.Getenv("MAX_EGRESS_PORTS"); raw != "" {
+		n, parseErr := strconv.Atoi(raw)
+		if parseErr != nil || n < 0 {
+			setupLog.Info("Ignoring invalid MAX_EGRESS_PORTS", "value", raw, "error", parseErr)
+		} else {
+			maxEgressPorts = n
+		}
+	}
+
+	// WORKSPACE_RESOURCE_QUOTA=true creates an owned ResourceQuota next to each
+	// workspace, sized from its spec plus RESOURCE_QUOTA_HEADROOM_PERCENT
+	// (default workspace.DefaultResourceQuotaHeadroomPercent).
+	resourceQuota := strings.EqualFold(strings.TrimSpace(os.Getenv("WORKSPACE_RESOURCE_QUOTA")), "true")
+	resourceQuotaHeadroom := workspace.DefaultResourceQuotaHeadroomPercent
+	if raw := os.Getenv("RESOURCE_QUOTA_HEADROOM_PERCENT"); raw != "" {
+		n, parseErr := strconv.Atoi(raw)
+		if parseErr != nil || n < 0 {
+			setupLog.Info("Ignoring invalid RESOURCE_QUOTA_HEADROOM_PERCENT", "value", raw, "error", parseErr)
+		} else {
+			resourceQuotaHeadroom = n
+		}
+	}
+
+	// WORKSPACE_INGRESS=true creates an owned Ingress for each workspace at
+	// <user>.<WORKSPACE_INGRESS_BASE_DOMAIN>, authenticated by the ingress
+	// controller against WORKSPACE_INGRESS_AUTH_URL (required, e.g.
+	// oauth2-proxy). WORKSPACE_INGRESS_AUTH_SIGNIN, WORKSPACE_INGRESS_CLASS and
+	// WORKSPACE_INGRESS_CLUSTER_ISSUER (cert-manager) are optional;
+	// WORKSPACE_INGRESS_CONTROLLER_NAMESPACE lets the controller reach the pods.
+	var ingressOpts workspace.IngressOpts
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("WORKSPACE_INGRESS")), "true") {
+		ingressOpts = workspace.IngressOpts{
+			BaseDomain:    strings.TrimSpace(os.Getenv("WORKSPACE_INGRESS_BASE_DOMAIN")),
+			ClassName:     strings.TrimSpace(os.Getenv("WORKSPACE_INGRESS_CLASS")),
+			ClusterIssuer: strings.TrimSpace(os.Getenv("WORKSPACE_INGRESS_CLUSTER_ISSUER")),
+			AuthURL:       strings.TrimSpace(os.Getenv("WORKSPACE_INGRESS_AUTH_URL")),
+			AuthSignin:    strings.TrimSpace(os.Getenv("WORKSPACE_INGRESS_AUTH_SIGNIN")),
+		}
+		switch {
+		case ingressOpts.BaseDomain == "":
+			setupLog.Info("WORKSPACE_INGRESS is set but WORKSPACE_INGRESS_BASE_DOMAIN is empty; workspace Ingresses disabled")
+			ingressOpts = workspace.IngressOpts{}
+		case ingressOpts.AuthURL == "":
+			setupLog.Info("WORKSPACE_INGRESS is set but WORKSPACE_INGRESS_AUTH_URL is empty; workspace Ingresses disabled")
+			ingressOpts = workspace.IngressOpts{}
+		}
+	}
+	ingressControllerNamespace := strings.TrimSpace(os.Getenv("WORKSPACE_INGRESS_CONTROLLER_NAMESPACE"))
+
+	// WORKSPACE_EXTERNAL_DNS_HOSTNAME is an optional host template such as
+	// "{user}.ws.example.com" set as the external-dns hostname annotation on
+	// every workspace Service. It must contain {user}, or all workspaces would
+	// claim the same name.
+	externalDNSHostname := strings.TrimSpace(os.Getenv("WORKSPACE_EXTERNAL_DNS_HOSTNAME"))
+	if externalDNSHostname != "" && !strings.Contains(externalDNSHostname, "{user}") {
+		setupLog.Info("Ignoring invalid WORKSPACE_EXTERNAL_DNS_HOSTNAME; must contain {user}", "value", externalDNSHostname)
+		externalDNSHostname = ""
+	}
+
+	// MAX_CONCURRENT_IMAGE_ROLLOUTS is an optional cap on how many workspace pods
+	// are recreated for an image change at once. Defaults to
+	// controllers.DefaultMaxConcurrentImageRollouts; "0" disables the cap.
+	maxImageRollouts := controllers.DefaultMaxConcurrentImageRollouts
+	if raw := os.Getenv("MAX_CONCURRENT_IMAGE_ROLLOUTS"); raw != "" {
+		n, parseErr := strconv.Atoi(raw)
+		if parseErr != nil || n < 0 {
+			setupLog.Info("Ignoring invalid MAX_CONCURRENT_IMAGE_ROLLOUTS", "value", raw, "error", parseErr)
+		} else {
+			maxImageRollouts = n
+		}
+	}
+
+	// IDLE_STOPS_PER_SECOND bounds how fast idle workspaces are stopped, so a
+	// mass idle timeout does not burst the API server. "0" disables the limit.
+	idleStopsPerSecond := controllers.DefaultIdleStopsPerSecond
+	if raw := os.Getenv("IDLE_STOPS_PER_SECOND"); raw != "" {
+		f, parseErr := strconv.ParseFloat(raw, 64)
+		if parseErr != nil || f < 0 {
+			setupLog.Info("Ignoring invalid IDLE_STOPS_PER_SECOND", "value", raw, "error", parseErr)
+		} else {
+			idleStopsPerSecond = f
+		}
+	}
+
 	// GATEWAY_NAMESPACE is the namespace where gateway pods run.  It is used to
 	// add a cross-namespace NamespaceSelector to the ingress-gateway
 	// NetworkPolicy so that deny-all does not silently block gateway traffic.
@@ -116,29 +290,119 @@ func main() {
 		setupLog.Info("GATEWAY_NAMESPACE not set; ingress-gateway NetworkPolicy will only allow gateway pods in the workspace namespace")
 	}
 
+	// RECONCILE_FREEZE=true pauses reconciliation of all workspaces until the
+	// operator restarts without it. FREEZE_CONFIGMAP ("<namespace>/<name>")
+	// names a ConfigMap whose "frozen" key toggles the same freeze at runtime.
+	frozen := strings.EqualFold(strings.TrimSpace(os.Getenv("RECONCILE_FREEZE")), "true")
+	if frozen {
+		setupLog.Info("RECONCILE_FREEZE is set; workspace reconciliation is paused")
+	}
+	var freezeConfigMap types.NamespacedName
+	if raw := strings.TrimSpace(os.Getenv("FREEZE_CONFIGMAP")); raw != "" {
+		ns, name, ok := strings.Cut(raw, "/")
+		if !ok || ns == "" || name == "" {
+			setupLog.Info("Ignoring invalid FREEZE_CONFIGMAP (want <namespace>/<name>)", "value", raw)
+		} else {
+			freezeConfigMap = types.NamespacedName{Namespace: ns, Name: name}
+		}
+	}
+
 	defaultCABundle := os.Getenv("DEFAULT_CA_BUNDLE_CONFIGMAP")
+	// VALIDATE_CA_BUNDLE=true checks that CA bundle ConfigMaps hold parseable PEM
+	// certificates and reports bad keys on the CABundleValid condition.
+	validateCABundle := strings.EqualFold(strings.TrimSpace(os.Getenv("VALIDATE_CA_BUNDLE")), "true")
 	pipIndexURL := os.Getenv("PIP_INDEX_URL")
 	pipTrustedHost := os.Getenv("PIP_TRUSTED_HOST")
 	npmRegistry := os.Getenv("NPM_REGISTRY")
+	// WORKSPACE_RUNTIME_CLASS is an optional default RuntimeClass (e.g. gvisor,
+	// kata) for workspace pods; spec.runtimeClassName overrides it.
+	runtimeClassName := os.Getenv("WORKSPACE_RUNTIME_CLASS")
+	// SHARED_WORKSPACE_SERVICE_ACCOUNT=true runs all workspace pods in a
+	// namespace as one shared ServiceAccount instead of one per user.
+	sharedServiceAccount := strings.EqualFold(strings.TrimSpace(os.Getenv("SHARED_WORKSPACE_SERVICE_ACCOUNT")), "true")
+	// WORKSPACE_USE_DEPLOYMENT=true runs every workspace as a Deployment so a
+	// pod lost to eviction or a node failure is recreated automatically.
+	useDeployment := strings.EqualFold(strings.TrimSpace(os.Getenv("WORKSPACE_USE_DEPLOYMENT")), "true")
+	// WORKSPACE_DOWNWARD_API_PATH optionally mounts the pod's name, namespace
+	// and user label as files at this absolute path (e.g. /etc/devplane/pod).
+	downwardAPIPath := strings.TrimSpace(os.Getenv("WORKSPACE_DOWNWARD_API_PATH"))
+	if downwardAPIPath != "" && !path.IsAbs(downwardAPIPath) {
+		setupLog.Info("Ignoring invalid WORKSPACE_DOWNWARD_API_PATH; must be absolute", "value", downwardAPIPath)
+		downwardAPIPath = ""
+	}
+	// WORKSPACE_POD_ANNOTATIONS is an optional JSON object of annotations set on
+	// every workspace pod (e.g. {"sidecar.istio.io/inject":"false"});
+	// spec.podAnnotations overrides individual keys.
+	var defaultPodAnnotations map[string]string
+	if raw := strings.TrimSpace(os.Getenv("WORKSPACE_POD_ANNOTATIONS")); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &defaultPodAnnotations); err != nil {
+			setupLog.Info("Ignoring invalid WORKSPACE_POD_ANNOTATIONS; must be a JSON object of strings", "error", err.Error())
+			defaultPodAnnotations = nil
+		}
+	}
+	// WORKSPACE_RESOURCE_LABELS is an optional JSON object of label templates
+	// added to every object created for a workspace, for cost attribution
+	// (e.g. {"cost-center":"{label:team}"}). Keys must be valid label keys.
+	var resourceLabels map[string]string
+	if raw := strings.TrimSpace(os.Getenv("WORKSPACE_RESOURCE_LABELS")); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &resourceLabels); err != nil {
+			setupLog.Info("Ignoring invalid WORKSPACE_RESOURCE_LABELS; must be a JSON object of strings", "error", err.Error())
+			resourceLabels = nil
+		}
+		for key := range resourceLabels {
+			if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+				setupLog.Info("Ignoring invalid WORKSPACE_RESOURCE_LABELS key", "key", key, "error", strings.Join(errs, "; "))
+				delete(resourceLabels, key)
+			}
+		}
+	}
 
 	if err = (&controllers.WorkspaceReconciler{
-		Client:           mgr.GetClient(),
-		Scheme:           mgr.GetScheme(),
-		Recorder:         mgr.GetEventRecorder("workspace-controller"),
-		WorkspaceImage:   workspaceImage,
-		LLMNamespaces:    llmNamespaces,
-		EgressPorts:      egressPorts,
-		IdleTimeout:      idleTimeout,
-		GatewayNamespace: gatewayNamespace,
-		DefaultCABundle:  defaultCABundle,
-		PipIndexURL:      pipIndexURL,
-		PipTrustedHost:   pipTrustedHost,
-		NpmRegistry:      npmRegistry,
+		Client:                       mgr.GetClient(),
+		Scheme:                       mgr.GetScheme(),
+		Recorder:                     mgr.GetEventRecorder("workspace-controller"),
+		WorkspaceImage:               workspaceImage,
+		LLMNamespaces:                llmNamespaces,
+		EgressPorts:                  egressPorts,
+		IdleTimeout:                  idleTimeout,
+		NeverAccessedIdleTimeout:     neverAccessedIdleTimeout,
+		IdleGracePeriod:              idleGracePeriod,
+		StuckTerminatingTimeout:      stuckTerminatingTimeout,
+		MaxConcurrentImageRollouts:   maxImageRollouts,
+		IdleStopsPerSecond:           idleStopsPerSecond,
+		MinStorage:                   minStorage,
+		MaxProvidersJSONBytes:        maxProvidersJSONBytes,
+		MaxEgressPorts:               maxEgressPorts,
+		ResourceQuota:                resourceQuota,
+		ResourceQuotaHeadroomPercent: resourceQuotaHeadroom,
+		GatewayNamespace:             gatewayNamespace,
+		Ingress:                      ingressOpts,
+		IngressControllerNamespace:   ingressControllerNamespace,
+		ExternalDNSHostname:          externalDNSHostname,
+		DefaultCABundle:              defaultCABundle,
+		ValidateCABundle:             validateCABundle,
+		PipIndexURL:                  pipIndexURL,
+		PipTrustedHost:               pipTrustedHost,
+		NpmRegistry:                  npmRegistry,
+		RuntimeClassName:             runtimeClassName,
+		DownwardAPIPath:              downwardAPIPath,
+		DefaultPodAnnotations:        defaultPodAnnotations,
+		ResourceLabels:               resourceLabels,
+		SharedServiceAccount:         sharedServiceAccount,
+		UseDeployment:                useDeployment,
+		APIServerEgress:              apiServerEgress,
+		APIServerCIDRs:               apiServerCIDRs,
+		APIReader:                    mgr.GetAPIReader(),
+		Frozen:                       frozen,
+		FreezeConfigMap:              freezeConfigMap,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "Unable to create controller", "controller", "Workspace")
 		os.Exit(1)
 	}
 
+	// Count Workspaces by phase from the informer cache at scrape time.
+	crmetrics.Registry.MustRegister(observability.NewWorkspacePhaseCollector(mgr.GetCache()))
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "Unable to set up health check")
 		os.Exit(1)
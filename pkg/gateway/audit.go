@@ -36,8 +36,14 @@ const (
 	EventAuditWorkspaceEnsureRunning  = "devplane.audit.workspace.ensure_running"
 	EventAuditWSSessionStart          = "devplane.audit.ws.session.start"
 	EventAuditWSSessionEnd            = "devplane.audit.ws.session.end"
+	EventAuditWSViewStart             = "devplane.audit.ws.view.start"
+	EventAuditWSViewEnd               = "devplane.audit.ws.view.end"
 	EventAuditAuthTokenRejected       = "devplane.audit.auth.token.rejected"
 	EventAuditRateLimitExceeded       = "devplane.audit.rate_limit.exceeded"
+	EventAuditAdminCacheInvalidate    = "devplane.audit.admin.cache_invalidate"
+	EventAuditKubeconfigIssued        = "devplane.audit.kubeconfig.issued"
+	EventAuditCheckpointCreate        = "devplane.audit.checkpoint.create"
+	EventAuditCheckpointRestore       = "devplane.audit.checkpoint.restore"
 )
 
 // EnsureAction returns a stable verb for workspace lifecycle audit: create, restart, or get.
@@ -8,11 +8,13 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	gooidc "github.com/coreos/go-oidc/v3/oidc"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
 // ErrUnauthorized means the request is not authenticated (missing/invalid token).
@@ -25,20 +27,78 @@ var ErrForbidden = errors.New("forbidden")
 // ErrTokenExpired means the OIDC ID token is past its expiry (within verifier leeway).
 var ErrTokenExpired = errors.New("token expired")
 
+// ErrGroupNotAllowed means the token is valid but none of its groups is in the
+// configured allowlist. It wraps ErrForbidden.
+var ErrGroupNotAllowed = fmt.Errorf("%w: not a member of an allowed group", ErrForbidden)
+
+// DefaultGroupsClaim is the token claim read for group membership when
+// OIDCConfig.GroupsClaim is empty.
+const DefaultGroupsClaim = "groups"
+
 // OIDCConfig configures JWT validation against an OIDC issuer discovered at IssuerURL.
 // ClientID is the OAuth2 client identifier used for the browser authorization-code flow.
 // Audience is the expected JWT "aud" claim; when empty it defaults to ClientID.
-// ClockSkew adjusts the verifier "now" clock backward by this duration so slightly
-// expired tokens still validate (mitigate NTP skew between gateway and IdP). Zero
-// disables skew (strict expiry). The go-oidc library applies a separate fixed leeway
-// for the nbf claim.
+// ClockSkew tolerates this much NTP skew between gateway and IdP in both
+// directions: tokens still validate for ClockSkew past exp, and a token whose
+// nbf or iat lies in the future is accepted within max(ClockSkew, 5m). Zero
+// disables skew (strict expiry; go-oidc's fixed 5m nbf leeway still applies).
 type OIDCConfig struct {
-	IssuerURL  string
-	ClientID   string
-	Audience   string
-	ClockSkew  time.Duration
+	IssuerURL string
+	ClientID  string
+	Audience  string
+	// Audiences, when non-empty, replaces Audience: a token is accepted when
+	// its "aud" claim contains any of these values.
+	Audiences []string
+	ClockSkew time.Duration
+	// Discovery bounds retries of provider discovery (zero value: single attempt).
+	Discovery DiscoveryRetry
+	// GroupsClaim names the claim holding the user's groups or roles, e.g.
+	// "groups", "roles" or "realm_access.roles" (dots descend into objects).
+	// Defaults to DefaultGroupsClaim.
+	GroupsClaim string
+	// AllowedGroups, when non-empty, only admits tokens with at least one of
+	// these groups; other valid tokens fail with ErrGroupNotAllowed.
+	AllowedGroups []string
+	// UserIDPrefix qualifies UserIDs derived from this issuer's subjects as
+	// "<prefix>--<sub>", so equal subjects from different issuers never map
+	// to the same workspace, and its group names as "<prefix>:<group>", so
+	// AllowedGroups and spec.sharing.groups only match them when written
+	// that way. It must be lowercase alphanumeric starting with a letter.
+	// MultiValidator allows at most one issuer without a prefix.
+	UserIDPrefix string
+	// ValidatorConfig tunes the verified-token cache.
+	ValidatorConfig
+}
+
+// ValidatorConfig bounds the Validator's in-memory token cache. CacheTTL is how
+// long a verified token is trusted without re-verification (and how often
+// expired entries are swept); CacheMax caps the number of cached tokens before
+// least-recently-used entries are evicted. Zero values use the defaults (5m,
+// 10000).
+type ValidatorConfig struct {
+	CacheTTL time.Duration
+	CacheMax int
+	// Registerer, when set, receives the token cache hit, miss, eviction and
+	// size metrics. Nil disables them.
+	Registerer prometheus.Registerer
+}
+
+// DiscoveryRetry bounds OIDC provider discovery retries so a briefly unreachable
+// IdP (for example during a coordinated restart) does not crash the gateway.
+// Attempts <= 1 disables retries. Backoff is the initial wait between attempts
+// and doubles after each failure, capped at maxDiscoveryBackoff.
+type DiscoveryRetry struct {
+	Attempts int
+	Backoff  time.Duration
 }
 
+const maxDiscoveryBackoff = 30 * time.Second
+
+// minIssuedLeeway is the least tolerance for nbf/iat in the future, matching
+// the fixed leeway go-oidc applies to nbf when it checks expiry itself.
+const minIssuedLeeway = 5 * time.Minute
+
+// Defaults for ValidatorConfig.
 const (
 	tokenCacheTTL = 5 * time.Minute
 	tokenCacheMax = 10_000 // maximum number of entries to prevent unbounded growth
@@ -52,16 +112,31 @@ type Claims struct {
 	Email string
 	// UserID is a Kubernetes-safe name derived from Sub (DNS label format).
 	UserID string
+	// Groups lists the groups or roles from the configured groups claim,
+	// qualified as "<prefix>:<group>" for an issuer with a UserIDPrefix.
+	Groups []string
 }
 
-// Validator verifies OIDC bearer tokens and caches results for tokenCacheTTL.
-// The cache is bounded to tokenCacheMax entries using an LRU eviction policy so
+// Validator verifies OIDC bearer tokens and caches results for cacheTTL.
+// The cache is bounded to cacheMax entries using an LRU eviction policy so
 // that a large number of distinct users cannot cause unbounded memory growth.
 type Validator struct {
+	issuer   string // IssuerURL, used by MultiValidator to route tokens
 	verifier *gooidc.IDTokenVerifier
 	mu       sync.Mutex
 	index    map[string]*list.Element // hash → LRU list element
 	lru      *list.List               // front = most recently used
+	cacheTTL time.Duration
+	cacheMax int
+	metrics  *tokenCacheMetrics // nil records nothing
+
+	audiences []string      // empty leaves the aud check to go-oidc
+	clockSkew time.Duration // > 0 means exp/nbf/iat are checked in checkValidity
+
+	groupsClaim   string              // empty skips group extraction
+	allowedGroups map[string]struct{} // empty admits every group
+
+	userIDPrefix string // OIDCConfig.UserIDPrefix; empty leaves UserIDs unqualified
 }
 
 type cachedEntry struct {
@@ -72,6 +147,11 @@ type cachedEntry struct {
 
 var nonAlphaNum = regexp.MustCompile(`[^a-z0-9]+`)
 
+// userIDPrefixRegex admits OIDCConfig.UserIDPrefix values. Without hyphens
+// the "--" separator cannot be produced by sanitizeUserID, so prefixed and
+// unprefixed UserIDs never collide.
+var userIDPrefixRegex = regexp.MustCompile(`^[a-z][a-z0-9]*$`)
+
 // sanitizeUserID converts an OIDC sub into a Kubernetes DNS-label-safe string.
 // E.g. "user|12345" → "user-12345", truncated to 63 chars.
 // Keycloak/UUID subs that start with a digit get a "u-" prefix so the result
@@ -91,9 +171,36 @@ func sanitizeUserID(sub string) string {
 	return s
 }
 
+// qualifyUserID derives the UserID for sub, prefixed with "<prefix>--" when
+// prefix is set. The result is truncated like sanitizeUserID's.
+func qualifyUserID(prefix, sub string) string {
+	id := sanitizeUserID(sub)
+	if prefix == "" {
+		return id
+	}
+	id = prefix + "--" + id
+	if len(id) > 49 {
+		id = strings.TrimRight(id[:49], "-")
+	}
+	return id
+}
+
+// qualifyGroups prefixes each group with "<prefix>:" when prefix is set, so a
+// group from one issuer never matches the same name from another.
+func qualifyGroups(prefix string, groups []string) []string {
+	if prefix == "" {
+		return groups
+	}
+	out := make([]string, len(groups))
+	for i, g := range groups {
+		out[i] = prefix + ":" + g
+	}
+	return out
+}
+
 // NewValidator creates a Validator that accepts tokens from issuerURL for clientID.
 // It performs OIDC discovery to fetch the provider's JWKS endpoint.
-// A background goroutine evicts expired cache entries every tokenCacheTTL.
+// A background goroutine evicts expired cache entries every cache TTL.
 func NewValidator(ctx context.Context, issuerURL, clientID string) (*Validator, error) {
 	return NewValidatorWithOIDC(ctx, OIDCConfig{IssuerURL: issuerURL, ClientID: clientID})
 }
@@ -103,28 +210,87 @@ func NewValidatorWithOIDC(ctx context.Context, cfg OIDCConfig) (*Validator, erro
 	if cfg.IssuerURL == "" || cfg.ClientID == "" {
 		return nil, fmt.Errorf("OIDC IssuerURL and ClientID are required")
 	}
+	if cfg.UserIDPrefix != "" && !userIDPrefixRegex.MatchString(cfg.UserIDPrefix) {
+		return nil, fmt.Errorf("OIDC UserIDPrefix %q must be lowercase alphanumeric and start with a letter", cfg.UserIDPrefix)
+	}
 	audience := cfg.Audience
 	if audience == "" {
 		audience = cfg.ClientID
 	}
-	provider, err := gooidc.NewProvider(ctx, cfg.IssuerURL)
+	provider, err := DiscoverProvider(ctx, cfg.IssuerURL, cfg.Discovery)
 	if err != nil {
-		return nil, fmt.Errorf("OIDC provider discovery %q: %w", cfg.IssuerURL, err)
+		return nil, err
 	}
 	verifyCfg := &gooidc.Config{ClientID: audience}
+	if len(cfg.Audiences) > 0 {
+		// go-oidc matches a single client ID; checkAudience handles the list.
+		verifyCfg.SkipClientIDCheck = true
+	}
 	if cfg.ClockSkew > 0 {
-		skew := cfg.ClockSkew
-		verifyCfg.Now = func() time.Time { return time.Now().Add(-skew) }
+		// go-oidc can only shift its clock one way, which would also shrink
+		// its nbf leeway; checkValidity applies the skew in both directions.
+		verifyCfg.SkipExpiryCheck = true
 	}
 	v := &Validator{
-		verifier: provider.Verifier(verifyCfg),
-		index:    make(map[string]*list.Element),
-		lru:      list.New(),
+		issuer:      cfg.IssuerURL,
+		verifier:    provider.Verifier(verifyCfg),
+		index:       make(map[string]*list.Element),
+		lru:         list.New(),
+		cacheTTL:    cfg.CacheTTL,
+		cacheMax:    cfg.CacheMax,
+		metrics:     newTokenCacheMetrics(cfg.Registerer, cfg.IssuerURL),
+		audiences:   cfg.Audiences,
+		clockSkew:   cfg.ClockSkew,
+		groupsClaim: cfg.GroupsClaim,
+
+		userIDPrefix: cfg.UserIDPrefix,
+	}
+	if v.cacheTTL <= 0 {
+		v.cacheTTL = tokenCacheTTL
+	}
+	if v.cacheMax <= 0 {
+		v.cacheMax = tokenCacheMax
+	}
+	if v.groupsClaim == "" {
+		v.groupsClaim = DefaultGroupsClaim
+	}
+	if len(cfg.AllowedGroups) > 0 {
+		v.allowedGroups = make(map[string]struct{}, len(cfg.AllowedGroups))
+		for _, g := range cfg.AllowedGroups {
+			v.allowedGroups[g] = struct{}{}
+		}
 	}
 	go v.evictExpired(ctx)
 	return v, nil
 }
 
+// DiscoverProvider performs OIDC discovery against issuerURL, retrying with
+// exponential backoff per retry. It gives up early when ctx is cancelled.
+func DiscoverProvider(ctx context.Context, issuerURL string, retry DiscoveryRetry) (*gooidc.Provider, error) {
+	attempts := max(retry.Attempts, 1)
+	backoff := retry.Backoff
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		provider, err := gooidc.NewProvider(ctx, issuerURL)
+		if err == nil {
+			return provider, nil
+		}
+		lastErr = err
+		if attempt == attempts {
+			break
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("OIDC provider discovery %q: %w (last error: %v)", issuerURL, ctx.Err(), lastErr)
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, maxDiscoveryBackoff)
+	}
+	return nil, fmt.Errorf("OIDC provider discovery %q failed after %d attempt(s): %w", issuerURL, attempts, lastErr)
+}
+
 func classifyOIDCVerifyError(err error) error {
 	if err == nil {
 		return nil
@@ -143,7 +309,7 @@ func classifyOIDCVerifyError(err error) error {
 
 // evictExpired periodically removes expired entries from the token cache.
 func (v *Validator) evictExpired(ctx context.Context) {
-	ticker := time.NewTicker(tokenCacheTTL)
+	ticker := time.NewTicker(v.cacheTTL)
 	defer ticker.Stop()
 	for {
 		select {
@@ -152,19 +318,23 @@ func (v *Validator) evictExpired(ctx context.Context) {
 		case <-ticker.C:
 			now := time.Now()
 			v.mu.Lock()
+			n := 0
 			for key, elem := range v.index {
 				if now.After(elem.Value.(*cachedEntry).expiry) {
 					v.lru.Remove(elem)
 					delete(v.index, key)
+					n++
 				}
 			}
+			v.metrics.evicted(n, v.lru.Len())
 			v.mu.Unlock()
 		}
 	}
 }
 
 // Validate verifies rawToken and returns the associated Claims.
-// Valid tokens are cached for tokenCacheTTL to reduce IdP round-trips.
+// Valid tokens are cached for the configured TTL, or until they expire if that
+// is sooner, to reduce IdP round-trips.
 func (v *Validator) Validate(ctx context.Context, rawToken string) (*Claims, error) {
 	key := hashToken(rawToken)
 
@@ -174,19 +344,31 @@ func (v *Validator) Validate(ctx context.Context, rawToken string) (*Claims, err
 		if time.Now().Before(entry.expiry) {
 			v.lru.MoveToFront(elem)
 			claims := entry.claims
+			v.metrics.hit()
 			v.mu.Unlock()
+			if err := v.authorize(claims); err != nil {
+				return nil, err
+			}
 			return claims, nil
 		}
 		// Expired entry — evict eagerly rather than waiting for the background ticker.
 		v.lru.Remove(elem)
 		delete(v.index, key)
+		v.metrics.evicted(1, v.lru.Len())
 	}
+	v.metrics.miss()
 	v.mu.Unlock()
 
 	idToken, err := v.verifier.Verify(ctx, rawToken)
 	if err != nil {
 		return nil, classifyOIDCVerifyError(err)
 	}
+	if err := v.checkAudience(idToken); err != nil {
+		return nil, err
+	}
+	if err := v.checkValidity(idToken, time.Now()); err != nil {
+		return nil, err
+	}
 
 	var raw struct {
 		Email string `json:"email"`
@@ -198,25 +380,163 @@ func (v *Validator) Validate(ctx context.Context, rawToken string) (*Claims, err
 	claims := &Claims{
 		Sub:    idToken.Subject,
 		Email:  raw.Email,
-		UserID: sanitizeUserID(idToken.Subject),
+		UserID: qualifyUserID(v.userIDPrefix, idToken.Subject),
 	}
+	if v.groupsClaim != "" {
+		var all map[string]any
+		if err := idToken.Claims(&all); err != nil {
+			return nil, fmt.Errorf("%w: extract claims: %v", ErrUnauthorized, err)
+		}
+		claims.Groups = qualifyGroups(v.userIDPrefix, groupsFromClaims(all, v.groupsClaim))
+	}
+
+	v.store(key, claims, idToken.Expiry)
 
+	if err := v.authorize(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// checkAudience accepts idToken when its aud claim contains one of the
+// configured audiences. Without a list go-oidc has already checked aud.
+func (v *Validator) checkAudience(idToken *gooidc.IDToken) error {
+	if len(v.audiences) == 0 {
+		return nil
+	}
+	for _, aud := range idToken.Audience {
+		if slices.Contains(v.audiences, aud) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: token audience %q not in %q", ErrForbidden, idToken.Audience, v.audiences)
+}
+
+// checkValidity enforces exp, nbf and iat with clockSkew tolerance. It only
+// runs when clockSkew > 0; otherwise go-oidc has already checked expiry.
+func (v *Validator) checkValidity(idToken *gooidc.IDToken, now time.Time) error {
+	if v.clockSkew <= 0 {
+		return nil
+	}
+	if now.Add(-v.clockSkew).After(idToken.Expiry) {
+		return fmt.Errorf("%w: token expired at %s", ErrTokenExpired, idToken.Expiry.UTC().Format(time.RFC3339))
+	}
+	var raw struct {
+		NotBefore *float64 `json:"nbf"`
+	}
+	if err := idToken.Claims(&raw); err != nil {
+		return fmt.Errorf("%w: extract claims: %v", ErrUnauthorized, err)
+	}
+	latest := now.Add(max(v.clockSkew, minIssuedLeeway))
+	if raw.NotBefore != nil {
+		if nbf := time.Unix(int64(*raw.NotBefore), 0); latest.Before(nbf) {
+			return fmt.Errorf("%w: token not valid before %s", ErrUnauthorized, nbf.UTC().Format(time.RFC3339))
+		}
+	}
+	if latest.Before(idToken.IssuedAt) {
+		return fmt.Errorf("%w: token used before issued (iat %s)", ErrUnauthorized, idToken.IssuedAt.UTC().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// store caches claims under key until cacheTTL passes or the token expires at
+// tokenExpiry, whichever comes first (a zero tokenExpiry only applies the TTL).
+// Least-recently-used entries are evicted once the cache holds cacheMax tokens.
+func (v *Validator) store(key string, claims *Claims, tokenExpiry time.Time) {
 	v.mu.Lock()
-	// Evict the LRU entry if we have reached the capacity limit.
-	for v.lru.Len() >= tokenCacheMax {
+	defer v.mu.Unlock()
+	for v.lru.Len() >= v.cacheMax {
 		oldest := v.lru.Back()
 		if oldest == nil {
 			break
 		}
 		v.lru.Remove(oldest)
 		delete(v.index, oldest.Value.(*cachedEntry).key)
+		v.metrics.evicted(1, v.lru.Len())
 	}
-	entry := &cachedEntry{key: key, claims: claims, expiry: time.Now().Add(tokenCacheTTL)}
-	elem := v.lru.PushFront(entry)
-	v.index[key] = elem
-	v.mu.Unlock()
+	expiry := time.Now().Add(v.cacheTTL)
+	if !tokenExpiry.IsZero() && tokenExpiry.Before(expiry) {
+		expiry = tokenExpiry
+	}
+	entry := &cachedEntry{key: key, claims: claims, expiry: expiry}
+	v.index[key] = v.lru.PushFront(entry)
+	v.metrics.setSize(v.lru.Len())
+}
 
-	return claims, nil
+// authorize checks claims against the allowed-groups list.
+func (v *Validator) authorize(claims *Claims) error {
+	if len(v.allowedGroups) == 0 {
+		return nil
+	}
+	for _, g := range claims.Groups {
+		if _, ok := v.allowedGroups[g]; ok {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: user %q", ErrGroupNotAllowed, claims.UserID)
+}
+
+// groupsFromClaims reads the group names at claim, a dot-separated path into
+// the token claims. The value may be a list of strings or a single string;
+// anything else yields no groups.
+func groupsFromClaims(all map[string]any, claim string) []string {
+	var val any = all
+	for _, part := range strings.Split(claim, ".") {
+		m, ok := val.(map[string]any)
+		if !ok {
+			return nil
+		}
+		val = m[part]
+	}
+	switch g := val.(type) {
+	case string:
+		return []string{g}
+	case []any:
+		groups := make([]string, 0, len(g))
+		for _, item := range g {
+			if s, ok := item.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+		return groups
+	}
+	return nil
+}
+
+// Evict removes every cached entry for which match returns true and reports how
+// many entries were removed. Evicted tokens are re-verified against the IdP on
+// their next use, so revoked sessions stop being served from the cache at once.
+func (v *Validator) Evict(match func(tokenHash string, claims *Claims) bool) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	n := 0
+	for key, elem := range v.index {
+		if match(key, elem.Value.(*cachedEntry).claims) {
+			v.lru.Remove(elem)
+			delete(v.index, key)
+			n++
+		}
+	}
+	v.metrics.setSize(v.lru.Len())
+	return n
+}
+
+// EvictUser evicts cached tokens whose UserID equals user. The raw Sub is
+// also matched when this issuer's UserIDs are unprefixed; a prefixed issuer's
+// subjects may equal another issuer's, so only its UserIDs identify a user.
+func (v *Validator) EvictUser(user string) int {
+	return v.Evict(func(_ string, c *Claims) bool {
+		return c.UserID == user || v.userIDPrefix == "" && c.Sub == user
+	})
+}
+
+// EvictTokenHash evicts the cached entry for a token identified by its
+// hex-encoded SHA-256 hash (the cache key; raw tokens are never stored).
+func (v *Validator) EvictTokenHash(tokenHash string) int {
+	tokenHash = strings.ToLower(tokenHash)
+	return v.Evict(func(key string, _ *Claims) bool {
+		return key == tokenHash
+	})
 }
 
 func hashToken(raw string) string {
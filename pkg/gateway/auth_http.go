@@ -3,7 +3,11 @@ package gateway
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 // Stable JSON error codes for machine-readable API responses.
@@ -16,10 +20,113 @@ const (
 	WorkspaceErrorCodeUnavailable = "workspace_unavailable"
 	// WorkspaceErrorCodeNotReady is returned when the workspace pod is not listening on ttyd yet.
 	WorkspaceErrorCodeNotReady = "workspace_not_ready"
+	// WorkspaceErrorCodeProvisioningBusy is returned with HTTP 503 when too many
+	// workspaces are already being waited on (GATEWAY_MAX_PROVISIONING_WAITS).
+	WorkspaceErrorCodeProvisioningBusy = "workspace_provisioning_busy"
 	// RateLimitErrorCode is returned with HTTP 429 when a gateway rate limit is exceeded.
 	RateLimitErrorCode = "rate_limited"
+	// TimeoutErrorCode is returned with HTTP 503 when a non-WebSocket handler exceeds its deadline.
+	TimeoutErrorCode = "request_timeout"
+	// WorkspaceErrorCodeElsewhere is returned with HTTP 409 when the user's
+	// workspace lives in a different namespace than this gateway serves.
+	WorkspaceErrorCodeElsewhere = "workspace_in_other_namespace"
+	// WorkspaceErrorCodeQuotaExceeded is returned with HTTP 429 when the user
+	// already has GATEWAY_MAX_WORKSPACES_PER_USER workspaces.
+	WorkspaceErrorCodeQuotaExceeded = "workspace_quota_exceeded"
+	// WorkspaceErrorCodeReadyTimeout is returned with HTTP 504 when the workspace
+	// did not reach Running within WORKSPACE_READY_TIMEOUT.
+	WorkspaceErrorCodeReadyTimeout = "workspace_ready_timeout"
+	// WorkspaceErrorCodeSuspended is returned with HTTP 409 when the workspace
+	// is stopped by spec.suspended and will not be restarted by the gateway.
+	WorkspaceErrorCodeSuspended = "workspace_suspended"
+	// MethodNotAllowedErrorCode is returned with HTTP 405 when an /api/*
+	// endpoint is called with an unsupported method.
+	MethodNotAllowedErrorCode = "method_not_allowed"
+	// InvalidRequestErrorCode is returned with HTTP 400 when an /api/* request
+	// body or parameter is malformed.
+	InvalidRequestErrorCode = "invalid_request"
+	// CheckpointErrorCodeNotFound is returned with HTTP 404 when the named
+	// checkpoint does not exist for the caller.
+	CheckpointErrorCodeNotFound = "checkpoint_not_found"
+	// CheckpointErrorCodeExists is returned with HTTP 409 when a checkpoint
+	// with the requested name already exists.
+	CheckpointErrorCodeExists = "checkpoint_exists"
+	// CheckpointErrorCodeNotReady is returned with HTTP 409 when restoring
+	// from a snapshot that is not ready to use yet.
+	CheckpointErrorCodeNotReady = "checkpoint_not_ready"
+	// CheckpointErrorCodeUnsupported is returned with HTTP 409 for ephemeral
+	// workspaces, which have no volume to checkpoint.
+	CheckpointErrorCodeUnsupported = "checkpoint_unsupported"
 )
 
+// apiErrorMessages holds the human-readable message sent alongside each code
+// by WriteAPIError. Codes without an entry fall back to http.StatusText.
+var apiErrorMessages = map[string]string{
+	AuthErrorCodeUnauthorized:          "Authentication is required.",
+	AuthErrorCodeForbidden:             "You are not allowed to access this resource.",
+	AuthErrorCodeTokenExpired:          "Your session has expired; sign in again.",
+	WorkspaceErrorCodeUnavailable:      "The workspace could not be read or created.",
+	WorkspaceErrorCodeNotReady:         "The workspace is not ready yet.",
+	WorkspaceErrorCodeProvisioningBusy: "Too many workspaces are being provisioned; retry shortly.",
+	RateLimitErrorCode:                 "Too many requests; retry shortly.",
+	TimeoutErrorCode:                   "The request timed out.",
+	WorkspaceErrorCodeElsewhere:        "Your workspace is served by the gateway for another namespace.",
+	WorkspaceErrorCodeQuotaExceeded:    "You have reached the maximum number of workspaces.",
+	WorkspaceErrorCodeReadyTimeout:     "The workspace did not become ready in time.",
+	WorkspaceErrorCodeSuspended:        "Your workspace is suspended; clear spec.suspended to resume it.",
+	MethodNotAllowedErrorCode:          "Method not allowed.",
+	InvalidRequestErrorCode:            "The request is malformed.",
+	CheckpointErrorCodeNotFound:        "No checkpoint with that name exists for your workspace.",
+	CheckpointErrorCodeExists:          "A checkpoint with that name already exists.",
+	CheckpointErrorCodeNotReady:        "The checkpoint is not ready to restore from yet.",
+	CheckpointErrorCodeUnsupported:     "Ephemeral workspaces have no volume to checkpoint.",
+}
+
+// APIError is the JSON error body returned by the /api/* endpoints. Error is
+// the stable machine-readable code, Message a human-readable explanation and
+// Code the HTTP status.
+type APIError struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+	// Namespace names the namespace holding the user's workspace for
+	// WorkspaceErrorCodeElsewhere.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// NewAPIError returns the APIError for code with its standard message.
+func NewAPIError(status int, code string) APIError {
+	msg, ok := apiErrorMessages[code]
+	if !ok {
+		msg = http.StatusText(status)
+	}
+	return APIError{Error: code, Message: msg, Code: status}
+}
+
+// WriteAPIError writes {"error": code, "message": ..., "code": status} with
+// Content-Type application/json. Browser-facing routes keep WriteJSONError.
+func WriteAPIError(w http.ResponseWriter, status int, code string) {
+	writeAPIError(w, NewAPIError(status, code))
+}
+
+// WriteAPIWorkspaceElsewhere is WriteAPIError for WorkspaceElsewhereError: a
+// 409 that also names the namespace holding the user's existing workspace.
+func WriteAPIWorkspaceElsewhere(w http.ResponseWriter, e *WorkspaceElsewhereError) {
+	body := NewAPIError(http.StatusConflict, WorkspaceErrorCodeElsewhere)
+	body.Message = fmt.Sprintf("Your workspace is served from namespace %q; use the DevPlane gateway for that namespace.", e.Namespace)
+	body.Namespace = e.Namespace
+	writeAPIError(w, body)
+}
+
+func writeAPIError(w http.ResponseWriter, body APIError) {
+	RecordJSONAPIError(body.Code, body.Error)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(body.Code)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(true)
+	_ = enc.Encode(body)
+}
+
 // WriteJSONAuthError writes {"error": code} with Content-Type application/json.
 func WriteJSONAuthError(w http.ResponseWriter, status int, code string) {
 	WriteJSONError(w, status, code)
@@ -35,6 +142,24 @@ func WriteJSONError(w http.ResponseWriter, status int, code string) {
 	_ = enc.Encode(map[string]string{"error": code})
 }
 
+// WriteJSONWorkspaceElsewhere writes a 409 naming the namespace that holds the
+// user's existing workspace, so clients can point the user at the right gateway.
+func WriteJSONWorkspaceElsewhere(w http.ResponseWriter, e *WorkspaceElsewhereError) {
+	RecordJSONAPIError(http.StatusConflict, WorkspaceErrorCodeElsewhere)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusConflict)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(true)
+	_ = enc.Encode(map[string]string{"error": WorkspaceErrorCodeElsewhere, "namespace": e.Namespace})
+}
+
+// SetRetryAfter sets the Retry-After header to d, rounded up to whole seconds
+// (minimum 1). Call it before writing the status of a retryable response.
+func SetRetryAfter(w http.ResponseWriter, d time.Duration) {
+	secs := max(int(math.Ceil(d.Seconds())), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+}
+
 // AuthErrorResponse interprets errors returned from token validation for HTTP APIs.
 func AuthErrorResponse(err error) (status int, code string) {
 	if err == nil {
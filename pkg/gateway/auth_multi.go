@@ -0,0 +1,111 @@
+package gateway
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// MultiValidator accepts tokens from several OIDC issuers (e.g. a corporate
+// IdP plus a contractor IdP). Each token is routed by its unverified "iss"
+// claim to the Validator for that issuer; the chosen Validator still checks
+// the signature and issuer. Tokens whose issuer matches no Validator are
+// tried against each in turn, so formatting differences such as a trailing
+// slash do not lock users out.
+type MultiValidator struct {
+	validators []*Validator
+	byIssuer   map[string]*Validator
+}
+
+// NewMultiValidator combines validators, each bound to a distinct issuer and
+// a distinct UserIDPrefix. At most one validator may leave the prefix empty:
+// otherwise equal subjects from two issuers would share a workspace.
+func NewMultiValidator(validators ...*Validator) (*MultiValidator, error) {
+	m := &MultiValidator{byIssuer: make(map[string]*Validator, len(validators))}
+	prefixes := make(map[string]string, len(validators))
+	for _, v := range validators {
+		key := issuerKey(v.issuer)
+		if _, dup := m.byIssuer[key]; dup {
+			return nil, fmt.Errorf("duplicate OIDC issuer %q", v.issuer)
+		}
+		if other, dup := prefixes[v.userIDPrefix]; dup {
+			if v.userIDPrefix == "" {
+				return nil, fmt.Errorf("OIDC issuers %q and %q both lack a user ID prefix; their subjects could collide", other, v.issuer)
+			}
+			return nil, fmt.Errorf("OIDC issuers %q and %q share user ID prefix %q", other, v.issuer, v.userIDPrefix)
+		}
+		prefixes[v.userIDPrefix] = v.issuer
+		m.byIssuer[key] = v
+		m.validators = append(m.validators, v)
+	}
+	return m, nil
+}
+
+// Validate verifies rawToken with the Validator for its issuer.
+func (m *MultiValidator) Validate(ctx context.Context, rawToken string) (*Claims, error) {
+	if v, ok := m.byIssuer[issuerKey(unverifiedIssuer(rawToken))]; ok {
+		return v.Validate(ctx, rawToken)
+	}
+	var firstErr error
+	for _, v := range m.validators {
+		claims, err := v.Validate(ctx, rawToken)
+		if err == nil {
+			return claims, nil
+		}
+		// Expiry and authorization failures mean some issuer recognised the
+		// token; report those over plain signature/issuer mismatches.
+		if errors.Is(err, ErrTokenExpired) || errors.Is(err, ErrForbidden) {
+			return nil, err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, fmt.Errorf("%w: token issuer is not configured: %v", ErrUnauthorized, firstErr)
+}
+
+// EvictUser evicts the user's cached tokens from every issuer's cache.
+func (m *MultiValidator) EvictUser(user string) int {
+	n := 0
+	for _, v := range m.validators {
+		n += v.EvictUser(user)
+	}
+	return n
+}
+
+// EvictTokenHash evicts a cached token from every issuer's cache.
+func (m *MultiValidator) EvictTokenHash(tokenHash string) int {
+	n := 0
+	for _, v := range m.validators {
+		n += v.EvictTokenHash(tokenHash)
+	}
+	return n
+}
+
+// unverifiedIssuer returns the "iss" claim of a compact JWT without checking
+// its signature, or "" when the token cannot be decoded. It is only used to
+// pick a verifier.
+func unverifiedIssuer(rawToken string) string {
+	parts := strings.Split(rawToken, ".")
+	if len(parts) != 3 {
+		return ""
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return ""
+	}
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return ""
+	}
+	return claims.Issuer
+}
+
+func issuerKey(issuer string) string {
+	return strings.TrimSuffix(issuer, "/")
+}
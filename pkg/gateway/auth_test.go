@@ -10,11 +10,18 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	gooidc "github.com/coreos/go-oidc/v3/oidc"
 	jose "github.com/go-jose/go-jose/v4"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	workspacev1alpha1 "workspace-operator/api/v1alpha1"
 )
 
 func TestSanitizeUserID(t *testing.T) {
@@ -91,6 +98,74 @@ func TestNewValidator(t *testing.T) {
 	}
 }
 
+// flakyDiscoveryServer serves OIDC discovery but returns 503 for the first
+// failures requests to /.well-known/openid-configuration.
+func flakyDiscoveryServer(t *testing.T, failures int32) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issuer := "http://" + r.Host
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			if calls.Add(1) <= failures {
+				http.Error(w, "unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"issuer":                 issuer,
+				"authorization_endpoint": issuer + "/auth",
+				"token_endpoint":         issuer + "/token",
+				"jwks_uri":               issuer + "/jwks",
+			})
+		case "/jwks":
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []interface{}{}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestNewValidatorWithOIDC_RetriesDiscovery(t *testing.T) {
+	srv, calls := flakyDiscoveryServer(t, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	v, err := NewValidatorWithOIDC(ctx, OIDCConfig{
+		IssuerURL: srv.URL,
+		ClientID:  "test-client",
+		Discovery: DiscoveryRetry{Attempts: 3, Backoff: 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatalf("NewValidatorWithOIDC: %v", err)
+	}
+	if v == nil {
+		t.Fatal("NewValidatorWithOIDC returned nil")
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("discovery calls = %d, want 3", got)
+	}
+}
+
+func TestDiscoverProvider_GivesUpAfterAttempts(t *testing.T) {
+	srv, calls := flakyDiscoveryServer(t, 10)
+
+	_, err := DiscoverProvider(context.Background(), srv.URL, DiscoveryRetry{Attempts: 2, Backoff: time.Millisecond})
+	if err == nil {
+		t.Fatal("expected error when discovery keeps failing")
+	}
+	if !strings.Contains(err.Error(), "after 2 attempt(s)") {
+		t.Errorf("error = %v, want attempt count", err)
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("discovery calls = %d, want 2", got)
+	}
+}
+
 func TestValidate_WithMockJWKS(t *testing.T) {
 	priv, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
@@ -306,6 +381,324 @@ func TestValidate_WrongAudience_ReturnsForbidden(t *testing.T) {
 	}
 }
 
+// signingIssuer serves OIDC discovery and a JWKS for a fresh RSA key and
+// returns the issuer URL plus a function that signs claims into a raw JWT.
+func signingIssuer(t *testing.T) (string, func(claims map[string]any) string) {
+	t.Helper()
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("RSA key: %v", err)
+	}
+	const kid = "test-kid"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issuer := "http://" + r.Host
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]any{
+				"issuer":                 issuer,
+				"authorization_endpoint": issuer + "/auth",
+				"token_endpoint":         issuer + "/token",
+				"jwks_uri":               issuer + "/jwks",
+			})
+		case "/jwks":
+			pub := jose.JSONWebKey{Key: priv.Public(), KeyID: kid, Algorithm: string(jose.RS256), Use: "sig"}
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{pub}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: priv},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader(jose.HeaderKey("kid"), kid),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv.URL, func(claims map[string]any) string {
+		t.Helper()
+		payload, err := json.Marshal(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jws, err := signer.Sign(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := jws.CompactSerialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+}
+
+func TestValidate_ClockSkew(t *testing.T) {
+	issuer, sign := signingIssuer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	v, err := NewValidatorWithOIDC(ctx, OIDCConfig{IssuerURL: issuer, ClientID: "gw-client", ClockSkew: 5 * time.Minute})
+	if err != nil {
+		t.Fatalf("NewValidatorWithOIDC: %v", err)
+	}
+
+	now := time.Now()
+	tests := []struct {
+		name    string
+		iat     time.Time
+		nbf     time.Time
+		exp     time.Time
+		wantErr error
+	}{
+		{name: "issued a few seconds in the future", iat: now.Add(10 * time.Second), nbf: now.Add(10 * time.Second), exp: now.Add(time.Hour)},
+		{name: "expired within skew", iat: now.Add(-time.Hour), nbf: now.Add(-time.Hour), exp: now.Add(-time.Minute)},
+		{name: "expired beyond skew", iat: now.Add(-time.Hour), nbf: now.Add(-time.Hour), exp: now.Add(-10 * time.Minute), wantErr: ErrTokenExpired},
+		{name: "not yet valid beyond skew", iat: now, nbf: now.Add(10 * time.Minute), exp: now.Add(time.Hour), wantErr: ErrUnauthorized},
+		{name: "issued beyond skew", iat: now.Add(10 * time.Minute), nbf: now, exp: now.Add(time.Hour), wantErr: ErrUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := sign(map[string]any{
+				"iss": issuer,
+				"sub": "alice",
+				"aud": "gw-client",
+				"iat": tt.iat.Unix(),
+				"nbf": tt.nbf.Unix(),
+				"exp": tt.exp.Unix(),
+			})
+			_, err := v.Validate(ctx, raw)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Validate err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate_AudienceList(t *testing.T) {
+	issuer, sign := signingIssuer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	v, err := NewValidatorWithOIDC(ctx, OIDCConfig{IssuerURL: issuer, ClientID: "web-client", Audiences: []string{"devplane-api", "devplane-cli"}})
+	if err != nil {
+		t.Fatalf("NewValidatorWithOIDC: %v", err)
+	}
+	token := func(aud any) string {
+		return sign(map[string]any{
+			"iss": issuer,
+			"sub": "alice",
+			"aud": aud,
+			"iat": time.Now().Unix(),
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+	}
+
+	if _, err := v.Validate(ctx, token([]string{"other", "devplane-cli"})); err != nil {
+		t.Errorf("listed audience: %v", err)
+	}
+	if _, err := v.Validate(ctx, token("web-client")); !errors.Is(err, ErrForbidden) {
+		t.Errorf("client ID outside the list: err = %v, want ErrForbidden", err)
+	}
+}
+
+func TestMultiValidator_RoutesByIssuer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	corpIssuer, signCorp := signingIssuer(t)
+	partnerIssuer, signPartner := signingIssuer(t)
+	unknownIssuer, signUnknown := signingIssuer(t)
+
+	corp, err := NewValidatorWithOIDC(ctx, OIDCConfig{IssuerURL: corpIssuer, ClientID: "corp"})
+	if err != nil {
+		t.Fatalf("corp validator: %v", err)
+	}
+	partner, err := NewValidatorWithOIDC(ctx, OIDCConfig{IssuerURL: partnerIssuer, ClientID: "partner", UserIDPrefix: "partner"})
+	if err != nil {
+		t.Fatalf("partner validator: %v", err)
+	}
+	m, err := NewMultiValidator(corp, partner)
+	if err != nil {
+		t.Fatalf("NewMultiValidator: %v", err)
+	}
+	token := func(sign func(map[string]any) string, issuer, aud, sub string) string {
+		return sign(map[string]any{
+			"iss": issuer,
+			"sub": sub,
+			"aud": aud,
+			"iat": time.Now().Unix(),
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+	}
+
+	partnerToken := token(signPartner, partnerIssuer, "partner", "bob")
+	claims, err := m.Validate(ctx, partnerToken)
+	if err != nil || claims.Sub != "bob" {
+		t.Fatalf("partner token: claims=%+v err=%v", claims, err)
+	}
+	if _, ok := partner.index[hashToken(partnerToken)]; !ok {
+		t.Error("partner token not verified by the partner validator")
+	}
+	if _, ok := corp.index[hashToken(partnerToken)]; ok {
+		t.Error("partner token was cached by the corp validator")
+	}
+	if claims, err := m.Validate(ctx, token(signCorp, corpIssuer, "corp", "alice")); err != nil || claims.Sub != "alice" {
+		t.Fatalf("corp token: claims=%+v err=%v", claims, err)
+	}
+
+	if _, err := m.Validate(ctx, token(signUnknown, unknownIssuer, "corp", "mallory")); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("unknown issuer: err = %v, want ErrUnauthorized", err)
+	}
+	// A token claiming a known issuer but signed by another key is rejected
+	// by that issuer's verifier.
+	if _, err := m.Validate(ctx, token(signUnknown, corpIssuer, "corp", "mallory")); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("forged corp token: err = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestMultiValidator_GroupsQualifiedByIssuer(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	corpIssuer, signCorp := signingIssuer(t)
+	partnerIssuer, signPartner := signingIssuer(t)
+	allowed := []string{"engineering", "partner:contractors"}
+	corp, err := NewValidatorWithOIDC(ctx, OIDCConfig{IssuerURL: corpIssuer, ClientID: "devplane", AllowedGroups: allowed})
+	if err != nil {
+		t.Fatalf("corp validator: %v", err)
+	}
+	partner, err := NewValidatorWithOIDC(ctx, OIDCConfig{IssuerURL: partnerIssuer, ClientID: "devplane", UserIDPrefix: "partner", AllowedGroups: allowed})
+	if err != nil {
+		t.Fatalf("partner validator: %v", err)
+	}
+	m, err := NewMultiValidator(corp, partner)
+	if err != nil {
+		t.Fatalf("NewMultiValidator: %v", err)
+	}
+	token := func(sign func(map[string]any) string, issuer, sub string, groups ...string) string {
+		return sign(map[string]any{
+			"iss":    issuer,
+			"sub":    sub,
+			"aud":    "devplane",
+			"groups": groups,
+			"iat":    time.Now().Unix(),
+			"exp":    time.Now().Add(time.Hour).Unix(),
+		})
+	}
+
+	corpClaims, err := m.Validate(ctx, token(signCorp, corpIssuer, "alice", "engineering"))
+	if err != nil || !slices.Equal(corpClaims.Groups, []string{"engineering"}) {
+		t.Fatalf("corp token: claims=%+v err=%v, want unqualified group engineering", corpClaims, err)
+	}
+	// The partner IdP's "engineering" is not the corporate group.
+	if _, err := m.Validate(ctx, token(signPartner, partnerIssuer, "mallory", "engineering")); !errors.Is(err, ErrGroupNotAllowed) {
+		t.Errorf("partner engineering: err = %v, want ErrGroupNotAllowed", err)
+	}
+	partnerClaims, err := m.Validate(ctx, token(signPartner, partnerIssuer, "bob", "contractors"))
+	if err != nil || !slices.Equal(partnerClaims.Groups, []string{"partner:contractors"}) {
+		t.Fatalf("partner token: claims=%+v err=%v, want group partner:contractors", partnerClaims, err)
+	}
+
+	ws := &workspacev1alpha1.Workspace{Spec: workspacev1alpha1.WorkspaceSpec{
+		User:    workspacev1alpha1.UserInfo{ID: "alice"},
+		Sharing: workspacev1alpha1.SharingSpec{Groups: []string{"engineering"}},
+	}}
+	if !CanView(ws, corpClaims) {
+		t.Error("corp engineering member cannot view a workspace shared with engineering")
+	}
+	if CanView(ws, &Claims{UserID: "partner--mallory", Groups: []string{"partner:engineering"}}) {
+		t.Error("partner engineering member can view a workspace shared with the corp engineering group")
+	}
+}
+
+func TestNewMultiValidator_RejectsDuplicateIssuer(t *testing.T) {
+	a := &Validator{issuer: "https://idp.example.com"}
+	b := &Validator{issuer: "https://idp.example.com/", userIDPrefix: "b"}
+	if _, err := NewMultiValidator(a, b); err == nil {
+		t.Fatal("expected error for duplicate issuer")
+	}
+}
+
+func TestNewMultiValidator_RejectsOverlappingSubjectSpaces(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b *Validator
+	}{
+		{name: "both unprefixed", a: &Validator{issuer: "https://a.example.com"}, b: &Validator{issuer: "https://b.example.com"}},
+		{name: "same prefix", a: &Validator{issuer: "https://a.example.com", userIDPrefix: "ext"}, b: &Validator{issuer: "https://b.example.com", userIDPrefix: "ext"}},
+	}
+	for _, tt := range tests {
+		if _, err := NewMultiValidator(tt.a, tt.b); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestNewValidatorWithOIDC_RejectsInvalidUserIDPrefix(t *testing.T) {
+	for _, prefix := range []string{"Ctr", "1ctr", "c-tr", "c--"} {
+		_, err := NewValidatorWithOIDC(context.Background(), OIDCConfig{IssuerURL: "https://idp.example.com", ClientID: "c", UserIDPrefix: prefix})
+		if err == nil || !strings.Contains(err.Error(), "UserIDPrefix") {
+			t.Errorf("prefix %q: err = %v, want a UserIDPrefix error", prefix, err)
+		}
+	}
+}
+
+func TestMultiValidator_SameSubjectFromTwoIssuers(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	corpIssuer, signCorp := signingIssuer(t)
+	partnerIssuer, signPartner := signingIssuer(t)
+	corp, err := NewValidatorWithOIDC(ctx, OIDCConfig{IssuerURL: corpIssuer, ClientID: "devplane"})
+	if err != nil {
+		t.Fatalf("corp validator: %v", err)
+	}
+	partner, err := NewValidatorWithOIDC(ctx, OIDCConfig{IssuerURL: partnerIssuer, ClientID: "devplane", UserIDPrefix: "partner"})
+	if err != nil {
+		t.Fatalf("partner validator: %v", err)
+	}
+	m, err := NewMultiValidator(corp, partner)
+	if err != nil {
+		t.Fatalf("NewMultiValidator: %v", err)
+	}
+	token := func(sign func(map[string]any) string, issuer string) string {
+		return sign(map[string]any{
+			"iss": issuer,
+			"sub": "alice",
+			"aud": "devplane",
+			"iat": time.Now().Unix(),
+			"exp": time.Now().Add(time.Hour).Unix(),
+		})
+	}
+
+	corpClaims, err := m.Validate(ctx, token(signCorp, corpIssuer))
+	if err != nil {
+		t.Fatalf("corp token: %v", err)
+	}
+	partnerClaims, err := m.Validate(ctx, token(signPartner, partnerIssuer))
+	if err != nil {
+		t.Fatalf("partner token: %v", err)
+	}
+	if corpClaims.UserID != "alice" {
+		t.Errorf("corp UserID = %q, want alice", corpClaims.UserID)
+	}
+	if partnerClaims.UserID != "partner--alice" {
+		t.Errorf("partner UserID = %q, want partner--alice", partnerClaims.UserID)
+	}
+
+	// Evicting "alice" must not drop the partner user who shares the sub.
+	if n := m.EvictUser("alice"); n != 1 {
+		t.Errorf("EvictUser(alice) = %d, want 1", n)
+	}
+	if partner.lru.Len() != 1 {
+		t.Errorf("partner cache = %d entries, want the partner token kept", partner.lru.Len())
+	}
+	if n := m.EvictUser("partner--alice"); n != 1 {
+		t.Errorf("EvictUser(partner--alice) = %d, want 1", n)
+	}
+}
+
 func TestAuthErrorResponse(t *testing.T) {
 	st, code := AuthErrorResponse(fmt.Errorf("wrap: %w", ErrUnauthorized))
 	if st != http.StatusUnauthorized || code != AuthErrorCodeUnauthorized {
@@ -363,13 +756,206 @@ func TestValidate_CacheHit(t *testing.T) {
 	}
 }
 
+func TestValidate_AllowedGroups(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		groups  []string
+		wantErr bool
+	}{
+		{name: "empty allowlist admits everyone", groups: nil},
+		{name: "matching group", allowed: []string{"devs", "admins"}, groups: []string{"staff", "devs"}},
+		{name: "no matching group", allowed: []string{"devs"}, groups: []string{"staff"}, wantErr: true},
+		{name: "no groups at all", allowed: []string{"devs"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &Validator{
+				index: make(map[string]*list.Element),
+				lru:   list.New(),
+			}
+			if len(tt.allowed) > 0 {
+				v.allowedGroups = make(map[string]struct{})
+				for _, g := range tt.allowed {
+					v.allowedGroups[g] = struct{}{}
+				}
+			}
+			rawToken := "token-" + tt.name
+			key := hashToken(rawToken)
+			v.index[key] = v.lru.PushFront(&cachedEntry{
+				key:    key,
+				claims: &Claims{Sub: "bob", UserID: "bob", Groups: tt.groups},
+				expiry: time.Now().Add(tokenCacheTTL),
+			})
+
+			claims, err := v.Validate(context.Background(), rawToken)
+			if tt.wantErr {
+				if !errors.Is(err, ErrGroupNotAllowed) || !errors.Is(err, ErrForbidden) {
+					t.Fatalf("err = %v, want ErrGroupNotAllowed wrapping ErrForbidden", err)
+				}
+				if st, code := AuthErrorResponse(err); st != http.StatusForbidden || code != AuthErrorCodeForbidden {
+					t.Errorf("AuthErrorResponse = %d %q, want 403 forbidden", st, code)
+				}
+				return
+			}
+			if err != nil || claims == nil {
+				t.Fatalf("Validate: claims=%v err=%v", claims, err)
+			}
+		})
+	}
+}
+
+func TestGroupsFromClaims(t *testing.T) {
+	all := map[string]any{
+		"groups":       []any{"devs", 42, "ops"},
+		"role":         "admin",
+		"realm_access": map[string]any{"roles": []any{"offline_access", "devplane-user"}},
+	}
+	tests := []struct {
+		claim string
+		want  []string
+	}{
+		{"groups", []string{"devs", "ops"}},
+		{"role", []string{"admin"}},
+		{"realm_access.roles", []string{"offline_access", "devplane-user"}},
+		{"missing", nil},
+		{"role.nested", nil},
+	}
+	for _, tt := range tests {
+		if got := groupsFromClaims(all, tt.claim); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("groupsFromClaims(%q) = %v, want %v", tt.claim, got, tt.want)
+		}
+	}
+}
+
+// TestStore_EvictsLeastRecentlyUsedAtConfiguredMax fills a two-entry cache,
+// touches the oldest entry via a cache hit, and verifies the next insert evicts
+// the least-recently-used token instead.
+func TestStore_EvictsLeastRecentlyUsedAtConfiguredMax(t *testing.T) {
+	v := &Validator{
+		index:    make(map[string]*list.Element),
+		lru:      list.New(),
+		cacheTTL: time.Minute,
+		cacheMax: 2,
+	}
+	v.store(hashToken("a"), &Claims{UserID: "a"}, time.Time{})
+	v.store(hashToken("b"), &Claims{UserID: "b"}, time.Time{})
+	if _, err := v.Validate(context.Background(), "a"); err != nil {
+		t.Fatalf("Validate(a) cache hit: %v", err)
+	}
+	v.store(hashToken("c"), &Claims{UserID: "c"}, time.Time{})
+
+	if v.lru.Len() != 2 || len(v.index) != 2 {
+		t.Fatalf("cache size = %d/%d, want 2", v.lru.Len(), len(v.index))
+	}
+	if _, ok := v.index[hashToken("b")]; ok {
+		t.Error("least-recently-used entry b still cached")
+	}
+	for _, tok := range []string{"a", "c"} {
+		if _, ok := v.index[hashToken(tok)]; !ok {
+			t.Errorf("entry %s evicted, want cached", tok)
+		}
+	}
+}
+
+// TestStore_ExpiryCappedAtTokenExpiry caches a token that expires before the
+// TTL and checks the entry expires with the token, not after the TTL.
+func TestStore_ExpiryCappedAtTokenExpiry(t *testing.T) {
+	v := &Validator{
+		index:    make(map[string]*list.Element),
+		lru:      list.New(),
+		cacheTTL: time.Hour,
+		cacheMax: 2,
+	}
+	tokenExp := time.Now().Add(time.Minute)
+	v.store(hashToken("short"), &Claims{UserID: "short"}, tokenExp)
+	if got := v.index[hashToken("short")].Value.(*cachedEntry).expiry; !got.Equal(tokenExp) {
+		t.Errorf("expiry = %s, want the token's exp %s", got, tokenExp)
+	}
+
+	before := time.Now()
+	v.store(hashToken("long"), &Claims{UserID: "long"}, before.Add(24*time.Hour))
+	if got := v.index[hashToken("long")].Value.(*cachedEntry).expiry; got.After(time.Now().Add(time.Hour)) || got.Before(before.Add(time.Hour)) {
+		t.Errorf("expiry = %s, want now+cacheTTL for a token outliving the TTL", got)
+	}
+}
+
+// TestValidate_CacheMetrics drives a cache hit, an IdP miss and an LRU
+// eviction and checks each token cache metric moved.
+func TestValidate_CacheMetrics(t *testing.T) {
+	ctx := context.Background()
+	v := &Validator{
+		verifier: gooidc.NewVerifier("https://idp.example.com", &gooidc.StaticKeySet{}, &gooidc.Config{ClientID: "gw"}),
+		index:    make(map[string]*list.Element),
+		lru:      list.New(),
+		cacheTTL: time.Minute,
+		cacheMax: 1,
+		metrics:  newTokenCacheMetrics(prometheus.NewRegistry(), "https://idp.example.com"),
+	}
+	v.store(hashToken("a"), &Claims{UserID: "a"}, time.Time{})
+
+	if _, err := v.Validate(ctx, "a"); err != nil {
+		t.Fatalf("Validate(a) cache hit: %v", err)
+	}
+	if _, err := v.Validate(ctx, "not-a-jwt"); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("Validate(not-a-jwt) err = %v, want ErrUnauthorized", err)
+	}
+	v.store(hashToken("b"), &Claims{UserID: "b"}, time.Time{})
+
+	for name, tc := range map[string]struct {
+		c    prometheus.Collector
+		want float64
+	}{
+		"hits":      {v.metrics.hits, 1},
+		"misses":    {v.metrics.misses, 1},
+		"evictions": {v.metrics.evictions, 1},
+		"size":      {v.metrics.size, 1},
+	} {
+		if got := testutil.ToFloat64(tc.c); got != tc.want {
+			t.Errorf("%s = %v, want %v", name, got, tc.want)
+		}
+	}
+}
+
+// TestValidate_CacheEntriesPerIssuer checks that Validators sharing a
+// registry report their cache sizes as separate issuer series.
+func TestValidate_CacheEntriesPerIssuer(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	newValidator := func(issuer string) *Validator {
+		return &Validator{
+			issuer:   issuer,
+			index:    make(map[string]*list.Element),
+			lru:      list.New(),
+			cacheTTL: time.Minute,
+			cacheMax: 10,
+			metrics:  newTokenCacheMetrics(reg, issuer),
+		}
+	}
+	corp := newValidator("https://corp.example.com")
+	ctr := newValidator("https://ctr.example.com")
+	corp.store(hashToken("a"), &Claims{UserID: "a"}, time.Time{})
+	corp.store(hashToken("b"), &Claims{UserID: "b"}, time.Time{})
+	ctr.store(hashToken("c"), &Claims{UserID: "ctr--c"}, time.Time{})
+
+	want := `
+# HELP devplane_gateway_token_cache_entries Tokens currently held in the verified-token cache, by issuer.
+# TYPE devplane_gateway_token_cache_entries gauge
+devplane_gateway_token_cache_entries{issuer="https://corp.example.com"} 2
+devplane_gateway_token_cache_entries{issuer="https://ctr.example.com"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "devplane_gateway_token_cache_entries"); err != nil {
+		t.Error(err)
+	}
+}
+
 // TestEvictExpired_StopsOnContextCancel verifies that the background eviction
 // goroutine exits cleanly when its context is cancelled.
 func TestEvictExpired_StopsOnContextCancel(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	v := &Validator{
-		index: make(map[string]*list.Element),
-		lru:   list.New(),
+		index:    make(map[string]*list.Element),
+		lru:      list.New(),
+		cacheTTL: tokenCacheTTL,
 	}
 
 	done := make(chan struct{})
@@ -428,6 +1014,66 @@ func TestValidate_ExpiredCacheEntry_EagerEviction(t *testing.T) {
 	}
 }
 
+// TestEvictUser_RemovesCachedEntry_ReverifiesOnNextUse seeds cache entries for
+// two users, evicts one, and confirms only that user's token goes back to the
+// verifier on its next Validate call (nil verifier → recovered panic).
+func TestEvictUser_RemovesCachedEntry_ReverifiesOnNextUse(t *testing.T) {
+	v := &Validator{
+		index: make(map[string]*list.Element),
+		lru:   list.New(),
+	}
+	seed := func(raw string, c *Claims) {
+		key := hashToken(raw)
+		v.index[key] = v.lru.PushFront(&cachedEntry{key: key, claims: c, expiry: time.Now().Add(tokenCacheTTL)})
+	}
+	seed("alice-token", &Claims{Sub: "alice-sub", UserID: "alice"})
+	seed("bob-token", &Claims{Sub: "bob-sub", UserID: "bob"})
+
+	if n := v.EvictUser("alice"); n != 1 {
+		t.Fatalf("EvictUser evicted %d entries, want 1", n)
+	}
+	if _, ok := v.index[hashToken("alice-token")]; ok {
+		t.Error("alice's cached entry should have been evicted")
+	}
+
+	// bob is still served from cache without touching the verifier.
+	if c, err := v.Validate(context.Background(), "bob-token"); err != nil || c.UserID != "bob" {
+		t.Fatalf("bob cache hit: claims=%+v err=%v", c, err)
+	}
+
+	reverified := false
+	func() {
+		defer func() {
+			if recover() != nil {
+				reverified = true
+			}
+		}()
+		_, _ = v.Validate(context.Background(), "alice-token")
+	}()
+	if !reverified {
+		t.Error("Validate after eviction should call the OIDC verifier")
+	}
+}
+
+func TestEvictTokenHash(t *testing.T) {
+	v := &Validator{
+		index: make(map[string]*list.Element),
+		lru:   list.New(),
+	}
+	key := hashToken("some-token")
+	v.index[key] = v.lru.PushFront(&cachedEntry{key: key, claims: &Claims{UserID: "u"}, expiry: time.Now().Add(tokenCacheTTL)})
+
+	if n := v.EvictTokenHash("0000"); n != 0 {
+		t.Errorf("EvictTokenHash(unknown) = %d, want 0", n)
+	}
+	if n := v.EvictTokenHash(strings.ToUpper(key)); n != 1 {
+		t.Errorf("EvictTokenHash = %d, want 1", n)
+	}
+	if v.lru.Len() != 0 {
+		t.Errorf("LRU list len = %d, want 0", v.lru.Len())
+	}
+}
+
 func TestHashToken(t *testing.T) {
 	h1 := hashToken("token-a")
 	h2 := hashToken("token-b")
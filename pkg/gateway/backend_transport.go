@@ -0,0 +1,56 @@
+package gateway
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultBackendIdleConnTimeout closes idle keep-alive connections to a
+	// workspace pod well before a recreated pod could reuse its address.
+	DefaultBackendIdleConnTimeout = 30 * time.Second
+	// DefaultBackendMaxIdleConnsPerHost bounds idle connections kept per
+	// workspace; one browser rarely needs more in parallel.
+	DefaultBackendMaxIdleConnsPerHost = 4
+	// DefaultBackendDialTimeout bounds the TCP connect to a workspace pod.
+	DefaultBackendDialTimeout = 5 * time.Second
+)
+
+// BackendTransportConfig tunes the HTTP transport used to reverse proxy the
+// ttyd web UI. Zero fields use the Default* values above.
+type BackendTransportConfig struct {
+	// IdleConnTimeout is how long an idle keep-alive connection to a
+	// workspace is kept before it is closed.
+	IdleConnTimeout time.Duration
+	// MaxIdleConnsPerHost caps idle keep-alive connections per workspace.
+	MaxIdleConnsPerHost int
+	// DialTimeout bounds establishing a new connection to a workspace.
+	DialTimeout time.Duration
+	// TLS is the client configuration for https:// backends, the same one
+	// ProxyConfig.BackendTLS uses for wss://. Nil keeps the defaults.
+	TLS *tls.Config
+}
+
+// NewBackendTransport returns an http.Transport for workspace backends based
+// on http.DefaultTransport (so HTTP_PROXY is still honored) with cfg applied.
+func NewBackendTransport(cfg BackendTransportConfig) *http.Transport {
+	if cfg.IdleConnTimeout <= 0 {
+		cfg.IdleConnTimeout = DefaultBackendIdleConnTimeout
+	}
+	if cfg.MaxIdleConnsPerHost <= 0 {
+		cfg.MaxIdleConnsPerHost = DefaultBackendMaxIdleConnsPerHost
+	}
+	if cfg.DialTimeout <= 0 {
+		cfg.DialTimeout = DefaultBackendDialTimeout
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.DialContext = (&net.Dialer{Timeout: cfg.DialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	t.IdleConnTimeout = cfg.IdleConnTimeout
+	t.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	if cfg.TLS != nil {
+		t.TLSClientConfig = cfg.TLS.Clone()
+	}
+	return t
+}
@@ -0,0 +1,40 @@
+package gateway
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewBackendTransport(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         BackendTransportConfig
+		wantIdle    time.Duration
+		wantMaxIdle int
+	}{
+		{name: "defaults", wantIdle: DefaultBackendIdleConnTimeout, wantMaxIdle: DefaultBackendMaxIdleConnsPerHost},
+		{
+			name:        "custom",
+			cfg:         BackendTransportConfig{IdleConnTimeout: 10 * time.Second, MaxIdleConnsPerHost: 2, DialTimeout: time.Second},
+			wantIdle:    10 * time.Second,
+			wantMaxIdle: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := NewBackendTransport(tt.cfg)
+			if tr.IdleConnTimeout != tt.wantIdle {
+				t.Errorf("IdleConnTimeout = %v, want %v", tr.IdleConnTimeout, tt.wantIdle)
+			}
+			if tr.MaxIdleConnsPerHost != tt.wantMaxIdle {
+				t.Errorf("MaxIdleConnsPerHost = %d, want %d", tr.MaxIdleConnsPerHost, tt.wantMaxIdle)
+			}
+			if tr.DialContext == nil {
+				t.Error("DialContext = nil, want a dialer with the configured timeout")
+			}
+			if tr.Proxy == nil {
+				t.Error("Proxy = nil, want HTTP_PROXY support from http.DefaultTransport")
+			}
+		})
+	}
+}
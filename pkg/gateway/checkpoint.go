@@ -0,0 +1,203 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	workspacev1alpha1 "workspace-operator/api/v1alpha1"
+	worksp "workspace-operator/pkg/workspace"
+)
+
+// VolumeSnapshotGVK is the CSI VolumeSnapshot kind checkpoints are stored as.
+// It is handled as unstructured so the gateway does not depend on the
+// external-snapshotter client.
+var VolumeSnapshotGVK = schema.GroupVersionKind{Group: worksp.VolumeSnapshotGroup, Version: "v1", Kind: "VolumeSnapshot"}
+
+// CheckpointLabel marks VolumeSnapshots created by the checkpoint API, so
+// restores never pick up snapshots made by other tooling.
+const CheckpointLabel = "devplane.io/checkpoint"
+
+var (
+	// ErrCheckpointNotFound is returned when the named checkpoint does not
+	// exist or belongs to another user.
+	ErrCheckpointNotFound = errors.New("checkpoint not found")
+	// ErrCheckpointExists is returned when a checkpoint with the requested
+	// name already exists.
+	ErrCheckpointExists = errors.New("checkpoint already exists")
+	// ErrCheckpointNotReady is returned when restoring from a snapshot that
+	// the CSI driver has not finished taking.
+	ErrCheckpointNotReady = errors.New("checkpoint is not ready to use")
+	// ErrCheckpointUnsupported is returned for ephemeral workspaces, which
+	// have no PVC to snapshot.
+	ErrCheckpointUnsupported = errors.New("workspace has no persistent volume")
+	// ErrCheckpointInvalidName is returned when a checkpoint name is not a
+	// valid Kubernetes object name.
+	ErrCheckpointInvalidName = errors.New("invalid checkpoint name")
+)
+
+// CheckpointConfig configures CheckpointManager.
+type CheckpointConfig struct {
+	// VolumeSnapshotClassName is set on every checkpoint. Empty uses the
+	// cluster's default VolumeSnapshotClass.
+	VolumeSnapshotClassName string
+}
+
+// Checkpoint describes a named VolumeSnapshot of a user's workspace PVC.
+type Checkpoint struct {
+	Name       string    `json:"name"`
+	CreatedAt  time.Time `json:"createdAt"`
+	ReadyToUse bool      `json:"readyToUse"`
+}
+
+// CheckpointManager creates on-demand VolumeSnapshots of workspace PVCs and
+// restores workspaces from them.
+type CheckpointManager struct {
+	client client.Client
+	cfg    CheckpointConfig
+}
+
+// NewCheckpointManager returns a CheckpointManager using the provided K8s client.
+func NewCheckpointManager(c client.Client, cfg CheckpointConfig) *CheckpointManager {
+	return &CheckpointManager{client: c, cfg: cfg}
+}
+
+// Create snapshots the PVC of userID's workspace in namespace. An empty name
+// is replaced by "<user>-<UTC timestamp>". It returns ErrNoWorkspace when the
+// user has no Workspace CR and ErrCheckpointExists when name is taken.
+func (m *CheckpointManager) Create(ctx context.Context, namespace, userID, name string) (*Checkpoint, error) {
+	ws, err := m.getWorkspace(ctx, namespace, userID)
+	if err != nil {
+		return nil, err
+	}
+	if ws.Spec.Persistence.Ephemeral {
+		return nil, ErrCheckpointUnsupported
+	}
+	now := time.Now().UTC()
+	if name == "" {
+		name = fmt.Sprintf("%s-%s", ws.Spec.User.ID, now.Format("20060102-150405"))
+	}
+	if err := validateCheckpointName(name); err != nil {
+		return nil, err
+	}
+
+	snap := &unstructured.Unstructured{}
+	snap.SetGroupVersionKind(VolumeSnapshotGVK)
+	snap.SetNamespace(namespace)
+	snap.SetName(name)
+	labels := worksp.Labels(ws.Spec.User.ID)
+	labels[CheckpointLabel] = "true"
+	snap.SetLabels(labels)
+	// Owned by the Workspace so garbage collection removes checkpoints with
+	// it. BlockOwnerDeletion stays unset: it would need update on
+	// workspaces/finalizers, which the gateway does not hold.
+	snap.SetOwnerReferences([]metav1.OwnerReference{{
+		APIVersion: workspacev1alpha1.GroupVersion.String(),
+		Kind:       "Workspace",
+		Name:       ws.Name,
+		UID:        ws.UID,
+	}})
+	spec := map[string]any{
+		"source": map[string]any{
+			"persistentVolumeClaimName": worksp.PVCName(ws.Spec.User.ID),
+		},
+	}
+	if m.cfg.VolumeSnapshotClassName != "" {
+		spec["volumeSnapshotClassName"] = m.cfg.VolumeSnapshotClassName
+	}
+	snap.Object["spec"] = spec
+	if err := m.client.Create(ctx, snap); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			return nil, ErrCheckpointExists
+		}
+		return nil, fmt.Errorf("create volumesnapshot %q: %w", name, err)
+	}
+	return &Checkpoint{Name: name, CreatedAt: now}, nil
+}
+
+// Restore points userID's workspace at checkpoint name. Only the Workspace is
+// patched: the operator replaces the PVC from the snapshot and recreates the
+// workspace pods. It returns ErrCheckpointNotFound, ErrCheckpointNotReady or
+// ErrNoWorkspace.
+func (m *CheckpointManager) Restore(ctx context.Context, namespace, userID, name string) (*Checkpoint, error) {
+	if err := validateCheckpointName(name); err != nil {
+		return nil, err
+	}
+	ws, err := m.getWorkspace(ctx, namespace, userID)
+	if err != nil {
+		return nil, err
+	}
+	if ws.Spec.Persistence.Ephemeral {
+		return nil, ErrCheckpointUnsupported
+	}
+	cp, err := m.get(ctx, namespace, ws.Spec.User.ID, name)
+	if err != nil {
+		return nil, err
+	}
+	if !cp.ReadyToUse {
+		return nil, ErrCheckpointNotReady
+	}
+
+	base := ws.DeepCopy()
+	ws.Spec.Persistence.RestoreFrom = name
+	if ws.Annotations == nil {
+		ws.Annotations = map[string]string{}
+	}
+	ws.Annotations[worksp.RestoreRequestAnnotation] = time.Now().UTC().Format(time.RFC3339Nano)
+	if err := m.client.Patch(ctx, ws, client.MergeFrom(base)); err != nil {
+		return nil, fmt.Errorf("set restoreFrom on workspace %q: %w", ws.Name, err)
+	}
+	return cp, nil
+}
+
+func validateCheckpointName(name string) error {
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("%w %q: %s", ErrCheckpointInvalidName, name, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (m *CheckpointManager) getWorkspace(ctx context.Context, namespace, userID string) (*workspacev1alpha1.Workspace, error) {
+	var ws workspacev1alpha1.Workspace
+	if err := m.client.Get(ctx, types.NamespacedName{Name: userID, Namespace: namespace}, &ws); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, ErrNoWorkspace
+		}
+		return nil, fmt.Errorf("get workspace %q: %w", userID, err)
+	}
+	return &ws, nil
+}
+
+// get returns checkpoint name of userID, treating snapshots without the
+// checkpoint label or owned by another user as not found.
+func (m *CheckpointManager) get(ctx context.Context, namespace, userID, name string) (*Checkpoint, error) {
+	snap := &unstructured.Unstructured{}
+	snap.SetGroupVersionKind(VolumeSnapshotGVK)
+	if err := m.client.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, snap); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, ErrCheckpointNotFound
+		}
+		return nil, fmt.Errorf("get volumesnapshot %q: %w", name, err)
+	}
+	labels := snap.GetLabels()
+	if labels[CheckpointLabel] != "true" {
+		return nil, ErrCheckpointNotFound
+	}
+	for k, v := range worksp.SelectorLabels(userID) {
+		if labels[k] != v {
+			return nil, ErrCheckpointNotFound
+		}
+	}
+	ready, _, _ := unstructured.NestedBool(snap.Object, "status", "readyToUse")
+	return &Checkpoint{Name: name, CreatedAt: snap.GetCreationTimestamp().UTC(), ReadyToUse: ready}, nil
+}
@@ -0,0 +1,223 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	workspacev1alpha1 "workspace-operator/api/v1alpha1"
+	worksp "workspace-operator/pkg/workspace"
+)
+
+// snapshotScheme is testScheme plus the VolumeSnapshot kinds, registered as
+// unstructured since the gateway does not import the snapshotter API.
+var snapshotScheme = func() *runtime.Scheme {
+	s := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(s))
+	utilruntime.Must(workspacev1alpha1.AddToScheme(s))
+	s.AddKnownTypeWithName(VolumeSnapshotGVK, &unstructured.Unstructured{})
+	s.AddKnownTypeWithName(VolumeSnapshotGVK.GroupVersion().WithKind("VolumeSnapshotList"), &unstructured.UnstructuredList{})
+	return s
+}()
+
+func checkpointWorkspace() *workspacev1alpha1.Workspace {
+	return &workspacev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "workspaces"},
+		Spec: workspacev1alpha1.WorkspaceSpec{
+			User:      workspacev1alpha1.UserInfo{ID: "alice", Email: "alice@example.com"},
+			Resources: workspacev1alpha1.ResourceRequirements{CPU: "1", Memory: "2Gi", Storage: "20Gi"},
+		},
+	}
+}
+
+func checkpointSnapshot(name, user string, ready bool) *unstructured.Unstructured {
+	snap := &unstructured.Unstructured{}
+	snap.SetGroupVersionKind(VolumeSnapshotGVK)
+	snap.SetNamespace("workspaces")
+	snap.SetName(name)
+	labels := worksp.Labels(user)
+	labels[CheckpointLabel] = "true"
+	snap.SetLabels(labels)
+	_ = unstructured.SetNestedField(snap.Object, worksp.PVCName(user), "spec", "source", "persistentVolumeClaimName")
+	_ = unstructured.SetNestedField(snap.Object, ready, "status", "readyToUse")
+	return snap
+}
+
+func getSnapshot(t *testing.T, c client.Client, name string) *unstructured.Unstructured {
+	t.Helper()
+	snap := &unstructured.Unstructured{}
+	snap.SetGroupVersionKind(VolumeSnapshotGVK)
+	if err := c.Get(context.Background(), types.NamespacedName{Name: name, Namespace: "workspaces"}, snap); err != nil {
+		t.Fatalf("get volumesnapshot %q: %v", name, err)
+	}
+	return snap
+}
+
+func TestCheckpointManager_Create(t *testing.T) {
+	fc := fake.NewClientBuilder().WithScheme(snapshotScheme).WithObjects(checkpointWorkspace()).Build()
+	m := NewCheckpointManager(fc, CheckpointConfig{VolumeSnapshotClassName: "csi-snapclass"})
+
+	cp, err := m.Create(context.Background(), "workspaces", "alice", "before-upgrade")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if cp.Name != "before-upgrade" {
+		t.Errorf("name = %q, want before-upgrade", cp.Name)
+	}
+	snap := getSnapshot(t, fc, "before-upgrade")
+	if src, _, _ := unstructured.NestedString(snap.Object, "spec", "source", "persistentVolumeClaimName"); src != worksp.PVCName("alice") {
+		t.Errorf("source PVC = %q, want %q", src, worksp.PVCName("alice"))
+	}
+	if class, _, _ := unstructured.NestedString(snap.Object, "spec", "volumeSnapshotClassName"); class != "csi-snapclass" {
+		t.Errorf("volumeSnapshotClassName = %q, want csi-snapclass", class)
+	}
+	if snap.GetLabels()[CheckpointLabel] != "true" || snap.GetLabels()["user"] != "alice" {
+		t.Errorf("labels = %v, want checkpoint and user labels", snap.GetLabels())
+	}
+	if refs := snap.GetOwnerReferences(); len(refs) != 1 || refs[0].Kind != "Workspace" || refs[0].Name != "alice" {
+		t.Errorf("ownerReferences = %v, want the Workspace so checkpoints are deleted with it", refs)
+	}
+
+	if _, err := m.Create(context.Background(), "workspaces", "alice", "before-upgrade"); !errors.Is(err, ErrCheckpointExists) {
+		t.Errorf("second Create err = %v, want ErrCheckpointExists", err)
+	}
+}
+
+func TestCheckpointManager_CreateGeneratesName(t *testing.T) {
+	fc := fake.NewClientBuilder().WithScheme(snapshotScheme).WithObjects(checkpointWorkspace()).Build()
+	cp, err := NewCheckpointManager(fc, CheckpointConfig{}).Create(context.Background(), "workspaces", "alice", "")
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	getSnapshot(t, fc, cp.Name)
+}
+
+func TestCheckpointManager_CreateErrors(t *testing.T) {
+	ephemeral := checkpointWorkspace()
+	ephemeral.Spec.Persistence.Ephemeral = true
+	tests := []struct {
+		name    string
+		objs    []client.Object
+		cpName  string
+		wantErr error
+	}{
+		{name: "no workspace", cpName: "cp", wantErr: ErrNoWorkspace},
+		{name: "ephemeral", objs: []client.Object{ephemeral}, cpName: "cp", wantErr: ErrCheckpointUnsupported},
+		{name: "invalid name", objs: []client.Object{checkpointWorkspace()}, cpName: "Not_Valid", wantErr: ErrCheckpointInvalidName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc := fake.NewClientBuilder().WithScheme(snapshotScheme).WithObjects(tt.objs...).Build()
+			_, err := NewCheckpointManager(fc, CheckpointConfig{}).Create(context.Background(), "workspaces", "alice", tt.cpName)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckpointManager_Restore(t *testing.T) {
+	pvc := &corev1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: worksp.PVCName("alice"), Namespace: "workspaces"}}
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: worksp.PodName("alice"), Namespace: "workspaces", Labels: worksp.Labels("alice"),
+	}}
+	fc := fake.NewClientBuilder().WithScheme(snapshotScheme).
+		WithObjects(checkpointWorkspace(), pvc, pod, checkpointSnapshot("before-upgrade", "alice", true)).
+		Build()
+
+	m := NewCheckpointManager(fc, CheckpointConfig{})
+	cp, err := m.Restore(context.Background(), "workspaces", "alice", "before-upgrade")
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if cp.Name != "before-upgrade" || !cp.ReadyToUse {
+		t.Errorf("checkpoint = %+v, want ready before-upgrade", cp)
+	}
+
+	var ws workspacev1alpha1.Workspace
+	if err := fc.Get(context.Background(), types.NamespacedName{Name: "alice", Namespace: "workspaces"}, &ws); err != nil {
+		t.Fatalf("get workspace: %v", err)
+	}
+	if ws.Spec.Persistence.RestoreFrom != "before-upgrade" {
+		t.Errorf("restoreFrom = %q, want before-upgrade", ws.Spec.Persistence.RestoreFrom)
+	}
+	first := ws.Annotations[worksp.RestoreRequestAnnotation]
+	if first == "" {
+		t.Errorf("annotation %s not set", worksp.RestoreRequestAnnotation)
+	}
+	// The gateway leaves the PVC and pod to the operator.
+	if err := fc.Get(context.Background(), client.ObjectKeyFromObject(pvc), &corev1.PersistentVolumeClaim{}); err != nil {
+		t.Errorf("PVC must not be deleted by the gateway: %v", err)
+	}
+	if err := fc.Get(context.Background(), client.ObjectKeyFromObject(pod), &corev1.Pod{}); err != nil {
+		t.Errorf("pod must not be deleted by the gateway: %v", err)
+	}
+	if !worksp.RestorePending(&ws, pvc) {
+		t.Error("RestorePending = false, want the operator to replace the PVC")
+	}
+
+}
+
+func TestCheckpointManager_RestoreSameCheckpointAgain(t *testing.T) {
+	ws := checkpointWorkspace()
+	ws.Spec.Persistence.RestoreFrom = "before-upgrade"
+	ws.Annotations = map[string]string{worksp.RestoreRequestAnnotation: "2020-01-01T00:00:00Z"}
+	fc := fake.NewClientBuilder().WithScheme(snapshotScheme).
+		WithObjects(ws, checkpointSnapshot("before-upgrade", "alice", true)).
+		Build()
+
+	if _, err := NewCheckpointManager(fc, CheckpointConfig{}).Restore(context.Background(), "workspaces", "alice", "before-upgrade"); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	var got workspacev1alpha1.Workspace
+	if err := fc.Get(context.Background(), client.ObjectKeyFromObject(ws), &got); err != nil {
+		t.Fatalf("get workspace: %v", err)
+	}
+	if got.Annotations[worksp.RestoreRequestAnnotation] == "2020-01-01T00:00:00Z" {
+		t.Error("restoring the same checkpoint again must issue a new restore request")
+	}
+}
+
+func TestCheckpointManager_RestoreErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		snap    *unstructured.Unstructured
+		cpName  string
+		wantErr error
+	}{
+		{name: "missing", cpName: "nope", wantErr: ErrCheckpointNotFound},
+		{name: "other user", snap: checkpointSnapshot("bobs", "bob", true), cpName: "bobs", wantErr: ErrCheckpointNotFound},
+		{name: "not ready", snap: checkpointSnapshot("pending", "alice", false), cpName: "pending", wantErr: ErrCheckpointNotReady},
+		{name: "empty name", cpName: "", wantErr: ErrCheckpointInvalidName},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objs := []client.Object{checkpointWorkspace()}
+			if tt.snap != nil {
+				objs = append(objs, tt.snap)
+			}
+			fc := fake.NewClientBuilder().WithScheme(snapshotScheme).WithObjects(objs...).Build()
+			_, err := NewCheckpointManager(fc, CheckpointConfig{}).Restore(context.Background(), "workspaces", "alice", tt.cpName)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			var ws workspacev1alpha1.Workspace
+			if err := fc.Get(context.Background(), types.NamespacedName{Name: "alice", Namespace: "workspaces"}, &ws); err != nil {
+				t.Fatalf("get workspace: %v", err)
+			}
+			if ws.Spec.Persistence.RestoreFrom != "" {
+				t.Errorf("restoreFrom = %q, want unchanged on error", ws.Spec.Persistence.RestoreFrom)
+			}
+		})
+	}
+}
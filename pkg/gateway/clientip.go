@@ -0,0 +1,82 @@
+package gateway
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// TrustedProxies is the set of proxy networks whose X-Forwarded-For header is
+// believed. An empty set trusts no one, so ClientIP returns the TCP peer.
+type TrustedProxies []netip.Prefix
+
+// ParseTrustedProxies parses a comma-separated list of CIDRs or bare IPs
+// (e.g. "10.0.0.0/8,192.168.1.10").
+func ParseTrustedProxies(raw string) (TrustedProxies, error) {
+	var out TrustedProxies
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if !strings.Contains(part, "/") {
+			addr, err := netip.ParseAddr(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", part, err)
+			}
+			out = append(out, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", part, err)
+		}
+		out = append(out, prefix.Masked())
+	}
+	return out, nil
+}
+
+func (t TrustedProxies) contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, p := range t {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the originating client IP for r. X-Forwarded-For is only
+// consulted when the TCP peer is a trusted proxy; it is then walked from the
+// right, skipping trusted hops, so a client cannot spoof its address by
+// prepending entries. Falls back to the peer address from r.RemoteAddr.
+func (t TrustedProxies) ClientIP(r *http.Request) string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	peerAddr, err := netip.ParseAddr(peer)
+	if err != nil || !t.contains(peerAddr) {
+		return peer
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		addr, err := netip.ParseAddr(hop)
+		if err != nil {
+			// A malformed entry cannot be trusted; stop at the last good hop.
+			break
+		}
+		client = addr.Unmap().String()
+		if !t.contains(addr) {
+			break
+		}
+	}
+	return client
+}
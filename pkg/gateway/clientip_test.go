@@ -0,0 +1,63 @@
+package gateway
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	tp, err := ParseTrustedProxies(" 10.0.0.0/8, 192.168.1.10 ,,fd00::/8")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies: %v", err)
+	}
+	if len(tp) != 3 {
+		t.Fatalf("len = %d, want 3", len(tp))
+	}
+	if _, err := ParseTrustedProxies("10.0.0.0/33"); err == nil {
+		t.Error("expected error for invalid CIDR")
+	}
+	if _, err := ParseTrustedProxies("not-an-ip"); err == nil {
+		t.Error("expected error for invalid IP")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tp, err := ParseTrustedProxies("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		want   string
+	}{
+		{name: "trusted peer uses XFF", remote: "10.1.2.3:5555", xff: []string{"203.0.113.7"}, want: "203.0.113.7"},
+		{name: "untrusted peer ignores XFF", remote: "198.51.100.9:5555", xff: []string{"203.0.113.7"}, want: "198.51.100.9"},
+		{name: "trusted peer without XFF", remote: "10.1.2.3:5555", want: "10.1.2.3"},
+		{name: "spoofed leftmost entry skipped", remote: "10.1.2.3:5555", xff: []string{"1.2.3.4, 203.0.113.7"}, want: "203.0.113.7"},
+		{name: "trusted hops skipped", remote: "10.1.2.3:5555", xff: []string{"203.0.113.7, 10.9.9.9"}, want: "203.0.113.7"},
+		{name: "multiple headers", remote: "10.1.2.3:5555", xff: []string{"1.2.3.4", "203.0.113.7"}, want: "203.0.113.7"},
+		{name: "malformed hop stops walk", remote: "10.1.2.3:5555", xff: []string{"203.0.113.7, garbage"}, want: "10.1.2.3"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remote
+		for _, v := range tt.xff {
+			r.Header.Add("X-Forwarded-For", v)
+		}
+		if got := tp.ClientIP(r); got != tt.want {
+			t.Errorf("%s: ClientIP = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClientIP_NoTrustedProxies(t *testing.T) {
+	var tp TrustedProxies
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "10.1.2.3:5555"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+	if got := tp.ClientIP(r); got != "10.1.2.3" {
+		t.Errorf("ClientIP = %q, want peer address", got)
+	}
+}
@@ -0,0 +1,93 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	workspacev1alpha1 "workspace-operator/api/v1alpha1"
+)
+
+// healthProbeTimeout bounds the request HealthChecker sends to the ttyd
+// backend, so a hung pod reads as unhealthy rather than stalling monitors.
+const healthProbeTimeout = 3 * time.Second
+
+// WorkspaceHealth is the JSON body of GET /api/workspaces/{user}/healthz.
+type WorkspaceHealth struct {
+	User    string `json:"user"`
+	Healthy bool   `json:"healthy"`
+	Phase   string `json:"phase,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// HealthChecker reports whether a user's workspace is Running and its ttyd
+// backend answers HTTP. It never creates or starts the workspace.
+type HealthChecker struct {
+	client client.Client
+	proxy  *Proxy
+	http   *http.Client
+}
+
+// NewHealthChecker returns a HealthChecker using the provided K8s client and
+// transport for backend probes; a nil transport uses http.DefaultTransport.
+// proxy picks the probe scheme (https:// with backend TLS); nil probes http://.
+func NewHealthChecker(c client.Client, proxy *Proxy, transport http.RoundTripper) *HealthChecker {
+	return &HealthChecker{
+		client: c,
+		proxy:  proxy,
+		http: &http.Client{
+			Transport: transport,
+			Timeout:   healthProbeTimeout,
+			// Any answer from ttyd proves it is up; do not chase redirects.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+	}
+}
+
+// Check returns userID's workspace health in namespace. A missing workspace is
+// reported as unhealthy; only errors reading the Workspace are returned.
+func (h *HealthChecker) Check(ctx context.Context, namespace, userID string) (WorkspaceHealth, error) {
+	health := WorkspaceHealth{User: userID}
+	var ws workspacev1alpha1.Workspace
+	if err := h.client.Get(ctx, types.NamespacedName{Name: userID, Namespace: namespace}, &ws); err != nil {
+		if apierrors.IsNotFound(err) {
+			health.Message = "Workspace does not exist."
+			return health, nil
+		}
+		return health, fmt.Errorf("get workspace %q: %w", userID, err)
+	}
+	health.Phase = string(ws.Status.Phase)
+	health.Message = ws.Status.Message
+	if ws.Status.Phase != workspacev1alpha1.WorkspacePhaseRunning || ws.Status.ServiceEndpoint == "" {
+		if health.Message == "" {
+			health.Message = "Workspace is not running."
+		}
+		return health, nil
+	}
+
+	backendURL := BackendHTTPURL(ws.Status.ServiceEndpoint, BackendPort(&ws))
+	if h.proxy != nil {
+		backendURL = h.proxy.BackendHTTPURL(ws.Status.ServiceEndpoint, BackendPort(&ws))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, backendURL, nil)
+	if err != nil {
+		return health, fmt.Errorf("build backend probe: %w", err)
+	}
+	resp, err := h.http.Do(req)
+	if err != nil {
+		health.Message = fmt.Sprintf("Workspace backend is unreachable: %v", err)
+		return health, nil
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		health.Message = fmt.Sprintf("Workspace backend answered %d.", resp.StatusCode)
+		return health, nil
+	}
+	health.Healthy = true
+	return health, nil
+}
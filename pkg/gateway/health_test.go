@@ -0,0 +1,92 @@
+package gateway
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	workspacev1alpha1 "workspace-operator/api/v1alpha1"
+)
+
+// backendFunc adapts a function to http.RoundTripper for backend probes.
+type backendFunc func(*http.Request) (*http.Response, error)
+
+func (f backendFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func healthWorkspace(phase workspacev1alpha1.WorkspacePhase, endpoint string) *workspacev1alpha1.Workspace {
+	ws := &workspacev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "workspaces"},
+		Spec:       workspacev1alpha1.WorkspaceSpec{User: workspacev1alpha1.UserInfo{ID: "alice"}},
+	}
+	ws.Status.Phase = phase
+	ws.Status.ServiceEndpoint = endpoint
+	return ws
+}
+
+func TestHealthChecker_Check(t *testing.T) {
+	up := backendFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ttyd")), Request: r}, nil
+	})
+	down := backendFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+	tests := []struct {
+		name        string
+		ws          *workspacev1alpha1.Workspace
+		backend     http.RoundTripper
+		wantHealthy bool
+		wantMessage string
+	}{
+		{name: "running and reachable", ws: healthWorkspace(workspacev1alpha1.WorkspacePhaseRunning, "alice-workspace-svc.workspaces.svc"), backend: up, wantHealthy: true},
+		{name: "running but unreachable", ws: healthWorkspace(workspacev1alpha1.WorkspacePhaseRunning, "alice-workspace-svc.workspaces.svc"), backend: down, wantMessage: "unreachable"},
+		{name: "not running", ws: healthWorkspace(workspacev1alpha1.WorkspacePhaseStopped, ""), backend: up, wantMessage: "not running"},
+		{name: "missing", backend: up, wantMessage: "does not exist"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var objs []client.Object
+			if tt.ws != nil {
+				objs = append(objs, tt.ws)
+			}
+			fc := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(objs...).Build()
+			got, err := NewHealthChecker(fc, nil, tt.backend).Check(context.Background(), "workspaces", "alice")
+			if err != nil {
+				t.Fatalf("Check: %v", err)
+			}
+			if got.Healthy != tt.wantHealthy {
+				t.Errorf("healthy = %v, want %v (%+v)", got.Healthy, tt.wantHealthy, got)
+			}
+			if !strings.Contains(got.Message, tt.wantMessage) {
+				t.Errorf("message = %q, want it to mention %q", got.Message, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestHealthChecker_Check_BackendTLS(t *testing.T) {
+	var gotScheme string
+	backend := backendFunc(func(r *http.Request) (*http.Response, error) {
+		gotScheme = r.URL.Scheme
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ttyd")), Request: r}, nil
+	})
+	ws := healthWorkspace(workspacev1alpha1.WorkspacePhaseRunning, "alice-workspace-svc.workspaces.svc")
+	fc := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(ws).Build()
+	proxy := NewProxy(zap.New(zap.UseDevMode(true)), ProxyConfig{BackendTLS: &tls.Config{}})
+
+	got, err := NewHealthChecker(fc, proxy, backend).Check(context.Background(), "workspaces", "alice")
+	if err != nil || !got.Healthy {
+		t.Fatalf("Check = %+v, %v; want healthy", got, err)
+	}
+	if gotScheme != "https" {
+		t.Errorf("probe scheme = %q, want https with backend TLS", gotScheme)
+	}
+}
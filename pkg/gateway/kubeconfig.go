@@ -0,0 +1,105 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	workspacev1alpha1 "workspace-operator/api/v1alpha1"
+	worksp "workspace-operator/pkg/workspace"
+)
+
+// DefaultKubeconfigTokenTTL is the lifetime of tokens minted for downloaded
+// kubeconfigs when KubeconfigConfig.TokenTTL is zero.
+const DefaultKubeconfigTokenTTL = time.Hour
+
+// ErrNoWorkspace is returned by KubeconfigIssuer.Issue when the user has no
+// Workspace CR, and therefore no ServiceAccount to mint a token for.
+var ErrNoWorkspace = errors.New("user has no workspace")
+
+// KubeconfigConfig configures the kubeconfigs handed out by KubeconfigIssuer.
+type KubeconfigConfig struct {
+	// Server is the API server URL as reachable from users' machines. The
+	// in-cluster address the gateway itself uses is usually not.
+	Server string
+	// CAData is the PEM bundle that verifies Server. Empty leaves TLS
+	// verification to the client's system roots.
+	CAData []byte
+	// TokenTTL is the requested token lifetime. The API server may shorten it.
+	TokenTTL time.Duration
+}
+
+// KubeconfigIssuer mints short-lived tokens for workspace ServiceAccounts via
+// the TokenRequest API and renders them as kubeconfig files.
+type KubeconfigIssuer struct {
+	client client.Client
+	cfg    KubeconfigConfig
+}
+
+// NewKubeconfigIssuer returns a KubeconfigIssuer using the provided K8s client.
+func NewKubeconfigIssuer(c client.Client, cfg KubeconfigConfig) *KubeconfigIssuer {
+	if cfg.TokenTTL <= 0 {
+		cfg.TokenTTL = DefaultKubeconfigTokenTTL
+	}
+	return &KubeconfigIssuer{client: c, cfg: cfg}
+}
+
+// Issue returns a kubeconfig for the ServiceAccount of userID's workspace in
+// namespace, with the namespace set as the context default, and the token's
+// expiry. It returns ErrNoWorkspace when the user has no Workspace CR.
+func (k *KubeconfigIssuer) Issue(ctx context.Context, namespace, userID string) ([]byte, time.Time, error) {
+	var ws workspacev1alpha1.Workspace
+	if err := k.client.Get(ctx, types.NamespacedName{Name: userID, Namespace: namespace}, &ws); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, time.Time{}, ErrNoWorkspace
+		}
+		return nil, time.Time{}, fmt.Errorf("get workspace %q: %w", userID, err)
+	}
+
+	// Only mint for the ServiceAccount the operator built for this workspace;
+	// a same-named account created by someone else is refused.
+	saName := worksp.ServiceAccountName(ws.Spec.User.ID)
+	sa := &corev1.ServiceAccount{}
+	if err := k.client.Get(ctx, types.NamespacedName{Name: saName, Namespace: namespace}, sa); err != nil {
+		return nil, time.Time{}, fmt.Errorf("get serviceaccount %q: %w", saName, err)
+	}
+	if !metav1.IsControlledBy(sa, &ws) || sa.Labels["app"] != "workspace" || sa.Labels["user"] != ws.Spec.User.ID {
+		return nil, time.Time{}, fmt.Errorf("serviceaccount %q is not the workspace's own account", saName)
+	}
+	seconds := int64(k.cfg.TokenTTL / time.Second)
+	tr := &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{ExpirationSeconds: &seconds},
+	}
+	if err := k.client.SubResource("token").Create(ctx, sa, tr); err != nil {
+		return nil, time.Time{}, fmt.Errorf("request token for serviceaccount %q: %w", saName, err)
+	}
+
+	contextName := fmt.Sprintf("devplane-%s", ws.Name)
+	cfg := clientcmdapi.NewConfig()
+	cfg.Clusters[contextName] = &clientcmdapi.Cluster{
+		Server:                   k.cfg.Server,
+		CertificateAuthorityData: k.cfg.CAData,
+	}
+	cfg.AuthInfos[contextName] = &clientcmdapi.AuthInfo{Token: tr.Status.Token}
+	cfg.Contexts[contextName] = &clientcmdapi.Context{
+		Cluster:   contextName,
+		AuthInfo:  contextName,
+		Namespace: namespace,
+	}
+	cfg.CurrentContext = contextName
+	out, err := clientcmd.Write(*cfg)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("render kubeconfig: %w", err)
+	}
+	return out, tr.Status.ExpirationTimestamp.Time, nil
+}
@@ -0,0 +1,130 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	workspacev1alpha1 "workspace-operator/api/v1alpha1"
+	worksp "workspace-operator/pkg/workspace"
+)
+
+// workspaceSA returns the ServiceAccount the operator builds for ws.
+func workspaceSA(ws *workspacev1alpha1.Workspace) *corev1.ServiceAccount {
+	return &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+		Name:            worksp.ServiceAccountName(ws.Spec.User.ID),
+		Namespace:       ws.Namespace,
+		Labels:          map[string]string{"app": "workspace", "user": ws.Spec.User.ID, "managed-by": "devplane"},
+		OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(ws, workspacev1alpha1.GroupVersion.WithKind("Workspace"))},
+	}}
+}
+
+func TestKubeconfigIssuer_Issue(t *testing.T) {
+	ws := &workspacev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "workspaces"},
+		Spec: workspacev1alpha1.WorkspaceSpec{
+			User: workspacev1alpha1.UserInfo{ID: "alice", Email: "alice@example.com"},
+		},
+	}
+	var tokenFor string
+	var requestedTTL int64
+	fc := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(ws, workspaceSA(ws)).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceCreate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+				if subResourceName != "token" {
+					t.Fatalf("subresource = %q, want token", subResourceName)
+				}
+				tokenFor = obj.GetNamespace() + "/" + obj.GetName()
+				tr := subResource.(*authenticationv1.TokenRequest)
+				requestedTTL = *tr.Spec.ExpirationSeconds
+				tr.Status.Token = "sa-token"
+				tr.Status.ExpirationTimestamp = metav1.NewTime(time.Now().Add(30 * time.Minute))
+				return nil
+			},
+		}).Build()
+
+	issuer := NewKubeconfigIssuer(fc, KubeconfigConfig{
+		Server:   "https://k8s.example.com:6443",
+		CAData:   []byte("ca-pem"),
+		TokenTTL: 30 * time.Minute,
+	})
+	raw, expiry, err := issuer.Issue(context.Background(), "workspaces", "alice")
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if want := "workspaces/" + worksp.ServiceAccountName("alice"); tokenFor != want {
+		t.Errorf("token requested for %q, want %q", tokenFor, want)
+	}
+	if requestedTTL != 1800 {
+		t.Errorf("ExpirationSeconds = %d, want 1800", requestedTTL)
+	}
+	if expiry.IsZero() {
+		t.Error("expected token expiry to be returned")
+	}
+
+	cfg, err := clientcmd.Load(raw)
+	if err != nil {
+		t.Fatalf("kubeconfig does not parse: %v\n%s", err, raw)
+	}
+	ctx := cfg.Contexts[cfg.CurrentContext]
+	if ctx == nil {
+		t.Fatalf("current context %q missing", cfg.CurrentContext)
+	}
+	if ctx.Namespace != "workspaces" {
+		t.Errorf("context namespace = %q, want workspaces", ctx.Namespace)
+	}
+	if got := cfg.AuthInfos[ctx.AuthInfo].Token; got != "sa-token" {
+		t.Errorf("token = %q, want sa-token", got)
+	}
+	cluster := cfg.Clusters[ctx.Cluster]
+	if cluster.Server != "https://k8s.example.com:6443" || string(cluster.CertificateAuthorityData) != "ca-pem" {
+		t.Errorf("cluster = %+v", cluster)
+	}
+}
+
+func TestKubeconfigIssuer_NoWorkspace(t *testing.T) {
+	fc := fake.NewClientBuilder().WithScheme(testScheme).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceCreate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+				t.Fatal("no token must be requested for a user without a workspace")
+				return nil
+			},
+		}).Build()
+	issuer := NewKubeconfigIssuer(fc, KubeconfigConfig{Server: "https://k8s.example.com:6443"})
+	if _, _, err := issuer.Issue(context.Background(), "workspaces", "mallory"); !errors.Is(err, ErrNoWorkspace) {
+		t.Errorf("err = %v, want ErrNoWorkspace", err)
+	}
+}
+
+func TestKubeconfigIssuer_RefusesForeignServiceAccount(t *testing.T) {
+	ws := &workspacev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "workspaces"},
+		Spec:       workspacev1alpha1.WorkspaceSpec{User: workspacev1alpha1.UserInfo{ID: "alice"}},
+	}
+	// Same name as the workspace account, but not owned by the Workspace.
+	foreign := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+		Name:      worksp.ServiceAccountName("alice"),
+		Namespace: "workspaces",
+		Labels:    map[string]string{"app": "workspace", "user": "alice"},
+	}}
+	fc := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(ws, foreign).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourceCreate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+				t.Fatal("no token must be requested for an account the workspace does not own")
+				return nil
+			},
+		}).Build()
+	issuer := NewKubeconfigIssuer(fc, KubeconfigConfig{Server: "https://k8s.example.com:6443"})
+	if _, _, err := issuer.Issue(context.Background(), "workspaces", "alice"); err == nil {
+		t.Error("expected an error for a ServiceAccount not owned by the workspace")
+	}
+}
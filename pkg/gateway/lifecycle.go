@@ -2,11 +2,13 @@ package gateway
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
-	"k8s.io/apimachinery/pkg/api/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -16,10 +18,56 @@ import (
 )
 
 const (
+	// workspaceReadyTimeout is the default LifecycleConfig.ReadyTimeout.
 	workspaceReadyTimeout = 60 * time.Second
 	workspaceReadyPoll    = 2 * time.Second
 )
 
+// ErrWorkspaceNotReady is returned by EnsureWorkspace when the workspace does
+// not reach Running within LifecycleConfig.ReadyTimeout. Callers should answer
+// 504 and let the client retry; the workspace keeps provisioning.
+var ErrWorkspaceNotReady = errors.New("workspace not ready")
+
+// ErrProvisioningBusy is returned by EnsureWorkspace when the workspace is not
+// Running yet and LifecycleConfig.MaxConcurrentWaits callers are already
+// waiting for theirs. Callers should answer 503 and let the client retry.
+var ErrProvisioningBusy = errors.New("too many workspaces provisioning; retry shortly")
+
+// ErrWorkspaceSuspended is returned by EnsureWorkspace when the workspace is
+// stopped because spec.suspended is set; only clearing it resumes the workspace.
+var ErrWorkspaceSuspended = errors.New("workspace is suspended")
+
+// ErrQuotaExceeded is returned by EnsureWorkspace and EnsureExists when
+// creating a workspace would take the user past
+// LifecycleConfig.MaxWorkspacesPerUser. Callers should answer 429.
+var ErrQuotaExceeded = errors.New("workspace quota exceeded")
+
+// CreateThrottledError is returned by EnsureWorkspace and EnsureExists when
+// the user's last Workspace CR creation was less than
+// LifecycleConfig.MinCreateInterval ago. Callers should answer 429.
+type CreateThrottledError struct {
+	UserID string
+	// RetryAfter is how long until the user may create a workspace again.
+	RetryAfter time.Duration
+}
+
+func (e *CreateThrottledError) Error() string {
+	return fmt.Sprintf("workspace for %q created too recently; retry in %s", e.UserID, e.RetryAfter.Round(time.Second))
+}
+
+// WorkspaceElsewhereError is returned by EnsureWorkspace and EnsureExists when
+// the user has no workspace in the requested namespace but already owns one in
+// another namespace. No second workspace is created.
+type WorkspaceElsewhereError struct {
+	// Namespace and Name identify the user's existing Workspace CR.
+	Namespace string
+	Name      string
+}
+
+func (e *WorkspaceElsewhereError) Error() string {
+	return fmt.Sprintf("user already has workspace %s/%s", e.Namespace, e.Name)
+}
+
 // EnsureDetails describes how the Workspace CR was resolved for structured audit logs.
 type EnsureDetails struct {
 	// Created is true if this call created a new Workspace CR.
@@ -35,6 +83,20 @@ type LifecycleConfig struct {
 	DefaultMemory  string
 	DefaultStorage string
 	StorageClass   string
+	// MaxConcurrentWaits caps how many EnsureWorkspace calls may poll the API
+	// server for a workspace to become Running at once. Zero means unlimited.
+	MaxConcurrentWaits int
+	// MaxWorkspacesPerUser caps how many Workspace CRs labeled for one user may
+	// exist across all namespaces before creation is refused. Zero means unlimited.
+	MaxWorkspacesPerUser int
+	// ReadyTimeout bounds how long EnsureWorkspace waits for the workspace to
+	// reach Running. Zero uses the 60s default.
+	ReadyTimeout time.Duration
+	// MinCreateInterval is the minimum time between two Workspace CR creations
+	// for the same user, guarding against create/delete churn. Existing
+	// workspaces are always returned. Creations are tracked in memory, so the
+	// limit applies per gateway replica. Zero disables the limit.
+	MinCreateInterval time.Duration
 }
 
 // LifecycleManager creates and retrieves Workspace custom resources on behalf
@@ -43,15 +105,31 @@ type LifecycleManager struct {
 	client client.Client
 	log    logr.Logger
 	cfg    LifecycleConfig
+	// waitSlots is a semaphore bounding concurrent provisioning waits; nil when unlimited.
+	waitSlots chan struct{}
+	// slotAcquired, when set, is called once a provisioning wait takes a
+	// slot (for tests).
+	slotAcquired func()
+
+	createMu sync.Mutex
+	// lastCreate records each user's most recent successful creation for MinCreateInterval.
+	lastCreate map[string]time.Time
 }
 
 // NewLifecycleManager returns a LifecycleManager using the provided K8s client.
 func NewLifecycleManager(c client.Client, log logr.Logger, cfg LifecycleConfig) *LifecycleManager {
-	return &LifecycleManager{client: c, log: log, cfg: cfg}
+	if cfg.ReadyTimeout <= 0 {
+		cfg.ReadyTimeout = workspaceReadyTimeout
+	}
+	m := &LifecycleManager{client: c, log: log, cfg: cfg, lastCreate: make(map[string]time.Time)}
+	if cfg.MaxConcurrentWaits > 0 {
+		m.waitSlots = make(chan struct{}, cfg.MaxConcurrentWaits)
+	}
+	return m
 }
 
 // EnsureWorkspace gets or creates a Workspace CR for claims.UserID in namespace,
-// then waits up to workspaceReadyTimeout for it to reach the Running phase.
+// then waits up to LifecycleConfig.ReadyTimeout for it to reach the Running phase.
 // It also stamps LastAccessed so the idle-timeout controller can track activity.
 func (m *LifecycleManager) EnsureWorkspace(ctx context.Context, namespace string, claims *Claims) (*workspacev1alpha1.Workspace, EnsureDetails, error) {
 	var details EnsureDetails
@@ -60,11 +138,20 @@ func (m *LifecycleManager) EnsureWorkspace(ctx context.Context, namespace string
 
 	ws := &workspacev1alpha1.Workspace{}
 	err := m.client.Get(ctx, key, ws)
-	if err != nil && !errors.IsNotFound(err) {
+	if err != nil && !apierrors.IsNotFound(err) {
 		return nil, details, fmt.Errorf("get workspace %q: %w", claims.UserID, err)
 	}
 
-	if errors.IsNotFound(err) {
+	if apierrors.IsNotFound(err) {
+		if err := m.checkNotElsewhere(ctx, namespace, claims.UserID); err != nil {
+			return nil, details, err
+		}
+		if err := m.checkQuota(ctx, claims.UserID); err != nil {
+			return nil, details, err
+		}
+		if err := m.throttleCreate(claims.UserID); err != nil {
+			return nil, details, err
+		}
 		details.Created = true
 		ws = &workspacev1alpha1.Workspace{
 			ObjectMeta: metav1.ObjectMeta{
@@ -94,6 +181,7 @@ func (m *LifecycleManager) EnsureWorkspace(ctx context.Context, namespace string
 		if err := m.client.Create(ctx, ws); err != nil {
 			return nil, details, fmt.Errorf("create workspace %q: %w", claims.UserID, err)
 		}
+		m.recordCreate(claims.UserID)
 	}
 
 	var restarted bool
@@ -126,11 +214,20 @@ func (m *LifecycleManager) EnsureExists(ctx context.Context, namespace string, c
 
 	ws := &workspacev1alpha1.Workspace{}
 	err := m.client.Get(ctx, key, ws)
-	if err != nil && !errors.IsNotFound(err) {
+	if err != nil && !apierrors.IsNotFound(err) {
 		return nil, details, fmt.Errorf("get workspace %q: %w", claims.UserID, err)
 	}
 
-	if errors.IsNotFound(err) {
+	if apierrors.IsNotFound(err) {
+		if err := m.checkNotElsewhere(ctx, namespace, claims.UserID); err != nil {
+			return nil, details, err
+		}
+		if err := m.checkQuota(ctx, claims.UserID); err != nil {
+			return nil, details, err
+		}
+		if err := m.throttleCreate(claims.UserID); err != nil {
+			return nil, details, err
+		}
 		details.Created = true
 		ws = &workspacev1alpha1.Workspace{
 			ObjectMeta: metav1.ObjectMeta{
@@ -160,11 +257,13 @@ func (m *LifecycleManager) EnsureExists(ctx context.Context, namespace string, c
 		if err := m.client.Create(ctx, ws); err != nil {
 			return nil, details, fmt.Errorf("create workspace %q: %w", claims.UserID, err)
 		}
+		m.recordCreate(claims.UserID)
 		return ws, details, nil
 	}
 
 	// If Stopped, clear the phase so the operator reconcile loop recreates the pod.
-	if ws.Status.Phase == workspacev1alpha1.WorkspacePhaseStopped {
+	// A suspended workspace is left alone; the operator would stop it again.
+	if ws.Status.Phase == workspacev1alpha1.WorkspacePhaseStopped && !ws.Spec.Suspended {
 		details.RestartedFromStopped = true
 		m.log.Info("Restarting stopped workspace", "workspace", key.Name)
 		patchBase := ws.DeepCopy()
@@ -174,18 +273,92 @@ func (m *LifecycleManager) EnsureExists(ctx context.Context, namespace string, c
 		if patchErr := m.client.Status().Patch(ctx, ws, client.MergeFrom(patchBase)); patchErr != nil {
 			return nil, details, fmt.Errorf("restart stopped workspace %q: %w", key.Name, patchErr)
 		}
+		RecordWorkspaceRestart(key.Namespace)
 	}
 
 	return ws, details, nil
 }
 
+// checkNotElsewhere returns a *WorkspaceElsewhereError when userID already owns
+// a Workspace outside namespace. It is only called before creating a workspace,
+// so the cluster-wide list is paid once per user rather than per request.
+func (m *LifecycleManager) checkNotElsewhere(ctx context.Context, namespace, userID string) error {
+	var list workspacev1alpha1.WorkspaceList
+	if err := m.client.List(ctx, &list); err != nil {
+		return fmt.Errorf("list workspaces for %q: %w", userID, err)
+	}
+	for i := range list.Items {
+		ws := &list.Items[i]
+		if ws.Namespace != namespace && ws.Spec.User.ID == userID {
+			return &WorkspaceElsewhereError{Namespace: ws.Namespace, Name: ws.Name}
+		}
+	}
+	return nil
+}
+
+// checkQuota returns an error wrapping ErrQuotaExceeded when userID already has
+// MaxWorkspacesPerUser Workspaces carrying its user label in any namespace.
+func (m *LifecycleManager) checkQuota(ctx context.Context, userID string) error {
+	if m.cfg.MaxWorkspacesPerUser <= 0 {
+		return nil
+	}
+	var list workspacev1alpha1.WorkspaceList
+	if err := m.client.List(ctx, &list, client.MatchingLabels(worksp.Labels(userID))); err != nil {
+		return fmt.Errorf("list workspaces for %q: %w", userID, err)
+	}
+	if n := len(list.Items); n >= m.cfg.MaxWorkspacesPerUser {
+		return fmt.Errorf("%w: user %q has %d workspaces (limit %d)", ErrQuotaExceeded, userID, n, m.cfg.MaxWorkspacesPerUser)
+	}
+	return nil
+}
+
+// throttleCreate returns a *CreateThrottledError when userID created a workspace
+// less than MinCreateInterval ago. Entries older than the interval are pruned so
+// the map stays bounded by recent creators.
+func (m *LifecycleManager) throttleCreate(userID string) error {
+	if m.cfg.MinCreateInterval <= 0 {
+		return nil
+	}
+	m.createMu.Lock()
+	defer m.createMu.Unlock()
+	now := time.Now()
+	if last, ok := m.lastCreate[userID]; ok && now.Sub(last) < m.cfg.MinCreateInterval {
+		return &CreateThrottledError{UserID: userID, RetryAfter: m.cfg.MinCreateInterval - now.Sub(last)}
+	}
+	for user, last := range m.lastCreate {
+		if now.Sub(last) >= m.cfg.MinCreateInterval {
+			delete(m.lastCreate, user)
+		}
+	}
+	return nil
+}
+
+// recordCreate starts userID's MinCreateInterval after a successful creation, so
+// a failed Create does not lock the user out of retrying.
+func (m *LifecycleManager) recordCreate(userID string) {
+	if m.cfg.MinCreateInterval <= 0 {
+		return
+	}
+	m.createMu.Lock()
+	defer m.createMu.Unlock()
+	m.lastCreate[userID] = time.Now()
+}
+
 // waitForRunning polls until the Workspace reaches Running or the deadline passes.
 // When the workspace is Stopped it patches the status to clear the phase, allowing
 // the operator to recreate the pod, then continues polling.
 // The returned bool is true if a Stopped workspace was restarted during the wait.
+// A wait slot is only taken once the workspace is found not Running, so users
+// whose workspace is already up are never rejected with ErrProvisioningBusy.
 func (m *LifecycleManager) waitForRunning(ctx context.Context, key types.NamespacedName) (*workspacev1alpha1.Workspace, bool, error) {
 	var restartedFromStopped bool
-	deadline := time.Now().Add(workspaceReadyTimeout)
+	var holdingSlot bool
+	defer func() {
+		if holdingSlot {
+			<-m.waitSlots
+		}
+	}()
+	deadline := time.Now().Add(m.cfg.ReadyTimeout)
 	for time.Now().Before(deadline) {
 		ws := &workspacev1alpha1.Workspace{}
 		if err := m.client.Get(ctx, key, ws); err != nil {
@@ -197,6 +370,9 @@ func (m *LifecycleManager) waitForRunning(ctx context.Context, key types.Namespa
 		case workspacev1alpha1.WorkspacePhaseFailed:
 			return nil, restartedFromStopped, fmt.Errorf("workspace %q failed: %s", key.Name, ws.Status.Message)
 		case workspacev1alpha1.WorkspacePhaseStopped:
+			if ws.Spec.Suspended {
+				return nil, restartedFromStopped, fmt.Errorf("%w: %q", ErrWorkspaceSuspended, key.Name)
+			}
 			// Clear the Stopped phase so the operator reconcile loop recreates the pod.
 			restartedFromStopped = true
 			m.log.Info("Restarting stopped workspace", "workspace", key.Name)
@@ -207,15 +383,28 @@ func (m *LifecycleManager) waitForRunning(ctx context.Context, key types.Namespa
 			if patchErr := m.client.Status().Patch(ctx, ws, client.MergeFrom(patchBase)); patchErr != nil {
 				return nil, restartedFromStopped, fmt.Errorf("restart stopped workspace %q: %w", key.Name, patchErr)
 			}
+			RecordWorkspaceRestart(key.Namespace)
+		}
+		if m.waitSlots != nil && !holdingSlot {
+			select {
+			case m.waitSlots <- struct{}{}:
+				holdingSlot = true
+				if m.slotAcquired != nil {
+					m.slotAcquired()
+				}
+			default:
+				m.log.Info("Provisioning wait limit reached", "workspace", key.Name, "limit", cap(m.waitSlots))
+				return nil, restartedFromStopped, ErrProvisioningBusy
+			}
 		}
 		m.log.Info("Waiting for workspace", "workspace", key.Name, "phase", ws.Status.Phase)
 		select {
 		case <-ctx.Done():
 			return nil, restartedFromStopped, ctx.Err()
-		case <-time.After(workspaceReadyPoll):
+		case <-time.After(min(workspaceReadyPoll, time.Until(deadline))):
 		}
 	}
-	return nil, restartedFromStopped, fmt.Errorf("workspace %q not ready after %s", key.Name, workspaceReadyTimeout)
+	return nil, restartedFromStopped, fmt.Errorf("%w: %q did not reach Running within %s", ErrWorkspaceNotReady, key.Name, m.cfg.ReadyTimeout)
 }
 
 // TouchLastAccessed stamps the workspace's LastAccessed to now.
@@ -2,9 +2,13 @@ package gateway
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
 
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -12,6 +16,7 @@ import (
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	workspacev1alpha1 "workspace-operator/api/v1alpha1"
@@ -121,6 +126,45 @@ func TestEnsureWorkspace_FailedWorkspace(t *testing.T) {
 	}
 }
 
+func TestEnsureWorkspace_SuspendedWorkspaceNotRestarted(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	fc := fake.NewClientBuilder().WithScheme(testScheme).
+		WithStatusSubresource(&workspacev1alpha1.Workspace{}).
+		Build()
+	lm := NewLifecycleManager(fc, zap.New(zap.UseDevMode(true)), testConfig())
+
+	ws := &workspacev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "sleepy", Namespace: "default"},
+		Spec: workspacev1alpha1.WorkspaceSpec{
+			User:      workspacev1alpha1.UserInfo{ID: "sleepy", Email: "sleepy@test.com"},
+			Resources: workspacev1alpha1.ResourceRequirements{CPU: "1", Memory: "1Gi", Storage: "10Gi"},
+			Suspended: true,
+		},
+	}
+	if err := fc.Create(ctx, ws); err != nil {
+		t.Fatalf("Create workspace: %v", err)
+	}
+	ws.Status.Phase = workspacev1alpha1.WorkspacePhaseStopped
+	ws.Status.Message = "Suspended by user"
+	if err := fc.Status().Update(ctx, ws); err != nil {
+		t.Fatalf("Update status: %v", err)
+	}
+
+	claims := &Claims{Sub: "sleepy", Email: "sleepy@test.com", UserID: "sleepy"}
+	if _, _, err := lm.EnsureWorkspace(ctx, "default", claims); !errors.Is(err, ErrWorkspaceSuspended) {
+		t.Fatalf("EnsureWorkspace err = %v, want ErrWorkspaceSuspended", err)
+	}
+	got, details, err := lm.EnsureExists(ctx, "default", claims)
+	if err != nil {
+		t.Fatalf("EnsureExists: %v", err)
+	}
+	if details.RestartedFromStopped || got.Status.Phase != workspacev1alpha1.WorkspacePhaseStopped {
+		t.Errorf("phase = %q (restarted %v), want Stopped left in place", got.Status.Phase, details.RestartedFromStopped)
+	}
+}
+
 func TestEnsureWorkspace_CreatesNewCR(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -266,6 +310,96 @@ func TestWaitForRunning_ContextCancelled(t *testing.T) {
 	}
 }
 
+func TestEnsureWorkspace_ReadyTimeout(t *testing.T) {
+	ctx := context.Background()
+	// The workspace stays Creating; nothing ever moves it to Running.
+	ws := &workspacev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "slow", Namespace: "default"},
+		Spec: workspacev1alpha1.WorkspaceSpec{
+			User: workspacev1alpha1.UserInfo{ID: "slow", Email: "slow@test.com"},
+		},
+		Status: workspacev1alpha1.WorkspaceStatus{Phase: workspacev1alpha1.WorkspacePhaseCreating},
+	}
+	fc := fake.NewClientBuilder().WithScheme(testScheme).
+		WithStatusSubresource(&workspacev1alpha1.Workspace{}).
+		WithObjects(ws).
+		Build()
+	cfg := testConfig()
+	cfg.ReadyTimeout = 50 * time.Millisecond
+	lm := NewLifecycleManager(fc, zap.New(zap.UseDevMode(true)), cfg)
+
+	start := time.Now()
+	_, _, err := lm.EnsureWorkspace(ctx, "default", &Claims{Sub: "slow", Email: "slow@test.com", UserID: "slow"})
+	if !errors.Is(err, ErrWorkspaceNotReady) {
+		t.Fatalf("err = %v, want ErrWorkspaceNotReady", err)
+	}
+	if elapsed := time.Since(start); elapsed > workspaceReadyPoll {
+		t.Errorf("EnsureWorkspace took %s, want it bounded by the 50ms ReadyTimeout", elapsed)
+	}
+}
+
+func TestNewLifecycleManager_DefaultReadyTimeout(t *testing.T) {
+	lm := NewLifecycleManager(nil, zap.New(zap.UseDevMode(true)), testConfig())
+	if lm.cfg.ReadyTimeout != workspaceReadyTimeout {
+		t.Errorf("ReadyTimeout = %s, want %s", lm.cfg.ReadyTimeout, workspaceReadyTimeout)
+	}
+}
+
+func TestEnsureWorkspace_MaxConcurrentWaits(t *testing.T) {
+	const limit = 2
+	fc := fake.NewClientBuilder().WithScheme(testScheme).
+		WithStatusSubresource(&workspacev1alpha1.Workspace{}).
+		Build()
+	log := zap.New(zap.UseDevMode(true))
+	cfg := testConfig()
+	cfg.MaxConcurrentWaits = limit
+	lm := NewLifecycleManager(fc, log, cfg)
+	acquired := make(chan struct{}, limit)
+	lm.slotAcquired = func() { acquired <- struct{}{} }
+
+	// Fill every wait slot with callers whose workspaces never become Running.
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	waitErrs := make(chan error, limit)
+	for i := 0; i < limit; i++ {
+		userID := fmt.Sprintf("storm%d", i)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := lm.EnsureWorkspace(ctx, "default", &Claims{Sub: userID, UserID: userID})
+			waitErrs <- err
+		}()
+	}
+	for i := 0; i < limit; i++ {
+		select {
+		case <-acquired:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("waiters holding slots = %d, want %d", i, limit)
+		}
+	}
+
+	// Callers beyond the limit fail fast instead of polling the API server.
+	for i := 0; i < 3; i++ {
+		userID := fmt.Sprintf("excess%d", i)
+		_, _, err := lm.EnsureWorkspace(context.Background(), "default", &Claims{Sub: userID, UserID: userID})
+		if !errors.Is(err, ErrProvisioningBusy) {
+			t.Errorf("excess caller %d: err = %v, want ErrProvisioningBusy", i, err)
+		}
+	}
+
+	cancel()
+	wg.Wait()
+	close(waitErrs)
+	for err := range waitErrs {
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("waiter err = %v, want context.Canceled", err)
+		}
+	}
+	if n := len(lm.waitSlots); n != 0 {
+		t.Errorf("slots still held after waiters returned: %d", n)
+	}
+}
+
 func TestTouchLastAccessed(t *testing.T) {
 	ctx := context.Background()
 	fc := fake.NewClientBuilder().WithScheme(testScheme).
@@ -393,6 +527,190 @@ func TestEnsureExists_CreatesNewCR(t *testing.T) {
 	}
 }
 
+func TestEnsureExists_WorkspaceInOtherNamespace(t *testing.T) {
+	ctx := context.Background()
+	existing := &workspacev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "roamer", Namespace: "team-a"},
+		Spec: workspacev1alpha1.WorkspaceSpec{
+			User: workspacev1alpha1.UserInfo{ID: "roamer", Email: "roamer@test.com"},
+		},
+	}
+	fc := fake.NewClientBuilder().WithScheme(testScheme).
+		WithStatusSubresource(&workspacev1alpha1.Workspace{}).
+		WithObjects(existing).
+		Build()
+	lm := NewLifecycleManager(fc, zap.New(zap.UseDevMode(true)), testConfig())
+	claims := &Claims{Sub: "roamer", Email: "roamer@test.com", UserID: "roamer"}
+
+	for name, ensure := range map[string]func(context.Context, string, *Claims) (*workspacev1alpha1.Workspace, EnsureDetails, error){
+		"EnsureExists":    lm.EnsureExists,
+		"EnsureWorkspace": lm.EnsureWorkspace,
+	} {
+		_, details, err := ensure(ctx, "team-b", claims)
+		var elsewhere *WorkspaceElsewhereError
+		if !errors.As(err, &elsewhere) {
+			t.Fatalf("%s: err = %v, want *WorkspaceElsewhereError", name, err)
+		}
+		if elsewhere.Namespace != "team-a" || elsewhere.Name != "roamer" {
+			t.Errorf("%s: elsewhere = %+v, want team-a/roamer", name, elsewhere)
+		}
+		if details.Created {
+			t.Errorf("%s: expected Created=false", name)
+		}
+	}
+	var list workspacev1alpha1.WorkspaceList
+	if err := fc.List(ctx, &list); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list.Items) != 1 {
+		t.Errorf("workspaces = %d, want 1 (no duplicate created)", len(list.Items))
+	}
+}
+
+func TestEnsure_MaxWorkspacesPerUser(t *testing.T) {
+	ctx := context.Background()
+	// A stray CR labeled for the user, e.g. created by hand under another name.
+	stray := &workspacev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "quota-old", Namespace: "default", Labels: worksp.Labels("quota")},
+		Spec: workspacev1alpha1.WorkspaceSpec{
+			User: workspacev1alpha1.UserInfo{ID: "quota", Email: "quota@test.com"},
+		},
+	}
+	claims := &Claims{Sub: "quota", Email: "quota@test.com", UserID: "quota"}
+
+	for _, tc := range []struct {
+		limit       int
+		wantCreated bool
+	}{
+		{limit: 1, wantCreated: false},
+		{limit: 2, wantCreated: true},
+		{limit: 0, wantCreated: true},
+	} {
+		fc := fake.NewClientBuilder().WithScheme(testScheme).
+			WithStatusSubresource(&workspacev1alpha1.Workspace{}).
+			WithObjects(stray.DeepCopy()).
+			Build()
+		cfg := testConfig()
+		cfg.MaxWorkspacesPerUser = tc.limit
+		lm := NewLifecycleManager(fc, zap.New(zap.UseDevMode(true)), cfg)
+
+		_, details, err := lm.EnsureExists(ctx, "default", claims)
+		if tc.wantCreated {
+			if err != nil || !details.Created {
+				t.Errorf("limit %d: err = %v, created = %v; want a new workspace", tc.limit, err, details.Created)
+			}
+			continue
+		}
+		if !errors.Is(err, ErrQuotaExceeded) {
+			t.Fatalf("limit %d: err = %v, want ErrQuotaExceeded", tc.limit, err)
+		}
+		if _, _, err := lm.EnsureWorkspace(ctx, "default", claims); !errors.Is(err, ErrQuotaExceeded) {
+			t.Errorf("limit %d: EnsureWorkspace err = %v, want ErrQuotaExceeded", tc.limit, err)
+		}
+		var list workspacev1alpha1.WorkspaceList
+		if err := fc.List(ctx, &list); err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		if len(list.Items) != 1 {
+			t.Errorf("limit %d: workspaces = %d, want 1 (none created over quota)", tc.limit, len(list.Items))
+		}
+	}
+}
+
+func TestEnsureExists_MinCreateIntervalThrottlesRecreation(t *testing.T) {
+	ctx := context.Background()
+	fc := fake.NewClientBuilder().WithScheme(testScheme).
+		WithStatusSubresource(&workspacev1alpha1.Workspace{}).
+		Build()
+	cfg := testConfig()
+	cfg.MinCreateInterval = time.Minute
+	lm := NewLifecycleManager(fc, zap.New(zap.UseDevMode(true)), cfg)
+	claims := &Claims{Sub: "churn", Email: "churn@test.com", UserID: "churn"}
+
+	ws, details, err := lm.EnsureExists(ctx, "default", claims)
+	if err != nil || !details.Created {
+		t.Fatalf("first EnsureExists: err = %v, created = %v", err, details.Created)
+	}
+	// While the CR exists it is returned, not throttled.
+	if _, details, err := lm.EnsureExists(ctx, "default", claims); err != nil || details.Created {
+		t.Fatalf("EnsureExists on existing CR: err = %v, created = %v", err, details.Created)
+	}
+
+	// Deleting and immediately recreating is throttled.
+	if err := fc.Delete(ctx, ws); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	_, _, err = lm.EnsureExists(ctx, "default", claims)
+	var throttled *CreateThrottledError
+	if !errors.As(err, &throttled) {
+		t.Fatalf("rapid re-creation: err = %v, want *CreateThrottledError", err)
+	}
+	if throttled.RetryAfter <= 0 || throttled.RetryAfter > time.Minute {
+		t.Errorf("RetryAfter = %s, want within (0, 1m]", throttled.RetryAfter)
+	}
+	if err := fc.Get(ctx, types.NamespacedName{Name: "churn", Namespace: "default"}, &workspacev1alpha1.Workspace{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Get after throttled create: err = %v, want NotFound", err)
+	}
+
+	// Other users are not affected.
+	other := &Claims{Sub: "fresh", Email: "fresh@test.com", UserID: "fresh"}
+	if _, details, err := lm.EnsureExists(ctx, "default", other); err != nil || !details.Created {
+		t.Errorf("other user: err = %v, created = %v", err, details.Created)
+	}
+}
+
+func TestEnsureExists_FailedCreateNotThrottled(t *testing.T) {
+	ctx := context.Background()
+	failCreate := true
+	fc := fake.NewClientBuilder().WithScheme(testScheme).
+		WithStatusSubresource(&workspacev1alpha1.Workspace{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				if failCreate {
+					return apierrors.NewServiceUnavailable("etcd unavailable")
+				}
+				return c.Create(ctx, obj, opts...)
+			},
+		}).
+		Build()
+	cfg := testConfig()
+	cfg.MinCreateInterval = time.Minute
+	lm := NewLifecycleManager(fc, zap.New(zap.UseDevMode(true)), cfg)
+	claims := &Claims{Sub: "retry", Email: "retry@test.com", UserID: "retry"}
+
+	if _, _, err := lm.EnsureExists(ctx, "default", claims); err == nil {
+		t.Fatal("EnsureExists with failing Create: err = nil, want error")
+	}
+	// The failed attempt does not start the interval, so a retry goes through.
+	failCreate = false
+	if _, details, err := lm.EnsureExists(ctx, "default", claims); err != nil || !details.Created {
+		t.Errorf("retry after failed create: err = %v, created = %v", err, details.Created)
+	}
+}
+
+func TestEnsureExists_FirstTimeIgnoresOtherUsersElsewhere(t *testing.T) {
+	ctx := context.Background()
+	other := &workspacev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "someone", Namespace: "team-a"},
+		Spec: workspacev1alpha1.WorkspaceSpec{
+			User: workspacev1alpha1.UserInfo{ID: "someone", Email: "someone@test.com"},
+		},
+	}
+	fc := fake.NewClientBuilder().WithScheme(testScheme).
+		WithStatusSubresource(&workspacev1alpha1.Workspace{}).
+		WithObjects(other).
+		Build()
+	lm := NewLifecycleManager(fc, zap.New(zap.UseDevMode(true)), testConfig())
+
+	ws, details, err := lm.EnsureExists(ctx, "team-b", &Claims{Sub: "fresh", Email: "fresh@test.com", UserID: "fresh"})
+	if err != nil {
+		t.Fatalf("EnsureExists: %v", err)
+	}
+	if !details.Created || ws.Namespace != "team-b" {
+		t.Errorf("created=%v namespace=%q, want a new workspace in team-b", details.Created, ws.Namespace)
+	}
+}
+
 func TestEnsureExists_ExistingRunningReturnsImmediately(t *testing.T) {
 	ctx := context.Background()
 	fc := fake.NewClientBuilder().WithScheme(testScheme).
@@ -467,11 +785,15 @@ func TestEnsureExists_StoppedResetsPhase(t *testing.T) {
 
 	lm := NewLifecycleManager(fc, log, testConfig())
 	claims := &Claims{Sub: "stopex", Email: "stop@test.com", UserID: "stopex"}
+	restarts := WorkspaceRestartsTotal("default")
 
 	result, details, err := lm.EnsureExists(ctx, "default", claims)
 	if err != nil {
 		t.Fatalf("EnsureExists: %v", err)
 	}
+	if got := WorkspaceRestartsTotal("default"); got != restarts+1 {
+		t.Errorf("workspace restarts = %v, want %v", got, restarts+1)
+	}
 	if !details.RestartedFromStopped {
 		t.Error("EnsureExists: expected RestartedFromStopped=true for Stopped workspace")
 	}
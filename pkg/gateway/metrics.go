@@ -1,6 +1,7 @@
 package gateway
 
 import (
+	"errors"
 	"strconv"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -31,6 +32,7 @@ const (
 	EventWSProxySessionEnd      = "gateway.ws.session.end"
 	EventHTTPBackendUnreachable = "gateway.http.backend_unreachable"
 	EventRateLimited            = "gateway.rate_limit.exceeded"
+	EventHTTPAccess             = "gateway.http.access"
 )
 
 // LogKeyRequestID is the structured-log field for HTTP request correlation.
@@ -55,8 +57,95 @@ var (
 		},
 		[]string{"endpoint", "scope"},
 	)
+	workspaceRestarts = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "devplane",
+			Subsystem: "gateway",
+			Name:      "workspace_restarts_total",
+			Help:      "Stopped workspaces (e.g. after an idle timeout) restarted because their user came back.",
+		},
+		[]string{"namespace"},
+	)
 )
 
+// tokenCacheMetrics tracks Validator cache activity. A nil *tokenCacheMetrics
+// records nothing, so validators built without a Registerer need no registry.
+type tokenCacheMetrics struct {
+	hits      prometheus.Counter
+	misses    prometheus.Counter
+	evictions prometheus.Counter
+	size      prometheus.Gauge
+}
+
+// newTokenCacheMetrics registers the token cache metrics with reg, reusing
+// collectors already registered by another Validator. The counters are shared
+// across issuers; the entries gauge is labelled with issuer, since each
+// Validator reports the size of its own cache. A nil reg returns nil.
+func newTokenCacheMetrics(reg prometheus.Registerer, issuer string) *tokenCacheMetrics {
+	if reg == nil {
+		return nil
+	}
+	counter := func(name, help string) prometheus.Counter {
+		return registerOrExisting(reg, prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "devplane",
+			Subsystem: "gateway",
+			Name:      name,
+			Help:      help,
+		}))
+	}
+	return &tokenCacheMetrics{
+		hits:      counter("token_cache_hits_total", "Token validations answered from the verified-token cache."),
+		misses:    counter("token_cache_misses_total", "Token validations that had to verify the token against the IdP keys."),
+		evictions: counter("token_cache_evictions_total", "Cached tokens dropped for expiring or for exceeding the cache size limit."),
+		size: registerOrExisting(reg, prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "devplane",
+			Subsystem: "gateway",
+			Name:      "token_cache_entries",
+			Help:      "Tokens currently held in the verified-token cache, by issuer.",
+		}, []string{"issuer"})).WithLabelValues(issuer),
+	}
+}
+
+// registerOrExisting registers c with reg, returning the collector already
+// registered under the same descriptor if there is one.
+func registerOrExisting[C prometheus.Collector](reg prometheus.Registerer, c C) C {
+	if err := reg.Register(c); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(C); ok {
+				return existing
+			}
+		}
+	}
+	return c
+}
+
+func (m *tokenCacheMetrics) hit() {
+	if m != nil {
+		m.hits.Inc()
+	}
+}
+
+func (m *tokenCacheMetrics) miss() {
+	if m != nil {
+		m.misses.Inc()
+	}
+}
+
+// evicted counts n evictions and records the resulting cache size.
+func (m *tokenCacheMetrics) evicted(n, size int) {
+	if m != nil {
+		m.evictions.Add(float64(n))
+		m.size.Set(float64(size))
+	}
+}
+
+func (m *tokenCacheMetrics) setSize(size int) {
+	if m != nil {
+		m.size.Set(float64(size))
+	}
+}
+
 // RecordJSONAPIError increments Prometheus counters for a JSON error response.
 func RecordJSONAPIError(httpStatus int, code string) {
 	jsonAPIErrors.WithLabelValues(strconv.Itoa(httpStatus), code).Inc()
@@ -72,3 +161,14 @@ func RecordRateLimitHit(endpoint, scope string) {
 func RateLimitHitsTotal(endpoint, scope string) float64 {
 	return testutil.ToFloat64(rateLimitHits.WithLabelValues(endpoint, scope))
 }
+
+// RecordWorkspaceRestart increments the stopped-workspace restart counter.
+func RecordWorkspaceRestart(namespace string) {
+	workspaceRestarts.WithLabelValues(namespace).Inc()
+}
+
+// WorkspaceRestartsTotal returns the current value of
+// devplane_gateway_workspace_restarts_total for namespace (for tests).
+func WorkspaceRestartsTotal(namespace string) float64 {
+	return testutil.ToFloat64(workspaceRestarts.WithLabelValues(namespace))
+}
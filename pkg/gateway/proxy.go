@@ -2,24 +2,55 @@ package gateway
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/gorilla/websocket"
+
+	workspacev1alpha1 "workspace-operator/api/v1alpha1"
+	worksp "workspace-operator/pkg/workspace"
 )
 
 const (
-	ttydPort           = 7681
 	backendDialTimeout = 30 * time.Second
 	// maxWSFrameBytes caps a single WebSocket message from either peer to limit memory
 	// use if a client or ttyd misbehaves (default gorilla limit is unlimited).
 	maxWSFrameBytes = 1 << 20 // 1 MiB
+	// maxBackendErrorBody caps how much of a failed handshake response body is
+	// kept in the returned error.
+	maxBackendErrorBody = 512
+	// maxCloseReasonBytes is the WebSocket limit on a close frame's reason text.
+	maxCloseReasonBytes = 123
+	// defaultKeepaliveInterval is the default ProxyConfig.KeepaliveInterval,
+	// well under the common 60s idle timeout of cloud load balancers.
+	defaultKeepaliveInterval = 30 * time.Second
+	// pingWriteTimeout bounds how long sending a single ping may block.
+	pingWriteTimeout = 5 * time.Second
+	// closeHandshakeTimeout bounds how long a tunnel drained by Shutdown waits
+	// for the peers to answer its close frames before the connections are closed.
+	closeHandshakeTimeout = 5 * time.Second
 )
 
+// ErrTooManyConnections is returned by ServeWS when the user already holds
+// ProxyConfig.MaxConnectionsPerUser open tunnels. ServeWS has answered the
+// request with HTTP 429 and no upgrade or backend dial took place.
+var ErrTooManyConnections = errors.New("too many concurrent WebSocket connections for user")
+
+// ErrProxyShuttingDown is returned by ServeWS once Shutdown has been called.
+// ServeWS has answered the request with HTTP 503.
+var ErrProxyShuttingDown = errors.New("WebSocket proxy is shutting down")
+
 // wsBackendDialer matches DefaultDialer but uses the same handshake timeout as
 // backendDialTimeout and honors HTTP_PROXY for outbound dials from the gateway.
 var wsBackendDialer = &websocket.Dialer{
@@ -34,26 +65,236 @@ var upgrader = websocket.Upgrader{
 	Subprotocols: []string{"tty"},
 }
 
+// ProxyConfig tunes a Proxy.
+type ProxyConfig struct {
+	// KeepaliveInterval is how often a ping is sent on both the client and the
+	// backend connection. A peer that answers no pong for two intervals is
+	// considered dead. Zero uses 30s; a negative value disables keepalive.
+	KeepaliveInterval time.Duration
+	// BackendTLS, when set, dials workspace backends over wss:// with this TLS
+	// configuration, for pods that terminate TLS themselves. Nil uses ws://.
+	BackendTLS *tls.Config
+	// BackendPath is the path ttyd serves its WebSocket on (e.g. "/terminal/ws"
+	// when ttyd runs with --base-path). Empty dials the root.
+	BackendPath string
+	// MaxConnectionsPerUser caps concurrent tunnels per user ID. Zero means unlimited.
+	MaxConnectionsPerUser int
+	// OnTTYDControl, when set, inspects client frames for ttyd commands
+	// (init, input, resize, pause, resume) for metrics or policy. Frames are
+	// forwarded unchanged either way.
+	OnTTYDControl TTYDControlHook
+}
+
 // Proxy upgrades an HTTP request to WebSocket and bidirectionally proxies
 // frames to a backend workspace pod.
 type Proxy struct {
-	log logr.Logger
+	log               logr.Logger
+	keepaliveInterval time.Duration
+	dialer            *websocket.Dialer
+	backendTLS        bool
+	backendPath       string
+	maxConnsPerUser   int
+	onTTYDControl     TTYDControlHook
+
+	// connReleased, when set, is called after releaseConn frees a slot of
+	// userID (for tests).
+	connReleased func(userID string)
+
+	mu       sync.Mutex
+	conns    map[string]int // open tunnels per user ID
+	closing  bool
+	shutdown chan struct{} // closed by Shutdown
+	tunnels  sync.WaitGroup
 }
 
 // FrameObserver receives each proxied WebSocket frame directionally.
 type FrameObserver func(direction string, msgType int, payload []byte)
 
 // NewProxy creates a Proxy that uses log for structured logging.
-func NewProxy(log logr.Logger) *Proxy {
-	return &Proxy{log: log}
+func NewProxy(log logr.Logger, cfg ProxyConfig) *Proxy {
+	interval := cfg.KeepaliveInterval
+	if interval == 0 {
+		interval = defaultKeepaliveInterval
+	}
+	dialer := wsBackendDialer
+	if cfg.BackendTLS != nil {
+		d := *wsBackendDialer
+		d.TLSClientConfig = cfg.BackendTLS
+		dialer = &d
+	}
+	return &Proxy{
+		log:               log,
+		keepaliveInterval: interval,
+		dialer:            dialer,
+		backendTLS:        cfg.BackendTLS != nil,
+		backendPath:       normalizeBackendPath(cfg.BackendPath),
+		maxConnsPerUser:   cfg.MaxConnectionsPerUser,
+		onTTYDControl:     cfg.OnTTYDControl,
+		conns:             make(map[string]int),
+		shutdown:          make(chan struct{}),
+	}
+}
+
+// Shutdown drains the open tunnels: each one sends a going-away close frame to
+// the client and the backend and ends once the peers answer or after a short
+// timeout. ServeWS refuses new tunnels from then on. Shutdown returns when all
+// tunnels have ended or ctx is done, whichever comes first.
+func (p *Proxy) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closing {
+		p.closing = true
+		close(p.shutdown)
+	}
+	p.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		p.tunnels.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// beginTunnel registers a tunnel with the drain group. It reports false once
+// Shutdown has been called.
+func (p *Proxy) beginTunnel() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closing {
+		return false
+	}
+	p.tunnels.Add(1)
+	return true
+}
+
+// acquireConn reserves a tunnel slot for userID. It reports false when the
+// user is already at the limit.
+func (p *Proxy) acquireConn(userID string) bool {
+	if p.maxConnsPerUser <= 0 {
+		return true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conns[userID] >= p.maxConnsPerUser {
+		return false
+	}
+	p.conns[userID]++
+	return true
+}
+
+// releaseConn frees a slot taken by acquireConn.
+func (p *Proxy) releaseConn(userID string) {
+	if p.maxConnsPerUser <= 0 {
+		return
+	}
+	p.mu.Lock()
+	if p.conns[userID] <= 1 {
+		delete(p.conns, userID)
+	} else {
+		p.conns[userID]--
+	}
+	p.mu.Unlock()
+	if p.connReleased != nil {
+		p.connReleased(userID)
+	}
+}
+
+// BackendURL returns the WebSocket URL for a workspace's ttyd service on port:
+// wss:// when the Proxy was configured with BackendTLS, ws:// otherwise,
+// followed by the configured BackendPath.
+func (p *Proxy) BackendURL(serviceEndpoint string, port int32) string {
+	u := url.URL{Scheme: "ws", Host: fmt.Sprintf("%s:%d", serviceEndpoint, port), Path: p.backendPath}
+	if p.backendTLS {
+		u.Scheme = "wss"
+	}
+	return u.String()
+}
+
+// BackendHTTPURL returns the HTTP URL for a workspace's ttyd service on port:
+// https:// when the Proxy was configured with BackendTLS, http:// otherwise.
+// Pair it with a transport built from the same TLS configuration.
+func (p *Proxy) BackendHTTPURL(serviceEndpoint string, port int32) string {
+	u := url.URL{Scheme: "http", Host: fmt.Sprintf("%s:%d", serviceEndpoint, port)}
+	if p.backendTLS {
+		u.Scheme = "https"
+	}
+	return u.String()
+}
+
+// normalizeBackendPath trims path and gives a non-empty path a leading slash.
+func normalizeBackendPath(path string) string {
+	path = strings.TrimSpace(path)
+	if path == "" || strings.HasPrefix(path, "/") {
+		return path
+	}
+	return "/" + path
+}
+
+// BackendTLSConfig returns the TLS configuration for wss:// backends. caFile
+// is a PEM bundle of CAs to trust; empty uses the system roots, which include
+// SSL_CERT_FILE (e.g. the gateway's custom CA bundle).
+func BackendTLSConfig(caFile string) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile == "" {
+		return cfg, nil
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("read backend CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("backend CA file %q contains no PEM certificates", caFile)
+	}
+	cfg.RootCAs = pool
+	return cfg, nil
 }
 
 // ServeWS upgrades r to WebSocket and proxies traffic to backendURL.
+// userID keys the MaxConnectionsPerUser limit; when it is reached the request
+// is refused with HTTP 429 and ErrTooManyConnections before any upgrade.
 // onActivity is called on each forwarded frame so callers can update an
 // idle-timeout timestamp; pass nil to disable activity tracking.
-// It blocks until either side closes the connection.
-func (p *Proxy) ServeWS(w http.ResponseWriter, r *http.Request, backendURL string, onActivity func(), onFrame FrameObserver) error {
-	clientConn, err := upgrader.Upgrade(w, r, nil)
+// It blocks until either side closes the connection or Shutdown drains it.
+func (p *Proxy) ServeWS(w http.ResponseWriter, r *http.Request, userID, backendURL string, onActivity func(), onFrame FrameObserver) error {
+	return p.serveWS(w, r, userID, backendURL, false, onActivity, onFrame)
+}
+
+// ServeWSReadOnly is ServeWS for a viewer of someone else's terminal: backend
+// output is forwarded as usual, but client frames other than the ttyd
+// handshake and flow control are dropped, so keystrokes and resizes never
+// reach the workspace. userID is the viewer's ID and keys the connection
+// limit. Viewing does not count as workspace activity.
+func (p *Proxy) ServeWSReadOnly(w http.ResponseWriter, r *http.Request, userID, backendURL string, onFrame FrameObserver) error {
+	return p.serveWS(w, r, userID, backendURL, true, nil, onFrame)
+}
+
+func (p *Proxy) serveWS(w http.ResponseWriter, r *http.Request, userID, backendURL string, readOnly bool, onActivity func(), onFrame FrameObserver) error {
+	if !p.beginTunnel() {
+		http.Error(w, "gateway shutting down", http.StatusServiceUnavailable)
+		return ErrProxyShuttingDown
+	}
+	defer p.tunnels.Done()
+	if !p.acquireConn(userID) {
+		WriteJSONError(w, http.StatusTooManyRequests, RateLimitErrorCode)
+		return ErrTooManyConnections
+	}
+	// Deferred first so it runs last: the slot is freed only after both
+	// connections are closed and the keepalive pingers have stopped.
+	defer p.releaseConn(userID)
+
+	// Forward cookies set before the upgrade (e.g. a refreshed session token);
+	// the upgrader writes its own response and ignores w.Header().
+	var respHeader http.Header
+	if cookies := w.Header().Values("Set-Cookie"); len(cookies) > 0 {
+		respHeader = http.Header{"Set-Cookie": cookies}
+	}
+	clientConn, err := upgrader.Upgrade(w, r, respHeader)
 	if err != nil {
 		return fmt.Errorf("upgrade client connection: %w", err)
 	}
@@ -68,42 +309,129 @@ func (p *Proxy) ServeWS(w http.ResponseWriter, r *http.Request, backendURL strin
 	if subproto := clientConn.Subprotocol(); subproto != "" {
 		backendHeaders = http.Header{"Sec-WebSocket-Protocol": []string{subproto}}
 	}
-	backendConn, _, err := wsBackendDialer.DialContext(dialCtx, backendURL, backendHeaders)
+	backendConn, resp, err := p.dialer.DialContext(dialCtx, backendURL, backendHeaders)
 	if err != nil {
-		return fmt.Errorf("dial backend %q: %w", backendURL, err)
+		dialErr := newBackendDialError(backendURL, resp, err)
+		// Tell the already-upgraded client why, instead of dropping the connection.
+		_ = clientConn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseTryAgainLater, dialErr.closeReason()),
+			time.Now().Add(time.Second))
+		return dialErr
 	}
 	defer func() { _ = backendConn.Close() }()
 
 	clientConn.SetReadLimit(maxWSFrameBytes)
 	backendConn.SetReadLimit(maxWSFrameBytes)
 
-	p.log.Info("WebSocket tunnel open", LogKeyComponent, ComponentGateway, LogKeyEvent, EventWSProxyStart, "backend", backendURL)
+	p.log.Info("WebSocket tunnel open", LogKeyComponent, ComponentGateway, LogKeyEvent, EventWSProxyStart, "backend", backendURL, "readOnly", readOnly)
+
+	if p.keepaliveInterval > 0 {
+		done := make(chan struct{})
+		defer close(done)
+		startKeepalive(clientConn, p.keepaliveInterval, done)
+		startKeepalive(backendConn, p.keepaliveInterval, done)
+	}
 
 	errc := make(chan error, 2)
-	go copyFrames(clientConn, backendConn, "client_to_backend", errc, onActivity, onFrame)
-	go copyFrames(backendConn, clientConn, "backend_to_client", errc, onActivity, onFrame)
+	onFrame = withTTYDControl(onFrame, userID, p.onTTYDControl)
+	go copyFrames(backendConn, clientConn, DirectionClientToBackend, errc, onActivity, onFrame, readOnly)
+	go copyFrames(clientConn, backendConn, DirectionBackendToClient, errc, onActivity, onFrame, false)
 
-	err = <-errc
+	select {
+	case err = <-errc:
+	case <-p.shutdown:
+		err = drainTunnel(clientConn, backendConn, errc)
+	}
 	p.log.Info("WebSocket tunnel closed", LogKeyComponent, ComponentGateway, LogKeyEvent, EventWSProxySessionEnd, "backend", backendURL, "reason", err)
 	return nil
 }
 
+// BackendDialError is returned by ServeWS when the backend WebSocket dial fails.
+// When the backend answered the handshake with a non-101 response, StatusCode
+// and a snippet of the response Body are kept so the reason is not lost.
+type BackendDialError struct {
+	BackendURL string
+	// StatusCode is the backend's HTTP status, or 0 if no response was received.
+	StatusCode int
+	// Body is the start of the backend's response body, trimmed of whitespace.
+	Body string
+	Err  error
+}
+
+func newBackendDialError(backendURL string, resp *http.Response, err error) *BackendDialError {
+	e := &BackendDialError{BackendURL: backendURL, Err: err}
+	if resp != nil {
+		e.StatusCode = resp.StatusCode
+		if resp.Body != nil {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, maxBackendErrorBody))
+			_ = resp.Body.Close()
+			e.Body = strings.TrimSpace(string(body))
+		}
+	}
+	return e
+}
+
+func (e *BackendDialError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("dial backend %q: %v", e.BackendURL, e.Err)
+	}
+	if e.Body == "" {
+		return fmt.Sprintf("dial backend %q: HTTP %d: %v", e.BackendURL, e.StatusCode, e.Err)
+	}
+	return fmt.Sprintf("dial backend %q: HTTP %d: %v: %s", e.BackendURL, e.StatusCode, e.Err, e.Body)
+}
+
+func (e *BackendDialError) Unwrap() error { return e.Err }
+
+// closeReason is the client-facing close frame text. It omits the backend URL
+// (internal service DNS) and fits the WebSocket reason length limit.
+func (e *BackendDialError) closeReason() string {
+	reason := "workspace backend unavailable"
+	if e.StatusCode != 0 {
+		reason = fmt.Sprintf("workspace backend returned HTTP %d", e.StatusCode)
+		if e.Body != "" {
+			reason += ": " + e.Body
+		}
+	}
+	if len(reason) > maxCloseReasonBytes {
+		reason = strings.ToValidUTF8(reason[:maxCloseReasonBytes], "")
+	}
+	return reason
+}
+
+// BackendPort returns the ttyd port the operator published in ws.Status.Port,
+// or the default port for workspaces reconciled before the field existed.
+func BackendPort(ws *workspacev1alpha1.Workspace) int32 {
+	if ws.Status.Port > 0 {
+		return ws.Status.Port
+	}
+	return worksp.DefaultTTYDPort
+}
+
 // BackendURL builds the WebSocket URL for a workspace pod's ttyd service.
-func BackendURL(serviceEndpoint string) string {
-	u := url.URL{Scheme: "ws", Host: fmt.Sprintf("%s:%d", serviceEndpoint, ttydPort)}
+func BackendURL(serviceEndpoint string, port int32) string {
+	u := url.URL{Scheme: "ws", Host: fmt.Sprintf("%s:%d", serviceEndpoint, port)}
+	return u.String()
+}
+
+// BackendWSSURL builds the TLS WebSocket URL for a workspace pod's ttyd service.
+func BackendWSSURL(serviceEndpoint string, port int32) string {
+	u := url.URL{Scheme: "wss", Host: fmt.Sprintf("%s:%d", serviceEndpoint, port)}
 	return u.String()
 }
 
 // BackendHTTPURL builds the HTTP URL for a workspace pod's ttyd service.
-func BackendHTTPURL(serviceEndpoint string) string {
-	u := url.URL{Scheme: "http", Host: fmt.Sprintf("%s:%d", serviceEndpoint, ttydPort)}
+func BackendHTTPURL(serviceEndpoint string, port int32) string {
+	u := url.URL{Scheme: "http", Host: fmt.Sprintf("%s:%d", serviceEndpoint, port)}
 	return u.String()
 }
 
 // copyFrames reads WebSocket frames from src and writes them to dst.
 // onActivity is invoked after each successfully forwarded frame; may be nil.
+// When readOnly is set, frames that readOnlyClientFrame rejects are read and
+// discarded instead of forwarded.
 // On a normal close it propagates the close handshake to dst before returning.
-func copyFrames(dst, src *websocket.Conn, direction string, errc chan<- error, onActivity func(), onFrame FrameObserver) {
+func copyFrames(dst, src *websocket.Conn, direction string, errc chan<- error, onActivity func(), onFrame FrameObserver, readOnly bool) {
 	for {
 		msgType, data, err := src.ReadMessage()
 		if err != nil {
@@ -114,6 +442,9 @@ func copyFrames(dst, src *websocket.Conn, direction string, errc chan<- error, o
 			errc <- err
 			return
 		}
+		if readOnly && !readOnlyClientFrame(msgType, data) {
+			continue
+		}
 		if err := dst.WriteMessage(msgType, data); err != nil {
 			errc <- err
 			return
@@ -127,15 +458,55 @@ func copyFrames(dst, src *websocket.Conn, direction string, errc chan<- error, o
 	}
 }
 
+// drainTunnel sends a going-away close frame to both peers and waits for the
+// close handshake to finish a copy loop, or for closeHandshakeTimeout.
+func drainTunnel(clientConn, backendConn *websocket.Conn, errc <-chan error) error {
+	msg := websocket.FormatCloseMessage(websocket.CloseGoingAway, "gateway shutting down")
+	deadline := time.Now().Add(time.Second)
+	_ = clientConn.WriteControl(websocket.CloseMessage, msg, deadline)
+	_ = backendConn.WriteControl(websocket.CloseMessage, msg, deadline)
+	select {
+	case err := <-errc:
+		return err
+	case <-time.After(closeHandshakeTimeout):
+		return ErrProxyShuttingDown
+	}
+}
+
+// startKeepalive pings conn every interval until done is closed, so load
+// balancers see traffic on an idle tunnel. Each pong extends the read deadline
+// by two intervals; a peer that stops answering fails the pending read in
+// copyFrames. It must be called before copyFrames starts reading from conn.
+func startKeepalive(conn *websocket.Conn, interval time.Duration, done <-chan struct{}) {
+	_ = conn.SetReadDeadline(time.Now().Add(2 * interval))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * interval))
+	})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(pingWriteTimeout)); err != nil {
+					return
+				}
+			}
+		}
+	}()
+}
+
 // backendReadyTimeout is the maximum time to wait for a TCP connection to the backend.
 const backendReadyTimeout = 5 * time.Second
 
-// BackendReady performs a quick TCP dial to serviceEndpoint:7681 to check
+// BackendReady performs a quick TCP dial to serviceEndpoint:port to check
 // whether the workspace pod's ttyd server is accepting connections.
 // This avoids proxying a WebSocket dial that would hang or fail when the
 // pod is running but the ttyd process hasn't started yet.
-func BackendReady(serviceEndpoint string) bool {
-	addr := net.JoinHostPort(serviceEndpoint, fmt.Sprintf("%d", ttydPort))
+func BackendReady(serviceEndpoint string, port int32) bool {
+	addr := net.JoinHostPort(serviceEndpoint, fmt.Sprintf("%d", port))
 	conn, err := net.DialTimeout("tcp", addr, backendReadyTimeout)
 	if err != nil {
 		return false
@@ -1,22 +1,32 @@
 package gateway
 
 import (
-	"fmt"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	workspacev1alpha1 "workspace-operator/api/v1alpha1"
+	worksp "workspace-operator/pkg/workspace"
 )
 
 func TestNewProxy(t *testing.T) {
 	log := zap.New(zap.UseDevMode(true))
-	p := NewProxy(log)
+	p := NewProxy(log, ProxyConfig{})
 	if p == nil {
 		t.Fatal("NewProxy returned nil")
 	}
@@ -71,7 +81,7 @@ func TestCopyFrames_ForwardsMessages(t *testing.T) {
 	// and the test goroutine (reader).
 	errc := make(chan error, 1)
 	var activityCalled atomic.Bool
-	go copyFrames(dstClientConn, src, "client_to_backend", errc, func() { activityCalled.Store(true) }, nil)
+	go copyFrames(dstClientConn, src, "client_to_backend", errc, func() { activityCalled.Store(true) }, nil, false)
 
 	// Inject a message through srcClientConn; the server-side (src) sees it and
 	// copyFrames relays it to dstClientConn, which sends it to dstSrv handler.
@@ -96,7 +106,7 @@ func TestCopyFrames_ForwardsMessages(t *testing.T) {
 // bidirectional frame relay → close.
 func TestServeWS(t *testing.T) {
 	log := zap.New(zap.UseDevMode(true))
-	proxy := NewProxy(log)
+	proxy := NewProxy(log, ProxyConfig{})
 
 	// Backend: a WebSocket echo server.
 	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -121,7 +131,7 @@ func TestServeWS(t *testing.T) {
 
 	// Frontend: an HTTP server that calls ServeWS to proxy to the backend.
 	frontend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if err := proxy.ServeWS(w, r, backendWSURL, nil, nil); err != nil {
+		if err := proxy.ServeWS(w, r, "alice", backendWSURL, nil, nil); err != nil {
 			// Errors after the tunnel is set up are normal on close.
 			t.Logf("ServeWS: %v", err)
 		}
@@ -148,6 +158,81 @@ func TestServeWS(t *testing.T) {
 	}
 }
 
+// TestServeWS_KeepaliveKeepsIdleTunnelOpen uses a backend that sends nothing
+// and only answers pings: the tunnel must outlive several read deadlines
+// (two keepalive intervals) and still relay a message afterwards.
+func TestServeWS_KeepaliveKeepsIdleTunnelOpen(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	proxy := NewProxy(zap.New(zap.UseDevMode(true)), ProxyConfig{KeepaliveInterval: interval})
+
+	var backendPings atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := websocket.Upgrader{CheckOrigin: func(_ *http.Request) bool { return true }}
+		conn, err := u.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		conn.SetPingHandler(func(data string) error {
+			backendPings.Add(1)
+			return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		})
+		for {
+			mt, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(mt, msg); err != nil {
+				return
+			}
+		}
+	}))
+	defer backend.Close()
+	backendWSURL := "ws" + strings.TrimPrefix(backend.URL, "http")
+
+	frontend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = proxy.ServeWS(w, r, "alice", backendWSURL, nil, nil)
+	}))
+	defer frontend.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(frontend.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial frontend proxy: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	var clientPings atomic.Int32
+	conn.SetPingHandler(func(data string) error {
+		clientPings.Add(1)
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	// Keep reading so pings are answered while the tunnel is idle.
+	got := make(chan string, 1)
+	go func() {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			got <- "error: " + err.Error()
+			return
+		}
+		got <- string(msg)
+	}()
+
+	time.Sleep(6 * interval)
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("still-here")); err != nil {
+		t.Fatalf("WriteMessage after idle period: %v", err)
+	}
+	select {
+	case msg := <-got:
+		if msg != "still-here" {
+			t.Fatalf("after idle period got %q, want still-here", msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for echo after idle period")
+	}
+	if clientPings.Load() == 0 || backendPings.Load() == 0 {
+		t.Errorf("pings: client %d, backend %d; want both > 0", clientPings.Load(), backendPings.Load())
+	}
+}
+
 // TestServeWS_SubprotocolForwarded verifies that when the client requests the
 // "tty" subprotocol, the gateway echoes it back to the client and forwards it
 // to the backend. A backend that rejects connections missing the subprotocol
@@ -155,7 +240,7 @@ func TestServeWS(t *testing.T) {
 // data loss.
 func TestServeWS_SubprotocolForwarded(t *testing.T) {
 	log := zap.New(zap.UseDevMode(true))
-	proxy := NewProxy(log)
+	proxy := NewProxy(log, ProxyConfig{})
 
 	// Backend: only accepts connections that negotiate "tty"; rejects others.
 	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -185,7 +270,7 @@ func TestServeWS_SubprotocolForwarded(t *testing.T) {
 
 	// Frontend: proxies to backend via ServeWS.
 	frontend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if err := proxy.ServeWS(w, r, backendWSURL, nil, nil); err != nil {
+		if err := proxy.ServeWS(w, r, "alice", backendWSURL, nil, nil); err != nil {
 			t.Logf("ServeWS: %v", err)
 		}
 	}))
@@ -217,6 +302,505 @@ func TestServeWS_SubprotocolForwarded(t *testing.T) {
 	}
 }
 
+// TestServeWS_BackendNon101SurfacesStatus verifies that a backend answering the
+// handshake with an HTTP error has its status and body reported both in the
+// returned error and in the close frame sent to the client.
+func TestServeWS_BackendNon101SurfacesStatus(t *testing.T) {
+	log := zap.New(zap.UseDevMode(true))
+	proxy := NewProxy(log, ProxyConfig{})
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "ttyd still starting", http.StatusServiceUnavailable)
+	}))
+	defer backend.Close()
+	backendWSURL := "ws" + strings.TrimPrefix(backend.URL, "http")
+
+	serveErr := make(chan error, 1)
+	frontend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveErr <- proxy.ServeWS(w, r, "alice", backendWSURL, nil, nil)
+	}))
+	defer frontend.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(frontend.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial frontend proxy: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) {
+		t.Fatalf("ReadMessage error = %v, want close frame", err)
+	}
+	if closeErr.Code != websocket.CloseTryAgainLater {
+		t.Errorf("close code = %d, want %d", closeErr.Code, websocket.CloseTryAgainLater)
+	}
+	if !strings.Contains(closeErr.Text, "503") || !strings.Contains(closeErr.Text, "ttyd still starting") {
+		t.Errorf("close reason = %q, want backend status and body", closeErr.Text)
+	}
+
+	select {
+	case err := <-serveErr:
+		var dialErr *BackendDialError
+		if !errors.As(err, &dialErr) {
+			t.Fatalf("ServeWS error = %v, want *BackendDialError", err)
+		}
+		if dialErr.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("StatusCode = %d, want 503", dialErr.StatusCode)
+		}
+		if !strings.Contains(err.Error(), "HTTP 503") || !strings.Contains(err.Error(), "ttyd still starting") {
+			t.Errorf("error = %q, want backend status and body", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeWS did not return")
+	}
+}
+
+// TestServeWS_MaxConnectionsPerUser opens the per-user limit of tunnels
+// concurrently, then checks that one more is refused with 429 before the
+// backend is dialed, that other users are unaffected, and that closing a
+// tunnel frees its slot.
+func TestServeWS_MaxConnectionsPerUser(t *testing.T) {
+	const limit = 3
+	log := zap.New(zap.UseDevMode(true))
+	proxy := NewProxy(log, ProxyConfig{MaxConnectionsPerUser: limit})
+	aliceReleased := make(chan struct{}, limit+1)
+	proxy.connReleased = func(userID string) {
+		if userID == "alice" {
+			aliceReleased <- struct{}{}
+		}
+	}
+
+	var backendDials atomic.Int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		backendDials.Add(1)
+		u := websocket.Upgrader{CheckOrigin: func(_ *http.Request) bool { return true }}
+		conn, err := u.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		for {
+			mt, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(mt, msg); err != nil {
+				return
+			}
+		}
+	}))
+	defer backend.Close()
+	backendWSURL := "ws" + strings.TrimPrefix(backend.URL, "http")
+
+	frontend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = proxy.ServeWS(w, r, r.URL.Query().Get("user"), backendWSURL, nil, nil)
+	}))
+	defer frontend.Close()
+	dial := func(user string) (*websocket.Conn, *http.Response, error) {
+		return websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(frontend.URL, "http")+"/?user="+user, nil)
+	}
+
+	conns := make([]*websocket.Conn, limit)
+	errs := make([]error, limit)
+	var wg sync.WaitGroup
+	for i := range limit {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, _, err := dial("alice")
+			if err == nil {
+				// Round-trip a message so the tunnel is known to be fully open.
+				if err = conn.WriteMessage(websocket.TextMessage, []byte("hi")); err == nil {
+					_, _, err = conn.ReadMessage()
+				}
+			}
+			conns[i], errs[i] = conn, err
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("connection %d: %v", i, err)
+		}
+	}
+	defer func() {
+		for _, c := range conns {
+			_ = c.Close()
+		}
+	}()
+
+	dialsBefore := backendDials.Load()
+	_, resp, err := dial("alice")
+	if err == nil {
+		t.Fatalf("connection %d was accepted, want 429", limit+1)
+	}
+	if resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("over-limit response = %v, want 429", resp)
+	}
+	if got := backendDials.Load(); got != dialsBefore {
+		t.Errorf("backend dialed %d more time(s) for a refused connection", got-dialsBefore)
+	}
+
+	other, _, err := dial("bob")
+	if err != nil {
+		t.Fatalf("other user was refused: %v", err)
+	}
+	_ = other.Close()
+
+	// Closing a tunnel releases its slot once ServeWS has torn it down.
+	_ = conns[0].Close()
+	select {
+	case <-aliceReleased:
+	case <-time.After(5 * time.Second):
+		t.Fatal("slot was not released after closing a tunnel")
+	}
+	conn, _, err := dial("alice")
+	if err != nil {
+		t.Fatalf("dial after a slot was released: %v", err)
+	}
+	conns[0] = conn
+}
+
+// TestProxyShutdown_DrainsTunnel opens a tunnel, shuts the proxy down and
+// checks the client gets a going-away close frame, Shutdown returns once the
+// tunnel has ended, and later upgrades are refused.
+func TestProxyShutdown_DrainsTunnel(t *testing.T) {
+	log := zap.New(zap.UseDevMode(true))
+	proxy := NewProxy(log, ProxyConfig{})
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := websocket.Upgrader{CheckOrigin: func(_ *http.Request) bool { return true }}
+		conn, err := u.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		for {
+			mt, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(mt, msg); err != nil {
+				return
+			}
+		}
+	}))
+	defer backend.Close()
+	backendWSURL := "ws" + strings.TrimPrefix(backend.URL, "http")
+
+	frontend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = proxy.ServeWS(w, r, "alice", backendWSURL, nil, nil)
+	}))
+	defer frontend.Close()
+	frontendURL := "ws" + strings.TrimPrefix(frontend.URL, "http")
+
+	conn, _, err := websocket.DefaultDialer.Dial(frontendURL, nil)
+	if err != nil {
+		t.Fatalf("dial frontend proxy: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("before-shutdown")); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- proxy.Shutdown(ctx) }()
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Fatalf("ReadMessage error = %v, want going-away close frame", err)
+	}
+	select {
+	case err := <-shutdownErr:
+		if err != nil {
+			t.Errorf("Shutdown = %v, want nil after the tunnel drained", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown did not return after the tunnel closed")
+	}
+
+	_, resp, err := websocket.DefaultDialer.Dial(frontendURL, nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("dial after shutdown = %v, %v; want 503", resp, err)
+	}
+}
+
+// TestServeWS_TTYDControlHook sends a ttyd resize frame through the tunnel and
+// checks the hook sees it while the backend receives the identical bytes.
+func TestServeWS_TTYDControlHook(t *testing.T) {
+	log := zap.New(zap.UseDevMode(true))
+	observed := make(chan TTYDControl, 1)
+	var observedUser atomic.Value
+	proxy := NewProxy(log, ProxyConfig{OnTTYDControl: func(userID string, c TTYDControl) {
+		observedUser.Store(userID)
+		observed <- c
+	}})
+
+	received := make(chan []byte, 1)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := websocket.Upgrader{CheckOrigin: func(_ *http.Request) bool { return true }}
+		conn, err := u.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		if _, msg, err := conn.ReadMessage(); err == nil {
+			received <- msg
+		}
+		_, _, _ = conn.ReadMessage()
+	}))
+	defer backend.Close()
+	backendWSURL := "ws" + strings.TrimPrefix(backend.URL, "http")
+
+	frontend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = proxy.ServeWS(w, r, "alice", backendWSURL, nil, nil)
+	}))
+	defer frontend.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(frontend.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial frontend proxy: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	resize := []byte(`1{"columns":120,"rows":40}`)
+	if err := conn.WriteMessage(websocket.BinaryMessage, resize); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	select {
+	case got := <-received:
+		if string(got) != string(resize) {
+			t.Errorf("backend received %q, want %q unchanged", got, resize)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("backend did not receive the resize frame")
+	}
+	select {
+	case c := <-observed:
+		want := TTYDControl{Type: TTYDControlResize, Columns: 120, Rows: 40}
+		if c != want {
+			t.Errorf("hook saw %+v, want %+v", c, want)
+		}
+		if observedUser.Load() != "alice" {
+			t.Errorf("hook user = %v, want alice", observedUser.Load())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("hook did not observe the resize frame")
+	}
+}
+
+// TestServeWSReadOnly_DropsInput checks that a read-only tunnel forwards the
+// ttyd handshake and backend output but drops the viewer's keystrokes and
+// resizes.
+func TestServeWSReadOnly_DropsInput(t *testing.T) {
+	log := zap.New(zap.UseDevMode(true))
+	proxy := NewProxy(log, ProxyConfig{})
+
+	received := make(chan string, 4)
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := websocket.Upgrader{CheckOrigin: func(_ *http.Request) bool { return true }}
+		conn, err := u.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- string(msg)
+			if err := conn.WriteMessage(websocket.BinaryMessage, []byte("0$ ")); err != nil {
+				return
+			}
+		}
+	}))
+	defer backend.Close()
+	backendWSURL := "ws" + strings.TrimPrefix(backend.URL, "http")
+
+	frontend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = proxy.ServeWSReadOnly(w, r, "bob", backendWSURL, nil)
+	}))
+	defer frontend.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(frontend.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial frontend proxy: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	frames := []string{
+		`{"AuthToken":"","columns":80,"rows":24}`,
+		"0rm -rf ~\r",
+		`1{"columns":20,"rows":5}`,
+		"not a ttyd frame",
+		"3",
+	}
+	for _, f := range frames {
+		if err := conn.WriteMessage(websocket.BinaryMessage, []byte(f)); err != nil {
+			t.Fatalf("WriteMessage %q: %v", f, err)
+		}
+	}
+
+	for _, want := range []string{frames[0], "3"} {
+		select {
+		case got := <-received:
+			if got != want {
+				t.Errorf("backend received %q, want %q", got, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("backend did not receive %q", want)
+		}
+	}
+	select {
+	case got := <-received:
+		t.Errorf("backend received unexpected frame %q", got)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, out, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read backend output: %v", err)
+	}
+	if string(out) != "0$ " {
+		t.Errorf("viewer received %q, want backend output forwarded", out)
+	}
+}
+
+// TestServeWS_BackendTLS proxies to a wss:// echo backend whose certificate is
+// only trusted through a custom cert pool, and checks that the default roots
+// reject it.
+func TestServeWS_BackendTLS(t *testing.T) {
+	log := zap.New(zap.UseDevMode(true))
+	backend := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u := websocket.Upgrader{CheckOrigin: func(_ *http.Request) bool { return true }}
+		conn, err := u.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		mt, msg, err := conn.ReadMessage()
+		if err == nil {
+			_ = conn.WriteMessage(mt, msg)
+		}
+	}))
+	defer backend.Close()
+	backendWSSURL := "wss://" + strings.TrimPrefix(backend.URL, "https://")
+
+	pool := x509.NewCertPool()
+	pool.AddCert(backend.Certificate())
+
+	for _, tc := range []struct {
+		name    string
+		tls     *tls.Config
+		wantErr bool
+	}{
+		{name: "custom CA", tls: &tls.Config{RootCAs: pool}},
+		{name: "system roots", tls: &tls.Config{}, wantErr: true},
+	} {
+		proxy := NewProxy(log, ProxyConfig{BackendTLS: tc.tls})
+		serveErr := make(chan error, 1)
+		frontend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			serveErr <- proxy.ServeWS(w, r, "alice", backendWSSURL, nil, nil)
+		}))
+
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(frontend.URL, "http"), nil)
+		if err != nil {
+			frontend.Close()
+			t.Fatalf("%s: dial frontend proxy: %v", tc.name, err)
+		}
+		_ = conn.WriteMessage(websocket.TextMessage, []byte("tls-hello"))
+		_, got, readErr := conn.ReadMessage()
+		_ = conn.Close()
+		if tc.wantErr {
+			var dialErr *BackendDialError
+			if err := <-serveErr; !errors.As(err, &dialErr) {
+				t.Errorf("%s: ServeWS err = %v, want *BackendDialError for an untrusted certificate", tc.name, err)
+			}
+		} else if readErr != nil || string(got) != "tls-hello" {
+			t.Errorf("%s: echoed = %q, %v; want tls-hello", tc.name, got, readErr)
+		}
+		frontend.Close()
+	}
+}
+
+func TestProxyBackendURL_Scheme(t *testing.T) {
+	log := zap.New(zap.UseDevMode(true))
+	if got := NewProxy(log, ProxyConfig{}).BackendURL("svc", 7681); got != "ws://svc:7681" {
+		t.Errorf("plain BackendURL = %q, want ws://svc:7681", got)
+	}
+	if got := NewProxy(log, ProxyConfig{BackendTLS: &tls.Config{}}).BackendURL("svc", 7681); got != "wss://svc:7681" {
+		t.Errorf("TLS BackendURL = %q, want wss://svc:7681", got)
+	}
+	if got := BackendWSSURL("10.0.0.5", 7681); got != "wss://10.0.0.5:7681" {
+		t.Errorf("BackendWSSURL = %q", got)
+	}
+	if got := NewProxy(log, ProxyConfig{}).BackendHTTPURL("svc", 7681); got != "http://svc:7681" {
+		t.Errorf("plain BackendHTTPURL = %q, want http://svc:7681", got)
+	}
+	if got := NewProxy(log, ProxyConfig{BackendTLS: &tls.Config{}}).BackendHTTPURL("svc", 7681); got != "https://svc:7681" {
+		t.Errorf("TLS BackendHTTPURL = %q, want https://svc:7681", got)
+	}
+}
+
+func TestProxyBackendURL_Path(t *testing.T) {
+	log := zap.New(zap.UseDevMode(true))
+	tests := []struct {
+		path string
+		tls  bool
+		want string
+	}{
+		{path: "/terminal/ws", want: "ws://svc:7681/terminal/ws"},
+		{path: "terminal/ws", want: "ws://svc:7681/terminal/ws"},
+		{path: "/ws", tls: true, want: "wss://svc:7681/ws"},
+		{path: "  ", want: "ws://svc:7681"},
+	}
+	for _, tt := range tests {
+		cfg := ProxyConfig{BackendPath: tt.path}
+		if tt.tls {
+			cfg.BackendTLS = &tls.Config{}
+		}
+		if got := NewProxy(log, cfg).BackendURL("svc", 7681); got != tt.want {
+			t.Errorf("BackendURL with path %q = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestBackendTLSConfig_CAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := BackendTLSConfig(caFile)
+	if err != nil {
+		t.Fatalf("BackendTLSConfig: %v", err)
+	}
+	if cfg.RootCAs == nil {
+		t.Fatal("RootCAs = nil, want the CA file's pool")
+	}
+	if _, err := srv.Certificate().Verify(x509.VerifyOptions{Roots: cfg.RootCAs}); err != nil {
+		t.Errorf("certificate does not verify against loaded pool: %v", err)
+	}
+
+	if err := os.WriteFile(caFile, []byte("not pem"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := BackendTLSConfig(caFile); err == nil {
+		t.Error("expected error for a CA file without certificates")
+	}
+}
+
 func TestBackendURL(t *testing.T) {
 	tests := []struct {
 		endpoint string
@@ -226,7 +810,7 @@ func TestBackendURL(t *testing.T) {
 		{"10.0.0.5", "ws://10.0.0.5:7681"},
 	}
 	for _, tt := range tests {
-		got := BackendURL(tt.endpoint)
+		got := BackendURL(tt.endpoint, 7681)
 		if got != tt.want {
 			t.Errorf("BackendURL(%q) = %q, want %q", tt.endpoint, got, tt.want)
 		}
@@ -242,13 +826,27 @@ func TestBackendHTTPURL(t *testing.T) {
 		{"10.0.0.5", "http://10.0.0.5:7681"},
 	}
 	for _, tt := range tests {
-		got := BackendHTTPURL(tt.endpoint)
+		got := BackendHTTPURL(tt.endpoint, 7681)
 		if got != tt.want {
 			t.Errorf("BackendHTTPURL(%q) = %q, want %q", tt.endpoint, got, tt.want)
 		}
 	}
 }
 
+func TestBackendPort(t *testing.T) {
+	ws := &workspacev1alpha1.Workspace{}
+	if got := BackendPort(ws); got != worksp.DefaultTTYDPort {
+		t.Errorf("BackendPort without status.port = %d, want %d", got, worksp.DefaultTTYDPort)
+	}
+	ws.Status.Port = 8080
+	if got := BackendPort(ws); got != 8080 {
+		t.Errorf("BackendPort = %d, want 8080", got)
+	}
+	if got := BackendHTTPURL("svc", BackendPort(ws)); got != "http://svc:8080" {
+		t.Errorf("BackendHTTPURL = %q, want http://svc:8080", got)
+	}
+}
+
 func TestCopyFrames(t *testing.T) {
 	// Create a WebSocket echo server
 	echoServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -295,13 +893,13 @@ func TestCopyFrames(t *testing.T) {
 }
 
 func TestBackendReady(t *testing.T) {
-	// BackendReady always dials port 7681, so we must listen on that port.
 	// Use 127.0.0.1 to avoid binding to a wildcard address.
-	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", ttydPort))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
 	if err != nil {
-		t.Skipf("cannot bind to port %d (likely in use): %v", ttydPort, err)
+		t.Fatalf("listen: %v", err)
 	}
 	defer func() { _ = ln.Close() }()
+	port := int32(ln.Addr().(*net.TCPAddr).Port)
 
 	// Accept and close connections in the background so BackendReady's
 	// dial succeeds (net.DialTimeout completes once TCP handshake finishes).
@@ -316,12 +914,12 @@ func TestBackendReady(t *testing.T) {
 	}()
 
 	endpoint := "127.0.0.1"
-	if !BackendReady(endpoint) {
-		t.Errorf("BackendReady(%q) = false, want true (listener is accepting)", endpoint)
+	if !BackendReady(endpoint, port) {
+		t.Errorf("BackendReady(%q, %d) = false, want true (listener is accepting)", endpoint, port)
 	}
 
 	// Unreachable endpoint should return false.
-	if BackendReady("192.0.2.1") {
+	if BackendReady("192.0.2.1", port) {
 		t.Error("BackendReady(unreachable) = true, want false")
 	}
 }
@@ -0,0 +1,63 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	workspacev1alpha1 "workspace-operator/api/v1alpha1"
+)
+
+// ErrViewForbidden is returned by SharingManager.Viewable when the workspace
+// does not exist or is not shared with the caller. Both cases look the same
+// so viewers cannot probe which users have workspaces.
+var ErrViewForbidden = errors.New("workspace is not shared with this user")
+
+// CanView reports whether viewer may watch ws read-only: the owner always
+// can, others must be listed in spec.sharing.users or belong to one of
+// spec.sharing.groups.
+func CanView(ws *workspacev1alpha1.Workspace, viewer *Claims) bool {
+	if ws == nil || viewer == nil || viewer.UserID == "" {
+		return false
+	}
+	if ws.Spec.User.ID == viewer.UserID || slices.Contains(ws.Spec.Sharing.Users, viewer.UserID) {
+		return true
+	}
+	for _, g := range viewer.Groups {
+		if slices.Contains(ws.Spec.Sharing.Groups, g) {
+			return true
+		}
+	}
+	return false
+}
+
+// SharingManager looks up workspaces other users have shared read-only.
+type SharingManager struct {
+	client client.Client
+}
+
+// NewSharingManager returns a SharingManager using the provided K8s client.
+func NewSharingManager(c client.Client) *SharingManager {
+	return &SharingManager{client: c}
+}
+
+// Viewable returns ownerID's workspace in namespace when viewer may watch it,
+// and ErrViewForbidden otherwise. It never creates or starts the workspace.
+func (m *SharingManager) Viewable(ctx context.Context, namespace, ownerID string, viewer *Claims) (*workspacev1alpha1.Workspace, error) {
+	var ws workspacev1alpha1.Workspace
+	if err := m.client.Get(ctx, types.NamespacedName{Name: ownerID, Namespace: namespace}, &ws); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, ErrViewForbidden
+		}
+		return nil, fmt.Errorf("get workspace %q: %w", ownerID, err)
+	}
+	if !CanView(&ws, viewer) {
+		return nil, ErrViewForbidden
+	}
+	return &ws, nil
+}
@@ -0,0 +1,63 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	workspacev1alpha1 "workspace-operator/api/v1alpha1"
+)
+
+func sharedWorkspace() *workspacev1alpha1.Workspace {
+	return &workspacev1alpha1.Workspace{
+		ObjectMeta: metav1.ObjectMeta{Name: "alice", Namespace: "workspaces"},
+		Spec: workspacev1alpha1.WorkspaceSpec{
+			User:    workspacev1alpha1.UserInfo{ID: "alice", Email: "alice@example.com"},
+			Sharing: workspacev1alpha1.SharingSpec{Users: []string{"bob"}, Groups: []string{"pairing"}},
+		},
+	}
+}
+
+func TestCanView(t *testing.T) {
+	tests := []struct {
+		name   string
+		viewer *Claims
+		want   bool
+	}{
+		{name: "owner", viewer: &Claims{UserID: "alice"}, want: true},
+		{name: "listed user", viewer: &Claims{UserID: "bob"}, want: true},
+		{name: "group member", viewer: &Claims{UserID: "carol", Groups: []string{"devs", "pairing"}}, want: true},
+		{name: "stranger", viewer: &Claims{UserID: "mallory", Groups: []string{"devs"}}, want: false},
+		{name: "empty user", viewer: &Claims{Groups: []string{"devs"}}, want: false},
+		{name: "nil claims", viewer: nil, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CanView(sharedWorkspace(), tt.viewer); got != tt.want {
+				t.Errorf("CanView = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSharingManager_Viewable(t *testing.T) {
+	fc := fake.NewClientBuilder().WithScheme(testScheme).WithObjects(sharedWorkspace()).Build()
+	m := NewSharingManager(fc)
+
+	ws, err := m.Viewable(context.Background(), "workspaces", "alice", &Claims{UserID: "bob"})
+	if err != nil {
+		t.Fatalf("Viewable: %v", err)
+	}
+	if ws.Name != "alice" {
+		t.Errorf("workspace = %q, want alice", ws.Name)
+	}
+	if _, err := m.Viewable(context.Background(), "workspaces", "alice", &Claims{UserID: "mallory"}); !errors.Is(err, ErrViewForbidden) {
+		t.Errorf("unshared err = %v, want ErrViewForbidden", err)
+	}
+	if _, err := m.Viewable(context.Background(), "workspaces", "nobody", &Claims{UserID: "bob"}); !errors.Is(err, ErrViewForbidden) {
+		t.Errorf("missing workspace err = %v, want ErrViewForbidden", err)
+	}
+}
@@ -0,0 +1,112 @@
+package gateway
+
+import (
+	"encoding/json"
+
+	"github.com/gorilla/websocket"
+)
+
+// Frame directions passed to FrameObserver.
+const (
+	DirectionClientToBackend = "client_to_backend"
+	DirectionBackendToClient = "backend_to_client"
+)
+
+// ttyd client command prefixes (first byte of a client-to-server frame).
+const (
+	ttydInput  = '0'
+	ttydResize = '1'
+	ttydPause  = '2'
+	ttydResume = '3'
+)
+
+// TTYD control message types reported in TTYDControl.Type.
+const (
+	TTYDControlInit   = "init"
+	TTYDControlInput  = "input"
+	TTYDControlResize = "resize"
+	TTYDControlPause  = "pause"
+	TTYDControlResume = "resume"
+)
+
+// TTYDControl is a parsed ttyd client frame. Keystrokes are not kept: input
+// frames only report their size.
+type TTYDControl struct {
+	Type string
+	// Columns and Rows are set for init and resize frames.
+	Columns int
+	Rows    int
+	// InputBytes is the number of keystroke bytes in an input frame.
+	InputBytes int
+}
+
+// TTYDControlHook observes the ttyd control frames a user sends through a
+// tunnel. It runs on the relay goroutine after the frame was forwarded, so it
+// must not block.
+type TTYDControlHook func(userID string, c TTYDControl)
+
+// ParseTTYDClientFrame parses a client-to-backend ttyd frame. It reports false
+// for frames that are not ttyd commands. payload is not retained or modified.
+func ParseTTYDClientFrame(msgType int, payload []byte) (TTYDControl, bool) {
+	if len(payload) == 0 || (msgType != websocket.BinaryMessage && msgType != websocket.TextMessage) {
+		return TTYDControl{}, false
+	}
+	var size struct {
+		Columns int `json:"columns"`
+		Rows    int `json:"rows"`
+	}
+	switch payload[0] {
+	case '{':
+		// The handshake frame carries the auth token and initial size.
+		if err := json.Unmarshal(payload, &size); err != nil {
+			return TTYDControl{}, false
+		}
+		return TTYDControl{Type: TTYDControlInit, Columns: size.Columns, Rows: size.Rows}, true
+	case ttydInput:
+		return TTYDControl{Type: TTYDControlInput, InputBytes: len(payload) - 1}, true
+	case ttydResize:
+		if err := json.Unmarshal(payload[1:], &size); err != nil {
+			return TTYDControl{}, false
+		}
+		return TTYDControl{Type: TTYDControlResize, Columns: size.Columns, Rows: size.Rows}, true
+	case ttydPause:
+		return TTYDControl{Type: TTYDControlPause}, true
+	case ttydResume:
+		return TTYDControl{Type: TTYDControlResume}, true
+	}
+	return TTYDControl{}, false
+}
+
+// readOnlyClientFrame reports whether a client frame may reach ttyd on a
+// read-only tunnel. The handshake and pause/resume flow control pass so the
+// viewer receives output; input, resize and unrecognised frames do not.
+func readOnlyClientFrame(msgType int, payload []byte) bool {
+	c, ok := ParseTTYDClientFrame(msgType, payload)
+	if !ok {
+		return false
+	}
+	switch c.Type {
+	case TTYDControlInit, TTYDControlPause, TTYDControlResume:
+		return true
+	}
+	return false
+}
+
+// withTTYDControl chains onFrame with hook, which sees the parsed
+// client-to-backend ttyd frames of userID. A nil hook returns onFrame as is.
+func withTTYDControl(onFrame FrameObserver, userID string, hook TTYDControlHook) FrameObserver {
+	if hook == nil {
+		return onFrame
+	}
+	return func(direction string, msgType int, payload []byte) {
+		if onFrame != nil {
+			onFrame(direction, msgType, payload)
+		}
+		if direction != DirectionClientToBackend {
+			return
+		}
+		if c, ok := ParseTTYDClientFrame(msgType, payload); ok {
+			hook(userID, c)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package gateway
+
+import (
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestParseTTYDClientFrame(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		msgType int
+		payload string
+		want    TTYDControl
+		wantOK  bool
+	}{
+		{name: "init", msgType: websocket.TextMessage, payload: `{"AuthToken":"","columns":80,"rows":24}`,
+			want: TTYDControl{Type: TTYDControlInit, Columns: 80, Rows: 24}, wantOK: true},
+		{name: "input", msgType: websocket.BinaryMessage, payload: "0ls\r",
+			want: TTYDControl{Type: TTYDControlInput, InputBytes: 3}, wantOK: true},
+		{name: "resize", msgType: websocket.BinaryMessage, payload: `1{"columns":100,"rows":30}`,
+			want: TTYDControl{Type: TTYDControlResize, Columns: 100, Rows: 30}, wantOK: true},
+		{name: "pause", msgType: websocket.BinaryMessage, payload: "2", want: TTYDControl{Type: TTYDControlPause}, wantOK: true},
+		{name: "resume", msgType: websocket.BinaryMessage, payload: "3", want: TTYDControl{Type: TTYDControlResume}, wantOK: true},
+		{name: "malformed resize", msgType: websocket.BinaryMessage, payload: "1{not json"},
+		{name: "unknown command", msgType: websocket.BinaryMessage, payload: "9x"},
+		{name: "empty", msgType: websocket.BinaryMessage, payload: ""},
+		{name: "ping", msgType: websocket.PingMessage, payload: "0"},
+	} {
+		got, ok := ParseTTYDClientFrame(tc.msgType, []byte(tc.payload))
+		if ok != tc.wantOK || got != tc.want {
+			t.Errorf("%s: ParseTTYDClientFrame = %+v, %v; want %+v, %v", tc.name, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
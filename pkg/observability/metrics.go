@@ -3,7 +3,11 @@
 package observability
 
 import (
+	"context"
+	"errors"
+
 	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	crmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 )
 
@@ -51,10 +55,67 @@ var (
 			Help:      "Failed patches to Workspace status subresource.",
 		},
 	)
+
+	// WorkspaceIdleStops counts workspaces stopped by the idle-timeout check.
+	WorkspaceIdleStops = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "devplane",
+			Subsystem: "workspace",
+			Name:      "idle_stops_total",
+			Help:      "Workspace pods deleted because the workspace exceeded its idle timeout.",
+		},
+		[]string{"namespace"},
+	)
+
+	// WorkspaceReconcileDuration observes the wall time of each Workspace reconcile.
+	WorkspaceReconcileDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Namespace: "devplane",
+			Subsystem: "workspace",
+			Name:      "reconcile_duration_seconds",
+			Help:      "Duration of Workspace reconciles, including those that return an error.",
+			Buckets:   prometheus.DefBuckets,
+		},
+	)
+
+	// WorkspaceReconcileErrors counts reconciles that returned an error, by
+	// ReconcileErrorType.
+	WorkspaceReconcileErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "devplane",
+			Subsystem: "workspace",
+			Name:      "reconcile_errors_total",
+			Help:      "Workspace reconciles that returned an error.",
+		},
+		[]string{"type"},
+	)
 )
 
 func init() {
-	crmetrics.Registry.MustRegister(WorkspacePhaseTransitions, WorkspaceStatusPatchFailures)
+	crmetrics.Registry.MustRegister(
+		WorkspacePhaseTransitions, WorkspaceStatusPatchFailures, WorkspaceIdleStops,
+		WorkspaceReconcileDuration, WorkspaceReconcileErrors,
+	)
+}
+
+// ReconcileErrorType buckets a reconcile error into a small, fixed set of
+// label values: conflict, not_found, forbidden, invalid, timeout or other.
+func ReconcileErrorType(err error) string {
+	switch {
+	case apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err):
+		return "conflict"
+	case apierrors.IsNotFound(err):
+		return "not_found"
+	case apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err):
+		return "forbidden"
+	case apierrors.IsInvalid(err) || apierrors.IsBadRequest(err):
+		return "invalid"
+	case apierrors.IsTimeout(err) || apierrors.IsServerTimeout(err) || apierrors.IsTooManyRequests(err) ||
+		errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	default:
+		return "other"
+	}
 }
 
 // PhaseLabel normalizes an empty phase for Prometheus label values.
@@ -0,0 +1,51 @@
+package observability
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	workspacev1alpha1 "workspace-operator/api/v1alpha1"
+)
+
+func TestWorkspacePhaseCollector(t *testing.T) {
+	s := runtime.NewScheme()
+	utilruntime.Must(workspacev1alpha1.AddToScheme(s))
+	ws := func(name string, phase workspacev1alpha1.WorkspacePhase) client.Object {
+		return &workspacev1alpha1.Workspace{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Status:     workspacev1alpha1.WorkspaceStatus{Phase: phase},
+		}
+	}
+	deleting := ws("deleting", workspacev1alpha1.WorkspacePhaseRunning)
+	deleting.SetFinalizers([]string{"workspace.devplane.io/finalizer"})
+	now := metav1.Now()
+	deleting.SetDeletionTimestamp(&now)
+	fc := fake.NewClientBuilder().WithScheme(s).WithObjects(
+		ws("a", workspacev1alpha1.WorkspacePhaseRunning),
+		ws("b", workspacev1alpha1.WorkspacePhaseRunning),
+		ws("c", workspacev1alpha1.WorkspacePhaseStopped),
+		ws("d", ""),
+		deleting,
+	).Build()
+
+	want := `
+# HELP devplane_workspace_workspaces Workspaces by status.phase.
+# TYPE devplane_workspace_workspaces gauge
+devplane_workspace_workspaces{phase="Creating"} 0
+devplane_workspace_workspaces{phase="Failed"} 0
+devplane_workspace_workspaces{phase="None"} 1
+devplane_workspace_workspaces{phase="Pending"} 0
+devplane_workspace_workspaces{phase="Running"} 2
+devplane_workspace_workspaces{phase="Stopped"} 1
+`
+	if err := testutil.CollectAndCompare(NewWorkspacePhaseCollector(fc), strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
@@ -2,6 +2,8 @@ package security
 
 import (
 	"fmt"
+	"net/netip"
+	"slices"
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
@@ -13,6 +15,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	workspacev1alpha1 "workspace-operator/api/v1alpha1"
+	worksp "workspace-operator/pkg/workspace"
 )
 
 // NetworkPolicy naming and label conventions.
@@ -40,6 +43,11 @@ const (
 //   - 11434 — Ollama default port
 var DefaultEgressPorts = []int32{22, 80, 443, 5000, 8000, 8080, 8081, 11434}
 
+// DefaultAPIServerPorts are the TCP ports opened by the API server egress rule:
+// 443 (the kubernetes Service port) and 6443 (the usual kube-apiserver port,
+// which is what NetworkPolicy sees once the Service address is translated).
+var DefaultAPIServerPorts = []int32{443, 6443}
+
 // DefaultLLMNamespace is the reconciler fallback when neither the Workspace
 // spec nor operator-level LLM_NAMESPACES configures LLM namespaces. It keeps
 // single-namespace installs predictable; override via Helm workspace.ai.egressNamespaces.
@@ -157,6 +165,7 @@ func BuildDenyAllNetworkPolicy(workspace *workspacev1alpha1.Workspace, scheme *r
 // to reach:
 //   - DNS (UDP+TCP 53) in kube-system
 //   - All pods in LLM service namespaces (e.g., "ai-system")
+//   - All pods in each spec.dataEgress namespace, on that rule's TCP ports only
 //   - External IPs (0.0.0.0/0) on the provided TCP egressPorts
 //
 // egressPorts must not be empty; callers should fall back to DefaultEgressPorts
@@ -186,6 +195,29 @@ func BuildEgressNetworkPolicy(workspace *workspacev1alpha1.Workspace, llmNamespa
 		egressRules = append(egressRules, networkingv1.NetworkPolicyEgressRule{To: peers})
 	}
 
+	// Shared data services — namespace-scoped and port-restricted. A rule left
+	// with no valid ports is dropped rather than emitted as "all ports".
+	for _, de := range workspace.Spec.DataEgress {
+		var ports []networkingv1.NetworkPolicyPort
+		for _, p := range de.Ports {
+			if p < 1 || p > 65535 {
+				log.Info("Skipping invalid data egress port", "namespace", de.Namespace, "port", p)
+				continue
+			}
+			ports = append(ports, networkingv1.NetworkPolicyPort{
+				Protocol: protoPtr(corev1.ProtocolTCP),
+				Port:     port(int(p)),
+			})
+		}
+		if de.Namespace == "" || len(ports) == 0 {
+			continue
+		}
+		egressRules = append(egressRules, networkingv1.NetworkPolicyEgressRule{
+			Ports: ports,
+			To:    []networkingv1.NetworkPolicyPeer{namespaceSelectorByName(de.Namespace)},
+		})
+	}
+
 	// External IPs — TCP on configurable port list (SSH, HTTP, HTTPS, registries, LLMs, etc.).
 	var internetPorts []networkingv1.NetworkPolicyPort
 	for _, p := range egressPorts {
@@ -238,6 +270,50 @@ func BuildEgressNetworkPolicy(workspace *workspacev1alpha1.Workspace, llmNamespa
 	return np, nil
 }
 
+// BuildAPIServerEgressRule returns an egress rule allowing TCP to the API
+// server endpoints on ports. Endpoints are IPs or CIDRs; NetworkPolicy matches
+// the real endpoint addresses, not the kubernetes Service ClusterIP. Returns nil
+// when no valid endpoint or port remains.
+func BuildAPIServerEgressRule(endpoints []string, ports []int32) *networkingv1.NetworkPolicyEgressRule {
+	log := log.Log.WithName("security.netpol")
+	var cidrs []string
+	for _, e := range endpoints {
+		e = strings.TrimSpace(e)
+		var prefix netip.Prefix
+		if addr, err := netip.ParseAddr(e); err == nil {
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		} else if p, err := netip.ParsePrefix(e); err == nil {
+			prefix = p.Masked()
+		} else {
+			log.Info("Skipping invalid API server endpoint", "endpoint", e)
+			continue
+		}
+		if cidr := prefix.String(); !slices.Contains(cidrs, cidr) {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	var npPorts []networkingv1.NetworkPolicyPort
+	var seen []int32
+	for _, p := range ports {
+		if p < 1 || p > 65535 || slices.Contains(seen, p) {
+			continue
+		}
+		seen = append(seen, p)
+		npPorts = append(npPorts, networkingv1.NetworkPolicyPort{
+			Protocol: protoPtr(corev1.ProtocolTCP),
+			Port:     port(int(p)),
+		})
+	}
+	if len(cidrs) == 0 || len(npPorts) == 0 {
+		return nil
+	}
+	peers := make([]networkingv1.NetworkPolicyPeer, len(cidrs))
+	for i, cidr := range cidrs {
+		peers[i] = networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr}}
+	}
+	return &networkingv1.NetworkPolicyEgressRule{Ports: npPorts, To: peers}
+}
+
 // BuildIngressFromGatewayNetworkPolicy returns a NetworkPolicy that allows the
 // gateway pods (selected by app=workspace-gateway) to reach the workspace pod
 // on the ttyd port.
@@ -280,7 +356,7 @@ func BuildIngressFromGatewayNetworkPolicy(workspace *workspacev1alpha1.Workspace
 			Ingress: []networkingv1.NetworkPolicyIngressRule{
 				{
 					Ports: []networkingv1.NetworkPolicyPort{
-						{Protocol: protoPtr(corev1.ProtocolTCP), Port: port(7681)},
+						{Protocol: protoPtr(corev1.ProtocolTCP), Port: port(int(worksp.TTYDPort(workspace)))},
 					},
 					From: []networkingv1.NetworkPolicyPeer{peer},
 				},
@@ -292,3 +368,12 @@ func BuildIngressFromGatewayNetworkPolicy(workspace *workspacev1alpha1.Workspace
 	}
 	return np, nil
 }
+
+// AllowIngressFromNamespace adds every pod in namespace (e.g. the ingress
+// controller's) as a peer of np's ttyd ingress rule.
+func AllowIngressFromNamespace(np *networkingv1.NetworkPolicy, namespace string) {
+	if len(np.Spec.Ingress) == 0 {
+		return
+	}
+	np.Spec.Ingress[0].From = append(np.Spec.Ingress[0].From, namespaceSelectorByName(namespace))
+}
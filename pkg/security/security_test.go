@@ -1,6 +1,7 @@
 package security
 
 import (
+	"strings"
 	"testing"
 
 	corev1 "k8s.io/api/core/v1"
@@ -248,6 +249,58 @@ func TestBuildEgressNetworkPolicy_MultipleNamespaces(t *testing.T) {
 	}
 }
 
+func TestBuildEgressNetworkPolicy_DataEgress(t *testing.T) {
+	ws := minimalWorkspace()
+	ws.Spec.DataEgress = []workspacev1alpha1.DataEgressRule{
+		{Namespace: "data", Ports: []int32{5432}},
+		{Namespace: "cache", Ports: []int32{0}}, // no valid ports: dropped, never "all ports"
+	}
+	np, err := BuildEgressNetworkPolicy(ws, []string{"ai-system"}, []int32{443}, scheme)
+	if err != nil {
+		t.Fatalf("BuildEgressNetworkPolicy: %v", err)
+	}
+	// DNS, LLM, data (postgres), internet.
+	if len(np.Spec.Egress) != 4 {
+		t.Fatalf("egress rules = %d, want 4", len(np.Spec.Egress))
+	}
+	rule := np.Spec.Egress[2]
+	if len(rule.To) != 1 {
+		t.Fatalf("data rule peers = %d, want 1", len(rule.To))
+	}
+	if ns := rule.To[0].NamespaceSelector; ns == nil || ns.MatchLabels["kubernetes.io/metadata.name"] != "data" {
+		t.Errorf("data rule namespace = %v, want data", ns)
+	}
+	if rule.To[0].IPBlock != nil {
+		t.Error("data rule must not use an IPBlock")
+	}
+	if len(rule.Ports) != 1 || rule.Ports[0].Port.IntVal != 5432 || *rule.Ports[0].Protocol != corev1.ProtocolTCP {
+		t.Errorf("data rule ports = %+v, want only TCP 5432", rule.Ports)
+	}
+}
+
+func TestBuildAPIServerEgressRule(t *testing.T) {
+	rule := BuildAPIServerEgressRule([]string{"172.18.0.2", "10.0.0.0/24", "172.18.0.2", "bogus"}, []int32{443, 6443, 443, 0})
+	if rule == nil {
+		t.Fatal("expected a rule")
+	}
+	var cidrs []string
+	for _, peer := range rule.To {
+		if peer.IPBlock == nil {
+			t.Fatalf("peer %+v, want IPBlock", peer)
+		}
+		cidrs = append(cidrs, peer.IPBlock.CIDR)
+	}
+	if strings.Join(cidrs, ",") != "172.18.0.2/32,10.0.0.0/24" {
+		t.Errorf("CIDRs = %v, want [172.18.0.2/32 10.0.0.0/24]", cidrs)
+	}
+	if len(rule.Ports) != 2 || rule.Ports[0].Port.IntVal != 443 || rule.Ports[1].Port.IntVal != 6443 {
+		t.Errorf("ports = %+v, want 443 and 6443", rule.Ports)
+	}
+	if BuildAPIServerEgressRule(nil, DefaultAPIServerPorts) != nil {
+		t.Error("expected nil rule without endpoints")
+	}
+}
+
 func TestBuildEgressNetworkPolicy_DefaultPorts(t *testing.T) {
 	ws := minimalWorkspace()
 	np, err := BuildEgressNetworkPolicy(ws, []string{"ai-system"}, DefaultEgressPorts, scheme)
@@ -421,6 +474,18 @@ func TestBuildIngressFromGatewayNetworkPolicy(t *testing.T) {
 			t.Errorf("NamespaceSelector must be nil when gatewayNamespace is empty, got %v", peer.NamespaceSelector)
 		}
 	})
+
+	t.Run("custom spec.port", func(t *testing.T) {
+		ws := minimalWorkspace()
+		ws.Spec.Port = 8080
+		np, err := BuildIngressFromGatewayNetworkPolicy(ws, "", scheme)
+		if err != nil {
+			t.Fatalf("BuildIngressFromGatewayNetworkPolicy: %v", err)
+		}
+		if ports := np.Spec.Ingress[0].Ports; len(ports) != 1 || ports[0].Port.IntVal != 8080 {
+			t.Errorf("Ingress port = %v, want 8080", ports)
+		}
+	})
 }
 
 // ── RBAC tests ────────────────────────────────────────────────────────────────
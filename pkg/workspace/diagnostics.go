@@ -3,35 +3,43 @@ package workspace
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
 
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 // Remediation snippets for status.remediationHint (no secrets, stable for operators).
 const (
-	RemediationRBAC       = "Confirm the operator ServiceAccount has RBAC to manage ServiceAccounts, Roles, and RoleBindings in this namespace (see DevPlane operator ClusterRole/RoleBinding)."
-	RemediationNetPol     = "Confirm the operator can create and update NetworkPolicies in this namespace."
-	RemediationPVCGet     = "Check API server connectivity. If errors mention timeout, investigate apiserver load and admission webhook latency."
-	RemediationPVCCreate  = "Confirm the operator can create PersistentVolumeClaims in this namespace and that spec.persistence.storageClass exists."
-	RemediationPVCLost    = "PVC entered Lost — check storage backend, reclaim policy, and underlying volume health; you may need to delete the PVC and recreate the Workspace."
-	RemediationPodGet     = "Check API server connectivity and that the operator can read Pods in this namespace."
-	RemediationPodCreate  = "Confirm the operator can create Pods. If an admission webhook is mentioned, review that webhook's logs and failurePolicy."
-	RemediationService    = "Confirm the operator can create Services and that the Workspace namespace allows ClusterIP=None headless services."
-	RemediationImagePull  = "Verify WORKSPACE_IMAGE (or the image in the pod spec) exists, is pullable from nodes, and registry credentials are configured if the registry is private."
-	RemediationCrashLoop  = "Inspect pod logs and previous container logs; fix startup command, config, or resource limits in the workspace image or Workspace spec."
-	RemediationPodFailed  = "Inspect pod status and logs; adjust resource limits or fix the workload."
-	RemediationPodUnknown = "Check node and kubelet health; Unknown often means the node is unreachable or the kubelet stopped reporting."
-	RemediationValidation = "Fix the Workspace spec fields shown in status.message and re-apply the manifest."
-	RemediationForbidden  = "Kubernetes returned Forbidden — grant the operator RBAC required for the resource in this namespace."
-	RemediationTimeout    = "Request timed out — check apiserver connectivity, etcd health, and cluster load."
-	RemediationWebhook    = "An admission webhook rejected or blocked the request — inspect validating/mutating webhook configuration and webhook pod logs."
-	RemediationAPIError   = "See status.message for the Kubernetes API error details."
+	RemediationRBAC                  = "Confirm the operator ServiceAccount has RBAC to manage ServiceAccounts, Roles, and RoleBindings in this namespace (see DevPlane operator ClusterRole/RoleBinding)."
+	RemediationNetPol                = "Confirm the operator can create and update NetworkPolicies in this namespace."
+	RemediationPVCGet                = "Check API server connectivity. If errors mention timeout, investigate apiserver load and admission webhook latency."
+	RemediationPVCCreate             = "Confirm the operator can create PersistentVolumeClaims in this namespace and that spec.persistence.storageClass exists."
+	RemediationPVCPending            = "The storage class binds immediately but no volume was provisioned — check the CSI provisioner pods and logs, storage quota, and the PVC's events (kubectl describe pvc)."
+	RemediationPVCLost               = "PVC entered Lost — check storage backend, reclaim policy, and underlying volume health; you may need to delete the PVC and recreate the Workspace."
+	RemediationPodGet                = "Check API server connectivity and that the operator can read Pods in this namespace."
+	RemediationPodCreate             = "Confirm the operator can create Pods. If an admission webhook is mentioned, review that webhook's logs and failurePolicy."
+	RemediationService               = "Confirm the operator can create Services and that the Workspace namespace allows ClusterIP=None headless services."
+	RemediationImagePull             = "Verify WORKSPACE_IMAGE (or the image in the pod spec) exists, is pullable from nodes, and registry credentials are configured if the registry is private."
+	RemediationCrashLoop             = "Inspect pod logs and previous container logs; fix startup command, config, or resource limits in the workspace image or Workspace spec."
+	RemediationPodFailed             = "Inspect pod status and logs; adjust resource limits or fix the workload."
+	RemediationOOMKilled             = "The workspace ran out of memory — raise spec.resources.memory (e.g. from 2Gi to 4Gi), re-apply the Workspace and delete the pod so it is recreated with the new limit."
+	RemediationPodUnknown            = "Check node and kubelet health; Unknown often means the node is unreachable or the kubelet stopped reporting."
+	RemediationValidation            = "Fix the Workspace spec fields shown in status.message and re-apply the manifest."
+	RemediationForbidden             = "Kubernetes returned Forbidden — grant the operator RBAC required for the resource in this namespace."
+	RemediationTimeout               = "Request timed out — check apiserver connectivity, etcd health, and cluster load."
+	RemediationWebhook               = "An admission webhook rejected or blocked the request — inspect validating/mutating webhook configuration and webhook pod logs."
+	RemediationAPIError              = "See status.message for the Kubernetes API error details."
+	RemediationDependency            = "Remove the dependency cycle from spec.dependsOn so at least one workspace in the chain can start first."
+	RemediationInsufficientResources = "No node offers the requested extended resource (e.g. GPUs) — add nodes with it and its device plugin, or remove the request from the Workspace spec."
 
 	// Condition / event reason codes for the Ready condition and Kubernetes events.
-	ReasonRunning               = "Running"
+	ReasonPodRunning            = "PodRunning"
 	ReasonProgressing           = "Progressing"
 	ReasonStopped               = "Stopped"
+	ReasonIdleStopPending       = "IdleStopPending"
+	ReasonSuspended             = "Suspended"
 	ReasonFailed                = "Failed"
 	ReasonValidationFailed      = "ValidationFailed"
 	ReasonRBACReconcileFailed   = "RBACReconcileFailed"
@@ -39,10 +47,12 @@ const (
 	ReasonPVCReadFailed         = "PVCReadFailed"
 	ReasonPVCCreateFailed       = "PVCCreateFailed"
 	ReasonPVCLost               = "PVCLost"
+	ReasonPVCPending            = "PVCPending"
 	ReasonPodReadFailed         = "PodReadFailed"
 	ReasonPodCreateFailed       = "PodCreateFailed"
 	ReasonServiceFailed         = "ServiceEnsureFailed"
 	ReasonPodFailed             = "PodFailed"
+	ReasonOOMKilled             = "OOMKilled"
 	ReasonPodUnknown            = "PodUnknown"
 	ReasonImagePullBackOff      = "ImagePullBackOff"
 	ReasonErrImagePull          = "ErrImagePull"
@@ -52,6 +62,17 @@ const (
 	ReasonTimeout               = "Timeout"
 	ReasonAdmissionWebhook      = "AdmissionWebhook"
 	ReasonAPIError              = "APIError"
+	ReasonWaitingForDependency  = "WaitingForDependency"
+	ReasonDependencyCycle       = "DependencyCycle"
+	ReasonInsufficientResources = "InsufficientResources"
+	ReasonCABundleValid         = "CABundleValid"
+	ReasonCABundleInvalid       = "CABundleInvalid"
+	ReasonNetPolApplied         = "NetworkPoliciesApplied"
+	ReasonScheduled             = "Scheduled"
+	ReasonPodPending            = "PodPending"
+	ReasonPVCCreated            = "PVCCreated"
+	ReasonPodCreated            = "PodCreated"
+	ReasonIdleStopped           = "IdleStopped"
 )
 
 // ErrorDetailsForService classifies errors when ensuring the headless Service.
@@ -156,3 +177,29 @@ func RemediationForPodWaitingReason(waitingReason string) (remediation string, r
 		return RemediationPodFailed, ReasonFailed
 	}
 }
+
+// OOMKilledMessage returns a status message naming the container of pod that
+// was killed for exceeding its memory limit, either in its current or its
+// last termination, or "" when no container was OOMKilled.
+func OOMKilledMessage(pod *corev1.Pod) string {
+	for _, cs := range pod.Status.ContainerStatuses {
+		if !terminatedOOM(cs.State) && !terminatedOOM(cs.LastTerminationState) {
+			continue
+		}
+		limit := "unknown"
+		for _, c := range pod.Spec.Containers {
+			if q, ok := c.Resources.Limits[corev1.ResourceMemory]; ok && c.Name == cs.Name {
+				limit = q.String()
+			}
+		}
+		return fmt.Sprintf("Container %q was OOMKilled: it exceeded its memory limit of %s. Increase spec.resources.memory.", cs.Name, limit)
+	}
+	if pod.Status.Reason == ReasonOOMKilled {
+		return "Pod was OOMKilled: it exceeded its memory limit. Increase spec.resources.memory."
+	}
+	return ""
+}
+
+func terminatedOOM(state corev1.ContainerState) bool {
+	return state.Terminated != nil && state.Terminated.Reason == ReasonOOMKilled
+}
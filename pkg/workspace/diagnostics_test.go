@@ -3,9 +3,12 @@ package workspace
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
 
@@ -103,3 +106,27 @@ func TestRemediationForPodWaitingReason_Empty(t *testing.T) {
 		t.Fatalf("hint = %q", hint)
 	}
 }
+
+func TestOOMKilledMessage(t *testing.T) {
+	pod := &corev1.Pod{
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "workspace",
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			},
+		}}},
+		Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name:  "workspace",
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled"}},
+		}}},
+	}
+	msg := OOMKilledMessage(pod)
+	if !strings.Contains(msg, `"workspace"`) || !strings.Contains(msg, "1Gi") {
+		t.Fatalf("message = %q", msg)
+	}
+
+	pod.Status.ContainerStatuses[0].State = corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "Error"}}
+	if msg := OOMKilledMessage(pod); msg != "" {
+		t.Fatalf("non-OOM termination: message = %q, want empty", msg)
+	}
+}
@@ -2,18 +2,35 @@
 package workspace
 
 import (
+	"cmp"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"maps"
+	"math"
+	"net/url"
+	"path"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 
+	"gopkg.in/inf.v0"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	workspacev1alpha1 "workspace-operator/api/v1alpha1"
@@ -26,12 +43,57 @@ import (
 // with the stricter rule that requires the first character to be a letter.
 var dnsLabelRegex = regexp.MustCompile(`^[a-z]([a-z0-9\-]*[a-z0-9])?$`)
 
+// networkRefRegex matches a Multus network reference: an RFC 1123 name,
+// optionally prefixed with the NetworkAttachmentDefinition's namespace.
+var networkRefRegex = regexp.MustCompile(`^([a-z0-9]([a-z0-9\-]*[a-z0-9])?/)?[a-z0-9]([a-z0-9\-]*[a-z0-9])?$`)
+
+// featureFlagKeyRegex matches a feature flag key: safe to use as a JSON key,
+// shell word or env-style name without quoting.
+var featureFlagKeyRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,62}$`)
+
+// maxFeatureFlags and maxFeatureFlagsBytes bound spec.featureFlags so the
+// serialised env var stays small next to the rest of the pod spec.
+const (
+	maxFeatureFlags      = 64
+	maxFeatureFlagsBytes = 4096
+)
+
+// scpRepoRegex matches an scp-style git remote such as git@github.com:org/repo.git.
+var scpRepoRegex = regexp.MustCompile(`^[A-Za-z0-9._-]+@[A-Za-z0-9.-]+:[^\s]+$`)
+
 const (
 	labelApp       = "workspace"
 	labelManagedBy = "devplane"
 	labelUser      = "user"
-	ttydPort       = 7681
 	workspaceMount = "/workspace"
+	// defaultScratchMount is used when spec.scratch.mountPath is empty.
+	defaultScratchMount = "/scratch"
+
+	// AISettingsKey is the ConfigMap key holding rendered spec.aiConfig.settings JSON.
+	AISettingsKey   = "ai-settings.json"
+	aiSettingsMount = "/etc/devplane/ai"
+
+	// MultusNetworksAnnotation is the pod annotation Multus reads to attach
+	// secondary network interfaces.
+	MultusNetworksAnnotation = "k8s.v1.cni.cncf.io/networks"
+
+	// CABundleHashAnnotation records a hash of the mounted CA bundle ConfigMap's
+	// data, so the controller can recreate the pod when the bundle changes.
+	CABundleHashAnnotation = "devplane.io/ca-bundle-hash"
+
+	// RestoreRequestAnnotation is set on the Workspace by each checkpoint
+	// restore and copied to the PVC built from it, so restoring the same
+	// checkpoint twice still replaces the volume.
+	RestoreRequestAnnotation = "devplane.io/restore-requested"
+
+	// NetworkPolicyReadyCondition is the pod readiness gate the controller sets
+	// once the workspace's NetworkPolicies are in place, so a pod never becomes
+	// Ready (and reachable through its Service) before they apply.
+	NetworkPolicyReadyCondition corev1.PodConditionType = "devplane.io/network-policy-ready"
+
+	// VolumeSnapshotGroup is the API group of the CSI VolumeSnapshot that
+	// spec.persistence.restoreFrom refers to.
+	VolumeSnapshotGroup = "snapshot.storage.k8s.io"
 )
 
 // PVCName returns the PVC name for a user ID.
@@ -49,13 +111,76 @@ func ServiceName(userID string) string {
 	return fmt.Sprintf("%s-workspace-svc", userID)
 }
 
+// IngressName returns the name of the optional per-workspace Ingress.
+func IngressName(userID string) string {
+	return fmt.Sprintf("%s-workspace-ingress", userID)
+}
+
+// IngressTLSSecretName returns the Secret cert-manager issues the workspace
+// Ingress certificate into.
+func IngressTLSSecretName(userID string) string {
+	return fmt.Sprintf("%s-workspace-tls", userID)
+}
+
+// DeploymentName returns the name of the Deployment used for multi-replica workspaces.
+func DeploymentName(userID string) string {
+	return fmt.Sprintf("%s-workspace", userID)
+}
+
+// UsesDeployment reports whether the workspace runs as a Deployment instead of
+// a single Pod: when it has multiple replicas or stops by scaling to zero.
+func UsesDeployment(workspace *workspacev1alpha1.Workspace) bool {
+	return workspace.Spec.Replicas != nil && *workspace.Spec.Replicas > 1 ||
+		workspace.Spec.Lifecycle.StopMode == workspacev1alpha1.StopModeScaleToZero
+}
+
 // Labels returns the common labels for all workspace resources.
 func Labels(userID string) map[string]string {
+	labels := SelectorLabels(userID)
+	labels["managed-by"] = labelManagedBy
+	return labels
+}
+
+// SelectorLabels returns the immutable core labels (app, user) that the
+// workspace Service selects pods by. Any other pod label may change without
+// breaking routing.
+func SelectorLabels(userID string) map[string]string {
 	return map[string]string{
-		"app":        labelApp,
-		labelUser:    userID,
-		"managed-by": labelManagedBy,
+		"app":     labelApp,
+		labelUser: userID,
+	}
+}
+
+// labelTemplateRef matches the {label:<key>} placeholder of a resource label
+// template.
+var labelTemplateRef = regexp.MustCompile(`\{label:([^}]+)\}`)
+
+// ResourceLabels returns a copy of base plus the operator's label templates
+// rendered for workspace, e.g. {"cost-center": "{label:team}"} for FinOps
+// attribution. Templates expand {user}, {namespace} and {label:<key>}, the
+// value of that label on the Workspace itself. Keys already in base are never
+// overridden, and values that render empty or are not valid label values are
+// skipped.
+func ResourceLabels(base map[string]string, workspace *workspacev1alpha1.Workspace, templates map[string]string) map[string]string {
+	labels := maps.Clone(base)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	r := strings.NewReplacer("{user}", workspace.Spec.User.ID, "{namespace}", workspace.Namespace)
+	for key, tmpl := range templates {
+		if _, ok := labels[key]; ok {
+			continue
+		}
+		value := labelTemplateRef.ReplaceAllStringFunc(tmpl, func(ref string) string {
+			return workspace.Labels[labelTemplateRef.FindStringSubmatch(ref)[1]]
+		})
+		value = r.Replace(value)
+		if value == "" || len(validation.IsValidLabelValue(value)) > 0 {
+			continue
+		}
+		labels[key] = value
 	}
+	return labels
 }
 
 // BuildPVC creates a PersistentVolumeClaim for the workspace with an owner reference to the Workspace.
@@ -75,7 +200,7 @@ func BuildPVC(workspace *workspacev1alpha1.Workspace, scheme *runtime.Scheme) (*
 			Labels:    Labels(userID),
 		},
 		Spec: corev1.PersistentVolumeClaimSpec{
-			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			AccessModes: []corev1.PersistentVolumeAccessMode{pvcAccessMode(workspace.Spec.Persistence)},
 			Resources: corev1.VolumeResourceRequirements{
 				Requests: corev1.ResourceList{
 					corev1.ResourceStorage: storageQty,
@@ -86,23 +211,232 @@ func BuildPVC(workspace *workspacev1alpha1.Workspace, scheme *runtime.Scheme) (*
 	if workspace.Spec.Persistence.StorageClass != "" {
 		pvc.Spec.StorageClassName = &workspace.Spec.Persistence.StorageClass
 	}
+	if snap := workspace.Spec.Persistence.RestoreFrom; snap != "" {
+		group := VolumeSnapshotGroup
+		pvc.Spec.DataSource = &corev1.TypedLocalObjectReference{
+			APIGroup: &group,
+			Kind:     "VolumeSnapshot",
+			Name:     snap,
+		}
+		if req := workspace.Annotations[RestoreRequestAnnotation]; req != "" {
+			pvc.Annotations = map[string]string{RestoreRequestAnnotation: req}
+		}
+	}
 	if err := controllerutil.SetControllerReference(workspace, pvc, scheme); err != nil {
 		return nil, fmt.Errorf("set PVC owner reference: %w", err)
 	}
 	return pvc, nil
 }
 
+// RestorePending reports whether pvc must be replaced to honor
+// spec.persistence.restoreFrom: it was not built from that snapshot, or not
+// for the latest RestoreRequestAnnotation on the Workspace.
+func RestorePending(workspace *workspacev1alpha1.Workspace, pvc *corev1.PersistentVolumeClaim) bool {
+	snap := workspace.Spec.Persistence.RestoreFrom
+	if snap == "" || workspace.Spec.Persistence.Ephemeral {
+		return false
+	}
+	ds := pvc.Spec.DataSource
+	if ds == nil || ds.Kind != "VolumeSnapshot" || ds.Name != snap {
+		return true
+	}
+	return workspace.Annotations[RestoreRequestAnnotation] != pvc.Annotations[RestoreRequestAnnotation]
+}
+
+// pvcAccessMode maps spec.persistence.accessMode to the PVC access mode.
+func pvcAccessMode(p workspacev1alpha1.PersistenceConfig) corev1.PersistentVolumeAccessMode {
+	if p.AccessMode == workspacev1alpha1.PersistenceAccessModeReadWriteMany {
+		return corev1.ReadWriteMany
+	}
+	return corev1.ReadWriteOnce
+}
+
+// AISettingsConfigMapName returns the name of the ConfigMap holding rendered AI settings.
+func AISettingsConfigMapName(userID string) string {
+	return fmt.Sprintf("%s-workspace-ai", userID)
+}
+
+// renderedAISettings is the JSON document the workspace entrypoint reads from
+// AI_SETTINGS_FILE. Unset fields are omitted so the assistant defaults apply.
+type renderedAISettings struct {
+	Temperature  *float64 `json:"temperature,omitempty"`
+	SystemPrompt string   `json:"systemPrompt,omitempty"`
+}
+
+// BuildAISettingsConfigMap renders spec.aiConfig.settings into a ConfigMap with
+// an owner reference. The ConfigMap is always built (possibly as "{}") so the
+// pod's volume mount never dangles.
+func BuildAISettingsConfigMap(workspace *workspacev1alpha1.Workspace, scheme *runtime.Scheme) (*corev1.ConfigMap, error) {
+	userID := workspace.Spec.User.ID
+	settings := workspace.Spec.AIConfig.Settings
+
+	doc := renderedAISettings{SystemPrompt: settings.SystemPrompt}
+	if raw := strings.TrimSpace(settings.Temperature); raw != "" {
+		t, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parse temperature %q: %w", raw, err)
+		}
+		doc.Temperature = &t
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("marshal AI settings: %w", err)
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      AISettingsConfigMapName(userID),
+			Namespace: workspace.Namespace,
+			Labels:    Labels(userID),
+		},
+		Data: map[string]string{AISettingsKey: string(data)},
+	}
+	if err := controllerutil.SetControllerReference(workspace, cm, scheme); err != nil {
+		return nil, fmt.Errorf("set ConfigMap owner reference: %w", err)
+	}
+	return cm, nil
+}
+
+// ResourceQuotaName returns the name of the optional per-workspace ResourceQuota.
+func ResourceQuotaName(userID string) string {
+	return fmt.Sprintf("%s-workspace-quota", userID)
+}
+
+// DefaultResourceQuotaHeadroomPercent is the operator fallback headroom added
+// on top of the workspace's own requests and limits when
+// RESOURCE_QUOTA_HEADROOM_PERCENT is unset.
+const DefaultResourceQuotaHeadroomPercent = 25
+
+// BuildResourceQuota returns a ResourceQuota capping the namespace at the
+// workspace pods' requests and limits (times spec.replicas) and storage, each
+// scaled up by headroomPercent. Only resources the pod actually sets are constrained, so a
+// BestEffort or CPU-unlimited workspace pod is still admitted. It is meant for
+// deployments where each workspace lives in its own namespace.
+func BuildResourceQuota(workspace *workspacev1alpha1.Workspace, scheme *runtime.Scheme, headroomPercent int) (*corev1.ResourceQuota, error) {
+	userID := workspace.Spec.User.ID
+	res, err := buildResources(workspace.Spec.Resources)
+	if err != nil {
+		return nil, err
+	}
+	storage, err := resource.ParseQuantity(workspace.Spec.Resources.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("parse storage quantity %q: %w", workspace.Spec.Resources.Storage, err)
+	}
+
+	// Compute scales with the replica count; the (shared) PVC does not.
+	replicas := int64(1)
+	if workspace.Spec.Replicas != nil {
+		replicas = int64(*workspace.Spec.Replicas)
+	}
+	hard := corev1.ResourceList{
+		corev1.ResourceRequestsStorage: withHeadroom(storage, 1, headroomPercent),
+	}
+	for name, qty := range res.Requests {
+		// Devices are whole units; headroom would only round into a fraction.
+		if isExtendedResourceName(name) {
+			hard[corev1.ResourceName("requests."+string(name))] = withHeadroom(qty, replicas, 0)
+			continue
+		}
+		hard[corev1.ResourceName("requests."+string(name))] = withHeadroom(qty, replicas, headroomPercent)
+	}
+	for name, qty := range res.Limits {
+		// ResourceQuota only accepts the requests. prefix for extended resources.
+		if isExtendedResourceName(name) {
+			continue
+		}
+		hard[corev1.ResourceName("limits."+string(name))] = withHeadroom(qty, replicas, headroomPercent)
+	}
+
+	quota := &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      ResourceQuotaName(userID),
+			Namespace: workspace.Namespace,
+			Labels:    Labels(userID),
+		},
+		Spec: corev1.ResourceQuotaSpec{Hard: hard},
+	}
+	if err := controllerutil.SetControllerReference(workspace, quota, scheme); err != nil {
+		return nil, fmt.Errorf("set ResourceQuota owner reference: %w", err)
+	}
+	return quota, nil
+}
+
+// withHeadroom multiplies qty by count and scales it up by percent, keeping its
+// format. The arithmetic is done in inf.Dec so large quantities cannot overflow
+// int64; the result is rounded up to a milli-unit.
+func withHeadroom(qty resource.Quantity, count int64, percent int) resource.Quantity {
+	d := new(inf.Dec).Mul(qty.AsDec(), inf.NewDec(count*int64(100+percent), 0))
+	d.QuoRound(d, inf.NewDec(100, 0), 3, inf.RoundUp)
+	return *resource.NewDecimalQuantity(*d, qty.Format)
+}
+
+// PVCCapacity returns the provisioned storage capacity of a bound PVC
+// (status.capacity.storage), or "" when the claim is not bound yet.
+func PVCCapacity(pvc *corev1.PersistentVolumeClaim) string {
+	if pvc == nil || pvc.Status.Phase != corev1.ClaimBound {
+		return ""
+	}
+	qty, ok := pvc.Status.Capacity[corev1.ResourceStorage]
+	if !ok {
+		return ""
+	}
+	return qty.String()
+}
+
 // ServiceAccountName returns the per-user ServiceAccount name for a user ID.
 func ServiceAccountName(userID string) string {
 	return fmt.Sprintf("%s-workspace", userID)
 }
 
+// SharedServiceAccountName names the ServiceAccount, Role and RoleBinding
+// shared by every workspace in a namespace when the operator runs with
+// SHARED_WORKSPACE_SERVICE_ACCOUNT.
+const SharedServiceAccountName = "devplane-workspace"
+
 // BuildOpts holds operator-level defaults injected into every workspace pod.
 type BuildOpts struct {
 	DefaultCABundle string // ConfigMap name; used when spec.tls.customCABundle is empty
 	PipIndexURL     string
 	PipTrustedHost  string
 	NpmRegistry     string
+	// RuntimeClassName is used when spec.runtimeClassName is empty.
+	RuntimeClassName string
+	// CABundleHash is the ConfigMapDataHash of the CA bundle ConfigMap, stamped
+	// on the pod as CABundleHashAnnotation. Empty omits the annotation.
+	CABundleHash string
+	// DownwardAPIPath mounts a downward-API volume at this absolute path with
+	// the files pod-name, pod-namespace and user. Empty omits the volume.
+	DownwardAPIPath string
+	// ServiceAccountName overrides the per-user ServiceAccount the pod runs
+	// as (e.g. SharedServiceAccountName). Empty uses ServiceAccountName(userID).
+	ServiceAccountName string
+	// DefaultPodAnnotations are set on every workspace pod (e.g. service mesh
+	// injection); spec.podAnnotations overrides them key by key.
+	DefaultPodAnnotations map[string]string
+}
+
+// DefaultTTYDPort is the port ttyd listens on when spec.port is unset.
+const DefaultTTYDPort int32 = 7681
+
+// TTYDPort returns the port ttyd listens on in the workspace container.
+func TTYDPort(workspace *workspacev1alpha1.Workspace) int32 {
+	if workspace.Spec.Port > 0 {
+		return workspace.Spec.Port
+	}
+	return DefaultTTYDPort
+}
+
+// PodTTYDPort returns the "ttyd" container port of a workspace pod built by
+// BuildPod, or 0 when the pod declares none.
+func PodTTYDPort(pod *corev1.Pod) int32 {
+	for _, c := range pod.Spec.Containers {
+		for _, p := range c.Ports {
+			if p.Name == "ttyd" {
+				return p.ContainerPort
+			}
+		}
+	}
+	return 0
 }
 
 // BuildPod creates a Pod for the workspace with security context, volume, env, and owner reference.
@@ -110,24 +444,35 @@ func BuildPod(workspace *workspacev1alpha1.Workspace, pvcName, workspaceImage st
 	userID := workspace.Spec.User.ID
 	name := PodName(userID)
 	labels := Labels(userID)
+	port := TTYDPort(workspace)
 
-	cpuQty, err := resource.ParseQuantity(workspace.Spec.Resources.CPU)
+	resources, err := buildResources(workspace.Spec.Resources)
 	if err != nil {
-		return nil, fmt.Errorf("parse CPU quantity %q: %w", workspace.Spec.Resources.CPU, err)
+		return nil, err
 	}
-	memQty, err := resource.ParseQuantity(workspace.Spec.Resources.Memory)
+	dataVolume, err := buildDataVolumeSource(workspace, pvcName)
 	if err != nil {
-		return nil, fmt.Errorf("parse memory quantity %q: %w", workspace.Spec.Resources.Memory, err)
+		return nil, err
 	}
+	scheduling := workspace.Spec.Scheduling.DeepCopy()
 
 	pod := &corev1.Pod{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      name,
-			Namespace: workspace.Namespace,
-			Labels:    labels,
+			Name:        name,
+			Namespace:   workspace.Namespace,
+			Labels:      labels,
+			Annotations: buildPodAnnotations(workspace, opts),
 		},
 		Spec: corev1.PodSpec{
-			ServiceAccountName: ServiceAccountName(userID),
+			ServiceAccountName: cmp.Or(opts.ServiceAccountName, ServiceAccountName(userID)),
+			RuntimeClassName:   runtimeClassName(workspace, opts.RuntimeClassName),
+			SchedulerName:      workspace.Spec.SchedulerName,
+			NodeSelector:       scheduling.NodeSelector,
+			Tolerations:        scheduling.Tolerations,
+			Affinity:           buildAffinity(scheduling.NodeAffinity),
+			ReadinessGates: []corev1.PodReadinessGate{
+				{ConditionType: NetworkPolicyReadyCondition},
+			},
 			SecurityContext: &corev1.PodSecurityContext{
 				RunAsNonRoot:        ptr(true),
 				RunAsUser:           ptr(int64(1000)),
@@ -148,28 +493,13 @@ func BuildPod(workspace *workspacev1alpha1.Workspace, pvcName, workspaceImage st
 							Drop: []corev1.Capability{"ALL"},
 						},
 					},
-					Resources: corev1.ResourceRequirements{
-						Requests: corev1.ResourceList{
-							corev1.ResourceCPU:    cpuQty,
-							corev1.ResourceMemory: memQty,
-						},
-						Limits: corev1.ResourceList{
-							corev1.ResourceCPU:    cpuQty,
-							corev1.ResourceMemory: memQty,
-						},
-					},
+					Resources: resources,
 					Ports: []corev1.ContainerPort{
-						{Name: "ttyd", ContainerPort: ttydPort, Protocol: corev1.ProtocolTCP},
-					},
-					ReadinessProbe: &corev1.Probe{
-						ProbeHandler: corev1.ProbeHandler{
-							TCPSocket: &corev1.TCPSocketAction{
-								Port: intstr.FromInt(ttydPort),
-							},
-						},
-						InitialDelaySeconds: 5,
-						PeriodSeconds:       5,
+						{Name: "ttyd", ContainerPort: port, Protocol: corev1.ProtocolTCP},
 					},
+					ReadinessProbe: buildReadinessProbe(workspace.Spec.Readiness, port),
+					StartupProbe:   buildStartupProbe(workspace.Spec.StartupProbe, port),
+					Lifecycle:      buildContainerLifecycle(workspace.Spec.Lifecycle),
 					VolumeMounts: []corev1.VolumeMount{
 						{
 							Name:      "workspace-data",
@@ -179,33 +509,38 @@ func BuildPod(workspace *workspacev1alpha1.Workspace, pvcName, workspaceImage st
 							Name:      "tmp",
 							MountPath: "/tmp",
 						},
+						{
+							Name:      "ai-settings",
+							MountPath: aiSettingsMount,
+							ReadOnly:  true,
+						},
 					},
 					Env: buildEnvVars(workspace),
 				},
 			},
 			Volumes: []corev1.Volume{
 				{
-					Name: "workspace-data",
-					VolumeSource: corev1.VolumeSource{
-						PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
-							ClaimName: pvcName,
-						},
-					},
+					Name:         "workspace-data",
+					VolumeSource: dataVolume,
 				},
 				{
 					Name:         "tmp",
 					VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
 				},
+				{
+					Name: "ai-settings",
+					VolumeSource: corev1.VolumeSource{
+						ConfigMap: &corev1.ConfigMapVolumeSource{
+							LocalObjectReference: corev1.LocalObjectReference{
+								Name: AISettingsConfigMapName(userID),
+							},
+						},
+					},
+				},
 			},
 		},
 	}
-	caConfigMap := ""
-	if workspace.Spec.TLS.CustomCABundle != nil && workspace.Spec.TLS.CustomCABundle.Name != "" {
-		caConfigMap = workspace.Spec.TLS.CustomCABundle.Name
-	} else if opts.DefaultCABundle != "" {
-		caConfigMap = opts.DefaultCABundle
-	}
-	if caConfigMap != "" {
+	if caConfigMap := CABundleConfigMapName(workspace, opts.DefaultCABundle); caConfigMap != "" {
 		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
 			Name: "custom-ca-certs",
 			VolumeSource: corev1.VolumeSource{
@@ -225,6 +560,39 @@ func BuildPod(workspace *workspacev1alpha1.Workspace, pvcName, workspaceImage st
 			corev1.EnvVar{Name: "CUSTOM_CA_MOUNTED", Value: "true"},
 		)
 	}
+	if opts.DownwardAPIPath != "" {
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name: "pod-info",
+			VolumeSource: corev1.VolumeSource{
+				DownwardAPI: &corev1.DownwardAPIVolumeSource{
+					Items: []corev1.DownwardAPIVolumeFile{
+						{Path: "pod-name", FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}},
+						{Path: "pod-namespace", FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.namespace"}},
+						{Path: "user", FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.labels['user']"}},
+					},
+				},
+			},
+		})
+		pod.Spec.Containers[0].VolumeMounts = append(pod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      "pod-info",
+			MountPath: opts.DownwardAPIPath,
+			ReadOnly:  true,
+		})
+	}
+	if limit := workspace.Spec.Scratch.SizeLimit; limit != "" {
+		sizeLimit, err := resource.ParseQuantity(limit)
+		if err != nil {
+			return nil, fmt.Errorf("parse scratch size limit %q: %w", limit, err)
+		}
+		pod.Spec.Volumes = append(pod.Spec.Volumes, corev1.Volume{
+			Name:         "scratch",
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{SizeLimit: &sizeLimit}},
+		})
+		pod.Spec.Containers[0].VolumeMounts = append(pod.Spec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      "scratch",
+			MountPath: cmp.Or(workspace.Spec.Scratch.MountPath, defaultScratchMount),
+		})
+	}
 	if opts.PipIndexURL != "" {
 		pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env,
 			corev1.EnvVar{Name: "PIP_INDEX_URL", Value: opts.PipIndexURL},
@@ -261,11 +629,11 @@ func BuildHeadlessService(workspace *workspacev1alpha1.Workspace, scheme *runtim
 		},
 		Spec: corev1.ServiceSpec{
 			ClusterIP: corev1.ClusterIPNone,
-			Selector:  labels,
+			Selector:  SelectorLabels(userID),
 			Ports: []corev1.ServicePort{
 				{
 					Name:     "ttyd",
-					Port:     ttydPort,
+					Port:     TTYDPort(workspace),
 					Protocol: corev1.ProtocolTCP,
 				},
 			},
@@ -277,6 +645,747 @@ func BuildHeadlessService(workspace *workspacev1alpha1.Workspace, scheme *runtim
 	return svc, nil
 }
 
+// ExternalDNSHostnameAnnotation tells external-dns which DNS name to publish
+// for a Service. On the headless workspace Service it resolves to the pod IP.
+const ExternalDNSHostnameAnnotation = "external-dns.alpha.kubernetes.io/hostname"
+
+// externalDNSAnnotationPrefix marks the annotations external-dns acts on. Only
+// the operator sets them, so a workspace cannot claim someone else's DNS name.
+const externalDNSAnnotationPrefix = "external-dns.alpha.kubernetes.io/"
+
+// ManagedAnnotationsAnnotation lists, comma-separated, the annotation keys the
+// operator set on an object, so keys dropped from the spec can be removed
+// without touching annotations added by others.
+const ManagedAnnotationsAnnotation = "devplane.io/managed-annotations"
+
+// ExternalDNSHostname expands the {user} and {namespace} placeholders of
+// template for workspace, e.g. "{user}.ws.example.com". An empty template
+// returns "".
+func ExternalDNSHostname(template string, workspace *workspacev1alpha1.Workspace) string {
+	if template == "" {
+		return ""
+	}
+	return strings.NewReplacer("{user}", workspace.Spec.User.ID, "{namespace}", workspace.Namespace).Replace(template)
+}
+
+// BuildServiceAnnotations returns the workspace Service annotations, or nil
+// when none apply: spec.serviceAnnotations, then the external-dns hostname
+// expanded from hostnameTemplate. external-dns keys from the spec are dropped
+// (ValidateSpec rejects them), so the operator's hostname always wins.
+func BuildServiceAnnotations(workspace *workspacev1alpha1.Workspace, hostnameTemplate string) map[string]string {
+	annotations := map[string]string{}
+	for k, v := range workspace.Spec.ServiceAnnotations {
+		if !strings.HasPrefix(k, externalDNSAnnotationPrefix) {
+			annotations[k] = v
+		}
+	}
+	if host := ExternalDNSHostname(hostnameTemplate, workspace); host != "" {
+		annotations[ExternalDNSHostnameAnnotation] = host
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+	return annotations
+}
+
+// ApplyManagedAnnotations merges desired into current and deletes the keys a
+// previous call set that desired no longer has. Annotations the operator did
+// not set are kept. The managed keys are recorded under
+// ManagedAnnotationsAnnotation; the result is nil when nothing remains.
+func ApplyManagedAnnotations(current, desired map[string]string) map[string]string {
+	out := maps.Clone(current)
+	if out == nil {
+		out = map[string]string{}
+	}
+	if prev := out[ManagedAnnotationsAnnotation]; prev != "" {
+		for k := range strings.SplitSeq(prev, ",") {
+			if _, keep := desired[k]; !keep {
+				delete(out, k)
+			}
+		}
+	}
+	delete(out, ManagedAnnotationsAnnotation)
+	maps.Copy(out, desired)
+	if len(desired) > 0 {
+		out[ManagedAnnotationsAnnotation] = strings.Join(slices.Sorted(maps.Keys(desired)), ",")
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// IngressOpts configures the optional per-workspace Ingress.
+type IngressOpts struct {
+	// BaseDomain is the parent domain of workspace hosts: a workspace for user
+	// "john" is served at john.<BaseDomain>. Empty disables the Ingress.
+	BaseDomain string
+	// ClassName is the IngressClass to use. Empty uses the cluster default.
+	ClassName string
+	// ClusterIssuer is the cert-manager ClusterIssuer that issues the host's
+	// certificate. Empty serves the Ingress without TLS.
+	ClusterIssuer string
+	// AuthURL is the external auth endpoint (e.g. oauth2-proxy's /oauth2/auth)
+	// the ingress controller checks every request against. The Ingress skips
+	// the gateway, so BuildIngress refuses to publish ttyd without it.
+	AuthURL string
+	// AuthSignin is where unauthenticated browsers are sent to log in, e.g.
+	// oauth2-proxy's /oauth2/start. Optional.
+	AuthSignin string
+}
+
+// Ingress annotations for external authentication (ingress-nginx).
+const (
+	IngressAuthURLAnnotation    = "nginx.ingress.kubernetes.io/auth-url"
+	IngressAuthSigninAnnotation = "nginx.ingress.kubernetes.io/auth-signin"
+)
+
+// IngressHost returns the host the workspace Ingress serves.
+func IngressHost(userID, baseDomain string) string {
+	return userID + "." + strings.TrimPrefix(baseDomain, ".")
+}
+
+// BuildIngress creates an Ingress routing IngressHost to the workspace
+// Service's ttyd port, with an owner reference. Requests are authenticated
+// against opts.AuthURL by the ingress controller; without it no Ingress is
+// built. When opts.ClusterIssuer is set the host is served over TLS with a
+// cert-manager issued certificate.
+func BuildIngress(workspace *workspacev1alpha1.Workspace, scheme *runtime.Scheme, opts IngressOpts) (*networkingv1.Ingress, error) {
+	if opts.AuthURL == "" {
+		return nil, errors.New("workspace Ingress requires an auth URL; it bypasses the gateway's OIDC check")
+	}
+	userID := workspace.Spec.User.ID
+	host := IngressHost(userID, opts.BaseDomain)
+	pathType := networkingv1.PathTypePrefix
+
+	ing := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        IngressName(userID),
+			Namespace:   workspace.Namespace,
+			Labels:      Labels(userID),
+			Annotations: map[string]string{IngressAuthURLAnnotation: opts.AuthURL},
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: host,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     "/",
+									PathType: &pathType,
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: ServiceName(userID),
+											Port: networkingv1.ServiceBackendPort{Name: "ttyd"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	if opts.ClassName != "" {
+		ing.Spec.IngressClassName = ptr(opts.ClassName)
+	}
+	if opts.AuthSignin != "" {
+		ing.Annotations[IngressAuthSigninAnnotation] = opts.AuthSignin
+	}
+	if opts.ClusterIssuer != "" {
+		ing.Annotations["cert-manager.io/cluster-issuer"] = opts.ClusterIssuer
+		ing.Spec.TLS = []networkingv1.IngressTLS{
+			{Hosts: []string{host}, SecretName: IngressTLSSecretName(userID)},
+		}
+	}
+	if err := controllerutil.SetControllerReference(workspace, ing, scheme); err != nil {
+		return nil, fmt.Errorf("set Ingress owner reference: %w", err)
+	}
+	return ing, nil
+}
+
+// DefaultMinStorage is the operator fallback floor for spec.resources.storage
+// when MIN_STORAGE is unset. Smaller volumes fill up as soon as the workspace
+// image seeds the home directory.
+const DefaultMinStorage = "1Gi"
+
+// ValidateMinStorage returns an error if spec.resources.storage is below floor.
+// A zero floor disables the check.
+func ValidateMinStorage(workspace *workspacev1alpha1.Workspace, floor resource.Quantity) error {
+	if floor.IsZero() {
+		return nil
+	}
+	qty, err := resource.ParseQuantity(workspace.Spec.Resources.Storage)
+	if err != nil {
+		return fmt.Errorf("spec.resources.storage invalid: %w", err)
+	}
+	if qty.Cmp(floor) < 0 {
+		return fmt.Errorf("spec.resources.storage %s is below the operator minimum of %s", qty.String(), floor.String())
+	}
+	return nil
+}
+
+// DefaultMaxProvidersJSONBytes is the operator fallback limit on the serialized
+// size of spec.aiConfig.providers when MAX_PROVIDERS_JSON_BYTES is unset. It is
+// well under Linux's 128KiB limit for a single environment string, beyond which
+// the container fails to start with an opaque exec error.
+const DefaultMaxProvidersJSONBytes = 64 * 1024
+
+// ValidateProvidersSize returns an error if spec.aiConfig.providers serializes
+// to more than maxBytes of AI_PROVIDERS_JSON. A non-positive limit disables the check.
+func ValidateProvidersSize(workspace *workspacev1alpha1.Workspace, maxBytes int) error {
+	if maxBytes <= 0 {
+		return nil
+	}
+	data, err := json.Marshal(workspace.Spec.AIConfig.Providers)
+	if err != nil {
+		return fmt.Errorf("spec.aiConfig.providers invalid: %w", err)
+	}
+	if len(data) > maxBytes {
+		return fmt.Errorf("spec.aiConfig.providers serializes to %d bytes, above the operator limit of %d bytes; reduce the number of providers or models", len(data), maxBytes)
+	}
+	return nil
+}
+
+// DefaultMaxEgressPorts is the operator fallback limit on the number of egress
+// ports a workspace spec may list when MAX_EGRESS_PORTS is unset. Legitimate
+// specs need a handful; hundreds effectively open everything and bloat the
+// egress NetworkPolicy.
+const DefaultMaxEgressPorts = 64
+
+// ValidateEgressPorts returns an error if spec.aiConfig.egressPorts and the
+// ports of spec.dataEgress together list more than maxPorts entries. A
+// non-positive limit disables the check.
+func ValidateEgressPorts(workspace *workspacev1alpha1.Workspace, maxPorts int) error {
+	if maxPorts <= 0 {
+		return nil
+	}
+	n := len(workspace.Spec.AIConfig.EgressPorts)
+	for _, rule := range workspace.Spec.DataEgress {
+		n += len(rule.Ports)
+	}
+	if n > maxPorts {
+		return fmt.Errorf("spec lists %d egress ports (spec.aiConfig.egressPorts plus spec.dataEgress[].ports), above the operator limit of %d; list only the ports the workspace needs", n, maxPorts)
+	}
+	return nil
+}
+
+// buildReadinessProbe returns the workspace container readiness probe: a TCP
+// check on the ttyd port by default, or the configured command in Exec mode.
+func buildReadinessProbe(spec workspacev1alpha1.ReadinessSpec, port int32) *corev1.Probe {
+	handler := corev1.ProbeHandler{
+		TCPSocket: &corev1.TCPSocketAction{
+			Port: intstr.FromInt32(port),
+		},
+	}
+	if spec.Type == workspacev1alpha1.ReadinessProbeExec {
+		handler = corev1.ProbeHandler{
+			Exec: &corev1.ExecAction{Command: append([]string(nil), spec.Command...)},
+		}
+	}
+	return &corev1.Probe{
+		ProbeHandler:        handler,
+		InitialDelaySeconds: 5,
+		PeriodSeconds:       5,
+	}
+}
+
+// Startup probe defaults give an image five minutes to start ttyd.
+const (
+	defaultStartupProbeFailureThreshold = 60
+	defaultStartupProbePeriodSeconds    = 5
+)
+
+// buildStartupProbe returns the workspace container startup probe: a TCP check
+// on the ttyd port that holds off the readiness probe until ttyd is listening.
+func buildStartupProbe(spec workspacev1alpha1.StartupProbeSpec, port int32) *corev1.Probe {
+	failureThreshold := spec.FailureThreshold
+	if failureThreshold <= 0 {
+		failureThreshold = defaultStartupProbeFailureThreshold
+	}
+	period := spec.PeriodSeconds
+	if period <= 0 {
+		period = defaultStartupProbePeriodSeconds
+	}
+	return &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			TCPSocket: &corev1.TCPSocketAction{
+				Port: intstr.FromInt32(port),
+			},
+		},
+		PeriodSeconds:    period,
+		FailureThreshold: failureThreshold,
+	}
+}
+
+// validateScratch checks spec.scratch: a positive size limit and, when set, an
+// absolute mount path that does not shadow a volume the operator mounts.
+func validateScratch(spec workspacev1alpha1.ScratchSpec) error {
+	if spec.SizeLimit == "" {
+		return nil
+	}
+	qty, err := resource.ParseQuantity(spec.SizeLimit)
+	if err != nil {
+		return fmt.Errorf("spec.scratch.sizeLimit invalid: %w", err)
+	}
+	if qty.Sign() <= 0 {
+		return fmt.Errorf("spec.scratch.sizeLimit must be greater than zero (got %s)", spec.SizeLimit)
+	}
+	if spec.MountPath == "" {
+		return nil
+	}
+	if !path.IsAbs(spec.MountPath) || path.Clean(spec.MountPath) != spec.MountPath {
+		return fmt.Errorf("spec.scratch.mountPath %q must be a clean absolute path", spec.MountPath)
+	}
+	switch spec.MountPath {
+	case "/", workspaceMount, "/tmp", aiSettingsMount, "/etc/ssl/certs/custom":
+		return fmt.Errorf("spec.scratch.mountPath %q is reserved by the operator", spec.MountPath)
+	}
+	return nil
+}
+
+// buildContainerLifecycle returns the container preStop hook from
+// spec.lifecycle.preStopExec, or nil when none is configured.
+func buildContainerLifecycle(spec workspacev1alpha1.WorkspaceLifecycleSpec) *corev1.Lifecycle {
+	if len(spec.PreStopExec) == 0 {
+		return nil
+	}
+	return &corev1.Lifecycle{
+		PreStop: &corev1.LifecycleHandler{
+			Exec: &corev1.ExecAction{Command: append([]string(nil), spec.PreStopExec...)},
+		},
+	}
+}
+
+// buildDataVolumeSource returns the workspace-data volume: the workspace PVC,
+// or an emptyDir capped at spec.resources.storage when persistence is ephemeral.
+func buildDataVolumeSource(workspace *workspacev1alpha1.Workspace, pvcName string) (corev1.VolumeSource, error) {
+	if !workspace.Spec.Persistence.Ephemeral {
+		return corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: pvcName},
+		}, nil
+	}
+	storageQty, err := resource.ParseQuantity(workspace.Spec.Resources.Storage)
+	if err != nil {
+		return corev1.VolumeSource{}, fmt.Errorf("parse storage quantity %q: %w", workspace.Spec.Resources.Storage, err)
+	}
+	return corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{SizeLimit: &storageQty}}, nil
+}
+
+// BuildDeployment returns a Deployment running spec.replicas copies of the
+// workspace pod built by BuildPod, with an owner reference. The pod template
+// carries the same labels as a single workspace pod so the Service and
+// NetworkPolicies select every replica. A workspace on a ReadWriteOnce PVC is
+// rolled with the Recreate strategy: a surge pod on another node could not
+// attach the volume while the old pod holds it.
+func BuildDeployment(workspace *workspacev1alpha1.Workspace, pvcName, workspaceImage string, scheme *runtime.Scheme, opts BuildOpts) (*appsv1.Deployment, error) {
+	userID := workspace.Spec.User.ID
+	pod, err := BuildPod(workspace, pvcName, workspaceImage, scheme, opts)
+	if err != nil {
+		return nil, err
+	}
+	replicas := int32(1)
+	if workspace.Spec.Replicas != nil {
+		replicas = *workspace.Spec.Replicas
+	}
+	labels := Labels(userID)
+	strategy := appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+	if workspace.Spec.Persistence.Ephemeral || pvcAccessMode(workspace.Spec.Persistence) == corev1.ReadWriteMany {
+		strategy = appsv1.DeploymentStrategy{Type: appsv1.RollingUpdateDeploymentStrategyType}
+	}
+	deploy := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      DeploymentName(userID),
+			Namespace: workspace.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Strategy: strategy,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels, Annotations: pod.Annotations},
+				Spec:       pod.Spec,
+			},
+		},
+	}
+	if err := controllerutil.SetControllerReference(workspace, deploy, scheme); err != nil {
+		return nil, fmt.Errorf("set Deployment owner reference: %w", err)
+	}
+	return deploy, nil
+}
+
+// buildAffinity turns spec.scheduling.nodeAffinity into a required node
+// affinity, or nil when no term is set.
+func buildAffinity(term *corev1.NodeSelectorTerm) *corev1.Affinity {
+	if term == nil {
+		return nil
+	}
+	return &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{*term},
+			},
+		},
+	}
+}
+
+// validateScheduling checks spec.scheduling: node selector labels, toleration
+// operators and effects, and node affinity operators.
+func validateScheduling(s workspacev1alpha1.SchedulingSpec) error {
+	for k, v := range s.NodeSelector {
+		if errs := validation.IsQualifiedName(k); len(errs) > 0 {
+			return fmt.Errorf("spec.scheduling.nodeSelector key %q is invalid: %s", k, strings.Join(errs, "; "))
+		}
+		if errs := validation.IsValidLabelValue(v); len(errs) > 0 {
+			return fmt.Errorf("spec.scheduling.nodeSelector[%s] value %q is invalid: %s", k, v, strings.Join(errs, "; "))
+		}
+	}
+	for i, t := range s.Tolerations {
+		switch t.Operator {
+		case "", corev1.TolerationOpEqual:
+			if t.Key == "" {
+				return fmt.Errorf("spec.scheduling.tolerations[%d]: operator Equal needs a key", i)
+			}
+		case corev1.TolerationOpExists:
+			if t.Value != "" {
+				return fmt.Errorf("spec.scheduling.tolerations[%d]: operator Exists must not set a value", i)
+			}
+		default:
+			return fmt.Errorf("spec.scheduling.tolerations[%d].operator %q is not supported (use Equal or Exists)", i, t.Operator)
+		}
+		switch t.Effect {
+		case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return fmt.Errorf("spec.scheduling.tolerations[%d].effect %q is not supported (use NoSchedule, PreferNoSchedule or NoExecute)", i, t.Effect)
+		}
+	}
+	if term := s.NodeAffinity; term != nil {
+		if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+			return errors.New("spec.scheduling.nodeAffinity must have at least one matchExpressions or matchFields entry")
+		}
+		for i, r := range slices.Concat(term.MatchExpressions, term.MatchFields) {
+			switch r.Operator {
+			case corev1.NodeSelectorOpIn, corev1.NodeSelectorOpNotIn, corev1.NodeSelectorOpExists,
+				corev1.NodeSelectorOpDoesNotExist, corev1.NodeSelectorOpGt, corev1.NodeSelectorOpLt:
+			default:
+				return fmt.Errorf("spec.scheduling.nodeAffinity requirement %d (key %q): operator %q is not supported", i, r.Key, r.Operator)
+			}
+		}
+	}
+	return nil
+}
+
+// runtimeClassName returns the pod RuntimeClass: spec.runtimeClassName, else
+// the operator default, else nil for the cluster's default runtime.
+func runtimeClassName(workspace *workspacev1alpha1.Workspace, operatorDefault string) *string {
+	if workspace.Spec.RuntimeClassName != "" {
+		return ptr(workspace.Spec.RuntimeClassName)
+	}
+	if operatorDefault != "" {
+		return ptr(operatorDefault)
+	}
+	return nil
+}
+
+// buildPodAnnotations returns the workspace pod annotations, or nil when none
+// apply. Operator defaults are overridden by spec.podAnnotations, and both by
+// the annotations the operator itself manages.
+func buildPodAnnotations(workspace *workspacev1alpha1.Workspace, opts BuildOpts) map[string]string {
+	annotations := map[string]string{}
+	maps.Copy(annotations, opts.DefaultPodAnnotations)
+	maps.Copy(annotations, workspace.Spec.PodAnnotations)
+	if len(workspace.Spec.AdditionalNetworks) > 0 {
+		annotations[MultusNetworksAnnotation] = strings.Join(workspace.Spec.AdditionalNetworks, ",")
+	}
+	if opts.CABundleHash != "" {
+		annotations[CABundleHashAnnotation] = opts.CABundleHash
+	}
+	if len(annotations) == 0 {
+		return nil
+	}
+	return annotations
+}
+
+// CABundleConfigMapName returns the CA bundle ConfigMap mounted in the workspace
+// pod: spec.tls.customCABundle, else defaultCABundle. Empty means none.
+func CABundleConfigMapName(workspace *workspacev1alpha1.Workspace, defaultCABundle string) string {
+	if workspace.Spec.TLS.CustomCABundle != nil && workspace.Spec.TLS.CustomCABundle.Name != "" {
+		return workspace.Spec.TLS.CustomCABundle.Name
+	}
+	return defaultCABundle
+}
+
+// ConfigMapDataHash returns a short, stable hash of a ConfigMap's data and
+// binaryData, independent of key order.
+func ConfigMapDataHash(cm *corev1.ConfigMap) string {
+	h := sha256.New()
+	for _, k := range slices.Sorted(maps.Keys(cm.Data)) {
+		fmt.Fprintf(h, "d:%s=%d:%s\n", k, len(cm.Data[k]), cm.Data[k])
+	}
+	for _, k := range slices.Sorted(maps.Keys(cm.BinaryData)) {
+		fmt.Fprintf(h, "b:%s=%d:", k, len(cm.BinaryData[k]))
+		h.Write(cm.BinaryData[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// InvalidCABundleKeys returns the sorted keys of cm whose values do not hold at
+// least one PEM CERTIFICATE block, or hold a block that fails to parse. Text
+// between blocks (e.g. "# Issuer:" comments in distro bundles) is ignored.
+func InvalidCABundleKeys(cm *corev1.ConfigMap) []string {
+	var bad []string
+	for _, k := range slices.Sorted(maps.Keys(cm.Data)) {
+		if !validPEMCertificates([]byte(cm.Data[k])) {
+			bad = append(bad, k)
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(cm.BinaryData)) {
+		if !validPEMCertificates(cm.BinaryData[k]) {
+			bad = append(bad, k)
+		}
+	}
+	slices.Sort(bad)
+	return bad
+}
+
+func validPEMCertificates(data []byte) bool {
+	n := 0
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return n > 0
+		}
+		if block.Type != "CERTIFICATE" {
+			return false
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return false
+		}
+		n++
+	}
+}
+
+// buildResources derives the container resource block from the spec's
+// quantities, CPU burst factor, and declared QoS class.
+func buildResources(spec workspacev1alpha1.ResourceRequirements) (corev1.ResourceRequirements, error) {
+	if spec.QoSClass == workspacev1alpha1.QoSClassBestEffort {
+		return corev1.ResourceRequirements{}, nil
+	}
+	cpuQty, err := resource.ParseQuantity(spec.CPU)
+	if err != nil {
+		return corev1.ResourceRequirements{}, fmt.Errorf("parse CPU quantity %q: %w", spec.CPU, err)
+	}
+	memQty, err := resource.ParseQuantity(spec.Memory)
+	if err != nil {
+		return corev1.ResourceRequirements{}, fmt.Errorf("parse memory quantity %q: %w", spec.Memory, err)
+	}
+	cpuLimit, err := CPULimit(cpuQty, spec.CPUBurst)
+	if err != nil {
+		return corev1.ResourceRequirements{}, err
+	}
+	if spec.CPULimit != "" {
+		if cpuLimit, err = resource.ParseQuantity(spec.CPULimit); err != nil {
+			return corev1.ResourceRequirements{}, fmt.Errorf("parse CPU limit %q: %w", spec.CPULimit, err)
+		}
+	}
+	memLimit := memQty
+	if spec.MemoryLimit != "" {
+		if memLimit, err = resource.ParseQuantity(spec.MemoryLimit); err != nil {
+			return corev1.ResourceRequirements{}, fmt.Errorf("parse memory limit %q: %w", spec.MemoryLimit, err)
+		}
+	}
+	res := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{
+			corev1.ResourceCPU:    cpuQty,
+			corev1.ResourceMemory: memQty,
+		},
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    cpuLimit,
+			corev1.ResourceMemory: memLimit,
+		},
+	}
+	// An explicit Burstable class without a burst factor or CPU limit leaves CPU unlimited.
+	if spec.QoSClass == workspacev1alpha1.QoSClassBurstable && strings.TrimSpace(spec.CPUBurst) == "" && spec.CPULimit == "" {
+		delete(res.Limits, corev1.ResourceCPU)
+	}
+	// Extended resources cannot be overcommitted, so request and limit match.
+	if spec.GPU != nil {
+		name := gpuResourceName(spec.GPU)
+		count := *resource.NewQuantity(int64(spec.GPU.Count), resource.DecimalSI)
+		res.Requests[name] = count
+		res.Limits[name] = count
+	}
+	return res, nil
+}
+
+// DefaultGPUResourceName is the extended resource requested when
+// spec.resources.gpu.vendor is empty.
+const DefaultGPUResourceName = "nvidia.com/gpu"
+
+// gpuResourceName returns the extended resource name for gpu.
+func gpuResourceName(gpu *workspacev1alpha1.GPUResource) corev1.ResourceName {
+	return corev1.ResourceName(cmp.Or(strings.TrimSpace(gpu.Vendor), DefaultGPUResourceName))
+}
+
+// validateGPU rejects a non-positive GPU count and a vendor that is not a
+// vendor-prefixed extended resource name such as nvidia.com/gpu.
+func validateGPU(gpu *workspacev1alpha1.GPUResource) error {
+	if gpu == nil {
+		return nil
+	}
+	if gpu.Count <= 0 {
+		return fmt.Errorf("spec.resources.gpu.count must be greater than zero (got %d)", gpu.Count)
+	}
+	name := gpuResourceName(gpu)
+	if errs := validation.IsQualifiedName(string(name)); len(errs) > 0 || !isExtendedResourceName(name) {
+		return fmt.Errorf("spec.resources.gpu.vendor %q must be an extended resource name such as %s", gpu.Vendor, DefaultGPUResourceName)
+	}
+	return nil
+}
+
+// validateLimits parses spec.resources.cpuLimit and memoryLimit and rejects
+// a limit below its request or a CPU limit combined with cpuBurst.
+func validateLimits(spec workspacev1alpha1.ResourceRequirements, cpuQty, memQty resource.Quantity) error {
+	if spec.CPULimit != "" {
+		if strings.TrimSpace(spec.CPUBurst) != "" {
+			return errors.New("spec.resources.cpuLimit and spec.resources.cpuBurst are mutually exclusive")
+		}
+		limit, err := resource.ParseQuantity(spec.CPULimit)
+		if err != nil {
+			return fmt.Errorf("spec.resources.cpuLimit invalid: %w", err)
+		}
+		if limit.Cmp(cpuQty) < 0 {
+			return fmt.Errorf("spec.resources.cpuLimit %s is below spec.resources.cpu %s", spec.CPULimit, spec.CPU)
+		}
+	}
+	if spec.MemoryLimit != "" {
+		limit, err := resource.ParseQuantity(spec.MemoryLimit)
+		if err != nil {
+			return fmt.Errorf("spec.resources.memoryLimit invalid: %w", err)
+		}
+		if limit.Cmp(memQty) < 0 {
+			return fmt.Errorf("spec.resources.memoryLimit %s is below spec.resources.memory %s", spec.MemoryLimit, spec.Memory)
+		}
+	}
+	return nil
+}
+
+// quantityEquals reports whether the optional quantity s is empty or parses to
+// a value equal to want.
+func quantityEquals(s string, want resource.Quantity) bool {
+	if s == "" {
+		return true
+	}
+	q, err := resource.ParseQuantity(s)
+	return err == nil && q.Cmp(want) == 0
+}
+
+// validateQoSClass rejects QoS class and CPU burst combinations that cannot
+// produce the declared class.
+func validateQoSClass(spec workspacev1alpha1.ResourceRequirements, cpuQty, memQty resource.Quantity) error {
+	switch spec.QoSClass {
+	case "":
+		return nil
+	case workspacev1alpha1.QoSClassBurstable:
+		// Without a burst factor or CPU limit, CPU is left unlimited.
+		if strings.TrimSpace(spec.CPUBurst) == "" && spec.CPULimit == "" {
+			return nil
+		}
+		limit, err := CPULimit(cpuQty, spec.CPUBurst)
+		if err != nil {
+			return err
+		}
+		// Limits that all equal the requests would silently make the pod Guaranteed.
+		if quantityEquals(spec.CPULimit, cpuQty) && limit.Cmp(cpuQty) == 0 && quantityEquals(spec.MemoryLimit, memQty) {
+			return errors.New("spec.resources.cpuBurst must be above 1 with qosClass Burstable, or set cpuLimit or memoryLimit above the request")
+		}
+		return nil
+	case workspacev1alpha1.QoSClassGuaranteed:
+		limit, err := CPULimit(cpuQty, spec.CPUBurst)
+		if err != nil {
+			return err
+		}
+		if limit.Cmp(cpuQty) != 0 {
+			return errors.New("spec.resources.cpuBurst above 1 is not allowed with qosClass Guaranteed")
+		}
+		if !quantityEquals(spec.CPULimit, cpuQty) || !quantityEquals(spec.MemoryLimit, memQty) {
+			return errors.New("spec.resources.cpuLimit and memoryLimit must equal the requests with qosClass Guaranteed")
+		}
+		return nil
+	case workspacev1alpha1.QoSClassBestEffort:
+		if strings.TrimSpace(spec.CPUBurst) != "" {
+			return errors.New("spec.resources.cpuBurst is not allowed with qosClass BestEffort")
+		}
+		if spec.CPULimit != "" || spec.MemoryLimit != "" {
+			return errors.New("spec.resources.cpuLimit and memoryLimit are not allowed with qosClass BestEffort")
+		}
+		if spec.GPU != nil {
+			return errors.New("spec.resources.gpu is not allowed with qosClass BestEffort")
+		}
+		return nil
+	default:
+		return fmt.Errorf("spec.resources.qosClass %q is not supported (use Guaranteed, Burstable, or BestEffort)", spec.QoSClass)
+	}
+}
+
+// MaxCPUBurst caps spec.resources.cpuBurst so a single workspace cannot claim
+// an unbounded share of node CPU when bursting.
+const MaxCPUBurst = 8.0
+
+// CPULimit derives the container CPU limit from the request and the optional
+// burst factor. An empty factor yields a limit equal to the request.
+func CPULimit(request resource.Quantity, burst string) (resource.Quantity, error) {
+	burst = strings.TrimSpace(burst)
+	if burst == "" {
+		return request, nil
+	}
+	factor, err := strconv.ParseFloat(burst, 64)
+	if err != nil {
+		return resource.Quantity{}, fmt.Errorf("spec.resources.cpuBurst invalid: %w", err)
+	}
+	if factor < 1 || factor > MaxCPUBurst {
+		return resource.Quantity{}, fmt.Errorf("spec.resources.cpuBurst must be between 1 and %g (got %s)", MaxCPUBurst, burst)
+	}
+	return *resource.NewMilliQuantity(int64(math.Round(float64(request.MilliValue())*factor)), resource.DecimalSI), nil
+}
+
+// ExtendedResourceRequests sums the extended resources (vendor-prefixed names
+// such as nvidia.com/gpu) requested by pod's containers. Extended resources
+// default their request to the limit, so a limit-only entry counts too.
+func ExtendedResourceRequests(pod *corev1.Pod) corev1.ResourceList {
+	out := corev1.ResourceList{}
+	add := func(name corev1.ResourceName, qty resource.Quantity) {
+		total := out[name]
+		total.Add(qty)
+		out[name] = total
+	}
+	for _, c := range pod.Spec.Containers {
+		for name, qty := range c.Resources.Requests {
+			if isExtendedResourceName(name) {
+				add(name, qty)
+			}
+		}
+		for name, qty := range c.Resources.Limits {
+			if _, requested := c.Resources.Requests[name]; !requested && isExtendedResourceName(name) {
+				add(name, qty)
+			}
+		}
+	}
+	return out
+}
+
+// isExtendedResourceName reports whether name is a vendor-prefixed resource
+// outside the kubernetes.io namespace, e.g. nvidia.com/gpu.
+func isExtendedResourceName(name corev1.ResourceName) bool {
+	domain, _, ok := strings.Cut(string(name), "/")
+	return ok && domain != "kubernetes.io" && !strings.HasSuffix(domain, ".kubernetes.io") && !strings.HasPrefix(string(name), "requests.")
+}
+
 // ValidateSpec returns an error if the workspace spec is invalid.
 // It validates required fields, user ID DNS-label format, and resource quantity syntax.
 func ValidateSpec(workspace *workspacev1alpha1.Workspace) error {
@@ -310,15 +1419,39 @@ func ValidateSpec(workspace *workspacev1alpha1.Workspace) error {
 	}
 	// Validate resource quantities eagerly to surface parse errors before
 	// resource.MustParse panics in builder functions.
-	if _, err := resource.ParseQuantity(s.Resources.CPU); err != nil {
+	cpuQty, err := resource.ParseQuantity(s.Resources.CPU)
+	if err != nil {
 		return fmt.Errorf("spec.resources.cpu invalid: %w", err)
 	}
-	if _, err := resource.ParseQuantity(s.Resources.Memory); err != nil {
+	if cpuQty.Sign() <= 0 {
+		return fmt.Errorf("spec.resources.cpu must be greater than zero (got %s)", s.Resources.CPU)
+	}
+	if _, err := CPULimit(cpuQty, s.Resources.CPUBurst); err != nil {
+		return err
+	}
+	memQty, err := resource.ParseQuantity(s.Resources.Memory)
+	if err != nil {
 		return fmt.Errorf("spec.resources.memory invalid: %w", err)
 	}
-	if _, err := resource.ParseQuantity(s.Resources.Storage); err != nil {
+	if memQty.Sign() <= 0 {
+		return fmt.Errorf("spec.resources.memory must be greater than zero (got %s)", s.Resources.Memory)
+	}
+	if err := validateLimits(s.Resources, cpuQty, memQty); err != nil {
+		return err
+	}
+	if err := validateGPU(s.Resources.GPU); err != nil {
+		return err
+	}
+	if err := validateQoSClass(s.Resources, cpuQty, memQty); err != nil {
+		return err
+	}
+	storageQty, err := resource.ParseQuantity(s.Resources.Storage)
+	if err != nil {
 		return fmt.Errorf("spec.resources.storage invalid: %w", err)
 	}
+	if storageQty.Sign() <= 0 {
+		return fmt.Errorf("spec.resources.storage must be greater than zero (got %s)", s.Resources.Storage)
+	}
 	if len(s.AIConfig.Providers) == 0 {
 		return errors.New("spec.aiConfig.providers must have at least one entry")
 	}
@@ -332,11 +1465,170 @@ func ValidateSpec(workspace *workspacev1alpha1.Workspace) error {
 		if len(p.Models) == 0 {
 			return fmt.Errorf("spec.aiConfig.providers[%d].models must have at least one entry", i)
 		}
+		// Model IDs are compared case-insensitively: servers differ in whether
+		// they treat "Llama-3" and "llama-3" as the same model.
+		seen := make(map[string]int, len(p.Models))
+		for j, m := range p.Models {
+			id := strings.TrimSpace(m)
+			if id == "" {
+				return fmt.Errorf("spec.aiConfig.providers[%d].models[%d] must not be empty", i, j)
+			}
+			if k, dup := seen[strings.ToLower(id)]; dup {
+				return fmt.Errorf("spec.aiConfig.providers[%d].models[%d] %q duplicates models[%d]", i, j, id, k)
+			}
+			seen[strings.ToLower(id)] = j
+		}
+	}
+	switch s.Persistence.AccessMode {
+	case "", workspacev1alpha1.PersistenceAccessModeReadWriteOnce, workspacev1alpha1.PersistenceAccessModeReadWriteMany:
+	default:
+		return fmt.Errorf("spec.persistence.accessMode %q is not supported (use ReadWriteOnce or ReadWriteMany)", s.Persistence.AccessMode)
+	}
+	if snap := s.Persistence.RestoreFrom; snap != "" {
+		if s.Persistence.Ephemeral {
+			return errors.New("spec.persistence.restoreFrom cannot be combined with spec.persistence.ephemeral")
+		}
+		if errs := validation.IsDNS1123Subdomain(snap); len(errs) > 0 {
+			return fmt.Errorf("spec.persistence.restoreFrom %q must be a valid VolumeSnapshot name: %s", snap, strings.Join(errs, "; "))
+		}
+	}
+	if s.Replicas != nil {
+		if *s.Replicas < 1 {
+			return fmt.Errorf("spec.replicas must be at least 1 (got %d)", *s.Replicas)
+		}
+		if *s.Replicas > 1 && !s.Persistence.Ephemeral && s.Persistence.AccessMode != workspacev1alpha1.PersistenceAccessModeReadWriteMany {
+			return fmt.Errorf("spec.replicas %d requires spec.persistence.ephemeral or accessMode ReadWriteMany; a ReadWriteOnce volume cannot be shared between pods", *s.Replicas)
+		}
+	}
+	for i, d := range s.DependsOn {
+		if d == "" {
+			return fmt.Errorf("spec.dependsOn[%d] must not be empty", i)
+		}
+		if d == workspace.Name {
+			return fmt.Errorf("spec.dependsOn[%d] must not reference the workspace itself", i)
+		}
+	}
+	for i, de := range s.DataEgress {
+		if de.Namespace == "" {
+			return fmt.Errorf("spec.dataEgress[%d].namespace is required", i)
+		}
+		if len(de.Ports) == 0 {
+			return fmt.Errorf("spec.dataEgress[%d].ports must have at least one entry", i)
+		}
+		for _, p := range de.Ports {
+			if p < 1 || p > 65535 {
+				return fmt.Errorf("spec.dataEgress[%d].ports: %d is not a valid port", i, p)
+			}
+		}
+	}
+	if rc := s.RuntimeClassName; rc != "" {
+		if errs := validation.IsDNS1123Subdomain(rc); len(errs) > 0 {
+			return fmt.Errorf("spec.runtimeClassName %q must be a valid RuntimeClass name: %s", rc, strings.Join(errs, "; "))
+		}
+	}
+	if err := validateScheduling(s.Scheduling); err != nil {
+		return err
+	}
+	// Checked here so a bad key fails the Workspace instead of every pod create.
+	if errs := apivalidation.ValidateAnnotations(s.PodAnnotations, field.NewPath("spec", "podAnnotations")); len(errs) > 0 {
+		return errs.ToAggregate()
+	}
+	for i, n := range s.AdditionalNetworks {
+		if len(n) > 253 || !networkRefRegex.MatchString(n) {
+			return fmt.Errorf("spec.additionalNetworks[%d] %q must be a network name or namespace/name", i, n)
+		}
+	}
+	if err := validateRepo(s.Repo); err != nil {
+		return err
+	}
+	if len(s.FeatureFlags) > maxFeatureFlags {
+		return fmt.Errorf("spec.featureFlags must have at most %d entries (got %d)", maxFeatureFlags, len(s.FeatureFlags))
+	}
+	for k := range s.FeatureFlags {
+		if !featureFlagKeyRegex.MatchString(k) {
+			return fmt.Errorf("spec.featureFlags key %q must be 1-63 characters of letters, digits, '.', '_' or '-'", k)
+		}
+	}
+	if raw, _ := json.Marshal(s.FeatureFlags); len(raw) > maxFeatureFlagsBytes {
+		return fmt.Errorf("spec.featureFlags must serialise to at most %d bytes (got %d)", maxFeatureFlagsBytes, len(raw))
+	}
+	if err := validateEnv(s.User.ID, s.Env); err != nil {
+		return err
+	}
+	switch s.Readiness.Type {
+	case "", workspacev1alpha1.ReadinessProbeTCP:
+	case workspacev1alpha1.ReadinessProbeExec:
+		if len(s.Readiness.Command) == 0 || strings.TrimSpace(s.Readiness.Command[0]) == "" {
+			return errors.New("spec.readiness.command is required when spec.readiness.type is Exec")
+		}
+	default:
+		return fmt.Errorf("spec.readiness.type %q is not supported (use TCP or Exec)", s.Readiness.Type)
+	}
+	if raw := strings.TrimSpace(s.AIConfig.Settings.Temperature); raw != "" {
+		t, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("spec.aiConfig.settings.temperature invalid: %w", err)
+		}
+		if t < 0 || t > 2 {
+			return fmt.Errorf("spec.aiConfig.settings.temperature must be between 0 and 2 (got %s)", raw)
+		}
+	}
+	if s.Port != 0 && (s.Port < 1024 || s.Port > 65535) {
+		return fmt.Errorf("spec.port must be between 1024 and 65535 (got %d); the workspace runs without the capability to bind lower ports", s.Port)
+	}
+	if err := validateScratch(s.Scratch); err != nil {
+		return err
+	}
+	for _, k := range slices.Sorted(maps.Keys(s.ServiceAnnotations)) {
+		if strings.HasPrefix(k, externalDNSAnnotationPrefix) {
+			return fmt.Errorf("spec.serviceAnnotations key %q is reserved: external-dns names are set by the operator", k)
+		}
+	}
+	if s.Lifecycle.PreStopExec != nil && (len(s.Lifecycle.PreStopExec) == 0 || strings.TrimSpace(s.Lifecycle.PreStopExec[0]) == "") {
+		return errors.New("spec.lifecycle.preStopExec must name a command when set")
 	}
 	if raw := strings.TrimSpace(s.Lifecycle.IdleTimeout); raw != "" && raw != "0" {
-		if _, err := time.ParseDuration(raw); err != nil {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
 			return fmt.Errorf("spec.lifecycle.idleTimeout invalid: %w", err)
 		}
+		if d < 0 {
+			return fmt.Errorf("spec.lifecycle.idleTimeout must not be negative (got %s)", raw)
+		}
+	}
+	return nil
+}
+
+// validateRepo checks spec.repo. The entrypoint passes these values to git on
+// the command line, so anything that could be read as an option is rejected.
+func validateRepo(repo workspacev1alpha1.RepoSpec) error {
+	if repo.URL == "" {
+		if repo.Ref != "" || repo.SubPath != "" {
+			return errors.New("spec.repo.url is required when spec.repo.ref or spec.repo.subPath is set")
+		}
+		return nil
+	}
+	if !scpRepoRegex.MatchString(repo.URL) {
+		u, err := url.Parse(repo.URL)
+		if err != nil {
+			return fmt.Errorf("spec.repo.url invalid: %w", err)
+		}
+		switch u.Scheme {
+		case "https", "http", "ssh", "git":
+		default:
+			return fmt.Errorf("spec.repo.url %q must use https, http, ssh or git, or the scp-style user@host:path form", repo.URL)
+		}
+		if u.Host == "" || strings.Trim(u.Path, "/") == "" {
+			return fmt.Errorf("spec.repo.url %q must include a host and repository path", repo.URL)
+		}
+	}
+	if strings.HasPrefix(repo.Ref, "-") || strings.ContainsAny(repo.Ref, " \t\n~^:?*[\\") {
+		return fmt.Errorf("spec.repo.ref %q is not a valid git ref", repo.Ref)
+	}
+	if sp := repo.SubPath; sp != "" {
+		if path.IsAbs(sp) || path.Clean(sp) != sp || sp == "." || sp == ".." || strings.HasPrefix(sp, "../") || strings.HasPrefix(sp, "-") {
+			return fmt.Errorf("spec.repo.subPath %q must be a clean relative path inside the workspace", sp)
+		}
 	}
 	return nil
 }
@@ -345,12 +1637,106 @@ func ValidateSpec(workspace *workspacev1alpha1.Workspace) error {
 // AI provider configuration is serialised to JSON so the entrypoint script can
 // iterate over providers without requiring a template engine.
 func buildEnvVars(workspace *workspacev1alpha1.Workspace) []corev1.EnvVar {
-	providersJSON, _ := json.Marshal(workspace.Spec.AIConfig.Providers)
-	return []corev1.EnvVar{
+	providersJSON, _ := json.Marshal(normalizeProviders(workspace.Spec.AIConfig.Providers))
+	env := []corev1.EnvVar{
 		{Name: "AI_PROVIDERS_JSON", Value: string(providersJSON)},
 		{Name: "USER_EMAIL", Value: workspace.Spec.User.Email},
 		{Name: "USER_ID", Value: workspace.Spec.User.ID},
+		{Name: "AI_SETTINGS_FILE", Value: aiSettingsMount + "/" + AISettingsKey},
+		{Name: "TTYD_PORT", Value: strconv.Itoa(int(TTYDPort(workspace)))},
+	}
+	if repo := workspace.Spec.Repo; repo.URL != "" {
+		env = append(env,
+			corev1.EnvVar{Name: "GIT_REPO_URL", Value: repo.URL},
+			corev1.EnvVar{Name: "GIT_REPO_REF", Value: repo.Ref},
+			corev1.EnvVar{Name: "GIT_REPO_SUBPATH", Value: repo.SubPath},
+		)
+	}
+	if len(workspace.Spec.FeatureFlags) > 0 {
+		flagsJSON, _ := json.Marshal(workspace.Spec.FeatureFlags)
+		env = append(env, corev1.EnvVar{Name: "FEATURE_FLAGS_JSON", Value: string(flagsJSON)})
+	}
+	for _, e := range workspace.Spec.Env {
+		v := corev1.EnvVar{Name: e.Name, Value: e.Value}
+		switch {
+		case e.SecretKeyRef != nil:
+			v.ValueFrom = &corev1.EnvVarSource{SecretKeyRef: e.SecretKeyRef.DeepCopy()}
+		case e.ConfigMapKeyRef != nil:
+			v.ValueFrom = &corev1.EnvVarSource{ConfigMapKeyRef: e.ConfigMapKeyRef.DeepCopy()}
+		}
+		env = append(env, v)
+	}
+	return env
+}
+
+// operatorEnvVars are the workspace container variables BuildPod sets itself;
+// spec.env may not redefine them.
+var operatorEnvVars = []string{
+	"AI_PROVIDERS_JSON", "USER_EMAIL", "USER_ID", "AI_SETTINGS_FILE", "TTYD_PORT",
+	"GIT_REPO_URL", "GIT_REPO_REF", "GIT_REPO_SUBPATH", "FEATURE_FLAGS_JSON",
+	"CUSTOM_CA_MOUNTED", "PIP_INDEX_URL", "PIP_TRUSTED_HOST", "npm_config_registry",
+}
+
+// validateEnv checks spec.env: valid, unique names that the operator does not
+// set itself, each with exactly one of value, secretKeyRef or configMapKeyRef.
+// Referenced Secrets and ConfigMaps must be named "<userID>-...", like the
+// objects the operator creates per user, so a Workspace cannot read another
+// user's Secret from the shared namespace.
+func validateEnv(userID string, env []workspacev1alpha1.EnvSource) error {
+	prefix := userID + "-"
+	seen := make(map[string]bool, len(env))
+	for i, e := range env {
+		if errs := validation.IsEnvVarName(e.Name); len(errs) > 0 {
+			return fmt.Errorf("spec.env[%d].name %q is invalid: %s", i, e.Name, strings.Join(errs, "; "))
+		}
+		if slices.Contains(operatorEnvVars, e.Name) {
+			return fmt.Errorf("spec.env[%d].name %q is set by the operator", i, e.Name)
+		}
+		if seen[e.Name] {
+			return fmt.Errorf("spec.env[%d].name %q is listed more than once", i, e.Name)
+		}
+		seen[e.Name] = true
+		sources := 0
+		if e.Value != "" {
+			sources++
+		}
+		if e.SecretKeyRef != nil {
+			if e.SecretKeyRef.Name == "" || e.SecretKeyRef.Key == "" {
+				return fmt.Errorf("spec.env[%d].secretKeyRef needs a name and key", i)
+			}
+			if !strings.HasPrefix(e.SecretKeyRef.Name, prefix) {
+				return fmt.Errorf("spec.env[%d].secretKeyRef.name %q must start with %q", i, e.SecretKeyRef.Name, prefix)
+			}
+			sources++
+		}
+		if e.ConfigMapKeyRef != nil {
+			if e.ConfigMapKeyRef.Name == "" || e.ConfigMapKeyRef.Key == "" {
+				return fmt.Errorf("spec.env[%d].configMapKeyRef needs a name and key", i)
+			}
+			if !strings.HasPrefix(e.ConfigMapKeyRef.Name, prefix) {
+				return fmt.Errorf("spec.env[%d].configMapKeyRef.name %q must start with %q", i, e.ConfigMapKeyRef.Name, prefix)
+			}
+			sources++
+		}
+		if sources != 1 {
+			return fmt.Errorf("spec.env[%d] (%s) must set exactly one of value, secretKeyRef or configMapKeyRef", i, e.Name)
+		}
+	}
+	return nil
+}
+
+// normalizeProviders returns a copy of providers with surrounding whitespace
+// trimmed from each model ID. Case is kept as written.
+func normalizeProviders(providers []workspacev1alpha1.AIProvider) []workspacev1alpha1.AIProvider {
+	out := make([]workspacev1alpha1.AIProvider, len(providers))
+	for i, p := range providers {
+		out[i] = p
+		out[i].Models = make([]string, len(p.Models))
+		for j, m := range p.Models {
+			out[i].Models[j] = strings.TrimSpace(m)
+		}
 	}
+	return out
 }
 
 func ptr[T any](v T) *T {
@@ -2,11 +2,17 @@ package workspace
 
 import (
 	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"testing"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -120,78 +126,1478 @@ func TestBuildPVC_EmptyStorageClass(t *testing.T) {
 	}
 }
 
+func TestPVCCapacity(t *testing.T) {
+	pvc := &corev1.PersistentVolumeClaim{
+		Status: corev1.PersistentVolumeClaimStatus{
+			Phase:    corev1.ClaimPending,
+			Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("20Gi")},
+		},
+	}
+	if got := PVCCapacity(pvc); got != "" {
+		t.Errorf("PVCCapacity(pending) = %q, want empty", got)
+	}
+	pvc.Status.Phase = corev1.ClaimBound
+	if got := PVCCapacity(pvc); got != "20Gi" {
+		t.Errorf("PVCCapacity(bound) = %q, want 20Gi", got)
+	}
+	if got := PVCCapacity(nil); got != "" {
+		t.Errorf("PVCCapacity(nil) = %q, want empty", got)
+	}
+}
+
+func TestValidateProvidersSize_Oversized(t *testing.T) {
+	ws := minimalWorkspace()
+	models := make([]string, 500)
+	for i := range models {
+		models[i] = fmt.Sprintf("org/very-long-model-identifier-%04d-instruct", i)
+	}
+	for i := 0; i < 10; i++ {
+		ws.Spec.AIConfig.Providers = append(ws.Spec.AIConfig.Providers, workspacev1alpha1.AIProvider{
+			Name: fmt.Sprintf("p%d", i), Endpoint: "http://vllm:8000", Models: models,
+		})
+	}
+	err := ValidateProvidersSize(ws, DefaultMaxProvidersJSONBytes)
+	if err == nil {
+		t.Fatal("expected error for oversized provider list")
+	}
+	if !strings.Contains(err.Error(), "spec.aiConfig.providers serializes to") {
+		t.Errorf("error = %v, want a clear size message", err)
+	}
+	if err := ValidateProvidersSize(ws, 0); err != nil {
+		t.Errorf("zero limit should disable the check, got %v", err)
+	}
+}
+
+func TestValidateProvidersSize_WithinLimit(t *testing.T) {
+	if err := ValidateProvidersSize(minimalWorkspace(), DefaultMaxProvidersJSONBytes); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateEgressPorts(t *testing.T) {
+	ws := minimalWorkspace()
+	for p := int32(1000); p < 1060; p++ {
+		ws.Spec.AIConfig.EgressPorts = append(ws.Spec.AIConfig.EgressPorts, p)
+	}
+	if err := ValidateEgressPorts(ws, DefaultMaxEgressPorts); err != nil {
+		t.Fatalf("60 ports should be within the default limit: %v", err)
+	}
+
+	ws.Spec.DataEgress = []workspacev1alpha1.DataEgressRule{
+		{Namespace: "data", Ports: []int32{5432, 6379, 9092, 27017, 3306}},
+	}
+	err := ValidateEgressPorts(ws, DefaultMaxEgressPorts)
+	if err == nil {
+		t.Fatal("expected error for 65 egress ports")
+	}
+	if !strings.Contains(err.Error(), "65 egress ports") || !strings.Contains(err.Error(), "limit of 64") {
+		t.Errorf("error = %v, want the count and the limit", err)
+	}
+	if err := ValidateEgressPorts(ws, 0); err != nil {
+		t.Errorf("zero limit should disable the check, got %v", err)
+	}
+}
+
 func TestBuildPod(t *testing.T) {
 	ws := minimalWorkspace()
 	pod, err := BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
 	if err != nil {
 		t.Fatalf("BuildPod: %v", err)
 	}
-	if pod.Name != "john-workspace-pod" {
-		t.Errorf("pod.Name = %q, want john-workspace-pod", pod.Name)
+	if pod.Name != "john-workspace-pod" {
+		t.Errorf("pod.Name = %q, want john-workspace-pod", pod.Name)
+	}
+	psc := pod.Spec.SecurityContext
+	if psc == nil {
+		t.Fatal("PodSecurityContext is nil")
+	}
+	if psc.RunAsNonRoot == nil || !*psc.RunAsNonRoot {
+		t.Error("PodSecurityContext.RunAsNonRoot expected true")
+	}
+	if psc.RunAsUser == nil || *psc.RunAsUser != 1000 {
+		t.Error("PodSecurityContext.RunAsUser expected 1000")
+	}
+	if len(pod.Spec.Containers) != 1 {
+		t.Fatalf("expected one container, got %d", len(pod.Spec.Containers))
+	}
+	c := &pod.Spec.Containers[0]
+	if c.Image != "workspace:0.0.1" {
+		t.Errorf("container image = %q, want workspace:0.0.1", c.Image)
+	}
+	if c.SecurityContext == nil {
+		t.Fatal("container SecurityContext is nil")
+	}
+	if c.SecurityContext.ReadOnlyRootFilesystem == nil || !*c.SecurityContext.ReadOnlyRootFilesystem {
+		t.Error("container SecurityContext.ReadOnlyRootFilesystem expected true")
+	}
+	if c.SecurityContext.AllowPrivilegeEscalation == nil || *c.SecurityContext.AllowPrivilegeEscalation {
+		t.Error("container SecurityContext.AllowPrivilegeEscalation expected false")
+	}
+	foundWorkspaceMount := false
+	for _, vm := range c.VolumeMounts {
+		if vm.MountPath == workspaceMount {
+			foundWorkspaceMount = true
+		}
+	}
+	if !foundWorkspaceMount {
+		t.Errorf("VolumeMounts = %v, want a mount at %s", c.VolumeMounts, workspaceMount)
+	}
+	envMap := make(map[string]string)
+	for _, e := range c.Env {
+		envMap[e.Name] = e.Value
+	}
+	for _, name := range []string{"AI_PROVIDERS_JSON", "USER_EMAIL", "USER_ID"} {
+		if _, ok := envMap[name]; !ok {
+			t.Errorf("missing env %q", name)
+		}
+	}
+	if envMap["USER_ID"] != "john" || envMap["USER_EMAIL"] != "john@example.com" {
+		t.Errorf("USER_ID=%q USER_EMAIL=%q", envMap["USER_ID"], envMap["USER_EMAIL"])
+	}
+	// Verify AI_PROVIDERS_JSON round-trips correctly.
+	var providers []workspacev1alpha1.AIProvider
+	if err := json.Unmarshal([]byte(envMap["AI_PROVIDERS_JSON"]), &providers); err != nil {
+		t.Fatalf("AI_PROVIDERS_JSON is not valid JSON: %v", err)
+	}
+	if len(providers) != 1 || providers[0].Name != "local" || providers[0].Endpoint != "http://vllm:8000" {
+		t.Errorf("AI_PROVIDERS_JSON providers = %+v", providers)
+	}
+	if len(providers[0].Models) != 1 || providers[0].Models[0] != "model" {
+		t.Errorf("AI_PROVIDERS_JSON models = %v", providers[0].Models)
+	}
+	if len(pod.OwnerReferences) != 1 || pod.OwnerReferences[0].Kind != "Workspace" {
+		t.Errorf("expected Workspace owner reference, got %v", pod.OwnerReferences)
+	}
+	if pod.Spec.ServiceAccountName != "john-workspace" {
+		t.Errorf("pod.Spec.ServiceAccountName = %q, want john-workspace", pod.Spec.ServiceAccountName)
+	}
+}
+
+func TestBuildPod_NetworkPolicyReadinessGate(t *testing.T) {
+	pod, err := BuildPod(minimalWorkspace(), "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	want := []corev1.PodReadinessGate{{ConditionType: NetworkPolicyReadyCondition}}
+	if !slices.Equal(pod.Spec.ReadinessGates, want) {
+		t.Errorf("readinessGates = %v, want %v", pod.Spec.ReadinessGates, want)
+	}
+}
+
+func TestBuildPod_CPUBurst(t *testing.T) {
+	ws := minimalWorkspace()
+	ws.Spec.Resources.CPU = "1500m"
+	ws.Spec.Resources.CPUBurst = "2.5"
+	pod, err := BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	res := pod.Spec.Containers[0].Resources
+	req := res.Requests[corev1.ResourceCPU]
+	lim := res.Limits[corev1.ResourceCPU]
+	if req.MilliValue() != 1500 {
+		t.Errorf("CPU request = %s, want 1500m", req.String())
+	}
+	if lim.MilliValue() != 3750 {
+		t.Errorf("CPU limit = %s, want 3750m (request × 2.5)", lim.String())
+	}
+	memReq := res.Requests[corev1.ResourceMemory]
+	memLim := res.Limits[corev1.ResourceMemory]
+	if memReq.Cmp(memLim) != 0 {
+		t.Errorf("memory request %s != limit %s; burst must only affect CPU", memReq.String(), memLim.String())
+	}
+}
+
+func TestBuildPod_NoCPUBurst_LimitEqualsRequest(t *testing.T) {
+	ws := minimalWorkspace()
+	pod, err := BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	res := pod.Spec.Containers[0].Resources
+	req := res.Requests[corev1.ResourceCPU]
+	lim := res.Limits[corev1.ResourceCPU]
+	if req.Cmp(lim) != 0 {
+		t.Errorf("CPU limit %s != request %s without cpuBurst", lim.String(), req.String())
+	}
+}
+
+func TestBuildPod_ExplicitLimits(t *testing.T) {
+	ws := minimalWorkspace()
+	ws.Spec.Resources.CPULimit = "4"
+	ws.Spec.Resources.MemoryLimit = "8Gi"
+	if err := ValidateSpec(ws); err != nil {
+		t.Fatalf("ValidateSpec: %v", err)
+	}
+	pod, err := BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	res := pod.Spec.Containers[0].Resources
+	for name, want := range map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    "1",
+		corev1.ResourceMemory: "2Gi",
+	} {
+		if got := res.Requests[name]; got.Cmp(resource.MustParse(want)) != 0 {
+			t.Errorf("%s request = %s, want %s", name, got.String(), want)
+		}
+	}
+	for name, want := range map[corev1.ResourceName]string{
+		corev1.ResourceCPU:    "4",
+		corev1.ResourceMemory: "8Gi",
+	} {
+		if got := res.Limits[name]; got.Cmp(resource.MustParse(want)) != 0 {
+			t.Errorf("%s limit = %s, want %s", name, got.String(), want)
+		}
+	}
+}
+
+func TestValidateSpec_Limits(t *testing.T) {
+	tests := []struct {
+		name     string
+		cpuLimit string
+		memLimit string
+		burst    string
+		qos      workspacev1alpha1.QoSClass
+		wantErr  bool
+	}{
+		{name: "equal to requests", cpuLimit: "1", memLimit: "2Gi"},
+		{name: "above requests", cpuLimit: "1500m", memLimit: "3Gi"},
+		{name: "cpu below request", cpuLimit: "500m", wantErr: true},
+		{name: "memory below request", memLimit: "1Gi", wantErr: true},
+		{name: "unparseable", cpuLimit: "lots", wantErr: true},
+		{name: "with cpuBurst", cpuLimit: "2", burst: "2", wantErr: true},
+		{name: "guaranteed above request", memLimit: "4Gi", qos: workspacev1alpha1.QoSClassGuaranteed, wantErr: true},
+		{name: "best effort", cpuLimit: "2", qos: workspacev1alpha1.QoSClassBestEffort, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := minimalWorkspace()
+			ws.Spec.Resources.CPULimit = tt.cpuLimit
+			ws.Spec.Resources.MemoryLimit = tt.memLimit
+			ws.Spec.Resources.CPUBurst = tt.burst
+			ws.Spec.Resources.QoSClass = tt.qos
+			if err := ValidateSpec(ws); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildPod_GPU(t *testing.T) {
+	ws := minimalWorkspace()
+	ws.Spec.Resources.GPU = &workspacev1alpha1.GPUResource{Count: 1}
+	if err := ValidateSpec(ws); err != nil {
+		t.Fatalf("ValidateSpec: %v", err)
+	}
+	pod, err := BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	res := pod.Spec.Containers[0].Resources
+	for kind, list := range map[string]corev1.ResourceList{"request": res.Requests, "limit": res.Limits} {
+		if got, ok := list["nvidia.com/gpu"]; !ok || got.Cmp(resource.MustParse("1")) != 0 {
+			t.Errorf("nvidia.com/gpu %s = %s (set %v), want 1", kind, got.String(), ok)
+		}
+	}
+
+	ws.Spec.Resources.GPU = nil
+	pod, err = BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	if got := ExtendedResourceRequests(pod); len(got) != 0 {
+		t.Errorf("extended resources without spec.resources.gpu = %v, want none", got)
+	}
+}
+
+func TestValidateSpec_GPU(t *testing.T) {
+	tests := []struct {
+		name    string
+		gpu     workspacev1alpha1.GPUResource
+		qos     workspacev1alpha1.QoSClass
+		wantErr bool
+	}{
+		{name: "default vendor", gpu: workspacev1alpha1.GPUResource{Count: 2}},
+		{name: "amd", gpu: workspacev1alpha1.GPUResource{Vendor: "amd.com/gpu", Count: 1}},
+		{name: "zero count", gpu: workspacev1alpha1.GPUResource{Count: 0}, wantErr: true},
+		{name: "unprefixed vendor", gpu: workspacev1alpha1.GPUResource{Vendor: "gpu", Count: 1}, wantErr: true},
+		{name: "core resource", gpu: workspacev1alpha1.GPUResource{Vendor: "kubernetes.io/gpu", Count: 1}, wantErr: true},
+		{name: "invalid name", gpu: workspacev1alpha1.GPUResource{Vendor: "nvidia.com/g pu", Count: 1}, wantErr: true},
+		{name: "best effort", gpu: workspacev1alpha1.GPUResource{Count: 1}, qos: workspacev1alpha1.QoSClassBestEffort, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := minimalWorkspace()
+			ws.Spec.Resources.GPU = &tt.gpu
+			ws.Spec.Resources.QoSClass = tt.qos
+			if err := ValidateSpec(ws); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildPod_Scheduling(t *testing.T) {
+	ws := minimalWorkspace()
+	toleration := corev1.Toleration{
+		Key:      "nvidia.com/gpu",
+		Operator: corev1.TolerationOpExists,
+		Effect:   corev1.TaintEffectNoSchedule,
+	}
+	term := corev1.NodeSelectorTerm{MatchExpressions: []corev1.NodeSelectorRequirement{
+		{Key: "node.kubernetes.io/instance-type", Operator: corev1.NodeSelectorOpIn, Values: []string{"g5.xlarge"}},
+	}}
+	ws.Spec.Scheduling = workspacev1alpha1.SchedulingSpec{
+		NodeSelector: map[string]string{"pool": "gpu"},
+		Tolerations:  []corev1.Toleration{toleration},
+		NodeAffinity: &term,
+	}
+	if err := ValidateSpec(ws); err != nil {
+		t.Fatalf("ValidateSpec: %v", err)
+	}
+	pod, err := BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	if !maps.Equal(pod.Spec.NodeSelector, map[string]string{"pool": "gpu"}) {
+		t.Errorf("nodeSelector = %v, want pool=gpu", pod.Spec.NodeSelector)
+	}
+	if len(pod.Spec.Tolerations) != 1 || pod.Spec.Tolerations[0] != toleration {
+		t.Errorf("tolerations = %+v, want [%+v]", pod.Spec.Tolerations, toleration)
+	}
+	aff := pod.Spec.Affinity
+	if aff == nil || aff.NodeAffinity == nil || aff.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		t.Fatalf("affinity = %+v, want a required node affinity", aff)
+	}
+	terms := aff.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) != 1 || terms[0].MatchExpressions[0].Values[0] != "g5.xlarge" {
+		t.Errorf("node selector terms = %+v", terms)
+	}
+
+	// The pod holds copies: later edits to the spec must not leak into it.
+	ws.Spec.Scheduling.NodeSelector["pool"] = "cpu"
+	if pod.Spec.NodeSelector["pool"] != "gpu" {
+		t.Error("pod nodeSelector aliases the workspace spec")
+	}
+}
+
+func TestBuildPod_NoSchedulingByDefault(t *testing.T) {
+	pod, err := BuildPod(minimalWorkspace(), "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	if pod.Spec.NodeSelector != nil || pod.Spec.Tolerations != nil || pod.Spec.Affinity != nil {
+		t.Errorf("scheduling fields set without spec.scheduling: %v %v %v", pod.Spec.NodeSelector, pod.Spec.Tolerations, pod.Spec.Affinity)
+	}
+}
+
+func TestValidateSpec_Scheduling(t *testing.T) {
+	tests := []struct {
+		name    string
+		sched   workspacev1alpha1.SchedulingSpec
+		wantErr bool
+	}{
+		{name: "equal toleration", sched: workspacev1alpha1.SchedulingSpec{Tolerations: []corev1.Toleration{{Key: "spot", Value: "true", Effect: corev1.TaintEffectNoExecute}}}},
+		{name: "exists without key", sched: workspacev1alpha1.SchedulingSpec{Tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists}}}},
+		{name: "unknown operator", sched: workspacev1alpha1.SchedulingSpec{Tolerations: []corev1.Toleration{{Key: "spot", Operator: "Matches"}}}, wantErr: true},
+		{name: "exists with value", sched: workspacev1alpha1.SchedulingSpec{Tolerations: []corev1.Toleration{{Key: "spot", Operator: corev1.TolerationOpExists, Value: "true"}}}, wantErr: true},
+		{name: "equal without key", sched: workspacev1alpha1.SchedulingSpec{Tolerations: []corev1.Toleration{{Value: "true"}}}, wantErr: true},
+		{name: "unknown effect", sched: workspacev1alpha1.SchedulingSpec{Tolerations: []corev1.Toleration{{Key: "spot", Effect: "Evict"}}}, wantErr: true},
+		{name: "bad selector key", sched: workspacev1alpha1.SchedulingSpec{NodeSelector: map[string]string{"bad key": "x"}}, wantErr: true},
+		{name: "empty affinity term", sched: workspacev1alpha1.SchedulingSpec{NodeAffinity: &corev1.NodeSelectorTerm{}}, wantErr: true},
+		{name: "bad affinity operator", sched: workspacev1alpha1.SchedulingSpec{NodeAffinity: &corev1.NodeSelectorTerm{
+			MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "pool", Operator: "Like"}},
+		}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := minimalWorkspace()
+			ws.Spec.Scheduling = tt.sched
+			if err := ValidateSpec(ws); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildPod_QoSClass(t *testing.T) {
+	tests := []struct {
+		name     string
+		qos      workspacev1alpha1.QoSClass
+		burst    string
+		wantReq  bool
+		cpuLimit string // "" means no CPU limit
+		memLimit string // "" means no memory limit
+	}{
+		{name: "default", wantReq: true, cpuLimit: "1", memLimit: "2Gi"},
+		{name: "guaranteed", qos: workspacev1alpha1.QoSClassGuaranteed, wantReq: true, cpuLimit: "1", memLimit: "2Gi"},
+		{name: "burstable", qos: workspacev1alpha1.QoSClassBurstable, wantReq: true, memLimit: "2Gi"},
+		{name: "burstable with burst", qos: workspacev1alpha1.QoSClassBurstable, burst: "2", wantReq: true, cpuLimit: "2", memLimit: "2Gi"},
+		{name: "best effort", qos: workspacev1alpha1.QoSClassBestEffort},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := minimalWorkspace()
+			ws.Spec.Resources.QoSClass = tt.qos
+			ws.Spec.Resources.CPUBurst = tt.burst
+			if err := ValidateSpec(ws); err != nil {
+				t.Fatalf("ValidateSpec: %v", err)
+			}
+			pod, err := BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+			if err != nil {
+				t.Fatalf("BuildPod: %v", err)
+			}
+			res := pod.Spec.Containers[0].Resources
+			if got := len(res.Requests) > 0; got != tt.wantReq {
+				t.Errorf("requests set = %v, want %v (%v)", got, tt.wantReq, res.Requests)
+			}
+			for name, want := range map[corev1.ResourceName]string{
+				corev1.ResourceCPU:    tt.cpuLimit,
+				corev1.ResourceMemory: tt.memLimit,
+			} {
+				got, ok := res.Limits[name]
+				if want == "" {
+					if ok {
+						t.Errorf("%s limit = %s, want unset", name, got.String())
+					}
+					continue
+				}
+				if !ok || got.Cmp(resource.MustParse(want)) != 0 {
+					t.Errorf("%s limit = %s, want %s", name, got.String(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateSpec_QoSClassCombinations(t *testing.T) {
+	tests := []struct {
+		qos   workspacev1alpha1.QoSClass
+		burst string
+	}{
+		{qos: workspacev1alpha1.QoSClassGuaranteed, burst: "2"},
+		{qos: workspacev1alpha1.QoSClassBestEffort, burst: "2"},
+		{qos: workspacev1alpha1.QoSClassBurstable, burst: "1"},
+		{qos: workspacev1alpha1.QoSClassBurstable, burst: "1.0"},
+		{qos: "Premium"},
+	}
+	for _, tt := range tests {
+		ws := minimalWorkspace()
+		ws.Spec.Resources.QoSClass = tt.qos
+		ws.Spec.Resources.CPUBurst = tt.burst
+		if err := ValidateSpec(ws); err == nil {
+			t.Errorf("ValidateSpec(qosClass=%q, cpuBurst=%q): expected error", tt.qos, tt.burst)
+		}
+	}
+}
+
+func TestValidateSpec_BurstableWithLimitEqualToRequest(t *testing.T) {
+	ws := minimalWorkspace()
+	ws.Spec.Resources.QoSClass = workspacev1alpha1.QoSClassBurstable
+	ws.Spec.Resources.CPULimit = ws.Spec.Resources.CPU
+	if err := ValidateSpec(ws); err == nil {
+		t.Error("cpuLimit equal to cpu with no memoryLimit: expected error, the pod would be Guaranteed")
+	}
+	// A memory limit above the request keeps the pod Burstable.
+	ws.Spec.Resources.MemoryLimit = "64Gi"
+	if err := ValidateSpec(ws); err != nil {
+		t.Errorf("memoryLimit above memory: ValidateSpec() = %v, want nil", err)
+	}
+}
+
+func TestValidateSpec_ModelIDs(t *testing.T) {
+	tests := []struct {
+		name    string
+		models  []string
+		wantErr bool
+	}{
+		{name: "distinct", models: []string{"llama-3", "qwen2.5"}},
+		{name: "surrounding whitespace", models: []string{" llama-3 ", "qwen2.5\t"}},
+		{name: "blank after trim", models: []string{"llama-3", "   "}, wantErr: true},
+		{name: "exact duplicate", models: []string{"llama-3", "llama-3"}, wantErr: true},
+		{name: "case-insensitive duplicate", models: []string{"Llama-3", "llama-3"}, wantErr: true},
+		{name: "duplicate after trim", models: []string{"llama-3", " LLAMA-3"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		ws := minimalWorkspace()
+		ws.Spec.AIConfig.Providers[0].Models = tt.models
+		err := ValidateSpec(ws)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: ValidateSpec() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBuildEnvVars_TrimsModelIDs(t *testing.T) {
+	ws := minimalWorkspace()
+	ws.Spec.AIConfig.Providers[0].Models = []string{"  Llama-3\n", "qwen2.5 "}
+	var raw string
+	for _, e := range buildEnvVars(ws) {
+		if e.Name == "AI_PROVIDERS_JSON" {
+			raw = e.Value
+		}
+	}
+	var providers []workspacev1alpha1.AIProvider
+	if err := json.Unmarshal([]byte(raw), &providers); err != nil {
+		t.Fatalf("AI_PROVIDERS_JSON is not valid JSON: %v", err)
+	}
+	if got := providers[0].Models; len(got) != 2 || got[0] != "Llama-3" || got[1] != "qwen2.5" {
+		t.Errorf("models = %q, want [Llama-3 qwen2.5]", got)
+	}
+	if ws.Spec.AIConfig.Providers[0].Models[0] != "  Llama-3\n" {
+		t.Error("buildEnvVars must not modify the Workspace spec")
+	}
+}
+
+func TestValidateSpec_Replicas(t *testing.T) {
+	replicas := func(n int32) *int32 { return &n }
+	tests := []struct {
+		name        string
+		replicas    *int32
+		persistence workspacev1alpha1.PersistenceConfig
+		wantErr     bool
+	}{
+		{name: "single replica RWO", replicas: replicas(1)},
+		{name: "multi replica RWO", replicas: replicas(3), wantErr: true},
+		{name: "multi replica default access mode", replicas: replicas(2),
+			persistence: workspacev1alpha1.PersistenceConfig{StorageClass: "standard"}, wantErr: true},
+		{name: "multi replica RWX", replicas: replicas(3),
+			persistence: workspacev1alpha1.PersistenceConfig{AccessMode: workspacev1alpha1.PersistenceAccessModeReadWriteMany}},
+		{name: "multi replica ephemeral", replicas: replicas(3),
+			persistence: workspacev1alpha1.PersistenceConfig{Ephemeral: true}},
+		{name: "zero replicas", replicas: replicas(0), wantErr: true},
+		{name: "unknown access mode", persistence: workspacev1alpha1.PersistenceConfig{AccessMode: "ReadOnlyMany"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		ws := minimalWorkspace()
+		ws.Spec.Replicas = tt.replicas
+		ws.Spec.Persistence = tt.persistence
+		err := ValidateSpec(ws)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: ValidateSpec() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBuildDeployment_MultiReplicaEphemeral(t *testing.T) {
+	ws := minimalWorkspace()
+	replicas := int32(3)
+	ws.Spec.Replicas = &replicas
+	ws.Spec.Persistence.Ephemeral = true
+	if !UsesDeployment(ws) {
+		t.Fatal("UsesDeployment = false, want true for 3 replicas")
+	}
+
+	deploy, err := BuildDeployment(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildDeployment: %v", err)
+	}
+	if deploy.Name != "john-workspace" {
+		t.Errorf("deploy.Name = %q, want john-workspace", deploy.Name)
+	}
+	if deploy.Spec.Replicas == nil || *deploy.Spec.Replicas != 3 {
+		t.Errorf("replicas = %v, want 3", deploy.Spec.Replicas)
+	}
+	labels := Labels("john")
+	for k, v := range labels {
+		if deploy.Spec.Selector.MatchLabels[k] != v {
+			t.Errorf("selector[%s] = %q, want %q", k, deploy.Spec.Selector.MatchLabels[k], v)
+		}
+		if deploy.Spec.Template.Labels[k] != v {
+			t.Errorf("template label %s = %q, want %q", k, deploy.Spec.Template.Labels[k], v)
+		}
+	}
+	if len(deploy.OwnerReferences) != 1 || deploy.OwnerReferences[0].Kind != "Workspace" {
+		t.Errorf("expected Workspace owner reference, got %v", deploy.OwnerReferences)
+	}
+
+	var data *corev1.Volume
+	for i := range deploy.Spec.Template.Spec.Volumes {
+		if deploy.Spec.Template.Spec.Volumes[i].Name == "workspace-data" {
+			data = &deploy.Spec.Template.Spec.Volumes[i]
+		}
+	}
+	if data == nil {
+		t.Fatal("workspace-data volume missing from template")
+	}
+	if data.PersistentVolumeClaim != nil || data.EmptyDir == nil {
+		t.Fatalf("workspace-data = %+v, want emptyDir for ephemeral persistence", data.VolumeSource)
+	}
+	if got := data.EmptyDir.SizeLimit; got == nil || got.Cmp(resource.MustParse("20Gi")) != 0 {
+		t.Errorf("emptyDir sizeLimit = %v, want 20Gi", got)
+	}
+}
+
+func TestBuildDeployment_Strategy(t *testing.T) {
+	tests := []struct {
+		name      string
+		ephemeral bool
+		mode      workspacev1alpha1.PersistenceAccessMode
+		want      appsv1.DeploymentStrategyType
+	}{
+		{name: "ReadWriteOnce", want: appsv1.RecreateDeploymentStrategyType},
+		{name: "explicit ReadWriteOnce", mode: workspacev1alpha1.PersistenceAccessModeReadWriteOnce, want: appsv1.RecreateDeploymentStrategyType},
+		{name: "ReadWriteMany", mode: workspacev1alpha1.PersistenceAccessModeReadWriteMany, want: appsv1.RollingUpdateDeploymentStrategyType},
+		{name: "ephemeral", ephemeral: true, want: appsv1.RollingUpdateDeploymentStrategyType},
+	}
+	for _, tt := range tests {
+		ws := minimalWorkspace()
+		ws.Spec.Persistence.Ephemeral = tt.ephemeral
+		ws.Spec.Persistence.AccessMode = tt.mode
+		deploy, err := BuildDeployment(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+		if err != nil {
+			t.Fatalf("%s: BuildDeployment: %v", tt.name, err)
+		}
+		if got := deploy.Spec.Strategy; got.Type != tt.want || got.RollingUpdate != nil {
+			t.Errorf("%s: strategy = %+v, want type %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestBuildPVC_ReadWriteMany(t *testing.T) {
+	ws := minimalWorkspace()
+	ws.Spec.Persistence.AccessMode = workspacev1alpha1.PersistenceAccessModeReadWriteMany
+	pvc, err := BuildPVC(ws, scheme)
+	if err != nil {
+		t.Fatalf("BuildPVC: %v", err)
+	}
+	if len(pvc.Spec.AccessModes) != 1 || pvc.Spec.AccessModes[0] != corev1.ReadWriteMany {
+		t.Errorf("access modes = %v, want [ReadWriteMany]", pvc.Spec.AccessModes)
+	}
+}
+
+func TestBuildPVC_RestoreFrom(t *testing.T) {
+	ws := minimalWorkspace()
+	pvc, err := BuildPVC(ws, scheme)
+	if err != nil {
+		t.Fatalf("BuildPVC: %v", err)
+	}
+	if pvc.Spec.DataSource != nil {
+		t.Errorf("dataSource = %+v, want nil without restoreFrom", pvc.Spec.DataSource)
+	}
+
+	ws.Spec.Persistence.RestoreFrom = "john-20260101-120000"
+	pvc, err = BuildPVC(ws, scheme)
+	if err != nil {
+		t.Fatalf("BuildPVC: %v", err)
+	}
+	ds := pvc.Spec.DataSource
+	if ds == nil || ds.APIGroup == nil || *ds.APIGroup != VolumeSnapshotGroup || ds.Kind != "VolumeSnapshot" || ds.Name != "john-20260101-120000" {
+		t.Errorf("dataSource = %+v, want VolumeSnapshot john-20260101-120000", ds)
+	}
+}
+
+func TestRestorePending(t *testing.T) {
+	ws := minimalWorkspace()
+	plain, err := BuildPVC(ws, scheme)
+	if err != nil {
+		t.Fatalf("BuildPVC: %v", err)
+	}
+	if RestorePending(ws, plain) {
+		t.Error("RestorePending without restoreFrom = true, want false")
+	}
+
+	ws.Spec.Persistence.RestoreFrom = "snap-a"
+	ws.Annotations = map[string]string{RestoreRequestAnnotation: "t1"}
+	if !RestorePending(ws, plain) {
+		t.Error("PVC without dataSource must be replaced")
+	}
+	restored, err := BuildPVC(ws, scheme)
+	if err != nil {
+		t.Fatalf("BuildPVC: %v", err)
+	}
+	if RestorePending(ws, restored) {
+		t.Error("PVC built for the current request must not be replaced")
+	}
+	ws.Annotations[RestoreRequestAnnotation] = "t2"
+	if !RestorePending(ws, restored) {
+		t.Error("a new restore request for the same snapshot must replace the PVC")
+	}
+	ws.Annotations[RestoreRequestAnnotation] = "t1"
+	ws.Spec.Persistence.RestoreFrom = "snap-b"
+	if !RestorePending(ws, restored) {
+		t.Error("a different snapshot must replace the PVC")
+	}
+}
+
+func TestValidateSpec_RestoreFrom(t *testing.T) {
+	tests := []struct {
+		name      string
+		snapshot  string
+		ephemeral bool
+		wantErr   string
+	}{
+		{name: "valid", snapshot: "john-checkpoint"},
+		{name: "invalid name", snapshot: "Bad_Name", wantErr: "spec.persistence.restoreFrom"},
+		{name: "ephemeral", snapshot: "john-checkpoint", ephemeral: true, wantErr: "cannot be combined with spec.persistence.ephemeral"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := minimalWorkspace()
+			ws.Spec.Persistence.RestoreFrom = tt.snapshot
+			ws.Spec.Persistence.Ephemeral = tt.ephemeral
+			err := ValidateSpec(ws)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("ValidateSpec: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildAISettingsConfigMap(t *testing.T) {
+	ws := minimalWorkspace()
+	cm, err := BuildAISettingsConfigMap(ws, scheme)
+	if err != nil {
+		t.Fatalf("BuildAISettingsConfigMap: %v", err)
+	}
+	if cm.Name != "john-workspace-ai" {
+		t.Errorf("cm.Name = %q, want john-workspace-ai", cm.Name)
+	}
+	if got := cm.Data[AISettingsKey]; got != "{}" {
+		t.Errorf("empty settings rendered as %q, want {}", got)
+	}
+
+	ws.Spec.AIConfig.Settings = workspacev1alpha1.AISettings{Temperature: "0", SystemPrompt: "hi"}
+	cm, err = BuildAISettingsConfigMap(ws, scheme)
+	if err != nil {
+		t.Fatalf("BuildAISettingsConfigMap: %v", err)
+	}
+	// An explicit zero temperature must be kept, not dropped as unset.
+	if got := cm.Data[AISettingsKey]; got != `{"temperature":0,"systemPrompt":"hi"}` {
+		t.Errorf("rendered settings = %s", got)
+	}
+}
+
+func TestWithHeadroom(t *testing.T) {
+	tests := []struct {
+		qty     string
+		count   int64
+		percent int
+		want    string
+	}{
+		{qty: "2", count: 1, percent: 25, want: "2500m"},
+		{qty: "500m", count: 3, percent: 10, want: "1650m"},
+		{qty: "1m", count: 1, percent: 50, want: "2m"},
+		// 4Ei in milli-units overflows int64.
+		{qty: "4Ei", count: 1, percent: 25, want: "5Ei"},
+		{qty: "2Ei", count: 3, percent: 0, want: "6Ei"},
+	}
+	for _, tt := range tests {
+		got := withHeadroom(resource.MustParse(tt.qty), tt.count, tt.percent)
+		if got.Cmp(resource.MustParse(tt.want)) != 0 {
+			t.Errorf("withHeadroom(%s, %d, %d%%) = %s, want %s", tt.qty, tt.count, tt.percent, got.String(), tt.want)
+		}
+	}
+}
+
+func TestBuildResourceQuota_BestEffortOnlyCapsStorage(t *testing.T) {
+	ws := minimalWorkspace()
+	ws.Spec.Resources.QoSClass = workspacev1alpha1.QoSClassBestEffort
+	quota, err := BuildResourceQuota(ws, scheme, 0)
+	if err != nil {
+		t.Fatalf("BuildResourceQuota: %v", err)
+	}
+	if quota.Name != "john-workspace-quota" {
+		t.Errorf("quota.Name = %q, want john-workspace-quota", quota.Name)
+	}
+	// A CPU or memory quota would reject a pod that sets no requests or limits.
+	if len(quota.Spec.Hard) != 1 {
+		t.Errorf("quota hard = %v, want only requests.storage", quota.Spec.Hard)
+	}
+	if got := quota.Spec.Hard[corev1.ResourceRequestsStorage]; got.Cmp(resource.MustParse("20Gi")) != 0 {
+		t.Errorf("requests.storage = %s, want 20Gi", got.String())
+	}
+}
+
+func TestBuildPod_MountsAISettings(t *testing.T) {
+	pod, err := BuildPod(minimalWorkspace(), "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	foundVol := false
+	for _, v := range pod.Spec.Volumes {
+		if v.Name == "ai-settings" && v.ConfigMap != nil && v.ConfigMap.Name == "john-workspace-ai" {
+			foundVol = true
+		}
+	}
+	if !foundVol {
+		t.Errorf("missing ai-settings ConfigMap volume: %v", pod.Spec.Volumes)
+	}
+	c := pod.Spec.Containers[0]
+	var settingsFile string
+	for _, e := range c.Env {
+		if e.Name == "AI_SETTINGS_FILE" {
+			settingsFile = e.Value
+		}
+	}
+	if settingsFile != "/etc/devplane/ai/ai-settings.json" {
+		t.Errorf("AI_SETTINGS_FILE = %q", settingsFile)
+	}
+}
+
+func TestValidateSpec_AISettingsTemperature(t *testing.T) {
+	for _, temp := range []string{"abc", "2.5"} {
+		ws := minimalWorkspace()
+		ws.Spec.AIConfig.Settings.Temperature = temp
+		if err := ValidateSpec(ws); err == nil {
+			t.Errorf("ValidateSpec: expected error for temperature %q", temp)
+		}
+	}
+}
+
+func TestBuildPod_DefaultTCPReadinessProbe(t *testing.T) {
+	pod, err := BuildPod(minimalWorkspace(), "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	probe := pod.Spec.Containers[0].ReadinessProbe
+	if probe == nil || probe.TCPSocket == nil || probe.TCPSocket.Port.IntValue() != int(DefaultTTYDPort) {
+		t.Fatalf("ReadinessProbe = %+v, want TCP on %d", probe, DefaultTTYDPort)
+	}
+	if probe.Exec != nil {
+		t.Error("default readiness probe must not set Exec")
+	}
+}
+
+func TestBuildPod_ExecReadinessProbe(t *testing.T) {
+	ws := minimalWorkspace()
+	ws.Spec.Readiness = workspacev1alpha1.ReadinessSpec{
+		Type:    workspacev1alpha1.ReadinessProbeExec,
+		Command: []string{"sh", "-c", "curl -fs http://localhost:8000/health"},
+	}
+	pod, err := BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	probe := pod.Spec.Containers[0].ReadinessProbe
+	if probe == nil || probe.Exec == nil {
+		t.Fatalf("ReadinessProbe = %+v, want Exec", probe)
+	}
+	if probe.TCPSocket != nil {
+		t.Error("exec readiness probe must not also set TCPSocket")
+	}
+	if got := strings.Join(probe.Exec.Command, " "); got != "sh -c curl -fs http://localhost:8000/health" {
+		t.Errorf("Exec.Command = %q", got)
+	}
+}
+
+func TestBuildPod_StartupProbe(t *testing.T) {
+	tests := []struct {
+		name          string
+		spec          workspacev1alpha1.StartupProbeSpec
+		wantThreshold int32
+		wantPeriod    int32
+	}{
+		{name: "defaults", wantThreshold: defaultStartupProbeFailureThreshold, wantPeriod: defaultStartupProbePeriodSeconds},
+		{name: "custom", spec: workspacev1alpha1.StartupProbeSpec{FailureThreshold: 120, PeriodSeconds: 10}, wantThreshold: 120, wantPeriod: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := minimalWorkspace()
+			ws.Spec.StartupProbe = tt.spec
+			pod, err := BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+			if err != nil {
+				t.Fatalf("BuildPod: %v", err)
+			}
+			c := pod.Spec.Containers[0]
+			probe := c.StartupProbe
+			if probe == nil || probe.TCPSocket == nil || probe.TCPSocket.Port.IntValue() != int(DefaultTTYDPort) {
+				t.Fatalf("StartupProbe = %+v, want TCP on %d", probe, DefaultTTYDPort)
+			}
+			if probe.FailureThreshold != tt.wantThreshold || probe.PeriodSeconds != tt.wantPeriod {
+				t.Errorf("StartupProbe failureThreshold/period = %d/%d, want %d/%d",
+					probe.FailureThreshold, probe.PeriodSeconds, tt.wantThreshold, tt.wantPeriod)
+			}
+			// Readiness stays the lightweight default regardless of startup tuning.
+			ready := c.ReadinessProbe
+			if ready == nil || ready.TCPSocket == nil || ready.InitialDelaySeconds != 5 || ready.PeriodSeconds != 5 || ready.FailureThreshold != 0 {
+				t.Errorf("ReadinessProbe = %+v, want the default 5s TCP check", ready)
+			}
+		})
+	}
+}
+
+func TestBuildPod_CustomPort(t *testing.T) {
+	ws := minimalWorkspace()
+	ws.Spec.Port = 8080
+	pod, err := BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	if got := PodTTYDPort(pod); got != 8080 {
+		t.Errorf("PodTTYDPort = %d, want 8080", got)
+	}
+	c := pod.Spec.Containers[0]
+	for _, e := range c.Env {
+		if e.Name == "TTYD_PORT" && e.Value != "8080" {
+			t.Errorf("TTYD_PORT = %q, want 8080", e.Value)
+		}
+	}
+	if c.ReadinessProbe == nil || c.ReadinessProbe.TCPSocket == nil || c.ReadinessProbe.TCPSocket.Port.IntValue() != 8080 {
+		t.Errorf("ReadinessProbe = %+v, want TCP on 8080", c.ReadinessProbe)
+	}
+	if c.StartupProbe == nil || c.StartupProbe.TCPSocket == nil || c.StartupProbe.TCPSocket.Port.IntValue() != 8080 {
+		t.Errorf("StartupProbe = %+v, want TCP on 8080", c.StartupProbe)
+	}
+
+	svc, err := BuildHeadlessService(ws, scheme)
+	if err != nil {
+		t.Fatalf("BuildHeadlessService: %v", err)
+	}
+	if got := svc.Spec.Ports[0].Port; got != 8080 {
+		t.Errorf("Service port = %d, want 8080", got)
+	}
+	if got := TTYDPort(minimalWorkspace()); got != DefaultTTYDPort {
+		t.Errorf("TTYDPort without spec.port = %d, want %d", got, DefaultTTYDPort)
+	}
+}
+
+func TestBuildPod_Scratch(t *testing.T) {
+	tests := []struct {
+		name      string
+		scratch   workspacev1alpha1.ScratchSpec
+		wantMount string
+	}{
+		{name: "disabled"},
+		{name: "default mount", scratch: workspacev1alpha1.ScratchSpec{SizeLimit: "50Gi"}, wantMount: "/scratch"},
+		{name: "custom mount", scratch: workspacev1alpha1.ScratchSpec{SizeLimit: "20Gi", MountPath: "/build"}, wantMount: "/build"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := minimalWorkspace()
+			ws.Spec.Scratch = tt.scratch
+			pod, err := BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+			if err != nil {
+				t.Fatalf("BuildPod: %v", err)
+			}
+			var vol *corev1.Volume
+			for i := range pod.Spec.Volumes {
+				if pod.Spec.Volumes[i].Name == "scratch" {
+					vol = &pod.Spec.Volumes[i]
+				}
+			}
+			var mountPath string
+			for _, m := range pod.Spec.Containers[0].VolumeMounts {
+				if m.Name == "scratch" {
+					mountPath = m.MountPath
+				}
+			}
+			if tt.wantMount == "" {
+				if vol != nil || mountPath != "" {
+					t.Errorf("scratch volume = %+v, mount = %q; want none", vol, mountPath)
+				}
+				return
+			}
+			if vol == nil || vol.EmptyDir == nil || vol.EmptyDir.SizeLimit == nil {
+				t.Fatalf("scratch volume = %+v, want a size-limited emptyDir", vol)
+			}
+			if got := vol.EmptyDir.SizeLimit.String(); got != tt.scratch.SizeLimit {
+				t.Errorf("scratch sizeLimit = %s, want %s", got, tt.scratch.SizeLimit)
+			}
+			if mountPath != tt.wantMount {
+				t.Errorf("scratch mountPath = %q, want %q", mountPath, tt.wantMount)
+			}
+		})
+	}
+}
+
+func TestBuildPod_AdditionalNetworks(t *testing.T) {
+	ws := minimalWorkspace()
+	pod, err := BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	if _, ok := pod.Annotations[MultusNetworksAnnotation]; ok {
+		t.Error("networks annotation must not be set when spec.additionalNetworks is empty")
+	}
+
+	ws.Spec.AdditionalNetworks = []string{"macvlan-conf", "infra/sriov-net"}
+	pod, err = BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	if got := pod.Annotations[MultusNetworksAnnotation]; got != "macvlan-conf,infra/sriov-net" {
+		t.Errorf("%s = %q, want %q", MultusNetworksAnnotation, got, "macvlan-conf,infra/sriov-net")
+	}
+}
+
+func TestValidateSpec_DataEgress(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		rule    workspacev1alpha1.DataEgressRule
+		wantErr bool
+	}{
+		{name: "postgres", rule: workspacev1alpha1.DataEgressRule{Namespace: "data", Ports: []int32{5432}}},
+		{name: "missing namespace", rule: workspacev1alpha1.DataEgressRule{Ports: []int32{5432}}, wantErr: true},
+		{name: "no ports", rule: workspacev1alpha1.DataEgressRule{Namespace: "data"}, wantErr: true},
+		{name: "port out of range", rule: workspacev1alpha1.DataEgressRule{Namespace: "data", Ports: []int32{70000}}, wantErr: true},
+	} {
+		ws := minimalWorkspace()
+		ws.Spec.DataEgress = []workspacev1alpha1.DataEgressRule{tc.rule}
+		if err := ValidateSpec(ws); (err != nil) != tc.wantErr {
+			t.Errorf("%s: ValidateSpec err = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
+	}
+}
+
+func TestValidateSpec_DependsOnSelf(t *testing.T) {
+	ws := minimalWorkspace()
+	ws.Spec.DependsOn = []string{ws.Name}
+	if err := ValidateSpec(ws); err == nil {
+		t.Error("expected error when a workspace depends on itself")
+	}
+}
+
+func TestValidateSpec_AdditionalNetworks(t *testing.T) {
+	for _, tc := range []struct {
+		networks []string
+		wantErr  bool
+	}{
+		{networks: []string{"macvlan-conf"}},
+		{networks: []string{"infra/sriov-net"}},
+		{networks: []string{"Bad_Name"}, wantErr: true},
+		{networks: []string{"a/b/c"}, wantErr: true},
+		{networks: []string{""}, wantErr: true},
+	} {
+		ws := minimalWorkspace()
+		ws.Spec.AdditionalNetworks = tc.networks
+		err := ValidateSpec(ws)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ValidateSpec(%v) err = %v, wantErr %v", tc.networks, err, tc.wantErr)
+		}
+	}
+}
+
+func TestBuildEnvVars_Repo(t *testing.T) {
+	ws := minimalWorkspace()
+	for _, e := range buildEnvVars(ws) {
+		if strings.HasPrefix(e.Name, "GIT_REPO_") {
+			t.Errorf("unexpected env %s without spec.repo", e.Name)
+		}
+	}
+
+	ws.Spec.Repo = workspacev1alpha1.RepoSpec{URL: "https://github.com/org/app.git", Ref: "main", SubPath: "src/app"}
+	envMap := make(map[string]string)
+	for _, e := range buildEnvVars(ws) {
+		envMap[e.Name] = e.Value
+	}
+	if envMap["GIT_REPO_URL"] != "https://github.com/org/app.git" || envMap["GIT_REPO_REF"] != "main" || envMap["GIT_REPO_SUBPATH"] != "src/app" {
+		t.Errorf("GIT_REPO_URL=%q GIT_REPO_REF=%q GIT_REPO_SUBPATH=%q", envMap["GIT_REPO_URL"], envMap["GIT_REPO_REF"], envMap["GIT_REPO_SUBPATH"])
+	}
+}
+
+func TestBuildEnvVars_FeatureFlags(t *testing.T) {
+	ws := minimalWorkspace()
+	ws.Spec.FeatureFlags = map[string]string{"terminal.splitPanes": "true", "new-prompt": "beta"}
+	var raw string
+	for _, e := range buildEnvVars(ws) {
+		if e.Name == "FEATURE_FLAGS_JSON" {
+			raw = e.Value
+		}
+	}
+	var got map[string]string
+	if err := json.Unmarshal([]byte(raw), &got); err != nil {
+		t.Fatalf("FEATURE_FLAGS_JSON %q is not valid JSON: %v", raw, err)
+	}
+	if !maps.Equal(got, ws.Spec.FeatureFlags) {
+		t.Errorf("FEATURE_FLAGS_JSON = %v, want %v", got, ws.Spec.FeatureFlags)
+	}
+}
+
+func TestBuildEnvVars_EnvSources(t *testing.T) {
+	ws := minimalWorkspace()
+	ws.Spec.Env = []workspacev1alpha1.EnvSource{
+		{Name: "GIT_AUTHOR_NAME", Value: "John"},
+		{Name: "OPENAI_API_KEY", SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "john-keys"}, Key: "openai",
+		}},
+		{Name: "GIT_CONFIG_GLOBAL", ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "john-git-config"}, Key: "path",
+		}},
+	}
+	pod, err := BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	env := map[string]corev1.EnvVar{}
+	for _, e := range pod.Spec.Containers[0].Env {
+		env[e.Name] = e
 	}
-	psc := pod.Spec.SecurityContext
-	if psc == nil {
-		t.Fatal("PodSecurityContext is nil")
+	if got := env["GIT_AUTHOR_NAME"]; got.Value != "John" || got.ValueFrom != nil {
+		t.Errorf("GIT_AUTHOR_NAME = %+v, want literal John", got)
 	}
-	if psc.RunAsNonRoot == nil || !*psc.RunAsNonRoot {
-		t.Error("PodSecurityContext.RunAsNonRoot expected true")
+	secret := env["OPENAI_API_KEY"]
+	if secret.Value != "" || secret.ValueFrom == nil || secret.ValueFrom.SecretKeyRef == nil {
+		t.Fatalf("OPENAI_API_KEY = %+v, want a secretKeyRef", secret)
 	}
-	if psc.RunAsUser == nil || *psc.RunAsUser != 1000 {
-		t.Error("PodSecurityContext.RunAsUser expected 1000")
+	if ref := secret.ValueFrom.SecretKeyRef; ref.Name != "john-keys" || ref.Key != "openai" {
+		t.Errorf("OPENAI_API_KEY secretKeyRef = %s/%s, want john-keys/openai", ref.Name, ref.Key)
 	}
-	if len(pod.Spec.Containers) != 1 {
-		t.Fatalf("expected one container, got %d", len(pod.Spec.Containers))
+	if secret.ValueFrom.SecretKeyRef == ws.Spec.Env[1].SecretKeyRef {
+		t.Error("secretKeyRef must be copied, not shared with the Workspace spec")
 	}
-	c := &pod.Spec.Containers[0]
-	if c.Image != "workspace:0.0.1" {
-		t.Errorf("container image = %q, want workspace:0.0.1", c.Image)
+	cm := env["GIT_CONFIG_GLOBAL"]
+	if cm.ValueFrom == nil || cm.ValueFrom.ConfigMapKeyRef == nil || cm.ValueFrom.ConfigMapKeyRef.Name != "john-git-config" {
+		t.Errorf("GIT_CONFIG_GLOBAL = %+v, want a configMapKeyRef to john-git-config", cm)
 	}
-	if c.SecurityContext == nil {
-		t.Fatal("container SecurityContext is nil")
+}
+
+func TestValidateSpec_Env(t *testing.T) {
+	secretRef := &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "john-keys"}, Key: "token"}
+	cmRef := &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "john-cfg"}, Key: "k"}
+	for _, tc := range []struct {
+		name    string
+		env     []workspacev1alpha1.EnvSource
+		wantErr bool
+	}{
+		{name: "literal", env: []workspacev1alpha1.EnvSource{{Name: "EDITOR", Value: "vim"}}},
+		{name: "secret", env: []workspacev1alpha1.EnvSource{{Name: "TOKEN", SecretKeyRef: secretRef}}},
+		{name: "config map", env: []workspacev1alpha1.EnvSource{{Name: "CFG", ConfigMapKeyRef: cmRef}}},
+		{name: "no source", env: []workspacev1alpha1.EnvSource{{Name: "EMPTY"}}, wantErr: true},
+		{name: "two sources", env: []workspacev1alpha1.EnvSource{{Name: "TOKEN", Value: "x", SecretKeyRef: secretRef}}, wantErr: true},
+		{name: "secret without key", env: []workspacev1alpha1.EnvSource{{Name: "TOKEN", SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "john-keys"},
+		}}}, wantErr: true},
+		{name: "other user's secret", env: []workspacev1alpha1.EnvSource{{Name: "TOKEN", SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "alice-keys"}, Key: "token",
+		}}}, wantErr: true},
+		{name: "unprefixed config map", env: []workspacev1alpha1.EnvSource{{Name: "CFG", ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "john"}, Key: "k",
+		}}}, wantErr: true},
+		{name: "invalid name", env: []workspacev1alpha1.EnvSource{{Name: "A=B", Value: "x"}}, wantErr: true},
+		{name: "operator name", env: []workspacev1alpha1.EnvSource{{Name: "USER_ID", Value: "mallory"}}, wantErr: true},
+		{name: "duplicate", env: []workspacev1alpha1.EnvSource{{Name: "X", Value: "1"}, {Name: "X", Value: "2"}}, wantErr: true},
+	} {
+		ws := minimalWorkspace()
+		ws.Spec.Env = tc.env
+		err := ValidateSpec(ws)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
 	}
-	if c.SecurityContext.ReadOnlyRootFilesystem == nil || !*c.SecurityContext.ReadOnlyRootFilesystem {
-		t.Error("container SecurityContext.ReadOnlyRootFilesystem expected true")
+}
+
+func TestValidateSpec_FeatureFlags(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		flags   map[string]string
+		wantErr bool
+	}{
+		{name: "valid", flags: map[string]string{"terminal.splitPanes": "true", "new_prompt-v2": "on"}},
+		{name: "shell metacharacters in key", flags: map[string]string{"a;rm -rf": "x"}, wantErr: true},
+		{name: "empty key", flags: map[string]string{"": "x"}, wantErr: true},
+		{name: "value too large", flags: map[string]string{"big": strings.Repeat("x", maxFeatureFlagsBytes)}, wantErr: true},
+	} {
+		ws := minimalWorkspace()
+		ws.Spec.FeatureFlags = tc.flags
+		err := ValidateSpec(ws)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tc.name, err, tc.wantErr)
+		}
 	}
-	if c.SecurityContext.AllowPrivilegeEscalation == nil || *c.SecurityContext.AllowPrivilegeEscalation {
-		t.Error("container SecurityContext.AllowPrivilegeEscalation expected false")
+	ws := minimalWorkspace()
+	ws.Spec.FeatureFlags = make(map[string]string, maxFeatureFlags+1)
+	for i := range maxFeatureFlags + 1 {
+		ws.Spec.FeatureFlags[fmt.Sprintf("flag%d", i)] = "on"
 	}
-	foundWorkspaceMount := false
-	for _, vm := range c.VolumeMounts {
-		if vm.MountPath == workspaceMount {
-			foundWorkspaceMount = true
+	if err := ValidateSpec(ws); err == nil {
+		t.Error("expected error for too many feature flags")
+	}
+}
+
+func TestValidateSpec_Scratch(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		scratch workspacev1alpha1.ScratchSpec
+		wantErr bool
+	}{
+		{name: "disabled"},
+		{name: "default mount", scratch: workspacev1alpha1.ScratchSpec{SizeLimit: "50Gi"}},
+		{name: "custom mount", scratch: workspacev1alpha1.ScratchSpec{SizeLimit: "50Gi", MountPath: "/build"}},
+		{name: "invalid size", scratch: workspacev1alpha1.ScratchSpec{SizeLimit: "lots"}, wantErr: true},
+		{name: "zero size", scratch: workspacev1alpha1.ScratchSpec{SizeLimit: "0"}, wantErr: true},
+		{name: "relative mount", scratch: workspacev1alpha1.ScratchSpec{SizeLimit: "1Gi", MountPath: "scratch"}, wantErr: true},
+		{name: "unclean mount", scratch: workspacev1alpha1.ScratchSpec{SizeLimit: "1Gi", MountPath: "/scratch/../tmp"}, wantErr: true},
+		{name: "shadows workspace", scratch: workspacev1alpha1.ScratchSpec{SizeLimit: "1Gi", MountPath: "/workspace"}, wantErr: true},
+		{name: "shadows tmp", scratch: workspacev1alpha1.ScratchSpec{SizeLimit: "1Gi", MountPath: "/tmp"}, wantErr: true},
+	} {
+		ws := minimalWorkspace()
+		ws.Spec.Scratch = tc.scratch
+		err := ValidateSpec(ws)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tc.name, err, tc.wantErr)
 		}
 	}
-	if !foundWorkspaceMount {
-		t.Errorf("VolumeMounts = %v, want a mount at %s", c.VolumeMounts, workspaceMount)
+}
+
+func TestValidateSpec_Port(t *testing.T) {
+	for _, tc := range []struct {
+		port    int32
+		wantErr bool
+	}{
+		{port: 0},
+		{port: 1024},
+		{port: 8080},
+		{port: 65535},
+		{port: 80, wantErr: true},
+		{port: -1, wantErr: true},
+		{port: 70000, wantErr: true},
+	} {
+		ws := minimalWorkspace()
+		ws.Spec.Port = tc.port
+		err := ValidateSpec(ws)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("port %d: err = %v, wantErr %v", tc.port, err, tc.wantErr)
+		}
 	}
-	envMap := make(map[string]string)
-	for _, e := range c.Env {
-		envMap[e.Name] = e.Value
+}
+
+func TestValidateSpec_Repo(t *testing.T) {
+	for _, tc := range []struct {
+		repo    workspacev1alpha1.RepoSpec
+		wantErr bool
+	}{
+		{repo: workspacev1alpha1.RepoSpec{}},
+		{repo: workspacev1alpha1.RepoSpec{URL: "https://github.com/org/app.git", Ref: "release/1.2"}},
+		{repo: workspacev1alpha1.RepoSpec{URL: "ssh://git@gitlab.internal:2222/team/app.git"}},
+		{repo: workspacev1alpha1.RepoSpec{URL: "git@github.com:org/app.git", SubPath: "code/app"}},
+		{repo: workspacev1alpha1.RepoSpec{URL: "file:///etc"}, wantErr: true},
+		{repo: workspacev1alpha1.RepoSpec{URL: "https://github.com"}, wantErr: true},
+		{repo: workspacev1alpha1.RepoSpec{URL: "not a url"}, wantErr: true},
+		{repo: workspacev1alpha1.RepoSpec{URL: "https://github.com/org/app.git", Ref: "--upload-pack=x"}, wantErr: true},
+		{repo: workspacev1alpha1.RepoSpec{URL: "https://github.com/org/app.git", SubPath: "../escape"}, wantErr: true},
+		{repo: workspacev1alpha1.RepoSpec{URL: "https://github.com/org/app.git", SubPath: "/abs"}, wantErr: true},
+		{repo: workspacev1alpha1.RepoSpec{Ref: "main"}, wantErr: true},
+	} {
+		ws := minimalWorkspace()
+		ws.Spec.Repo = tc.repo
+		err := ValidateSpec(ws)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("ValidateSpec(%+v) err = %v, wantErr %v", tc.repo, err, tc.wantErr)
+		}
 	}
-	for _, name := range []string{"AI_PROVIDERS_JSON", "USER_EMAIL", "USER_ID"} {
-		if _, ok := envMap[name]; !ok {
-			t.Errorf("missing env %q", name)
+}
+
+func TestBuildPod_PreStopHook(t *testing.T) {
+	ws := minimalWorkspace()
+	pod, err := BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	if pod.Spec.Containers[0].Lifecycle != nil {
+		t.Errorf("Lifecycle = %+v, want nil without spec.lifecycle.preStopExec", pod.Spec.Containers[0].Lifecycle)
+	}
+
+	ws.Spec.Lifecycle.PreStopExec = []string{"/bin/sh", "-c", "tmux kill-server"}
+	pod, err = BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	lc := pod.Spec.Containers[0].Lifecycle
+	if lc == nil || lc.PreStop == nil || lc.PreStop.Exec == nil {
+		t.Fatalf("Lifecycle = %+v, want a preStop exec hook", lc)
+	}
+	if !slices.Equal(lc.PreStop.Exec.Command, ws.Spec.Lifecycle.PreStopExec) {
+		t.Errorf("preStop command = %v, want %v", lc.PreStop.Exec.Command, ws.Spec.Lifecycle.PreStopExec)
+	}
+}
+
+func TestBuildPod_RuntimeClassName(t *testing.T) {
+	ws := minimalWorkspace()
+	pod, err := BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	if pod.Spec.RuntimeClassName != nil {
+		t.Errorf("RuntimeClassName = %q, want nil by default", *pod.Spec.RuntimeClassName)
+	}
+
+	pod, err = BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{RuntimeClassName: "gvisor"})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	if pod.Spec.RuntimeClassName == nil || *pod.Spec.RuntimeClassName != "gvisor" {
+		t.Errorf("RuntimeClassName = %v, want operator default gvisor", pod.Spec.RuntimeClassName)
+	}
+
+	ws.Spec.RuntimeClassName = "kata"
+	pod, err = BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{RuntimeClassName: "gvisor"})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	if pod.Spec.RuntimeClassName == nil || *pod.Spec.RuntimeClassName != "kata" {
+		t.Errorf("RuntimeClassName = %v, want spec override kata", pod.Spec.RuntimeClassName)
+	}
+}
+
+func TestValidateSpec_RuntimeClassName(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "gvisor"},
+		{name: "kata-qemu"},
+		{name: "nvidia.runtime.example.com"},
+		{name: "Kata", wantErr: true},
+		{name: "ns/kata", wantErr: true},
+		{name: "kata_fc", wantErr: true},
+	}
+	for _, tt := range tests {
+		ws := minimalWorkspace()
+		ws.Spec.RuntimeClassName = tt.name
+		if err := ValidateSpec(ws); (err != nil) != tt.wantErr {
+			t.Errorf("runtimeClassName %q: ValidateSpec() error = %v, wantErr %v", tt.name, err, tt.wantErr)
 		}
 	}
-	if envMap["USER_ID"] != "john" || envMap["USER_EMAIL"] != "john@example.com" {
-		t.Errorf("USER_ID=%q USER_EMAIL=%q", envMap["USER_ID"], envMap["USER_EMAIL"])
+}
+
+func TestBuildPod_SchedulerName(t *testing.T) {
+	ws := minimalWorkspace()
+	pod, err := BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
 	}
-	// Verify AI_PROVIDERS_JSON round-trips correctly.
-	var providers []workspacev1alpha1.AIProvider
-	if err := json.Unmarshal([]byte(envMap["AI_PROVIDERS_JSON"]), &providers); err != nil {
-		t.Fatalf("AI_PROVIDERS_JSON is not valid JSON: %v", err)
+	if pod.Spec.SchedulerName != "" {
+		t.Errorf("SchedulerName = %q, want empty by default", pod.Spec.SchedulerName)
 	}
-	if len(providers) != 1 || providers[0].Name != "local" || providers[0].Endpoint != "http://vllm:8000" {
-		t.Errorf("AI_PROVIDERS_JSON providers = %+v", providers)
+
+	ws.Spec.SchedulerName = "volcano"
+	pod, err = BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
 	}
-	if len(providers[0].Models) != 1 || providers[0].Models[0] != "model" {
-		t.Errorf("AI_PROVIDERS_JSON models = %v", providers[0].Models)
+	if pod.Spec.SchedulerName != "volcano" {
+		t.Errorf("SchedulerName = %q, want volcano", pod.Spec.SchedulerName)
 	}
-	if len(pod.OwnerReferences) != 1 || pod.OwnerReferences[0].Kind != "Workspace" {
-		t.Errorf("expected Workspace owner reference, got %v", pod.OwnerReferences)
+}
+
+func TestBuildPod_DefaultPodAnnotations(t *testing.T) {
+	opts := BuildOpts{DefaultPodAnnotations: map[string]string{
+		"sidecar.istio.io/inject": "false",
+		"example.com/team":        "platform",
+	}}
+
+	ws := minimalWorkspace()
+	pod, err := BuildPod(ws, "john-workspace-pvc", "workspace:test", scheme, opts)
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
 	}
-	if pod.Spec.ServiceAccountName != "john-workspace" {
-		t.Errorf("pod.Spec.ServiceAccountName = %q, want john-workspace", pod.Spec.ServiceAccountName)
+	if got := pod.Annotations["sidecar.istio.io/inject"]; got != "false" {
+		t.Errorf("sidecar.istio.io/inject = %q, want operator default false", got)
+	}
+
+	ws.Spec.PodAnnotations = map[string]string{"sidecar.istio.io/inject": "true"}
+	pod, err = BuildPod(ws, "john-workspace-pvc", "workspace:test", scheme, opts)
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	if got := pod.Annotations["sidecar.istio.io/inject"]; got != "true" {
+		t.Errorf("sidecar.istio.io/inject = %q, want per-workspace override true", got)
+	}
+	if got := pod.Annotations["example.com/team"]; got != "platform" {
+		t.Errorf("example.com/team = %q, want untouched default", got)
+	}
+	if opts.DefaultPodAnnotations["sidecar.istio.io/inject"] != "false" {
+		t.Error("BuildPod must not modify the operator defaults")
+	}
+}
+
+func TestValidateSpec_PodAnnotations(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantErr     bool
+	}{
+		{name: "valid", annotations: map[string]string{"sidecar.istio.io/inject": "false", "Team": "platform"}},
+		{name: "space in key", annotations: map[string]string{"bad key": "x"}, wantErr: true},
+		{name: "empty prefix", annotations: map[string]string{"/inject": "x"}, wantErr: true},
+		{name: "too large", annotations: map[string]string{"big": strings.Repeat("x", 256*1024)}, wantErr: true},
+	}
+	for _, tt := range tests {
+		ws := minimalWorkspace()
+		ws.Spec.PodAnnotations = tt.annotations
+		if err := ValidateSpec(ws); (err != nil) != tt.wantErr {
+			t.Errorf("%s: ValidateSpec() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestBuildPod_DownwardAPIVolume(t *testing.T) {
+	ws := minimalWorkspace()
+	pod, err := BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	for _, v := range pod.Spec.Volumes {
+		if v.DownwardAPI != nil {
+			t.Fatalf("unexpected downward-API volume %q without DownwardAPIPath", v.Name)
+		}
+	}
+
+	pod, err = BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{DownwardAPIPath: "/etc/devplane/pod"})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	var vol *corev1.Volume
+	for i := range pod.Spec.Volumes {
+		if pod.Spec.Volumes[i].Name == "pod-info" {
+			vol = &pod.Spec.Volumes[i]
+		}
+	}
+	if vol == nil || vol.DownwardAPI == nil {
+		t.Fatalf("pod-info downward-API volume missing: %+v", pod.Spec.Volumes)
+	}
+	want := map[string]string{
+		"pod-name":      "metadata.name",
+		"pod-namespace": "metadata.namespace",
+		"user":          "metadata.labels['user']",
+	}
+	if len(vol.DownwardAPI.Items) != len(want) {
+		t.Errorf("items = %+v, want %d", vol.DownwardAPI.Items, len(want))
+	}
+	for _, item := range vol.DownwardAPI.Items {
+		if item.FieldRef == nil || want[item.Path] != item.FieldRef.FieldPath {
+			t.Errorf("item %q = %+v, want fieldPath %q", item.Path, item.FieldRef, want[item.Path])
+		}
+	}
+	var mounted bool
+	for _, m := range pod.Spec.Containers[0].VolumeMounts {
+		if m.Name == "pod-info" {
+			mounted = m.MountPath == "/etc/devplane/pod" && m.ReadOnly
+		}
+	}
+	if !mounted {
+		t.Errorf("pod-info not mounted read-only at /etc/devplane/pod: %+v", pod.Spec.Containers[0].VolumeMounts)
+	}
+}
+
+func TestConfigMapDataHash(t *testing.T) {
+	a := &corev1.ConfigMap{Data: map[string]string{"a.crt": "one", "b.crt": "two"}}
+	b := &corev1.ConfigMap{Data: map[string]string{"b.crt": "two", "a.crt": "one"}}
+	if ConfigMapDataHash(a) != ConfigMapDataHash(b) {
+		t.Error("hash must not depend on key order")
+	}
+	c := &corev1.ConfigMap{Data: map[string]string{"a.crt": "one", "b.crt": "three"}}
+	if ConfigMapDataHash(a) == ConfigMapDataHash(c) {
+		t.Error("hash must change when data changes")
+	}
+}
+
+func TestBuildPod_CABundleHashAnnotation(t *testing.T) {
+	ws := minimalWorkspace()
+	pod, err := BuildPod(ws, "john-workspace-pvc", "workspace:0.0.1", scheme, BuildOpts{DefaultCABundle: "corp-ca", CABundleHash: "abc123"})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	if got := pod.Annotations[CABundleHashAnnotation]; got != "abc123" {
+		t.Errorf("%s = %q, want abc123", CABundleHashAnnotation, got)
 	}
 }
 
@@ -287,8 +1693,8 @@ func TestBuildHeadlessService(t *testing.T) {
 		t.Fatalf("Ports len = %d, want 1", len(svc.Spec.Ports))
 	}
 	p := svc.Spec.Ports[0]
-	if p.Port != ttydPort {
-		t.Errorf("Port = %d, want %d", p.Port, ttydPort)
+	if p.Port != DefaultTTYDPort {
+		t.Errorf("Port = %d, want %d", p.Port, DefaultTTYDPort)
 	}
 	if p.Protocol != corev1.ProtocolTCP {
 		t.Errorf("Protocol = %q, want TCP", p.Protocol)
@@ -301,6 +1707,164 @@ func TestBuildHeadlessService(t *testing.T) {
 	}
 }
 
+func TestBuildHeadlessService_SelectsByCoreLabels(t *testing.T) {
+	ws := minimalWorkspace()
+	svc, err := BuildHeadlessService(ws, scheme)
+	if err != nil {
+		t.Fatalf("BuildHeadlessService: %v", err)
+	}
+	if !maps.Equal(svc.Spec.Selector, SelectorLabels("john")) {
+		t.Errorf("Selector = %v, want only core labels %v", svc.Spec.Selector, SelectorLabels("john"))
+	}
+
+	pod, err := BuildPod(ws, PVCName("john"), "img", scheme, BuildOpts{})
+	if err != nil {
+		t.Fatalf("BuildPod: %v", err)
+	}
+	pod.Labels["team"] = "platform"
+	pod.Labels["managed-by"] = "someone-else"
+	if !labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+		t.Errorf("Selector %v no longer matches pod labels %v", svc.Spec.Selector, pod.Labels)
+	}
+}
+
+func TestBuildServiceAnnotations_ExternalDNS(t *testing.T) {
+	tests := []struct {
+		name      string
+		template  string
+		overrides map[string]string
+		want      map[string]string
+	}{
+		{name: "disabled", want: nil},
+		{
+			name:     "user host",
+			template: "{user}.ws.example.com",
+			want:     map[string]string{ExternalDNSHostnameAnnotation: "john.ws.example.com"},
+		},
+		{
+			name:     "user and namespace",
+			template: "{user}.{namespace}.ws.example.com",
+			want:     map[string]string{ExternalDNSHostnameAnnotation: "john.default.ws.example.com"},
+		},
+		{
+			name:      "spec cannot claim a DNS name",
+			template:  "{user}.ws.example.com",
+			overrides: map[string]string{ExternalDNSHostnameAnnotation: "pairing.example.com", "external-dns.alpha.kubernetes.io/ttl": "60", "team": "infra"},
+			want: map[string]string{
+				ExternalDNSHostnameAnnotation: "john.ws.example.com",
+				"team":                        "infra",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := minimalWorkspace()
+			ws.Spec.ServiceAnnotations = tt.overrides
+			got := BuildServiceAnnotations(ws, tt.template)
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("annotations = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyManagedAnnotations(t *testing.T) {
+	current := map[string]string{"kubectl.kubernetes.io/restartedAt": "now"}
+	current = ApplyManagedAnnotations(current, map[string]string{"team": "infra", ExternalDNSHostnameAnnotation: "john.ws.example.com"})
+	want := map[string]string{
+		"kubectl.kubernetes.io/restartedAt": "now",
+		"team":                              "infra",
+		ExternalDNSHostnameAnnotation:       "john.ws.example.com",
+		ManagedAnnotationsAnnotation:        ExternalDNSHostnameAnnotation + ",team",
+	}
+	if !maps.Equal(current, want) {
+		t.Fatalf("first apply = %v, want %v", current, want)
+	}
+
+	current = ApplyManagedAnnotations(current, map[string]string{"team": "platform"})
+	want = map[string]string{
+		"kubectl.kubernetes.io/restartedAt": "now",
+		"team":                              "platform",
+		ManagedAnnotationsAnnotation:        "team",
+	}
+	if !maps.Equal(current, want) {
+		t.Errorf("after dropping the hostname = %v, want %v", current, want)
+	}
+
+	if got := ApplyManagedAnnotations(map[string]string{"team": "platform", ManagedAnnotationsAnnotation: "team"}, nil); got != nil {
+		t.Errorf("removing every managed key = %v, want nil", got)
+	}
+}
+
+func TestValidateSpec_ServiceAnnotations(t *testing.T) {
+	ws := minimalWorkspace()
+	ws.Spec.ServiceAnnotations = map[string]string{"team": "infra"}
+	if err := ValidateSpec(ws); err != nil {
+		t.Errorf("plain annotation: ValidateSpec err = %v", err)
+	}
+	ws.Spec.ServiceAnnotations = map[string]string{ExternalDNSHostnameAnnotation: "bank.example.com"}
+	if err := ValidateSpec(ws); err == nil || !strings.Contains(err.Error(), "reserved") {
+		t.Errorf("external-dns hostname: ValidateSpec err = %v, want reserved-key error", err)
+	}
+}
+
+func TestResourceLabels(t *testing.T) {
+	tests := []struct {
+		name      string
+		templates map[string]string
+		wsLabels  map[string]string
+		want      map[string]string
+	}{
+		{name: "no templates", want: Labels("john")},
+		{
+			name:      "user and namespace",
+			templates: map[string]string{"cost-center": "{namespace}", "owner": "{user}"},
+			want:      withLabels(Labels("john"), map[string]string{"cost-center": "default", "owner": "john"}),
+		},
+		{
+			name:      "workspace label",
+			templates: map[string]string{"cost-center": "cc-{label:team}"},
+			wsLabels:  map[string]string{"team": "platform"},
+			want:      withLabels(Labels("john"), map[string]string{"cost-center": "cc-platform"}),
+		},
+		{
+			name:      "missing workspace label is skipped",
+			templates: map[string]string{"cost-center": "{label:team}"},
+			want:      Labels("john"),
+		},
+		{
+			name:      "core labels win",
+			templates: map[string]string{"app": "billing", "user": "someone-else"},
+			want:      Labels("john"),
+		},
+		{
+			name:      "invalid value is skipped",
+			templates: map[string]string{"cost-center": "not a label value"},
+			want:      Labels("john"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := minimalWorkspace()
+			ws.Labels = tt.wsLabels
+			base := Labels("john")
+			got := ResourceLabels(base, ws, tt.templates)
+			if !maps.Equal(got, tt.want) {
+				t.Errorf("labels = %v, want %v", got, tt.want)
+			}
+			if !maps.Equal(base, Labels("john")) {
+				t.Errorf("base modified: %v", base)
+			}
+		})
+	}
+}
+
+// withLabels returns base with extra merged in.
+func withLabels(base, extra map[string]string) map[string]string {
+	maps.Copy(base, extra)
+	return base
+}
+
 func TestValidateSpec(t *testing.T) {
 	valid := minimalWorkspace()
 	if err := ValidateSpec(valid); err != nil {
@@ -445,6 +2009,44 @@ func TestValidateSpec_InvalidCPUQuantity(t *testing.T) {
 	}
 }
 
+func TestValidateSpec_InvalidCPUBurst(t *testing.T) {
+	for _, burst := range []string{"0.5", "abc", "9"} {
+		ws := minimalWorkspace()
+		ws.Spec.Resources.CPUBurst = burst
+		if err := ValidateSpec(ws); err == nil {
+			t.Errorf("ValidateSpec: expected error for cpuBurst %q", burst)
+		}
+	}
+}
+
+func TestValidateSpec_ExecReadinessRequiresCommand(t *testing.T) {
+	ws := minimalWorkspace()
+	ws.Spec.Readiness.Type = workspacev1alpha1.ReadinessProbeExec
+	if err := ValidateSpec(ws); err == nil {
+		t.Error("ValidateSpec: expected error for Exec readiness without command")
+	}
+	ws.Spec.Readiness.Command = []string{"/bin/ready"}
+	if err := ValidateSpec(ws); err != nil {
+		t.Errorf("ValidateSpec: unexpected error: %v", err)
+	}
+}
+
+func TestValidateSpec_PreStopExecRequiresCommand(t *testing.T) {
+	ws := minimalWorkspace()
+	ws.Spec.Lifecycle.PreStopExec = []string{}
+	if err := ValidateSpec(ws); err == nil {
+		t.Error("ValidateSpec: expected error for empty preStopExec")
+	}
+	ws.Spec.Lifecycle.PreStopExec = []string{" "}
+	if err := ValidateSpec(ws); err == nil {
+		t.Error("ValidateSpec: expected error for blank preStopExec command")
+	}
+	ws.Spec.Lifecycle.PreStopExec = []string{"tmux", "kill-server"}
+	if err := ValidateSpec(ws); err != nil {
+		t.Errorf("ValidateSpec: unexpected error: %v", err)
+	}
+}
+
 func TestValidateSpec_InvalidMemoryQuantity(t *testing.T) {
 	ws := minimalWorkspace()
 	ws.Spec.Resources.Memory = "not-a-quantity"
@@ -461,6 +2063,29 @@ func TestValidateSpec_InvalidLifecycleIdleTimeout(t *testing.T) {
 	}
 }
 
+func TestValidateSpec_NonPositiveValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*workspacev1alpha1.Workspace)
+	}{
+		{"zero storage", func(ws *workspacev1alpha1.Workspace) { ws.Spec.Resources.Storage = "0" }},
+		{"negative storage", func(ws *workspacev1alpha1.Workspace) { ws.Spec.Resources.Storage = "-10Gi" }},
+		{"zero cpu", func(ws *workspacev1alpha1.Workspace) { ws.Spec.Resources.CPU = "0" }},
+		{"negative memory", func(ws *workspacev1alpha1.Workspace) { ws.Spec.Resources.Memory = "-1Gi" }},
+		{"negative idle timeout", func(ws *workspacev1alpha1.Workspace) { ws.Spec.Lifecycle.IdleTimeout = "-5m" }},
+		{"zero replicas", func(ws *workspacev1alpha1.Workspace) { ws.Spec.Replicas = ptr(int32(0)) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ws := minimalWorkspace()
+			tt.mutate(ws)
+			if err := ValidateSpec(ws); err == nil {
+				t.Error("ValidateSpec: expected error")
+			}
+		})
+	}
+}
+
 func TestValidateSpec_InvalidStorageQuantity(t *testing.T) {
 	ws := minimalWorkspace()
 	ws.Spec.Resources.Storage = "not-a-quantity"
@@ -469,6 +2094,38 @@ func TestValidateSpec_InvalidStorageQuantity(t *testing.T) {
 	}
 }
 
+func TestValidateMinStorage_BelowFloor(t *testing.T) {
+	ws := minimalWorkspace()
+	ws.Spec.Resources.Storage = "1Mi"
+	err := ValidateMinStorage(ws, resource.MustParse("1Gi"))
+	if err == nil {
+		t.Fatal("expected error for storage below floor")
+	}
+	if !strings.Contains(err.Error(), "below the operator minimum") {
+		t.Errorf("error = %q, want mention of operator minimum", err)
+	}
+}
+
+func TestValidateMinStorage_AtFloor(t *testing.T) {
+	ws := minimalWorkspace()
+	ws.Spec.Resources.Storage = "1Gi"
+	if err := ValidateMinStorage(ws, resource.MustParse("1Gi")); err != nil {
+		t.Errorf("storage at floor should be accepted: %v", err)
+	}
+	ws.Spec.Resources.Storage = "1024Mi"
+	if err := ValidateMinStorage(ws, resource.MustParse("1Gi")); err != nil {
+		t.Errorf("1024Mi equals 1Gi and should be accepted: %v", err)
+	}
+}
+
+func TestValidateMinStorage_ZeroFloorDisabled(t *testing.T) {
+	ws := minimalWorkspace()
+	ws.Spec.Resources.Storage = "1Mi"
+	if err := ValidateMinStorage(ws, resource.Quantity{}); err != nil {
+		t.Errorf("zero floor should disable the check: %v", err)
+	}
+}
+
 func TestBuildPVC_InvalidStorageQuantity(t *testing.T) {
 	ws := minimalWorkspace()
 	ws.Spec.Resources.Storage = "not-a-quantity"
@@ -558,11 +2215,11 @@ func TestBuildPod_SecurityContext(t *testing.T) {
 
 	t.Run("TtydContainerPort", func(t *testing.T) {
 		for _, p := range c.Ports {
-			if p.Name == "ttyd" && p.ContainerPort == ttydPort && p.Protocol == corev1.ProtocolTCP {
+			if p.Name == "ttyd" && p.ContainerPort == DefaultTTYDPort && p.Protocol == corev1.ProtocolTCP {
 				return
 			}
 		}
-		t.Errorf("container must declare port name=ttyd containerPort=%d protocol=TCP", ttydPort)
+		t.Errorf("container must declare port name=ttyd containerPort=%d protocol=TCP", DefaultTTYDPort)
 	})
 
 	t.Run("FSGroup1000", func(t *testing.T) {
@@ -1,6 +1,7 @@
 package workspace
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	workspacev1alpha1 "workspace-operator/api/v1alpha1"
@@ -9,6 +10,19 @@ import (
 // ConditionTypeReady is the Workspace status condition that mirrors readiness for use.
 const ConditionTypeReady = "Ready"
 
+// ConditionTypeCABundleValid reports whether every key of the workspace's CA
+// bundle ConfigMap parses as PEM certificates. Only set when CA bundle
+// validation is enabled on the operator and a bundle is referenced.
+const ConditionTypeCABundleValid = "CABundleValid"
+
+// ConditionTypePodScheduled mirrors the PodScheduled condition of the
+// workspace pod so scheduling problems are visible on the Workspace itself.
+const ConditionTypePodScheduled = "PodScheduled"
+
+// ConditionTypeNetworkPoliciesReady reports whether the workspace's
+// NetworkPolicies were applied on the last reconcile.
+const ConditionTypeNetworkPoliciesReady = "NetworkPoliciesReady"
+
 // StatusSummary carries observed state for a single status patch.
 type StatusSummary struct {
 	Phase           workspacev1alpha1.WorkspacePhase
@@ -31,9 +45,68 @@ func ApplyStatusSummary(ws *workspacev1alpha1.Workspace, sum StatusSummary) {
 	ws.Status.Phase = sum.Phase
 	ws.Status.PodName = sum.PodName
 	ws.Status.ServiceEndpoint = sum.ServiceEndpoint
+	ws.Status.Port = 0
+	if sum.ServiceEndpoint != "" {
+		ws.Status.Port = TTYDPort(ws)
+	}
 	ws.Status.Message = msg
 	ws.Status.RemediationHint = sum.RemediationHint
 	syncReadyCondition(ws, sum, msg)
+	if sum.Phase == workspacev1alpha1.WorkspacePhaseStopped {
+		meta.RemoveStatusCondition(&ws.Status.Conditions, ConditionTypePodScheduled)
+	}
+}
+
+// PodScheduledCondition mirrors pod's PodScheduled condition for the
+// Workspace. A pod the scheduler has not looked at yet reports False with
+// reason PodPending.
+func PodScheduledCondition(pod *corev1.Pod) metav1.Condition {
+	cond := metav1.Condition{
+		Type:    ConditionTypePodScheduled,
+		Status:  metav1.ConditionFalse,
+		Reason:  ReasonPodPending,
+		Message: "Workspace pod has not been scheduled yet.",
+	}
+	for _, c := range pod.Status.Conditions {
+		if c.Type != corev1.PodScheduled {
+			continue
+		}
+		cond.Status = metav1.ConditionStatus(c.Status)
+		cond.Message = c.Message
+		switch {
+		case c.Reason != "":
+			cond.Reason = c.Reason
+		case c.Status == corev1.ConditionTrue:
+			cond.Reason = ReasonScheduled
+		}
+		if cond.Message == "" && c.Status == corev1.ConditionTrue {
+			cond.Message = "Workspace pod is scheduled."
+			if pod.Spec.NodeName != "" {
+				cond.Message = "Workspace pod is scheduled on node " + pod.Spec.NodeName + "."
+			}
+		}
+		break
+	}
+	return cond
+}
+
+// NetworkPoliciesCondition reports the outcome of applying the workspace's
+// NetworkPolicies; err is the error returned while ensuring them, if any.
+func NetworkPoliciesCondition(err error) metav1.Condition {
+	if err != nil {
+		return metav1.Condition{
+			Type:    ConditionTypeNetworkPoliciesReady,
+			Status:  metav1.ConditionFalse,
+			Reason:  ReasonNetPolReconcileFailed,
+			Message: err.Error(),
+		}
+	}
+	return metav1.Condition{
+		Type:    ConditionTypeNetworkPoliciesReady,
+		Status:  metav1.ConditionTrue,
+		Reason:  ReasonNetPolApplied,
+		Message: "Deny-all, egress and ingress NetworkPolicies are applied.",
+	}
 }
 
 func syncReadyCondition(ws *workspacev1alpha1.Workspace, sum StatusSummary, message string) {
@@ -61,7 +134,7 @@ func syncReadyCondition(ws *workspacev1alpha1.Workspace, sum StatusSummary, mess
 func defaultReadyReason(phase workspacev1alpha1.WorkspacePhase) string {
 	switch phase {
 	case workspacev1alpha1.WorkspacePhaseRunning:
-		return ReasonRunning
+		return ReasonPodRunning
 	case workspacev1alpha1.WorkspacePhaseCreating:
 		return ReasonProgressing
 	case workspacev1alpha1.WorkspacePhaseFailed:
@@ -72,3 +145,15 @@ func defaultReadyReason(phase workspacev1alpha1.WorkspacePhase) string {
 		return ReasonProgressing
 	}
 }
+
+// ReconcileResultSuccess is recorded in status.lastReconcileResult when a reconcile
+// returns without error.
+const ReconcileResultSuccess = "Success"
+
+// ReconcileResult formats the outcome of a reconcile for status.lastReconcileResult.
+func ReconcileResult(err error) string {
+	if err == nil {
+		return ReconcileResultSuccess
+	}
+	return "Error: " + err.Error()
+}
@@ -1,8 +1,10 @@
 package workspace
 
 import (
+	"errors"
 	"testing"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	workspacev1alpha1 "workspace-operator/api/v1alpha1"
@@ -16,7 +18,7 @@ func TestApplyStatusSummary_ReadyConditionRunning(t *testing.T) {
 		Phase:           workspacev1alpha1.WorkspacePhaseRunning,
 		PodName:         "u-workspace-pod",
 		ServiceEndpoint: "u-workspace.default.svc.cluster.local",
-		ReadyReason:     ReasonRunning,
+		ReadyReason:     ReasonPodRunning,
 	})
 	cond := meta.FindStatusCondition(ws.Status.Conditions, ConditionTypeReady)
 	if cond == nil {
@@ -25,7 +27,7 @@ func TestApplyStatusSummary_ReadyConditionRunning(t *testing.T) {
 	if cond.Status != metav1.ConditionTrue {
 		t.Fatalf("Ready status = %q, want True", cond.Status)
 	}
-	if cond.Reason != ReasonRunning {
+	if cond.Reason != ReasonPodRunning {
 		t.Fatalf("Ready reason = %q", cond.Reason)
 	}
 	if cond.ObservedGeneration != 3 {
@@ -33,6 +35,22 @@ func TestApplyStatusSummary_ReadyConditionRunning(t *testing.T) {
 	}
 }
 
+func TestApplyStatusSummary_Port(t *testing.T) {
+	ws := &workspacev1alpha1.Workspace{}
+	ws.Spec.Port = 8080
+	ApplyStatusSummary(ws, StatusSummary{
+		Phase:           workspacev1alpha1.WorkspacePhaseRunning,
+		ServiceEndpoint: "u-workspace.default.svc.cluster.local",
+	})
+	if ws.Status.Port != 8080 {
+		t.Fatalf("Status.Port = %d, want 8080", ws.Status.Port)
+	}
+	ApplyStatusSummary(ws, StatusSummary{Phase: workspacev1alpha1.WorkspacePhaseStopped})
+	if ws.Status.Port != 0 {
+		t.Fatalf("Status.Port = %d after stop, want 0", ws.Status.Port)
+	}
+}
+
 func TestApplyStatusSummary_RemediationFailed(t *testing.T) {
 	ws := &workspacev1alpha1.Workspace{}
 	ApplyStatusSummary(ws, StatusSummary{
@@ -49,3 +67,58 @@ func TestApplyStatusSummary_RemediationFailed(t *testing.T) {
 		t.Fatalf("unexpected Ready condition: %#v", cond)
 	}
 }
+
+func TestPodScheduledCondition(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions []corev1.PodCondition
+		wantStatus metav1.ConditionStatus
+		wantReason string
+	}{
+		{name: "not yet considered", wantStatus: metav1.ConditionFalse, wantReason: ReasonPodPending},
+		{
+			name:       "scheduled",
+			conditions: []corev1.PodCondition{{Type: corev1.PodScheduled, Status: corev1.ConditionTrue}},
+			wantStatus: metav1.ConditionTrue,
+			wantReason: ReasonScheduled,
+		},
+		{
+			name: "unschedulable",
+			conditions: []corev1.PodCondition{{
+				Type:    corev1.PodScheduled,
+				Status:  corev1.ConditionFalse,
+				Reason:  corev1.PodReasonUnschedulable,
+				Message: "0/3 nodes are available: 3 Insufficient memory.",
+			}},
+			wantStatus: metav1.ConditionFalse,
+			wantReason: corev1.PodReasonUnschedulable,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1.Pod{Status: corev1.PodStatus{Conditions: tt.conditions}}
+			cond := PodScheduledCondition(pod)
+			if cond.Type != ConditionTypePodScheduled || cond.Status != tt.wantStatus || cond.Reason != tt.wantReason {
+				t.Errorf("condition = %s %s/%s, want PodScheduled %s/%s", cond.Type, cond.Status, cond.Reason, tt.wantStatus, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestApplyStatusSummary_StoppedClearsPodScheduled(t *testing.T) {
+	ws := &workspacev1alpha1.Workspace{}
+	meta.SetStatusCondition(&ws.Status.Conditions, PodScheduledCondition(&corev1.Pod{}))
+	ApplyStatusSummary(ws, StatusSummary{Phase: workspacev1alpha1.WorkspacePhaseStopped})
+	if cond := meta.FindStatusCondition(ws.Status.Conditions, ConditionTypePodScheduled); cond != nil {
+		t.Errorf("PodScheduled condition = %#v, want removed once stopped", cond)
+	}
+}
+
+func TestReconcileResult(t *testing.T) {
+	if got := ReconcileResult(nil); got != ReconcileResultSuccess {
+		t.Errorf("ReconcileResult(nil) = %q, want %q", got, ReconcileResultSuccess)
+	}
+	if got := ReconcileResult(errors.New("ensure pvc: boom")); got != "Error: ensure pvc: boom" {
+		t.Errorf("ReconcileResult(err) = %q", got)
+	}
+}
@@ -0,0 +1,74 @@
+package workspace
+
+import (
+	"cmp"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	workspacev1alpha1 "workspace-operator/api/v1alpha1"
+)
+
+// ApplyTemplate fills the fields of workspace.Spec that are left empty with the
+// values from tmpl. Fields the workspace sets always win. Slices are merged as
+// a whole: a workspace listing any provider does not inherit the template's.
+// Only the in-memory object is changed; the stored Workspace keeps its own spec.
+func ApplyTemplate(workspace *workspacev1alpha1.Workspace, tmpl *workspacev1alpha1.WorkspaceTemplateSpec) {
+	if tmpl == nil {
+		return
+	}
+	t := tmpl.DeepCopy()
+	s := &workspace.Spec
+
+	r := &s.Resources
+	r.CPU = cmp.Or(r.CPU, t.Resources.CPU)
+	r.Memory = cmp.Or(r.Memory, t.Resources.Memory)
+	r.Storage = cmp.Or(r.Storage, t.Resources.Storage)
+	r.CPUBurst = cmp.Or(r.CPUBurst, t.Resources.CPUBurst)
+	r.CPULimit = cmp.Or(r.CPULimit, t.Resources.CPULimit)
+	r.MemoryLimit = cmp.Or(r.MemoryLimit, t.Resources.MemoryLimit)
+	r.QoSClass = cmp.Or(r.QoSClass, t.Resources.QoSClass)
+	if r.GPU == nil {
+		r.GPU = t.Resources.GPU
+	}
+
+	ai := &s.AIConfig
+	if len(ai.Providers) == 0 {
+		ai.Providers = t.AIConfig.Providers
+	}
+	if len(ai.EgressNamespaces) == 0 {
+		ai.EgressNamespaces = t.AIConfig.EgressNamespaces
+	}
+	if len(ai.EgressPorts) == 0 {
+		ai.EgressPorts = t.AIConfig.EgressPorts
+	}
+	ai.Settings.Temperature = cmp.Or(ai.Settings.Temperature, t.AIConfig.Settings.Temperature)
+	ai.Settings.SystemPrompt = cmp.Or(ai.Settings.SystemPrompt, t.AIConfig.Settings.SystemPrompt)
+
+	s.RuntimeClassName = cmp.Or(s.RuntimeClassName, t.RuntimeClassName)
+	s.SchedulerName = cmp.Or(s.SchedulerName, t.SchedulerName)
+}
+
+// ValidateTemplate checks the values a WorkspaceTemplate supplies. Fields are
+// optional on their own; ValidateSpec checks the merged workspace spec.
+func ValidateTemplate(tmpl *workspacev1alpha1.WorkspaceTemplate) error {
+	r := tmpl.Spec.Resources
+	for _, q := range []struct{ field, raw string }{{"cpu", r.CPU}, {"memory", r.Memory}, {"storage", r.Storage}} {
+		if q.raw == "" {
+			continue
+		}
+		qty, err := resource.ParseQuantity(q.raw)
+		if err != nil {
+			return fmt.Errorf("workspacetemplate %q: spec.resources.%s invalid: %w", tmpl.Name, q.field, err)
+		}
+		if qty.Sign() <= 0 {
+			return fmt.Errorf("workspacetemplate %q: spec.resources.%s must be greater than zero (got %s)", tmpl.Name, q.field, q.raw)
+		}
+	}
+	for i, p := range tmpl.Spec.AIConfig.Providers {
+		if p.Name == "" || p.Endpoint == "" || len(p.Models) == 0 {
+			return fmt.Errorf("workspacetemplate %q: spec.aiConfig.providers[%d] needs a name, an endpoint and at least one model", tmpl.Name, i)
+		}
+	}
+	return nil
+}
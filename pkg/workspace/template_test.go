@@ -0,0 +1,116 @@
+package workspace
+
+import (
+	"strings"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	workspacev1alpha1 "workspace-operator/api/v1alpha1"
+)
+
+func TestApplyTemplate(t *testing.T) {
+	ws := minimalWorkspace()
+	ws.Spec.Resources = workspacev1alpha1.ResourceRequirements{Memory: "8Gi"}
+	ws.Spec.AIConfig = workspacev1alpha1.AIConfiguration{}
+	tmpl := &workspacev1alpha1.WorkspaceTemplateSpec{
+		Resources: workspacev1alpha1.ResourceRequirements{CPU: "2", Memory: "4Gi", Storage: "50Gi"},
+		AIConfig: workspacev1alpha1.AIConfiguration{
+			Providers: []workspacev1alpha1.AIProvider{
+				{Name: "shared", Endpoint: "http://vllm:8000", Models: []string{"coder"}},
+			},
+		},
+		RuntimeClassName: "gvisor",
+	}
+
+	ApplyTemplate(ws, tmpl)
+
+	r := ws.Spec.Resources
+	if r.CPU != "2" || r.Storage != "50Gi" {
+		t.Errorf("resources = %+v, want cpu and storage from the template", r)
+	}
+	if r.Memory != "8Gi" {
+		t.Errorf("memory = %q, want the workspace's own 8Gi", r.Memory)
+	}
+	if len(ws.Spec.AIConfig.Providers) != 1 || ws.Spec.AIConfig.Providers[0].Name != "shared" {
+		t.Errorf("providers = %+v, want the template's", ws.Spec.AIConfig.Providers)
+	}
+	if ws.Spec.RuntimeClassName != "gvisor" {
+		t.Errorf("runtimeClassName = %q, want gvisor", ws.Spec.RuntimeClassName)
+	}
+	if err := ValidateSpec(ws); err != nil {
+		t.Errorf("merged spec should validate: %v", err)
+	}
+
+	// The template must not alias the workspace's slices.
+	ws.Spec.AIConfig.Providers[0].Name = "changed"
+	if tmpl.AIConfig.Providers[0].Name != "shared" {
+		t.Error("ApplyTemplate shared provider slice with the template")
+	}
+}
+
+func TestApplyTemplate_WorkspaceProvidersWin(t *testing.T) {
+	ws := minimalWorkspace()
+	ApplyTemplate(ws, &workspacev1alpha1.WorkspaceTemplateSpec{
+		AIConfig: workspacev1alpha1.AIConfiguration{
+			Providers: []workspacev1alpha1.AIProvider{
+				{Name: "shared", Endpoint: "http://other:8000", Models: []string{"m"}},
+				{Name: "extra", Endpoint: "http://extra:8000", Models: []string{"m"}},
+			},
+		},
+	})
+	if len(ws.Spec.AIConfig.Providers) != 1 || ws.Spec.AIConfig.Providers[0].Name != "local" {
+		t.Errorf("providers = %+v, want only the workspace's own", ws.Spec.AIConfig.Providers)
+	}
+}
+
+func TestValidateTemplate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    workspacev1alpha1.WorkspaceTemplateSpec
+		wantErr string
+	}{
+		{name: "empty", spec: workspacev1alpha1.WorkspaceTemplateSpec{}},
+		{
+			name: "zero cpu",
+			spec: workspacev1alpha1.WorkspaceTemplateSpec{
+				Resources: workspacev1alpha1.ResourceRequirements{CPU: "0"},
+			},
+			wantErr: "spec.resources.cpu",
+		},
+		{
+			name: "bad memory",
+			spec: workspacev1alpha1.WorkspaceTemplateSpec{
+				Resources: workspacev1alpha1.ResourceRequirements{Memory: "lots"},
+			},
+			wantErr: "spec.resources.memory",
+		},
+		{
+			name: "incomplete provider",
+			spec: workspacev1alpha1.WorkspaceTemplateSpec{
+				AIConfig: workspacev1alpha1.AIConfiguration{
+					Providers: []workspacev1alpha1.AIProvider{{Name: "p"}},
+				},
+			},
+			wantErr: "providers[0]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl := &workspacev1alpha1.WorkspaceTemplate{
+				ObjectMeta: metav1.ObjectMeta{Name: "t", Namespace: "default"},
+				Spec:       tt.spec,
+			}
+			err := ValidateTemplate(tmpl)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("err = %v, want mention of %q", err, tt.wantErr)
+			}
+		})
+	}
+}
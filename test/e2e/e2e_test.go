@@ -40,12 +40,12 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/wait"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
@@ -182,7 +182,7 @@ func probeTTYDViaPortForward(t *testing.T, cfg *rest.Config, namespace, podName
 func ensureNamespace(t *testing.T, ctx context.Context, c client.Client) {
 	t.Helper()
 	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: e2eNamespace}}
-	if err := c.Create(ctx, ns); err != nil && !errors.IsAlreadyExists(err) {
+	if err := c.Create(ctx, ns); err != nil && !apierrors.IsAlreadyExists(err) {
 		t.Fatalf("create namespace %s: %v", e2eNamespace, err)
 	}
 }
@@ -193,19 +193,19 @@ func cleanupWorkspace(t *testing.T, ctx context.Context, c client.Client, name s
 	ws := &workspacev1alpha1.Workspace{}
 	key := types.NamespacedName{Name: name, Namespace: e2eNamespace}
 	if err := c.Get(ctx, key, ws); err != nil {
-		if errors.IsNotFound(err) {
+		if apierrors.IsNotFound(err) {
 			return
 		}
 		t.Logf("Warning: failed to get workspace for cleanup: %v", err)
 		return
 	}
-	if err := c.Delete(ctx, ws); err != nil && !errors.IsNotFound(err) {
+	if err := c.Delete(ctx, ws); err != nil && !apierrors.IsNotFound(err) {
 		t.Logf("Warning: failed to delete workspace %s: %v", name, err)
 	}
 	// Wait for the CR to be fully removed (finalizer should be released by operator).
 	_ = wait.PollUntilContextTimeout(ctx, pollInterval, 30*time.Second, true, func(ctx context.Context) (bool, error) {
 		err := c.Get(ctx, key, &workspacev1alpha1.Workspace{})
-		return errors.IsNotFound(err), nil
+		return apierrors.IsNotFound(err), nil
 	})
 }
 
@@ -420,7 +420,7 @@ func TestWorkspaceDeletion(t *testing.T) {
 	t.Log("Waiting for Workspace CR to be fully deleted...")
 	if err := wait.PollUntilContextTimeout(ctx, pollInterval, 60*time.Second, true, func(ctx context.Context) (bool, error) {
 		err := c.Get(ctx, types.NamespacedName{Name: wsName, Namespace: e2eNamespace}, &workspacev1alpha1.Workspace{})
-		return errors.IsNotFound(err), nil
+		return apierrors.IsNotFound(err), nil
 	}); err != nil {
 		t.Errorf("Workspace CR not deleted within timeout: %v", err)
 	} else {
@@ -539,7 +539,7 @@ func TestGatewayWorkspaceAPISmoke(t *testing.T) {
 func pollUntilExists(ctx context.Context, c client.Client, obj client.Object, namespace, name, kind string) error {
 	return wait.PollUntilContextTimeout(ctx, pollInterval, 60*time.Second, true, func(ctx context.Context) (bool, error) {
 		err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, obj)
-		if errors.IsNotFound(err) {
+		if apierrors.IsNotFound(err) {
 			return false, nil
 		}
 		return err == nil, err